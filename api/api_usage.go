@@ -0,0 +1,175 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/apiusage"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+const defaultAPIUsagePeriod = 24 * time.Hour
+
+func apiQuotaError(err error) error {
+	switch err {
+	case apiusage.ErrInvalidQuota:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case apiusage.ErrQuotaNotFound, auth.ErrTeamNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: api usage report
+// path: /api-usage
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+func apiUsageReport(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	period := defaultAPIUsagePeriod
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		period, err = time.ParseDuration(v)
+		if err != nil || period <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for since: " + v}
+		}
+	}
+	contexts := permission.ContextsForPermission(t, permission.PermTeamReadApiUsage)
+	if len(contexts) == 0 {
+		return permission.ErrUnauthorized
+	}
+	teams := []string{}
+	for _, c := range contexts {
+		if c.CtxType == permission.CtxGlobal {
+			teams = nil
+			break
+		}
+		if c.CtxType == permission.CtxTeam {
+			teams = append(teams, c.Value)
+		}
+	}
+	if team := r.URL.Query().Get("team"); team != "" {
+		allowed := permission.Check(t, permission.PermTeamReadApiUsage, permission.Context(permission.CtxTeam, team))
+		if !allowed {
+			return permission.ErrUnauthorized
+		}
+		teams = []string{team}
+	}
+	usage, err := apiusage.Report(time.Now().Add(-period), teams)
+	if err != nil {
+		return err
+	}
+	if len(usage) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(usage)
+}
+
+// title: team api quota
+// path: /teams/{name}/api-quota
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func teamAPIQuotaGet(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	name := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamReadApiUsage,
+		permission.Context(permission.CtxTeam, name),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	limit, err := apiusage.TeamQuota(name)
+	if err != nil {
+		return apiQuotaError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(map[string]int{"limit": limit})
+}
+
+// title: team api quota set
+// path: /teams/{name}/api-quota
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Team not found
+func teamAPIQuotaSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamUpdateApiQuota,
+		permission.Context(permission.CtxTeam, name),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for limit: " + r.FormValue("limit")}
+	}
+	_, err = auth.GetTeam(name)
+	if err != nil {
+		return apiQuotaError(err)
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     teamTarget(name),
+		Kind:       permission.PermTeamUpdateApiQuota,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return apiQuotaError(apiusage.SetTeamQuota(name, limit))
+}
+
+// title: team api quota unset
+// path: /teams/{name}/api-quota
+// method: DELETE
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: Not found
+func teamAPIQuotaUnset(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamUpdateApiQuota,
+		permission.Context(permission.CtxTeam, name),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     teamTarget(name),
+		Kind:       permission.PermTeamUpdateApiQuota,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return apiQuotaError(apiusage.RemoveTeamQuota(name))
+}
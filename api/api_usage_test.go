@@ -0,0 +1,205 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/apiusage"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAPIUsageMiddleware(c *check.C) {
+	config.Set("api-usage:enabled", true)
+	defer config.Unset("api-usage")
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	err = apiusage.SetTeamQuota(s.team.Name, 1)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/?:app=myapp", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, token)
+	h, log := doHandler()
+	apiUsageMiddleware(httptest.NewRecorder(), request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(context.GetRequestError(request), check.IsNil)
+	request, err = http.NewRequest("GET", "/?:app=myapp", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, token)
+	h, log = doHandler()
+	apiUsageMiddleware(httptest.NewRecorder(), request, h)
+	c.Assert(log.called, check.Equals, false)
+	e, ok := context.GetRequestError(request).(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusTooManyRequests)
+	usage, err := apiusage.Report(time.Now().Add(-time.Hour), nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, []apiusage.Usage{
+		{Team: s.team.Name, Owner: token.GetUserName(), Calls: 2},
+	})
+}
+
+func (s *S) TestAPIUsageMiddlewareChargesResourceTeam(c *check.C) {
+	config.Set("api-usage:enabled", true)
+	defer config.Unset("api-usage")
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	err := apiusage.SetTeamQuota(s.team.Name, 1)
+	c.Assert(err, check.IsNil)
+	for i := 0; i < 2; i++ {
+		request, err := http.NewRequest("GET", "/apps", nil)
+		c.Assert(err, check.IsNil)
+		context.SetAuthToken(request, token)
+		h, log := doHandler()
+		apiUsageMiddleware(httptest.NewRecorder(), request, h)
+		c.Assert(log.called, check.Equals, true)
+		c.Assert(context.GetRequestError(request), check.IsNil)
+	}
+	usage, err := apiusage.Report(time.Now().Add(-time.Hour), nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, []apiusage.Usage{
+		{Team: "", Owner: token.GetUserName(), Calls: 2},
+	})
+}
+
+func (s *S) TestAPIUsageMiddlewareDisabled(c *check.C) {
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, s.token)
+	h, log := doHandler()
+	apiUsageMiddleware(httptest.NewRecorder(), request, h)
+	c.Assert(log.called, check.Equals, true)
+	usage, err := apiusage.Report(time.Now().Add(-time.Hour), nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.HasLen, 0)
+}
+
+func (s *S) TestAPIUsageReport(c *check.C) {
+	err := apiusage.Track("bot@example.com", s.team.Name)
+	c.Assert(err, check.IsNil)
+	err = apiusage.Track("other@example.com", "otherteam")
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/api-usage?since=1h", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var usage []apiusage.Usage
+	err = json.Unmarshal(recorder.Body.Bytes(), &usage)
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.HasLen, 2)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamReadApiUsage,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err = http.NewRequest("GET", "/api-usage", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	err = json.Unmarshal(recorder.Body.Bytes(), &usage)
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, []apiusage.Usage{
+		{Team: s.team.Name, Owner: "bot@example.com", Calls: 1},
+	})
+	request, err = http.NewRequest("GET", "/api-usage?team=otherteam", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAPIUsageReportInvalidSince(c *check.C) {
+	request, err := http.NewRequest("GET", "/api-usage?since=yesterday", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestTeamAPIQuotaSetAndUnset(c *check.C) {
+	body := strings.NewReader("limit=100")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/teams/%s/api-quota", s.team.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(eventtest.EventDesc{
+		Target: teamTarget(s.team.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "team.update.api-quota",
+		StartCustomData: []map[string]interface{}{
+			{"name": "limit", "value": "100"},
+			{"name": ":name", "value": s.team.Name},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("GET", fmt.Sprintf("/teams/%s/api-quota", s.team.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, `{"limit":100}`+"\n")
+	request, err = http.NewRequest("DELETE", fmt.Sprintf("/teams/%s/api-quota", s.team.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = apiusage.TeamQuota(s.team.Name)
+	c.Assert(err, check.Equals, apiusage.ErrQuotaNotFound)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestTeamAPIQuotaSetErrors(c *check.C) {
+	tests := []struct {
+		team string
+		body string
+		code int
+	}{
+		{s.team.Name, "limit=abc", http.StatusBadRequest},
+		{s.team.Name, "limit=0", http.StatusBadRequest},
+		{"unknown", "limit=10", http.StatusNotFound},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		request, err := http.NewRequest("PUT", fmt.Sprintf("/teams/%s/api-quota", tt.team), strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "b "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, tt.code, check.Commentf("%s %q", tt.team, tt.body))
+	}
+}
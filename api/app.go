@@ -42,7 +42,7 @@ func getAppFromContext(name string, r *http.Request) (app.App, error) {
 	var err error
 	a := context.GetApp(r)
 	if a == nil {
-		a, err = getApp(name)
+		a, err = getApp(name, r)
 		if err != nil {
 			return app.App{}, err
 		}
@@ -51,10 +51,10 @@ func getAppFromContext(name string, r *http.Request) (app.App, error) {
 	return *a, nil
 }
 
-func getApp(name string) (*app.App, error) {
+func getApp(name string, r *http.Request) (*app.App, error) {
 	a, err := app.GetByName(name)
 	if err != nil {
-		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", name)}
+		return nil, newCodedError(r, http.StatusNotFound, errors.ErrorCodeAppNotFound, fmt.Sprintf("App %s not found.", name))
 	}
 	return a, nil
 }
@@ -241,6 +241,48 @@ type inputApp struct {
 	Pool        string
 	Router      string
 	RouterOpts  map[string]string
+	Daemon      bool
+}
+
+func createAppError(r *http.Request, err error) error {
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	if _, ok := err.(app.NoTeamsError); ok {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "In order to create an app, you should be member of at least one team",
+		}
+	}
+	if e, ok := err.(*app.AppCreationError); ok {
+		if e.Err == app.ErrAppAlreadyExists {
+			return newCodedError(r, http.StatusConflict, errors.ErrorCodeAppAlreadyExists, e.Error())
+		}
+		if _, ok := e.Err.(*quota.QuotaExceededError); ok {
+			return newCodedError(r, http.StatusForbidden, errors.ErrorCodeQuotaExceeded, "Quota exceeded")
+		}
+	}
+	if err == app.InvalidPlatformError {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if _, ok := err.(*provision.PoolQuotaExceededError); ok {
+		return newCodedError(r, http.StatusForbidden, errors.ErrorCodeQuotaExceeded, err.Error())
+	}
+	return err
+}
+
+// dryRunParam parses the dry-run parameter of the request, false when it's
+// missing.
+func dryRunParam(r *http.Request) (bool, error) {
+	v := r.FormValue("dry-run")
+	if v == "" {
+		return false, nil
+	}
+	isDryRun, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for dry-run: " + v}
+	}
+	return isDryRun, nil
 }
 
 // title: app create
@@ -249,6 +291,7 @@ type inputApp struct {
 // consume: application/x-www-form-urlencoded
 // produce: application/json
 // responses:
+//   200: Dry run result
 //   201: App created
 //   400: Invalid data
 //   401: Unauthorized
@@ -274,6 +317,14 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 		RouterOpts:  ia.RouterOpts,
 		Router:      ia.Router,
 		Tags:        r.Form["tag"],
+		Daemon:      ia.Daemon,
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	if a.TeamOwner == "" {
+		a.TeamOwner = u.Settings.DefaultTeam
 	}
 	if a.TeamOwner == "" {
 		a.TeamOwner, err = permission.TeamForPermission(t, permission.PermAppCreate)
@@ -297,10 +348,6 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if !canCreate {
 		return permission.ErrUnauthorized
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	platform, err := app.GetPlatform(a.Platform)
 	if err != nil {
 		return err
@@ -312,6 +359,30 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 			return &errors.HTTP{Code: http.StatusBadRequest, Message: app.InvalidPlatformError.Error()}
 		}
 	}
+	err = checkPolicy(t, permission.PermAppCreate, appTarget(a.Name), map[string]interface{}{
+		"name":      a.Name,
+		"platform":  a.Platform,
+		"plan":      a.Plan.Name,
+		"pool":      a.Pool,
+		"teamOwner": a.TeamOwner,
+		"router":    a.Router,
+		"tags":      a.Tags,
+	})
+	if err != nil {
+		return err
+	}
+	isDryRun, err := dryRunParam(r)
+	if err != nil {
+		return err
+	}
+	if isDryRun {
+		result, dryErr := app.CreateAppDryRun(&a, u)
+		if dryErr != nil {
+			return createAppError(r, dryErr)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(result)
+	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(a.Name),
 		Kind:       permission.PermAppCreate,
@@ -322,34 +393,18 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if err != nil {
 		return err
 	}
-	defer func() { evt.Done(err) }()
+	requestedPool := a.Pool
+	defer func() {
+		endData := map[string]string{"pool": a.Pool}
+		if requestedPool == "" {
+			endData["poolSelector"] = provision.PoolSelectorName()
+		}
+		evt.DoneCustomData(err, endData)
+	}()
 	err = app.CreateApp(&a, u)
 	if err != nil {
 		log.Errorf("Got error while creating app: %s", err)
-		if e, ok := err.(*errors.ValidationError); ok {
-			return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
-		}
-		if _, ok := err.(app.NoTeamsError); ok {
-			return &errors.HTTP{
-				Code:    http.StatusBadRequest,
-				Message: "In order to create an app, you should be member of at least one team",
-			}
-		}
-		if e, ok := err.(*app.AppCreationError); ok {
-			if e.Err == app.ErrAppAlreadyExists {
-				return &errors.HTTP{Code: http.StatusConflict, Message: e.Error()}
-			}
-			if _, ok := e.Err.(*quota.QuotaExceededError); ok {
-				return &errors.HTTP{
-					Code:    http.StatusForbidden,
-					Message: "Quota exceeded",
-				}
-			}
-		}
-		if err == app.InvalidPlatformError {
-			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
-		}
-		return err
+		return createAppError(r, err)
 	}
 	repo, err := repository.Manager().GetRepository(a.Name)
 	if err != nil {
@@ -377,6 +432,7 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 // produce: application/x-json-stream
 // responses:
 //   200: App updated
+//   400: Invalid data
 //   401: Unauthorized
 //   404: Not found
 func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
@@ -390,6 +446,7 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 		Pool:        r.FormValue("pool"),
 		Description: r.FormValue("description"),
 		Router:      r.FormValue("router"),
+		Platform:    r.FormValue("platform"),
 		Tags:        r.Form["tag"],
 	}
 	appName := r.URL.Query().Get(":appname")
@@ -397,6 +454,10 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if err != nil {
 		return err
 	}
+	updateData.DeployToggles, err = deployTogglesParams(r, a.GetDeployToggles())
+	if err != nil {
+		return err
+	}
 	var wantedPerms []*permission.PermissionScheme
 	if updateData.Description != "" {
 		wantedPerms = append(wantedPerms, permission.PermAppUpdateDescription)
@@ -416,8 +477,14 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if updateData.Router != "" {
 		wantedPerms = append(wantedPerms, permission.PermAppUpdateRouter)
 	}
+	if updateData.Platform != "" {
+		wantedPerms = append(wantedPerms, permission.PermAppUpdatePlatform)
+	}
+	if updateData.DeployToggles != nil {
+		wantedPerms = append(wantedPerms, permission.PermAppUpdateDeployToggles)
+	}
 	if len(wantedPerms) == 0 {
-		msg := "Neither the description, plan, pool, router or team owner were set. You must define at least one."
+		msg := "Neither the description, plan, pool, router, platform or team owner were set. You must define at least one."
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
 	for _, perm := range wantedPerms {
@@ -428,6 +495,19 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 			return permission.ErrUnauthorized
 		}
 	}
+	if updateData.Platform != "" {
+		platform, errPlat := app.GetPlatform(updateData.Platform)
+		if errPlat != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: errPlat.Error()}
+		}
+		if platform.Disabled {
+			canUsePlat := permission.Check(t, permission.PermPlatformUpdate) ||
+				permission.Check(t, permission.PermPlatformCreate)
+			if !canUsePlat {
+				return &errors.HTTP{Code: http.StatusBadRequest, Message: app.InvalidPlatformError.Error()}
+			}
+		}
+	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdate,
@@ -450,9 +530,43 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if _, ok := err.(*router.ErrRouterNotFound); ok {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	}
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
 	return err
 }
 
+// deployTogglesParams returns the deploy toggles of the app with the values
+// set in the request applied over the current ones, or nil when the request
+// changes none of them.
+func deployTogglesParams(r *http.Request, toggles provision.DeployToggles) (*provision.DeployToggles, error) {
+	params := []struct {
+		name  string
+		value *bool
+	}{
+		{"disableAutoRollback", &toggles.DisableAutoRollback},
+		{"verboseBuild", &toggles.VerboseBuild},
+		{"skipHealthcheck", &toggles.SkipHealthcheck},
+	}
+	var changed bool
+	for _, p := range params {
+		v := r.FormValue(p.name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid value for %s: %s", p.name, v)}
+		}
+		*p.value = b
+		changed = true
+	}
+	if !changed {
+		return nil, nil
+	}
+	return &toggles, nil
+}
+
 func numberOfUnits(r *http.Request) (uint, error) {
 	unitsStr := r.FormValue("units")
 	if unitsStr == "" {
@@ -471,15 +585,26 @@ func numberOfUnits(r *http.Request) (uint, error) {
 	return uint(n), nil
 }
 
+func addUnitsDryRunError(r *http.Request, err error) error {
+	switch e := err.(type) {
+	case *errors.ValidationError:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	case *quota.QuotaExceededError, *provision.PoolQuotaExceededError:
+		return newCodedError(r, http.StatusForbidden, errors.ErrorCodeQuotaExceeded, err.Error())
+	}
+	return err
+}
+
 // title: add units
 // path: /apps/{name}/units
 // method: PUT
 // consume: application/x-www-form-urlencoded
-// produce: application/x-json-stream
+// produce: application/x-json-stream, application/json
 // responses:
 //   200: Units added
 //   400: Invalid data
 //   401: Unauthorized
+//   403: Quota exceeded
 //   404: App not found
 func addUnits(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	n, err := numberOfUnits(r)
@@ -498,6 +623,21 @@ func addUnits(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	if a.Daemon {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: app.ErrDaemonUnits.Error()}
+	}
+	isDryRun, err := dryRunParam(r)
+	if err != nil {
+		return err
+	}
+	if isDryRun {
+		result, dryErr := a.AddUnitsDryRun(n, processName)
+		if dryErr != nil {
+			return addUnitsDryRunError(r, dryErr)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(result)
+	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateUnitAdd,
@@ -542,13 +682,26 @@ func removeUnits(w http.ResponseWriter, r *http.Request, t auth.Token) (err erro
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	evt, err := event.New(&event.Opts{
+	if a.Daemon {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: app.ErrDaemonUnits.Error()}
+	}
+	scaleOpts, err := scaleDownOptions(r)
+	if err != nil {
+		return err
+	}
+	gradual := scaleOpts.IsGradual(n)
+	evtOpts := &event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateUnitRemove,
 		Owner:      t,
 		CustomData: event.FormToCustomData(r.Form),
 		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
-	})
+	}
+	if gradual {
+		evtOpts.Cancelable = true
+		evtOpts.AllowedCancel = event.Allowed(permission.PermAppUpdateEvents, contextsForApp(&a)...)
+	}
+	evt, err := event.New(evtOpts)
 	if err != nil {
 		return err
 	}
@@ -557,7 +710,39 @@ func removeUnits(w http.ResponseWriter, r *http.Request, t auth.Token) (err erro
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	return a.RemoveUnits(n, processName, writer)
+	if !gradual {
+		return a.RemoveUnits(n, processName, writer)
+	}
+	scaleOpts.Event = evt
+	return a.RemoveUnitsGradually(n, processName, scaleOpts, writer)
+}
+
+// scaleDownOptions returns the options for a gradual removal of units, using
+// the step and interval parameters when set and the server defaults
+// otherwise. A step of zero disables the gradual removal.
+func scaleDownOptions(r *http.Request) (app.ScaleDownOptions, error) {
+	opts := app.ScaleDownDefaults()
+	if stepStr := r.FormValue("step"); stepStr != "" {
+		step, err := strconv.ParseUint(stepStr, 10, 32)
+		if err != nil {
+			return opts, &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid step: the step must be a non negative integer.",
+			}
+		}
+		opts.Step = uint(step)
+	}
+	if intervalStr := r.FormValue("interval"); intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil || interval < 0 {
+			return opts, &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid interval: the interval must be a duration, like 30s.",
+			}
+		}
+		opts.Interval = interval
+	}
+	return opts, nil
 }
 
 // title: set unit status
@@ -879,6 +1064,17 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	envNames := make([]string, len(e.Envs))
+	for i, v := range e.Envs {
+		envNames[i] = v.Name
+	}
+	err = checkPolicy(t, permission.PermAppUpdateEnvSet, appTarget(appName), map[string]interface{}{
+		"envs":    envNames,
+		"private": e.Private,
+	})
+	if err != nil {
+		return err
+	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateEnvSet,
@@ -889,7 +1085,9 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if err != nil {
 		return err
 	}
-	defer func() { evt.Done(err) }()
+	before := envsCustomData(a.Envs())
+	var after map[string]string
+	defer func() { evt.DoneWithDiff(err, before, after) }()
 	envs := map[string]string{}
 	variables := []bind.EnvVar{}
 	for _, v := range e.Envs {
@@ -900,13 +1098,32 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	return a.SetEnvs(
+	err = a.SetEnvs(
 		bind.SetEnvApp{
 			Envs:          variables,
 			PublicOnly:    true,
 			ShouldRestart: !e.NoRestart,
 		}, writer,
 	)
+	if err != nil {
+		return err
+	}
+	after = envsCustomData(a.Envs())
+	return nil
+}
+
+// envsCustomData returns the envs of an app as stored in env events, hiding
+// the values of private envs.
+func envsCustomData(envs map[string]bind.EnvVar) map[string]string {
+	data := make(map[string]string, len(envs))
+	for name, env := range envs {
+		value := env.Value
+		if !env.Public {
+			value = "*****"
+		}
+		data[name] = value
+	}
+	return data
 }
 
 // title: unset envs
@@ -974,6 +1191,7 @@ func unsetEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 //   400: Invalid data
 //   401: Unauthorized
 //   404: App not found
+//   409: Conflict with another app or installation
 func setCName(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	cNameMsg := "You must provide the cname."
 	err = r.ParseForm()
@@ -995,6 +1213,15 @@ func setCName(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	force, _ := strconv.ParseBool(r.FormValue("force"))
+	if force {
+		allowed = permission.Check(t, permission.PermAppAdminCname,
+			contextsForApp(&a)...,
+		)
+		if !allowed {
+			return permission.ErrUnauthorized
+		}
+	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateCnameAdd,
@@ -1006,15 +1233,95 @@ func setCName(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 		return err
 	}
 	defer func() { evt.Done(err) }()
-	if err = a.AddCName(cnames...); err == nil {
+	if force {
+		err = a.ForceAddCName(cnames...)
+	} else {
+		err = a.AddCName(cnames...)
+	}
+	if err == nil {
 		return nil
 	}
 	if err.Error() == "Invalid cname" {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	}
+	if _, ok := err.(*app.CNameNotVerifiedError); ok {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: err.Error() + ". Users with the app.admin.cname permission may add it anyway using the force flag.",
+		}
+	}
+	if _, ok := err.(*app.CNameConflictError); ok {
+		return &errors.HTTP{
+			Code:    http.StatusConflict,
+			Message: err.Error() + ". Users with the app.admin.cname permission may add it anyway using the force flag.",
+		}
+	}
 	return err
 }
 
+// title: cname verification info
+// path: /apps/{app}/cname/{cname}/verification
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: App or verification not found
+func cnameVerificationInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	verification, err := a.GetCNameVerification(r.URL.Query().Get(":cname"))
+	if err == app.ErrCNameVerificationNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(verification)
+}
+
+// title: verify cname
+// path: /apps/{app}/cname/{cname}/verification
+// method: POST
+// produce: application/json
+// responses:
+//   200: Ok
+//   400: Invalid cname
+//   401: Unauthorized
+//   404: App not found
+func cnameVerify(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateCnameAdd,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	verification, err := a.VerifyCName(r.URL.Query().Get(":cname"))
+	if err == app.ErrInvalidCName {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(verification)
+}
+
 // title: unset cname
 // path: /apps/{app}/cname
 // method: DELETE
@@ -1441,14 +1748,14 @@ func swap(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		return err
 	}
 	defer app.ReleaseApplicationLock(app2Name)
-	app1, err := getApp(app1Name)
+	app1, err := getApp(app1Name, r)
 	if err != nil {
 		return err
 	}
 	if !locked1 {
 		return &errors.HTTP{Code: http.StatusConflict, Message: fmt.Sprintf("%s: %s", app1.Name, &app1.Lock)}
 	}
-	app2, err := getApp(app2Name)
+	app2, err := getApp(app2Name, r)
 	if err != nil {
 		return err
 	}
@@ -1704,6 +2011,84 @@ func appMetricEnvs(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return json.NewEncoder(w).Encode(metricMap)
 }
 
+// title: app units disk usage
+// path: /apps/{app}/units/disk-usage
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   400: Not supported by the provisioner
+//   401: Unauthorized
+//   404: App not found
+func appUnitsDiskUsage(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	usage, err := a.UnitsDiskUsage()
+	if err != nil {
+		if _, ok := err.(provision.ProvisionerNotSupported); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"limit": a.GetEphemeralStorage(),
+		"units": usage,
+	})
+}
+
+// title: app provisioner events
+// path: /apps/{app}/provisioner-events
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   204: No content
+//   400: Invalid data or not supported by the provisioner
+//   401: Unauthorized
+//   404: App not found
+func appProvisionerEvents(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	period := time.Hour
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		period, err = time.ParseDuration(v)
+		if err != nil || period <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for since: " + v}
+		}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	events, err := a.UnitsEvents(time.Now().Add(-period))
+	if err != nil {
+		if _, ok := err.(provision.ProvisionerNotSupported); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	if len(events) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(events)
+}
+
 // title: rebuild routes
 // path: /apps/{app}/routes
 // method: POST
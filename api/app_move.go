@@ -0,0 +1,95 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+)
+
+type appMoveParams struct {
+	Pool string `json:"pool"`
+	// HealthTimeout is the number of seconds the new units have to become
+	// healthy.
+	HealthTimeout int `json:"healthTimeout"`
+}
+
+// title: app move to pool
+// path: /apps/{app}/pool
+// method: POST
+// consume: application/x-www-form-urlencoded, application/json
+// produce: application/x-json-stream
+// responses:
+//   200: App moved
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App or pool not found
+func appMoveToPool(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	var params appMoveParams
+	err = decodeBody(r, &params)
+	if err != nil {
+		return err
+	}
+	if params.Pool == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "pool is required"}
+	}
+	if params.HealthTimeout < 0 {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "health timeout must not be negative"}
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdatePool, contextsForApp(&a)...)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:        appTarget(appName),
+		Kind:          permission.PermAppUpdatePool,
+		Owner:         t,
+		CustomData:    bodyCustomData(r, params),
+		Allowed:       event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, contextsForApp(&a)...),
+		Cancelable:    true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	writer := newJSONMessageStream(w, r, 15*time.Second)
+	defer writer.Close()
+	evt.SetLogWriter(writer)
+	oldPool := a.Pool
+	err = a.MoveToPool(app.MovePoolOptions{
+		Pool:          params.Pool,
+		HealthTimeout: time.Duration(params.HealthTimeout) * time.Second,
+		Writer:        evt,
+		Event:         evt,
+	})
+	if err == provision.ErrPoolNotFound {
+		return newCodedError(r, http.StatusNotFound, errors.ErrorCodePoolNotFound, err.Error())
+	}
+	switch err.(type) {
+	case *errors.ValidationError, provision.ProvisionerNotSupported:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case *provision.PoolQuotaExceededError:
+		return newCodedError(r, http.StatusForbidden, errors.ErrorCodeQuotaExceeded, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "App %q successfully moved from pool %q to %q!\n", appName, oldPool, params.Pool)
+	return nil
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/permission/permissiontest"
@@ -771,6 +772,10 @@ func (s *S) TestCreateApp(c *check.C) {
 			{"name": "name", "value": a.Name},
 			{"name": "platform", "value": "zend"},
 		},
+		EndCustomData: map[string]interface{}{
+			"pool":         gotApp.Pool,
+			"poolSelector": "default",
+		},
 	}, eventtest.HasEvent)
 	_, err = repository.Manager().GetRepository(a.Name)
 	c.Assert(err, check.IsNil)
@@ -835,6 +840,92 @@ func (s *S) TestCreateAppTeamOwner(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestCreateAppDefaultTeamFromUserSettings(c *check.C) {
+	t1 := auth.Team{Name: "team1"}
+	err := s.conn.Teams().Insert(t1)
+	c.Assert(err, check.IsNil)
+	t2 := auth.Team{Name: "team2"}
+	err = s.conn.Teams().Insert(t2)
+	c.Assert(err, check.IsNil)
+	permissions := []permission.Permission{
+		{
+			Scheme:  permission.PermAppCreate,
+			Context: permission.PermissionContext{CtxType: permission.CtxTeam, Value: "team1"},
+		},
+		{
+			Scheme:  permission.PermAppCreate,
+			Context: permission.PermissionContext{CtxType: permission.CtxTeam, Value: "team2"},
+		},
+	}
+	u, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "anotheruser", permissions...)
+	err = u.UpdateSettings(auth.UserSettings{DefaultTeam: "team2"})
+	c.Assert(err, check.IsNil)
+	data := "name=someapp&platform=zend"
+	request, err := http.NewRequest("POST", "/apps", strings.NewReader(data))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var gotApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "someapp"}).One(&gotApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(gotApp.TeamOwner, check.Equals, "team2")
+}
+
+func (s *S) TestCreateAppDryRun(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{Address: "http://n1:2375", Metadata: map[string]string{"pool": "test1"}})
+	c.Assert(err, check.IsNil)
+	data := "name=someapp&platform=zend&teamOwner=" + s.team.Name + "&dry-run=true"
+	request, err := http.NewRequest("POST", "/apps", strings.NewReader(data))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result app.DryRunResult
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.App, check.Equals, "someapp")
+	c.Assert(result.Pool, check.Equals, "test1")
+	c.Assert(result.Placement, check.DeepEquals, []provision.UnitPlacement{{Node: "http://n1:2375"}})
+	_, err = app.GetByName("someapp")
+	c.Assert(err, check.Equals, app.ErrAppNotFound)
+}
+
+func (s *S) TestCreateAppDryRunAlreadyExists(c *check.C) {
+	a := app.App{Name: "someapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	data := "name=someapp&platform=zend&teamOwner=" + s.team.Name + "&dry-run=true"
+	request, err := http.NewRequest("POST", "/apps", strings.NewReader(data))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestCreateAppInvalidDryRun(c *check.C) {
+	data := "name=someapp&platform=zend&teamOwner=" + s.team.Name + "&dry-run=maybe"
+	request, err := http.NewRequest("POST", "/apps", strings.NewReader(data))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid value for dry-run: maybe\n")
+}
+
 func (s *S) TestCreateAppAdminSingleTeam(c *check.C) {
 	a := app.App{Name: "someapp"}
 	data := "name=someapp&platform=zend"
@@ -1015,6 +1106,26 @@ func (s *S) TestCreateAppWithTags(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestCreateDaemonApp(c *check.C) {
+	b := strings.NewReader("name=someapp&platform=zend&daemon=true")
+	request, err := http.NewRequest("POST", "/apps", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var gotApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "someapp"}).One(&gotApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(gotApp.Daemon, check.Equals, true)
+}
+
 func (s *S) TestCreateAppWithPool(c *check.C) {
 	err := provision.AddPool(provision.AddPoolOptions{Name: "mypool1", Public: true})
 	c.Assert(err, check.IsNil)
@@ -1352,6 +1463,83 @@ func (s *S) TestUpdateAppWithDescriptionOnly(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestUpdateAppDeployToggles(c *check.C) {
+	a := app.App{
+		Name:          "myapp",
+		Platform:      "zend",
+		TeamOwner:     s.team.Name,
+		DeployToggles: &provision.DeployToggles{VerboseBuild: true},
+	}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateDeployToggles,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	b := strings.NewReader("skipHealthcheck=true&disableAutoRollback=1")
+	request, err := http.NewRequest("PUT", "/apps/myapp", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var gotApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "myapp"}).One(&gotApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(gotApp.GetDeployToggles(), check.DeepEquals, provision.DeployToggles{
+		DisableAutoRollback: true,
+		VerboseBuild:        true,
+		SkipHealthcheck:     true,
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("myapp"),
+		Owner:  token.GetUserName(),
+		Kind:   "app.update",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":appname", "value": a.Name},
+			{"name": "skipHealthcheck", "value": "true"},
+			{"name": "disableAutoRollback", "value": "1"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestUpdateAppDeployTogglesInvalidValue(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	b := strings.NewReader("verboseBuild=maybe")
+	request, err := http.NewRequest("PUT", "/apps/myapp", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid value for verboseBuild: maybe\n")
+}
+
+func (s *S) TestUpdateAppDeployTogglesWithoutPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateDescription,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	b := strings.NewReader("skipHealthcheck=true")
+	request, err := http.NewRequest("PUT", "/apps/myapp", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestUpdateAppWithTagsOnly(c *check.C) {
 	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
@@ -1442,6 +1630,50 @@ func (s *S) TestUpdateAppRouterNotFound(c *check.C) {
 	c.Check(recorder.Body.String(), check.Equals, expectedErr.Error()+"\n")
 }
 
+func (s *S) TestUpdateAppPlatform(c *check.C) {
+	err := s.conn.Platforms().Insert(app.Platform{Name: "python"})
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("platform=python")
+	request, err := http.NewRequest("PUT", "/apps/myappx", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Platform, check.Equals, "python")
+}
+
+func (s *S) TestUpdateAppPlatformNotAllowedInPool(c *check.C) {
+	err := s.conn.Platforms().Insert(app.Platform{Name: "python"})
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolConstraint(&provision.PoolConstraint{
+		PoolExpr: a.Pool,
+		Field:    "platform",
+		Values:   []string{"zend"},
+	})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("platform=python")
+	request, err := http.NewRequest("PUT", "/apps/myappx", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, fmt.Sprintf("platform \"python\" is not allowed in pool %q\n", a.Pool))
+}
+
 func (s *S) TestUpdateAppWithPoolOnly(c *check.C) {
 	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
@@ -1561,7 +1793,7 @@ func (s *S) TestUpdateAppWithoutFlag(c *check.C) {
 	recorder := httptest.NewRecorder()
 	m := RunServer(true)
 	m.ServeHTTP(recorder, request)
-	errorMessage := "Neither the description, plan, pool, router or team owner were set. You must define at least one.\n"
+	errorMessage := "Neither the description, plan, pool, router, platform or team owner were set. You must define at least one.\n"
 	c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
 	c.Check(recorder.Body.String(), check.Equals, errorMessage)
 }
@@ -1686,6 +1918,68 @@ func (s *S) TestAddUnits(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"added 3 units"}`+"\n")
 }
 
+func (s *S) TestAddUnitsDryRun(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{Address: "http://n1:2375", Metadata: map[string]string{"pool": "test1"}})
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "armorandsword", Platform: "zend", TeamOwner: s.team.Name, Quota: quota.Unlimited}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("units=2&process=web&dry-run=true")
+	request, err := http.NewRequest("PUT", "/apps/armorandsword/units", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result app.DryRunResult
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Placement, check.DeepEquals, []provision.UnitPlacement{
+		{Process: "web", Node: "http://n1:2375"},
+		{Process: "web", Node: "http://n1:2375"},
+	})
+	c.Assert(s.provisioner.GetUnits(&a), check.HasLen, 0)
+}
+
+func (s *S) TestAddUnitsDryRunPoolQuotaExceeded(c *check.C) {
+	a := app.App{Name: "armorandsword", Platform: "zend", TeamOwner: s.team.Name, Quota: quota.Unlimited}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolQuota("test1", provision.PoolQuota{MaxUnits: 1})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("units=2&process=web&dry-run=true")
+	request, err := http.NewRequest("PUT", "/apps/armorandsword/units", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAddUnitsDaemonApp(c *check.C) {
+	a := app.App{Name: "armorandsword", Platform: "zend", TeamOwner: s.team.Name, Quota: quota.Unlimited, Daemon: true}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("units=3&process=web")
+	request, err := http.NewRequest("PUT", "/apps/armorandsword/units", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrDaemonUnits.Error()+"\n")
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 0)
+}
+
 func (s *S) TestAddUnitsReturns404IfAppDoesNotExist(c *check.C) {
 	body := strings.NewReader("units=1&process=web")
 	request, err := http.NewRequest("PUT", "/apps/armorandsword/units?:app=armorandsword", body)
@@ -1831,6 +2125,68 @@ func (s *S) TestRemoveUnits(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"removing 2 units"}`+"\n")
 }
 
+func (s *S) TestRemoveUnitsGradually(c *check.C) {
+	a := app.App{Name: "velha", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 5, "web", nil)
+	request, err := http.NewRequest("DELETE", "/apps/velha/units?units=3&process=web&step=2&interval=1ms", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(s.provisioner.GetUnits(&a), check.HasLen, 2)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Removing 2 units \(0 of 3 removed\).*removing 2 units.*Removing 1 units \(2 of 3 removed\).*removing 1 units.*`)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("velha"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.unit.remove",
+		StartCustomData: []map[string]interface{}{
+			{"name": "units", "value": "3"},
+			{"name": "step", "value": "2"},
+		},
+	}, eventtest.HasEvent)
+	n, err := s.conn.Events().Find(bson.M{"kind.name": "app.update.unit.remove", "cancelable": true}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+}
+
+func (s *S) TestRemoveUnitsGraduallyFromConfig(c *check.C) {
+	config.Set("units:scale-down:max-step", 1)
+	defer config.Unset("units:scale-down:max-step")
+	config.Set("units:scale-down:interval", "1ms")
+	defer config.Unset("units:scale-down:interval")
+	a := app.App{Name: "velha", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 3, "web", nil)
+	request, err := http.NewRequest("DELETE", "/apps/velha/units?units=2&process=web", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(s.provisioner.GetUnits(&a), check.HasLen, 1)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Removing 1 units \(1 of 2 removed\).*`)
+}
+
+func (s *S) TestRemoveUnitsInvalidStep(c *check.C) {
+	a := app.App{Name: "velha", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/velha/units?units=2&process=web&step=-1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "Invalid step: the step must be a non negative integer.\n")
+}
+
 func (s *S) TestRemoveUnitsReturns404IfAppDoesNotExist(c *check.C) {
 	request, err := http.NewRequest("DELETE", "/apps/fetisha/units?:app=fetisha&units=1&process=web", nil)
 	c.Assert(err, check.IsNil)
@@ -2872,6 +3228,9 @@ func (s *S) TestSetEnvHandlerShouldSetAPrivateEnvironmentVariableInTheApp(c *che
 			{"name": "NoRestart", "value": ""},
 			{"name": "Private", "value": "true"},
 		},
+		Changes: []event.Change{
+			{Field: "DATABASE_HOST", After: "*****"},
+		},
 	}, eventtest.HasEvent)
 }
 
@@ -3477,6 +3836,130 @@ func (s *S) TestAddCNameHandlerReturnsBadRequestWhenCNameIsEmpty(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestAddCNameHandlerNotVerified(c *check.C) {
+	config.Set("cname:verification:enabled", true)
+	defer config.Unset("cname:verification:enabled")
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/leper/cname", strings.NewReader("cname=leper.secretcompany.com"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, `ownership of cname "leper.secretcompany.com" not verified, .*force flag.\n`)
+}
+
+func (s *S) TestCNameVerificationInfo(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.conn.CNameVerifications().Insert(app.CNameVerification{
+		CName:  "leper.secretcompany.com",
+		App:    "leper",
+		Record: "_tsuru-challenge.leper.secretcompany.com",
+		Token:  "tsuru-verification=abc",
+	})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/leper/cname/leper.secretcompany.com/verification", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var verification app.CNameVerification
+	err = json.Unmarshal(recorder.Body.Bytes(), &verification)
+	c.Assert(err, check.IsNil)
+	c.Assert(verification.CName, check.Equals, "leper.secretcompany.com")
+	c.Assert(verification.Record, check.Equals, "_tsuru-challenge.leper.secretcompany.com")
+	c.Assert(verification.Token, check.Equals, "tsuru-verification=abc")
+	c.Assert(verification.Verified, check.Equals, false)
+}
+
+func (s *S) TestCNameVerificationInfoNotFound(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/leper/cname/leper.secretcompany.com/verification", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrCNameVerificationNotFound.Error()+"\n")
+}
+
+func (s *S) TestCNameVerify(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.conn.CNameVerifications().Insert(app.CNameVerification{
+		CName:    "leper.secretcompany.com",
+		App:      "leper",
+		Record:   "_tsuru-challenge.leper.secretcompany.com",
+		Token:    "tsuru-verification=abc",
+		Verified: true,
+	})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateCnameAdd,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps/leper/cname/leper.secretcompany.com/verification", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var verification app.CNameVerification
+	err = json.Unmarshal(recorder.Body.Bytes(), &verification)
+	c.Assert(err, check.IsNil)
+	c.Assert(verification.Verified, check.Equals, true)
+}
+
+func (s *S) TestCNameVerifyInvalidCName(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/leper/cname/_leper.secretcompany.com/verification", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "Invalid cname\n")
+}
+
+func (s *S) TestCNameVerifyUserWithoutAccessToTheApp(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps/leper/cname/leper.secretcompany.com/verification", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestAddCNameHandlerReturnsBadRequestWhenCNameIsMissing(c *check.C) {
 	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
@@ -3527,6 +4010,72 @@ func (s *S) TestAddCNameHandlerUserWithoutAccessToTheApp(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
+func (s *S) TestAddCNameHandlerConflictWithWildcard(c *check.C) {
+	a := app.App{Name: "lost", Platform: "vougan", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddCName("*.secretcompany.com")
+	c.Assert(err, check.IsNil)
+	a2 := app.App{Name: "found", Platform: "vougan", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a2, s.user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/cname", a2.Name)
+	b := strings.NewReader("cname=found.secretcompany.com")
+	request, err := http.NewRequest("POST", url, b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Matches, `cname "found.secretcompany.com" conflicts with a cname of app "lost"\. .*app.admin.cname.*\n`)
+}
+
+func (s *S) TestAddCNameHandlerForce(c *check.C) {
+	a := app.App{Name: "lost", Platform: "vougan", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddCName("*.secretcompany.com")
+	c.Assert(err, check.IsNil)
+	a2 := app.App{Name: "found", Platform: "vougan", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a2, s.user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/cname", a2.Name)
+	b := strings.NewReader("cname=found.secretcompany.com&force=true")
+	request, err := http.NewRequest("POST", url, b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a2.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.CName, check.DeepEquals, []string{"found.secretcompany.com"})
+}
+
+func (s *S) TestAddCNameHandlerForceWithoutPermission(c *check.C) {
+	a := app.App{Name: "lost", Platform: "vougan", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/cname", a.Name)
+	b := strings.NewReader("cname=lost.secretcompany.com&force=true")
+	request, err := http.NewRequest("POST", url, b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateCnameAdd,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestRemoveCNameHandler(c *check.C) {
 	a := app.App{
 		Name:      "leper",
@@ -5389,6 +5938,83 @@ func (s *S) TestMEtricEnvsWhenAppDoesNotExist(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Matches, "^App .* not found.\n$")
 }
 
+func (s *S) TestAppUnitsDiskUsage(c *check.C) {
+	plan := app.Plan{Name: "storage", CpuShare: 100, EphemeralStorage: 8388608}
+	err := plan.Save()
+	c.Assert(err, check.IsNil)
+	defer s.conn.Plans().RemoveId(plan.Name)
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name, Plan: app.Plan{Name: "storage"}}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 1, "web", nil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	s.provisioner.SetUnitDiskUsage(units[0].ID, 1024)
+	request, err := http.NewRequest("GET", "/apps/myappx/units/disk-usage", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result struct {
+		Limit int64
+		Units []provision.UnitDiskUsage
+	}
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Limit, check.Equals, int64(8388608))
+	c.Assert(result.Units, check.DeepEquals, []provision.UnitDiskUsage{{ID: units[0].ID, Usage: 1024}})
+}
+
+func (s *S) TestAppProvisionerEvents(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	now := time.Now().UTC().Truncate(time.Second)
+	s.provisioner.AddUnitEvent(a.Name, provision.UnitEvent{Unit: "myappx-web-1", Type: "Warning", Reason: "FailedScheduling", Message: "no nodes available", Count: 3, FirstSeen: now.Add(-time.Minute), LastSeen: now})
+	s.provisioner.AddUnitEvent(a.Name, provision.UnitEvent{Unit: "myappx-web-0", Type: "Warning", Reason: "Failed", Message: "ErrImagePull", Count: 1, FirstSeen: now.Add(-2 * time.Hour), LastSeen: now.Add(-2 * time.Hour)})
+	request, err := http.NewRequest("GET", "/apps/myappx/provisioner-events", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var events []provision.UnitEvent
+	err = json.Unmarshal(recorder.Body.Bytes(), &events)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].Reason, check.Equals, "FailedScheduling")
+	request, err = http.NewRequest("GET", "/apps/myappx/provisioner-events?since=3h", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	events = nil
+	err = json.Unmarshal(recorder.Body.Bytes(), &events)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 2)
+	c.Assert(events[0].Reason, check.Equals, "FailedScheduling")
+	c.Assert(events[1].Reason, check.Equals, "Failed")
+}
+
+func (s *S) TestAppProvisionerEventsInvalidSince(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myappx/provisioner-events?since=-1h", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
 func (s *S) TestRebuildRoutes(c *check.C) {
 	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
 	err := app.CreateApp(&a, s.user)
@@ -289,7 +289,7 @@ func removeTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 		permission.Context(permission.CtxTeam, name),
 	)
 	if !allowed {
-		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+		return newCodedError(r, http.StatusNotFound, errors.ErrorCodeTeamNotFound, fmt.Sprintf(`Team "%s" not found.`, name))
 	}
 	evt, err := event.New(&event.Opts{
 		Target:     teamTarget(name),
@@ -309,7 +309,7 @@ func removeTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 			return &errors.HTTP{Code: http.StatusForbidden, Message: msg}
 		}
 		if err == auth.ErrTeamNotFound {
-			return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+			return newCodedError(r, http.StatusNotFound, errors.ErrorCodeTeamNotFound, fmt.Sprintf(`Team "%s" not found.`, name))
 		}
 		return err
 	}
@@ -776,3 +776,51 @@ func userInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	w.Header().Add("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(userData)
 }
+
+// title: user settings
+// path: /users/me/settings
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+func userSettings(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	user, err := t.User()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(user.Settings)
+}
+
+// title: update user settings
+// path: /users/me/settings
+// method: PUT
+// consume: application/x-www-form-urlencoded, application/json
+// responses:
+//   200: Settings updated
+//   400: Invalid data
+//   401: Unauthorized
+func updateUserSettings(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	var settings auth.UserSettings
+	err = decodeBody(r, &settings)
+	if err != nil {
+		return err
+	}
+	user, err := t.User()
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(user.Email),
+		Kind:       permission.PermUserUpdateSettings,
+		Owner:      t,
+		CustomData: bodyCustomData(r, settings),
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, user.Email)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return handleAuthError(user.UpdateSettings(settings))
+}
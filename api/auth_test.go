@@ -1649,6 +1649,63 @@ func (s *AuthSuite) TestUserInfoWithoutRoles(c *check.C) {
 	c.Assert(got, check.DeepEquals, expected)
 }
 
+func (s *AuthSuite) TestUserSettings(c *check.C) {
+	settings := auth.UserSettings{TimeZone: "Europe/Lisbon", EventDigest: auth.DigestWeekly}
+	err := s.user.UpdateSettings(settings)
+	c.Assert(err, check.IsNil)
+	defer s.user.UpdateSettings(auth.UserSettings{})
+	request, err := http.NewRequest("GET", "/users/me/settings", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Add("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var got auth.UserSettings
+	err = json.NewDecoder(recorder.Body).Decode(&got)
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.DeepEquals, settings)
+}
+
+func (s *AuthSuite) TestUpdateUserSettings(c *check.C) {
+	defer s.user.UpdateSettings(auth.UserSettings{})
+	body := `{"defaultTeam":"` + s.team.Name + `","timeZone":"Asia/Tokyo","eventDigest":"daily","notificationChannels":[{"type":"email","target":"me@example.com"}]}`
+	request, err := http.NewRequest("PUT", "/users/me/settings", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Add("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Settings, check.DeepEquals, auth.UserSettings{
+		NotificationChannels: []auth.NotificationChannel{{Type: auth.NotificationEmail, Target: "me@example.com"}},
+		DefaultTeam:          s.team.Name,
+		TimeZone:             "Asia/Tokyo",
+		EventDigest:          auth.DigestDaily,
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: userTarget(s.user.Email),
+		Owner:  s.token.GetUserName(),
+		Kind:   "user.update.settings",
+	}, eventtest.HasEvent)
+}
+
+func (s *AuthSuite) TestUpdateUserSettingsInvalid(c *check.C) {
+	request, err := http.NewRequest("PUT", "/users/me/settings", strings.NewReader("timeZone=Nowhere/Land"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Add("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid time zone: \"Nowhere/Land\"\n")
+}
+
 type rolePermList []rolePermissionData
 
 func (l rolePermList) Len() int      { return len(l) }
@@ -0,0 +1,86 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+func autoRollbackFromForm(r *http.Request) (app.AutoRollback, error) {
+	var cfg app.AutoRollback
+	var err error
+	if window := r.FormValue("window"); window != "" {
+		cfg.Window, err = time.ParseDuration(window)
+		if err != nil {
+			return cfg, &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid window: " + err.Error()}
+		}
+	}
+	if maxUnhealthy := r.FormValue("max-unhealthy"); maxUnhealthy != "" {
+		cfg.MaxUnhealthy, err = strconv.Atoi(maxUnhealthy)
+		if err != nil {
+			return cfg, &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid max-unhealthy: " + err.Error()}
+		}
+	}
+	if maxFailures := r.FormValue("max-failures"); maxFailures != "" {
+		cfg.MaxFailures, err = strconv.Atoi(maxFailures)
+		if err != nil {
+			return cfg, &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid max-failures: " + err.Error()}
+		}
+	}
+	cfg.HealthcheckPath = r.FormValue("healthcheck-path")
+	return cfg, nil
+}
+
+// title: app auto rollback set
+// path: /apps/{app}/auto-rollback
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appAutoRollbackSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateAutoRollback,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	cfg, err := autoRollbackFromForm(r)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateAutoRollback,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.SetAutoRollback(cfg)
+	if err == app.ErrInvalidAutoRollback {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
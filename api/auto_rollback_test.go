@@ -0,0 +1,77 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppAutoRollbackSet(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("window=10m&max-unhealthy=25&max-failures=2&healthcheck-path=/status")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/auto-rollback", a.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.AutoRollback, check.DeepEquals, app.AutoRollback{
+		Window:          10 * time.Minute,
+		MaxUnhealthy:    25,
+		MaxFailures:     2,
+		HealthcheckPath: "/status",
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.auto-rollback",
+		StartCustomData: []map[string]interface{}{
+			{"name": "window", "value": "10m"},
+			{"name": "max-unhealthy", "value": "25"},
+			{"name": "max-failures", "value": "2"},
+			{"name": "healthcheck-path", "value": "/status"},
+			{"name": ":app", "value": "leper"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppAutoRollbackSetInvalid(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	var tests = []struct {
+		body    string
+		message string
+	}{
+		{"window=ten", `invalid window: time: invalid duration "ten"`},
+		{"window=1m&max-unhealthy=x", `invalid max-unhealthy: strconv.Atoi: parsing "x": invalid syntax`},
+		{"window=1m&max-unhealthy=150", app.ErrInvalidAutoRollback.Error()},
+	}
+	for _, t := range tests {
+		request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/auto-rollback", a.Name), strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "b "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, t.message+"\n")
+	}
+}
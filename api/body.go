@@ -0,0 +1,53 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+)
+
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// decodeBody decodes the request body into v. Requests with the
+// application/json content type have the body decoded as JSON, any other
+// request is decoded as an urlencoded form, ignoring case and unknown keys.
+// Decoding errors are returned as bad requests.
+func decodeBody(r *http.Request, v interface{}) error {
+	var err error
+	if isJSONRequest(r) {
+		err = json.NewDecoder(r.Body).Decode(v)
+	} else {
+		err = r.ParseForm()
+		if err == nil {
+			dec := form.NewDecoder(nil)
+			dec.IgnoreCase(true)
+			dec.IgnoreUnknownKeys(true)
+			err = dec.DecodeValues(v, r.Form)
+		}
+	}
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return nil
+}
+
+// bodyCustomData returns the data stored in events for a request decoded with
+// decodeBody: the decoded value for JSON requests and the form values
+// otherwise.
+func bodyCustomData(r *http.Request, v interface{}) interface{} {
+	if isJSONRequest(r) {
+		return v
+	}
+	return event.FormToCustomData(r.Form)
+}
@@ -0,0 +1,314 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	pkgErrors "github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/cluster"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	bootstrapTokenHeader = "X-Tsuru-Bootstrap-Token"
+	bootstrapRootRole    = "AllowAll"
+	bootstrapLockID      = "bootstrap"
+	bootstrapLockTimeout = 5 * time.Minute
+)
+
+// bootstrapLock is the marker document serializing bootstraps. It's kept,
+// with Done set, after a successful bootstrap.
+type bootstrapLock struct {
+	ID          string `bson:"_id"`
+	Done        bool
+	LockedUntil time.Time
+}
+
+// bootstrapDocument describes the initial state of a fresh installation.
+type bootstrapDocument struct {
+	Admin struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	} `json:"admin"`
+	Team string `json:"team"`
+	Pool struct {
+		Name        string `json:"name"`
+		Provisioner string `json:"provisioner"`
+	} `json:"pool"`
+	Plan    *app.Plan         `json:"plan"`
+	Cluster *bootstrapCluster `json:"cluster"`
+}
+
+type bootstrapCluster struct {
+	Name        string            `json:"name"`
+	Provisioner string            `json:"provisioner"`
+	Addresses   []string          `json:"addresses"`
+	CaCert      string            `json:"cacert"`
+	ClientCert  string            `json:"clientcert"`
+	ClientKey   string            `json:"clientkey"`
+	CustomData  map[string]string `json:"custom_data"`
+}
+
+func (d *bootstrapDocument) validate() error {
+	if d.Admin.Email == "" {
+		return &errors.ValidationError{Message: "admin email is required"}
+	}
+	if d.Team == "" {
+		return &errors.ValidationError{Message: "team is required"}
+	}
+	if d.Pool.Name == "" {
+		return &errors.ValidationError{Message: "pool name is required"}
+	}
+	if d.Cluster != nil && d.Cluster.Name == "" {
+		return &errors.ValidationError{Message: "cluster name is required"}
+	}
+	return nil
+}
+
+func isFreshInstallation() (bool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	n, err := conn.Users().Count()
+	if err != nil {
+		return false, err
+	}
+	return n == 0, nil
+}
+
+// acquireBootstrapLock inserts the bootstrap marker document, whose unique id
+// prevents concurrent bootstraps. It returns false when another bootstrap is
+// running or the installation was already bootstrapped. The lock of a
+// bootstrap that stopped without releasing it expires after
+// bootstrapLockTimeout.
+func acquireBootstrapLock() (bool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	coll := conn.Collection("bootstrap")
+	now := time.Now().UTC()
+	err = coll.Insert(bootstrapLock{ID: bootstrapLockID, LockedUntil: now.Add(bootstrapLockTimeout)})
+	if err == nil {
+		return true, nil
+	}
+	if !mgo.IsDup(err) {
+		return false, err
+	}
+	err = coll.Update(
+		bson.M{"_id": bootstrapLockID, "done": false, "lockeduntil": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"lockeduntil": now.Add(bootstrapLockTimeout)}},
+	)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// releaseBootstrapLock marks the installation as bootstrapped when done is
+// true, or removes the lock so that a failed bootstrap may be retried.
+func releaseBootstrapLock(done bool) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.Collection("bootstrap")
+	if done {
+		return coll.UpdateId(bootstrapLockID, bson.M{"$set": bson.M{"done": true}})
+	}
+	return coll.RemoveId(bootstrapLockID)
+}
+
+// title: bootstrap installation
+// path: /bootstrap
+// method: POST
+// consume: application/json
+// responses:
+//   201: Installation bootstrapped
+//   400: Invalid data
+//   403: Bootstrap disabled or invalid token
+//   409: Installation already bootstrapped
+func bootstrapHandler(w http.ResponseWriter, r *http.Request) (err error) {
+	token, _ := config.GetString("bootstrap:token")
+	if token == "" {
+		return &errors.HTTP{Code: http.StatusForbidden, Message: "bootstrap is disabled, set bootstrap:token to enable it"}
+	}
+	sent := r.Header.Get(bootstrapTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+		return &errors.HTTP{Code: http.StatusForbidden, Message: "invalid bootstrap token"}
+	}
+	var doc bootstrapDocument
+	err = json.NewDecoder(r.Body).Decode(&doc)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	err = doc.validate()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	locked, err := acquireBootstrapLock()
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return &errors.HTTP{Code: http.StatusConflict, Message: "installation already bootstrapped"}
+	}
+	defer func() {
+		if releaseErr := releaseBootstrapLock(err == nil); releaseErr != nil {
+			log.Errorf("[bootstrap] unable to release bootstrap lock: %s", releaseErr)
+		}
+	}()
+	fresh, err := isFreshInstallation()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return &errors.HTTP{Code: http.StatusConflict, Message: "installation already bootstrapped"}
+	}
+	customData := map[string]interface{}{
+		"team": doc.Team,
+		"pool": doc.Pool.Name,
+	}
+	if doc.Plan != nil {
+		customData["plan"] = doc.Plan.Name
+	}
+	if doc.Cluster != nil {
+		customData["cluster"] = doc.Cluster.Name
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(doc.Admin.Email),
+		Kind:       permission.PermUserCreate,
+		RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: doc.Admin.Email},
+		CustomData: customData,
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, doc.Admin.Email)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = bootstrap(&doc)
+	if err != nil {
+		if _, ok := pkgErrors.Cause(err).(*errors.ValidationError); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return handleAuthError(err)
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// bootstrap creates everything in the document. The pool is created as the
+// default pool, open to all teams. Pool, plan and cluster are created first
+// and tolerate existing entries, so that a failed bootstrap may be retried.
+// The admin user is removed when its role or the team can't be created, as
+// the installation is only considered bootstrapped once it has users.
+func bootstrap(doc *bootstrapDocument) (err error) {
+	_, err = provision.GetPoolByName(doc.Pool.Name)
+	if err == provision.ErrPoolNotFound {
+		err = provision.AddPool(provision.AddPoolOptions{
+			Name:        doc.Pool.Name,
+			Provisioner: doc.Pool.Provisioner,
+			Default:     true,
+			Force:       true,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if doc.Plan != nil {
+		doc.Plan.Default = true
+		err = doc.Plan.Save()
+		switch err {
+		case nil, app.ErrPlanAlreadyExists:
+		case app.ErrLimitOfCpuShare, app.ErrLimitOfMemory, app.ErrLimitOfStorage:
+			return &errors.ValidationError{Message: err.Error()}
+		default:
+			if _, ok := err.(app.PlanValidationError); ok {
+				return &errors.ValidationError{Message: err.Error()}
+			}
+			return err
+		}
+	}
+	if doc.Cluster != nil {
+		provisioner := doc.Cluster.Provisioner
+		if provisioner == "" {
+			provisioner = doc.Pool.Provisioner
+		}
+		c := cluster.Cluster{
+			Name:        doc.Cluster.Name,
+			Provisioner: provisioner,
+			Addresses:   doc.Cluster.Addresses,
+			CaCert:      []byte(doc.Cluster.CaCert),
+			ClientCert:  []byte(doc.Cluster.ClientCert),
+			ClientKey:   []byte(doc.Cluster.ClientKey),
+			CustomData:  doc.Cluster.CustomData,
+			Default:     true,
+		}
+		err = c.Save()
+		if err != nil {
+			return err
+		}
+	}
+	user, err := app.AuthScheme.Create(&auth.User{
+		Email:    doc.Admin.Email,
+		Password: doc.Admin.Password,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if removeErr := app.AuthScheme.Remove(user); removeErr != nil {
+			log.Errorf("[bootstrap] unable to remove admin user %q: %s", user.Email, removeErr)
+		}
+	}()
+	err = addBootstrapRootRole(user)
+	if err != nil {
+		return err
+	}
+	err = auth.CreateTeam(doc.Team, user)
+	if err == auth.ErrInvalidTeamName {
+		return &errors.ValidationError{Message: err.Error()}
+	}
+	if err != nil && err != auth.ErrTeamAlreadyExists {
+		return err
+	}
+	return nil
+}
+
+func addBootstrapRootRole(u *auth.User) error {
+	r, err := permission.FindRole(bootstrapRootRole)
+	if err != nil {
+		r, err = permission.NewRole(bootstrapRootRole, string(permission.CtxGlobal), "")
+		if err != nil {
+			return err
+		}
+	}
+	err = r.AddPermissions("*")
+	if err != nil {
+		return err
+	}
+	return u.AddRole(bootstrapRootRole, "")
+}
@@ -0,0 +1,156 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/cluster"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const bootstrapDoc = `{
+	"admin": {"email": "root@example.com", "password": "123456"},
+	"team": "admin",
+	"pool": {"name": "main"},
+	"plan": {"name": "small", "memory": 134217728, "cpushare": 100},
+	"cluster": {"name": "c1", "provisioner": "fake", "addresses": ["https://k8s.example.com"]}
+}`
+
+func (s *S) doBootstrap(c *check.C, token, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", "/1.4/bootstrap", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set(bootstrapTokenHeader, token)
+	}
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *S) TestBootstrap(c *check.C) {
+	config.Set("bootstrap:token", "secret")
+	defer config.Unset("bootstrap:token")
+	err := dbtest.ClearAllCollections(s.conn.Apps().Database)
+	c.Assert(err, check.IsNil)
+	rec := s.doBootstrap(c, "secret", bootstrapDoc)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated, check.Commentf("%s", rec.Body.String()))
+	user, err := auth.GetUserByEmail("root@example.com")
+	c.Assert(err, check.IsNil)
+	perms, err := user.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(permission.CheckFromPermList(perms, permission.PermAll), check.Equals, true)
+	team, err := auth.GetTeam("admin")
+	c.Assert(err, check.IsNil)
+	c.Assert(team.CreatingUser, check.Equals, "root@example.com")
+	pool, err := provision.GetDefaultPool()
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Name, check.Equals, "main")
+	plan, err := app.DefaultPlan()
+	c.Assert(err, check.IsNil)
+	c.Assert(plan.Name, check.Equals, "small")
+	clusters, err := cluster.AllClusters()
+	c.Assert(err, check.IsNil)
+	c.Assert(clusters, check.HasLen, 1)
+	c.Assert(clusters[0].Name, check.Equals, "c1")
+	c.Assert(clusters[0].Default, check.Equals, true)
+	rec = s.doBootstrap(c, "secret", bootstrapDoc)
+	c.Assert(rec.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestBootstrapAlreadyBootstrapped(c *check.C) {
+	config.Set("bootstrap:token", "secret")
+	defer config.Unset("bootstrap:token")
+	rec := s.doBootstrap(c, "secret", bootstrapDoc)
+	c.Assert(rec.Code, check.Equals, http.StatusConflict)
+	_, err := auth.GetUserByEmail("root@example.com")
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+}
+
+func (s *S) TestBootstrapDisabled(c *check.C) {
+	err := dbtest.ClearAllCollections(s.conn.Apps().Database)
+	c.Assert(err, check.IsNil)
+	rec := s.doBootstrap(c, "secret", bootstrapDoc)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestBootstrapInvalidToken(c *check.C) {
+	config.Set("bootstrap:token", "secret")
+	defer config.Unset("bootstrap:token")
+	err := dbtest.ClearAllCollections(s.conn.Apps().Database)
+	c.Assert(err, check.IsNil)
+	rec := s.doBootstrap(c, "wrong", bootstrapDoc)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+	rec = s.doBootstrap(c, "", bootstrapDoc)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestBootstrapInvalidDocument(c *check.C) {
+	config.Set("bootstrap:token", "secret")
+	defer config.Unset("bootstrap:token")
+	err := dbtest.ClearAllCollections(s.conn.Apps().Database)
+	c.Assert(err, check.IsNil)
+	tests := []string{
+		`{"admin": `,
+		`{"team": "admin", "pool": {"name": "main"}}`,
+		`{"admin": {"email": "root@example.com", "password": "123456"}, "pool": {"name": "main"}}`,
+		`{"admin": {"email": "root@example.com", "password": "123456"}, "team": "admin"}`,
+		`{"admin": {"email": "root@example.com", "password": "123456"}, "team": "admin", "pool": {"name": "main"}, "plan": {"name": "small", "cpushare": 1}}`,
+	}
+	for _, body := range tests {
+		rec := s.doBootstrap(c, "secret", body)
+		c.Check(rec.Code, check.Equals, http.StatusBadRequest, check.Commentf("%s", body))
+	}
+	_, err = auth.GetUserByEmail("root@example.com")
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+}
+
+func (s *S) TestBootstrapRunning(c *check.C) {
+	config.Set("bootstrap:token", "secret")
+	defer config.Unset("bootstrap:token")
+	err := dbtest.ClearAllCollections(s.conn.Apps().Database)
+	c.Assert(err, check.IsNil)
+	locked, err := acquireBootstrapLock()
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	rec := s.doBootstrap(c, "secret", bootstrapDoc)
+	c.Assert(rec.Code, check.Equals, http.StatusConflict)
+	_, err = auth.GetUserByEmail("root@example.com")
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+	err = s.conn.Collection("bootstrap").UpdateId(bootstrapLockID, bson.M{"$set": bson.M{"lockeduntil": time.Now().Add(-time.Minute)}})
+	c.Assert(err, check.IsNil)
+	rec = s.doBootstrap(c, "secret", bootstrapDoc)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated, check.Commentf("%s", rec.Body.String()))
+	var lock bootstrapLock
+	err = s.conn.Collection("bootstrap").FindId(bootstrapLockID).One(&lock)
+	c.Assert(err, check.IsNil)
+	c.Assert(lock.Done, check.Equals, true)
+}
+
+func (s *S) TestBootstrapRemovesUserOnFailure(c *check.C) {
+	config.Set("bootstrap:token", "secret")
+	defer config.Unset("bootstrap:token")
+	err := dbtest.ClearAllCollections(s.conn.Apps().Database)
+	c.Assert(err, check.IsNil)
+	body := `{"admin": {"email": "root@example.com", "password": "123456"}, "team": "_invalid", "pool": {"name": "main"}}`
+	rec := s.doBootstrap(c, "secret", body)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest, check.Commentf("%s", rec.Body.String()))
+	_, err = auth.GetUserByEmail("root@example.com")
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+	rec = s.doBootstrap(c, "secret", bootstrapDoc)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated, check.Commentf("%s", rec.Body.String()))
+}
@@ -0,0 +1,175 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/chaos"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+func chaosError(err error) error {
+	switch err {
+	case chaos.ErrInvalidKind, chaos.ErrInvalidDuration, chaos.ErrInvalidLatency, chaos.ErrNoUnits,
+		chaos.ErrKillUnitNotSupported, chaos.ErrLatencyNotSupported, chaos.ErrNodeFailureNotSupported:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case chaos.ErrDisabled:
+		return &errors.HTTP{Code: http.StatusForbidden, Message: err.Error()}
+	case chaos.ErrExperimentRunning:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case chaos.ErrExperimentNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: app chaos experiment list
+// path: /apps/{app}/chaos
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func appChaosList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	experiments, err := chaos.List(a.Name)
+	if err != nil {
+		return err
+	}
+	if len(experiments) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(experiments)
+}
+
+// title: app chaos experiment run
+// path: /apps/{app}/chaos
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Experiment started
+//   400: Invalid data
+//   401: Unauthorized
+//   403: Chaos experiments disabled
+//   404: App not found
+//   409: Experiment already running
+func appChaosRun(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	opts := chaos.Opts{
+		Kind:    r.FormValue("kind"),
+		Process: r.FormValue("process"),
+		Owner:   t.GetUserName(),
+	}
+	if v := r.FormValue("duration"); v != "" {
+		opts.Duration, err = time.ParseDuration(v)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid duration: " + err.Error()}
+		}
+	}
+	if v := r.FormValue("latency"); v != "" {
+		opts.Latency, err = time.ParseDuration(v)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid latency: " + err.Error()}
+		}
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateChaosRun,
+		contextsForApp(&a)...,
+	)
+	// Failing a node affects every unit running on it, not only the units
+	// of the app.
+	if opts.Kind == chaos.KindNodeFailure {
+		allowed = allowed && permission.Check(t, permission.PermNodeUpdateEvacuate,
+			permission.Context(permission.CtxPool, a.Pool),
+		)
+	}
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateChaosRun,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	var exp *chaos.Experiment
+	defer func() { evt.DoneCustomData(err, exp) }()
+	opts.Writer = evt
+	exp, err = chaos.Run(&a, opts)
+	if err != nil {
+		return chaosError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(exp)
+}
+
+// title: app chaos experiment stop
+// path: /apps/{app}/chaos/{id}
+// method: DELETE
+// produce: application/json
+// responses:
+//   200: Experiment stopped
+//   401: Unauthorized
+//   404: App or experiment not found
+func appChaosStop(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateChaosStop,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateChaosStop,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	exp, err := chaos.Stop(a.Name, r.URL.Query().Get(":id"))
+	if err != nil {
+		return chaosError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(exp)
+}
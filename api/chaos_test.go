@@ -0,0 +1,130 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/chaos"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppChaosRun(c *check.C) {
+	config.Set("chaos:enabled", true)
+	defer config.Unset("chaos")
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("kind=router-latency&latency=500ms&duration=10m")
+	request, err := http.NewRequest("POST", "/apps/leper/chaos", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated, check.Commentf("%s", recorder.Body.String()))
+	var exp chaos.Experiment
+	err = json.NewDecoder(recorder.Body).Decode(&exp)
+	c.Assert(err, check.IsNil)
+	c.Assert(exp.Kind, check.Equals, chaos.KindRouterLatency)
+	c.Assert(exp.Owner, check.Equals, s.token.GetUserName())
+	c.Assert(exp.End.Sub(exp.Start), check.Equals, 10*time.Minute)
+	latency, ok := routertest.FakeRouter.GetLatency(a.Name)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(latency, check.Equals, 500*time.Millisecond)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.chaos.run",
+		StartCustomData: []map[string]interface{}{
+			{"name": "kind", "value": "router-latency"},
+			{"name": "latency", "value": "500ms"},
+			{"name": "duration", "value": "10m"},
+			{"name": ":app", "value": "leper"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppChaosRunDisabled(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("kind=kill-unit")
+	request, err := http.NewRequest("POST", "/apps/leper/chaos", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, chaos.ErrDisabled.Error()+"\n")
+}
+
+func (s *S) TestAppChaosRunNodeFailureRequiresNodePermission(c *check.C) {
+	config.Set("chaos:enabled", true)
+	defer config.Unset("chaos")
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateChaosRun,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader("kind=node-failure&duration=10m")
+	request, err := http.NewRequest("POST", "/apps/leper/chaos", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppChaosListAndStop(c *check.C) {
+	config.Set("chaos:enabled", true)
+	defer config.Unset("chaos")
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	exp, err := chaos.Run(&a, chaos.Opts{Kind: chaos.KindRouterLatency, Latency: time.Second, Duration: time.Minute})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/leper/chaos", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var experiments []chaos.Experiment
+	err = json.NewDecoder(recorder.Body).Decode(&experiments)
+	c.Assert(err, check.IsNil)
+	c.Assert(experiments, check.HasLen, 1)
+	c.Assert(experiments[0].ID, check.Equals, exp.ID)
+	request, err = http.NewRequest("DELETE", "/apps/leper/chaos/"+exp.ID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, ok := routertest.FakeRouter.GetLatency(a.Name)
+	c.Assert(ok, check.Equals, false)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.chaos.stop",
+	}, eventtest.HasEvent)
+}
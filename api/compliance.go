@@ -0,0 +1,154 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/compliance"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+func complianceError(err error) error {
+	if err == compliance.ErrReportNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: compliance report list
+// path: /compliance/reports
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+func listComplianceReports(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermComplianceReportRead) {
+		return permission.ErrUnauthorized
+	}
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for limit: " + v}
+		}
+	}
+	reports, err := compliance.List(limit)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(reports)
+}
+
+// title: compliance report info
+// path: /compliance/reports/{id}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Report not found
+func complianceReportInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermComplianceReportRead) {
+		return permission.ErrUnauthorized
+	}
+	report, err := compliance.Get(r.URL.Query().Get(":id"))
+	if err != nil {
+		return complianceError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(report)
+}
+
+// title: compliance report create
+// path: /compliance/reports
+// method: POST
+// produce: application/json
+// responses:
+//   201: Report created
+//   401: Unauthorized
+func createComplianceReport(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermComplianceReportCreate) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeComplianceReport},
+		Kind:    permission.PermComplianceReportCreate,
+		Owner:   t,
+		Allowed: event.Allowed(permission.PermComplianceReportReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	report, err := compliance.Generate()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(report)
+}
+
+// title: compliance report compare
+// path: /compliance/reports/compare
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Report not found
+func compareComplianceReports(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermComplianceReportRead) {
+		return permission.ErrUnauthorized
+	}
+	fromID := r.URL.Query().Get("from")
+	toID := r.URL.Query().Get("to")
+	var from, to *compliance.Report
+	var err error
+	if fromID == "" && toID == "" {
+		reports, err := compliance.List(2)
+		if err != nil {
+			return err
+		}
+		if len(reports) < 2 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "at least two reports are required for comparison"}
+		}
+		from, to = &reports[1], &reports[0]
+	} else {
+		if fromID == "" {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "from is required"}
+		}
+		from, err = compliance.Get(fromID)
+		if err != nil {
+			return complianceError(err)
+		}
+		if toID == "" {
+			to, err = compliance.Latest()
+		} else {
+			to, err = compliance.Get(toID)
+		}
+		if err != nil {
+			return complianceError(err)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(compliance.Compare(from, to))
+}
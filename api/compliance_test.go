@@ -0,0 +1,114 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/compliance"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCreateComplianceReport(c *check.C) {
+	request, err := http.NewRequest("POST", "/compliance/reports", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated, check.Commentf("%s", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var report compliance.Report
+	err = json.NewDecoder(recorder.Body).Decode(&report)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.AdminUsers, check.DeepEquals, []string{s.user.Email})
+	dbReport, err := compliance.Latest()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbReport.ID, check.Equals, report.ID)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeComplianceReport},
+		Owner:  s.token.GetUserName(),
+		Kind:   "compliance-report.create",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestListComplianceReports(c *check.C) {
+	report, err := compliance.Generate()
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/compliance/reports", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var list []compliance.Report
+	err = json.NewDecoder(recorder.Body).Decode(&list)
+	c.Assert(err, check.IsNil)
+	c.Assert(list, check.HasLen, 1)
+	c.Assert(list[0].ID, check.Equals, report.ID)
+}
+
+func (s *S) TestListComplianceReportsEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/compliance/reports", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestComplianceReportInfoNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/compliance/reports/000000000000000000000000", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, compliance.ErrReportNotFound.Error()+"\n")
+}
+
+func (s *S) TestCompareComplianceReports(c *check.C) {
+	from, err := compliance.Generate()
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "unconstrained"})
+	c.Assert(err, check.IsNil)
+	to, err := compliance.Generate()
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/compliance/reports/compare?from="+from.ID.Hex()+"&to="+to.ID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	var cmp compliance.Comparison
+	err = json.NewDecoder(recorder.Body).Decode(&cmp)
+	c.Assert(err, check.IsNil)
+	c.Assert(cmp.From.ID, check.Equals, from.ID)
+	c.Assert(cmp.To.ID, check.Equals, to.ID)
+	c.Assert(cmp.AdminUsers, check.DeepEquals, compliance.FindingsDiff{})
+	c.Assert(cmp.PoolsWithoutConstraints, check.DeepEquals, compliance.FindingsDiff{Added: []string{"unconstrained"}})
+}
+
+func (s *S) TestCompareComplianceReportsNotEnoughReports(c *check.C) {
+	_, err := compliance.Generate()
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/compliance/reports/compare", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "at least two reports are required for comparison\n")
+}
@@ -0,0 +1,164 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestConfigTemplateSet(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("Name=nginx.conf&Path=/etc/nginx.conf&Content=listen+{{.Env.PORT}}%3B")
+	request, err := http.NewRequest("PUT", "/apps/leper/config-templates", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ConfigTemplates, check.DeepEquals, []provision.ConfigTemplate{
+		{Name: "nginx.conf", Path: "/etc/nginx.conf", Content: "listen {{.Env.PORT}};"},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.config-templates",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Name", "value": "nginx.conf"},
+			{"name": "Path", "value": "/etc/nginx.conf"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestConfigTemplateSetInvalid(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("Name=nginx.conf&Path=nginx.conf")
+	request, err := http.NewRequest("PUT", "/apps/leper/config-templates", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, provision.ErrInvalidConfigTemplate.Error()+"\n")
+}
+
+func (s *S) TestConfigTemplateSetForbidden(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateConfigTemplates,
+		Context: permission.Context(permission.CtxApp, "other"),
+	})
+	body := strings.NewReader("Name=nginx.conf&Path=/etc/nginx.conf")
+	request, err := http.NewRequest("PUT", "/apps/leper/config-templates", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestConfigTemplateList(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tpl := provision.ConfigTemplate{Name: "nginx.conf", Path: "/etc/nginx.conf", Content: "listen 80;"}
+	err = a.SetConfigTemplate(tpl)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/leper/config-templates", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var templates []provision.ConfigTemplate
+	err = json.Unmarshal(recorder.Body.Bytes(), &templates)
+	c.Assert(err, check.IsNil)
+	c.Assert(templates, check.DeepEquals, []provision.ConfigTemplate{tpl})
+}
+
+func (s *S) TestConfigTemplateRemove(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetConfigTemplate(provision.ConfigTemplate{Name: "nginx.conf", Path: "/etc/nginx.conf"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/leper/config-templates/nginx.conf", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ConfigTemplates, check.HasLen, 0)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestConfigTemplatePreview(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("Name=app.ini&Path=/etc/app.ini&Content=app={{.App}}+process={{.Process}}&process=worker")
+	request, err := http.NewRequest("POST", "/apps/leper/config-templates/preview", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var rendered []provision.RenderedConfigTemplate
+	err = json.Unmarshal(recorder.Body.Bytes(), &rendered)
+	c.Assert(err, check.IsNil)
+	c.Assert(rendered, check.DeepEquals, []provision.RenderedConfigTemplate{
+		{Name: "app.ini", Path: "/etc/app.ini", Content: "app=leper process=worker"},
+	})
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ConfigTemplates, check.HasLen, 0)
+}
+
+func (s *S) TestConfigTemplatePreviewRenderError(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("Name=app.ini&Path=/etc/app.ini&Content={{.Env.MISSING}}")
+	request, err := http.NewRequest("POST", "/apps/leper/config-templates/preview", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s)unable to render config template "app.ini".*`)
+}
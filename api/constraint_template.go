@@ -0,0 +1,116 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+)
+
+// title: constraint template create
+// path: /constraint-templates
+// method: POST
+// consume: application/x-www-form-urlencoded, application/json
+// responses:
+//   201: Constraint template created
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Constraint template already exists
+func addConstraintTemplate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	allowed := permission.Check(t, permission.PermConstraintTemplateCreate)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	var tpl provision.ConstraintTemplate
+	err = decodeBody(r, &tpl)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeConstraintTemplate, Value: tpl.Name},
+		Kind:       permission.PermConstraintTemplateCreate,
+		Owner:      t,
+		CustomData: bodyCustomData(r, tpl),
+		Allowed:    event.Allowed(permission.PermConstraintTemplateReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.AddConstraintTemplate(tpl)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusCreated)
+	case provision.ErrConstraintTemplateNameRequired, provision.ErrInvalidConstraintTemplate,
+		provision.ErrInvalidConstraintType:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case provision.ErrConstraintTemplateAlreadyExists:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+// title: constraint template list
+// path: /constraint-templates
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func listConstraintTemplates(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	allowed := permission.Check(t, permission.PermPoolReadConstraints)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	templates, err := provision.ListConstraintTemplates()
+	if err != nil {
+		return err
+	}
+	if len(templates) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(templates)
+}
+
+// title: constraint template remove
+// path: /constraint-templates/{name}
+// method: DELETE
+// responses:
+//   200: Constraint template removed
+//   401: Unauthorized
+//   404: Constraint template not found
+func removeConstraintTemplate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	allowed := permission.Check(t, permission.PermConstraintTemplateDelete)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	name := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeConstraintTemplate, Value: name},
+		Kind:       permission.PermConstraintTemplateDelete,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermConstraintTemplateReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.RemoveConstraintTemplate(name)
+	if err == provision.ErrConstraintTemplateNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestConstraintTemplateAdd(c *check.C) {
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("name=web&constraints.0.field=router&constraints.0.values.0=planb&constraints.1.field=team&constraints.1.values.0=ops&constraints.1.blacklist=true")
+	request, err := http.NewRequest("POST", "/constraint-templates", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated, check.Commentf("%s", recorder.Body.String()))
+	tpl, err := provision.GetConstraintTemplate("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(*tpl, check.DeepEquals, provision.ConstraintTemplate{Name: "web", Constraints: []provision.TemplateConstraint{
+		{Field: "router", Values: []string{"planb"}},
+		{Field: "team", Values: []string{"ops"}, Blacklist: true},
+	}})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeConstraintTemplate, Value: "web"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "constraint-template.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "web"},
+			{"name": "constraints.0.field", "value": "router"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestConstraintTemplateAddJSON(c *check.C) {
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader(`{"name": "web", "constraints": [{"field": "router", "values": ["planb"]}]}`)
+	request, err := http.NewRequest("POST", "/constraint-templates", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/json")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	tpl, err := provision.GetConstraintTemplate("web")
+	c.Assert(err, check.IsNil)
+	c.Assert(tpl.Constraints, check.DeepEquals, []provision.TemplateConstraint{{Field: "router", Values: []string{"planb"}}})
+}
+
+func (s *S) TestConstraintTemplateAddInvalid(c *check.C) {
+	for _, data := range []string{"name=web", "constraints.0.field=router&constraints.0.values.0=planb", "name=web&constraints.0.field=invalid&constraints.0.values.0=x"} {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest("POST", "/constraint-templates", strings.NewReader(data))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		m := RunServer(true)
+		m.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest, check.Commentf("%s", data))
+	}
+}
+
+func (s *S) TestConstraintTemplateAddAlreadyExists(c *check.C) {
+	err := provision.AddConstraintTemplate(provision.ConstraintTemplate{Name: "web", Constraints: []provision.TemplateConstraint{{Field: "router", Values: []string{"planb"}}}})
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("name=web&constraints.0.field=router&constraints.0.values.0=galeb")
+	request, err := http.NewRequest("POST", "/constraint-templates", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestConstraintTemplateList(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/constraint-templates", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	tpl := provision.ConstraintTemplate{Name: "web", Constraints: []provision.TemplateConstraint{{Field: "router", Values: []string{"planb"}}}}
+	err = provision.AddConstraintTemplate(tpl)
+	c.Assert(err, check.IsNil)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var templates []provision.ConstraintTemplate
+	err = json.NewDecoder(recorder.Body).Decode(&templates)
+	c.Assert(err, check.IsNil)
+	c.Assert(templates, check.DeepEquals, []provision.ConstraintTemplate{tpl})
+}
+
+func (s *S) TestConstraintTemplateRemove(c *check.C) {
+	err := provision.AddConstraintTemplate(provision.ConstraintTemplate{Name: "web", Constraints: []provision.TemplateConstraint{{Field: "router", Values: []string{"planb"}}}})
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("DELETE", "/constraint-templates/web", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = provision.GetConstraintTemplate("web")
+	c.Assert(err, check.Equals, provision.ErrConstraintTemplateNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeConstraintTemplate, Value: "web"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "constraint-template.delete",
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAddPoolWithConstraintTemplates(c *check.C) {
+	err := provision.AddConstraintTemplate(provision.ConstraintTemplate{Name: "web", Constraints: []provision.TemplateConstraint{{Field: "router", Values: []string{"planb"}}}})
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/pools", strings.NewReader("name=pool1&constrainttemplates.0=web"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	constraints, err := provision.ListPoolsConstraints(nil)
+	c.Assert(err, check.IsNil)
+	var found bool
+	for _, cs := range constraints {
+		if cs.PoolExpr == "pool1" && cs.Field == "router" {
+			found = true
+			c.Assert(cs.Values, check.DeepEquals, []string{"planb"})
+		}
+	}
+	c.Assert(found, check.Equals, true)
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest("POST", "/pools", strings.NewReader("name=pool2&constrainttemplates.0=other"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
@@ -19,6 +19,8 @@ const (
 	delayedHandlerKey
 	preventUnlockKey
 	appContextKey
+	forwardedPrefixKey
+	errorCodesKey
 )
 
 func Clear(r *http.Request) {
@@ -65,6 +67,24 @@ func GetRequestError(r *http.Request) error {
 	return nil
 }
 
+// SetErrorCode records the machine-readable code of err, an error returned
+// for the request, when it's more specific than the code derived from the
+// status of the error.
+func SetErrorCode(r *http.Request, err error, code string) {
+	codes, _ := context.Get(r, errorCodesKey).(map[error]string)
+	if codes == nil {
+		codes = make(map[error]string)
+		context.Set(r, errorCodesKey, codes)
+	}
+	codes[err] = code
+}
+
+// GetErrorCode returns the code recorded for err with SetErrorCode.
+func GetErrorCode(r *http.Request, err error) string {
+	codes, _ := context.Get(r, errorCodesKey).(map[error]string)
+	return codes[err]
+}
+
 func SetDelayedHandler(r *http.Request, h http.Handler) {
 	context.Set(r, delayedHandlerKey, h)
 }
@@ -99,3 +119,17 @@ func GetRequestID(r *http.Request, requestIDHeader string) string {
 	}
 	return requestID.(string)
 }
+
+// SetForwardedPrefix sets the path prefix added to the API URLs by the
+// gateway in front of it.
+func SetForwardedPrefix(r *http.Request, prefix string) {
+	context.Set(r, forwardedPrefixKey, prefix)
+}
+
+// GetForwardedPrefix returns the prefix set by SetForwardedPrefix.
+func GetForwardedPrefix(r *http.Request) string {
+	if v := context.Get(r, forwardedPrefixKey); v != nil {
+		return v.(string)
+	}
+	return ""
+}
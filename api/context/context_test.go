@@ -92,6 +92,17 @@ func (s *S) TestAddRequestError(c *check.C) {
 	c.Assert(otherErr.Error(), check.Equals, "msg2 Caused by: msg1")
 }
 
+func (s *S) TestSetErrorCode(c *check.C) {
+	r, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	err1 := errors.New("msg1")
+	err2 := errors.New("msg2")
+	c.Assert(GetErrorCode(r, err1), check.Equals, "")
+	SetErrorCode(r, err1, "pool_not_found")
+	c.Assert(GetErrorCode(r, err1), check.Equals, "pool_not_found")
+	c.Assert(GetErrorCode(r, err2), check.Equals, "")
+}
+
 func (s *S) TestSetDelayedHandler(c *check.C) {
 	r, err := http.NewRequest("GET", "/", nil)
 	c.Assert(err, check.IsNil)
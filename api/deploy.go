@@ -8,8 +8,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -34,21 +37,19 @@ import (
 //   403: Forbidden
 //   404: Not found
 func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
-	var file multipart.File
+	var file io.ReadCloser
 	var fileSize int64
 	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
-		file, _, err = r.FormFile("file")
+		file, fileSize, err = readDeployUpload(r)
 		if err != nil {
-			return &tsuruErrors.HTTP{
-				Code:    http.StatusBadRequest,
-				Message: err.Error(),
+			if isBodyTooLarge(r) {
+				return newBodyTooLargeError("deploy", bodyLimit("deploy"))
 			}
+			if _, ok := err.(*tsuruErrors.HTTP); ok {
+				return err
+			}
+			return errors.Wrap(err, "unable to read uploaded file")
 		}
-		fileSize, err = file.Seek(0, io.SeekEnd)
-		if err != nil {
-			return errors.Wrap(err, "unable to find uploaded file size")
-		}
-		file.Seek(0, io.SeekStart)
 		defer file.Close()
 	}
 	archiveURL := r.FormValue("archive-url")
@@ -60,7 +61,7 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		}
 	}
 	commit := r.FormValue("commit")
-	w.Header().Set("Content-Type", "text")
+	gitRepository := r.FormValue("repository")
 	appName := r.URL.Query().Get(":appname")
 	origin := r.FormValue("origin")
 	if image != "" {
@@ -81,7 +82,9 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		}
 		userName = r.FormValue("user")
 	} else {
-		commit = ""
+		if gitRepository == "" {
+			commit = ""
+		}
 		userName = t.GetUserName()
 	}
 	instance, err := app.GetByName(appName)
@@ -100,7 +103,7 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		}
 	}
 	message := r.FormValue("message")
-	if commit != "" && message == "" {
+	if commit != "" && message == "" && gitRepository == "" {
 		var messages []string
 		messages, err = repository.Manager().CommitMessages(instance.Name, commit, 1)
 		if err != nil {
@@ -112,6 +115,9 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	}
 	if origin == "" && commit != "" {
 		origin = "git"
+		if gitRepository != "" {
+			origin = "app-deploy"
+		}
 	}
 	opts := app.DeployOptions{
 		App:        instance,
@@ -124,6 +130,7 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		Origin:     origin,
 		Build:      build,
 		Message:    message,
+		Repository: gitRepository,
 	}
 	opts.GetKind()
 	if t.GetAppName() != app.InternalAppName {
@@ -132,6 +139,16 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 			return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: "User does not have permission to do this action in this app"}
 		}
 	}
+	err = checkPolicy(t, permission.PermAppDeploy, appTarget(appName), map[string]interface{}{
+		"kind":   opts.Kind,
+		"image":  opts.Image,
+		"origin": opts.Origin,
+		"commit": opts.Commit,
+		"user":   userName,
+	})
+	if err != nil {
+		return err
+	}
 	var imageID string
 	evt, err := event.New(&event.Opts{
 		Target:        appTarget(appName),
@@ -147,11 +164,11 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	}
 	defer func() { evt.DoneCustomData(err, map[string]string{"image": imageID}) }()
 	opts.Event = evt
-	writer := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "please wait...")
-	defer writer.Stop()
+	writer := newTextStream(w, r, 30*time.Second, "please wait...")
+	defer writer.Close()
 	opts.OutputStream = writer
 	imageID, err = app.Deploy(opts)
-	if err == nil {
+	if err == nil && !writer.IsFrames() {
 		fmt.Fprintln(w, "\nOK")
 	}
 	return err
@@ -171,6 +188,8 @@ func permSchemeForDeploy(opts app.DeployOptions) *permission.PermissionScheme {
 		return permission.PermAppDeployArchiveUrl
 	case app.DeployRollback:
 		return permission.PermAppDeployRollback
+	case app.DeployPromote:
+		return permission.PermAppDeployPromote
 	default:
 		return permission.PermAppDeploy
 	}
@@ -242,10 +261,8 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 			}
 		}
 	}
-	w.Header().Set("Content-Type", "application/x-json-stream")
-	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
-	defer keepAliveWriter.Stop()
-	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	writer := newJSONMessageStream(w, r, 30*time.Second)
+	defer writer.Close()
 	opts := app.DeployOptions{
 		App:          instance,
 		OutputStream: writer,
@@ -259,6 +276,15 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	if !canRollback {
 		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
 	}
+	err = checkPolicy(t, permission.PermAppDeploy, appTarget(appName), map[string]interface{}{
+		"kind":   opts.Kind,
+		"image":  opts.Image,
+		"origin": opts.Origin,
+		"user":   opts.User,
+	})
+	if err != nil {
+		return err
+	}
 	var imageID string
 	evt, err := event.New(&event.Opts{
 		Target:        appTarget(appName),
@@ -276,7 +302,7 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	opts.Event = evt
 	imageID, err = app.Deploy(opts)
 	if err != nil {
-		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
+		writer.WriteError(err)
 	}
 	return nil
 }
@@ -312,6 +338,42 @@ func deploysList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return json.NewEncoder(w).Encode(deploys)
 }
 
+// title: app deploy stats
+// path: /apps/{app}/deploys/stats
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appDeployStats(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	var windows []time.Duration
+	for _, v := range r.URL.Query()["window"] {
+		window, err := time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for window: " + v}
+		}
+		windows = append(windows, window)
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppReadDeploy,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	stats, err := a.DeployStats(windows)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(stats)
+}
+
 // title: deploy info
 // path: /deploys/{deploy}
 // method: GET
@@ -364,10 +426,8 @@ func deployRebuild(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			Message: "Invalid deployment origin",
 		}
 	}
-	w.Header().Set("Content-Type", "application/x-json-stream")
-	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
-	defer keepAliveWriter.Stop()
-	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	writer := newJSONMessageStream(w, r, 30*time.Second)
+	defer writer.Close()
 	opts := app.DeployOptions{
 		App:          instance,
 		OutputStream: writer,
@@ -379,6 +439,97 @@ func deployRebuild(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !canDeploy {
 		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
 	}
+	err = checkPolicy(t, permission.PermAppDeploy, appTarget(appName), map[string]interface{}{
+		"kind":   opts.Kind,
+		"image":  opts.Image,
+		"origin": opts.Origin,
+		"user":   opts.User,
+	})
+	if err != nil {
+		return err
+	}
+	var imageID string
+	evt, err := event.New(&event.Opts{
+		Target:        appTarget(appName),
+		Kind:          permission.PermAppDeploy,
+		Owner:         t,
+		CustomData:    opts,
+		Allowed:       event.Allowed(permission.PermAppReadEvents, contextsForApp(instance)...),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, contextsForApp(instance)...),
+		Cancelable:    true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.DoneCustomData(err, map[string]string{"image": imageID}) }()
+	opts.Event = evt
+	imageID, err = app.Deploy(opts)
+	if err != nil {
+		writer.WriteError(err)
+	}
+	return nil
+}
+
+// title: promote
+// path: /apps/{appname}/deploy/promote
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: OK
+//   400: Invalid data
+//   403: Forbidden
+//   404: Not found
+func deployPromote(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":appname")
+	instance, err := app.GetByName(appName)
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", appName)}
+	}
+	sourceName := r.FormValue("source")
+	if sourceName == "" {
+		return &tsuruErrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "you must provide the source app",
+		}
+	}
+	source, err := app.GetByName(sourceName)
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", sourceName)}
+	}
+	canRead := permission.Check(t, permission.PermAppReadDeploy, contextsForApp(source)...)
+	if !canRead {
+		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	opts := app.DeployOptions{
+		App:       instance,
+		Image:     r.FormValue("image"),
+		User:      t.GetUserName(),
+		Origin:    "promote",
+		SourceApp: source.Name,
+	}
+	opts.GetKind()
+	canDeploy := permission.Check(t, permSchemeForDeploy(opts), contextsForApp(instance)...)
+	if !canDeploy {
+		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	err = opts.ResolveSourceImage()
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	writer := newJSONMessageStream(w, r, 30*time.Second)
+	defer writer.Close()
+	opts.OutputStream = writer
+	err = checkPolicy(t, permission.PermAppDeploy, appTarget(appName), map[string]interface{}{
+		"kind":      opts.Kind,
+		"image":     opts.Image,
+		"origin":    opts.Origin,
+		"user":      opts.User,
+		"sourceApp": opts.SourceApp,
+	})
+	if err != nil {
+		return err
+	}
 	var imageID string
 	evt, err := event.New(&event.Opts{
 		Target:        appTarget(appName),
@@ -396,7 +547,84 @@ func deployRebuild(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	opts.Event = evt
 	imageID, err = app.Deploy(opts)
 	if err != nil {
-		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
+		writer.WriteError(err)
 	}
 	return nil
 }
+
+const maxDeployFormValueSize = 1 << 20
+
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	defer os.Remove(f.Name())
+	return f.File.Close()
+}
+
+// readDeployUpload reads a multipart deploy request, streaming the uploaded
+// archive to a temporary file instead of buffering it in memory. The
+// remaining form values are stored in r.Form and r.PostForm. The returned
+// file is removed from disk when closed.
+func readDeployUpload(r *http.Request) (io.ReadCloser, int64, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, 0, &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	r.Form = r.URL.Query()
+	r.PostForm = url.Values{}
+	var file *tempFile
+	var fileSize int64
+	defer func() {
+		if err != nil && file != nil {
+			file.Close()
+		}
+	}()
+	for {
+		var part *multipart.Part
+		part, err = reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if part.FileName() == "" {
+			var value []byte
+			value, err = ioutil.ReadAll(io.LimitReader(part, maxDeployFormValueSize))
+			if err != nil {
+				return nil, 0, err
+			}
+			r.Form.Add(name, string(value))
+			r.PostForm.Add(name, string(value))
+			continue
+		}
+		if name != "file" || file != nil {
+			continue
+		}
+		var f *os.File
+		f, err = ioutil.TempFile("", "tsuru-deploy-")
+		if err != nil {
+			return nil, 0, err
+		}
+		file = &tempFile{File: f}
+		fileSize, err = io.Copy(file, part)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	if file == nil {
+		err = &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: http.ErrMissingFile.Error()}
+		return nil, 0, err
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "unable to read uploaded file")
+	}
+	return file, fileSize, nil
+}
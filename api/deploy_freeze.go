@@ -0,0 +1,234 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/deployfreeze"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+func deployFreezeError(err error) error {
+	switch err {
+	case deployfreeze.ErrNameRequired, deployfreeze.ErrInvalidPeriod, deployfreeze.ErrFreezeEnded,
+		deployfreeze.ErrExceptionReasonRequired:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case deployfreeze.ErrFreezeNotFound, deployfreeze.ErrExceptionNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case deployfreeze.ErrFreezeAlreadyExists, deployfreeze.ErrExceptionAlreadyExists,
+		deployfreeze.ErrExceptionAlreadyReviewed:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+// title: deploy freeze list
+// path: /deploy-freezes
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func listDeployFreezes(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	freezes, err := deployfreeze.List()
+	if err != nil {
+		return err
+	}
+	if len(freezes) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(freezes)
+}
+
+// title: deploy freeze create
+// path: /deploy-freezes
+// method: POST
+// consume: application/x-www-form-urlencoded, application/json
+// responses:
+//   201: Deploy freeze created
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Deploy freeze already exists
+func addDeployFreeze(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	allowed := permission.Check(t, permission.PermDeployFreezeCreate)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	var f deployfreeze.Freeze
+	err = decodeBody(r, &f)
+	if err != nil {
+		return err
+	}
+	f.CreatedBy = t.GetUserName()
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeDeployFreeze, Value: f.Name},
+		Kind:       permission.PermDeployFreezeCreate,
+		Owner:      t,
+		CustomData: bodyCustomData(r, f),
+		Allowed:    event.Allowed(permission.PermDeployFreezeReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = deployfreeze.Add(f)
+	if err != nil {
+		return deployFreezeError(err)
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// title: deploy freeze remove
+// path: /deploy-freezes/{name}
+// method: DELETE
+// responses:
+//   200: Deploy freeze removed
+//   401: Unauthorized
+//   404: Deploy freeze not found
+func removeDeployFreeze(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	allowed := permission.Check(t, permission.PermDeployFreezeDelete)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	name := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeDeployFreeze, Value: name},
+		Kind:       permission.PermDeployFreezeDelete,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermDeployFreezeReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return deployFreezeError(deployfreeze.Remove(name))
+}
+
+// title: deploy freeze exception request
+// path: /deploy-freezes/{name}/exceptions
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Exception requested
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Deploy freeze or app not found
+//   409: App already has an exception request
+func requestDeployFreezeException(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	a, err := getApp(r.FormValue("app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppDeployFreezeException,
+		contextsForApp(a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppDeployFreezeException,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	exception, err := deployfreeze.RequestException(r.URL.Query().Get(":name"), a.Name, r.FormValue("reason"), t.GetUserName())
+	if err != nil {
+		return deployFreezeError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(exception)
+}
+
+// title: deploy freeze exception list
+// path: /deploy-freezes/{name}/exceptions
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func listDeployFreezeExceptions(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	exceptions, err := deployfreeze.ListExceptions(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	// Users who can't review exceptions only see their own requests.
+	if !permission.Check(t, permission.PermDeployFreezeExceptionApprove) {
+		var own []deployfreeze.Exception
+		for _, e := range exceptions {
+			if e.RequestedBy == t.GetUserName() {
+				own = append(own, e)
+			}
+		}
+		exceptions = own
+	}
+	if len(exceptions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(exceptions)
+}
+
+// title: deploy freeze exception review
+// path: /deploy-freezes/{name}/exceptions/{id}
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: Exception reviewed
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Exception not found
+//   409: Exception already reviewed
+func reviewDeployFreezeException(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	approve, err := strconv.ParseBool(r.FormValue("approve"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for approve: " + err.Error()}
+	}
+	allowed := permission.Check(t, permission.PermDeployFreezeExceptionApprove)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	name := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeDeployFreeze, Value: name},
+		Kind:       permission.PermDeployFreezeExceptionApprove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermDeployFreezeReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	exception, err := deployfreeze.ReviewException(name, r.URL.Query().Get(":id"), approve, t.GetUserName())
+	if err != nil {
+		return deployFreezeError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(exception)
+}
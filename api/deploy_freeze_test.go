@@ -0,0 +1,215 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/deployfreeze"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) addActiveFreeze(c *check.C, name string) deployfreeze.Freeze {
+	f := deployfreeze.Freeze{
+		Name:  name,
+		Start: time.Now().Add(-time.Hour).UTC().Truncate(time.Second),
+		End:   time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+	}
+	err := deployfreeze.Add(f)
+	c.Assert(err, check.IsNil)
+	return f
+}
+
+func (s *S) TestDeployFreezeAdd(c *check.C) {
+	recorder := httptest.NewRecorder()
+	start := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	end := start.Add(48 * time.Hour)
+	body := strings.NewReader(fmt.Sprintf("name=xmas&start=%s&end=%s&pools.0=prod&reason=holidays", start.Format(time.RFC3339), end.Format(time.RFC3339)))
+	request, err := http.NewRequest("POST", "/deploy-freezes", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated, check.Commentf("%s", recorder.Body.String()))
+	f, err := deployfreeze.Get("xmas")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Pools, check.DeepEquals, []string{"prod"})
+	c.Assert(f.Start.Equal(start), check.Equals, true)
+	c.Assert(f.End.Equal(end), check.Equals, true)
+	c.Assert(f.Reason, check.Equals, "holidays")
+	c.Assert(f.CreatedBy, check.Equals, s.token.GetUserName())
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeDeployFreeze, Value: "xmas"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "deploy-freeze.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "xmas"},
+			{"name": "pools.0", "value": "prod"},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("GET", "/deploy-freezes", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var freezes []deployfreeze.Freeze
+	err = json.NewDecoder(recorder.Body).Decode(&freezes)
+	c.Assert(err, check.IsNil)
+	c.Assert(freezes, check.HasLen, 1)
+	c.Assert(freezes[0].Name, check.Equals, "xmas")
+}
+
+func (s *S) TestDeployFreezeAddInvalidPeriod(c *check.C) {
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("name=xmas&start=2017-12-25T00:00:00Z&end=2017-12-20T00:00:00Z")
+	request, err := http.NewRequest("POST", "/deploy-freezes", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, deployfreeze.ErrInvalidPeriod.Error()+"\n")
+}
+
+func (s *S) TestDeployFreezeAddUnauthorized(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppDeploy,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("name=xmas&start=2017-12-20T00:00:00Z&end=2017-12-25T00:00:00Z")
+	request, err := http.NewRequest("POST", "/deploy-freezes", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestDeployFreezeRemove(c *check.C) {
+	s.addActiveFreeze(c, "xmas")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("DELETE", "/deploy-freezes/xmas", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = deployfreeze.Get("xmas")
+	c.Assert(err, check.Equals, deployfreeze.ErrFreezeNotFound)
+	request, err = http.NewRequest("DELETE", "/deploy-freezes/xmas", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestDeployFreezeExceptionRequestAndReview(c *check.C) {
+	s.addActiveFreeze(c, "xmas")
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppDeploy,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	body := strings.NewReader("app=leper&reason=payment+gateway+fix")
+	request, err := http.NewRequest("POST", "/deploy-freezes/xmas/exceptions", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated, check.Commentf("%s", recorder.Body.String()))
+	var exception deployfreeze.Exception
+	err = json.NewDecoder(recorder.Body).Decode(&exception)
+	c.Assert(err, check.IsNil)
+	c.Assert(exception.Status, check.Equals, deployfreeze.ExceptionPending)
+	c.Assert(exception.RequestedBy, check.Equals, token.GetUserName())
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("leper"),
+		Owner:  token.GetUserName(),
+		Kind:   "app.deploy.freeze-exception",
+		StartCustomData: []map[string]interface{}{
+			{"name": "app", "value": "leper"},
+			{"name": "reason", "value": "payment gateway fix"},
+		},
+	}, eventtest.HasEvent)
+	c.Assert(deployfreeze.Check("leper", a.Pool), check.FitsTypeOf, &deployfreeze.FrozenError{})
+	request, err = http.NewRequest("POST", "/deploy-freezes/xmas/exceptions/"+exception.ID.Hex(), strings.NewReader("approve=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	request, err = http.NewRequest("POST", "/deploy-freezes/xmas/exceptions/"+exception.ID.Hex(), strings.NewReader("approve=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeDeployFreeze, Value: "xmas"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "deploy-freeze.exception.approve",
+		StartCustomData: []map[string]interface{}{
+			{"name": "approve", "value": "true"},
+		},
+	}, eventtest.HasEvent)
+	c.Assert(deployfreeze.Check("leper", a.Pool), check.IsNil)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestDeployFreezeExceptionListOnlyOwnRequests(c *check.C) {
+	s.addActiveFreeze(c, "xmas")
+	_, err := deployfreeze.RequestException("xmas", "leper", "fix", "someone@tsuru.io")
+	c.Assert(err, check.IsNil)
+	_, err = deployfreeze.RequestException("xmas", "lepra", "fix", "majortom@groundcontrol.com")
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppDeploy,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/deploy-freezes/xmas/exceptions", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var exceptions []deployfreeze.Exception
+	err = json.NewDecoder(recorder.Body).Decode(&exceptions)
+	c.Assert(err, check.IsNil)
+	c.Assert(exceptions, check.HasLen, 1)
+	c.Assert(exceptions[0].App, check.Equals, "lepra")
+	request, err = http.NewRequest("GET", "/deploy-freezes/xmas/exceptions", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	err = json.NewDecoder(recorder.Body).Decode(&exceptions)
+	c.Assert(err, check.IsNil)
+	c.Assert(exceptions, check.HasLen, 2)
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/deployqueue"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppDeployPrioritySetAndUnset(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("priority=critical")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/deploy-priority", a.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	priority, err := deployqueue.PriorityFor(a.Name, a.TeamOwner)
+	c.Assert(err, check.IsNil)
+	c.Assert(priority, check.Equals, deployqueue.PriorityCritical)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.deploy-priority",
+		StartCustomData: []map[string]interface{}{
+			{"name": "priority", "value": "critical"},
+			{"name": ":app", "value": "leper"},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("DELETE", fmt.Sprintf("/apps/%s/deploy-priority", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	priority, err = deployqueue.PriorityFor(a.Name, a.TeamOwner)
+	c.Assert(err, check.IsNil)
+	c.Assert(priority, check.Equals, deployqueue.PriorityNormal)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppDeployPrioritySetInvalid(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("priority=urgent")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/deploy-priority", a.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, deployqueue.ErrInvalidPriority.Error()+"\n")
+}
+
+func (s *S) TestTeamDeployPrioritySet(c *check.C) {
+	body := strings.NewReader("priority=low")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/teams/%s/deploy-priority", s.team.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	priority, err := deployqueue.PriorityFor("anyapp", s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(priority, check.Equals, deployqueue.PriorityLow)
+	request, err = http.NewRequest("PUT", "/teams/unknown/deploy-priority", strings.NewReader("priority=low"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestDeployQueueList(c *check.C) {
+	config.Set("deploy:max-concurrent", 1)
+	defer config.Unset("deploy:max-concurrent")
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	done, err := deployqueue.Wait(a.Name, a.TeamOwner, nil)
+	c.Assert(err, check.IsNil)
+	defer done()
+	request, err := http.NewRequest("GET", "/deploys/queue", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var entries []deployqueue.Entry
+	err = json.NewDecoder(recorder.Body).Decode(&entries)
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].App, check.Equals, "leper")
+	c.Assert(entries[0].Priority, check.Equals, deployqueue.PriorityNormal)
+	c.Assert(entries[0].Running, check.Equals, true)
+}
+
+func (s *S) TestDeployQueueListNoPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("GET", "/deploys/queue", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
@@ -0,0 +1,193 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/deploystatus"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	tsuruNet "github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/permission"
+)
+
+func deployStatusConfigFromForm(r *http.Request) deploystatus.Config {
+	return deploystatus.Config{
+		Provider:  r.FormValue("provider"),
+		URL:       r.FormValue("url"),
+		Token:     r.FormValue("token"),
+		TargetURL: r.FormValue("targetURL"),
+	}
+}
+
+// deployStatusCustomData returns the event custom data for the request form,
+// hiding the provider token.
+func deployStatusCustomData(form url.Values) []map[string]interface{} {
+	values := url.Values{}
+	for k, v := range form {
+		if k == "token" {
+			v = []string{"*****"}
+		}
+		values[k] = v
+	}
+	return event.FormToCustomData(values)
+}
+
+func deployStatusError(err error) error {
+	switch err {
+	case deploystatus.ErrInvalidProvider, deploystatus.ErrTokenRequired:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case deploystatus.ErrConfigNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case tsuruNet.ErrInvalidExternalURL:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if _, ok := err.(*tsuruNet.InternalAddressError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: app deploy status set
+// path: /apps/{app}/deploy-status
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appDeployStatusSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateDeployStatus,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateDeployStatus,
+		Owner:      t,
+		CustomData: deployStatusCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return deployStatusError(deploystatus.SetAppConfig(appName, deployStatusConfigFromForm(r)))
+}
+
+// title: app deploy status unset
+// path: /apps/{app}/deploy-status
+// method: DELETE
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: Not found
+func appDeployStatusUnset(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateDeployStatus,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateDeployStatus,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return deployStatusError(deploystatus.RemoveAppConfig(appName))
+}
+
+// title: team deploy status set
+// path: /teams/{name}/deploy-status
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Team not found
+func teamDeployStatusSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamUpdateDeployStatus,
+		permission.Context(permission.CtxTeam, name),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	_, err = auth.GetTeam(name)
+	if err != nil {
+		if err == auth.ErrTeamNotFound {
+			return newCodedError(r, http.StatusNotFound, errors.ErrorCodeTeamNotFound, err.Error())
+		}
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     teamTarget(name),
+		Kind:       permission.PermTeamUpdateDeployStatus,
+		Owner:      t,
+		CustomData: deployStatusCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return deployStatusError(deploystatus.SetTeamConfig(name, deployStatusConfigFromForm(r)))
+}
+
+// title: team deploy status unset
+// path: /teams/{name}/deploy-status
+// method: DELETE
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: Not found
+func teamDeployStatusUnset(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamUpdateDeployStatus,
+		permission.Context(permission.CtxTeam, name),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     teamTarget(name),
+		Kind:       permission.PermTeamUpdateDeployStatus,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return deployStatusError(deploystatus.RemoveTeamConfig(name))
+}
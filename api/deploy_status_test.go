@@ -0,0 +1,121 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/deploystatus"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppDeployStatusSet(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("provider=github&token=secret")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/deploy-status", a.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	cfg, err := deploystatus.ConfigFor(a.Name, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg, check.DeepEquals, &deploystatus.Config{Provider: "github", Token: "secret"})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.deploy-status",
+		StartCustomData: []map[string]interface{}{
+			{"name": "provider", "value": "github"},
+			{"name": "token", "value": "*****"},
+			{"name": ":app", "value": "leper"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppDeployStatusSetInvalidProvider(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("provider=svn&token=secret")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/deploy-status", a.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, deploystatus.ErrInvalidProvider.Error()+"\n")
+}
+
+func (s *S) TestAppDeployStatusSetInternalURL(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("provider=gitlab&token=secret&url=http://169.254.169.254/api/v4")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/deploy-status", a.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "169.254.169.254 is an internal address\n")
+	_, err = deploystatus.ConfigFor(a.Name, "")
+	c.Assert(err, check.Equals, deploystatus.ErrConfigNotFound)
+}
+
+func (s *S) TestAppInfoDoesNotExposeDeployStatusToken(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = deploystatus.SetAppConfig(a.Name, deploystatus.Config{Provider: "github", Token: "my-secret-token"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/"+a.Name, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Not(check.Matches), "(?s).*my-secret-token.*")
+}
+
+func (s *S) TestTeamDeployStatusSetAndUnset(c *check.C) {
+	body := strings.NewReader("provider=gitlab&token=secret&url=https://gitlab.example.com/api/v4")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/teams/%s/deploy-status", s.team.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	cfg, err := deploystatus.ConfigFor("anyapp", s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.URL, check.Equals, "https://gitlab.example.com/api/v4")
+	request, err = http.NewRequest("DELETE", fmt.Sprintf("/teams/%s/deploy-status", s.team.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = deploystatus.ConfigFor("anyapp", s.team.Name)
+	c.Assert(err, check.Equals, deploystatus.ErrConfigNotFound)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/tsuru/tsuru/db/dbtest"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
+	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/permission/permissiontest"
 	"github.com/tsuru/tsuru/provision"
@@ -188,6 +189,83 @@ func (s *DeploySuite) TestDeployOriginDragAndDrop(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *DeploySuite) TestDeployUploadFormValuesAfterFile(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	file, err := writer.CreateFormFile("file", "archive.tar.gz")
+	c.Assert(err, check.IsNil)
+	file.Write([]byte("hello world!"))
+	writer.WriteField("origin", "drag-and-drop")
+	writer.Close()
+	request, err := http.NewRequest("POST", url, &body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "multipart/form-data; boundary="+writer.Boundary())
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "Upload deploy called\nOK\n")
+	deploys, err := s.conn.Events().Find(bson.M{"target.value": a.Name, "kind.name": "app.deploy"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.Equals, 1)
+}
+
+func (s *DeploySuite) TestDeployUploadTooLarge(c *check.C) {
+	config.Set("server:request-body-limit:deploy", 100)
+	defer config.Unset("server:request-body-limit")
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	file, err := writer.CreateFormFile("file", "archive.tar.gz")
+	c.Assert(err, check.IsNil)
+	file.Write(bytes.Repeat([]byte("a"), 200))
+	writer.Close()
+	request, err := http.NewRequest("POST", url, &body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "multipart/form-data; boundary="+writer.Boundary())
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusRequestEntityTooLarge)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s)request body too large: the limit for deploy requests is 100 bytes\. .*archive URL.*`)
+}
+
+func (s *DeploySuite) TestDeployUploadTooLargeUnknownLength(c *check.C) {
+	config.Set("server:request-body-limit:deploy", 100)
+	defer config.Unset("server:request-body-limit")
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	file, err := writer.CreateFormFile("file", "archive.tar.gz")
+	c.Assert(err, check.IsNil)
+	file.Write(bytes.Repeat([]byte("a"), 200))
+	writer.Close()
+	request, err := http.NewRequest("POST", url, &body)
+	c.Assert(err, check.IsNil)
+	request.ContentLength = -1
+	request.Header.Set("Content-Type", "multipart/form-data; boundary="+writer.Boundary())
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusRequestEntityTooLarge)
+}
+
 func (s *DeploySuite) TestDeployInvalidOrigin(c *check.C) {
 	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
 	user, _ := s.token.User()
@@ -518,6 +596,24 @@ func (s *DeploySuite) TestDeployDockerImage(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *DeploySuite) TestDeployDockerImageWithJSONFrames(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy?output=json", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("image=127.0.0.1:5000/tsuru/otherapp"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, tsuruIo.FrameContentType)
+	c.Assert(recorder.Body.String(), check.Equals, `{"type":"message","message":"Image deploy called"}`+"\n")
+}
+
 func (s *DeploySuite) TestDeployShouldIncrementDeployNumberOnApp(c *check.C) {
 	user, _ := s.token.User()
 	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
@@ -831,6 +927,88 @@ func (s *DeploySuite) TestDeployListAppWithNoDeploys(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
 }
 
+func (s *DeploySuite) TestAppDeployStats(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	insertDeploysAsEvents([]app.DeployData{
+		{App: "g1", Timestamp: time.Now().Add(-time.Hour)},
+		{App: "g1", Timestamp: time.Now().Add(-48 * time.Hour)},
+	}, c)
+	request, err := http.NewRequest("GET", "/apps/g1/deploys/stats?window=24h&window=72h", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var stats []app.DeployStats
+	err = json.Unmarshal(recorder.Body.Bytes(), &stats)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats, check.HasLen, 2)
+	c.Assert(stats[0].Window, check.Equals, 24*time.Hour)
+	c.Assert(stats[0].Deploys, check.Equals, 1)
+	c.Assert(stats[1].Window, check.Equals, 72*time.Hour)
+	c.Assert(stats[1].Deploys, check.Equals, 2)
+	c.Assert(stats[1].FailureRate, check.Equals, 0.0)
+}
+
+func (s *DeploySuite) TestAppDeployStatsDefaultWindows(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/g1/deploys/stats", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var stats []app.DeployStats
+	err = json.Unmarshal(recorder.Body.Bytes(), &stats)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats, check.HasLen, len(app.DefaultDeployStatsWindows))
+	for i, window := range app.DefaultDeployStatsWindows {
+		c.Assert(stats[i], check.DeepEquals, app.DeployStats{Window: window})
+	}
+}
+
+func (s *DeploySuite) TestAppDeployStatsInvalidWindow(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/g1/deploys/stats?window=-1h", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid value for window: -1h\n")
+}
+
+func (s *DeploySuite) TestAppDeployStatsWithoutPermission(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "otheruser", permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxApp, "other-app"),
+	})
+	request, err := http.NewRequest("GET", "/apps/g1/deploys/stats", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *DeploySuite) TestDeployInfoByAdminUser(c *check.C) {
 	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
 	user, _ := s.token.User()
@@ -1015,6 +1193,65 @@ func (s *DeploySuite) TestDeployRollbackHandler(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *DeploySuite) TestDeployPromoteHandler(c *check.C) {
+	user, _ := s.token.User()
+	source := app.App{Name: "staging", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&source, user)
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName("staging", "127.0.0.1:5000/tsuru/app-staging:v1")
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("source", "staging")
+	v.Set("image", "v1")
+	u := fmt.Sprintf("/apps/%s/deploy/promote", a.Name)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"Image deploy called\"}\n")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy",
+		StartCustomData: map[string]interface{}{
+			"app.name":    a.Name,
+			"kind":        "promote",
+			"image":       "127.0.0.1:5000/tsuru/app-staging:v1",
+			"origin":      "promote",
+			"sourceapp":   "staging",
+			"sourceimage": "127.0.0.1:5000/tsuru/app-staging:v1",
+		},
+		EndCustomData: map[string]interface{}{
+			"image": "127.0.0.1:5000/tsuru/app-staging:v1",
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployPromoteHandlerWithoutSource(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	u := fmt.Sprintf("/apps/%s/deploy/promote", a.Name)
+	request, err := http.NewRequest("POST", u, strings.NewReader(""))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "you must provide the source app\n")
+}
+
 func (s *DeploySuite) TestDeployRollbackHandlerWithCompleteImage(c *check.C) {
 	user, _ := s.token.User()
 	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
@@ -0,0 +1,190 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/egress"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+const defaultBlockedEgressPeriod = time.Hour
+
+func egressRuleFromForm(r *http.Request) (egress.Rule, error) {
+	rule := egress.Rule{Host: r.FormValue("host")}
+	if port := r.FormValue("port"); port != "" {
+		var err error
+		rule.Port, err = strconv.Atoi(port)
+		if err != nil {
+			return rule, &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid port: " + port}
+		}
+	}
+	return rule, nil
+}
+
+func egressError(err error) error {
+	switch err {
+	case egress.ErrInvalidRule, egress.ErrNotEnforced:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case app.ErrEgressRuleNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: app egress rules list
+// path: /apps/{app}/egress
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func egressRuleList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	if len(a.Egress) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(a.Egress)
+}
+
+// title: app egress rule add
+// path: /apps/{app}/egress
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func egressRuleAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	rule, err := egressRuleFromForm(r)
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateEgress,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateEgress,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return egressError(a.AddEgressRules(rule))
+}
+
+// title: app egress rule remove
+// path: /apps/{app}/egress
+// method: DELETE
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App or rule not found
+func egressRuleRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	rule, err := egressRuleFromForm(r)
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateEgress,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateEgress,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return egressError(a.RemoveEgressRules(rule))
+}
+
+// title: app blocked egress attempts
+// path: /apps/{app}/egress/blocked
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func egressBlockedList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	period := defaultBlockedEgressPeriod
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		period, err = time.ParseDuration(v)
+		if err != nil || period <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for since: " + v}
+		}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	attempts, err := a.BlockedEgressAttempts(time.Now().Add(-period))
+	if err != nil {
+		return err
+	}
+	if len(attempts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(attempts)
+}
@@ -0,0 +1,166 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/egress"
+	"github.com/tsuru/tsuru/egress/egresstest"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestEgressRuleAdd(c *check.C) {
+	config.Set("egress:backend", "fake")
+	defer config.Unset("egress:backend")
+	defer egresstest.Reset()
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("host=payments.example.com&port=443")
+	request, err := http.NewRequest("POST", "/apps/leper/egress", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	expected := []egress.Rule{{Host: "payments.example.com", Port: 443}}
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Egress, check.DeepEquals, expected)
+	c.Assert(egresstest.Rules(a.Name), check.DeepEquals, expected)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.egress",
+		StartCustomData: []map[string]interface{}{
+			{"name": "host", "value": "payments.example.com"},
+			{"name": "port", "value": "443"},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("GET", "/apps/leper/egress", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var rules []egress.Rule
+	err = json.NewDecoder(recorder.Body).Decode(&rules)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, expected)
+}
+
+func (s *S) TestEgressRuleAddInvalid(c *check.C) {
+	config.Set("egress:backend", "fake")
+	defer config.Unset("egress:backend")
+	defer egresstest.Reset()
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	for _, data := range []string{"host=*", "host=payments.example.com&port=https"} {
+		request, err := http.NewRequest("POST", "/apps/leper/egress", strings.NewReader(data))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "b "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m := RunServer(true)
+		m.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest, check.Commentf("%s", data))
+	}
+}
+
+func (s *S) TestEgressRuleAddNotEnforced(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/leper/egress", strings.NewReader("host=payments.example.com"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, egress.ErrNotEnforced.Error()+"\n")
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Egress, check.IsNil)
+}
+
+func (s *S) TestEgressRuleRemove(c *check.C) {
+	config.Set("egress:backend", "fake")
+	defer config.Unset("egress:backend")
+	defer egresstest.Reset()
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddEgressRules(egress.Rule{Host: "payments.example.com", Port: 443})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/leper/egress?host=payments.example.com&port=80", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	request, err = http.NewRequest("DELETE", "/apps/leper/egress?host=payments.example.com&port=443", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Egress, check.IsNil)
+}
+
+func (s *S) TestEgressBlockedList(c *check.C) {
+	config.Set("egress:backend", "fake")
+	defer config.Unset("egress:backend")
+	defer egresstest.Reset()
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	now := time.Now().UTC().Truncate(time.Second)
+	egresstest.AddBlockedAttempt(a.Name, egress.BlockedAttempt{Host: "old.example.com", Port: 80, Count: 1, LastSeen: now.Add(-2 * time.Hour)})
+	egresstest.AddBlockedAttempt(a.Name, egress.BlockedAttempt{Host: "evil.example.com", Port: 443, Count: 7, LastSeen: now})
+	request, err := http.NewRequest("GET", "/apps/leper/egress/blocked", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var attempts []egress.BlockedAttempt
+	err = json.NewDecoder(recorder.Body).Decode(&attempts)
+	c.Assert(err, check.IsNil)
+	c.Assert(attempts, check.HasLen, 1)
+	c.Assert(attempts[0].Host, check.Equals, "evil.example.com")
+	c.Assert(attempts[0].Count, check.Equals, 7)
+	request, err = http.NewRequest("GET", "/apps/leper/egress/blocked?since=3h", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	err = json.NewDecoder(recorder.Body).Decode(&attempts)
+	c.Assert(err, check.IsNil)
+	c.Assert(attempts, check.HasLen, 2)
+	request, err = http.NewRequest("GET", "/apps/leper/egress/blocked?since=yesterday", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+type envRotateParams struct {
+	Name      string
+	OldValue  string
+	NewValue  string
+	BatchSize int
+	NoRestart bool
+	Dry       bool
+}
+
+// title: rotate env in apps
+// path: /envs/rotate
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: Env rotated
+//   204: No apps found
+//   400: Invalid data
+//   401: Unauthorized
+func envRotate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermAppAdminEnvRotate) {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var params envRotateParams
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	err = dec.DecodeValues(&params, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if params.Name == "" || params.OldValue == "" || params.NewValue == "" {
+		msg := "You must provide the env name, the old value and the new value"
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	if params.BatchSize < 0 {
+		msg := "The batch size must not be negative"
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	apps, err := app.ListAppsWithEnv(params.Name, params.OldValue)
+	if err == app.ErrInvalidEnvName {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	if len(apps) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	if params.Dry {
+		names := make([]string, len(apps))
+		for i := range apps {
+			names[i] = apps[i].Name
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(names)
+	}
+	batchSize := params.BatchSize
+	if batchSize == 0 {
+		batchSize = len(apps)
+	}
+	writer := newJSONMessageStream(w, r, 15*time.Second)
+	defer writer.Close()
+	for start := 0; start < len(apps); start += batchSize {
+		end := start + batchSize
+		if end > len(apps) {
+			end = len(apps)
+		}
+		fmt.Fprintf(writer, "---- Rotating %s in %d apps (batch %d) ----\n", params.Name, end-start, start/batchSize+1)
+		var failed []string
+		for i := start; i < end; i++ {
+			rotated, rotateErr := rotateAppEnv(apps[i].Name, params, t, writer)
+			if rotateErr != nil {
+				fmt.Fprintf(writer, "Failed to rotate %s in app %q: %s\n", params.Name, apps[i].Name, rotateErr)
+				failed = append(failed, apps[i].Name)
+				continue
+			}
+			if !rotated {
+				fmt.Fprintf(writer, "Skipped app %q, %s changed since the apps were listed.\n", apps[i].Name, params.Name)
+				continue
+			}
+			fmt.Fprintf(writer, "Rotated %s in app %q.\n", params.Name, apps[i].Name)
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("env rotation stopped, %d apps could not be updated: %v", len(failed), failed)
+		}
+	}
+	return nil
+}
+
+// rotateAppEnv locks the app and creates an event for it. The values are not
+// added to the event data, as the old value is likely a leaked secret. The
+// app is loaded again once locked, and is skipped, returning false, when the
+// variable no longer has the old value.
+func rotateAppEnv(appName string, params envRotateParams, t auth.Token, w *outputStream) (rotated bool, err error) {
+	locked, err := app.AcquireApplicationLockWait(appName, t.GetUserName(), "env rotate", lockWaitDuration)
+	if err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, fmt.Errorf("app %q is locked", appName)
+	}
+	defer app.ReleaseApplicationLock(appName)
+	a, err := app.GetByName(appName)
+	if err != nil {
+		return false, err
+	}
+	if env, ok := a.Env[params.Name]; !ok || env.InstanceName != "" || env.Value != params.OldValue {
+		return false, nil
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppAdminEnvRotate,
+		Owner:      t,
+		CustomData: map[string]interface{}{"name": params.Name},
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(a)...),
+	})
+	if err != nil {
+		return false, err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.RotateEnv(params.Name, params.NewValue, !params.NoRestart, w)
+	return err == nil, err
+}
@@ -0,0 +1,160 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createEnvRotateApps(c *check.C) {
+	for _, name := range []string{"app1", "app2", "app3"} {
+		a := app.App{
+			Name:      name,
+			Platform:  "zend",
+			TeamOwner: s.team.Name,
+			Env:       map[string]bind.EnvVar{"KEY": {Name: "KEY", Value: "leaked"}},
+		}
+		if name == "app3" {
+			a.Env["KEY"] = bind.EnvVar{Name: "KEY", Value: "safe"}
+		}
+		err := app.CreateApp(&a, s.user)
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *S) TestEnvRotateDry(c *check.C) {
+	s.createEnvRotateApps(c)
+	body := strings.NewReader("Name=KEY&OldValue=leaked&NewValue=new&Dry=true")
+	request, err := http.NewRequest("POST", "/envs/rotate", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var names []string
+	err = json.NewDecoder(recorder.Body).Decode(&names)
+	c.Assert(err, check.IsNil)
+	c.Assert(names, check.DeepEquals, []string{"app1", "app2"})
+	dbApp, err := app.GetByName("app1")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["KEY"].Value, check.Equals, "leaked")
+}
+
+func (s *S) TestEnvRotate(c *check.C) {
+	s.createEnvRotateApps(c)
+	body := strings.NewReader("Name=KEY&OldValue=leaked&NewValue=new&BatchSize=1&NoRestart=true")
+	request, err := http.NewRequest("POST", "/envs/rotate", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Rotated KEY in app \\"app1\\".*Rotated KEY in app \\"app2\\".*`)
+	for name, value := range map[string]string{"app1": "new", "app2": "new", "app3": "safe"} {
+		dbApp, err := app.GetByName(name)
+		c.Assert(err, check.IsNil)
+		c.Check(dbApp.Env["KEY"].Value, check.Equals, value)
+	}
+	for _, name := range []string{"app1", "app2"} {
+		c.Assert(eventtest.EventDesc{
+			Target:          appTarget(name),
+			Owner:           s.token.GetUserName(),
+			Kind:            "app.admin.env.rotate",
+			StartCustomData: map[string]interface{}{"name": "KEY"},
+		}, eventtest.HasEvent)
+	}
+}
+
+func (s *S) TestEnvRotateLockedApp(c *check.C) {
+	oldDuration := lockWaitDuration
+	lockWaitDuration = 100 * time.Millisecond
+	defer func() { lockWaitDuration = oldDuration }()
+	s.createEnvRotateApps(c)
+	locked, err := app.AcquireApplicationLock("app1", "test", "test")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	defer app.ReleaseApplicationLock("app1")
+	body := strings.NewReader("Name=KEY&OldValue=leaked&NewValue=new&NoRestart=true")
+	request, err := http.NewRequest("POST", "/envs/rotate", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Failed to rotate KEY in app \\"app1\\": app \\"app1\\" is locked.*`)
+	dbApp, err := app.GetByName("app1")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["KEY"].Value, check.Equals, "leaked")
+}
+
+func (s *S) TestEnvRotateInvalidName(c *check.C) {
+	body := strings.NewReader("Name=KEY.value&OldValue=leaked&NewValue=new")
+	request, err := http.NewRequest("POST", "/envs/rotate", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrInvalidEnvName.Error()+"\n")
+}
+
+func (s *S) TestEnvRotateNoApps(c *check.C) {
+	body := strings.NewReader("Name=KEY&OldValue=leaked&NewValue=new")
+	request, err := http.NewRequest("POST", "/envs/rotate", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestEnvRotateInvalidParams(c *check.C) {
+	for _, data := range []string{"Name=KEY&NewValue=new", "Name=KEY&OldValue=a&NewValue=b&BatchSize=-1"} {
+		request, err := http.NewRequest("POST", "/envs/rotate", strings.NewReader(data))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "b "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m := RunServer(true)
+		m.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest, check.Commentf("data %q", data))
+	}
+}
+
+func (s *S) TestEnvRotateRequiresGlobalPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppAdminEnvRotate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	body := strings.NewReader("Name=KEY&OldValue=leaked&NewValue=new")
+	request, err := http.NewRequest("POST", "/envs/rotate", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
@@ -35,6 +35,9 @@ func eventList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse event filters: %s", err)}
 	}
+	if filter.CorrelationID != "" && !bson.IsObjectIdHex(filter.CorrelationID) {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid correlation id: " + filter.CorrelationID}
+	}
 	filter.PruneUserValues()
 	filter.Permissions, err = t.Permissions()
 	if err != nil {
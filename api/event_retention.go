@@ -0,0 +1,121 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: event retention rule list
+// path: /events/retention-rules
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func eventRetentionRuleList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermEventRetentionRuleRead) {
+		return permission.ErrUnauthorized
+	}
+	rules, err := event.ListRetentionRules()
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(rules)
+}
+
+// title: event retention rule set
+// path: /events/retention-rules
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+func eventRetentionRuleSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermEventRetentionRuleSet) {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	rule := event.RetentionRule{
+		Name:   r.FormValue("name"),
+		Kind:   r.FormValue("kind"),
+		Action: event.RetentionAction(r.FormValue("action")),
+	}
+	if targetType := r.FormValue("targetType"); targetType != "" {
+		rule.TargetType = event.TargetType(targetType)
+	}
+	if retention := r.FormValue("retention"); retention != "" {
+		rule.Retention, err = time.ParseDuration(retention)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid retention %q, it must be a duration, like 2160h", retention)}
+		}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeEventRetentionRule, Value: rule.Name},
+		Kind:       permission.PermEventRetentionRuleSet,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermEventRetentionRuleReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = event.SetRetentionRule(rule)
+	if err == event.ErrInvalidRetentionRule {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: event retention rule remove
+// path: /events/retention-rules/{name}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func eventRetentionRuleRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermEventRetentionRuleRemove) {
+		return permission.ErrUnauthorized
+	}
+	name := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target: event.Target{Type: event.TargetTypeEventRetentionRule, Value: name},
+		Kind:   permission.PermEventRetentionRuleRemove,
+		Owner:  t,
+		CustomData: []map[string]interface{}{
+			{"name": "name", "value": name},
+		},
+		Allowed: event.Allowed(permission.PermEventRetentionRuleReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = event.RemoveRetentionRule(name)
+	if err == event.ErrRetentionRuleNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/permission/permissiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *EventSuite) TestEventRetentionRuleList(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventRetentionRuleRead,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	err := event.SetRetentionRule(event.RetentionRule{
+		Name:       "nodes",
+		TargetType: event.TargetTypeNode,
+		Retention:  720 * time.Hour,
+		Action:     event.RetentionActionExpire,
+	})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/events/retention-rules", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var rules []event.RetentionRule
+	err = json.Unmarshal(recorder.Body.Bytes(), &rules)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []event.RetentionRule{{
+		Name:       "nodes",
+		TargetType: event.TargetTypeNode,
+		Retention:  720 * time.Hour,
+		Action:     event.RetentionActionExpire,
+	}})
+}
+
+func (s *EventSuite) TestEventRetentionRuleListEmpty(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventRetentionRuleRead,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	request, err := http.NewRequest("GET", "/events/retention-rules", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *EventSuite) TestEventRetentionRuleListWithoutPermission(c *check.C) {
+	request, err := http.NewRequest("GET", "/events/retention-rules", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *EventSuite) TestEventRetentionRuleSet(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventRetentionRuleSet,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	body := strings.NewReader("name=deploys&kind=app.deploy&retention=8760h&action=archive")
+	request, err := http.NewRequest("POST", "/events/retention-rules", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	rules, err := event.ListRetentionRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []event.RetentionRule{{
+		Name:      "deploys",
+		Kind:      "app.deploy",
+		Retention: 8760 * time.Hour,
+		Action:    event.RetentionActionArchive,
+	}})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeEventRetentionRule, Value: "deploys"},
+		Owner:  token.GetUserName(),
+		Kind:   "event-retention-rule.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "deploys"},
+			{"name": "kind", "value": "app.deploy"},
+			{"name": "retention", "value": "8760h"},
+			{"name": "action", "value": "archive"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *EventSuite) TestEventRetentionRuleSetInvalid(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventRetentionRuleSet,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	tests := []struct {
+		body string
+		msg  string
+	}{
+		{"name=deploys&retention=1y&action=expire", `invalid retention "1y", it must be a duration, like 2160h`},
+		{"name=deploys&retention=720h&action=delete", event.ErrInvalidRetentionRule.Error()},
+		{"retention=720h&action=expire", event.ErrInvalidRetentionRule.Error()},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("POST", "/events/retention-rules", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+token.GetValue())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		server := RunServer(true)
+		server.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, tt.msg+"\n")
+	}
+	rules, err := event.ListRetentionRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 0)
+}
+
+func (s *EventSuite) TestEventRetentionRuleRemove(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventRetentionRuleRemove,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	err := event.SetRetentionRule(event.RetentionRule{
+		Name:      "deploys",
+		Kind:      "app.deploy",
+		Retention: 720 * time.Hour,
+		Action:    event.RetentionActionExpire,
+	})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/events/retention-rules/deploys", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	rules, err := event.ListRetentionRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeEventRetentionRule, Value: "deploys"},
+		Owner:  token.GetUserName(),
+		Kind:   "event-retention-rule.remove",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "deploys"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *EventSuite) TestEventRetentionRuleRemoveNotFound(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventRetentionRuleRemove,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	request, err := http.NewRequest("DELETE", "/events/retention-rules/unknown", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, event.ErrRetentionRuleNotFound.Error()+"\n")
+}
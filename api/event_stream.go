@@ -0,0 +1,172 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"golang.org/x/net/websocket"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	// eventStreamInterval is how often the stream looks for new events.
+	eventStreamInterval = time.Second
+	// eventStreamLag is how far back, from the start time of the last sent
+	// event, each lookup goes. It covers events stored late by other API
+	// instances, which would be missed otherwise.
+	eventStreamLag = 10 * time.Second
+)
+
+// eventStreamFilter builds the filter used to look for new events, accepting
+// the same parameters as the event list plus the pool and team the events
+// must be related to.
+func eventStreamFilter(r *http.Request, t auth.Token) (*event.Filter, error) {
+	r.ParseForm()
+	filter := &event.Filter{}
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	err := dec.DecodeValues(&filter, r.Form)
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse event filters: %s", err)}
+	}
+	filter.PruneUserValues()
+	filter.Sort = "starttime"
+	filter.Until = time.Time{}
+	var contexts []bson.M
+	if pool := r.FormValue("pool"); pool != "" {
+		contexts = append(contexts, bson.M{"$elemMatch": bson.M{"ctxtype": permission.CtxPool, "value": pool}})
+	}
+	if team := r.FormValue("team"); team != "" {
+		contexts = append(contexts, bson.M{"$elemMatch": bson.M{"ctxtype": permission.CtxTeam, "value": team}})
+	}
+	if len(contexts) > 0 {
+		filter.Raw = bson.M{"allowed.contexts": bson.M{"$all": contexts}}
+	}
+	filter.Permissions, err = t.Permissions()
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// streamEvents sends the events matching filter, as they are started, until
+// done is closed or send fails. Each event is sent only once. Events started
+// before filter.Since, or before the call when it's not set, are not sent.
+func streamEvents(filter *event.Filter, done <-chan bool, send func(*event.Event) error) error {
+	cursor := time.Now().UTC()
+	if !filter.Since.IsZero() {
+		cursor = filter.Since
+	}
+	start := cursor
+	sent := map[bson.ObjectId]time.Time{}
+	for {
+		filter.Since = cursor.Add(-eventStreamLag)
+		events, err := event.List(filter)
+		if err != nil {
+			return err
+		}
+		for i := range events {
+			evt := &events[i]
+			if _, ok := sent[evt.UniqueID]; ok || evt.StartTime.Before(start) {
+				continue
+			}
+			err = send(evt)
+			if err != nil {
+				// the client is gone
+				return nil
+			}
+			sent[evt.UniqueID] = evt.StartTime
+			if evt.StartTime.After(cursor) {
+				cursor = evt.StartTime
+			}
+		}
+		for id, startTime := range sent {
+			if startTime.Before(filter.Since) {
+				delete(sent, id)
+			}
+		}
+		select {
+		case <-done:
+			return nil
+		case <-time.After(eventStreamInterval):
+		}
+	}
+}
+
+func isWebSocketRequest(r *http.Request) bool {
+	return strings.ToLower(r.Header.Get("Upgrade")) == "websocket"
+}
+
+// title: event stream
+// path: /events/stream
+// method: GET
+// produce: text/event-stream
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+func eventStream(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	filter, err := eventStreamFilter(r, t)
+	if err != nil {
+		return err
+	}
+	if isWebSocketRequest(r) {
+		websocket.Handler(func(ws *websocket.Conn) {
+			defer ws.Close()
+			done := make(chan bool)
+			go func() {
+				defer close(done)
+				var discard []byte
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+			}()
+			err := streamEvents(filter, done, func(evt *event.Event) error {
+				return websocket.JSON.Send(ws, evt)
+			})
+			if err != nil {
+				log.Errorf("failure in events stream: %s", err)
+				websocket.JSON.Send(ws, &errMsg{Error: err.Error()})
+			}
+		}).ServeHTTP(w, r)
+		return nil
+	}
+	var done <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		done = notifier.CloseNotify()
+	} else {
+		done = make(chan bool)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	disableProxyBuffering(w)
+	w.WriteHeader(http.StatusOK)
+	// sends the headers right away, even if no event is started soon
+	fmt.Fprint(w, ": stream started\n\n")
+	err = streamEvents(filter, done, func(evt *event.Event) error {
+		data, err := json.Marshal(evt)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %s\nevent: event\ndata: %s\n\n", evt.UniqueID.Hex(), data)
+		return err
+	})
+	if err != nil {
+		log.Errorf("failure in events stream: %s", err)
+		fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+	}
+	return nil
+}
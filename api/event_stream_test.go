@@ -0,0 +1,116 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"golang.org/x/net/websocket"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *EventSuite) newStreamEvent(c *check.C, t event.TargetType, ctxs ...permission.PermissionContext) *event.Event {
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: t, Value: bson.NewObjectId().Hex()},
+		Owner:   s.token,
+		Kind:    permission.PermAppDeploy,
+		Allowed: event.Allowed(permission.PermAppReadEvents, ctxs...),
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *EventSuite) readStreamEvent(c *check.C, reader *bufio.Reader) *event.Event {
+	for {
+		line, err := reader.ReadString('\n')
+		c.Assert(err, check.IsNil)
+		if strings.HasPrefix(line, "data: ") {
+			var evt event.Event
+			err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &evt)
+			c.Assert(err, check.IsNil)
+			return &evt
+		}
+	}
+}
+
+func (s *EventSuite) TestEventStreamSSE(c *check.C) {
+	oldInterval := eventStreamInterval
+	eventStreamInterval = 10 * time.Millisecond
+	defer func() { eventStreamInterval = oldInterval }()
+	srv := httptest.NewServer(RunServer(true))
+	defer srv.Close()
+	since := url.QueryEscape(time.Now().UTC().Add(-time.Second).Format(time.RFC3339Nano))
+	request, err := http.NewRequest("GET", srv.URL+"/events/stream?target.type=app&since="+since, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rsp, err := http.DefaultClient.Do(request)
+	c.Assert(err, check.IsNil)
+	defer rsp.Body.Close()
+	c.Assert(rsp.StatusCode, check.Equals, http.StatusOK)
+	c.Assert(rsp.Header.Get("Content-Type"), check.Equals, "text/event-stream")
+	ctx := permission.Context(permission.CtxTeam, s.team.Name)
+	s.newStreamEvent(c, event.TargetTypeNode, ctx)
+	evt1 := s.newStreamEvent(c, event.TargetTypeApp, ctx)
+	evt2 := s.newStreamEvent(c, event.TargetTypeApp, ctx)
+	reader := bufio.NewReader(rsp.Body)
+	c.Assert(s.readStreamEvent(c, reader).UniqueID, check.Equals, evt1.UniqueID)
+	c.Assert(s.readStreamEvent(c, reader).UniqueID, check.Equals, evt2.UniqueID)
+}
+
+func (s *EventSuite) TestEventStreamFilterByPool(c *check.C) {
+	oldInterval := eventStreamInterval
+	eventStreamInterval = 10 * time.Millisecond
+	defer func() { eventStreamInterval = oldInterval }()
+	srv := httptest.NewServer(RunServer(true))
+	defer srv.Close()
+	since := url.QueryEscape(time.Now().UTC().Add(-time.Second).Format(time.RFC3339Nano))
+	request, err := http.NewRequest("GET", srv.URL+"/events/stream?pool=pool1&team="+s.team.Name+"&since="+since, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rsp, err := http.DefaultClient.Do(request)
+	c.Assert(err, check.IsNil)
+	defer rsp.Body.Close()
+	c.Assert(rsp.StatusCode, check.Equals, http.StatusOK)
+	teamCtx := permission.Context(permission.CtxTeam, s.team.Name)
+	s.newStreamEvent(c, event.TargetTypeApp, teamCtx)
+	s.newStreamEvent(c, event.TargetTypeApp, teamCtx, permission.Context(permission.CtxPool, "pool2"))
+	evt := s.newStreamEvent(c, event.TargetTypeApp, teamCtx, permission.Context(permission.CtxPool, "pool1"))
+	reader := bufio.NewReader(rsp.Body)
+	c.Assert(s.readStreamEvent(c, reader).UniqueID, check.Equals, evt.UniqueID)
+}
+
+func (s *EventSuite) TestEventStreamWebSocket(c *check.C) {
+	oldInterval := eventStreamInterval
+	eventStreamInterval = 10 * time.Millisecond
+	defer func() { eventStreamInterval = oldInterval }()
+	srv := httptest.NewServer(RunServer(true))
+	defer srv.Close()
+	srvURL, err := url.Parse(srv.URL)
+	c.Assert(err, check.IsNil)
+	since := url.QueryEscape(time.Now().UTC().Add(-time.Second).Format(time.RFC3339Nano))
+	wsURL := fmt.Sprintf("ws://%s/events/stream?kindname=app.deploy&since=%s", srvURL.Host, since)
+	config, err := websocket.NewConfig(wsURL, "ws://localhost/")
+	c.Assert(err, check.IsNil)
+	config.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	wsConn, err := websocket.DialConfig(config)
+	c.Assert(err, check.IsNil)
+	defer wsConn.Close()
+	evt := s.newStreamEvent(c, event.TargetTypeApp, permission.Context(permission.CtxTeam, s.team.Name))
+	var result event.Event
+	err = websocket.JSON.Receive(wsConn, &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.UniqueID, check.Equals, evt.UniqueID)
+	c.Assert(result.Kind.Name, check.Equals, "app.deploy")
+}
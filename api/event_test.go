@@ -220,6 +220,46 @@ func (s *EventSuite) TestEventListFilterByKind(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
 }
 
+func (s *EventSuite) TestEventListFilterByCorrelation(c *check.C) {
+	evts, err := s.insertEvents("app", c)
+	c.Assert(err, check.IsNil)
+	child, err := event.New(&event.Opts{
+		Target:      event.Target{Type: event.TargetTypeNode, Value: "http://10.0.0.1"},
+		Kind:        permission.PermNodeCreate,
+		Owner:       s.token,
+		Allowed:     event.Allowed(permission.PermApp),
+		DisableLock: true,
+		Parent:      evts[0],
+	})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/events?correlation="+evts[0].UniqueID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []event.Event
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 2)
+	byID := map[bson.ObjectId]*event.Event{}
+	for i := range result {
+		byID[result[i].UniqueID] = &result[i]
+	}
+	c.Assert(byID[evts[0].UniqueID].CorrelationID, check.Equals, bson.ObjectId(""))
+	c.Assert(byID[child.UniqueID].ParentID, check.Equals, evts[0].UniqueID)
+	c.Assert(byID[child.UniqueID].CorrelationID, check.Equals, evts[0].UniqueID)
+	request, err = http.NewRequest("GET", "/events?correlation=invalid", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid correlation id: invalid\n")
+}
+
 func (s *EventSuite) TestKindList(c *check.C) {
 	_, err := s.insertEvents("app", c)
 	c.Assert(err, check.IsNil)
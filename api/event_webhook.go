@@ -0,0 +1,272 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: event webhook list
+// path: /events/webhooks
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func eventWebhookList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if len(permission.ContextsForPermission(t, permission.PermEventWebhookRead)) == 0 {
+		return permission.ErrUnauthorized
+	}
+	allWebhooks, err := event.ListWebhooks()
+	if err != nil {
+		return err
+	}
+	var webhooks []event.Webhook
+	for i := range allWebhooks {
+		if canAccessWebhook(t, permission.PermEventWebhookRead, &allWebhooks[i]) {
+			webhooks = append(webhooks, allWebhooks[i])
+		}
+	}
+	if len(webhooks) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(webhooks)
+}
+
+// title: event webhook set
+// path: /events/webhooks
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+func eventWebhookSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if len(permission.ContextsForPermission(t, permission.PermEventWebhookSet)) == 0 {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	webhook := event.Webhook{
+		Name:      r.FormValue("name"),
+		URL:       r.FormValue("url"),
+		Secret:    r.FormValue("secret"),
+		Filter:    event.WebhookFilter{Kinds: r.Form["kind"]},
+		TeamOwner: r.FormValue("team"),
+		Owner:     t.GetUserName(),
+	}
+	// Targets are given as type or type:value, like app:myapp.
+	for _, target := range r.Form["target"] {
+		parts := strings.SplitN(target, ":", 2)
+		filterTarget := event.Target{Type: event.TargetType(parts[0])}
+		if len(parts) == 2 {
+			filterTarget.Value = parts[1]
+		}
+		webhook.Filter.Targets = append(webhook.Filter.Targets, filterTarget)
+	}
+	if errorOnly := r.FormValue("errorOnly"); errorOnly != "" {
+		webhook.Filter.ErrorOnly, err = strconv.ParseBool(errorOnly)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "errorOnly must be a boolean"}
+		}
+	}
+	if webhook.TeamOwner == "" && !canAccessWebhook(t, permission.PermEventWebhookSet, &webhook) {
+		webhook.TeamOwner, err = permission.TeamForPermission(t, permission.PermEventWebhookSet)
+		if err == permission.ErrTooManyTeams {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "you must provide the team owning the webhook"}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !canAccessWebhook(t, permission.PermEventWebhookSet, &webhook) {
+		return permission.ErrUnauthorized
+	}
+	if webhook.TeamOwner != "" {
+		_, err = auth.GetTeam(webhook.TeamOwner)
+		if err == auth.ErrTeamNotFound {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	existing, err := event.GetWebhook(webhook.Name)
+	if err != nil && err != event.ErrWebhookNotFound {
+		return err
+	}
+	if existing != nil && !canAccessWebhook(t, permission.PermEventWebhookSet, existing) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeEventWebhook, Value: webhook.Name},
+		Kind:       permission.PermEventWebhookSet,
+		Owner:      t,
+		CustomData: event.FormToCustomData(webhookFormWithoutSecret(r.Form)),
+		Allowed:    event.Allowed(permission.PermEventWebhookReadEvents, webhookContexts(&webhook)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = event.SetWebhook(&webhook)
+	if err == event.ErrInvalidWebhook || err == event.ErrWebhookInternalAddress {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(webhook)
+}
+
+// webhookContexts returns the permission contexts of the webhook: the team
+// owning it or, for webhooks without team owner, the apps in its targets.
+func webhookContexts(w *event.Webhook) []permission.PermissionContext {
+	if w.TeamOwner != "" {
+		return []permission.PermissionContext{permission.Context(permission.CtxTeam, w.TeamOwner)}
+	}
+	var contexts []permission.PermissionContext
+	for _, target := range w.Filter.Targets {
+		if target.Type == event.TargetTypeApp && target.Value != "" {
+			contexts = append(contexts, permission.Context(permission.CtxApp, target.Value))
+		}
+	}
+	return contexts
+}
+
+// canAccessWebhook returns whether the token has the permission in the
+// context of the webhook. Webhooks owned by a team require the permission
+// in the team, webhooks without team owner require it in every app they
+// are restricted to, or globally when they are not restricted to apps.
+func canAccessWebhook(t auth.Token, scheme *permission.PermissionScheme, w *event.Webhook) bool {
+	if permission.Check(t, scheme) {
+		return true
+	}
+	if w.TeamOwner != "" {
+		return permission.Check(t, scheme, permission.Context(permission.CtxTeam, w.TeamOwner))
+	}
+	contexts := webhookContexts(w)
+	if len(contexts) == 0 || len(contexts) != len(w.Filter.Targets) {
+		return false
+	}
+	for _, ctx := range contexts {
+		if !permission.Check(t, scheme, ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+// webhookFormWithoutSecret returns a copy of the form with the secret
+// masked, so it's not stored in the event.
+func webhookFormWithoutSecret(form url.Values) url.Values {
+	masked := url.Values{}
+	for k, v := range form {
+		masked[k] = v
+	}
+	if _, ok := masked["secret"]; ok {
+		masked["secret"] = []string{"*****"}
+	}
+	return masked
+}
+
+// title: event webhook remove
+// path: /events/webhooks/{name}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func eventWebhookRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	name := r.URL.Query().Get(":name")
+	webhook, err := event.GetWebhook(name)
+	if err == event.ErrWebhookNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	if !canAccessWebhook(t, permission.PermEventWebhookRemove, webhook) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target: event.Target{Type: event.TargetTypeEventWebhook, Value: name},
+		Kind:   permission.PermEventWebhookRemove,
+		Owner:  t,
+		CustomData: []map[string]interface{}{
+			{"name": "name", "value": name},
+		},
+		Allowed: event.Allowed(permission.PermEventWebhookReadEvents, webhookContexts(webhook)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = event.RemoveWebhook(name)
+	if err == event.ErrWebhookNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: event webhook deliveries
+// path: /events/webhooks/{name}/deliveries
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func eventWebhookDeliveries(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	name := r.URL.Query().Get(":name")
+	webhook, err := event.GetWebhook(name)
+	if err == event.ErrWebhookNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	if !canAccessWebhook(t, permission.PermEventWebhookRead, webhook) {
+		return permission.ErrUnauthorized
+	}
+	var limit int
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "limit must be an integer"}
+		}
+	}
+	deliveries, err := event.ListWebhookDeliveries(name, limit)
+	if err == event.ErrWebhookNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(deliveries)
+}
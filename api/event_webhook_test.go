@@ -0,0 +1,271 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/permission/permissiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *EventSuite) TestEventWebhookList(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventWebhookRead,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	err := event.SetWebhook(&event.Webhook{
+		Name:   "chat",
+		URL:    "https://chat.example.com/hook",
+		Secret: "s3cr3t",
+		Filter: event.WebhookFilter{Kinds: []string{"app.deploy"}},
+	})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/events/webhooks", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var webhooks []event.Webhook
+	err = json.Unmarshal(recorder.Body.Bytes(), &webhooks)
+	c.Assert(err, check.IsNil)
+	c.Assert(webhooks, check.DeepEquals, []event.Webhook{{
+		Name:   "chat",
+		URL:    "https://chat.example.com/hook",
+		Filter: event.WebhookFilter{Kinds: []string{"app.deploy"}},
+	}})
+}
+
+func (s *EventSuite) TestEventWebhookListWithoutPermission(c *check.C) {
+	request, err := http.NewRequest("GET", "/events/webhooks", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *EventSuite) TestEventWebhookSet(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventWebhookSet,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	body := strings.NewReader("name=chat&url=https://chat.example.com/hook&secret=s3cr3t&kind=app.deploy&target=app:myapp&target=pool&errorOnly=true")
+	request, err := http.NewRequest("POST", "/events/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	expected := event.Webhook{
+		Name:   "chat",
+		URL:    "https://chat.example.com/hook",
+		Secret: "s3cr3t",
+		Filter: event.WebhookFilter{
+			Kinds: []string{"app.deploy"},
+			Targets: []event.Target{
+				{Type: event.TargetTypeApp, Value: "myapp"},
+				{Type: event.TargetTypePool},
+			},
+			ErrorOnly: true,
+		},
+		Owner: token.GetUserName(),
+	}
+	var webhook event.Webhook
+	err = json.Unmarshal(recorder.Body.Bytes(), &webhook)
+	c.Assert(err, check.IsNil)
+	c.Assert(webhook, check.DeepEquals, expected)
+	stored, err := event.GetWebhook("chat")
+	c.Assert(err, check.IsNil)
+	c.Assert(*stored, check.DeepEquals, expected)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeEventWebhook, Value: "chat"},
+		Owner:  token.GetUserName(),
+		Kind:   "event-webhook.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "chat"},
+			{"name": "url", "value": "https://chat.example.com/hook"},
+			{"name": "secret", "value": "*****"},
+			{"name": "kind", "value": "app.deploy"},
+			{"name": "target", "value": []string{"app:myapp", "pool"}},
+			{"name": "errorOnly", "value": "true"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *EventSuite) TestEventWebhookSetInvalid(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventWebhookSet,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	tests := []struct {
+		body string
+		msg  string
+	}{
+		{"name=chat&url=https://chat.example.com&errorOnly=maybe", "errorOnly must be a boolean"},
+		{"name=chat&url=chat.example.com", event.ErrInvalidWebhook.Error()},
+		{"url=https://chat.example.com", event.ErrInvalidWebhook.Error()},
+		{"name=chat&url=https://chat.example.com&target=:myapp", event.ErrInvalidWebhook.Error()},
+		{"name=chat&url=http://169.254.169.254/latest/meta-data/", event.ErrWebhookInternalAddress.Error()},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest("POST", "/events/webhooks", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+token.GetValue())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		server := RunServer(true)
+		server.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, tt.msg+"\n")
+	}
+	webhooks, err := event.ListWebhooks()
+	c.Assert(err, check.IsNil)
+	c.Assert(webhooks, check.HasLen, 0)
+}
+
+func (s *EventSuite) TestEventWebhookRemove(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventWebhookRemove,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	err := event.SetWebhook(&event.Webhook{Name: "chat", URL: "https://chat.example.com/hook"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/events/webhooks/chat", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = event.GetWebhook("chat")
+	c.Assert(err, check.Equals, event.ErrWebhookNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeEventWebhook, Value: "chat"},
+		Owner:  token.GetUserName(),
+		Kind:   "event-webhook.remove",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "chat"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *EventSuite) TestEventWebhookRemoveNotFound(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventWebhookRemove,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	request, err := http.NewRequest("DELETE", "/events/webhooks/unknown", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, event.ErrWebhookNotFound.Error()+"\n")
+}
+
+func (s *EventSuite) TestEventWebhookDeliveries(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventWebhookRead,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	err := event.SetWebhook(&event.Webhook{Name: "chat", URL: "https://chat.example.com/hook"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/events/webhooks/chat/deliveries?limit=10", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	request, err = http.NewRequest("GET", "/events/webhooks/unknown/deliveries", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	request, err = http.NewRequest("GET", "/events/webhooks/chat/deliveries?limit=many", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *EventSuite) TestEventWebhookSetTeamScoped(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventWebhook,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	body := strings.NewReader("name=chat&url=https://chat.example.com/hook&kind=app.deploy")
+	request, err := http.NewRequest("POST", "/events/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	stored, err := event.GetWebhook("chat")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(stored.Owner, check.Equals, token.GetUserName())
+	body = strings.NewReader("name=other&url=https://chat.example.com/hook&team=otherteam")
+	request, err = http.NewRequest("POST", "/events/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = event.GetWebhook("other")
+	c.Assert(err, check.Equals, event.ErrWebhookNotFound)
+}
+
+func (s *EventSuite) TestEventWebhookListTeamScoped(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventWebhookRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	err := event.SetWebhook(&event.Webhook{Name: "chat", URL: "https://chat.example.com/hook", TeamOwner: s.team.Name})
+	c.Assert(err, check.IsNil)
+	err = event.SetWebhook(&event.Webhook{Name: "other", URL: "https://other.example.com/hook", TeamOwner: "otherteam"})
+	c.Assert(err, check.IsNil)
+	err = event.SetWebhook(&event.Webhook{Name: "global", URL: "https://global.example.com/hook"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/events/webhooks", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var webhooks []event.Webhook
+	err = json.Unmarshal(recorder.Body.Bytes(), &webhooks)
+	c.Assert(err, check.IsNil)
+	c.Assert(webhooks, check.DeepEquals, []event.Webhook{
+		{Name: "chat", URL: "https://chat.example.com/hook", TeamOwner: s.team.Name},
+	})
+	request, err = http.NewRequest("GET", "/events/webhooks/other/deliveries", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+)
+
+// title: hardware profile create
+// path: /hardware-profiles
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Hardware profile created
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Hardware profile already exists
+func addHardwareProfile(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	profile := provision.HardwareProfile{
+		Name:          r.FormValue("name"),
+		CPUGeneration: r.FormValue("cpu-generation"),
+		MemoryClass:   r.FormValue("memory-class"),
+		DiskType:      r.FormValue("disk-type"),
+	}
+	allowed := permission.Check(t, permission.PermHardwareProfileCreate)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeHardwareProfile, Value: profile.Name},
+		Kind:       permission.PermHardwareProfileCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermHardwareProfileReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.AddHardwareProfile(profile)
+	if err == provision.ErrHardwareProfileNameRequired {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
+	if err == provision.ErrHardwareProfileAlreadyExists {
+		return &errors.HTTP{
+			Code:    http.StatusConflict,
+			Message: err.Error(),
+		}
+	}
+	if err == nil {
+		w.WriteHeader(http.StatusCreated)
+	}
+	return err
+}
+
+// title: hardware profile list
+// path: /hardware-profiles
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func listHardwareProfiles(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	profiles, err := provision.ListHardwareProfiles()
+	if err != nil {
+		return err
+	}
+	if len(profiles) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(profiles)
+}
+
+// title: hardware profile remove
+// path: /hardware-profiles/{name}
+// method: DELETE
+// responses:
+//   200: Hardware profile removed
+//   400: Hardware profile in use
+//   401: Unauthorized
+//   404: Hardware profile not found
+func removeHardwareProfile(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	allowed := permission.Check(t, permission.PermHardwareProfileDelete)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	name := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeHardwareProfile, Value: name},
+		Kind:       permission.PermHardwareProfileDelete,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermHardwareProfileReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.RemoveHardwareProfile(name)
+	if err == provision.ErrHardwareProfileNotFound {
+		return &errors.HTTP{
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		}
+	}
+	if err == provision.ErrHardwareProfileInUse {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
+	return err
+}
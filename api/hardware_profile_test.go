@@ -0,0 +1,123 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestHardwareProfileAdd(c *check.C) {
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("name=fast&cpu-generation=skylake&memory-class=ddr4&disk-type=ssd")
+	request, err := http.NewRequest("POST", "/hardware-profiles", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	profile, err := provision.GetHardwareProfile("fast")
+	c.Assert(err, check.IsNil)
+	c.Assert(*profile, check.DeepEquals, provision.HardwareProfile{
+		Name:          "fast",
+		CPUGeneration: "skylake",
+		MemoryClass:   "ddr4",
+		DiskType:      "ssd",
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeHardwareProfile, Value: "fast"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "hardware-profile.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "fast"},
+			{"name": "cpu-generation", "value": "skylake"},
+			{"name": "memory-class", "value": "ddr4"},
+			{"name": "disk-type", "value": "ssd"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestHardwareProfileAddAlreadyExists(c *check.C) {
+	err := provision.AddHardwareProfile(provision.HardwareProfile{Name: "fast"})
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/hardware-profiles", strings.NewReader("name=fast"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Equals, provision.ErrHardwareProfileAlreadyExists.Error()+"\n")
+}
+
+func (s *S) TestHardwareProfileList(c *check.C) {
+	err := provision.AddHardwareProfile(provision.HardwareProfile{Name: "fast", DiskType: "ssd"})
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/hardware-profiles", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var profiles []provision.HardwareProfile
+	err = json.NewDecoder(recorder.Body).Decode(&profiles)
+	c.Assert(err, check.IsNil)
+	c.Assert(profiles, check.DeepEquals, []provision.HardwareProfile{{Name: "fast", DiskType: "ssd"}})
+}
+
+func (s *S) TestHardwareProfileRemove(c *check.C) {
+	err := provision.AddHardwareProfile(provision.HardwareProfile{Name: "fast"})
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("DELETE", "/hardware-profiles/fast", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = provision.GetHardwareProfile("fast")
+	c.Assert(err, check.Equals, provision.ErrHardwareProfileNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeHardwareProfile, Value: "fast"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "hardware-profile.delete",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestHardwareProfileRemoveInUse(c *check.C) {
+	err := provision.AddHardwareProfile(provision.HardwareProfile{Name: "fast"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "fast-pool", HardwareProfile: "fast"})
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("DELETE", "/hardware-profiles/fast", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, provision.ErrHardwareProfileInUse.Error()+"\n")
+}
+
+func (s *S) TestHardwareProfileRemoveNotFound(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("DELETE", "/hardware-profiles/fast", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
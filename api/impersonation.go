@@ -0,0 +1,84 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: impersonate user
+// path: /users/{email}/impersonate
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Token created
+//   400: Invalid data
+//   401: Unauthorized
+//   404: User not found
+func impersonateUser(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	email := r.URL.Query().Get(":email")
+	allowed := permission.Check(t, permission.PermUserImpersonate,
+		permission.Context(permission.CtxUser, email),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	var duration time.Duration
+	if d := r.FormValue("duration"); d != "" {
+		duration, err = time.ParseDuration(d)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid duration: " + err.Error()}
+		}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     userTarget(email),
+		Kind:       permission.PermUserImpersonate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermUserReadEvents, permission.Context(permission.CtxUser, email)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	u, err := auth.GetUserByEmail(email)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	token, err := auth.CreateImpersonationToken(t, u, duration)
+	if err != nil {
+		switch err {
+		case auth.ErrImpersonateSelf, auth.ErrImpersonationChain, auth.ErrImpersonationDuration, auth.ErrImpersonationAppTokens:
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(token)
+}
+
+// title: revoke impersonation tokens
+// path: /users/impersonate
+// method: DELETE
+// responses:
+//   200: Tokens revoked
+//   401: Unauthorized
+func revokeImpersonation(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	actor := t.GetUserName()
+	if impersonated, ok := t.(auth.ImpersonatedToken); ok {
+		actor = impersonated.GetImpersonator()
+	}
+	return auth.RemoveImpersonationTokens(actor)
+}
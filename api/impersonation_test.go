@@ -0,0 +1,104 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *AuthSuite) TestImpersonateUser(c *check.C) {
+	u := auth.User{Email: "leto@arrakis.com", Password: "123456"}
+	_, err := nativeScheme.Create(&u)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/users/leto@arrakis.com/impersonate", strings.NewReader("duration=10m"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var token auth.ImpersonationToken
+	err = json.NewDecoder(recorder.Body).Decode(&token)
+	c.Assert(err, check.IsNil)
+	c.Assert(token.UserEmail, check.Equals, u.Email)
+	c.Assert(token.ActorEmail, check.Equals, s.user.Email)
+	c.Assert(token.Expires, check.Equals, 10*time.Minute)
+	c.Assert(eventtest.EventDesc{
+		Target: userTarget(u.Email),
+		Owner:  s.token.GetUserName(),
+		Kind:   "user.impersonate",
+		StartCustomData: []map[string]interface{}{
+			{"name": "duration", "value": "10m"},
+			{"name": ":email", "value": u.Email},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("POST", "/users/api-key", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.Token)
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	evts, err := event.List(&event.Filter{KindName: "user.update.token"})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Owner, check.DeepEquals, event.Owner{Type: event.OwnerTypeUser, Name: u.Email, Impersonator: s.user.Email})
+}
+
+func (s *AuthSuite) TestImpersonateUserInvalidDuration(c *check.C) {
+	u := auth.User{Email: "leto@arrakis.com", Password: "123456"}
+	_, err := nativeScheme.Create(&u)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/users/leto@arrakis.com/impersonate", strings.NewReader("duration=100h"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrImpersonationDuration.Error()+"\n")
+}
+
+func (s *AuthSuite) TestImpersonateUserUnauthorized(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermUserUpdateToken,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("POST", "/users/"+s.user.Email+"/impersonate", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *AuthSuite) TestRevokeImpersonation(c *check.C) {
+	u := auth.User{Email: "leto@arrakis.com", Password: "123456"}
+	_, err := nativeScheme.Create(&u)
+	c.Assert(err, check.IsNil)
+	token, err := auth.CreateImpersonationToken(s.token, &u, time.Minute)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/users/impersonate", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.Token)
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = auth.ImpersonationAuth("b " + token.Token)
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+}
@@ -8,8 +8,10 @@ import (
 	"html/template"
 	"net/http"
 	"path"
+	"strings"
 
 	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
 )
 
 // title: index
@@ -19,6 +21,9 @@ import (
 //   200: OK
 func index(w http.ResponseWriter, r *http.Request) error {
 	host, _ := config.GetString("host")
+	if prefix := context.GetForwardedPrefix(r); prefix != "" && !strings.HasSuffix(host, prefix) {
+		host = strings.TrimRight(host, "/") + prefix
+	}
 	userCreate, _ := config.GetBool("auth:user-registration")
 	scheme, _ := config.GetString("auth:scheme")
 	repoManager, _ := config.GetString("repo-manager")
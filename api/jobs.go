@@ -0,0 +1,117 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/jobs"
+	"github.com/tsuru/tsuru/permission"
+)
+
+func jobsError(err error) error {
+	switch err {
+	case jobs.ErrInvalidJobStatus:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case jobs.ErrJobNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case jobs.ErrJobNotFailed, jobs.ErrJobKeyInUse:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+// title: job list
+// path: /jobs
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+func listJobs(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermJobRead) {
+		return permission.ErrUnauthorized
+	}
+	filter := jobs.Filter{
+		Kind:   r.URL.Query().Get("kind"),
+		Status: jobs.Status(r.URL.Query().Get("status")),
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		filter.Limit, err = strconv.Atoi(v)
+		if err != nil || filter.Limit <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for limit: " + v}
+		}
+	}
+	list, err := jobs.List(filter)
+	if err != nil {
+		return jobsError(err)
+	}
+	if len(list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(list)
+}
+
+// title: job info
+// path: /jobs/{id}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Job not found
+func jobInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermJobRead) {
+		return permission.ErrUnauthorized
+	}
+	job, err := jobs.Get(r.URL.Query().Get(":id"))
+	if err != nil {
+		return jobsError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(job)
+}
+
+// title: job retry
+// path: /jobs/{id}/retry
+// method: POST
+// produce: application/json
+// responses:
+//   200: Job enqueued
+//   401: Unauthorized
+//   404: Job not found
+//   409: Job not failed or job with the same key already enqueued
+func retryJob(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermJobUpdateRetry) {
+		return permission.ErrUnauthorized
+	}
+	id := r.URL.Query().Get(":id")
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeJob, Value: id},
+		Kind:    permission.PermJobUpdateRetry,
+		Owner:   t,
+		Allowed: event.Allowed(permission.PermJobReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	job, err := jobs.Retry(id)
+	if err != nil {
+		return jobsError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/jobs"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestListJobs(c *check.C) {
+	job, err := jobs.Enqueue("cleanup", "", jobs.Params{"app": "myapp"})
+	c.Assert(err, check.IsNil)
+	_, err = jobs.Enqueue("other", "", nil)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/jobs?kind=cleanup&status=pending", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var list []jobs.Job
+	err = json.NewDecoder(recorder.Body).Decode(&list)
+	c.Assert(err, check.IsNil)
+	c.Assert(list, check.HasLen, 1)
+	c.Assert(list[0].ID, check.Equals, job.ID)
+	c.Assert(list[0].Params, check.DeepEquals, jobs.Params{"app": "myapp"})
+}
+
+func (s *S) TestListJobsInvalidStatus(c *check.C) {
+	request, err := http.NewRequest("GET", "/jobs?status=stuck", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, jobs.ErrInvalidJobStatus.Error()+"\n")
+}
+
+func (s *S) TestJobInfoNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/jobs/000000000000000000000000", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestRetryJobNotFailed(c *check.C) {
+	job, err := jobs.Enqueue("cleanup", "", nil)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/jobs/"+job.ID.Hex()+"/retry", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Equals, jobs.ErrJobNotFailed.Error()+"\n")
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: event.TargetTypeJob, Value: job.ID.Hex()},
+		Owner:        s.token.GetUserName(),
+		Kind:         "job.update.retry",
+		ErrorMatches: jobs.ErrJobNotFailed.Error(),
+	}, eventtest.HasEvent)
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+func maintenanceFromForm(r *http.Request) (app.Maintenance, error) {
+	m := app.Maintenance{
+		HTML:        r.FormValue("html"),
+		RedirectURL: r.FormValue("redirect-url"),
+	}
+	var err error
+	if enabled := r.FormValue("enabled"); enabled != "" {
+		m.Enabled, err = strconv.ParseBool(enabled)
+		if err != nil {
+			return m, &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid enabled: " + err.Error()}
+		}
+	}
+	if onSwap := r.FormValue("on-swap"); onSwap != "" {
+		m.OnSwap, err = strconv.ParseBool(onSwap)
+		if err != nil {
+			return m, &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid on-swap: " + err.Error()}
+		}
+	}
+	return m, nil
+}
+
+func maintenanceError(err error) error {
+	switch err {
+	case app.ErrInvalidMaintenance, app.ErrMaintenanceNotSupported:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: app maintenance set
+// path: /apps/{app}/maintenance
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appMaintenanceSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateMaintenance,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	m, err := maintenanceFromForm(r)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateMaintenance,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return maintenanceError(a.SetMaintenance(m))
+}
+
+// title: app maintenance remove
+// path: /apps/{app}/maintenance
+// method: DELETE
+// responses:
+//   200: Ok
+//   400: Router does not support maintenance pages
+//   401: Unauthorized
+//   404: App not found
+func appMaintenanceRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateMaintenance,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateMaintenance,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return maintenanceError(a.RemoveMaintenance())
+}
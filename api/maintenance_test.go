@@ -0,0 +1,107 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppMaintenanceSet(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("html=<h1>down</h1>&enabled=true&on-swap=true")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/maintenance", a.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.DeepEquals, app.Maintenance{
+		HTML:    "<h1>down</h1>",
+		Enabled: true,
+		OnSwap:  true,
+	})
+	page, ok := routertest.FakeRouter.MaintenancePage(a.Name)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(page, check.DeepEquals, router.MaintenancePage{HTML: "<h1>down</h1>"})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.maintenance",
+		StartCustomData: []map[string]interface{}{
+			{"name": "html", "value": "<h1>down</h1>"},
+			{"name": "enabled", "value": "true"},
+			{"name": "on-swap", "value": "true"},
+			{"name": ":app", "value": "leper"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppMaintenanceSetInvalid(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	var tests = []struct {
+		body    string
+		message string
+	}{
+		{"html=x&enabled=maybe", `invalid enabled: strconv.ParseBool: parsing "maybe": invalid syntax`},
+		{"enabled=true", app.ErrInvalidMaintenance.Error()},
+		{"redirect-url=status.example.com", app.ErrInvalidMaintenance.Error()},
+	}
+	for _, t := range tests {
+		request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/maintenance", a.Name), strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "b "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, t.message+"\n")
+	}
+}
+
+func (s *S) TestAppMaintenanceRemove(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetMaintenance(app.Maintenance{RedirectURL: "https://status.example.com", Enabled: true})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/apps/%s/maintenance", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.DeepEquals, app.Maintenance{})
+	_, ok := routertest.FakeRouter.MaintenancePage(a.Name)
+	c.Assert(ok, check.Equals, false)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.maintenance",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": "leper"},
+		},
+	}, eventtest.HasEvent)
+}
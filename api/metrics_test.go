@@ -0,0 +1,145 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppMetricsSet(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("Port=9100&Path=/stats&Process=web")
+	request, err := http.NewRequest("PUT", "/apps/leper/metrics", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Metrics, check.DeepEquals, provision.MetricsConfig{Port: 9100, Path: "/stats", Process: "web"})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.metrics",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Port", "value": "9100"},
+			{"name": "Path", "value": "/stats"},
+			{"name": "Process", "value": "web"},
+			{"name": ":app", "value": "leper"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppMetricsSetInvalid(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("Port=90000")
+	request, err := http.NewRequest("PUT", "/apps/leper/metrics", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, provision.ErrInvalidMetricsConfig.Error()+"\n")
+}
+
+func (s *S) TestAppMetricsSetForbidden(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateMetrics,
+		Context: permission.Context(permission.CtxApp, "other"),
+	})
+	body := strings.NewReader("Port=9100")
+	request, err := http.NewRequest("PUT", "/apps/leper/metrics", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestMetricsTargetsList(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetMetricsConfig(provision.MetricsConfig{Port: 9100})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	other := app.App{Name: "other", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&other, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&other, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	units := s.provisioner.GetUnits(&a)
+	request, err := http.NewRequest("GET", "/metrics-targets", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var targets []app.MetricsTarget
+	err = json.Unmarshal(recorder.Body.Bytes(), &targets)
+	c.Assert(err, check.IsNil)
+	c.Assert(targets, check.DeepEquals, []app.MetricsTarget{
+		{
+			Targets: []string{units[0].Ip + ":9100"},
+			Labels: map[string]string{
+				"__metrics_path__": "/metrics",
+				"app":              "leper",
+				"process":          "web",
+				"pool":             a.Pool,
+			},
+		},
+	})
+}
+
+func (s *S) TestMetricsTargetsListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/metrics-targets", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "[]\n")
+}
+
+func (s *S) TestMetricsTargetsListForbidden(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/metrics-targets", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
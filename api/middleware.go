@@ -7,10 +7,13 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	stdIo "io"
 	stdLog "log"
 	"net/http"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/codegangsta/negroni"
@@ -18,11 +21,13 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/apiusage"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/service"
 )
 
 const (
@@ -31,30 +36,72 @@ const (
 	tsuruAdminMin = "1.0.0"
 )
 
-func validate(token string, r *http.Request) (auth.Token, error) {
+func init() {
+	auth.RegisterTokenResolver("authorization", auth.TokenResolverFunc(authorizationResolver))
+}
+
+// authorizationResolver handles the tokens sent in the Authorization header:
+// tokens of the auth scheme, API tokens and impersonation tokens.
+func authorizationResolver(r *http.Request) (auth.Token, error) {
+	token := r.Header.Get("Authorization")
+	if token == "" {
+		return nil, nil
+	}
 	t, err := app.AuthScheme.Auth(token)
 	if err != nil {
 		t, err = auth.APIAuth(token)
+		if err != nil {
+			t, err = auth.ImpersonationAuth(token)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t, nil
+}
+
+// resolveToken returns the token of the first resolver in the chain handling
+// the credentials of the request. Invalid credentials are ignored, so the
+// request may still be authenticated by the next resolvers or go on
+// unauthenticated.
+func resolveToken(r *http.Request) (auth.Token, error) {
+	resolvers, err := auth.TokenResolverChain()
+	if err != nil {
+		return nil, err
+	}
+	for _, resolver := range resolvers {
+		t, err := resolver.ResolveToken(r)
+		if err == auth.ErrInvalidToken {
+			log.Debugf("Ignored invalid token for %s: %s", r.URL.Path, err.Error())
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
+		if t != nil {
+			return t, validate(t, r)
+		}
 	}
+	return nil, nil
+}
+
+func validate(t auth.Token, r *http.Request) error {
 	if t.IsAppToken() {
 		if q := r.URL.Query().Get(":app"); q != "" && t.GetAppName() != q {
-			return nil, &tsuruErrors.HTTP{
+			return &tsuruErrors.HTTP{
 				Code:    http.StatusForbidden,
 				Message: fmt.Sprintf("app token mismatch, token for %q, request for %q", t.GetAppName(), q),
 			}
 		}
 	} else {
 		if q := r.URL.Query().Get(":app"); q != "" {
-			_, err = getAppFromContext(q, r)
+			_, err := getAppFromContext(q, r)
 			if err != nil {
-				return nil, err
+				return err
 			}
 		}
 	}
-	return t, nil
+	return nil
 }
 
 func contextClearerMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
@@ -107,40 +154,243 @@ func errorHandlingMiddleware(w http.ResponseWriter, r *http.Request, next http.H
 		if e, ok := err.(*tsuruErrors.HTTP); ok {
 			code = e.Code
 		}
+		errCode := context.GetErrorCode(r, err)
+		if errCode == "" {
+			errCode = tsuruErrors.StatusErrorCode(code)
+		}
 		flushing, ok := w.(*io.FlushingWriter)
 		if ok && flushing.Wrote() {
-			if w.Header().Get("Content-Type") == "application/x-json-stream" {
+			switch w.Header().Get("Content-Type") {
+			case "application/x-json-stream":
 				data, marshalErr := json.Marshal(io.SimpleJsonMessage{Error: err.Error()})
 				if marshalErr == nil {
 					w.Write(append(data, "\n"...))
 				}
-			} else {
+			case io.FrameContentType:
+				data, marshalErr := json.Marshal(io.Frame{Type: io.FrameError, Message: err.Error()})
+				if marshalErr == nil {
+					w.Write(append(data, "\n"...))
+				}
+			default:
 				fmt.Fprintln(w, err)
 			}
 		} else {
-			http.Error(w, err.Error(), code)
+			writeError(w, r, code, errCode, err)
 		}
 		log.Errorf("failure running HTTP request %s %s (%d): %s", r.Method, r.URL.Path, code, err)
 	}
 }
 
+// newCodedError returns an HTTP error, recording in the request its
+// machine-readable code, which is more specific than the one derived from the
+// status.
+func newCodedError(r *http.Request, status int, errorCode, message string) error {
+	err := &tsuruErrors.HTTP{Code: status, Message: message}
+	context.SetErrorCode(r, err, errorCode)
+	return err
+}
+
+// errorEnvelope is the body of error responses for clients accepting JSON.
+type errorEnvelope struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeError writes the error along with its machine-readable code, which is
+// always sent in the X-Tsuru-Error-Code header. Clients accepting JSON get
+// the code and the message in an errorEnvelope, other clients get the plain
+// text message.
+func writeError(w http.ResponseWriter, r *http.Request, status int, errCode string, err error) {
+	w.Header().Set("X-Tsuru-Error-Code", errCode)
+	if !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorEnvelope{Code: errCode, Message: err.Error()})
+}
+
 func authTokenMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	token := r.Header.Get("Authorization")
-	if token != "" {
-		t, err := validate(token, r)
+	t, err := resolveToken(r)
+	if err != nil {
+		context.AddRequestError(r, err)
+		return
+	}
+	if t != nil {
+		context.SetAuthToken(r, t)
+	}
+	next(w, r)
+}
+
+// apiUsageMiddleware tracks the API calls of authenticated tokens, rejecting
+// calls on resources of teams that exceeded their API quota. Failures
+// tracking a call are logged and don't fail the request.
+func apiUsageMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	t := context.GetAuthToken(r)
+	if t == nil || !apiusage.Enabled() {
+		next(w, r)
+		return
+	}
+	owner := t.GetUserName()
+	if t.IsAppToken() {
+		owner = t.GetAppName()
+	}
+	team, err := apiUsageTeam(r, t)
+	if err == nil {
+		err = apiusage.Track(owner, team)
+	}
+	if quotaErr, ok := err.(*apiusage.QuotaExceededError); ok {
+		context.AddRequestError(r, newCodedError(r, http.StatusTooManyRequests, tsuruErrors.ErrorCodeQuotaExceeded, quotaErr.Error()))
+		return
+	}
+	if err != nil {
+		log.Errorf("[api-usage] unable to track call of %q: %s", owner, err)
+	}
+	next(w, r)
+}
+
+// apiUsageTeam returns the team charged for a call: the team owner of the app
+// or service instance accessed by the request, or of the app of app tokens.
+// Calls not accessing a resource of a team are charged to no team.
+func apiUsageTeam(r *http.Request, t auth.Token) (string, error) {
+	query := r.URL.Query()
+	appName := query.Get(":app")
+	if appName == "" {
+		appName = query.Get(":appname")
+	}
+	if appName == "" && t.IsAppToken() {
+		appName = t.GetAppName()
+	}
+	if appName != "" {
+		a, err := app.GetByName(appName)
+		if err == app.ErrAppNotFound {
+			return "", nil
+		}
 		if err != nil {
-			if err != auth.ErrInvalidToken {
-				context.AddRequestError(r, err)
-				return
+			return "", err
+		}
+		return a.TeamOwner, nil
+	}
+	serviceName, instanceName := query.Get(":service"), query.Get(":instance")
+	if serviceName != "" && instanceName != "" {
+		instance, err := service.GetServiceInstance(serviceName, instanceName)
+		if err == service.ErrServiceInstanceNotFound {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return instance.TeamOwner, nil
+	}
+	return "", nil
+}
+
+const defaultBodyLimitGroup = "default"
+
+var defaultBodyLimits = map[string]int64{
+	defaultBodyLimitGroup: 32 << 20,
+}
+
+// bodyLimitMiddleware limits the size of request bodies. Handlers are grouped
+// so uploads (e.g. deploys) may have a different limit than regular API
+// calls, the limit for each group is read from the
+// server:request-body-limit:<group> setting, in bytes. Handlers not present
+// in any group use the "default" group, a limit lower than or equal to zero
+// disables the check.
+type bodyLimitMiddleware struct {
+	groups map[string][]http.Handler
+}
+
+func (m *bodyLimitMiddleware) groupFor(r *http.Request) string {
+	currentHandler := context.GetDelayedHandler(r)
+	if currentHandler == nil {
+		return defaultBodyLimitGroup
+	}
+	currentHandlerPtr := reflect.ValueOf(currentHandler).Pointer()
+	for group, handlers := range m.groups {
+		for _, h := range handlers {
+			if reflect.ValueOf(h).Pointer() == currentHandlerPtr {
+				return group
 			}
-			log.Debugf("Ignored invalid token for %s: %s", r.URL.Path, err.Error())
-		} else {
-			context.SetAuthToken(r, t)
 		}
 	}
+	return defaultBodyLimitGroup
+}
+
+func bodyLimit(group string) int64 {
+	limit, err := config.GetInt("server:request-body-limit:" + group)
+	if err != nil {
+		return defaultBodyLimits[group]
+	}
+	return int64(limit)
+}
+
+func (m *bodyLimitMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.Body == nil {
+		next(w, r)
+		return
+	}
+	group := m.groupFor(r)
+	limit := bodyLimit(group)
+	if limit <= 0 {
+		next(w, r)
+		return
+	}
+	if r.ContentLength > limit {
+		context.AddRequestError(r, newBodyTooLargeError(group, limit))
+		return
+	}
+	r.Body = &limitedBody{ReadCloser: r.Body, remaining: limit}
 	next(w, r)
 }
 
+var errBodyTooLarge = errors.New("request body too large")
+
+// limitedBody reads at most remaining bytes from the request body, failing
+// with errBodyTooLarge and recording that the limit was exceeded when the
+// body is larger than that.
+type limitedBody struct {
+	stdIo.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		b.exceeded = true
+		return n, errBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func newBodyTooLargeError(group string, limit int64) *tsuruErrors.HTTP {
+	msg := fmt.Sprintf("request body too large: the limit for %s requests is %d bytes. ", group, limit)
+	if group == "deploy" {
+		msg += "Consider removing unneeded files from the deploy archive, or deploying using an archive URL or an image instead. "
+	}
+	msg += fmt.Sprintf("The limit can be changed by the tsuru administrator in the server:request-body-limit:%s setting.", group)
+	return &tsuruErrors.HTTP{Code: http.StatusRequestEntityTooLarge, Message: msg}
+}
+
+// isBodyTooLarge returns whether reading the body of the request failed
+// because it exceeded the limit set by bodyLimitMiddleware.
+func isBodyTooLarge(r *http.Request) bool {
+	body, ok := r.Body.(*limitedBody)
+	return ok && body.exceeded
+}
+
 type appLockMiddleware struct {
 	excludedHandlers []http.Handler
 }
@@ -181,7 +431,7 @@ func (m *appLockMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, ne
 	}
 	_, err := app.GetByName(appName)
 	if err == app.ErrAppNotFound {
-		context.AddRequestError(r, &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()})
+		context.AddRequestError(r, newCodedError(r, http.StatusNotFound, tsuruErrors.ErrorCodeAppNotFound, err.Error()))
 		return
 	}
 	ok, err := app.AcquireApplicationLockWait(appName, owner, fmt.Sprintf("%s %s", r.Method, r.URL.Path), lockWaitDuration)
@@ -201,18 +451,16 @@ func (m *appLockMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, ne
 	a, err := app.GetByName(appName)
 	if err != nil {
 		if err == app.ErrAppNotFound {
-			err = &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+			err = newCodedError(r, http.StatusNotFound, tsuruErrors.ErrorCodeAppNotFound, err.Error())
 		} else {
 			err = errors.Wrap(err, "Error to get application")
 		}
 	} else {
-		httpErr := &tsuruErrors.HTTP{Code: http.StatusConflict}
+		msg := "Not locked anymore, please try again."
 		if a.Lock.Locked {
-			httpErr.Message = fmt.Sprintf("%s", &a.Lock)
-		} else {
-			httpErr.Message = "Not locked anymore, please try again."
+			msg = fmt.Sprintf("%s", &a.Lock)
 		}
-		err = httpErr
+		err = newCodedError(r, http.StatusConflict, tsuruErrors.ErrorCodeAppLocked, msg)
 	}
 	context.AddRequestError(r, err)
 }
@@ -251,5 +499,88 @@ func (l *loggerMiddleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, ne
 			requestID = fmt.Sprintf(" [%s: %s]", requestIDHeader, requestID)
 		}
 	}
-	l.logger.Printf("%s %s %s %d in %0.6fms%s", nowFormatted, r.Method, r.URL.Path, statusCode, float64(duration)/float64(time.Millisecond), requestID)
+	var impersonation string
+	if t, ok := context.GetAuthToken(r).(auth.ImpersonatedToken); ok {
+		impersonation = fmt.Sprintf(" [impersonation: %s as %s]", t.GetImpersonator(), t.GetUserName())
+	}
+	l.logger.Printf("%s %s %s %d in %0.6fms%s%s", nowFormatted, r.Method, r.URL.Path, statusCode, float64(duration)/float64(time.Millisecond), requestID, impersonation)
+}
+
+// corsMiddleware allows browsers in the origins listed in cors:allowed-origins
+// to call the API, answering preflight requests before routing. Credentials
+// are only allowed for origins listed explicitly, never for origins matched
+// by "*", otherwise any site could make requests authenticated as the user.
+func corsMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		next(w, r)
+		return
+	}
+	origins, _ := config.GetList("cors:allowed-origins")
+	allowAll, listed := false, false
+	for _, o := range origins {
+		if o == "*" {
+			allowAll = true
+		} else if strings.EqualFold(o, origin) {
+			listed = true
+		}
+	}
+	if !allowAll && !listed {
+		next(w, r)
+		return
+	}
+	credentials, _ := config.GetBool("cors:allow-credentials")
+	credentials = credentials && listed
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	if listed {
+		header.Set("Access-Control-Allow-Origin", origin)
+	} else {
+		header.Set("Access-Control-Allow-Origin", "*")
+	}
+	if credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		if exposed, _ := config.GetList("cors:exposed-headers"); len(exposed) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+		}
+		next(w, r)
+		return
+	}
+	header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+	headers, err := config.GetList("cors:allowed-headers")
+	if err != nil {
+		headers = []string{"Authorization", "Content-Type", "Accept"}
+		if requestIDHeader, _ := config.GetString("request-id-header"); requestIDHeader != "" {
+			headers = append(headers, requestIDHeader)
+		}
+	}
+	header.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	if maxAge, _ := config.GetInt("cors:max-age"); maxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(maxAge))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// forwardedPrefixMiddleware honors the X-Forwarded-Prefix header sent by
+// gateways serving the API under a path prefix, when
+// server:trust-forwarded-prefix is enabled. The prefix is removed from the
+// request path, if the gateway didn't do it, and used in generated URLs.
+func forwardedPrefixMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	trust, _ := config.GetBool("server:trust-forwarded-prefix")
+	prefix := strings.TrimRight(r.Header.Get("X-Forwarded-Prefix"), "/")
+	if !trust || prefix == "" {
+		next(w, r)
+		return
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	if r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, prefix+"/") {
+		r.URL.Path = "/" + strings.TrimLeft(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		r.URL.RawPath = ""
+	}
+	context.SetForwardedPrefix(r, prefix)
+	next(w, r)
 }
@@ -7,6 +7,7 @@ package api
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -121,6 +122,109 @@ func (s *S) TestSetVersionHeadersMiddleware(c *check.C) {
 	c.Assert(recorder.Header().Get("Supported-Tsuru-Admin"), check.Equals, tsuruAdminMin)
 }
 
+func (s *S) TestCORSMiddleware(c *check.C) {
+	config.Set("cors:allowed-origins", []string{"https://dashboard.example.com"})
+	config.Set("cors:exposed-headers", []string{"Supported-Tsuru"})
+	defer config.Unset("cors")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Origin", "https://dashboard.example.com")
+	h, log := doHandler()
+	corsMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), check.Equals, "https://dashboard.example.com")
+	c.Assert(recorder.Header().Get("Access-Control-Expose-Headers"), check.Equals, "Supported-Tsuru")
+	c.Assert(recorder.Header().Get("Vary"), check.Equals, "Origin")
+}
+
+func (s *S) TestCORSMiddlewarePreflight(c *check.C) {
+	config.Set("cors:allowed-origins", []string{"*"})
+	config.Set("cors:max-age", 600)
+	defer config.Unset("cors")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("OPTIONS", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Origin", "https://dashboard.example.com")
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	h, log := doHandler()
+	corsMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), check.Equals, "*")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Methods"), check.Equals, "GET, POST, PUT, DELETE")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Headers"), check.Equals, "Authorization, Content-Type, Accept")
+	c.Assert(recorder.Header().Get("Access-Control-Max-Age"), check.Equals, "600")
+}
+
+func (s *S) TestCORSMiddlewareOriginNotAllowed(c *check.C) {
+	config.Set("cors:allowed-origins", []string{"https://dashboard.example.com"})
+	defer config.Unset("cors")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Origin", "https://evil.example.com")
+	h, log := doHandler()
+	corsMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), check.Equals, "")
+}
+
+func (s *S) TestCORSMiddlewareWithCredentials(c *check.C) {
+	config.Set("cors:allowed-origins", []string{"*", "https://dashboard.example.com"})
+	config.Set("cors:allow-credentials", true)
+	defer config.Unset("cors")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Origin", "https://dashboard.example.com")
+	h, _ := doHandler()
+	corsMiddleware(recorder, request, h)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), check.Equals, "https://dashboard.example.com")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Credentials"), check.Equals, "true")
+}
+
+func (s *S) TestCORSMiddlewareWithCredentialsWildcardOrigin(c *check.C) {
+	config.Set("cors:allowed-origins", []string{"*", "https://dashboard.example.com"})
+	config.Set("cors:allow-credentials", true)
+	defer config.Unset("cors")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Origin", "https://evil.example.com")
+	h, log := doHandler()
+	corsMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), check.Equals, "*")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Credentials"), check.Equals, "")
+}
+
+func (s *S) TestForwardedPrefixMiddleware(c *check.C) {
+	config.Set("server:trust-forwarded-prefix", true)
+	defer config.Unset("server:trust-forwarded-prefix")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/tsuru/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("X-Forwarded-Prefix", "/tsuru/")
+	h, log := doHandler()
+	forwardedPrefixMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(request.URL.Path, check.Equals, "/apps")
+	c.Assert(context.GetForwardedPrefix(request), check.Equals, "/tsuru")
+}
+
+func (s *S) TestForwardedPrefixMiddlewareNotTrusted(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/tsuru/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("X-Forwarded-Prefix", "/tsuru")
+	h, log := doHandler()
+	forwardedPrefixMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(request.URL.Path, check.Equals, "/tsuru/apps")
+	c.Assert(context.GetForwardedPrefix(request), check.Equals, "")
+}
+
 func (s *S) TestErrorHandlingMiddlewareWithoutError(c *check.C) {
 	recorder := httptest.NewRecorder()
 	request, err := http.NewRequest("GET", "/", nil)
@@ -153,6 +257,54 @@ func (s *S) TestErrorHandlingMiddlewareWithHTTPError(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, 403)
 }
 
+func (s *S) TestErrorHandlingMiddlewareWithErrorCode(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	h, _ := doHandler()
+	context.AddRequestError(request, newCodedError(request, 404, errors.ErrorCodePoolNotFound, "pool not found"))
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(recorder.Code, check.Equals, 404)
+	c.Assert(recorder.Header().Get("X-Tsuru-Error-Code"), check.Equals, "pool_not_found")
+	c.Assert(recorder.Body.String(), check.Equals, "pool not found\n")
+}
+
+func (s *S) TestErrorHandlingMiddlewareWithErrorEnvelope(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Accept", "application/json")
+	h, _ := doHandler()
+	context.AddRequestError(request, &errors.HTTP{Code: 403, Message: "other msg"})
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(recorder.Code, check.Equals, 403)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	c.Assert(recorder.Header().Get("X-Tsuru-Error-Code"), check.Equals, "forbidden")
+	c.Assert(recorder.Body.String(), check.Equals, `{"code":"forbidden","message":"other msg"}`+"\n")
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Accept", "application/json")
+	context.AddRequestError(request, fmt.Errorf("something"))
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(recorder.Code, check.Equals, 500)
+	c.Assert(recorder.Body.String(), check.Equals, `{"code":"internal_error","message":"something"}`+"\n")
+}
+
+func (s *S) TestErrorHandlingMiddlewareWithErrorAfterFrames(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", io.FrameContentType)
+		w.Write([]byte(`{"type":"message","message":"working"}` + "\n"))
+		context.AddRequestError(r, fmt.Errorf("something"))
+	}
+	errorHandlingMiddleware(&io.FlushingWriter{ResponseWriter: recorder}, request, h)
+	c.Assert(recorder.Code, check.Equals, 200)
+	c.Assert(recorder.Body.String(), check.Equals, `{"type":"message","message":"working"}`+"\n"+`{"type":"error","message":"something"}`+"\n")
+}
+
 func (s *S) TestAuthTokenMiddlewareWithoutToken(c *check.C) {
 	recorder := httptest.NewRecorder()
 	request, err := http.NewRequest("GET", "/", nil)
@@ -194,6 +346,48 @@ func (s *S) TestAuthTokenMiddlewareWithAPIToken(c *check.C) {
 	c.Assert(t.GetUserName(), check.Equals, user.Email)
 }
 
+func (s *S) TestAuthTokenMiddlewareWithResolverChain(c *check.C) {
+	var called []string
+	auth.RegisterTokenResolver("skip", auth.TokenResolverFunc(func(r *http.Request) (auth.Token, error) {
+		called = append(called, "skip")
+		return nil, nil
+	}))
+	auth.RegisterTokenResolver("invalid", auth.TokenResolverFunc(func(r *http.Request) (auth.Token, error) {
+		called = append(called, "invalid")
+		return nil, auth.ErrInvalidToken
+	}))
+	auth.RegisterTokenResolver("header", auth.TokenResolverFunc(func(r *http.Request) (auth.Token, error) {
+		called = append(called, "header")
+		return &auth.ExternalUserToken{UserEmail: r.Header.Get("X-User"), Scheme: "header"}, nil
+	}))
+	config.Set("auth:token-resolvers", []interface{}{"skip", "invalid", "header", "authorization"})
+	defer config.Unset("auth:token-resolvers")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("X-User", s.user.Email)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	h, log := doHandler()
+	authTokenMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(called, check.DeepEquals, []string{"skip", "invalid", "header"})
+	t := context.GetAuthToken(request)
+	c.Assert(t.GetUserName(), check.Equals, s.user.Email)
+	c.Assert(t.GetValue(), check.Equals, "")
+}
+
+func (s *S) TestAuthTokenMiddlewareUnknownResolver(c *check.C) {
+	config.Set("auth:token-resolvers", []interface{}{"kerberos"})
+	defer config.Unset("auth:token-resolvers")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	h, log := doHandler()
+	authTokenMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	c.Assert(context.GetRequestError(request), check.ErrorMatches, `unknown token resolver "kerberos"`)
+}
+
 func (s *S) TestAuthTokenMiddlewareWithAppToken(c *check.C) {
 	token, err := nativeScheme.AppLogin("abc")
 	c.Assert(err, check.IsNil)
@@ -439,6 +633,82 @@ func (s *S) TestAppLockMiddlewareDoesNothingForExcludedHandlers(c *check.C) {
 	c.Assert(log.called, check.Equals, true)
 }
 
+func (s *S) TestBodyLimitMiddlewareDefaultGroup(c *check.C) {
+	config.Set("server:request-body-limit:default", 10)
+	defer config.Unset("server:request-body-limit")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/", bytes.NewBufferString("this body is too large"))
+	c.Assert(err, check.IsNil)
+	h, log := doHandler()
+	m := &bodyLimitMiddleware{}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	httpErr, ok := context.GetRequestError(request).(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(httpErr.Code, check.Equals, http.StatusRequestEntityTooLarge)
+	c.Assert(httpErr.Message, check.Matches, `request body too large: the limit for default requests is 10 bytes\. .*server:request-body-limit:default.*`)
+}
+
+func (s *S) TestBodyLimitMiddlewareWithinLimit(c *check.C) {
+	config.Set("server:request-body-limit:default", 10)
+	defer config.Unset("server:request-body-limit")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/", bytes.NewBufferString("small"))
+	c.Assert(err, check.IsNil)
+	h, log := doHandler()
+	m := &bodyLimitMiddleware{}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(context.GetRequestError(request), check.IsNil)
+}
+
+func (s *S) TestBodyLimitMiddlewareHandlerGroup(c *check.C) {
+	config.Set("server:request-body-limit:default", 10)
+	config.Set("server:request-body-limit:deploy", 100)
+	defer config.Unset("server:request-body-limit")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/", bytes.NewBufferString("this body is larger than the default limit"))
+	c.Assert(err, check.IsNil)
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	context.SetDelayedHandler(request, finalHandler)
+	h, log := doHandler()
+	m := &bodyLimitMiddleware{groups: map[string][]http.Handler{
+		"deploy": {finalHandler},
+	}}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(context.GetRequestError(request), check.IsNil)
+}
+
+func (s *S) TestBodyLimitMiddlewareDisabled(c *check.C) {
+	config.Set("server:request-body-limit:default", 0)
+	defer config.Unset("server:request-body-limit")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/", bytes.NewBufferString("this body is not too large"))
+	c.Assert(err, check.IsNil)
+	h, log := doHandler()
+	m := &bodyLimitMiddleware{}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+}
+
+func (s *S) TestBodyLimitMiddlewareUnknownLength(c *check.C) {
+	config.Set("server:request-body-limit:default", 10)
+	defer config.Unset("server:request-body-limit")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/", bytes.NewBufferString("this body is too large"))
+	c.Assert(err, check.IsNil)
+	request.ContentLength = -1
+	h, log := doHandler()
+	m := &bodyLimitMiddleware{}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	data, err := ioutil.ReadAll(log.r.Body)
+	c.Assert(err, check.Equals, errBodyTooLarge)
+	c.Assert(string(data), check.Equals, "this body ")
+	c.Assert(isBodyTooLarge(log.r), check.Equals, true)
+}
+
 func (s *S) TestAppLockMiddlewareWaitForLock(c *check.C) {
 	myApp := app.App{
 		Name: "my-app",
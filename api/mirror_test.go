@@ -0,0 +1,106 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppMirrorSet(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	shadow := app.App{Name: "leper-next", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err = app.CreateApp(&shadow, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("app=leper-next&percentage=5")
+	request, err := http.NewRequest("PUT", "/apps/leper/mirror", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Mirror, check.DeepEquals, app.Mirror{App: "leper-next", Percentage: 5})
+	mirror, ok := routertest.FakeRouter.GetMirror(a.Name)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(mirror, check.DeepEquals, routertest.Mirror{Backend: "leper-next", Percentage: 5})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.mirror",
+		StartCustomData: []map[string]interface{}{
+			{"name": "app", "value": "leper-next"},
+			{"name": "percentage", "value": "5"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppMirrorSetInvalidPercentage(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	shadow := app.App{Name: "leper-next", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err = app.CreateApp(&shadow, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("app=leper-next&percentage=150")
+	request, err := http.NewRequest("PUT", "/apps/leper/mirror", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrInvalidMirror.Error()+"\n")
+}
+
+func (s *S) TestAppMirrorSetMirrorNotFound(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("app=unknown&percentage=5")
+	request, err := http.NewRequest("PUT", "/apps/leper/mirror", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppMirrorRemove(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	shadow := app.App{Name: "leper-next", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err = app.CreateApp(&shadow, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetMirror(&shadow, 5)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/leper/mirror", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Mirror, check.DeepEquals, app.Mirror{})
+	_, ok := routertest.FakeRouter.GetMirror(a.Name)
+	c.Assert(ok, check.Equals, false)
+}
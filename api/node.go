@@ -7,6 +7,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -21,7 +22,7 @@ import (
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/healer"
 	"github.com/tsuru/tsuru/iaas"
-	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
@@ -45,23 +46,30 @@ func validateNodeAddress(address string) error {
 	return nil
 }
 
-func addNodeForParams(p provision.NodeProvisioner, params provision.AddNodeOptions) (string, map[string]string, error) {
+func addNodeForParams(p provision.NodeProvisioner, params provision.AddNodeOptions, evt *event.Event, w io.Writer) (string, map[string]string, error) {
 	response := make(map[string]string)
 	var address string
+	var machine *iaas.Machine
 	if params.Register {
 		address = params.Metadata["address"]
 		delete(params.Metadata, "address")
 	} else {
 		desc, _ := iaas.Describe(params.Metadata["iaas"])
 		response["description"] = desc
-		m, err := iaas.CreateMachine(params.Metadata)
+		err := evt.Checkpoint("create machine")
 		if err != nil {
 			return address, response, err
 		}
-		address = m.FormatNodeAddress()
-		params.CaCert = m.CaCert
-		params.ClientCert = m.ClientCert
-		params.ClientKey = m.ClientKey
+		fmt.Fprintf(w, "---- Creating machine in IaaS %q ----\n", params.Metadata["iaas"])
+		machine, err = iaas.CreateMachine(params.Metadata)
+		if err != nil {
+			return address, response, err
+		}
+		address = machine.FormatNodeAddress()
+		fmt.Fprintf(w, " ---> Machine %q created with address %s\n", machine.Id, address)
+		params.CaCert = machine.CaCert
+		params.ClientCert = machine.ClientCert
+		params.ClientKey = machine.ClientKey
 	}
 	prov, _, err := provision.FindNode(address)
 	if err != provision.ErrNodeNotFound {
@@ -74,9 +82,25 @@ func addNodeForParams(p provision.NodeProvisioner, params provision.AddNodeOptio
 	if err != nil {
 		return address, response, err
 	}
+	err = evt.Checkpoint("add node")
+	if err != nil {
+		// The machine created for the canceled node is useless.
+		if machine != nil {
+			if destroyErr := machine.Destroy(); destroyErr != nil {
+				log.Errorf("unable to destroy machine %q of canceled node: %s", machine.Id, destroyErr)
+			}
+		}
+		return address, response, err
+	}
+	fmt.Fprintf(w, "---- Adding node %s ----\n", address)
 	params.Address = address
+	params.Writer = w
 	err = p.AddNode(params)
-	return address, response, err
+	if err != nil {
+		return address, response, err
+	}
+	fmt.Fprintf(w, " ---> Node %s added\n", address)
+	return address, response, nil
 }
 
 // title: add node
@@ -121,12 +145,14 @@ func addNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 		}
 	}
 	evt, err := event.New(&event.Opts{
-		Target:      event.Target{Type: event.TargetTypeNode},
-		Kind:        permission.PermNodeCreate,
-		Owner:       t,
-		CustomData:  event.FormToCustomData(r.Form),
-		DisableLock: true,
-		Allowed:     event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
+		Target:        event.Target{Type: event.TargetTypeNode},
+		Kind:          permission.PermNodeCreate,
+		Owner:         t,
+		CustomData:    event.FormToCustomData(r.Form),
+		DisableLock:   true,
+		Allowed:       event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
+		AllowedCancel: event.Allowed(permission.PermNodeCreate, permission.Context(permission.CtxPool, poolName)),
+		Cancelable:    true,
 	})
 	if err != nil {
 		return err
@@ -144,11 +170,10 @@ func addNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	if !ok {
 		return provision.ProvisionerNotSupported{Prov: prov, Action: "node operations"}
 	}
-	w.Header().Set("Content-Type", "application/x-json-stream")
+	writer := newJSONMessageStream(w, r, 15*time.Second)
+	defer writer.Close()
 	w.WriteHeader(http.StatusCreated)
-	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
-	defer keepAliveWriter.Stop()
-	addr, response, err := addNodeForParams(nodeProv, params)
+	addr, response, err := addNodeForParams(nodeProv, params, evt, writer)
 	evt.Target.Value = addr
 	if err != nil {
 		if desc := response["description"]; desc != "" {
@@ -230,6 +255,106 @@ func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	return nil
 }
 
+// title: evacuate node
+// path: /node/{address}/evacuate
+// method: POST
+// produce: application/x-json-stream
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: Not found
+func evacuateNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	address := r.URL.Query().Get(":address")
+	prov, node, err := provision.FindNode(address)
+	if err != nil {
+		if err == provision.ErrNodeNotFound {
+			return &tsuruErrors.HTTP{
+				Code:    http.StatusNotFound,
+				Message: err.Error(),
+			}
+		}
+		return err
+	}
+	pool := node.Pool()
+	if !permission.Check(t, permission.PermNodeUpdateEvacuate, permission.Context(permission.CtxPool, pool)) {
+		return permission.ErrUnauthorized
+	}
+	evacuationProv, ok := prov.(provision.NodeEvacuationProvisioner)
+	if !ok {
+		return provision.ProvisionerNotSupported{Prov: prov, Action: "node evacuation"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeNode, Value: node.Address()},
+		Kind:       permission.PermNodeUpdateEvacuate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, pool)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	writer := newJSONMessageStream(w, r, 15*time.Second)
+	defer writer.Close()
+	evt.SetLogWriter(writer)
+	return evacuationProv.EvacuateNode(node.Address(), evt)
+}
+
+type nodeDiagnosticsResponse struct {
+	Address string                      `json:"address"`
+	Pool    string                      `json:"pool"`
+	Healthy bool                        `json:"healthy"`
+	Checks  []provision.NodeCheckResult `json:"checks"`
+}
+
+// title: node diagnostics
+// path: /node/{address}/diagnostics
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: Not found
+func nodeDiagnosticsHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	address := r.URL.Query().Get(":address")
+	prov, node, err := provision.FindNode(address)
+	if err != nil {
+		if err == provision.ErrNodeNotFound {
+			return &tsuruErrors.HTTP{
+				Code:    http.StatusNotFound,
+				Message: err.Error(),
+			}
+		}
+		return err
+	}
+	pool := node.Pool()
+	if !permission.Check(t, permission.PermNodeReadDiagnostics, permission.Context(permission.CtxPool, pool)) {
+		return permission.ErrUnauthorized
+	}
+	diagnosticsProv, ok := prov.(provision.NodeDiagnosticsProvisioner)
+	if !ok {
+		return provision.ProvisionerNotSupported{Prov: prov, Action: "node diagnostics"}
+	}
+	checks, err := diagnosticsProv.DiagnoseNode(node.Address())
+	if err != nil {
+		return err
+	}
+	result := nodeDiagnosticsResponse{
+		Address: node.Address(),
+		Pool:    pool,
+		Healthy: true,
+		Checks:  checks,
+	}
+	for _, check := range checks {
+		if !check.Successful {
+			result.Healthy = false
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
 type listNodeResponse struct {
 	Nodes    []json.RawMessage `json:"nodes"`
 	Machines []iaas.Machine    `json:"machines"`
@@ -504,6 +629,66 @@ func nodeHealingRead(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	return json.NewEncoder(w).Encode(configMap)
 }
 
+// title: node healing risk
+// path: /healing/node/risk
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   204: No content
+//   401: Unauthorized
+func nodeHealingRisk(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	pools, err := permission.ListContextValues(t, permission.PermHealingRead, false)
+	if err != nil {
+		return err
+	}
+	nodes, err := nodesForHealing(pools)
+	if err != nil {
+		return err
+	}
+	risks, err := healer.NodesRisk(nodes)
+	if err != nil {
+		return err
+	}
+	if len(risks) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(risks)
+}
+
+func nodesForHealing(pools []string) ([]provision.Node, error) {
+	provs, err := provision.Registry()
+	if err != nil {
+		return nil, err
+	}
+	var result []provision.Node
+	for _, prov := range provs {
+		nodeProv, ok := prov.(provision.NodeProvisioner)
+		if !ok {
+			continue
+		}
+		nodes, err := nodeProv.ListNodes(nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			if pools == nil {
+				result = append(result, n)
+				continue
+			}
+			for _, pool := range pools {
+				if n.Pool() == pool {
+					result = append(result, n)
+					break
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
 // title: node healing update
 // path: /healing/node
 // method: POST
@@ -630,10 +815,8 @@ func rebalanceNodesHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 		return err
 	}
 	defer func() { evt.Done(err) }()
-	w.Header().Set("Content-Type", "application/x-json-stream")
-	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
-	defer keepAliveWriter.Stop()
-	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	writer := newJSONMessageStream(w, r, 15*time.Second)
+	defer writer.Close()
 	params.Writer = writer
 	var provs []provision.Provisioner
 	if poolName != "" {
@@ -13,6 +13,7 @@ import (
 	"net/http/httptest"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ajg/form"
 	"github.com/tsuru/tsuru/app"
@@ -60,6 +61,7 @@ func (s *S) TestAddNodeHandler(c *check.C) {
 	m.ServeHTTP(rec, req)
 	c.Assert(rec.Code, check.Equals, http.StatusCreated)
 	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(rec.Body.String(), check.Matches, `(?s).*Adding node http://mysrv1.*Node http://mysrv1 added.*`)
 	nodes, err := s.provisioner.ListNodes(nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(nodes, check.HasLen, 1)
@@ -140,7 +142,7 @@ func (s *S) TestAddNodeHandlerCreatingAnIaasMachine(c *check.C) {
 	m := RunServer(true)
 	m.ServeHTTP(rec, req)
 	c.Assert(rec.Code, check.Equals, http.StatusCreated)
-	c.Assert(rec.Body.String(), check.Equals, "")
+	c.Assert(rec.Body.String(), check.Matches, `(?s).*Creating machine in IaaS \\"test-iaas\\".*Machine \\"test1\\" created with address http://test1.somewhere.com:2375.*Adding node http://test1.somewhere.com:2375.*Node http://test1.somewhere.com:2375 added.*`)
 	nodes, err := s.provisioner.ListNodes(nil)
 	c.Assert(err, check.IsNil)
 	c.Assert(nodes, check.HasLen, 1)
@@ -296,6 +298,98 @@ func (s *S) TestRemoveNodeHandler(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestEvacuateNodeHandler(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "host.com:2375",
+		Metadata: map[string]string{"pool": "pool1"},
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/node/host.com:2375/evacuate", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(rec.Body.String(), check.Matches, `(?s).*evacuating host.com:2375.*`)
+	node, err := s.provisioner.GetNode("host.com:2375")
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Status(), check.Equals, "disabled")
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNode, Value: "host.com:2375"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.update.evacuate",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":address", "value": "host.com:2375"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestEvacuateNodeHandlerNotFound(c *check.C) {
+	req, err := http.NewRequest("POST", "/node/host.com:2375/evacuate", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestNodeDiagnosticsHandler(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "host.com:2375",
+		Metadata: map[string]string{"pool": "pool1"},
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("GET", "/node/host.com:2375/diagnostics", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result nodeDiagnosticsResponse
+	err = json.Unmarshal(rec.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, nodeDiagnosticsResponse{
+		Address: "host.com:2375",
+		Pool:    "pool1",
+		Healthy: true,
+		Checks:  []provision.NodeCheckResult{{Name: "fake", Successful: true}},
+	})
+}
+
+func (s *S) TestNodeDiagnosticsHandlerNotFound(c *check.C) {
+	req, err := http.NewRequest("GET", "/node/host.com:2375/diagnostics", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestNodeDiagnosticsHandlerWithoutPermission(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "host.com:2375",
+		Metadata: map[string]string{"pool": "pool1"},
+	})
+	c.Assert(err, check.IsNil)
+	t := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermNodeReadDiagnostics,
+		Context: permission.Context(permission.CtxPool, "pool2"),
+	})
+	req, err := http.NewRequest("GET", "/node/host.com:2375/diagnostics", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+t.GetValue())
+	rec := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestRemoveNodeHandlerNoRebalance(c *check.C) {
 	err := s.provisioner.AddNode(provision.AddNodeOptions{
 		Address: "host.com:2375",
@@ -814,27 +908,27 @@ func (s *S) TestNodeHealingUpdateRead(c *check.C) {
 		}},
 		{"pool=p1&Enabled=false", map[string]healer.NodeHealerConfig{
 			"":   {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxUnresponsiveTime: intPtr(20)},
-			"p1": {Enabled: boolPtr(false), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(20), MaxUnresponsiveTimeInherited: true},
+			"p1": {Enabled: boolPtr(false), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(20), MaxUnresponsiveTimeInherited: true, MaxRiskScoreInherited: true, CordonOnRiskInherited: true},
 		}},
 		{"pool=p1&Enabled=true", map[string]healer.NodeHealerConfig{
 			"":   {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxUnresponsiveTime: intPtr(20)},
-			"p1": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(20), MaxUnresponsiveTimeInherited: true},
+			"p1": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(20), MaxUnresponsiveTimeInherited: true, MaxRiskScoreInherited: true, CordonOnRiskInherited: true},
 		}},
 		{"pool=p1", map[string]healer.NodeHealerConfig{
 			"":   {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxUnresponsiveTime: intPtr(20)},
-			"p1": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(20), MaxUnresponsiveTimeInherited: true},
+			"p1": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(20), MaxUnresponsiveTimeInherited: true, MaxRiskScoreInherited: true, CordonOnRiskInherited: true},
 		}},
 		{"pool=p1&MaxUnresponsiveTime=30", map[string]healer.NodeHealerConfig{
 			"":   {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxUnresponsiveTime: intPtr(20)},
-			"p1": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(30), MaxUnresponsiveTimeInherited: false},
+			"p1": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(30), MaxUnresponsiveTimeInherited: false, MaxRiskScoreInherited: true, CordonOnRiskInherited: true},
 		}},
 		{"pool=p1&MaxUnresponsiveTime=0", map[string]healer.NodeHealerConfig{
 			"":   {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxUnresponsiveTime: intPtr(20)},
-			"p1": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(0), MaxUnresponsiveTimeInherited: false},
+			"p1": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(0), MaxUnresponsiveTimeInherited: false, MaxRiskScoreInherited: true, CordonOnRiskInherited: true},
 		}},
 		{"pool=p1&Enabled=false", map[string]healer.NodeHealerConfig{
 			"":   {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxUnresponsiveTime: intPtr(20)},
-			"p1": {Enabled: boolPtr(false), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(0), MaxUnresponsiveTimeInherited: false},
+			"p1": {Enabled: boolPtr(false), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(0), MaxUnresponsiveTimeInherited: false, MaxRiskScoreInherited: true, CordonOnRiskInherited: true},
 		}},
 	}
 	for i, t := range tests {
@@ -868,7 +962,7 @@ func (s *S) TestNodeHealingUpdateRead(c *check.C) {
 	configMap := doRequest("")
 	c.Assert(configMap, check.DeepEquals, map[string]healer.NodeHealerConfig{
 		"":   {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60), MaxUnresponsiveTime: intPtr(20)},
-		"p1": {Enabled: boolPtr(false), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(20), MaxUnresponsiveTimeInherited: true},
+		"p1": {Enabled: boolPtr(false), MaxTimeSinceSuccess: intPtr(60), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTime: intPtr(20), MaxUnresponsiveTimeInherited: true, MaxRiskScoreInherited: true, CordonOnRiskInherited: true},
 	})
 	request, err = http.NewRequest("DELETE", "/docker/healing/node", nil)
 	c.Assert(err, check.IsNil)
@@ -879,7 +973,7 @@ func (s *S) TestNodeHealingUpdateRead(c *check.C) {
 	configMap = doRequest("")
 	c.Assert(configMap, check.DeepEquals, map[string]healer.NodeHealerConfig{
 		"":   {},
-		"p1": {Enabled: boolPtr(false), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTimeInherited: true},
+		"p1": {Enabled: boolPtr(false), MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTimeInherited: true, MaxRiskScoreInherited: true, CordonOnRiskInherited: true},
 	})
 	request, err = http.NewRequest("DELETE", "/docker/healing/node?pool=p1&name=Enabled", nil)
 	c.Assert(err, check.IsNil)
@@ -890,7 +984,7 @@ func (s *S) TestNodeHealingUpdateRead(c *check.C) {
 	configMap = doRequest("")
 	c.Assert(configMap, check.DeepEquals, map[string]healer.NodeHealerConfig{
 		"":   {},
-		"p1": {EnabledInherited: true, MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTimeInherited: true},
+		"p1": {EnabledInherited: true, MaxTimeSinceSuccessInherited: true, MaxUnresponsiveTimeInherited: true, MaxRiskScoreInherited: true, CordonOnRiskInherited: true},
 	})
 }
 
@@ -937,8 +1031,61 @@ func (s *S) TestNodeHealingConfigUpdateReadLimited(c *check.C) {
 	data = doRequest(t, http.StatusOK, "pool=p2&Enabled=true&MaxTimeSinceSuccess=20")
 	c.Assert(data, check.DeepEquals, map[string]healer.NodeHealerConfig{
 		"":   {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(60)},
-		"p2": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(20), MaxUnresponsiveTimeInherited: true},
+		"p2": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(20), MaxUnresponsiveTimeInherited: true, MaxRiskScoreInherited: true, CordonOnRiskInherited: true},
+	})
+}
+
+func (s *S) TestNodeHealingRisk(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "n1:1",
+		Metadata: map[string]string{"pool": "p1"},
+	})
+	c.Assert(err, check.IsNil)
+	node, err := s.provisioner.GetNode("n1:1")
+	c.Assert(err, check.IsNil)
+	err = (&healer.NodeHealer{}).UpdateNodeData(node, []provision.NodeCheckResult{
+		{Name: "ok1", Successful: true, Duration: time.Millisecond},
 	})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.4/healing/node/risk", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var risks []healer.NodeRisk
+	err = json.Unmarshal(recorder.Body.Bytes(), &risks)
+	c.Assert(err, check.IsNil)
+	c.Assert(risks, check.DeepEquals, []healer.NodeRisk{
+		{Address: "n1:1", Pool: "p1", Checks: 1},
+	})
+}
+
+func (s *S) TestNodeHealingRiskFilteredByPool(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "n1:1",
+		Metadata: map[string]string{"pool": "p1"},
+	})
+	c.Assert(err, check.IsNil)
+	node, err := s.provisioner.GetNode("n1:1")
+	c.Assert(err, check.IsNil)
+	err = (&healer.NodeHealer{}).UpdateNodeData(node, []provision.NodeCheckResult{
+		{Name: "ok1", Successful: true},
+	})
+	c.Assert(err, check.IsNil)
+	t := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermHealingRead,
+		Context: permission.Context(permission.CtxPool, "p2"),
+	})
+	request, err := http.NewRequest("GET", "/1.4/healing/node/risk", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+t.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
 }
 
 func boolPtr(b bool) *bool {
@@ -0,0 +1,299 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/quota"
+)
+
+func organizationTarget(name string) event.Target {
+	return event.Target{Type: event.TargetTypeOrganization, Value: name}
+}
+
+func organizationError(r *http.Request, err error) error {
+	switch err {
+	case auth.ErrInvalidOrganizationName, auth.ErrOrganizationQuotaBelowInUse:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case auth.ErrOrganizationNotFound, auth.ErrOrganizationMemberNotFound, auth.ErrTeamNotFound, provision.ErrPoolNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case auth.ErrOrganizationAlreadyExists, auth.ErrTeamInAnotherOrganization, auth.ErrPoolInAnotherOrganization:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case auth.ErrOrganizationStillUsed:
+		return &errors.HTTP{Code: http.StatusForbidden, Message: err.Error()}
+	}
+	if _, ok := err.(*quota.QuotaExceededError); ok {
+		return newCodedError(r, http.StatusForbidden, errors.ErrorCodeQuotaExceeded, err.Error())
+	}
+	return err
+}
+
+func organizationEventOpts(name string, kind *permission.PermissionScheme, t auth.Token, r *http.Request) *event.Opts {
+	return &event.Opts{
+		Target:     organizationTarget(name),
+		Kind:       kind,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermOrganizationReadEvents, permission.Context(permission.CtxOrganization, name)),
+	}
+}
+
+// title: organization create
+// path: /organizations
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Organization created
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Organization already exists
+func organizationCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermOrganizationCreate) {
+		return permission.ErrUnauthorized
+	}
+	name := r.FormValue("name")
+	if name == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: auth.ErrInvalidOrganizationName.Error()}
+	}
+	evt, err := event.New(organizationEventOpts(name, permission.PermOrganizationCreate, t, r))
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	err = auth.CreateOrganization(name, u)
+	if err != nil {
+		return organizationError(r, err)
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// title: organization list
+// path: /organizations
+// method: GET
+// produce: application/json
+// responses:
+//   200: List organizations
+//   204: No content
+//   401: Unauthorized
+func organizationList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	names, err := permission.ListContextValues(t, permission.PermOrganizationRead, true)
+	if err != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	orgs, err := auth.ListOrganizations(names)
+	if err != nil {
+		return err
+	}
+	if len(orgs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(orgs)
+}
+
+// title: organization info
+// path: /organizations/{name}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func organizationInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermOrganizationRead, permission.Context(permission.CtxOrganization, name)) {
+		return permission.ErrUnauthorized
+	}
+	org, err := auth.GetOrganization(name)
+	if err != nil {
+		return organizationError(r, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(org)
+}
+
+// title: organization delete
+// path: /organizations/{name}
+// method: DELETE
+// responses:
+//   200: Organization removed
+//   401: Unauthorized
+//   403: Organization still has teams
+//   404: Not found
+func organizationDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	if !permission.Check(t, permission.PermOrganizationDelete, permission.Context(permission.CtxOrganization, name)) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(organizationEventOpts(name, permission.PermOrganizationDelete, t, r))
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return organizationError(r, auth.RemoveOrganization(name))
+}
+
+// title: organization add team
+// path: /organizations/{name}/teams
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Team added
+//   401: Unauthorized
+//   403: Quota exceeded
+//   404: Not found
+//   409: Team already belongs to an organization
+func organizationAddTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	name := r.URL.Query().Get(":name")
+	team := r.FormValue("team")
+	if team == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "you must provide a team"}
+	}
+	if !permission.Check(t, permission.PermOrganizationUpdateTeamAdd) {
+		return permission.ErrUnauthorized
+	}
+	org, err := auth.GetOrganization(name)
+	if err != nil {
+		return organizationError(r, err)
+	}
+	evt, err := event.New(organizationEventOpts(name, permission.PermOrganizationUpdateTeamAdd, t, r))
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return organizationError(r, org.AddTeam(team))
+}
+
+// title: organization remove team
+// path: /organizations/{name}/teams/{team}
+// method: DELETE
+// responses:
+//   200: Team removed
+//   401: Unauthorized
+//   404: Not found
+func organizationRemoveTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	team := r.URL.Query().Get(":team")
+	if !permission.Check(t, permission.PermOrganizationUpdateTeamRemove) {
+		return permission.ErrUnauthorized
+	}
+	org, err := auth.GetOrganization(name)
+	if err != nil {
+		return organizationError(r, err)
+	}
+	evt, err := event.New(organizationEventOpts(name, permission.PermOrganizationUpdateTeamRemove, t, r))
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return organizationError(r, org.RemoveTeam(team))
+}
+
+// title: organization add pool
+// path: /organizations/{name}/pools
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Pool added
+//   401: Unauthorized
+//   404: Not found
+//   409: Pool already belongs to an organization
+func organizationAddPool(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	name := r.URL.Query().Get(":name")
+	pool := r.FormValue("pool")
+	if pool == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "you must provide a pool"}
+	}
+	if !permission.Check(t, permission.PermOrganizationUpdatePoolAdd) {
+		return permission.ErrUnauthorized
+	}
+	org, err := auth.GetOrganization(name)
+	if err != nil {
+		return organizationError(r, err)
+	}
+	_, err = provision.GetPoolByName(pool)
+	if err != nil {
+		return organizationError(r, err)
+	}
+	evt, err := event.New(organizationEventOpts(name, permission.PermOrganizationUpdatePoolAdd, t, r))
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return organizationError(r, org.AddPool(pool))
+}
+
+// title: organization remove pool
+// path: /organizations/{name}/pools/{pool}
+// method: DELETE
+// responses:
+//   200: Pool removed
+//   401: Unauthorized
+//   404: Not found
+func organizationRemovePool(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	name := r.URL.Query().Get(":name")
+	pool := r.URL.Query().Get(":pool")
+	if !permission.Check(t, permission.PermOrganizationUpdatePoolRemove) {
+		return permission.ErrUnauthorized
+	}
+	org, err := auth.GetOrganization(name)
+	if err != nil {
+		return organizationError(r, err)
+	}
+	evt, err := event.New(organizationEventOpts(name, permission.PermOrganizationUpdatePoolRemove, t, r))
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return organizationError(r, org.RemovePool(pool))
+}
+
+// title: organization change quota
+// path: /organizations/{name}/quota
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Quota updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func organizationChangeQuota(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	name := r.URL.Query().Get(":name")
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid limit"}
+	}
+	if !permission.Check(t, permission.PermOrganizationUpdateQuota) {
+		return permission.ErrUnauthorized
+	}
+	org, err := auth.GetOrganization(name)
+	if err != nil {
+		return organizationError(r, err)
+	}
+	evt, err := event.New(organizationEventOpts(name, permission.PermOrganizationUpdateQuota, t, r))
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return organizationError(r, org.ChangeQuota(limit))
+}
@@ -0,0 +1,189 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestOrganizationCreate(c *check.C) {
+	body := strings.NewReader("name=acme")
+	request, err := http.NewRequest("POST", "/organizations", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	org, err := auth.GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.CreatingUser, check.Equals, s.user.Email)
+	c.Assert(eventtest.EventDesc{
+		Target: organizationTarget("acme"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "organization.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "acme"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestOrganizationCreateAlreadyExists(c *check.C) {
+	err := auth.CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("name=acme")
+	request, err := http.NewRequest("POST", "/organizations", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrOrganizationAlreadyExists.Error()+"\n")
+}
+
+func (s *S) TestOrganizationCreateUnauthorized(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermOrganizationRead,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	body := strings.NewReader("name=acme")
+	request, err := http.NewRequest("POST", "/organizations", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestOrganizationList(c *check.C) {
+	for _, name := range []string{"acme", "globex"} {
+		err := auth.CreateOrganization(name, s.user)
+		c.Assert(err, check.IsNil)
+	}
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermOrganizationRead,
+		Context: permission.Context(permission.CtxOrganization, "globex"),
+	})
+	request, err := http.NewRequest("GET", "/organizations", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var orgs []auth.Organization
+	err = json.NewDecoder(recorder.Body).Decode(&orgs)
+	c.Assert(err, check.IsNil)
+	c.Assert(orgs, check.HasLen, 1)
+	c.Assert(orgs[0].Name, check.Equals, "globex")
+}
+
+func (s *S) TestOrganizationAddTeamAndPool(c *check.C) {
+	err := auth.CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	body := strings.NewReader("team=" + s.team.Name)
+	request, err := http.NewRequest("POST", "/organizations/acme/teams", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	body = strings.NewReader("pool=" + s.Pool)
+	request, err = http.NewRequest("POST", "/organizations/acme/pools", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	org, err := auth.GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Teams, check.DeepEquals, []string{s.team.Name})
+	c.Assert(org.Pools, check.DeepEquals, []string{s.Pool})
+	c.Assert(eventtest.EventDesc{
+		Target: organizationTarget("acme"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "organization.update.pool.add",
+		StartCustomData: []map[string]interface{}{
+			{"name": "pool", "value": s.Pool},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestOrganizationAddPoolNotFound(c *check.C) {
+	err := auth.CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("pool=unknown")
+	request, err := http.NewRequest("POST", "/organizations/acme/pools", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestOrganizationDeleteWithTeams(c *check.C) {
+	err := auth.CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	org, err := auth.GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	err = org.AddTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/organizations/acme", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrOrganizationStillUsed.Error()+"\n")
+}
+
+func (s *S) TestOrganizationChangeQuota(c *check.C) {
+	err := auth.CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("limit=10")
+	request, err := http.NewRequest("PUT", "/organizations/acme/quota", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	org, err := auth.GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Quota.Limit, check.Equals, 10)
+}
+
+func (s *S) TestOrganizationChangeQuotaInvalidLimit(c *check.C) {
+	body := strings.NewReader("limit=lots")
+	request, err := http.NewRequest("PUT", "/organizations/acme/quota", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "Invalid limit\n")
+}
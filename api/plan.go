@@ -14,6 +14,7 @@ import (
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
 )
 
 // title: plan create
@@ -30,13 +31,17 @@ func addPlan(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	isDefault, _ := strconv.ParseBool(r.FormValue("default"))
 	memory := getSize(r.FormValue("memory"))
 	swap := getSize(r.FormValue("swap"))
+	storage := getSize(r.FormValue("ephemeral-storage"))
 	plan := app.Plan{
-		Name:     r.FormValue("name"),
-		Memory:   memory,
-		Swap:     swap,
-		CpuShare: cpuShare,
-		Default:  isDefault,
+		Name:             r.FormValue("name"),
+		Memory:           memory,
+		Swap:             swap,
+		CpuShare:         cpuShare,
+		EphemeralStorage: storage,
+		Default:          isDefault,
+		HardwareProfile:  r.FormValue("hardware-profile"),
 	}
+	plan.ToleratePreemption, _ = strconv.ParseBool(r.FormValue("tolerate-preemption"))
 	allowed := permission.Check(t, permission.PermPlanCreate)
 	if !allowed {
 		return permission.ErrUnauthorized
@@ -65,7 +70,8 @@ func addPlan(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 			Message: err.Error(),
 		}
 	}
-	if err == app.ErrLimitOfMemory || err == app.ErrLimitOfCpuShare {
+	if err == app.ErrLimitOfMemory || err == app.ErrLimitOfCpuShare || err == app.ErrLimitOfStorage ||
+		err == provision.ErrHardwareProfileNotFound {
 		return &errors.HTTP{
 			Code:    http.StatusBadRequest,
 			Message: err.Error(),
@@ -134,6 +140,9 @@ func removePlan(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 
 func getSize(formValue string) int64 {
 	const OneKbInBytes = 1024
+	if formValue == "" {
+		return 0
+	}
 	value, err := strconv.ParseInt(formValue, 10, 64)
 	if err != nil {
 		unit := formValue[len(formValue)-1:]
@@ -13,6 +13,7 @@ import (
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
 	_ "github.com/tsuru/tsuru/router/routertest"
 	"gopkg.in/check.v1"
 )
@@ -66,6 +67,25 @@ func (s *S) TestPlanAddWithMegabyteAsMemoryUnit(c *check.C) {
 	})
 }
 
+func (s *S) TestPlanAddWithEphemeralStorage(c *check.C) {
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("name=xyz&memory=512M&cpushare=100&ephemeral-storage=1G")
+	request, err := http.NewRequest("POST", "/plans", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	defer s.conn.Plans().RemoveAll(nil)
+	var plans []app.Plan
+	err = s.conn.Plans().Find(nil).All(&plans)
+	c.Assert(err, check.IsNil)
+	c.Assert(plans, check.DeepEquals, []app.Plan{
+		{Name: "xyz", Memory: 536870912, CpuShare: 100, EphemeralStorage: 1073741824},
+	})
+}
+
 func (s *S) TestPlanAddWithMegabyteAsSwapUnit(c *check.C) {
 	recorder := httptest.NewRecorder()
 	body := strings.NewReader("name=xyz&memory=512M&swap=1024&cpushare=100")
@@ -248,3 +268,37 @@ func (s *S) TestPlanRemoveInvalid(c *check.C) {
 	m.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
 }
+
+func (s *S) TestPlanAddWithHardwareProfile(c *check.C) {
+	err := provision.AddHardwareProfile(provision.HardwareProfile{Name: "fast"})
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("name=xyz&memory=512M&cpushare=100&hardware-profile=fast")
+	request, err := http.NewRequest("POST", "/plans", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	defer s.conn.Plans().RemoveAll(nil)
+	var plans []app.Plan
+	err = s.conn.Plans().Find(nil).All(&plans)
+	c.Assert(err, check.IsNil)
+	c.Assert(plans, check.DeepEquals, []app.Plan{
+		{Name: "xyz", Memory: 536870912, CpuShare: 100, HardwareProfile: "fast"},
+	})
+}
+
+func (s *S) TestPlanAddWithUnknownHardwareProfile(c *check.C) {
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("name=xyz&memory=512M&cpushare=100&hardware-profile=fast")
+	request, err := http.NewRequest("POST", "/plans", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, provision.ErrHardwareProfileNotFound.Error()+"\n")
+}
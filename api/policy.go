@@ -0,0 +1,35 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/policy"
+)
+
+// checkPolicy asks the configured policy engine whether the operation
+// described by kind, target and data may be executed by the token owner.
+func checkPolicy(t auth.Token, kind *permission.PermissionScheme, target event.Target, data interface{}) error {
+	user := t.GetUserName()
+	if t.IsAppToken() {
+		user = t.GetAppName()
+	}
+	err := policy.Check(&policy.Input{
+		Action:      kind.FullName(),
+		User:        user,
+		TargetType:  string(target.Type),
+		TargetValue: target.Value,
+		Data:        data,
+	})
+	if e, ok := err.(*policy.ErrDenied); ok {
+		return &errors.HTTP{Code: http.StatusForbidden, Message: e.Error()}
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/policy"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+type fakePolicyEngine struct {
+	inputs []*policy.Input
+}
+
+func (e *fakePolicyEngine) Evaluate(input *policy.Input) (*policy.Decision, error) {
+	e.inputs = append(e.inputs, input)
+	return &policy.Decision{Allowed: false, Reasons: []string{"frozen"}}, nil
+}
+
+func (s *S) TestPoolUpdateDeniedByPolicy(c *check.C) {
+	engine := &fakePolicyEngine{}
+	policy.Register("fake-deny", engine)
+	config.Set("policy:engine", "fake-deny")
+	defer config.Unset("policy:engine")
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	b := bytes.NewBufferString("provisioner=myprov")
+	req, err := http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+	c.Assert(rec.Body.String(), check.Equals, "pool.update denied by policy: frozen\n")
+	c.Assert(engine.inputs, check.HasLen, 1)
+	c.Assert(engine.inputs[0].Action, check.Equals, "pool.update")
+	c.Assert(engine.inputs[0].User, check.Equals, s.token.GetUserName())
+	c.Assert(engine.inputs[0].TargetValue, check.Equals, "pool1")
+	p, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Provisioner, check.Equals, "")
+}
+
+func (s *S) TestDeployRollbackDeniedByPolicy(c *check.C) {
+	engine := &fakePolicyEngine{}
+	policy.Register("fake-deny", engine)
+	config.Set("policy:engine", "fake-deny")
+	defer config.Unset("policy:engine")
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("origin", "rollback")
+	v.Set("image", "my-image-123:v1")
+	req, err := http.NewRequest("POST", "/apps/otherapp/deploy/rollback", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+	c.Assert(engine.inputs, check.HasLen, 1)
+	c.Assert(engine.inputs[0].Action, check.Equals, "app.deploy")
+	c.Assert(engine.inputs[0].TargetValue, check.Equals, "otherapp")
+	data := engine.inputs[0].Data.(map[string]interface{})
+	c.Assert(data["image"], check.Equals, "my-image-123:v1")
+	c.Assert(data["kind"], check.Equals, app.DeployRollback)
+}
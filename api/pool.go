@@ -6,15 +6,22 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	terrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/naming"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/cluster"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // title: pool list
@@ -24,9 +31,14 @@ import (
 // responses:
 //   200: OK
 //   204: No content
+//   400: Invalid data
 //   401: Unauthorized
 //   404: User not found
 func poolList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	filter, offset, limit, err := poolListParams(r)
+	if err != nil {
+		return err
+	}
 	teams := []string{}
 	poolNames := []string{}
 	contexts := permission.ContextsForPermission(t, permission.PermAppCreate)
@@ -64,6 +76,18 @@ func poolList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		poolList = append(poolList, p)
 		poolsMap[p.Name] = struct{}{}
 	}
+	poolList, err = provision.FilterPools(poolList, filter)
+	if err != nil {
+		return err
+	}
+	if offset >= len(poolList) {
+		poolList = nil
+	} else {
+		poolList = poolList[offset:]
+	}
+	if limit > 0 && limit < len(poolList) {
+		poolList = poolList[:limit]
+	}
 	if len(poolList) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return nil
@@ -72,10 +96,226 @@ func poolList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return json.NewEncoder(w).Encode(poolList)
 }
 
+// poolListParams reads the filter and pagination parameters of the pool list
+// request. A zero limit means no limit.
+func poolListParams(r *http.Request) (filter provision.PoolFilter, offset, limit int, err error) {
+	query := r.URL.Query()
+	filter.Provisioner = query.Get("provisioner")
+	filter.Team = query.Get("team")
+	for _, label := range query["label"] {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return filter, 0, 0, invalidPoolListParam("label", label)
+		}
+		if filter.Labels == nil {
+			filter.Labels = make(map[string]string)
+		}
+		filter.Labels[parts[0]] = parts[1]
+	}
+	if v := query.Get("default"); v != "" {
+		isDefault, parseErr := strconv.ParseBool(v)
+		if parseErr != nil {
+			return filter, 0, 0, invalidPoolListParam("default", v)
+		}
+		filter.Default = &isDefault
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return filter, 0, 0, invalidPoolListParam("offset", v)
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return filter, 0, 0, invalidPoolListParam("limit", v)
+		}
+	}
+	return filter, offset, limit, nil
+}
+
+func invalidPoolListParam(name, value string) error {
+	return &terrors.HTTP{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("invalid value for %s: %q", name, value),
+	}
+}
+
+type poolInfoResult struct {
+	Pool     *provision.Pool         `json:"pool"`
+	Nodes    int                     `json:"nodes"`
+	Units    int                     `json:"units"`
+	Apps     []string                `json:"apps"`
+	Capacity *provision.PoolCapacity `json:"capacity,omitempty"`
+}
+
+// title: pool info
+// path: /pools/{name}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Pool not found
+func poolInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	poolName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermPoolRead, permission.Context(permission.CtxPool, poolName))
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	pool, err := provision.GetPoolByName(poolName)
+	if err == provision.ErrPoolNotFound {
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	result := poolInfoResult{Pool: pool, Apps: []string{}}
+	apps, err := app.List(&app.Filter{Pool: poolName})
+	if err != nil {
+		return err
+	}
+	for i := range apps {
+		result.Apps = append(result.Apps, apps[i].Name)
+		units, unitsErr := apps[i].Units()
+		if unitsErr != nil {
+			return unitsErr
+		}
+		result.Units += len(units)
+	}
+	nodes, err := poolNodes(pool)
+	if err != nil {
+		return err
+	}
+	result.Nodes = len(nodes)
+	prov, err := pool.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	if capacityProv, ok := prov.(provision.PoolCapacityProvisioner); ok {
+		result.Capacity, err = capacityProv.PoolCapacity(poolName)
+		if err != nil {
+			return err
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+type poolCompareResult struct {
+	*provision.PoolComparison
+	Nodes poolCompareNodes `json:"nodes"`
+}
+
+type poolCompareNodes struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+// title: pool compare
+// path: /pools/compare
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+func poolCompare(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	names := []string{r.URL.Query().Get("a"), r.URL.Query().Get("b")}
+	pools := make([]*provision.Pool, len(names))
+	nodes := make([]int, len(names))
+	for i, name := range names {
+		if name == "" {
+			return &terrors.HTTP{Code: http.StatusBadRequest, Message: "both pools, a and b, are required"}
+		}
+		allowed := permission.Check(t, permission.PermPoolRead, permission.Context(permission.CtxPool, name))
+		if !allowed {
+			return permission.ErrUnauthorized
+		}
+		pool, err := provision.GetPoolByName(name)
+		if err == provision.ErrPoolNotFound {
+			return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+		nodeList, err := poolNodes(pool)
+		if err != nil {
+			return err
+		}
+		pools[i] = pool
+		nodes[i] = len(nodeList)
+	}
+	comparison, err := provision.ComparePools(pools[0], pools[1])
+	if err != nil {
+		return err
+	}
+	result := poolCompareResult{
+		PoolComparison: comparison,
+		Nodes:          poolCompareNodes{A: nodes[0], B: nodes[1]},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// title: pool health
+// path: /pools/{name}/health
+// method: GET
+// produce: application/json
+// responses:
+//   200: Pool healthy
+//   401: Unauthorized
+//   404: Pool not found
+//   503: Pool unhealthy
+func poolHealthHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	poolName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermPoolReadHealth, permission.Context(permission.CtxPool, poolName))
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	report, err := app.PoolHealth(poolName)
+	if err == provision.ErrPoolNotFound {
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	return json.NewEncoder(w).Encode(report)
+}
+
+// poolNodes returns the nodes of the pool, as registered in the pool
+// provisioner.
+func poolNodes(pool *provision.Pool) ([]provision.Node, error) {
+	prov, err := pool.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	nodeProv, ok := prov.(provision.NodeProvisioner)
+	if !ok {
+		return nil, nil
+	}
+	nodes, err := nodeProv.ListNodes(nil)
+	if err != nil {
+		return nil, err
+	}
+	var result []provision.Node
+	for _, n := range nodes {
+		if n.Pool() == pool.Name {
+			result = append(result, n)
+		}
+	}
+	return result, nil
+}
+
 // title: pool create
 // path: /pools
 // method: POST
-// consume: application/x-www-form-urlencoded
+// consume: application/x-www-form-urlencoded, application/json
 // responses:
 //   201: Pool created
 //   400: Invalid data
@@ -86,19 +326,10 @@ func addPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	dec := form.NewDecoder(nil)
-	dec.IgnoreCase(true)
-	dec.IgnoreUnknownKeys(true)
 	var addOpts provision.AddPoolOptions
-	err = r.ParseForm()
-	if err == nil {
-		err = dec.DecodeValues(&addOpts, r.Form)
-	}
+	err = decodeBody(r, &addOpts)
 	if err != nil {
-		return &terrors.HTTP{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+		return err
 	}
 	if addOpts.Name == "" {
 		return &terrors.HTTP{
@@ -110,7 +341,7 @@ func addPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 		Target:     event.Target{Type: event.TargetTypePool, Value: addOpts.Name},
 		Kind:       permission.PermPoolCreate,
 		Owner:      t,
-		CustomData: event.FormToCustomData(r.Form),
+		CustomData: bodyCustomData(r, addOpts),
 		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, addOpts.Name)),
 	})
 	if err != nil {
@@ -124,7 +355,15 @@ func addPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 			Message: err.Error(),
 		}
 	}
-	if err == provision.ErrPoolNameIsRequired {
+	if err == provision.ErrPoolNameIsRequired || err == provision.ErrInvalidPoolMetadataKey ||
+		err == provision.ErrInvalidPoolScheduler || err == provision.ErrHardwareProfileNotFound ||
+		err == provision.ErrConstraintTemplateNotFound {
+		return &terrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
+	if _, ok := err.(*naming.InvalidNameError); ok {
 		return &terrors.HTTP{
 			Code:    http.StatusBadRequest,
 			Message: err.Error(),
@@ -136,11 +375,47 @@ func addPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	return err
 }
 
+type poolReferences struct {
+	Apps  []string `json:"apps"`
+	Nodes []string `json:"nodes"`
+}
+
+// poolDeleteDryRun writes the apps and nodes still bound to the pool, which
+// would be left referencing a missing pool if it were removed.
+func poolDeleteDryRun(w http.ResponseWriter, r *http.Request, poolName string) error {
+	pool, err := provision.GetPoolByName(poolName)
+	if err == provision.ErrPoolNotFound {
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	result := poolReferences{Apps: []string{}, Nodes: []string{}}
+	apps, err := app.List(&app.Filter{Pool: poolName})
+	if err != nil {
+		return err
+	}
+	for _, a := range apps {
+		result.Apps = append(result.Apps, a.Name)
+	}
+	nodes, err := poolNodes(pool)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		result.Nodes = append(result.Nodes, n.Address())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
 // title: remove pool
 // path: /pools/{name}
 // method: DELETE
+// produce: application/json
 // responses:
 //   200: Pool removed
+//   400: Invalid data
 //   401: Unauthorized
 //   404: Pool not found
 func removePoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
@@ -150,6 +425,15 @@ func removePoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 		return permission.ErrUnauthorized
 	}
 	poolName := r.URL.Query().Get(":name")
+	if dryRun := r.FormValue("dry-run"); dryRun != "" {
+		isDryRun, parseErr := strconv.ParseBool(dryRun)
+		if parseErr != nil {
+			return &terrors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for dry-run: " + dryRun}
+		}
+		if isDryRun {
+			return poolDeleteDryRun(w, r, poolName)
+		}
+	}
 	evt, err := event.New(&event.Opts{
 		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
 		Kind:       permission.PermPoolDelete,
@@ -163,15 +447,143 @@ func removePoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	defer func() { evt.Done(err) }()
 	err = provision.RemovePool(poolName)
 	if err == provision.ErrPoolNotFound {
-		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	}
+	return err
+}
+
+type poolRenameParams struct {
+	Name string `json:"name"`
+}
+
+// title: rename pool
+// path: /pools/{name}/rename
+// method: POST
+// consume: application/x-www-form-urlencoded, application/json
+// responses:
+//   200: Pool renamed
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+//   409: Pool already exists
+func poolRenameHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	allowed := permission.Check(t, permission.PermPoolUpdateRename)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	var params poolRenameParams
+	err = decodeBody(r, &params)
+	if err != nil {
+		return err
+	}
+	if params.Name == "" {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: provision.ErrPoolNameIsRequired.Error()}
+	}
+	poolName := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
+		Kind:       permission.PermPoolUpdateRename,
+		Owner:      t,
+		CustomData: bodyCustomData(r, params),
+		Allowed: event.Allowed(permission.PermPoolReadEvents,
+			permission.Context(permission.CtxPool, poolName),
+			permission.Context(permission.CtxPool, params.Name),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = app.RenamePool(poolName, params.Name)
+	switch err {
+	case provision.ErrPoolNotFound:
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	case provision.ErrPoolAlreadyExists:
+		return &terrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if _, ok := err.(*naming.InvalidNameError); ok {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	}
 	return err
 }
 
+type poolMigrateParams struct {
+	Provisioner string `json:"provisioner"`
+	Cluster     string `json:"cluster"`
+	Reschedule  bool   `json:"reschedule"`
+}
+
+// title: migrate pool
+// path: /pools/{name}/migrate
+// method: POST
+// consume: application/x-www-form-urlencoded, application/json
+// produce: application/x-json-stream
+// responses:
+//   200: Pool migrated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool or cluster not found
+func poolMigrateHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	allowed := permission.Check(t, permission.PermPoolUpdateMigrate)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	var params poolMigrateParams
+	err = decodeBody(r, &params)
+	if err != nil {
+		return err
+	}
+	poolName := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:        event.Target{Type: event.TargetTypePool, Value: poolName},
+		Kind:          permission.PermPoolUpdateMigrate,
+		Owner:         t,
+		CustomData:    bodyCustomData(r, params),
+		Allowed:       event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
+		AllowedCancel: event.Allowed(permission.PermPoolUpdateMigrate, permission.Context(permission.CtxPool, poolName)),
+		Cancelable:    true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	writer := newJSONMessageStream(w, r, 15*time.Second)
+	defer writer.Close()
+	evt.SetLogWriter(writer)
+	err = app.MigratePool(poolName, app.MigratePoolOptions{
+		Provisioner: params.Provisioner,
+		Cluster:     params.Cluster,
+		Reschedule:  params.Reschedule,
+		Writer:      evt,
+		Event:       evt,
+	})
+	switch err {
+	case provision.ErrPoolNotFound:
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	case cluster.ErrClusterNotFound:
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodeClusterNotFound, err.Error())
+	}
+	switch err.(type) {
+	case *terrors.ValidationError, provision.ProvisionerNotSupported:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "Pool %q successfully migrated!\n", poolName)
+	return nil
+}
+
+// poolTeamsParams holds the teams sent to the pool team handlers, either as
+// team form values or as a JSON document like {"team": ["team1", "team2"]}.
+type poolTeamsParams struct {
+	Team []string `json:"team"`
+}
+
 // title: add team too pool
 // path: /pools/{name}/team
 // method: POST
-// consume: application/x-www-form-urlencoded
+// consume: application/x-www-form-urlencoded, application/json
 // responses:
 //   200: Pool updated
 //   401: Unauthorized
@@ -183,7 +595,13 @@ func addTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 		return permission.ErrUnauthorized
 	}
 	msg := "You must provide the team."
-	err = r.ParseForm()
+	var params poolTeamsParams
+	if isJSONRequest(r) {
+		err = decodeBody(r, &params)
+	} else {
+		err = r.ParseForm()
+		params.Team = r.Form["team"]
+	}
 	if err != nil {
 		return &terrors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
@@ -192,17 +610,17 @@ func addTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
 		Kind:       permission.PermPoolUpdateTeamAdd,
 		Owner:      t,
-		CustomData: event.FormToCustomData(r.Form),
+		CustomData: bodyCustomData(r, params),
 		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
 	})
 	if err != nil {
 		return err
 	}
 	defer func() { evt.Done(err) }()
-	if teams, ok := r.Form["team"]; ok {
+	if teams := params.Team; len(teams) > 0 {
 		err := provision.AddTeamsToPool(poolName, teams)
 		if err == provision.ErrPoolNotFound {
-			return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+			return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
 		}
 		return err
 	}
@@ -212,6 +630,7 @@ func addTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 // title: remove team from pool
 // path: /pools/{name}/team
 // method: DELETE
+// consume: application/json
 // responses:
 //   200: Pool updated
 //   401: Unauthorized
@@ -223,22 +642,29 @@ func removeTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Toke
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	params := poolTeamsParams{Team: r.URL.Query()["team"]}
+	if isJSONRequest(r) {
+		err = decodeBody(r, &params)
+		if err != nil {
+			return err
+		}
+	}
 	poolName := r.URL.Query().Get(":name")
 	evt, err := event.New(&event.Opts{
 		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
 		Kind:       permission.PermPoolUpdateTeamRemove,
 		Owner:      t,
-		CustomData: event.FormToCustomData(r.Form),
+		CustomData: bodyCustomData(r, params),
 		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
 	})
 	if err != nil {
 		return err
 	}
 	defer func() { evt.Done(err) }()
-	if teams, ok := r.URL.Query()["team"]; ok {
+	if teams := params.Team; len(teams) > 0 {
 		err := provision.RemoveTeamsFromPool(poolName, teams)
 		if err == provision.ErrPoolNotFound {
-			return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+			return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
 		}
 		return err
 	}
@@ -248,12 +674,59 @@ func removeTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Toke
 	}
 }
 
+// title: set pool teams
+// path: /pools/{name}/teams
+// method: PUT
+// consume: application/x-www-form-urlencoded, application/json
+// responses:
+//   200: Pool updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+func setPoolTeamsHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	allowed := permission.Check(t, permission.PermPoolUpdateTeamSet)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	var params poolTeamsParams
+	if isJSONRequest(r) {
+		err = decodeBody(r, &params)
+	} else {
+		err = r.ParseForm()
+		params.Team = r.Form["team"]
+	}
+	if err != nil {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	poolName := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
+		Kind:       permission.PermPoolUpdateTeamSet,
+		Owner:      t,
+		CustomData: bodyCustomData(r, params),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.SetPoolTeams(poolName, params.Team)
+	switch err {
+	case provision.ErrPoolNotFound:
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	case provision.ErrPoolTeamsRequired, provision.ErrPublicDefaultPoolCantHaveTeams:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
 // title: pool update
 // path: /pools/{name}
 // method: PUT
-// consume: application/x-www-form-urlencoded
+// consume: application/x-www-form-urlencoded, application/json
 // responses:
 //   200: Pool updated
+//   400: Invalid data
 //   401: Unauthorized
 //   404: Pool not found
 //   409: Default pool already defined
@@ -264,31 +737,39 @@ func poolUpdateHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 		return permission.ErrUnauthorized
 	}
 	poolName := r.URL.Query().Get(":name")
+	var updateOpts provision.UpdatePoolOptions
+	err = decodeBody(r, &updateOpts)
+	if err != nil {
+		return err
+	}
+	poolTarget := event.Target{Type: event.TargetTypePool, Value: poolName}
+	err = checkPolicy(t, permission.PermPoolUpdate, poolTarget, updateOpts)
+	if err != nil {
+		return err
+	}
 	evt, err := event.New(&event.Opts{
-		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
+		Target:     poolTarget,
 		Kind:       permission.PermPoolUpdate,
 		Owner:      t,
-		CustomData: event.FormToCustomData(r.Form),
+		CustomData: bodyCustomData(r, updateOpts),
 		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
 	})
 	if err != nil {
 		return err
 	}
-	defer func() { evt.Done(err) }()
-	dec := form.NewDecoder(nil)
-	dec.IgnoreCase(true)
-	dec.IgnoreUnknownKeys(true)
-	var updateOpts provision.UpdatePoolOptions
-	err = dec.DecodeValues(&updateOpts, r.Form)
-	if err != nil {
-		return &terrors.HTTP{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+	var before, after *provision.Pool
+	defer func() { evt.DoneWithDiff(err, before, after) }()
+	before, err = provision.GetPoolByName(poolName)
+	if err == nil {
+		err = provision.PoolUpdate(poolName, updateOpts)
 	}
-	err = provision.PoolUpdate(poolName, updateOpts)
 	if err == provision.ErrPoolNotFound {
-		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	}
+	if err == provision.ErrInvalidPoolMetadataKey || err == provision.ErrInvalidPoolState ||
+		err == provision.ErrDefaultPoolMustBeActive || err == provision.ErrInvalidPoolScheduler ||
+		err == provision.ErrHardwareProfileNotFound {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	}
 	if err == provision.ErrDefaultPoolAlreadyExists {
 		return &terrors.HTTP{
@@ -296,6 +777,112 @@ func poolUpdateHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 			Message: err.Error(),
 		}
 	}
+	if err != nil {
+		return err
+	}
+	after, err = provision.GetPoolByName(poolName)
+	return err
+}
+
+type poolEnvParams struct {
+	Envs map[string]string `json:"envs"`
+}
+
+// title: set pool envs
+// path: /pools/{name}/env
+// method: PUT
+// consume: application/json
+// responses:
+//   200: Envs updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+func poolEnvSetHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	poolName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermPoolUpdateEnv, permission.Context(permission.CtxPool, poolName))
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	var params poolEnvParams
+	err = decodeBody(r, &params)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
+		Kind:       permission.PermPoolUpdateEnv,
+		Owner:      t,
+		CustomData: bodyCustomData(r, params),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
+	})
+	if err != nil {
+		return err
+	}
+	var before, after *provision.Pool
+	defer func() { evt.DoneWithDiff(err, poolEnvs(before), poolEnvs(after)) }()
+	before, err = provision.GetPoolByName(poolName)
+	if err == nil {
+		err = provision.SetPoolEnvs(poolName, params.Envs)
+	}
+	switch err {
+	case nil:
+	case provision.ErrPoolNotFound:
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	case provision.ErrInvalidPoolEnvName:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	default:
+		return err
+	}
+	after, err = provision.GetPoolByName(poolName)
+	return err
+}
+
+// poolEnvs returns the envs of the pool as stored in pool env events.
+func poolEnvs(pool *provision.Pool) *poolEnvParams {
+	if pool == nil {
+		return nil
+	}
+	return &poolEnvParams{Envs: pool.Env}
+}
+
+// title: set pool quota
+// path: /pools/{name}/quota
+// method: PUT
+// consume: application/json
+// responses:
+//   200: Quota updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+func poolQuotaSetHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	poolName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermPoolUpdateQuota, permission.Context(permission.CtxPool, poolName))
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	var quota provision.PoolQuota
+	err = decodeBody(r, &quota)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
+		Kind:       permission.PermPoolUpdateQuota,
+		Owner:      t,
+		CustomData: bodyCustomData(r, quota),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.SetPoolQuota(poolName, quota)
+	switch err {
+	case provision.ErrPoolNotFound:
+		return newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	case provision.ErrInvalidPoolQuota:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
 	return err
 }
 
@@ -327,9 +914,12 @@ func poolConstraintList(w http.ResponseWriter, r *http.Request, t auth.Token) er
 // path: /constraints
 // method: PUT
 // consume: application/x-www-form-urlencoded
+// produce: application/json
 // responses:
 //   200: OK
+//   400: Invalid data
 //   401: Unauthorized
+//   409: Existing apps or clusters would become non-compliant
 func poolConstraintSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if !permission.Check(t, permission.PermPoolUpdateConstraintsSet) {
 		return permission.ErrUnauthorized
@@ -348,14 +938,27 @@ func poolConstraintSet(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 			Message: err.Error(),
 		}
 	}
-	if poolConstraint.PoolExpr == "" {
+	if poolConstraint.PoolExpr == "" && poolConstraint.Layer != provision.ConstraintLayerGlobal {
 		return &terrors.HTTP{
 			Code:    http.StatusBadRequest,
 			Message: "You must provide a Pool Expression",
 		}
 	}
+	append, _ := strconv.ParseBool(r.FormValue("append"))
+	// With impact, the existing apps and clusters that would become
+	// non-compliant are listed without changing the constraint.
+	if impact, _ := strconv.ParseBool(r.FormValue("impact")); impact {
+		var violations []provision.ConstraintViolation
+		violations, err = provision.PoolConstraintImpact(&poolConstraint, append)
+		if err != nil {
+			return poolConstraintError(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(violations)
+	}
+	enforce, _ := strconv.ParseBool(r.FormValue("enforce"))
 	evt, err := event.New(&event.Opts{
-		Target:     event.Target{Type: event.TargetTypePool, Value: poolConstraint.PoolExpr},
+		Target:     poolConstraintTarget(&poolConstraint),
 		Kind:       permission.PermPoolUpdateConstraintsSet,
 		Owner:      t,
 		CustomData: event.FormToCustomData(r.Form),
@@ -365,12 +968,195 @@ func poolConstraintSet(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 		return err
 	}
 	defer func() { evt.Done(err) }()
-	append := false
-	if appendStr := r.FormValue("append"); appendStr != "" {
-		append, _ = strconv.ParseBool(appendStr)
+	poolConstraint.SetBy = t.GetUserName()
+	if enforce {
+		err = provision.SetPoolConstraintEnforced(&poolConstraint, append)
+	} else if append {
+		err = provision.AppendPoolConstraint(&poolConstraint)
+	} else {
+		err = provision.SetPoolConstraint(&poolConstraint)
+	}
+	return poolConstraintError(err)
+}
+
+func poolConstraintError(err error) error {
+	if err == provision.ErrInvalidConstraintLayer || err == provision.ErrInvalidPoolExpr ||
+		err == provision.ErrInvalidConstraintType {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if _, ok := err.(*provision.ConstraintViolationsError); ok {
+		return &terrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+// poolConstraintTarget returns the event target of a constraint, global
+// constraints target every pool.
+func poolConstraintTarget(c *provision.PoolConstraint) event.Target {
+	if c.Layer == provision.ConstraintLayerGlobal {
+		return event.Target{Type: event.TargetTypePool, Value: "*"}
+	}
+	return event.Target{Type: event.TargetTypePool, Value: c.PoolExpr}
+}
+
+type poolConstraintsResult struct {
+	PoolExpr    string                               `json:"poolExpr"`
+	Constraints []*provision.PoolConstraint          `json:"constraints"`
+	Applied     map[string]*provision.PoolConstraint `json:"applied"`
+}
+
+// title: get pool constraints
+// path: /constraints/{poolExpr}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func poolConstraintGet(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermPoolReadConstraints) {
+		return permission.ErrUnauthorized
+	}
+	poolExpr := r.URL.Query().Get(":poolExpr")
+	constraints, err := provision.MatchingPoolConstraints(poolExpr)
+	if err != nil {
+		return err
+	}
+	if len(constraints) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	applied, err := provision.ResolvePoolConstraints(poolExpr)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(poolConstraintsResult{
+		PoolExpr:    poolExpr,
+		Constraints: constraints,
+		Applied:     applied,
+	})
+}
+
+// title: pool constraint history
+// path: /constraints/history
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+func poolConstraintHistory(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermPoolReadConstraints) {
+		return permission.ErrUnauthorized
+	}
+	poolExpr := r.URL.Query().Get("poolExpr")
+	if poolExpr == "" {
+		return &terrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "You must provide a Pool Expression",
+		}
+	}
+	perms, err := t.Permissions()
+	if err != nil {
+		return err
+	}
+	events, err := event.List(&event.Filter{
+		Target: event.Target{Type: event.TargetTypePool, Value: poolExpr},
+		Raw: bson.M{"kind.name": bson.M{"$in": []string{
+			permission.PermPoolUpdateConstraintsSet.FullName(),
+			permission.PermPoolUpdateConstraintsRemove.FullName(),
+		}}},
+		Permissions: perms,
+	})
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(events)
+}
+
+// title: resolve pool constraint
+// path: /constraints/resolve
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+func poolConstraintResolve(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermPoolReadConstraints) {
+		return permission.ErrUnauthorized
+	}
+	resolution, err := provision.ResolvePoolConstraint(r.FormValue("pool"), r.FormValue("field"))
+	if err == provision.ErrPoolNameIsRequired || err == provision.ErrInvalidConstraintType {
+		return &terrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(resolution)
+}
+
+// title: remove a pool constraint
+// path: /constraints
+// method: DELETE
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Constraint not found
+func poolConstraintRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermPoolUpdateConstraintsRemove) {
+		return permission.ErrUnauthorized
+	}
+	dec := form.NewDecoder(nil)
+	dec.IgnoreCase(true)
+	dec.IgnoreUnknownKeys(true)
+	var poolConstraint provision.PoolConstraint
+	err = r.ParseForm()
+	if err == nil {
+		err = dec.DecodeValues(&poolConstraint, r.Form)
 	}
-	if append {
-		return provision.AppendPoolConstraint(&poolConstraint)
+	if err != nil {
+		return &terrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
 	}
-	return provision.SetPoolConstraint(&poolConstraint)
+	if poolConstraint.PoolExpr == "" && poolConstraint.Layer != provision.ConstraintLayerGlobal {
+		return &terrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "You must provide a Pool Expression",
+		}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     poolConstraintTarget(&poolConstraint),
+		Kind:       permission.PermPoolUpdateConstraintsRemove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.RemoveConstraint(&poolConstraint)
+	switch err {
+	case provision.ErrInvalidConstraintType, provision.ErrInvalidConstraintLayer:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case provision.ErrPoolConstraintNotFound:
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
 }
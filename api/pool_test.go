@@ -7,17 +7,24 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	"github.com/ajg/form"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
 	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
 )
 
 func (s *S) TestAddPoolNameIsRequired(c *check.C) {
@@ -33,6 +40,21 @@ func (s *S) TestAddPoolNameIsRequired(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, provision.ErrPoolNameIsRequired.Error()+"\n")
 }
 
+func (s *S) TestAddPoolInvalidName(c *check.C) {
+	config.Set("naming:pool:regex", "^pool-")
+	defer config.Unset("naming")
+	b := bytes.NewBufferString("name=mypool")
+	request, err := http.NewRequest("POST", "/pools", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "Invalid pool name, it must match the regular expression \"^pool-\".\n")
+}
+
 func (s *S) TestAddPoolDefaultPoolAlreadyExists(c *check.C) {
 	b := bytes.NewBufferString("name=pool1&default=true")
 	req, err := http.NewRequest("POST", "/pools", b)
@@ -111,6 +133,22 @@ func (s *S) TestRemovePoolNotFound(c *check.C) {
 	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
 }
 
+func (s *S) TestRemovePoolNotFoundErrorCode(c *check.C) {
+	req, err := http.NewRequest("DELETE", "/pools/not-found", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+	c.Assert(rec.Header().Get("X-Tsuru-Error-Code"), check.Equals, "pool_not_found")
+	var result map[string]string
+	err = json.Unmarshal(rec.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, map[string]string{"code": "pool_not_found", "message": provision.ErrPoolNotFound.Error()})
+}
+
 func (s *S) TestRemovePoolHandler(c *check.C) {
 	opts := provision.AddPoolOptions{
 		Name: "pool1",
@@ -136,6 +174,386 @@ func (s *S) TestRemovePoolHandler(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestRemovePoolHandlerDryRun(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Pool: "pool1"}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://node1:2375",
+		Metadata: map[string]string{"pool": "pool1"},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://node2:2375",
+		Metadata: map[string]string{"pool": "other"},
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("DELETE", "/pools/pool1?dry-run=true", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result map[string][]string
+	err = json.NewDecoder(rec.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, map[string][]string{
+		"apps":  {"myapp"},
+		"nodes": {"http://node1:2375"},
+	})
+	_, err = provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestRemovePoolHandlerDryRunNotFound(c *check.C) {
+	req, err := http.NewRequest("DELETE", "/pools/not-found?dry-run=true", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestRemovePoolHandlerInvalidDryRun(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("DELETE", "/pools/pool1?dry-run=maybe", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	_, err = provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestPoolRenameHandler(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Pool: "pool1"}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/pools/pool1/rename", strings.NewReader("name=pool2"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	_, err = provision.GetPoolByName("pool1")
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+	_, err = provision.GetPoolByName("pool2")
+	c.Assert(err, check.IsNil)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "pool2")
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.rename",
+		StartCustomData: []map[string]interface{}{
+			{"name": "name", "value": "pool2"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestPoolRenameHandlerErrors(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool2"})
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/pools/pool1/rename", "name=pool2", http.StatusConflict},
+		{"/pools/unknown/rename", "name=pool3", http.StatusNotFound},
+		{"/pools/pool1/rename", "", http.StatusBadRequest},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Check(rec.Code, check.Equals, tt.code, check.Commentf("%s %q", tt.path, tt.body))
+	}
+	_, err = provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestPoolRenameHandlerForbidden(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdate,
+		Context: permission.Context(permission.CtxPool, "pool1"),
+	})
+	req, err := http.NewRequest("POST", "/pools/pool1/rename", strings.NewReader("name=pool2"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
+type migrateTargetProvisioner struct {
+	*provisiontest.FakeProvisioner
+}
+
+func (p *migrateTargetProvisioner) GetName() string {
+	return "fake-migrate"
+}
+
+func (s *S) TestPoolMigrateHandler(c *check.C) {
+	provision.Register("fake-migrate", func() (provision.Provisioner, error) {
+		return &migrateTargetProvisioner{FakeProvisioner: provisiontest.NewFakeProvisioner()}, nil
+	})
+	defer provision.Unregister("fake-migrate")
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/pools/pool1/migrate", strings.NewReader(`{"provisioner": "fake-migrate"}`))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(rec.Body.String(), check.Matches, `(?s).*Pool \\"pool1\\" successfully migrated!.*`)
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "fake-migrate")
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.migrate",
+		StartCustomData: map[string]interface{}{
+			"provisioner": "fake-migrate",
+			"cluster":     "",
+			"reschedule":  false,
+		},
+		LogMatches: `Pool "pool1" moved from provisioner "fake" to "fake-migrate"`,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestPoolMigrateHandlerErrors(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Pool: "pool1"}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/pools/unknown/migrate", "provisioner=fake", http.StatusNotFound},
+		{"/pools/pool1/migrate", "cluster=unknown", http.StatusNotFound},
+		{"/pools/pool1/migrate", "", http.StatusBadRequest},
+		{"/pools/pool1/migrate", "provisioner=fake", http.StatusBadRequest},
+		{"/pools/pool1/migrate", "provisioner=unknown", http.StatusBadRequest},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Check(rec.Code, check.Equals, tt.code, check.Commentf("%s %q", tt.path, tt.body))
+	}
+}
+
+func (s *S) TestPoolEnvSetHandler(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader(`{"envs": {"REGION": "dc1", "HTTP_PROXY": "http://proxy:3128"}}`)
+	req, err := http.NewRequest("PUT", "/pools/pool1/env", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Env, check.DeepEquals, map[string]string{"REGION": "dc1", "HTTP_PROXY": "http://proxy:3128"})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.env",
+		StartCustomData: map[string]interface{}{
+			"envs.REGION": "dc1",
+		},
+		Changes: []event.Change{
+			{Field: "envs.HTTP_PROXY", After: "http://proxy:3128"},
+			{Field: "envs.REGION", After: "dc1"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestPoolEnvSetHandlerErrors(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/pools/unknown/env", `{"envs": {"REGION": "dc1"}}`, http.StatusNotFound},
+		{"/pools/pool1/env", `{"envs": {"a.b": "c"}}`, http.StatusBadRequest},
+		{"/pools/pool1/env", `{"envs": `, http.StatusBadRequest},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		req, err := http.NewRequest("PUT", tt.path, strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Check(rec.Code, check.Equals, tt.code, check.Commentf("%s %q", tt.path, tt.body))
+	}
+}
+
+func (s *S) TestPoolEnvSetHandlerForbidden(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateEnv,
+		Context: permission.Context(permission.CtxPool, "pool2"),
+	})
+	req, err := http.NewRequest("PUT", "/pools/pool1/env", strings.NewReader(`{"envs": {"REGION": "dc1"}}`))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestPoolQuotaSetHandler(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader(`{"maxApps": 10, "maxUnits": 20, "maxMemory": 1024}`)
+	req, err := http.NewRequest("PUT", "/pools/pool1/quota", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Quota, check.DeepEquals, provision.PoolQuota{MaxApps: 10, MaxUnits: 20, MaxMemory: 1024})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.quota",
+		StartCustomData: map[string]interface{}{
+			"maxapps":  10,
+			"maxunits": 20,
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestPoolQuotaSetHandlerErrors(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/pools/unknown/quota", `{"maxApps": 1}`, http.StatusNotFound},
+		{"/pools/pool1/quota", `{"maxUnits": -1}`, http.StatusBadRequest},
+		{"/pools/pool1/quota", `{"maxApps": `, http.StatusBadRequest},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		req, err := http.NewRequest("PUT", tt.path, strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Check(rec.Code, check.Equals, tt.code, check.Commentf("%s %q", tt.path, tt.body))
+	}
+}
+
+func (s *S) TestPoolQuotaSetHandlerForbidden(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateQuota,
+		Context: permission.Context(permission.CtxPool, "pool2"),
+	})
+	req, err := http.NewRequest("PUT", "/pools/pool1/quota", strings.NewReader(`{"maxApps": 1}`))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestPoolListWithQuotaUsage(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1", Public: true})
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolQuota("pool1", provision.PoolQuota{MaxUnits: 10})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Insert(bson.M{
+		"name":  "app1",
+		"pool":  "pool1",
+		"plan":  bson.M{"memory": 512, "cpushare": 10},
+		"quota": bson.M{"limit": -1, "inuse": 3},
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("GET", "/pools", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	var pools []struct {
+		Name  string
+		Quota provision.PoolQuota
+		Usage *provision.PoolUsage
+	}
+	err = json.NewDecoder(rec.Body).Decode(&pools)
+	c.Assert(err, check.IsNil)
+	var found bool
+	for _, p := range pools {
+		if p.Name != "pool1" {
+			continue
+		}
+		found = true
+		c.Assert(p.Quota, check.DeepEquals, provision.PoolQuota{MaxUnits: 10})
+		c.Assert(p.Usage, check.DeepEquals, &provision.PoolUsage{Apps: 1, Units: 3, Memory: 1536, CPUShare: 30})
+	}
+	c.Assert(found, check.Equals, true)
+}
+
 func (s *S) TestAddTeamsToPoolWithoutTeam(c *check.C) {
 	pool := provision.Pool{Name: "pool1"}
 	opts := provision.AddPoolOptions{Name: pool.Name}
@@ -152,6 +570,71 @@ func (s *S) TestAddTeamsToPoolWithoutTeam(c *check.C) {
 	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
 }
 
+func (s *S) TestAddPoolJSON(c *check.C) {
+	b := strings.NewReader(`{"name": "pool1", "public": true, "provisioner": "fake"}`)
+	req, err := http.NewRequest("POST", "/pools", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "fake")
+	teams, err := pool.GetTeams()
+	c.Assert(err, check.IsNil)
+	c.Assert(teams, check.DeepEquals, []string{"tsuruteam"})
+	c.Assert(eventtest.EventDesc{
+		Target:          event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:           s.token.GetUserName(),
+		Kind:            "pool.create",
+		StartCustomData: map[string]interface{}{"name": "pool1", "public": true},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAddPoolInvalidJSON(c *check.C) {
+	b := strings.NewReader(`{"name": `)
+	req, err := http.NewRequest("POST", "/pools", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestAddPoolWithLabels(c *check.C) {
+	b := strings.NewReader("name=pool1&labels.env=prod&annotations.owner=infra")
+	req, err := http.NewRequest("POST", "/pools", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Labels, check.DeepEquals, map[string]string{"env": "prod"})
+	c.Assert(pool.Annotations, check.DeepEquals, map[string]string{"owner": "infra"})
+}
+
+func (s *S) TestAddPoolInvalidLabel(c *check.C) {
+	b := strings.NewReader(`{"name": "pool1", "labels": {"a.b": "c"}}`)
+	req, err := http.NewRequest("POST", "/pools", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, provision.ErrInvalidPoolMetadataKey.Error()+"\n")
+}
+
 func (s *S) TestAddTeamsToPool(c *check.C) {
 	pool := provision.Pool{Name: "pool1"}
 	opts := provision.AddPoolOptions{Name: pool.Name}
@@ -182,6 +665,82 @@ func (s *S) TestAddTeamsToPool(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestSetPoolTeamsHandler(c *check.C) {
+	err := auth.CreateTeam("ateam", s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddTeamsToPool("pool1", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	b := strings.NewReader(`{"team": ["ateam"]}`)
+	req, err := http.NewRequest("PUT", "/pools/pool1/teams", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	p, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	teams, err := p.GetTeams()
+	c.Assert(err, check.IsNil)
+	c.Assert(teams, check.DeepEquals, []string{"ateam"})
+	c.Assert(eventtest.EventDesc{
+		Target:          event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:           s.token.GetUserName(),
+		Kind:            "pool.update.team.set",
+		StartCustomData: map[string]interface{}{"team": []interface{}{"ateam"}},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestSetPoolTeamsHandlerErrors(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/pools/unknown/teams", "team=ateam", http.StatusNotFound},
+		{"/pools/pool1/teams", "", http.StatusBadRequest},
+		{"/pools/pool2/teams", "team=ateam", http.StatusBadRequest},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		req, err := http.NewRequest("PUT", tt.path, strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Check(rec.Code, check.Equals, tt.code, check.Commentf("%s %q", tt.path, tt.body))
+	}
+}
+
+func (s *S) TestAddTeamsToPoolJSON(c *check.C) {
+	err := auth.CreateTeam("ateam", s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	b := strings.NewReader(`{"team": ["tsuruteam", "ateam"]}`)
+	req, err := http.NewRequest("POST", "/pools/pool1/team", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	p, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	teams, err := p.GetTeams()
+	c.Assert(err, check.IsNil)
+	c.Assert(teams, check.DeepEquals, []string{"tsuruteam", "ateam"})
+}
+
 func (s *S) TestAddTeamsToPoolNotFound(c *check.C) {
 	b := strings.NewReader("team=test")
 	req, err := http.NewRequest("POST", "/pools/notfound/team", b)
@@ -255,6 +814,29 @@ func (s *S) TestRemoveTeamsToPoolHandler(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestRemoveTeamsToPoolJSON(c *check.C) {
+	err := auth.CreateTeam("ateam", s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddTeamsToPool("pool1", []string{"tsuruteam", "ateam"})
+	c.Assert(err, check.IsNil)
+	b := strings.NewReader(`{"team": ["ateam"]}`)
+	req, err := http.NewRequest("DELETE", "/pools/pool1/team", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	p, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	teams, err := p.GetTeams()
+	c.Assert(err, check.IsNil)
+	c.Assert(teams, check.DeepEquals, []string{"tsuruteam"})
+}
+
 func (s *S) TestPoolListPublicPool(c *check.C) {
 	pool := provision.Pool{Name: "pool1"}
 	opts := provision.AddPoolOptions{Name: pool.Name, Public: true}
@@ -396,38 +978,385 @@ func (s *S) TestPoolListHandlerWithPoolReadPermission(c *check.C) {
 	c.Assert(pools[1].Name, check.Equals, "pool1")
 }
 
-func (s *S) TestPoolUpdateToPublicHandler(c *check.C) {
-	opts := provision.AddPoolOptions{Name: "pool1"}
-	err := provision.AddPool(opts)
+func (s *S) TestPoolListHandlerPaginationAndFilters(c *check.C) {
+	for _, name := range []string{"pool1", "pool2", "pool3"} {
+		err := provision.AddPool(provision.AddPoolOptions{Name: name, Public: true})
+		c.Assert(err, check.IsNil)
+	}
+	err := provision.PoolUpdate("pool2", provision.UpdatePoolOptions{Provisioner: "fake-extensible"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c)
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"test1", "pool1", "pool2", "pool3"}},
+		{"limit=2", []string{"test1", "pool1"}},
+		{"offset=1&limit=2", []string{"pool1", "pool2"}},
+		{"offset=3", []string{"pool3"}},
+		{"default=true", []string{"test1"}},
+		{"default=false&limit=1", []string{"pool1"}},
+		{"provisioner=fake-extensible", []string{"pool2"}},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", "/pools?"+tt.query, nil)
+		c.Assert(err, check.IsNil)
+		rec := httptest.NewRecorder()
+		err = poolList(rec, req, token)
+		c.Assert(err, check.IsNil)
+		var pools []provision.Pool
+		err = json.NewDecoder(rec.Body).Decode(&pools)
+		c.Assert(err, check.IsNil)
+		var names []string
+		for _, p := range pools {
+			names = append(names, p.Name)
+		}
+		c.Check(names, check.DeepEquals, tt.expected, check.Commentf("query %q", tt.query))
+	}
+	req, err := http.NewRequest("GET", "/pools?offset=10", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	err = poolList(rec, req, token)
+	c.Assert(err, check.IsNil)
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestPoolListHandlerFilterByLabel(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1", Public: true, Labels: map[string]string{"env": "prod"}})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool2", Public: true, Labels: map[string]string{"env": "dev"}})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c)
+	req, err := http.NewRequest("GET", "/pools?label=env=prod", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	err = poolList(rec, req, token)
+	c.Assert(err, check.IsNil)
+	var pools []map[string]interface{}
+	err = json.NewDecoder(rec.Body).Decode(&pools)
+	c.Assert(err, check.IsNil)
+	c.Assert(pools, check.HasLen, 1)
+	c.Assert(pools[0]["name"], check.Equals, "pool1")
+	c.Assert(pools[0]["labels"], check.DeepEquals, map[string]interface{}{"env": "prod"})
+}
+
+func (s *S) TestPoolListHandlerInvalidParams(c *check.C) {
+	token := userWithPermission(c)
+	for _, query := range []string{"limit=-1", "offset=abc", "default=maybe", "label=env", "label==prod"} {
+		req, err := http.NewRequest("GET", "/pools?"+query, nil)
+		c.Assert(err, check.IsNil)
+		rec := httptest.NewRecorder()
+		err = poolList(rec, req, token)
+		c.Assert(err, check.NotNil)
+		e, ok := err.(*errors.HTTP)
+		c.Assert(ok, check.Equals, true)
+		c.Check(e.Code, check.Equals, http.StatusBadRequest)
+	}
+}
+
+func (s *S) TestPoolInfo(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Pool: "test1"}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://node1:2375",
+		Metadata: map[string]string{"pool": "test1"},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://node2:2375",
+		Metadata: map[string]string{"pool": "other"},
+	})
+	c.Assert(err, check.IsNil)
+	s.provisioner.SetPoolCapacity("test1", provision.PoolCapacity{Memory: 1024, ReservedMemory: 512})
+	request, err := http.NewRequest("GET", "/pools/test1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result struct {
+		Pool     map[string]interface{}
+		Nodes    int
+		Units    int
+		Apps     []string
+		Capacity *provision.PoolCapacity
+	}
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Pool["name"], check.Equals, "test1")
+	c.Assert(result.Pool["default"], check.Equals, true)
+	c.Assert(result.Nodes, check.Equals, 1)
+	c.Assert(result.Units, check.Equals, 2)
+	c.Assert(result.Apps, check.DeepEquals, []string{"myapp"})
+	c.Assert(result.Capacity, check.DeepEquals, &provision.PoolCapacity{Memory: 1024, ReservedMemory: 512})
+}
+
+func (s *S) TestPoolInfoNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/pools/notfound", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, provision.ErrPoolNotFound.Error()+"\n")
+}
+
+func (s *S) TestPoolInfoForbidden(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolRead,
+		Context: permission.Context(permission.CtxPool, "other"),
+	})
+	request, err := http.NewRequest("GET", "/pools/test1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestPoolCompare(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "other", Provisioner: "fake", Labels: map[string]string{"env": "dev"}})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://node1:2375",
+		Metadata: map[string]string{"pool": "test1"},
+	})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/pools/compare?a=test1&b=other", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result struct {
+		A        string
+		B        string
+		Settings []provision.PoolSettingDiff
+		Nodes    struct{ A, B int }
+	}
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.A, check.Equals, "test1")
+	c.Assert(result.B, check.Equals, "other")
+	c.Assert(result.Nodes.A, check.Equals, 1)
+	c.Assert(result.Nodes.B, check.Equals, 0)
+	var settings []string
+	for _, diff := range result.Settings {
+		settings = append(settings, diff.Setting)
+	}
+	c.Assert(settings, check.DeepEquals, []string{"provisioner", "default", "labels"})
+}
+
+func (s *S) TestPoolCompareMissingPool(c *check.C) {
+	request, err := http.NewRequest("GET", "/pools/compare?a=test1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	request, err = http.NewRequest("GET", "/pools/compare?a=test1&b=notfound", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestPoolCompareForbidden(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "other"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolRead,
+		Context: permission.Context(permission.CtxPool, "other"),
+	})
+	request, err := http.NewRequest("GET", "/pools/compare?a=test1&b=other", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestPoolHealth(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://node1:2375",
+		Metadata: map[string]string{"pool": "test1"},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.SetNodeStatus("http://node1:2375", "ready")
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/pools/test1/health", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var report app.PoolHealthReport
+	err = json.NewDecoder(recorder.Body).Decode(&report)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Pool, check.Equals, "test1")
+	c.Assert(report.Healthy, check.Equals, true)
+	c.Assert(report.Checks[0], check.DeepEquals, app.PoolHealthCheck{Name: "cluster", Status: app.PoolHealthOK})
+	c.Assert(report.Checks[1], check.DeepEquals, app.PoolHealthCheck{Name: "nodes", Status: app.PoolHealthOK, Message: "1 nodes ready"})
+}
+
+func (s *S) TestPoolHealthUnhealthy(c *check.C) {
+	s.provisioner.PrepareFailure("PoolHealthCheck", fmt.Errorf("cluster unreachable"))
+	request, err := http.NewRequest("GET", "/pools/test1/health", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusServiceUnavailable)
+	var report app.PoolHealthReport
+	err = json.NewDecoder(recorder.Body).Decode(&report)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Healthy, check.Equals, false)
+	c.Assert(report.Checks[0], check.DeepEquals, app.PoolHealthCheck{Name: "cluster", Status: app.PoolHealthFail, Message: "cluster unreachable"})
+	c.Assert(report.Checks[1], check.DeepEquals, app.PoolHealthCheck{Name: "nodes", Status: app.PoolHealthFail, Message: `no nodes found in pool "test1"`})
+}
+
+func (s *S) TestPoolHealthNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/pools/notfound/health", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestPoolUpdateToPublicHandler(c *check.C) {
+	opts := provision.AddPoolOptions{Name: "pool1"}
+	err := provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolConstraint(&provision.PoolConstraint{PoolExpr: "pool1", Field: "team", Values: []string{"*"}, Blacklist: true})
+	c.Assert(err, check.IsNil)
+	p, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	_, err = p.GetTeams()
+	c.Assert(err, check.NotNil)
+	b := bytes.NewBufferString("public=true")
+	req, err := http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(err, check.IsNil)
+	teams, err := p.GetTeams()
+	c.Assert(err, check.IsNil)
+	c.Assert(teams, check.DeepEquals, []string{"tsuruteam"})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "pool1"},
+			{"name": "public", "value": "true"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestPoolUpdateStateHandler(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	b := bytes.NewBufferString("state=draining")
+	req, err := http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	p, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.GetState(), check.Equals, provision.PoolStateDraining)
+}
+
+func (s *S) TestPoolUpdateStateHandlerInvalid(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		pool string
+		body string
+	}{
+		{"pool1", "state=gone"},
+		{"test1", "state=archived"},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		req, err := http.NewRequest("PUT", "/pools/"+tt.pool, bytes.NewBufferString(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Check(rec.Code, check.Equals, http.StatusBadRequest, check.Commentf("%s %s", tt.pool, tt.body))
+	}
+}
+
+func (s *S) TestAddPoolWithScheduler(c *check.C) {
+	b := strings.NewReader("name=pool1&scheduler.strategy=binpack&scheduler.maxunitspernode=10")
+	req, err := http.NewRequest("POST", "/pools", b)
 	c.Assert(err, check.IsNil)
-	err = provision.SetPoolConstraint(&provision.PoolConstraint{PoolExpr: "pool1", Field: "team", Values: []string{"*"}, Blacklist: true})
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated, check.Commentf("%s", rec.Body.String()))
+	pool, err := provision.GetPoolByName("pool1")
 	c.Assert(err, check.IsNil)
-	p, err := provision.GetPoolByName("pool1")
+	c.Assert(pool.Scheduler, check.DeepEquals, provision.PoolScheduler{
+		Strategy:        provision.SchedulerStrategyBinpack,
+		MaxUnitsPerNode: 10,
+	})
+}
+
+func (s *S) TestPoolUpdateSchedulerHandler(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
 	c.Assert(err, check.IsNil)
-	_, err = p.GetTeams()
-	c.Assert(err, check.NotNil)
-	b := bytes.NewBufferString("public=true")
+	b := strings.NewReader(`{"scheduler": {"antiAffinity": "required", "maxUnitsPerNode": 5}}`)
 	req, err := http.NewRequest("PUT", "/pools/pool1", b)
 	c.Assert(err, check.IsNil)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
 	rec := httptest.NewRecorder()
 	m := RunServer(true)
 	m.ServeHTTP(rec, req)
 	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	p, err := provision.GetPoolByName("pool1")
 	c.Assert(err, check.IsNil)
-	teams, err := p.GetTeams()
+	c.Assert(p.Scheduler, check.DeepEquals, provision.PoolScheduler{
+		AntiAffinity:    provision.AntiAffinityRequired,
+		MaxUnitsPerNode: 5,
+	})
+	req, err = http.NewRequest("PUT", "/pools/pool1", strings.NewReader(`{"scheduler": {"strategy": "random"}}`))
 	c.Assert(err, check.IsNil)
-	c.Assert(teams, check.DeepEquals, []string{"tsuruteam"})
-	c.Assert(eventtest.EventDesc{
-		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
-		Owner:  s.token.GetUserName(),
-		Kind:   "pool.update",
-		StartCustomData: []map[string]interface{}{
-			{"name": ":name", "value": "pool1"},
-			{"name": "public", "value": "true"},
-		},
-	}, eventtest.HasEvent)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, provision.ErrInvalidPoolScheduler.Error()+"\n")
 }
 
 func (s *S) TestPoolUpdateToDefaultPoolHandler(c *check.C) {
@@ -448,6 +1377,14 @@ func (s *S) TestPoolUpdateToDefaultPoolHandler(c *check.C) {
 	p, err := provision.GetPoolByName("pool1")
 	c.Assert(err, check.IsNil)
 	c.Assert(p.Default, check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update",
+		Changes: []event.Change{
+			{Field: "default", Before: false, After: true},
+		},
+	}, eventtest.HasEvent)
 }
 
 func (s *S) TestPoolUpdateOverwriteDefaultPoolHandler(c *check.C) {
@@ -523,6 +1460,45 @@ func (s *S) TestPoolUpdateProvisioner(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestPoolUpdateJSON(c *check.C) {
+	provision.RemovePool("test1")
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1", Default: true})
+	c.Assert(err, check.IsNil)
+	b := strings.NewReader(`{"provisioner": "myprov", "default": false, "public": true}`)
+	req, err := http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	p, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Provisioner, check.Equals, "myprov")
+	c.Assert(p.Default, check.Equals, false)
+	teams, err := p.GetTeams()
+	c.Assert(err, check.IsNil)
+	c.Assert(teams, check.DeepEquals, []string{"tsuruteam"})
+}
+
+func (s *S) TestPoolUpdateLabels(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1", Labels: map[string]string{"env": "dev", "region": "us"}})
+	c.Assert(err, check.IsNil)
+	b := strings.NewReader("labels.env=prod&labels.region=")
+	req, err := http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	p, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Labels, check.DeepEquals, map[string]string{"env": "prod"})
+}
+
 func (s *S) TestPoolUpdateNotFound(c *check.C) {
 	b := bytes.NewBufferString("public=true")
 	request, err := http.NewRequest("PUT", "/pools/not-found", b)
@@ -593,6 +1569,10 @@ func (s *S) TestPoolConstraintSet(c *check.C) {
 	}
 	constraints, err := provision.ListPoolsConstraints(nil)
 	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 2)
+	c.Assert(constraints[1].SetBy, check.Equals, s.token.GetUserName())
+	c.Assert(constraints[1].SetAt.IsZero(), check.Equals, false)
+	constraints[1].SetBy, constraints[1].SetAt = "", time.Time{}
 	c.Assert(constraints, check.DeepEquals, expected)
 	c.Assert(eventtest.EventDesc{
 		Target: event.Target{Type: event.TargetTypePool, Value: "*"},
@@ -632,6 +1612,10 @@ func (s *S) TestPoolConstraintSetAppend(c *check.C) {
 	}
 	constraints, err := provision.ListPoolsConstraints(nil)
 	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 2)
+	c.Assert(constraints[1].SetBy, check.Equals, s.token.GetUserName())
+	c.Assert(constraints[1].SetAt.IsZero(), check.Equals, false)
+	constraints[1].SetBy, constraints[1].SetAt = "", time.Time{}
 	c.Assert(constraints, check.DeepEquals, expected)
 	c.Assert(eventtest.EventDesc{
 		Target: event.Target{Type: event.TargetTypePool, Value: "*"},
@@ -648,6 +1632,133 @@ func (s *S) TestPoolConstraintSetAppend(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestPoolConstraintGet(c *check.C) {
+	err := provision.SetPoolConstraint(&provision.PoolConstraint{PoolExpr: "*", Field: "router", Values: []string{"*"}})
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolConstraint(&provision.PoolConstraint{PoolExpr: "dev*", Field: "router", Values: []string{"dev"}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/constraints/dev1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, request)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result poolConstraintsResult
+	err = json.NewDecoder(rec.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, poolConstraintsResult{
+		PoolExpr: "dev1",
+		Constraints: []*provision.PoolConstraint{
+			{PoolExpr: "dev*", Field: "router", Values: []string{"dev"}},
+			{PoolExpr: "*", Field: "router", Values: []string{"*"}},
+		},
+		Applied: map[string]*provision.PoolConstraint{
+			"router": {PoolExpr: "dev*", Field: "router", Values: []string{"dev"}},
+		},
+	})
+}
+
+func (s *S) TestPoolConstraintGetNoContent(c *check.C) {
+	request, err := http.NewRequest("GET", "/constraints/dev1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, request)
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestPoolConstraintResolve(c *check.C) {
+	err := s.conn.Platforms().Insert(app.Platform{Name: "python"})
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolConstraint(&provision.PoolConstraint{PoolExpr: "dev*", Field: "platform", Values: []string{"py*", "java"}})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("pool=dev1&field=platform")
+	request, err := http.NewRequest("POST", "/constraints/resolve", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, request)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result provision.ConstraintResolution
+	err = json.NewDecoder(rec.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	constraint := &provision.PoolConstraint{PoolExpr: "dev*", Field: "platform", Values: []string{"py*", "java"}}
+	c.Assert(result, check.DeepEquals, provision.ConstraintResolution{
+		Pool:      "dev1",
+		Field:     "platform",
+		Matching:  []*provision.PoolConstraint{constraint},
+		Applied:   constraint,
+		Allowed:   []string{"python"},
+		Denied:    []string{"zend"},
+		Unmatched: []string{"java"},
+	})
+}
+
+func (s *S) TestPoolConstraintResolveInvalidField(c *check.C) {
+	body := strings.NewReader("pool=dev1&field=size")
+	request, err := http.NewRequest("POST", "/constraints/resolve", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, request)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, provision.ErrInvalidConstraintType.Error()+"\n")
+}
+
+func (s *S) TestPoolConstraintRemove(c *check.C) {
+	err := provision.SetPoolConstraint(&provision.PoolConstraint{PoolExpr: "dev*", Field: "router", Values: []string{"dev"}})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("DELETE", "/constraints?poolExpr=dev*&field=router", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	constraints, err := provision.ListPoolsConstraints(bson.M{"poolexpr": "dev*"})
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "dev*"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.constraints.remove",
+		StartCustomData: []map[string]interface{}{
+			{"name": "poolExpr", "value": "dev*"},
+			{"name": "field", "value": "router"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestPoolConstraintRemoveNotFound(c *check.C) {
+	req, err := http.NewRequest("DELETE", "/constraints?poolExpr=dev*&field=router", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+	c.Assert(rec.Body.String(), check.Equals, provision.ErrPoolConstraintNotFound.Error()+"\n")
+}
+
+func (s *S) TestPoolConstraintRemoveInvalidField(c *check.C) {
+	req, err := http.NewRequest("DELETE", "/constraints?poolExpr=dev*&field=size", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, provision.ErrInvalidConstraintType.Error()+"\n")
+}
+
 func (s *S) TestPoolConstraintSetRequiresPoolExpr(c *check.C) {
 	req, err := http.NewRequest("PUT", "/constraints", bytes.NewBufferString(""))
 	c.Assert(err, check.IsNil)
@@ -659,3 +1770,174 @@ func (s *S) TestPoolConstraintSetRequiresPoolExpr(c *check.C) {
 	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
 	c.Assert(rec.Body.String(), check.Equals, "You must provide a Pool Expression\n")
 }
+
+func (s *S) TestPoolConstraintSetGlobalLayer(c *check.C) {
+	body := strings.NewReader("Layer=global&Field=router&Values.0=legacy&Blacklist=true")
+	req, err := http.NewRequest("PUT", "/constraints", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	constraints, err := provision.ListPoolsConstraints(bson.M{"layer": provision.ConstraintLayerGlobal})
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 1)
+	c.Assert(constraints[0].SetBy, check.Equals, s.token.GetUserName())
+	constraints[0].SetBy, constraints[0].SetAt = "", time.Time{}
+	c.Assert(constraints, check.DeepEquals, []*provision.PoolConstraint{
+		{Layer: provision.ConstraintLayerGlobal, Field: "router", Values: []string{"legacy"}, Blacklist: true},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "*"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.constraints.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Layer", "value": "global"},
+			{"name": "Field", "value": "router"},
+		},
+	}, eventtest.HasEvent)
+	request, err := http.NewRequest("GET", "/constraints/test1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, request)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	var result poolConstraintsResult
+	err = json.NewDecoder(rec.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Applied["router"].Values, check.DeepEquals, []string{"legacy"})
+	c.Assert(result.Applied["router"].Layer, check.Equals, provision.ConstraintLayerGlobal)
+}
+
+func (s *S) TestPoolConstraintSetInvalidLayer(c *check.C) {
+	body := strings.NewReader("PoolExpr=dev&Layer=team&Field=router&Values.0=planb")
+	req, err := http.NewRequest("PUT", "/constraints", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, provision.ErrInvalidConstraintLayer.Error()+"\n")
+}
+
+func (s *S) TestPoolConstraintSetInvalidRegex(c *check.C) {
+	body := strings.NewReader("PoolExpr=re:^prod-(&Field=router&Values.0=planb")
+	req, err := http.NewRequest("PUT", "/constraints", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, provision.ErrInvalidPoolExpr.Error()+"\n")
+}
+
+func (s *S) TestPoolConstraintHistory(c *check.C) {
+	body := strings.NewReader("PoolExpr=dev*&Field=router&Values.0=planb")
+	req, err := http.NewRequest("PUT", "/constraints", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	req, err = http.NewRequest("DELETE", "/constraints?poolExpr=dev*&field=router", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	req, err = http.NewRequest("GET", "/constraints/history?poolExpr=dev*", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var events []event.Event
+	err = json.NewDecoder(rec.Body).Decode(&events)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 2)
+	c.Assert(events[0].Kind.Name, check.Equals, "pool.update.constraints.remove")
+	c.Assert(events[1].Kind.Name, check.Equals, "pool.update.constraints.set")
+	c.Assert(events[1].Owner.Name, check.Equals, s.token.GetUserName())
+	req, err = http.NewRequest("GET", "/constraints/history?poolExpr=prod*", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestPoolConstraintHistoryRequiresPoolExpr(c *check.C) {
+	req, err := http.NewRequest("GET", "/constraints/history", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestPoolConstraintSetImpact(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "prod1"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Insert(bson.M{"name": "a1", "pool": "prod1", "router": "planb"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("PoolExpr=prod*&Field=router&Values.0=galeb&impact=true")
+	req, err := http.NewRequest("PUT", "/constraints", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var violations []provision.ConstraintViolation
+	err = json.NewDecoder(rec.Body).Decode(&violations)
+	c.Assert(err, check.IsNil)
+	c.Assert(violations, check.DeepEquals, []provision.ConstraintViolation{
+		{Kind: provision.ViolationKindApp, Name: "a1", Pool: "prod1", Value: "planb"},
+	})
+	constraints, err := provision.ListPoolsConstraints(bson.M{"field": "router"})
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 0)
+}
+
+func (s *S) TestPoolConstraintSetEnforce(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "prod1"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Insert(bson.M{"name": "a1", "pool": "prod1", "router": "planb"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("PoolExpr=prod*&Field=router&Values.0=galeb&enforce=true")
+	req, err := http.NewRequest("PUT", "/constraints", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusConflict)
+	c.Assert(rec.Body.String(), check.Matches, `.*app "a1" in pool "prod1" \(planb\).*`)
+	constraints, err := provision.ListPoolsConstraints(bson.M{"field": "router"})
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 0)
+	body = strings.NewReader("PoolExpr=prod*&Field=router&Values.0=galeb&Values.1=planb&enforce=true")
+	req, err = http.NewRequest("PUT", "/constraints", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	constraints, err = provision.ListPoolsConstraints(bson.M{"field": "router"})
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 1)
+}
@@ -5,7 +5,10 @@
 package api
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
@@ -19,16 +22,23 @@ import (
 	"github.com/tsuru/config"
 	apiRouter "github.com/tsuru/tsuru/api/router"
 	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/apiusage"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	_ "github.com/tsuru/tsuru/auth/native"
 	_ "github.com/tsuru/tsuru/auth/oauth"
 	_ "github.com/tsuru/tsuru/auth/saml"
 	"github.com/tsuru/tsuru/autoscale"
+	"github.com/tsuru/tsuru/autosleep"
+	"github.com/tsuru/tsuru/chaos"
+	"github.com/tsuru/tsuru/compliance"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/hc"
 	"github.com/tsuru/tsuru/healer"
+	"github.com/tsuru/tsuru/jobs"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/policy"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/nodecontainer"
 	"github.com/tsuru/tsuru/router"
@@ -112,6 +122,7 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Put", "/services/{service}/instances/{instance}/{app}", AuthorizationRequiredHandler(bindServiceInstance))
 	m.Add("1.0", "Delete", "/services/{service}/instances/{instance}/{app}", AuthorizationRequiredHandler(unbindServiceInstance))
 	m.Add("1.0", "Get", "/services/{service}/instances/{instance}/status", AuthorizationRequiredHandler(serviceInstanceStatus))
+	m.Add("1.4", "Get", "/services/{service}/instances/{instance}/status/stream", AuthorizationRequiredHandler(serviceInstanceStatusStream))
 	m.Add("1.0", "Put", "/services/{service}/instances/permission/{instance}/{team}", AuthorizationRequiredHandler(serviceInstanceGrantTeam))
 	m.Add("1.0", "Delete", "/services/{service}/instances/permission/{instance}/{team}", AuthorizationRequiredHandler(serviceInstanceRevokeTeam))
 
@@ -133,6 +144,37 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/apps/{app}", AuthorizationRequiredHandler(appInfo))
 	m.Add("1.0", "Post", "/apps/{app}/cname", AuthorizationRequiredHandler(setCName))
 	m.Add("1.0", "Delete", "/apps/{app}/cname", AuthorizationRequiredHandler(unsetCName))
+	m.Add("1.4", "Get", "/apps/{app}/cname/{cname}/verification", AuthorizationRequiredHandler(cnameVerificationInfo))
+	m.Add("1.4", "Post", "/apps/{app}/cname/{cname}/verification", AuthorizationRequiredHandler(cnameVerify))
+	m.Add("1.4", "Put", "/apps/{app}/deploy-status", AuthorizationRequiredHandler(appDeployStatusSet))
+	m.Add("1.4", "Delete", "/apps/{app}/deploy-status", AuthorizationRequiredHandler(appDeployStatusUnset))
+	m.Add("1.4", "Put", "/apps/{app}/deploy-priority", AuthorizationRequiredHandler(appDeployPrioritySet))
+	m.Add("1.4", "Delete", "/apps/{app}/deploy-priority", AuthorizationRequiredHandler(appDeployPriorityUnset))
+	m.Add("1.4", "Put", "/apps/{app}/auto-rollback", AuthorizationRequiredHandler(appAutoRollbackSet))
+	m.Add("1.4", "Put", "/apps/{app}/maintenance", AuthorizationRequiredHandler(appMaintenanceSet))
+	m.Add("1.4", "Delete", "/apps/{app}/maintenance", AuthorizationRequiredHandler(appMaintenanceRemove))
+	m.Add("1.4", "Put", "/apps/{app}/mirror", AuthorizationRequiredHandler(appMirrorSet))
+	m.Add("1.4", "Delete", "/apps/{app}/mirror", AuthorizationRequiredHandler(appMirrorRemove))
+	m.Add("1.4", "Get", "/apps/{app}/egress", AuthorizationRequiredHandler(egressRuleList))
+	m.Add("1.4", "Post", "/apps/{app}/egress", AuthorizationRequiredHandler(egressRuleAdd))
+	m.Add("1.4", "Delete", "/apps/{app}/egress", AuthorizationRequiredHandler(egressRuleRemove))
+	m.Add("1.4", "Get", "/apps/{app}/egress/blocked", AuthorizationRequiredHandler(egressBlockedList))
+	m.Add("1.4", "Put", "/apps/{app}/node-requirements", AuthorizationRequiredHandler(appNodeRequirementsSet))
+	m.Add("1.4", "Put", "/apps/{app}/preemption-tolerance", AuthorizationRequiredHandler(appPreemptionToleranceSet))
+	m.Add("1.4", "Post", "/apps/{app}/pool", AuthorizationRequiredHandler(appMoveToPool))
+	m.Add("1.4", "Get", "/apps/{app}/chaos", AuthorizationRequiredHandler(appChaosList))
+	m.Add("1.4", "Post", "/apps/{app}/chaos", AuthorizationRequiredHandler(appChaosRun))
+	m.Add("1.4", "Delete", "/apps/{app}/chaos/{id}", AuthorizationRequiredHandler(appChaosStop))
+	m.Add("1.4", "Get", "/apps/{app}/timeline", AuthorizationRequiredHandler(appTimeline))
+	m.Add("1.4", "Get", "/apps/{app}/deploys/stats", AuthorizationRequiredHandler(appDeployStats))
+	m.Add("1.4", "Post", "/apps/{app}/annotations", AuthorizationRequiredHandler(appAnnotate))
+	m.Add("1.4", "Put", "/apps/{app}/process-settings", AuthorizationRequiredHandler(appProcessSettingsSet))
+	m.Add("1.4", "Put", "/apps/{app}/metrics", AuthorizationRequiredHandler(appMetricsSet))
+	m.Add("1.4", "Get", "/metrics-targets", AuthorizationRequiredHandler(metricsTargetsList))
+	m.Add("1.4", "Get", "/apps/{app}/config-templates", AuthorizationRequiredHandler(configTemplateList))
+	m.Add("1.4", "Put", "/apps/{app}/config-templates", AuthorizationRequiredHandler(configTemplateSet))
+	m.Add("1.4", "Post", "/apps/{app}/config-templates/preview", AuthorizationRequiredHandler(configTemplatePreview))
+	m.Add("1.4", "Delete", "/apps/{app}/config-templates/{name}", AuthorizationRequiredHandler(configTemplateRemove))
 	runHandler := AuthorizationRequiredHandler(runCommand)
 	m.Add("1.0", "Post", "/apps/{app}/run", runHandler)
 	m.Add("1.0", "Post", "/apps/{app}/restart", AuthorizationRequiredHandler(restart))
@@ -145,6 +187,7 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/apps/{app}/env", AuthorizationRequiredHandler(getEnv))
 	m.Add("1.0", "Post", "/apps/{app}/env", AuthorizationRequiredHandler(setEnv))
 	m.Add("1.0", "Delete", "/apps/{app}/env", AuthorizationRequiredHandler(unsetEnv))
+	m.Add("1.4", "Post", "/envs/rotate", AuthorizationRequiredHandler(envRotate))
 	m.Add("1.0", "Get", "/apps", AuthorizationRequiredHandler(appList))
 	m.Add("1.0", "Post", "/apps", AuthorizationRequiredHandler(createApp))
 	forceDeleteLockHandler := AuthorizationRequiredHandler(forceDeleteLock)
@@ -153,6 +196,8 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Delete", "/apps/{app}/units", AuthorizationRequiredHandler(removeUnits))
 	registerUnitHandler := AuthorizationRequiredHandler(registerUnit)
 	m.Add("1.0", "Post", "/apps/{app}/units/register", registerUnitHandler)
+	m.Add("1.4", "Get", "/apps/{app}/units/disk-usage", AuthorizationRequiredHandler(appUnitsDiskUsage))
+	m.Add("1.4", "Get", "/apps/{app}/provisioner-events", AuthorizationRequiredHandler(appProvisionerEvents))
 	setUnitStatusHandler := AuthorizationRequiredHandler(setUnitStatus)
 	m.Add("1.0", "Post", "/apps/{app}/units/{unit}", setUnitStatusHandler)
 	m.Add("1.0", "Put", "/apps/{app}/teams/{team}", AuthorizationRequiredHandler(grantAppAccess))
@@ -162,6 +207,7 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Post", "/apps/{app}/log", logPostHandler)
 	m.Add("1.0", "Post", "/apps/{appname}/deploy/rollback", AuthorizationRequiredHandler(deployRollback))
 	m.Add("1.3", "Post", "/apps/{appname}/deploy/rebuild", AuthorizationRequiredHandler(deployRebuild))
+	m.Add("1.4", "Post", "/apps/{appname}/deploy/promote", AuthorizationRequiredHandler(deployPromote))
 	m.Add("1.0", "Get", "/apps/{app}/metric/envs", AuthorizationRequiredHandler(appMetricEnvs))
 	m.Add("1.0", "Post", "/apps/{app}/routes", AuthorizationRequiredHandler(appRebuildRoutes))
 	m.Add("1.2", "Get", "/apps/{app}/certificate", AuthorizationRequiredHandler(listCertificates))
@@ -171,26 +217,38 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Post", "/node/status", AuthorizationRequiredHandler(setNodeStatus))
 
 	m.Add("1.0", "Get", "/deploys", AuthorizationRequiredHandler(deploysList))
+	m.Add("1.4", "Get", "/deploys/queue", AuthorizationRequiredHandler(deployQueueList))
 	m.Add("1.0", "Get", "/deploys/{deploy}", AuthorizationRequiredHandler(deployInfo))
 
 	m.Add("1.1", "Get", "/events", AuthorizationRequiredHandler(eventList))
 	m.Add("1.3", "Get", "/events/blocks", AuthorizationRequiredHandler(eventBlockList))
 	m.Add("1.3", "Post", "/events/blocks", AuthorizationRequiredHandler(eventBlockAdd))
 	m.Add("1.3", "Delete", "/events/blocks/{uuid}", AuthorizationRequiredHandler(eventBlockRemove))
+	m.Add("1.4", "Get", "/events/retention-rules", AuthorizationRequiredHandler(eventRetentionRuleList))
+	m.Add("1.4", "Post", "/events/retention-rules", AuthorizationRequiredHandler(eventRetentionRuleSet))
+	m.Add("1.4", "Delete", "/events/retention-rules/{name}", AuthorizationRequiredHandler(eventRetentionRuleRemove))
+	m.Add("1.4", "Get", "/events/webhooks", AuthorizationRequiredHandler(eventWebhookList))
+	m.Add("1.4", "Post", "/events/webhooks", AuthorizationRequiredHandler(eventWebhookSet))
+	m.Add("1.4", "Delete", "/events/webhooks/{name}", AuthorizationRequiredHandler(eventWebhookRemove))
+	m.Add("1.4", "Get", "/events/webhooks/{name}/deliveries", AuthorizationRequiredHandler(eventWebhookDeliveries))
 	m.Add("1.1", "Get", "/events/kinds", AuthorizationRequiredHandler(kindList))
+	m.Add("1.4", "Get", "/events/stream", AuthorizationRequiredHandler(eventStream))
 	m.Add("1.1", "Get", "/events/{uuid}", AuthorizationRequiredHandler(eventInfo))
 	m.Add("1.1", "Post", "/events/{uuid}/cancel", AuthorizationRequiredHandler(eventCancel))
 
 	m.Add("1.0", "Get", "/platforms", AuthorizationRequiredHandler(platformList))
-	m.Add("1.0", "Post", "/platforms", AuthorizationRequiredHandler(platformAdd))
-	m.Add("1.0", "Put", "/platforms/{name}", AuthorizationRequiredHandler(platformUpdate))
+	platformAddHandler := AuthorizationRequiredHandler(platformAdd)
+	m.Add("1.0", "Post", "/platforms", platformAddHandler)
+	platformUpdateHandler := AuthorizationRequiredHandler(platformUpdate)
+	m.Add("1.0", "Put", "/platforms/{name}", platformUpdateHandler)
 	m.Add("1.0", "Delete", "/platforms/{name}", AuthorizationRequiredHandler(platformRemove))
 
 	// These handlers don't use {app} on purpose. Using :app means that only
 	// the token generate for the given app is valid, but these handlers
 	// use a token generated for Gandalf.
-	m.Add("1.0", "Post", "/apps/{appname}/repository/clone", AuthorizationRequiredHandler(deploy))
-	m.Add("1.0", "Post", "/apps/{appname}/deploy", AuthorizationRequiredHandler(deploy))
+	deployHandler := AuthorizationRequiredHandler(deploy)
+	m.Add("1.0", "Post", "/apps/{appname}/repository/clone", deployHandler)
+	m.Add("1.0", "Post", "/apps/{appname}/deploy", deployHandler)
 	diffDeployHandler := AuthorizationRequiredHandler(diffDeploy)
 	m.Add("1.0", "Post", "/apps/{appname}/diff", diffDeployHandler)
 
@@ -200,7 +258,10 @@ func RunServer(dry bool) http.Handler {
 
 	m.Add("1.0", "Get", "/users", AuthorizationRequiredHandler(listUsers))
 	m.Add("1.0", "Post", "/users", Handler(createUser))
+	m.Add("1.4", "Post", "/bootstrap", Handler(bootstrapHandler))
 	m.Add("1.0", "Get", "/users/info", AuthorizationRequiredHandler(userInfo))
+	m.Add("1.4", "Get", "/users/me/settings", AuthorizationRequiredHandler(userSettings))
+	m.Add("1.4", "Put", "/users/me/settings", AuthorizationRequiredHandler(updateUserSettings))
 	m.Add("1.0", "Get", "/auth/scheme", Handler(authScheme))
 	m.Add("1.0", "Post", "/auth/login", Handler(login))
 
@@ -219,12 +280,32 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Delete", "/users/keys/{key}", AuthorizationRequiredHandler(removeKeyFromUser))
 	m.Add("1.0", "Get", "/users/api-key", AuthorizationRequiredHandler(showAPIToken))
 	m.Add("1.0", "Post", "/users/api-key", AuthorizationRequiredHandler(regenerateAPIToken))
+	m.Add("1.4", "Post", "/users/{email}/impersonate", AuthorizationRequiredHandler(impersonateUser))
+	m.Add("1.4", "Delete", "/users/impersonate", AuthorizationRequiredHandler(revokeImpersonation))
 
 	m.Add("1.0", "Get", "/logs", websocket.Handler(addLogs))
 
 	m.Add("1.0", "Get", "/teams", AuthorizationRequiredHandler(teamList))
 	m.Add("1.0", "Post", "/teams", AuthorizationRequiredHandler(createTeam))
 	m.Add("1.0", "Delete", "/teams/{name}", AuthorizationRequiredHandler(removeTeam))
+	m.Add("1.4", "Put", "/teams/{name}/deploy-status", AuthorizationRequiredHandler(teamDeployStatusSet))
+	m.Add("1.4", "Delete", "/teams/{name}/deploy-status", AuthorizationRequiredHandler(teamDeployStatusUnset))
+	m.Add("1.4", "Put", "/teams/{name}/deploy-priority", AuthorizationRequiredHandler(teamDeployPrioritySet))
+	m.Add("1.4", "Delete", "/teams/{name}/deploy-priority", AuthorizationRequiredHandler(teamDeployPriorityUnset))
+	m.Add("1.4", "Get", "/teams/{name}/api-quota", AuthorizationRequiredHandler(teamAPIQuotaGet))
+	m.Add("1.4", "Put", "/teams/{name}/api-quota", AuthorizationRequiredHandler(teamAPIQuotaSet))
+	m.Add("1.4", "Delete", "/teams/{name}/api-quota", AuthorizationRequiredHandler(teamAPIQuotaUnset))
+	m.Add("1.4", "Get", "/api-usage", AuthorizationRequiredHandler(apiUsageReport))
+
+	m.Add("1.4", "Get", "/organizations", AuthorizationRequiredHandler(organizationList))
+	m.Add("1.4", "Post", "/organizations", AuthorizationRequiredHandler(organizationCreate))
+	m.Add("1.4", "Get", "/organizations/{name}", AuthorizationRequiredHandler(organizationInfo))
+	m.Add("1.4", "Delete", "/organizations/{name}", AuthorizationRequiredHandler(organizationDelete))
+	m.Add("1.4", "Post", "/organizations/{name}/teams", AuthorizationRequiredHandler(organizationAddTeam))
+	m.Add("1.4", "Delete", "/organizations/{name}/teams/{team}", AuthorizationRequiredHandler(organizationRemoveTeam))
+	m.Add("1.4", "Post", "/organizations/{name}/pools", AuthorizationRequiredHandler(organizationAddPool))
+	m.Add("1.4", "Delete", "/organizations/{name}/pools/{pool}", AuthorizationRequiredHandler(organizationRemovePool))
+	m.Add("1.4", "Put", "/organizations/{name}/quota", AuthorizationRequiredHandler(organizationChangeQuota))
 
 	m.Add("1.0", "Post", "/swap", AuthorizationRequiredHandler(swap))
 
@@ -242,15 +323,62 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Post", "/plans", AuthorizationRequiredHandler(addPlan))
 	m.Add("1.0", "Delete", "/plans/{planname}", AuthorizationRequiredHandler(removePlan))
 
+	m.Add("1.4", "Get", "/hardware-profiles", AuthorizationRequiredHandler(listHardwareProfiles))
+	m.Add("1.4", "Post", "/hardware-profiles", AuthorizationRequiredHandler(addHardwareProfile))
+	m.Add("1.4", "Delete", "/hardware-profiles/{name}", AuthorizationRequiredHandler(removeHardwareProfile))
+
+	m.Add("1.4", "Get", "/constraint-templates", AuthorizationRequiredHandler(listConstraintTemplates))
+	m.Add("1.4", "Post", "/constraint-templates", AuthorizationRequiredHandler(addConstraintTemplate))
+	m.Add("1.4", "Delete", "/constraint-templates/{name}", AuthorizationRequiredHandler(removeConstraintTemplate))
+
+	m.Add("1.4", "Get", "/deploy-freezes", AuthorizationRequiredHandler(listDeployFreezes))
+	m.Add("1.4", "Post", "/deploy-freezes", AuthorizationRequiredHandler(addDeployFreeze))
+	m.Add("1.4", "Delete", "/deploy-freezes/{name}", AuthorizationRequiredHandler(removeDeployFreeze))
+	m.Add("1.4", "Get", "/deploy-freezes/{name}/exceptions", AuthorizationRequiredHandler(listDeployFreezeExceptions))
+	m.Add("1.4", "Post", "/deploy-freezes/{name}/exceptions", AuthorizationRequiredHandler(requestDeployFreezeException))
+	m.Add("1.4", "Post", "/deploy-freezes/{name}/exceptions/{id}", AuthorizationRequiredHandler(reviewDeployFreezeException))
+
+	m.Add("1.4", "Get", "/jobs", AuthorizationRequiredHandler(listJobs))
+	m.Add("1.4", "Get", "/jobs/{id}", AuthorizationRequiredHandler(jobInfo))
+	m.Add("1.4", "Post", "/jobs/{id}/retry", AuthorizationRequiredHandler(retryJob))
+
+	m.Add("1.4", "Get", "/compliance/reports", AuthorizationRequiredHandler(listComplianceReports))
+	m.Add("1.4", "Post", "/compliance/reports", AuthorizationRequiredHandler(createComplianceReport))
+	m.Add("1.4", "Get", "/compliance/reports/compare", AuthorizationRequiredHandler(compareComplianceReports))
+	m.Add("1.4", "Get", "/compliance/reports/{id}", AuthorizationRequiredHandler(complianceReportInfo))
+
 	m.Add("1.0", "Get", "/pools", AuthorizationRequiredHandler(poolList))
 	m.Add("1.0", "Post", "/pools", AuthorizationRequiredHandler(addPoolHandler))
+	m.Add("1.4", "Get", "/pools/compare", AuthorizationRequiredHandler(poolCompare))
+	m.Add("1.4", "Get", "/pools/{name}", AuthorizationRequiredHandler(poolInfo))
+	m.Add("1.4", "Get", "/pools/{name}/health", AuthorizationRequiredHandler(poolHealthHandler))
 	m.Add("1.0", "Delete", "/pools/{name}", AuthorizationRequiredHandler(removePoolHandler))
 	m.Add("1.0", "Put", "/pools/{name}", AuthorizationRequiredHandler(poolUpdateHandler))
+	m.Add("1.4", "Post", "/pools/{name}/rename", AuthorizationRequiredHandler(poolRenameHandler))
+	m.Add("1.4", "Post", "/pools/{name}/migrate", AuthorizationRequiredHandler(poolMigrateHandler))
+	m.Add("1.4", "Put", "/pools/{name}/env", AuthorizationRequiredHandler(poolEnvSetHandler))
+	m.Add("1.4", "Put", "/pools/{name}/quota", AuthorizationRequiredHandler(poolQuotaSetHandler))
 	m.Add("1.0", "Post", "/pools/{name}/team", AuthorizationRequiredHandler(addTeamToPoolHandler))
 	m.Add("1.0", "Delete", "/pools/{name}/team", AuthorizationRequiredHandler(removeTeamToPoolHandler))
+	m.Add("1.4", "Put", "/pools/{name}/teams", AuthorizationRequiredHandler(setPoolTeamsHandler))
+
+	m.Add("1.4", "Get", "/tags", AuthorizationRequiredHandler(tagList))
+	m.Add("1.4", "Get", "/apps/{app}/tags", AuthorizationRequiredHandler(objectTags))
+	m.Add("1.4", "Put", "/apps/{app}/tags", AuthorizationRequiredHandler(objectTagsAdd))
+	m.Add("1.4", "Delete", "/apps/{app}/tags", AuthorizationRequiredHandler(objectTagsRemove))
+	m.Add("1.4", "Get", "/pools/{name}/tags", AuthorizationRequiredHandler(objectTags))
+	m.Add("1.4", "Put", "/pools/{name}/tags", AuthorizationRequiredHandler(objectTagsAdd))
+	m.Add("1.4", "Delete", "/pools/{name}/tags", AuthorizationRequiredHandler(objectTagsRemove))
+	m.Add("1.4", "Get", "/services/{service}/instances/{instance}/tags", AuthorizationRequiredHandler(objectTags))
+	m.Add("1.4", "Put", "/services/{service}/instances/{instance}/tags", AuthorizationRequiredHandler(objectTagsAdd))
+	m.Add("1.4", "Delete", "/services/{service}/instances/{instance}/tags", AuthorizationRequiredHandler(objectTagsRemove))
 
 	m.Add("1.3", "Get", "/constraints", AuthorizationRequiredHandler(poolConstraintList))
 	m.Add("1.3", "Put", "/constraints", AuthorizationRequiredHandler(poolConstraintSet))
+	m.Add("1.4", "Delete", "/constraints", AuthorizationRequiredHandler(poolConstraintRemove))
+	m.Add("1.4", "Get", "/constraints/history", AuthorizationRequiredHandler(poolConstraintHistory))
+	m.Add("1.4", "Get", "/constraints/{poolExpr}", AuthorizationRequiredHandler(poolConstraintGet))
+	m.Add("1.4", "Post", "/constraints/resolve", AuthorizationRequiredHandler(poolConstraintResolve))
 
 	m.Add("1.0", "Get", "/roles", AuthorizationRequiredHandler(listRoles))
 	m.Add("1.0", "Post", "/roles", AuthorizationRequiredHandler(addRole))
@@ -291,6 +419,8 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.2", "PUT", "/node", AuthorizationRequiredHandler(updateNodeHandler))
 	m.Add("1.2", "DELETE", "/node/{address:.*}", AuthorizationRequiredHandler(removeNodeHandler))
 	m.Add("1.3", "POST", "/node/rebalance", AuthorizationRequiredHandler(rebalanceNodesHandler))
+	m.Add("1.4", "POST", "/node/{address:.*}/evacuate", AuthorizationRequiredHandler(evacuateNodeHandler))
+	m.Add("1.4", "GET", "/node/{address:.*}/diagnostics", AuthorizationRequiredHandler(nodeDiagnosticsHandler))
 
 	m.Add("1.2", "GET", "/nodecontainers", AuthorizationRequiredHandler(nodeContainerList))
 	m.Add("1.2", "POST", "/nodecontainers", AuthorizationRequiredHandler(nodeContainerCreate))
@@ -306,6 +436,7 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.2", "GET", "/healing/node", AuthorizationRequiredHandler(nodeHealingRead))
 	m.Add("1.2", "POST", "/healing/node", AuthorizationRequiredHandler(nodeHealingUpdate))
 	m.Add("1.2", "DELETE", "/healing/node", AuthorizationRequiredHandler(nodeHealingDelete))
+	m.Add("1.4", "GET", "/healing/node/risk", AuthorizationRequiredHandler(nodeHealingRisk))
 	m.Add("1.3", "GET", "/healing", AuthorizationRequiredHandler(healingHistoryHandler))
 	m.Add("1.3", "GET", "/routers", AuthorizationRequiredHandler(listRouters))
 	m.Add("1.2", "GET", "/metrics", promhttp.Handler())
@@ -351,12 +482,19 @@ func RunServer(dry bool) http.Handler {
 	if !dry {
 		n.Use(newLoggerMiddleware())
 	}
+	n.Use(negroni.HandlerFunc(corsMiddleware))
+	n.Use(negroni.HandlerFunc(forwardedPrefixMiddleware))
 	n.UseHandler(m)
 	n.Use(negroni.HandlerFunc(flushingWriterMiddleware))
 	n.Use(negroni.HandlerFunc(setRequestIDHeaderMiddleware))
 	n.Use(negroni.HandlerFunc(errorHandlingMiddleware))
 	n.Use(negroni.HandlerFunc(setVersionHeadersMiddleware))
+	n.Use(&bodyLimitMiddleware{groups: map[string][]http.Handler{
+		"deploy":   {deployHandler},
+		"platform": {platformAddHandler, platformUpdateHandler},
+	}})
 	n.Use(negroni.HandlerFunc(authTokenMiddleware))
+	n.Use(negroni.HandlerFunc(apiUsageMiddleware))
 	n.Use(&appLockMiddleware{excludedHandlers: []http.Handler{
 		logPostHandler,
 		runHandler,
@@ -373,6 +511,24 @@ func RunServer(dry bool) http.Handler {
 	return n
 }
 
+// clientCertTLSConfig verifies the client certificates signed by the CAs in
+// the given file, used by the client-cert token resolver. Clients without
+// certificates are still accepted.
+func clientCertTLSConfig(caFile string) (*tls.Config, error) {
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %q", caFile)
+	}
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+	}, nil
+}
+
 func appFinder(appName string) (rebuild.RebuildApp, error) {
 	a, err := app.GetByName(appName)
 	if err == app.ErrAppNotFound {
@@ -476,6 +632,14 @@ func startServer(handler http.Handler) {
 		fatal(err)
 	}
 	fmt.Printf("Using %q auth scheme.\n", scheme)
+	_, err = auth.TokenResolverChain()
+	if err != nil {
+		fatal(err)
+	}
+	_, err = policy.GetEngine()
+	if err != nil {
+		fatal(err)
+	}
 	_, err = nodecontainer.InitializeBS(app.AuthScheme, app.InternalAppName)
 	if err != nil {
 		fatal(err)
@@ -492,6 +656,30 @@ func startServer(handler http.Handler) {
 	if err != nil {
 		fatal(err)
 	}
+	err = autosleep.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	err = chaos.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	err = jobs.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	err = apiusage.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	err = compliance.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	err = event.StartPruner()
+	if err != nil {
+		fatal(err)
+	}
 	fmt.Println("Checking components status:")
 	results := hc.Check()
 	for _, result := range results {
@@ -514,6 +702,13 @@ func startServer(handler http.Handler) {
 		if err != nil {
 			fatal(err)
 		}
+		clientCAFile, _ := config.GetString("tls:client-ca-file")
+		if clientCAFile != "" {
+			srv.Server.TLSConfig, err = clientCertTLSConfig(clientCAFile)
+			if err != nil {
+				fatal(err)
+			}
+		}
 		fmt.Printf("tsuru HTTP/TLS server listening at %s...\n", listen)
 		err = srv.ListenAndServeTLS(certFile, keyFile)
 	} else {
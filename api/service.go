@@ -297,7 +297,7 @@ func grantServiceAccess(w http.ResponseWriter, r *http.Request, t auth.Token) (e
 	team, err := auth.GetTeam(teamName)
 	if err != nil {
 		if err == auth.ErrTeamNotFound {
-			return &errors.HTTP{Code: http.StatusBadRequest, Message: "Team not found"}
+			return newCodedError(r, http.StatusBadRequest, errors.ErrorCodeTeamNotFound, "Team not found")
 		}
 		return err
 	}
@@ -345,7 +345,7 @@ func revokeServiceAccess(w http.ResponseWriter, r *http.Request, t auth.Token) (
 	team, err := auth.GetTeam(teamName)
 	if err != nil {
 		if err == auth.ErrTeamNotFound {
-			return &errors.HTTP{Code: http.StatusBadRequest, Message: "Team not found"}
+			return newCodedError(r, http.StatusBadRequest, errors.ErrorCodeTeamNotFound, "Team not found")
 		}
 		return err
 	}
@@ -22,6 +22,7 @@ import (
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/naming"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/service"
 )
@@ -63,6 +64,7 @@ func createServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 		TeamOwner:   r.FormValue("owner"),
 		Description: r.FormValue("description"),
 		Tags:        r.Form["tag"],
+		Pool:        r.FormValue("pool"),
 	}
 	var teamOwner string
 	if instance.TeamOwner == "" {
@@ -107,12 +109,18 @@ func createServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 			Message: err.Error(),
 		}
 	}
-	if err == service.ErrInvalidInstanceName {
+	if _, ok := err.(*naming.InvalidNameError); ok {
 		return &tsuruErrors.HTTP{
 			Code:    http.StatusBadRequest,
 			Message: err.Error(),
 		}
 	}
+	if e, ok := err.(*tsuruErrors.ValidationError); ok {
+		return &tsuruErrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: e.Message,
+		}
+	}
 	if err == nil {
 		w.WriteHeader(http.StatusCreated)
 	}
@@ -256,7 +264,7 @@ func removeServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 	return nil
 }
 
-func readableInstances(t auth.Token, contexts []permission.PermissionContext, appName, serviceName string) ([]service.ServiceInstance, error) {
+func readableInstances(t auth.Token, contexts []permission.PermissionContext, filter service.ServiceInstanceFilter) ([]service.ServiceInstance, error) {
 	teams := []string{}
 	instanceNames := []string{}
 	for _, c := range contexts {
@@ -268,14 +276,27 @@ func readableInstances(t auth.Token, contexts []permission.PermissionContext, ap
 		switch c.CtxType {
 		case permission.CtxServiceInstance:
 			parts := strings.SplitN(c.Value, "/", 2)
-			if len(parts) == 2 && (serviceName == "" || parts[0] == serviceName) {
+			if len(parts) == 2 && (filter.ServiceName == "" || parts[0] == filter.ServiceName) {
 				instanceNames = append(instanceNames, parts[1])
 			}
 		case permission.CtxTeam:
 			teams = append(teams, c.Value)
 		}
 	}
-	return service.GetServicesInstancesByTeamsAndNames(teams, instanceNames, appName, serviceName)
+	return service.GetServicesInstancesByTeamsAndNames(teams, instanceNames, filter)
+}
+
+// serviceInstanceFilterFromRequest reads the app, team, plan and tag query
+// string parameters used to filter service instances listings. The tag
+// parameter may be repeated, matching instances with all the given tags.
+func serviceInstanceFilterFromRequest(r *http.Request) service.ServiceInstanceFilter {
+	query := r.URL.Query()
+	return service.ServiceInstanceFilter{
+		AppName:   query.Get("app"),
+		TeamOwner: query.Get("team"),
+		PlanName:  query.Get("plan"),
+		Tags:      query["tag"],
+	}
 }
 
 func filtersForServiceList(t auth.Token, contexts []permission.PermissionContext) ([]string, []string) {
@@ -311,9 +332,9 @@ func readableServices(t auth.Token, contexts []permission.PermissionContext) ([]
 //   204: No content
 //   401: Unauthorized
 func serviceInstances(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	appName := r.URL.Query().Get("app")
+	filter := serviceInstanceFilterFromRequest(r)
 	contexts := permission.ContextsForPermission(t, permission.PermServiceInstanceRead)
-	instances, err := readableInstances(t, contexts, appName, "")
+	instances, err := readableInstances(t, contexts, filter)
 	if err != nil {
 		return err
 	}
@@ -382,6 +403,53 @@ func serviceInstanceStatus(w http.ResponseWriter, r *http.Request, t auth.Token)
 	return err
 }
 
+// title: service instance status stream
+// path: /services/{service}/instances/{instance}/status/stream
+// method: GET
+// produce: application/x-json-stream
+// responses:
+//   200: Status streamed
+//   401: Unauthorized
+//   404: Service instance not found
+func serviceInstanceStatusStream(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	instanceName := r.URL.Query().Get(":instance")
+	serviceName := r.URL.Query().Get(":service")
+	serviceInstance, err := getServiceInstanceOrError(serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermServiceInstanceReadStatus,
+		contextsForServiceInstance(serviceInstance, serviceName)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     serviceInstanceTarget(serviceName, instanceName),
+		Kind:       permission.PermServiceInstanceReadStatus,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed: event.Allowed(permission.PermServiceInstanceReadEvents,
+			contextsForServiceInstance(serviceInstance, serviceName)...),
+	})
+	if err != nil {
+		return err
+	}
+	var status service.InstanceStatus
+	defer func() { evt.DoneCustomData(err, status) }()
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	evt.SetLogWriter(writer)
+	requestIDHeader, _ := config.GetString("request-id-header")
+	requestID := context.GetRequestID(r, requestIDHeader)
+	interval, timeout := service.StatusStreamOptions()
+	status, err = serviceInstance.StreamStatus(evt, requestID, interval, timeout)
+	return err
+}
+
 type serviceInstanceInfo struct {
 	Apps            []string
 	Teams           []string
@@ -391,6 +459,7 @@ type serviceInstanceInfo struct {
 	PlanDescription string
 	CustomInfo      map[string]string
 	Tags            []string
+	Pool            string
 }
 
 // title: service instance info
@@ -433,6 +502,7 @@ func serviceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) error
 		PlanDescription: plan.Description,
 		CustomInfo:      info,
 		Tags:            serviceInstance.Tags,
+		Pool:            serviceInstance.Pool,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(sInfo)
@@ -450,8 +520,10 @@ func serviceInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
+	filter := serviceInstanceFilterFromRequest(r)
+	filter.ServiceName = serviceName
 	contexts := permission.ContextsForPermission(t, permission.PermServiceInstanceRead)
-	instances, err := readableInstances(t, contexts, "", serviceName)
+	instances, err := readableInstances(t, contexts, filter)
 	if err != nil {
 		return err
 	}
@@ -238,6 +238,21 @@ func (s *ServiceInstanceSuite) TestCreateInstanceInvalidName(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, service.ErrInvalidInstanceName.Error()+"\n")
 }
 
+func (s *ServiceInstanceSuite) TestCreateInstanceInvalidPool(c *check.C) {
+	params := map[string]interface{}{
+		"name":         "brainSQL",
+		"service_name": "mysql",
+		"owner":        s.team.Name,
+		"pool":         "unknown",
+		"token":        "bearer " + s.token.GetValue(),
+	}
+	m := RunServer(true)
+	recorder, request := makeRequestToCreateServiceInstance(params, c)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, provision.ErrPoolNotFound.Error()+"\n")
+}
+
 func (s *ServiceInstanceSuite) TestCreateInstanceNameAlreadyExists(c *check.C) {
 	params := map[string]interface{}{
 		"name":         "brainSQL",
@@ -1106,6 +1121,35 @@ func (s *ServiceInstanceSuite) TestListServiceInstancesAppFilter(c *check.C) {
 	c.Assert(instances, check.DeepEquals, expected)
 }
 
+func (s *ServiceInstanceSuite) TestListServiceInstancesTagTeamAndPlanFilter(c *check.C) {
+	err := s.conn.Services().RemoveId(s.service.Name)
+	c.Assert(err, check.IsNil)
+	srv := service.Service{Name: "redis", Teams: []string{s.team.Name}}
+	err = srv.Create()
+	c.Assert(err, check.IsNil)
+	instances := []service.ServiceInstance{
+		{Name: "redis-prod", ServiceName: "redis", PlanName: "large", TeamOwner: s.team.Name, Teams: []string{s.team.Name}, Tags: []string{"env=prod"}},
+		{Name: "redis-prod-small", ServiceName: "redis", PlanName: "small", TeamOwner: s.team.Name, Teams: []string{s.team.Name}, Tags: []string{"env=prod"}},
+		{Name: "redis-dev", ServiceName: "redis", PlanName: "large", TeamOwner: s.team.Name, Teams: []string{s.team.Name}, Tags: []string{"env=dev"}},
+	}
+	for _, instance := range instances {
+		err = instance.Create()
+		c.Assert(err, check.IsNil)
+	}
+	request, err := http.NewRequest("GET", "/services/instances?tag=env%3Dprod&plan=large&team="+s.team.Name, nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	err = serviceInstances(recorder, request, s.token)
+	c.Assert(err, check.IsNil)
+	var result []service.ServiceModel
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	expected := []service.ServiceModel{
+		{Service: "redis", Instances: []string{"redis-prod"}, Plans: []string{"large"}},
+	}
+	c.Assert(result, check.DeepEquals, expected)
+}
+
 func (s *ServiceInstanceSuite) TestListServiceInstancesReturnsOnlyServicesThatTheUserHasAccess(c *check.C) {
 	err := s.conn.Services().RemoveId(s.service.Name)
 	c.Assert(err, check.IsNil)
@@ -1207,6 +1251,44 @@ func (s *ServiceInstanceSuite) TestServiceInstanceStatus(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, "Service instance \"my_nosql\" is up")
 }
 
+func (s *ServiceInstanceSuite) TestServiceInstanceStatusStream(c *check.C) {
+	config.Set("services:status-stream:interval", "1ms")
+	defer config.Unset("services:status-stream:interval")
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("creating database"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	srv := service.Service{Name: "mongodb", OwnerTeams: []string{s.team.Name}, Endpoint: map[string]string{"production": ts.URL}}
+	err := srv.Create()
+	c.Assert(err, check.IsNil)
+	si := service.ServiceInstance{Name: "my_nosql", ServiceName: srv.Name, Teams: []string{s.team.Name}}
+	err = si.Create()
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/services/mongodb/instances/my_nosql/status/stream", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(calls, check.Equals, 3)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Service instance \\"my_nosql\\" is pending: creating database.*Service instance \\"my_nosql\\" is up.*`)
+	c.Assert(eventtest.EventDesc{
+		Target:        serviceInstanceTarget("mongodb", "my_nosql"),
+		Owner:         s.token.GetUserName(),
+		Kind:          "service-instance.read.status",
+		EndCustomData: map[string]interface{}{"status": "up"},
+		LogMatches:    `(?s)Service instance "my_nosql" is pending: creating database.*Service instance "my_nosql" is up`,
+	}, eventtest.HasEvent)
+}
+
 func (s *ServiceInstanceSuite) TestServiceInstanceStatusWithSameInstanceName(c *check.C) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
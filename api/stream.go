@@ -0,0 +1,103 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	tsuruIo "github.com/tsuru/tsuru/io"
+)
+
+type stopWriter interface {
+	io.Writer
+	Stop()
+}
+
+// outputStream is the writer used by endpoints streaming the output of long
+// running operations. By default the output is sent as is, either as plain
+// text or as a stream of SimpleJsonMessages, but when the request includes the
+// output=json query string parameter the output is converted to structured
+// JSON frames, which are easier to consume by clients other than the tsuru
+// client.
+type outputStream struct {
+	keepAlive stopWriter
+	frames    *tsuruIo.FrameWriter
+	writer    io.Writer
+}
+
+func frameOutputRequested(r *http.Request) bool {
+	return r.URL.Query().Get("output") == "json"
+}
+
+// disableProxyBuffering asks proxies and gateways in front of the API, like
+// nginx, to send the output to clients as soon as it's written.
+func disableProxyBuffering(w http.ResponseWriter) {
+	w.Header().Set("X-Accel-Buffering", "no")
+}
+
+// newTextStream creates a stream sending plain text, keeping the connection
+// alive with keepAliveMsg.
+func newTextStream(w http.ResponseWriter, r *http.Request, interval time.Duration, keepAliveMsg string) *outputStream {
+	if frameOutputRequested(r) {
+		return newFrameStream(w, interval)
+	}
+	w.Header().Set("Content-Type", "text")
+	disableProxyBuffering(w)
+	keepAlive := tsuruIo.NewKeepAliveWriter(w, interval, keepAliveMsg)
+	return &outputStream{keepAlive: keepAlive, writer: keepAlive}
+}
+
+// newJSONMessageStream creates a stream sending SimpleJsonMessages.
+func newJSONMessageStream(w http.ResponseWriter, r *http.Request, interval time.Duration) *outputStream {
+	if frameOutputRequested(r) {
+		return newFrameStream(w, interval)
+	}
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	disableProxyBuffering(w)
+	keepAlive := tsuruIo.NewKeepAliveWriter(w, interval, "")
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAlive)}
+	return &outputStream{keepAlive: keepAlive, writer: writer}
+}
+
+func newFrameStream(w http.ResponseWriter, interval time.Duration) *outputStream {
+	w.Header().Set("Content-Type", tsuruIo.FrameContentType)
+	disableProxyBuffering(w)
+	keepAlive := tsuruIo.NewKeepAliveWriter(w, interval, tsuruIo.KeepAliveFrame())
+	frames := tsuruIo.NewFrameWriter(keepAlive)
+	return &outputStream{keepAlive: keepAlive, frames: frames, writer: frames}
+}
+
+func (s *outputStream) Write(b []byte) (int, error) {
+	return s.writer.Write(b)
+}
+
+// IsFrames reports whether the output is being converted to JSON frames.
+func (s *outputStream) IsFrames() bool {
+	return s.frames != nil
+}
+
+// WriteError sends the error that interrupted the operation.
+func (s *outputStream) WriteError(err error) {
+	if s.frames != nil {
+		s.frames.WriteFrame(tsuruIo.Frame{Type: tsuruIo.FrameError, Message: err.Error()})
+		return
+	}
+	if encoder, ok := s.writer.(*tsuruIo.SimpleJsonMessageEncoderWriter); ok {
+		encoder.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
+		return
+	}
+	io.WriteString(s.writer, err.Error()+"\n")
+}
+
+// Close flushes any pending output and stops the keepalive.
+func (s *outputStream) Close() {
+	if s.frames != nil {
+		s.frames.Close()
+	}
+	s.keepAlive.Stop()
+}
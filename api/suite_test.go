@@ -86,6 +86,7 @@ func (s *S) SetUpSuite(c *check.C) {
 	c.Assert(err, check.IsNil)
 	config.Set("database:url", "127.0.0.1:27017")
 	config.Set("database:name", "tsuru_api_base_test")
+	config.Set("deploy-status:token-key", "tsuru-api-tests")
 	app.LogPubSubQueuePrefix = "pubsub:api-base-test:"
 }
 
@@ -0,0 +1,241 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	terrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/service"
+	"github.com/tsuru/tsuru/tag"
+)
+
+// taggedObject holds the object targeted by a tag request along with the
+// permissions required to read and change its tags.
+type taggedObject struct {
+	object     tag.Object
+	target     event.Target
+	contexts   []permission.PermissionContext
+	readPerm   *permission.PermissionScheme
+	updatePerm *permission.PermissionScheme
+	eventsPerm *permission.PermissionScheme
+}
+
+func taggedObjectFromRequest(r *http.Request) (*taggedObject, error) {
+	query := r.URL.Query()
+	if appName := query.Get(":app"); appName != "" {
+		a, err := getAppFromContext(appName, r)
+		if err != nil {
+			return nil, err
+		}
+		return &taggedObject{
+			object:     tag.Object{Kind: tag.KindApp, Name: a.Name},
+			target:     appTarget(a.Name),
+			contexts:   contextsForApp(&a),
+			readPerm:   permission.PermAppRead,
+			updatePerm: permission.PermAppUpdateTags,
+			eventsPerm: permission.PermAppReadEvents,
+		}, nil
+	}
+	if serviceName := query.Get(":service"); serviceName != "" {
+		instanceName := query.Get(":instance")
+		si, err := getServiceInstanceOrError(serviceName, instanceName)
+		if err != nil {
+			return nil, err
+		}
+		return &taggedObject{
+			object:     tag.Object{Kind: tag.KindServiceInstance, Name: serviceIntancePermName(serviceName, instanceName)},
+			target:     serviceInstanceTarget(serviceName, instanceName),
+			contexts:   contextsForServiceInstance(si, serviceName),
+			readPerm:   permission.PermServiceInstanceRead,
+			updatePerm: permission.PermServiceInstanceUpdateTags,
+			eventsPerm: permission.PermServiceInstanceReadEvents,
+		}, nil
+	}
+	poolName := query.Get(":name")
+	_, err := provision.GetPoolByName(poolName)
+	if err == provision.ErrPoolNotFound {
+		return nil, newCodedError(r, http.StatusNotFound, terrors.ErrorCodePoolNotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &taggedObject{
+		object:     tag.Object{Kind: tag.KindPool, Name: poolName},
+		target:     event.Target{Type: event.TargetTypePool, Value: poolName},
+		contexts:   []permission.PermissionContext{permission.Context(permission.CtxPool, poolName)},
+		readPerm:   permission.PermPoolRead,
+		updatePerm: permission.PermPoolUpdateTags,
+		eventsPerm: permission.PermPoolReadEvents,
+	}, nil
+}
+
+func tagError(err error) error {
+	switch err {
+	case tag.ErrInvalidTag:
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case tag.ErrObjectNotFound:
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: tagged objects list
+// path: /tags
+// method: GET
+// produce: application/json
+// responses:
+//   200: List tagged objects
+//   204: No content
+//   400: Tag is required
+//   401: Unauthorized
+func tagList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	tagName := r.URL.Query().Get("tag")
+	if tagName == "" {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: "tag is required"}
+	}
+	objects, err := tag.List(tagName)
+	if err != nil {
+		return err
+	}
+	var result []tag.Object
+	for _, obj := range objects {
+		var allowed bool
+		switch obj.Kind {
+		case tag.KindApp:
+			a, err := app.GetByName(obj.Name)
+			if err == app.ErrAppNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			allowed = permission.Check(t, permission.PermAppRead, contextsForApp(a)...)
+		case tag.KindPool:
+			allowed = permission.Check(t, permission.PermPoolRead, permission.Context(permission.CtxPool, obj.Name))
+		case tag.KindServiceInstance:
+			parts := strings.SplitN(obj.Name, "/", 2)
+			si, err := service.GetServiceInstance(parts[0], parts[1])
+			if err == service.ErrServiceInstanceNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			allowed = permission.Check(t, permission.PermServiceInstanceRead, contextsForServiceInstance(si, si.ServiceName)...)
+		}
+		if allowed {
+			result = append(result, obj)
+		}
+	}
+	if len(result) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// title: object tags
+// path: /{object}/tags
+// method: GET
+// produce: application/json
+// responses:
+//   200: List tags
+//   401: Unauthorized
+//   404: Object not found
+func objectTags(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	obj, err := taggedObjectFromRequest(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, obj.readPerm, obj.contexts...) {
+		return permission.ErrUnauthorized
+	}
+	tags, err := tag.Get(obj.object)
+	if err != nil {
+		return tagError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(tags)
+}
+
+// title: object tags add
+// path: /{object}/tags
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Tags added
+//   400: Invalid tag
+//   401: Unauthorized
+//   404: Object not found
+func objectTagsAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	obj, err := taggedObjectFromRequest(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, obj.updatePerm, obj.contexts...) {
+		return permission.ErrUnauthorized
+	}
+	tags := r.Form["tag"]
+	if len(tags) == 0 {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: "at least one tag is required"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     obj.target,
+		Kind:       obj.updatePerm,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(obj.eventsPerm, obj.contexts...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return tagError(tag.Add(obj.object, tags...))
+}
+
+// title: object tags remove
+// path: /{object}/tags
+// method: DELETE
+// responses:
+//   200: Tags removed
+//   400: Invalid tag
+//   401: Unauthorized
+//   404: Object not found
+func objectTagsRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	obj, err := taggedObjectFromRequest(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, obj.updatePerm, obj.contexts...) {
+		return permission.ErrUnauthorized
+	}
+	tags := r.Form["tag"]
+	if len(tags) == 0 {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: "at least one tag is required"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     obj.target,
+		Kind:       obj.updatePerm,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(obj.eventsPerm, obj.contexts...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return tagError(tag.Remove(obj.object, tags...))
+}
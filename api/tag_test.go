@@ -0,0 +1,159 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/tag"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestObjectTagsAdd(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("tag=region=eu&tag=tier=gold")
+	request, err := http.NewRequest("PUT", fmt.Sprintf("/apps/%s/tags", a.Name), body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Tags, check.DeepEquals, []string{"region=eu", "tier=gold"})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.tags",
+		StartCustomData: []map[string]interface{}{
+			{"name": "tag", "value": []string{"region=eu", "tier=gold"}},
+			{"name": ":app", "value": "leper"},
+		},
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("GET", fmt.Sprintf("/apps/%s/tags", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var tags []string
+	err = json.Unmarshal(recorder.Body.Bytes(), &tags)
+	c.Assert(err, check.IsNil)
+	c.Assert(tags, check.DeepEquals, []string{"region=eu", "tier=gold"})
+}
+
+func (s *S) TestObjectTagsRemove(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = tag.Add(tag.Object{Kind: tag.KindPool, Name: "pool1"}, "region=eu", "tier=gold")
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/pools/pool1/tags?tag=tier=gold", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Tags, check.DeepEquals, []string{"region=eu"})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.tags",
+		StartCustomData: []map[string]interface{}{
+			{"name": "tag", "value": "tier=gold"},
+			{"name": ":name", "value": "pool1"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestObjectTagsInvalid(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	var tests = []struct {
+		method string
+		url    string
+		code   int
+	}{
+		{"PUT", "/pools/pool1/tags", http.StatusBadRequest},
+		{"PUT", "/pools/pool1/tags?tag=%20", http.StatusBadRequest},
+		{"PUT", "/pools/unknown/tags?tag=a", http.StatusNotFound},
+		{"GET", "/apps/unknown/tags", http.StatusNotFound},
+		{"GET", "/services/mysql/instances/db1/tags", http.StatusNotFound},
+	}
+	for _, t := range tests {
+		request, err := http.NewRequest(t.method, t.url, nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "b "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, t.code, check.Commentf("%s %s", t.method, t.url))
+	}
+}
+
+func (s *S) TestTagList(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool2"})
+	c.Assert(err, check.IsNil)
+	err = tag.Add(tag.Object{Kind: tag.KindPool, Name: "pool1"}, "region=eu")
+	c.Assert(err, check.IsNil)
+	err = tag.Add(tag.Object{Kind: tag.KindPool, Name: "pool2"}, "region=eu")
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolRead,
+		Context: permission.Context(permission.CtxPool, "pool1"),
+	})
+	request, err := http.NewRequest("GET", "/tags?tag=region=eu", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var objects []tag.Object
+	err = json.Unmarshal(recorder.Body.Bytes(), &objects)
+	c.Assert(err, check.IsNil)
+	c.Assert(objects, check.DeepEquals, []tag.Object{{Kind: tag.KindPool, Name: "pool1"}})
+}
+
+func (s *S) TestTagListPermissionByTag(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = tag.Add(tag.Object{Kind: tag.KindPool, Name: "pool1"}, "region=eu")
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateTags,
+		Context: permission.Context(permission.CtxTag, "region=eu"),
+	})
+	request, err := http.NewRequest("PUT", "/pools/pool1/tags?tag=tier=gold", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("%s", recorder.Body.String()))
+	request, err = http.NewRequest("GET", "/tags?tag=region=eu", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: app timeline
+// path: /apps/{app}/timeline
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appTimeline(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	var opts app.TimelineOpts
+	if v := r.URL.Query().Get("before"); v != "" {
+		var err error
+		opts.Before, err = time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for before: " + v}
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		opts.Limit, err = strconv.Atoi(v)
+		if err != nil || opts.Limit <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid value for limit: " + v}
+		}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppReadEvents,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	entries, err := a.Timeline(opts)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(entries)
+}
+
+// title: app annotate
+// path: /apps/{app}/annotations
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Annotation added
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appAnnotate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	if r.FormValue("message") == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "message is required"}
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateAnnotate,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(appName),
+		Kind:        permission.PermAppUpdateAnnotate,
+		Owner:       t,
+		CustomData:  event.FormToCustomData(r.Form),
+		DisableLock: true,
+		Allowed:     event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	return evt.Done(nil)
+}
@@ -0,0 +1,353 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package apiusage tracks the API calls made by each token, grouped by the
+// team owning the resource accessed by the call, and enforces limits on the
+// number of calls per minute on the resources of a team. Calls are counted in
+// memory and written to the database in batches, so usage and limits are
+// shared by all API servers, lagging by at most one flush interval.
+package apiusage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultRetention = 7 * 24 * time.Hour
+
+var flushInterval = time.Second
+
+var (
+	ErrInvalidQuota  = errors.New("invalid API quota, the limit must be greater than zero")
+	ErrQuotaNotFound = errors.New("API quota not found")
+)
+
+// QuotaExceededError is returned when a team made more calls in the current
+// minute than allowed by its quota.
+type QuotaExceededError struct {
+	Team  string
+	Limit int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("API quota exceeded for team %q: limit of %d calls per minute", e.Team, e.Limit)
+}
+
+type callsEntry struct {
+	Team   string
+	Owner  string
+	Window time.Time
+	Calls  int64
+}
+
+type callsKey struct {
+	Team   string
+	Owner  string
+	Window time.Time
+}
+
+// pendingCalls holds the calls not written to the database yet. Calls are
+// only removed after being written, so they're never missing from the quota
+// checks of this API server.
+var pendingCalls = struct {
+	sync.Mutex
+	calls map[callsKey]int64
+}{calls: make(map[callsKey]int64)}
+
+// flushMu serializes flushes, so the same calls are never written twice.
+var flushMu sync.Mutex
+
+type quotaEntry struct {
+	Team  string `bson:"_id"`
+	Limit int
+}
+
+// Usage is the number of API calls made by the tokens of an owner, which is
+// either a user or an app, on behalf of a team.
+type Usage struct {
+	Team  string `json:"team"`
+	Owner string `json:"owner"`
+	Calls int64  `json:"calls"`
+}
+
+// Enabled returns whether API calls are tracked, as set in the
+// api-usage:enabled config.
+func Enabled() bool {
+	enabled, _ := config.GetBool("api-usage:enabled")
+	return enabled
+}
+
+func retention() time.Duration {
+	hours, err := config.GetInt("api-usage:retention-hours")
+	if err != nil || hours <= 0 {
+		return defaultRetention
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func usageCollection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	coll := conn.Collection("api_usage")
+	indexes := []mgo.Index{
+		{Key: []string{"window"}, ExpireAfter: retention()},
+		{Key: []string{"team", "window"}},
+		{Key: []string{"team", "owner", "window"}},
+	}
+	for _, index := range indexes {
+		err = coll.EnsureIndex(index)
+		if err != nil {
+			log.Errorf("[api-usage] unable to ensure index %v: %s", index.Key, err)
+		}
+	}
+	return coll, nil
+}
+
+func quotasCollection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("api_quotas"), nil
+}
+
+// Track records a call made by the owner on a resource of the given team and
+// returns a *QuotaExceededError if the team exceeded its quota in the current
+// minute. Calls on resources without a team are recorded with an empty team
+// and are never limited.
+func Track(owner, team string) error {
+	window := time.Now().UTC().Truncate(time.Minute)
+	pendingCalls.Lock()
+	pendingCalls.calls[callsKey{Team: team, Owner: owner, Window: window}]++
+	var pending int64
+	for k, calls := range pendingCalls.calls {
+		if k.Team == team && k.Window.Equal(window) {
+			pending += calls
+		}
+	}
+	pendingCalls.Unlock()
+	if team == "" {
+		return nil
+	}
+	limit, err := TeamQuota(team)
+	if err == ErrQuotaNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pending > int64(limit) {
+		return &QuotaExceededError{Team: team, Limit: limit}
+	}
+	coll, err := usageCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	var result []struct {
+		Calls int64
+	}
+	err = coll.Pipe([]bson.M{
+		{"$match": bson.M{"team": team, "window": window}},
+		{"$group": bson.M{"_id": nil, "calls": bson.M{"$sum": "$calls"}}},
+	}).All(&result)
+	if err != nil {
+		return err
+	}
+	var stored int64
+	if len(result) > 0 {
+		stored = result[0].Calls
+	}
+	if stored+pending > int64(limit) {
+		return &QuotaExceededError{Team: team, Limit: limit}
+	}
+	return nil
+}
+
+// Flush writes the calls tracked by this API server to the database, in a
+// single bulk operation.
+func Flush() error {
+	flushMu.Lock()
+	defer flushMu.Unlock()
+	pendingCalls.Lock()
+	batch := make(map[callsKey]int64, len(pendingCalls.calls))
+	for k, calls := range pendingCalls.calls {
+		batch[k] = calls
+	}
+	pendingCalls.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	coll, err := usageCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	bulk := coll.Bulk()
+	bulk.Unordered()
+	for k, calls := range batch {
+		bulk.Upsert(
+			bson.M{"team": k.Team, "owner": k.Owner, "window": k.Window},
+			bson.M{"$inc": bson.M{"calls": calls}},
+		)
+	}
+	_, err = bulk.Run()
+	if err != nil {
+		return err
+	}
+	pendingCalls.Lock()
+	defer pendingCalls.Unlock()
+	for k, calls := range batch {
+		pendingCalls.calls[k] -= calls
+		if pendingCalls.calls[k] <= 0 {
+			delete(pendingCalls.calls, k)
+		}
+	}
+	return nil
+}
+
+type flusher struct {
+	done chan struct{}
+}
+
+// Initialize starts writing the tracked calls to the database in background,
+// every second.
+func Initialize() error {
+	f := &flusher{done: make(chan struct{})}
+	shutdown.Register(f)
+	go f.run()
+	return nil
+}
+
+func (f *flusher) run() {
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-time.After(flushInterval):
+		}
+		err := Flush()
+		if err != nil {
+			log.Errorf("[api-usage] unable to write api calls: %s", err)
+		}
+	}
+}
+
+func (f *flusher) Shutdown() {
+	close(f.done)
+	err := Flush()
+	if err != nil {
+		log.Errorf("[api-usage] unable to write api calls: %s", err)
+	}
+}
+
+func (f *flusher) String() string {
+	return "api usage flusher"
+}
+
+// Report returns the calls made by each owner and team since the given time,
+// sorted by the number of calls. When teams is not nil, only calls of the
+// given teams are returned.
+func Report(since time.Time, teams []string) ([]Usage, error) {
+	err := Flush()
+	if err != nil {
+		return nil, err
+	}
+	coll, err := usageCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	query := bson.M{"window": bson.M{"$gte": since.UTC().Truncate(time.Minute)}}
+	if teams != nil {
+		query["team"] = bson.M{"$in": teams}
+	}
+	var entries []callsEntry
+	err = coll.Find(query).All(&entries)
+	if err != nil {
+		return nil, err
+	}
+	indexes := make(map[[2]string]int)
+	var result []Usage
+	for _, e := range entries {
+		key := [2]string{e.Team, e.Owner}
+		i, ok := indexes[key]
+		if !ok {
+			i = len(result)
+			indexes[key] = i
+			result = append(result, Usage{Team: e.Team, Owner: e.Owner})
+		}
+		result[i].Calls += e.Calls
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Calls != result[j].Calls {
+			return result[i].Calls > result[j].Calls
+		}
+		if result[i].Team != result[j].Team {
+			return result[i].Team < result[j].Team
+		}
+		return result[i].Owner < result[j].Owner
+	})
+	return result, nil
+}
+
+// SetTeamQuota limits the number of API calls the tokens of the team may
+// make per minute.
+func SetTeamQuota(team string, limit int) error {
+	if limit <= 0 {
+		return ErrInvalidQuota
+	}
+	coll, err := quotasCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	_, err = coll.UpsertId(team, quotaEntry{Team: team, Limit: limit})
+	return err
+}
+
+// RemoveTeamQuota removes the API quota of the team.
+func RemoveTeamQuota(team string) error {
+	coll, err := quotasCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.RemoveId(team)
+	if err == mgo.ErrNotFound {
+		return ErrQuotaNotFound
+	}
+	return err
+}
+
+// TeamQuota returns the API quota of the team, in calls per minute.
+func TeamQuota(team string) (int, error) {
+	coll, err := quotasCollection()
+	if err != nil {
+		return 0, err
+	}
+	defer coll.Close()
+	var entry quotaEntry
+	err = coll.FindId(team).One(&entry)
+	if err == mgo.ErrNotFound {
+		return 0, ErrQuotaNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return entry.Limit, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package apiusage
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestTrackAndReport(c *check.C) {
+	since := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		err := Track("bot@example.com", "team1")
+		c.Assert(err, check.IsNil)
+	}
+	err := Track("user@example.com", "team1")
+	c.Assert(err, check.IsNil)
+	err = Track("user@example.com", "team2")
+	c.Assert(err, check.IsNil)
+	err = Track("admin@example.com", "")
+	c.Assert(err, check.IsNil)
+	usage, err := Report(since, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, []Usage{
+		{Team: "team1", Owner: "bot@example.com", Calls: 3},
+		{Team: "", Owner: "admin@example.com", Calls: 1},
+		{Team: "team1", Owner: "user@example.com", Calls: 1},
+		{Team: "team2", Owner: "user@example.com", Calls: 1},
+	})
+	usage, err = Report(since, []string{"team2"})
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, []Usage{
+		{Team: "team2", Owner: "user@example.com", Calls: 1},
+	})
+	usage, err = Report(time.Now().Add(time.Hour), nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.HasLen, 0)
+}
+
+func (s *S) TestTrackQuotaExceeded(c *check.C) {
+	err := SetTeamQuota("team1", 3)
+	c.Assert(err, check.IsNil)
+	err = Track("bot@example.com", "team1")
+	c.Assert(err, check.IsNil)
+	err = Track("user@example.com", "team1")
+	c.Assert(err, check.IsNil)
+	err = Flush()
+	c.Assert(err, check.IsNil)
+	err = Track("user@example.com", "team2")
+	c.Assert(err, check.IsNil)
+	err = Track("bot@example.com", "team1")
+	c.Assert(err, check.IsNil)
+	err = Track("bot@example.com", "team1")
+	c.Assert(err, check.DeepEquals, &QuotaExceededError{Team: "team1", Limit: 3})
+	c.Assert(err, check.ErrorMatches, `API quota exceeded for team "team1": limit of 3 calls per minute`)
+}
+
+func (s *S) TestFlush(c *check.C) {
+	for i := 0; i < 5; i++ {
+		err := Track("bot@example.com", "team1")
+		c.Assert(err, check.IsNil)
+	}
+	err := Flush()
+	c.Assert(err, check.IsNil)
+	c.Assert(pendingCalls.calls, check.HasLen, 0)
+	var entries []callsEntry
+	err = s.conn.Collection("api_usage").Find(nil).All(&entries)
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].Team, check.Equals, "team1")
+	c.Assert(entries[0].Owner, check.Equals, "bot@example.com")
+	c.Assert(entries[0].Calls, check.Equals, int64(5))
+	err = Track("bot@example.com", "team1")
+	c.Assert(err, check.IsNil)
+	err = Flush()
+	c.Assert(err, check.IsNil)
+	err = s.conn.Collection("api_usage").Find(nil).All(&entries)
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].Calls, check.Equals, int64(6))
+}
+
+func (s *S) TestTeamQuota(c *check.C) {
+	_, err := TeamQuota("team1")
+	c.Assert(err, check.Equals, ErrQuotaNotFound)
+	err = SetTeamQuota("team1", 0)
+	c.Assert(err, check.Equals, ErrInvalidQuota)
+	err = SetTeamQuota("team1", 100)
+	c.Assert(err, check.IsNil)
+	limit, err := TeamQuota("team1")
+	c.Assert(err, check.IsNil)
+	c.Assert(limit, check.Equals, 100)
+	err = RemoveTeamQuota("team1")
+	c.Assert(err, check.IsNil)
+	_, err = TeamQuota("team1")
+	c.Assert(err, check.Equals, ErrQuotaNotFound)
+	err = RemoveTeamQuota("team1")
+	c.Assert(err, check.Equals, ErrQuotaNotFound)
+}
@@ -7,7 +7,6 @@ package app
 import (
 	"fmt"
 	"io"
-	"regexp"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
@@ -28,6 +27,7 @@ import (
 var (
 	ErrAppAlreadyExists = errors.New("there is already an app with this name")
 	ErrAppNotFound      = errors.New("App not found.")
+	ErrInvalidCName     = errors.New("Invalid cname")
 )
 
 // reserveUserApp reserves the app for the user, only if the user has a quota
@@ -70,6 +70,27 @@ var reserveUserApp = action.Action{
 	MinParams: 2,
 }
 
+// reserveOrganizationApp reserves the app in the quota of the organization of
+// the team owner of the app, when the team belongs to an organization.
+var reserveOrganizationApp = action.Action{
+	Name: "reserve-organization-app",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app, ok := ctx.Params[0].(*App)
+		if !ok {
+			return nil, errors.New("First parameter must be *App.")
+		}
+		err := auth.ReserveOrganizationApp(app.TeamOwner)
+		if err != nil {
+			return nil, err
+		}
+		return app.TeamOwner, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		auth.ReleaseOrganizationApp(ctx.FWResult.(string))
+	},
+	MinParams: 1,
+}
+
 // insertApp is an action that inserts an app in the database in Forward and
 // removes it in the Backward.
 //
@@ -348,6 +369,10 @@ var reserveUnitsToAdd = action.Action{
 		if err != nil {
 			return nil, ErrAppNotFound
 		}
+		err = checkUnitsToAdd(app, n)
+		if err != nil {
+			return nil, err
+		}
 		err = reserveUnits(app, n)
 		if err != nil {
 			return nil, err
@@ -534,8 +559,12 @@ var removeOldBackend = action.Action{
 var validateNewCNames = action.Action{
 	Name: "validate-new-cnames",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
-		cnameRegexp := regexp.MustCompile(`^(\*\.)?[a-zA-Z0-9][\w-.]+$`)
+		app := ctx.Params[0].(*App)
 		cnames := ctx.Params[1].([]string)
+		var force bool
+		if len(ctx.Params) > 2 {
+			force, _ = ctx.Params[2].(bool)
+		}
 		conn, err := db.Conn()
 		if err != nil {
 			return nil, err
@@ -543,7 +572,7 @@ var validateNewCNames = action.Action{
 		defer conn.Close()
 		for _, cname := range cnames {
 			if !cnameRegexp.MatchString(cname) {
-				return nil, errors.New("Invalid cname")
+				return nil, ErrInvalidCName
 			}
 			cs, err := conn.Apps().Find(bson.M{"cname": cname}).Count()
 			if err != nil {
@@ -552,6 +581,16 @@ var validateNewCNames = action.Action{
 			if cs > 0 {
 				return nil, errors.New("cname already exists!")
 			}
+			if !force {
+				err = checkCNameConflicts(conn, app, cname)
+				if err != nil {
+					return nil, err
+				}
+				err = checkCNameVerified(conn, app, cname)
+				if err != nil {
+					return nil, err
+				}
+			}
 		}
 		return cnames, nil
 	},
@@ -463,6 +463,28 @@ func (s *S) TestReserveUserAppMinParams(c *check.C) {
 	c.Assert(reserveUserApp.MinParams, check.Equals, 2)
 }
 
+func (s *S) TestReserveOrganizationApp(c *check.C) {
+	err := auth.CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	org, err := auth.GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	err = org.AddTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	err = org.ChangeQuota(1)
+	c.Assert(err, check.IsNil)
+	app := App{Name: "clap", TeamOwner: s.team.Name}
+	result, err := reserveOrganizationApp.Forward(action.FWContext{Params: []interface{}{&app}})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.Equals, s.team.Name)
+	_, err = reserveOrganizationApp.Forward(action.FWContext{Params: []interface{}{&app}})
+	_, ok := err.(*quota.QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+	reserveOrganizationApp.Backward(action.BWContext{FWResult: result})
+	org, err = auth.GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Quota.InUse, check.Equals, 0)
+}
+
 func (s *S) TestReserveUnitsToAddForward(c *check.C) {
 	app := App{
 		Name:     "visions",
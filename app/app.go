@@ -12,7 +12,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/url"
-	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -21,16 +21,21 @@ import (
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/deploystatus"
+	"github.com/tsuru/tsuru/dns"
+	"github.com/tsuru/tsuru/egress"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/healer"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/naming"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/nodecontainer"
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/repository"
 	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/certstore"
 	"github.com/tsuru/tsuru/router/rebuild"
 	"github.com/tsuru/tsuru/service"
 	"gopkg.in/mgo.v2"
@@ -40,12 +45,11 @@ import (
 var AuthScheme auth.Scheme
 
 var (
-	nameRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]{0,62}$`)
-
 	ErrAlreadyHaveAccess = errors.New("team already have access to this app")
 	ErrNoAccess          = errors.New("team does not have access to this app")
 	ErrCannotOrphanApp   = errors.New("cannot revoke access from this team, as it's the unique team with access to the app")
 	ErrDisabledPlatform  = errors.New("Disabled Platform, only admin users can create applications with the platform")
+	ErrDaemonUnits       = errors.New("units of daemon apps are managed by tsuru, one per node of the app pool")
 )
 
 const (
@@ -111,23 +115,34 @@ func (l *AppLock) GetAcquireDate() time.Time {
 // This struct holds information about the app: its name, address, list of
 // teams that have access to it, used platform, etc.
 type App struct {
-	Env            map[string]bind.EnvVar
-	Platform       string `bson:"framework"`
-	Name           string
-	Ip             string
-	CName          []string
-	Teams          []string
-	TeamOwner      string
-	Owner          string
-	Plan           Plan
-	UpdatePlatform bool
-	Lock           AppLock
-	Pool           string
-	Description    string
-	Router         string
-	RouterOpts     map[string]string
-	Deploys        uint
-	Tags           []string
+	Env                 map[string]bind.EnvVar
+	Platform            string `bson:"framework"`
+	Name                string
+	Ip                  string
+	CName               []string
+	Teams               []string
+	TeamOwner           string
+	Owner               string
+	Plan                Plan
+	UpdatePlatform      bool
+	Lock                AppLock
+	Pool                string
+	Description         string
+	Router              string
+	RouterOpts          map[string]string
+	Deploys             uint
+	Tags                []string
+	AutoRollback        AutoRollback
+	NodeRequirements    map[string]string
+	PreemptionTolerance string `bson:",omitempty"`
+	ProcessSettings     provision.ProcessSettings
+	DeployToggles       *provision.DeployToggles `bson:",omitempty"`
+	Metrics             provision.MetricsConfig
+	ConfigTemplates     []provision.ConfigTemplate `bson:",omitempty"`
+	Daemon              bool
+	Maintenance         Maintenance   `bson:",omitempty"`
+	Mirror              Mirror        `bson:",omitempty"`
+	Egress              []egress.Rule `bson:",omitempty"`
 
 	quota.Quota
 	provisioner provision.Provisioner
@@ -188,16 +203,50 @@ func (app *App) MarshalJSON() ([]byte, error) {
 	result["description"] = app.Description
 	result["deploys"] = app.Deploys
 	result["teamowner"] = app.TeamOwner
-	result["plan"] = map[string]interface{}{
+	plan := map[string]interface{}{
 		"name":     app.Plan.Name,
 		"memory":   app.Plan.Memory,
 		"swap":     app.Plan.Swap,
 		"cpushare": app.Plan.CpuShare,
 		"router":   app.Router,
 	}
+	if app.Plan.EphemeralStorage > 0 {
+		plan["ephemeralStorage"] = app.Plan.EphemeralStorage
+	}
+	result["plan"] = plan
 	result["router"] = app.Router
 	result["lock"] = app.Lock
 	result["tags"] = app.Tags
+	if app.AutoRollback.Window > 0 {
+		result["autoRollback"] = app.AutoRollback
+	}
+	if app.Maintenance.configured() {
+		result["maintenance"] = app.Maintenance
+	}
+	if app.Mirror.App != "" {
+		result["mirror"] = app.Mirror
+	}
+	if len(app.Egress) > 0 {
+		result["egress"] = app.Egress
+	}
+	if len(app.NodeRequirements) > 0 {
+		result["nodeRequirements"] = app.NodeRequirements
+	}
+	if app.PreemptionTolerance != "" {
+		result["preemptionTolerance"] = app.PreemptionTolerance
+	}
+	if !app.ProcessSettings.IsEmpty() {
+		result["processSettings"] = app.ProcessSettings
+	}
+	if !app.Metrics.IsEmpty() {
+		result["metrics"] = app.Metrics
+	}
+	if toggles := app.GetDeployToggles(); !toggles.IsEmpty() {
+		result["deployToggles"] = toggles
+	}
+	if app.Daemon {
+		result["daemon"] = true
+	}
 	return json.Marshal(&result)
 }
 
@@ -287,6 +336,31 @@ func GetByName(name string) (*App, error) {
 //       2. Create the git repository using the repository manager
 //       3. Provision the app using the provisioner
 func CreateApp(app *App, user *auth.User) error {
+	err := app.prepareCreation(user)
+	if err != nil {
+		return err
+	}
+	actions := []*action.Action{
+		&reserveUserApp,
+		&reserveOrganizationApp,
+		&insertApp,
+		&exportEnvironmentsAction,
+		&createRepository,
+		&addRouterBackend,
+		&provisionApp,
+		&setAppIp,
+	}
+	pipeline := action.NewPipeline(actions...)
+	err = pipeline.Execute(app, user)
+	if err != nil {
+		return &AppCreationError{app: app.Name, Err: err}
+	}
+	return nil
+}
+
+// prepareCreation fills the defaults of a new app and runs the validations
+// that don't depend on reserving quota.
+func (app *App) prepareCreation(user *auth.User) error {
 	var plan *Plan
 	var err error
 	if app.Plan.Name == "" {
@@ -317,21 +391,7 @@ func CreateApp(app *App, user *auth.User) error {
 	if err != nil {
 		return err
 	}
-	actions := []*action.Action{
-		&reserveUserApp,
-		&insertApp,
-		&exportEnvironmentsAction,
-		&createRepository,
-		&addRouterBackend,
-		&provisionApp,
-		&setAppIp,
-	}
-	pipeline := action.NewPipeline(actions...)
-	err = pipeline.Execute(app, user)
-	if err != nil {
-		return &AppCreationError{app: app.Name, Err: err}
-	}
-	return nil
+	return provision.CheckPoolQuota(app.Pool, provision.PoolUsage{Apps: 1})
 }
 
 // Update changes informations of the application.
@@ -341,19 +401,33 @@ func (app *App) Update(updateData App, w io.Writer) (err error) {
 	poolName := updateData.Pool
 	teamOwner := updateData.TeamOwner
 	routerName := updateData.Router
+	platformName := updateData.Platform
 	tags := processTags(updateData.Tags)
 	if description != "" {
 		app.Description = description
 	}
+	oldPool := app.Pool
 	if poolName != "" {
 		app.Pool = poolName
 		_, err = app.getPoolForApp(app.Pool)
 		if err != nil {
 			return err
 		}
+		if app.Pool != oldPool {
+			var pool *provision.Pool
+			pool, err = provision.GetPoolByName(app.Pool)
+			if err != nil {
+				return err
+			}
+			err = validatePoolAcceptsApps(pool)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	oldPlan := app.Plan
 	oldRouter := app.Router
+	oldTeamOwner := app.TeamOwner
 	if routerName != "" {
 		_, err = router.Get(routerName)
 		if err != nil {
@@ -383,10 +457,35 @@ func (app *App) Update(updateData App, w io.Writer) (err error) {
 	if tags != nil {
 		app.Tags = tags
 	}
-	err = app.validate()
+	if updateData.DeployToggles != nil {
+		app.DeployToggles = updateData.DeployToggles
+	}
+	if platformName != "" && platformName != app.Platform {
+		_, err = GetPlatform(platformName)
+		if err != nil {
+			return err
+		}
+		app.Platform = platformName
+		app.UpdatePlatform = true
+	}
+	err = app.validatePool()
 	if err != nil {
 		return err
 	}
+	err = app.checkPoolQuotaForUpdate(oldPool, oldPlan)
+	if err != nil {
+		return err
+	}
+	if app.TeamOwner != oldTeamOwner {
+		var done func(bool)
+		done, err = moveOrganizationApp(oldTeamOwner, app.TeamOwner)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			done(err == nil)
+		}()
+	}
 	if app.Router != oldRouter || app.Plan != oldPlan {
 		actions := []*action.Action{
 			&moveRouterUnits,
@@ -407,6 +506,40 @@ func (app *App) Update(updateData App, w io.Writer) (err error) {
 	return conn.Apps().Update(bson.M{"name": app.Name}, app)
 }
 
+// moveOrganizationApp reserves the app in the quota of the organization of
+// the new team owner, when it differs from the organization of the old team
+// owner. The returned function must be called with the result of the update,
+// releasing the app from the quota of the old organization on success, or
+// from the quota of the new one on failure.
+func moveOrganizationApp(oldTeamOwner, newTeamOwner string) (func(bool), error) {
+	nop := func(bool) {}
+	oldOrg, err := auth.OrganizationForTeam(oldTeamOwner)
+	if err != nil && err != auth.ErrOrganizationNotFound {
+		return nop, err
+	}
+	newOrg, err := auth.OrganizationForTeam(newTeamOwner)
+	if err != nil && err != auth.ErrOrganizationNotFound {
+		return nop, err
+	}
+	if oldOrg == nil && newOrg == nil || oldOrg != nil && newOrg != nil && oldOrg.Name == newOrg.Name {
+		return nop, nil
+	}
+	err = auth.ReserveOrganizationApp(newTeamOwner)
+	if err != nil {
+		return nop, err
+	}
+	return func(success bool) {
+		team := oldTeamOwner
+		if !success {
+			team = newTeamOwner
+		}
+		err := auth.ReleaseOrganizationApp(team)
+		if err != nil {
+			log.Errorf("unable to release organization app quota for team %q: %s", team, err)
+		}
+	}, nil
+}
+
 func processTags(tags []string) []string {
 	if tags == nil {
 		return nil
@@ -486,16 +619,44 @@ func Delete(app *App, w io.Writer) error {
 	if err != nil {
 		logErr("Unable to destroy app in provisioner", err)
 	}
+	var backendRemovalEnqueued bool
 	r, err := app.GetRouter()
 	if err == nil {
 		err = r.RemoveBackend(app.Name)
+		if err != nil && err != router.ErrBackendNotFound && err != router.ErrBackendSwapped {
+			enqueueErr := enqueueRouterBackendRemoval(app.Router, app.Name)
+			if enqueueErr != nil {
+				logErr("Unable to enqueue router backend removal", enqueueErr)
+			} else {
+				backendRemovalEnqueued = true
+				err = errors.Wrap(err, "removal enqueued to retry later")
+			}
+		}
 	}
 	if err != nil {
 		logErr("Failed to remove router backend", err)
 	}
-	err = router.Remove(app.Name)
+	if !backendRemovalEnqueued {
+		err = router.Remove(app.Name)
+		if err != nil {
+			logErr("Failed to remove router backend from database", err)
+		}
+	}
+	err = dns.RemoveApp(app.Name)
+	if err != nil {
+		logErr("Failed to remove dns records", err)
+	}
+	err = egress.RemoveApp(app.Name)
 	if err != nil {
-		logErr("Failed to remove router backend from database", err)
+		logErr("Failed to remove egress rules", err)
+	}
+	err = deploystatus.RemoveAppConfig(app.Name)
+	if err != nil && err != deploystatus.ErrConfigNotFound {
+		logErr("Failed to remove deploy status config", err)
+	}
+	err = removeCNameVerifications(app.Name)
+	if err != nil {
+		logErr("Failed to remove cname verifications", err)
 	}
 	err = app.unbind()
 	if err != nil {
@@ -517,6 +678,10 @@ func Delete(app *App, w io.Writer) error {
 	if err != nil {
 		logErr("Unable to release app quota", err)
 	}
+	err = auth.ReleaseOrganizationApp(app.TeamOwner)
+	if err != nil {
+		logErr("Unable to release organization app quota", err)
+	}
 	logConn, err := db.LogConn()
 	if err == nil {
 		defer logConn.Close()
@@ -598,9 +763,22 @@ func (app *App) serviceInstances() ([]service.ServiceInstance, error) {
 // AddUnits creates n new units within the provisioner, saves new units in the
 // database and enqueues the apprc serialization.
 func (app *App) AddUnits(n uint, process string, w io.Writer) error {
+	if app.Daemon {
+		return ErrDaemonUnits
+	}
 	if n == 0 {
 		return errors.New("Cannot add zero units.")
 	}
+	if app.Plan.HardwareProfile != "" {
+		pool, err := provision.GetPoolByName(app.Pool)
+		if err != nil {
+			return err
+		}
+		err = app.validateHardwareProfile(pool)
+		if err != nil {
+			return err
+		}
+	}
 	w = app.withLogWriter(w)
 	err := action.NewPipeline(
 		&reserveUnitsToAdd,
@@ -610,12 +788,33 @@ func (app *App) AddUnits(n uint, process string, w io.Writer) error {
 	return err
 }
 
+// checkUnitsToAdd checks whether the pool of the app accepts n new units.
+func checkUnitsToAdd(app *App, n int) error {
+	pool, err := provision.GetPoolByName(app.Pool)
+	if err != nil && err != provision.ErrPoolNotFound {
+		return err
+	}
+	if pool != nil && !pool.AcceptsNewUnits() {
+		return &tsuruErrors.ValidationError{
+			Message: fmt.Sprintf("pool %q is %s and doesn't accept new units", pool.Name, pool.GetState()),
+		}
+	}
+	return provision.CheckPoolQuota(app.Pool, provision.PoolUsage{
+		Units:    n,
+		Memory:   int64(n) * app.Plan.Memory,
+		CPUShare: n * app.Plan.CpuShare,
+	})
+}
+
 // RemoveUnits removes n units from the app. It's a process composed of
 // multiple steps:
 //
 //     1. Remove units from the provisioner
 //     2. Update quota
 func (app *App) RemoveUnits(n uint, process string, w io.Writer) error {
+	if app.Daemon {
+		return ErrDaemonUnits
+	}
 	prov, err := app.getProvisioner()
 	if err != nil {
 		return err
@@ -834,39 +1033,35 @@ func (app *App) SetPool() error {
 	if err != nil {
 		return err
 	}
-	if poolName == "" {
-		var pool *provision.Pool
-		pool, err = provision.GetDefaultPool()
-		if err != nil {
-			return err
-		}
-		poolName = pool.Name
-	}
 	app.Pool = poolName
 	pool, err := provision.GetPoolByName(poolName)
 	if err != nil {
 		return err
 	}
+	err = validatePoolAcceptsApps(pool)
+	if err != nil {
+		return err
+	}
 	return app.validateTeamOwner(pool)
 }
 
+func validatePoolAcceptsApps(pool *provision.Pool) error {
+	if !pool.AcceptsNewApps() {
+		msg := fmt.Sprintf("pool %q is %s and doesn't accept new apps", pool.Name, pool.GetState())
+		return &tsuruErrors.ValidationError{Message: msg}
+	}
+	return nil
+}
+
+// getPoolForApp returns the pool named poolName or, when poolName is empty,
+// the pool chosen by the configured pool selector.
 func (app *App) getPoolForApp(poolName string) (string, error) {
 	if poolName == "" {
-		pools, err := provision.ListPoolsForTeam(app.TeamOwner)
+		pool, err := provision.SelectPool(provision.SelectPoolOptions{TeamOwner: app.TeamOwner})
 		if err != nil {
 			return "", err
 		}
-		if len(pools) > 1 {
-			var names []string
-			for _, p := range pools {
-				names = append(names, fmt.Sprintf("%q", p.Name))
-			}
-			return "", errors.Errorf("you have access to %s pools. Please choose one in app creation", strings.Join(names, ","))
-		}
-		if len(pools) == 0 {
-			return "", nil
-		}
-		return pools[0].Name, nil
+		return pool.Name, nil
 	}
 	pool, err := provision.GetPoolByName(poolName)
 	if err != nil {
@@ -900,13 +1095,21 @@ func (app *App) getEnv(name string) (bind.EnvVar, error) {
 	return env, err
 }
 
-// validate checks app name format
+// validate checks app name against the configured naming policies
 func (app *App) validate() error {
-	if app.Name == InternalAppName || !nameRegexp.MatchString(app.Name) {
-		msg := "Invalid app name, your app should have at most 63 " +
-			"characters, containing only lower case letters, numbers or dashes, " +
-			"starting with a letter."
-		return &tsuruErrors.ValidationError{Message: msg}
+	err := naming.Validate(naming.Target{Kind: naming.KindApp, Name: app.Name, Team: app.TeamOwner})
+	if err == nil && app.Name == InternalAppName {
+		err = &naming.InvalidNameError{
+			Kind:   naming.KindApp,
+			Name:   app.Name,
+			Reason: fmt.Sprintf("%q is a reserved name.", app.Name),
+		}
+	}
+	if err != nil {
+		if _, ok := err.(*naming.InvalidNameError); ok {
+			return &tsuruErrors.ValidationError{Message: err.Error()}
+		}
+		return err
 	}
 	return app.validatePool()
 }
@@ -920,6 +1123,18 @@ func (app *App) validatePool() error {
 	if err != nil {
 		return err
 	}
+	err = app.validatePlatform(pool)
+	if err != nil {
+		return err
+	}
+	err = app.validatePlan(pool)
+	if err != nil {
+		return err
+	}
+	err = app.validateHardwareProfile(pool)
+	if err != nil {
+		return err
+	}
 	return app.validateRouter(pool)
 }
 
@@ -947,6 +1162,41 @@ func (app *App) validateTeamOwner(pool *provision.Pool) error {
 	return nil
 }
 
+func (app *App) validatePlatform(pool *provision.Pool) error {
+	allowed, err := pool.AllowsPlatform(app.Platform)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		msg := fmt.Sprintf("platform %q is not allowed in pool %q", app.Platform, pool.Name)
+		return &tsuruErrors.ValidationError{Message: msg}
+	}
+	return nil
+}
+
+func (app *App) validatePlan(pool *provision.Pool) error {
+	if app.Plan.Name == "" {
+		return nil
+	}
+	allowed, err := pool.AllowsPlan(app.Plan.Name)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		msg := fmt.Sprintf("plan %q is not allowed in pool %q", app.Plan.Name, pool.Name)
+		return &tsuruErrors.ValidationError{Message: msg}
+	}
+	return nil
+}
+
+func (app *App) validateHardwareProfile(pool *provision.Pool) error {
+	err := provision.CheckPoolHardwareProfile(pool, app.Plan.Name, app.Plan.HardwareProfile)
+	if mismatchErr, ok := err.(*provision.HardwareProfileMismatchError); ok {
+		return &tsuruErrors.ValidationError{Message: mismatchErr.Error()}
+	}
+	return err
+}
+
 func (app *App) validateRouter(pool *provision.Pool) error {
 	routers, err := pool.GetRouters()
 	if err != nil {
@@ -1140,6 +1390,85 @@ func (app *App) GetSwap() int64 {
 	return app.Plan.Swap
 }
 
+// UnitsDiskUsage returns the ephemeral storage used by each unit of the app.
+func (app *App) UnitsDiskUsage() ([]provision.UnitDiskUsage, error) {
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	diskProv, ok := prov.(provision.DiskUsageProvisioner)
+	if !ok {
+		return nil, provision.ProvisionerNotSupported{Prov: prov, Action: "reporting disk usage"}
+	}
+	return diskProv.UnitsDiskUsage(app)
+}
+
+// UnitsEvents returns the events of the units of the app reported by the
+// provisioner since the given time, most recent first.
+func (app *App) UnitsEvents(since time.Time) ([]provision.UnitEvent, error) {
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	evtProv, ok := prov.(provision.UnitEventsProvisioner)
+	if !ok {
+		return nil, provision.ProvisionerNotSupported{Prov: prov, Action: "reporting unit events"}
+	}
+	events, err := evtProv.UnitsEvents(app, since)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastSeen.After(events[j].LastSeen)
+	})
+	return events, nil
+}
+
+// GetEphemeralStorage returns the ephemeral storage limit (in bytes) for the
+// units of the app. Zero means no limit.
+func (app *App) GetEphemeralStorage() int64 {
+	return app.Plan.EphemeralStorage
+}
+
+// GetNodeRequirements returns the metadata required in nodes running units of
+// the app.
+func (app *App) GetNodeRequirements() map[string]string {
+	return app.NodeRequirements
+}
+
+// GetProcessSettings returns the start order and grace periods of the app
+// processes.
+func (app *App) GetProcessSettings() provision.ProcessSettings {
+	return app.ProcessSettings
+}
+
+// GetDeployToggles returns the switches changing how deploys of the app are
+// executed.
+func (app *App) GetDeployToggles() provision.DeployToggles {
+	if app.DeployToggles == nil {
+		return provision.DeployToggles{}
+	}
+	return *app.DeployToggles
+}
+
+// GetMetricsConfig returns the endpoint where the units of the app expose
+// metrics.
+func (app *App) GetMetricsConfig() provision.MetricsConfig {
+	return app.Metrics
+}
+
+// GetConfigTemplates returns the configuration files rendered for the units
+// of the app.
+func (app *App) GetConfigTemplates() []provision.ConfigTemplate {
+	return app.ConfigTemplates
+}
+
+// IsDaemon returns whether the app runs one unit of each process in every node
+// of its pool.
+func (app *App) IsDaemon() bool {
+	return app.Daemon
+}
+
 // GetCpuShare returns the cpu share for the app.
 func (app *App) GetCpuShare() int {
 	return app.Plan.CpuShare
@@ -1150,6 +1479,27 @@ func (app *App) GetIp() string {
 	return app.Ip
 }
 
+// checkPoolQuotaForUpdate checks whether the pool of the app has room for it
+// after its pool or plan changed. Moving to another pool requires room for the
+// whole app, while changing the plan only requires room for the additional
+// resources of its units.
+func (app *App) checkPoolQuotaForUpdate(oldPool string, oldPlan Plan) error {
+	units := app.Quota.InUse
+	requested := provision.PoolUsage{
+		Memory:   int64(units) * (app.Plan.Memory - oldPlan.Memory),
+		CPUShare: units * (app.Plan.CpuShare - oldPlan.CpuShare),
+	}
+	if app.Pool != oldPool {
+		requested = provision.PoolUsage{
+			Apps:     1,
+			Units:    units,
+			Memory:   int64(units) * app.Plan.Memory,
+			CPUShare: units * app.Plan.CpuShare,
+		}
+	}
+	return provision.CheckPoolQuota(app.Pool, requested)
+}
+
 func (app *App) GetQuota() quota.Quota {
 	return app.Quota
 }
@@ -1333,6 +1683,21 @@ func (app *App) AddCName(cnames ...string) error {
 	return err
 }
 
+// ForceAddCName adds cnames to the app like AddCName, but skipping the checks
+// for conflicts with wildcard cnames of other apps and with other tsuru
+// installations. Cnames already attached to another app are still rejected.
+func (app *App) ForceAddCName(cnames ...string) error {
+	actions := []*action.Action{
+		&validateNewCNames,
+		&setNewCNamesToProvisioner,
+		&saveCNames,
+		&updateApp,
+	}
+	err := action.NewPipeline(actions...).Execute(app, cnames, true)
+	rebuild.RoutesRebuildOrEnqueue(app.Name)
+	return err
+}
+
 func (app *App) RemoveCName(cnames ...string) error {
 	actions := []*action.Action{
 		&checkCNameExists,
@@ -1683,7 +2048,9 @@ func Swap(app1, app2 *App, cnameOnly bool) error {
 	}
 	defer rebuild.RoutesRebuildOrEnqueue(app1.Name)
 	defer rebuild.RoutesRebuildOrEnqueue(app2.Name)
+	restoreMaintenance := swapMaintenance(app1, app2)
 	err = r1.Swap(app1.Name, app2.Name, cnameOnly)
+	restoreMaintenance(err == nil && !cnameOnly)
 	if err != nil {
 		return err
 	}
@@ -1821,9 +2188,27 @@ func (app *App) SetCertificate(name, certificate, key string) error {
 	if err != nil {
 		return err
 	}
+	store, err := certstore.Get()
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		err = store.Save(certstore.Certificate{
+			CertificateRef: app.certificateRef(name),
+			Certificate:    certificate,
+			Key:            key,
+		})
+		if err != nil {
+			return err
+		}
+	}
 	return tlsRouter.AddCertificate(name, certificate, key)
 }
 
+func (app *App) certificateRef(cname string) certstore.CertificateRef {
+	return certstore.CertificateRef{App: app.Name, Pool: app.Pool, CName: cname}
+}
+
 func (app *App) RemoveCertificate(name string) error {
 	hasCname := false
 	for _, c := range app.CName {
@@ -1842,6 +2227,16 @@ func (app *App) RemoveCertificate(name string) error {
 	if !ok {
 		return errors.New("router does not support tls")
 	}
+	store, err := certstore.Get()
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		err = store.Remove(app.certificateRef(name))
+		if err != nil && err != certstore.ErrCertificateNotFound {
+			return err
+		}
+	}
 	return tlsRouter.RemoveCertificate(name)
 }
 
@@ -1854,9 +2249,25 @@ func (app *App) GetCertificates() (map[string]string, error) {
 	if !ok {
 		return nil, errors.New("router does not support tls")
 	}
+	store, err := certstore.Get()
+	if err != nil {
+		return nil, err
+	}
 	names := append(app.CName, app.Ip)
 	certificates := make(map[string]string)
 	for _, n := range names {
+		if store != nil {
+			cert, err := store.Get(app.certificateRef(n))
+			if err != nil && err != certstore.ErrCertificateNotFound {
+				return nil, err
+			}
+			if cert != nil {
+				certificates[n] = cert.Certificate
+			} else {
+				certificates[n] = ""
+			}
+			continue
+		}
 		cert, err := tlsRouter.GetCertificate(n)
 		if err != nil && err != router.ErrCertificateNotFound {
 			return nil, err
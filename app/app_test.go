@@ -34,6 +34,7 @@ import (
 	"github.com/tsuru/tsuru/repository"
 	"github.com/tsuru/tsuru/repository/repositorytest"
 	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/certstore"
 	"github.com/tsuru/tsuru/router/routertest"
 	"github.com/tsuru/tsuru/safe"
 	"github.com/tsuru/tsuru/service"
@@ -286,6 +287,27 @@ func (s *S) TestCreateAppWithExplicitPlan(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *S) TestCreateAppWithPlanHardwareProfile(c *check.C) {
+	err := provision.AddHardwareProfile(provision.HardwareProfile{Name: "fast", DiskType: "ssd"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "fast-pool", Public: true, HardwareProfile: "fast"})
+	c.Assert(err, check.IsNil)
+	myPlan := Plan{Name: "myplan", Memory: 4194304, CpuShare: 3, HardwareProfile: "fast"}
+	err = myPlan.Save()
+	c.Assert(err, check.IsNil)
+	defer PlanRemove(myPlan.Name)
+	a := App{Name: "appname", Platform: "python", Plan: Plan{Name: "myplan"}, TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `plan "myplan" requires hardware profile "fast", which is not available in pool "pool1", compatible pools: fast-pool`)
+	a = App{Name: "appname", Platform: "python", Plan: Plan{Name: "myplan"}, TeamOwner: s.team.Name, Pool: "fast-pool"}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	retrievedApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(retrievedApp.Plan, check.DeepEquals, myPlan)
+}
+
 func (s *S) TestCreateAppUserQuotaExceeded(c *check.C) {
 	app := App{Name: "america", Platform: "python", TeamOwner: s.team.Name}
 	s.conn.Users().Update(
@@ -299,6 +321,33 @@ func (s *S) TestCreateAppUserQuotaExceeded(c *check.C) {
 	c.Assert(ok, check.Equals, true)
 }
 
+func (s *S) TestCreateAppPoolQuotaExceeded(c *check.C) {
+	err := provision.SetPoolQuota(s.Pool, provision.PoolQuota{MaxApps: 1})
+	c.Assert(err, check.IsNil)
+	app := App{Name: "america", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	other := App{Name: "europe", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&other, s.user)
+	c.Assert(err, check.DeepEquals, &provision.PoolQuotaExceededError{
+		Pool: s.Pool, Resource: "apps", Limit: 1, InUse: 1, Requested: 1,
+	})
+	_, err = GetByName(other.Name)
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
+
+func (s *S) TestCreateAppDrainingPool(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "old", Public: true})
+	c.Assert(err, check.IsNil)
+	err = provision.PoolUpdate("old", provision.UpdatePoolOptions{State: provision.PoolStateDraining})
+	c.Assert(err, check.IsNil)
+	app := App{Name: "america", Platform: "python", TeamOwner: s.team.Name, Pool: "old"}
+	err = CreateApp(&app, s.user)
+	c.Assert(err, check.DeepEquals, &errors.ValidationError{
+		Message: `pool "old" is draining and doesn't accept new apps`,
+	})
+}
+
 func (s *S) TestCreateAppTeamOwner(c *check.C) {
 	app := App{Name: "america", Platform: "python", TeamOwner: "tsuruteam"}
 	err := CreateApp(&app, s.user)
@@ -571,6 +620,42 @@ func (s *S) TestAddUnitsQuotaExceeded(c *check.C) {
 	c.Assert(units, check.HasLen, 0)
 }
 
+func (s *S) TestAddUnitsPoolQuotaExceeded(c *check.C) {
+	app := App{Name: "warpaint", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolQuota(s.Pool, provision.PoolQuota{MaxUnits: 3})
+	c.Assert(err, check.IsNil)
+	err = app.AddUnits(2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = app.AddUnits(2, "web", nil)
+	c.Assert(err, check.DeepEquals, &provision.PoolQuotaExceededError{
+		Pool: s.Pool, Resource: "units", Limit: 3, InUse: 2, Requested: 2,
+	})
+	units := s.provisioner.GetUnits(&app)
+	c.Assert(units, check.HasLen, 2)
+}
+
+func (s *S) TestAddUnitsArchivedPool(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "old", Public: true})
+	c.Assert(err, check.IsNil)
+	app := App{Name: "warpaint", Platform: "python", TeamOwner: s.team.Name, Pool: "old"}
+	err = CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.PoolUpdate("old", provision.UpdatePoolOptions{State: provision.PoolStateDraining})
+	c.Assert(err, check.IsNil)
+	err = app.AddUnits(1, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = provision.PoolUpdate("old", provision.UpdatePoolOptions{State: provision.PoolStateArchived})
+	c.Assert(err, check.IsNil)
+	err = app.AddUnits(1, "web", nil)
+	c.Assert(err, check.DeepEquals, &errors.ValidationError{
+		Message: `pool "old" is archived and doesn't accept new units`,
+	})
+	units := s.provisioner.GetUnits(&app)
+	c.Assert(units, check.HasLen, 1)
+}
+
 func (s *S) TestAddUnitsMultiple(c *check.C) {
 	app := App{
 		Name: "warpaint", Platform: "ruby",
@@ -620,6 +705,27 @@ func (s *S) TestAddUnitsIsAtomic(c *check.C) {
 	c.Assert(err, check.Equals, ErrAppNotFound)
 }
 
+func (s *S) TestAddAndRemoveUnitsDaemonApp(c *check.C) {
+	app := App{
+		Name: "warpaint", Platform: "python",
+		Quota:     quota.Unlimited,
+		TeamOwner: s.team.Name,
+		Daemon:    true,
+	}
+	err := CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.IsDaemon(), check.Equals, true)
+	err = dbApp.AddUnits(2, "web", nil)
+	c.Assert(err, check.Equals, ErrDaemonUnits)
+	err = dbApp.RemoveUnits(1, "web", nil)
+	c.Assert(err, check.Equals, ErrDaemonUnits)
+	units, err := dbApp.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 0)
+}
+
 func (s *S) TestRemoveUnitsWithQuota(c *check.C) {
 	a := App{
 		Name:      "ble",
@@ -1390,6 +1496,76 @@ func (s *S) TestAddCNameCantBeDuplicated(c *check.C) {
 	c.Assert(err.Error(), check.Equals, "cname already exists!")
 }
 
+func (s *S) TestAddCNameConflictsWithWildcard(c *check.C) {
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(app, s.user)
+	c.Assert(err, check.IsNil)
+	err = app.AddCName("*.mycompany.com")
+	c.Assert(err, check.IsNil)
+	app2 := &App{Name: "ktulu2", TeamOwner: s.team.Name}
+	err = CreateApp(app2, s.user)
+	c.Assert(err, check.IsNil)
+	err = app2.AddCName("a.b.mycompany.com")
+	c.Assert(err, check.FitsTypeOf, &CNameConflictError{})
+	c.Assert(err, check.ErrorMatches, `cname "a.b.mycompany.com" conflicts with a cname of app "ktulu"`)
+	err = app2.AddCName("*.b.mycompany.com")
+	c.Assert(err, check.FitsTypeOf, &CNameConflictError{})
+	err = app.AddCName("a.b.mycompany.com")
+	c.Assert(err, check.IsNil)
+	err = app2.ForceAddCName("b.mycompany.com")
+	c.Assert(err, check.IsNil)
+	app2, err = GetByName(app2.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(app2.CName, check.DeepEquals, []string{"b.mycompany.com"})
+}
+
+func (s *S) TestAddCNameWildcardConflictsWithExistingCName(c *check.C) {
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(app, s.user)
+	c.Assert(err, check.IsNil)
+	err = app.AddCName("www.mycompany.com")
+	c.Assert(err, check.IsNil)
+	app2 := &App{Name: "ktulu2", TeamOwner: s.team.Name}
+	err = CreateApp(app2, s.user)
+	c.Assert(err, check.IsNil)
+	err = app2.AddCName("*.mycompany.com")
+	c.Assert(err, check.ErrorMatches, `cname "\*.mycompany.com" conflicts with a cname of app "ktulu"`)
+	err = app2.AddCName("*.othercompany.com")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestAddCNameResolvingToForeignInstallation(c *check.C) {
+	config.Set("cname:foreign-installations", []string{"other-tsuru.io", "10.9.9.9"})
+	defer config.Unset("cname:foreign-installations")
+	oldLookupCNAME, oldLookupHost := lookupCNAME, lookupHost
+	defer func() {
+		lookupCNAME, lookupHost = oldLookupCNAME, oldLookupHost
+	}()
+	lookupCNAME = func(host string) (string, error) {
+		if host == "myapp.mycompany.com" {
+			return "myapp.other-tsuru.io.", nil
+		}
+		return host + ".", nil
+	}
+	lookupHost = func(host string) ([]string, error) {
+		if host == "ip.mycompany.com" {
+			return []string{"10.9.9.9"}, nil
+		}
+		return []string{"10.1.1.1"}, nil
+	}
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(app, s.user)
+	c.Assert(err, check.IsNil)
+	err = app.AddCName("myapp.mycompany.com")
+	c.Assert(err, check.ErrorMatches, `cname "myapp.mycompany.com" conflicts with another tsuru installation \(myapp.mycompany.com resolves to myapp.other-tsuru.io\)`)
+	err = app.AddCName("ip.mycompany.com")
+	c.Assert(err, check.ErrorMatches, `cname "ip.mycompany.com" conflicts with another tsuru installation \(ip.mycompany.com resolves to 10.9.9.9\)`)
+	err = app.AddCName("ok.mycompany.com")
+	c.Assert(err, check.IsNil)
+	err = app.ForceAddCName("myapp.mycompany.com")
+	c.Assert(err, check.IsNil)
+}
+
 func (s *S) TestAddCNameWithWildCard(c *check.C) {
 	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
 	err := CreateApp(app, s.user)
@@ -2175,7 +2351,7 @@ func (s *S) TestIsValid(c *check.C) {
 		{"-myapp", s.team.Name, "pool1", "fake", errMsg},
 		{"my_app", s.team.Name, "pool1", "fake", errMsg},
 		{"b", s.team.Name, "pool1", "fake", ""},
-		{InternalAppName, s.team.Name, "pool1", "fake", errMsg},
+		{InternalAppName, s.team.Name, "pool1", "fake", "Invalid app name, \"tsr\" is a reserved name."},
 		{"myapp", "invalidteam", "pool1", "fake", "team not found"},
 		{"myapp", s.team.Name, "pool1", "faketls", "router \"faketls\" is not available for pool \"pool1\""},
 		{"myapp", "noaccessteam", "pool1", "fake", "App team owner \"noaccessteam\" has no access to pool \"pool1\""},
@@ -3636,6 +3812,35 @@ func (s *S) TestAppCreateValidateRouterNotAvailableForPool(c *check.C) {
 	})
 }
 
+func (s *S) TestAppCreateValidatePlatformNotAllowedInPool(c *check.C) {
+	err := provision.SetPoolConstraint(&provision.PoolConstraint{
+		PoolExpr: "pool1",
+		Field:    "platform",
+		Values:   []string{"java"},
+	})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "test", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.DeepEquals, &errors.ValidationError{
+		Message: "platform \"python\" is not allowed in pool \"pool1\"",
+	})
+}
+
+func (s *S) TestAppCreateValidatePlanNotAllowedInPool(c *check.C) {
+	err := provision.SetPoolConstraint(&provision.PoolConstraint{
+		PoolExpr:  "pool1",
+		Field:     "plan",
+		Values:    []string{"default-*"},
+		Blacklist: true,
+	})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "test", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.DeepEquals, &errors.ValidationError{
+		Message: "plan \"default-plan\" is not allowed in pool \"pool1\"",
+	})
+}
+
 func (s *S) TestAppSetPoolByTeamOwner(c *check.C) {
 	opts := provision.AddPoolOptions{Name: "test"}
 	err := provision.AddPool(opts)
@@ -3914,6 +4119,39 @@ func (s *S) TestGetCertificates(c *check.C) {
 	c.Assert(certs, check.DeepEquals, expectedCerts)
 }
 
+func (s *S) TestCertificatesWithStore(c *check.C) {
+	config.Set("certificates:store", "database")
+	defer config.Unset("certificates:store")
+	cname := "app.io"
+	cert, err := ioutil.ReadFile("testdata/certificate.crt")
+	c.Assert(err, check.IsNil)
+	key, err := ioutil.ReadFile("testdata/private.key")
+	c.Assert(err, check.IsNil)
+	a := App{Name: "my-test-app", TeamOwner: s.team.Name, Router: "fake-tls", CName: []string{cname}}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate(cname, string(cert), string(key))
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.TLSRouter.Certs[cname], check.Equals, string(cert))
+	store, err := certstore.Get()
+	c.Assert(err, check.IsNil)
+	stored, err := store.Get(certstore.CertificateRef{App: a.Name, CName: cname})
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Certificate, check.Equals, string(cert))
+	c.Assert(stored.Key, check.Equals, string(key))
+	routertest.TLSRouter.Certs[cname] = ""
+	certs, err := a.GetCertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certs, check.DeepEquals, map[string]string{
+		"app.io":                     string(cert),
+		"my-test-app.fakerouter.com": "",
+	})
+	err = a.RemoveCertificate(cname)
+	c.Assert(err, check.IsNil)
+	_, err = store.Get(certstore.CertificateRef{App: a.Name, CName: cname})
+	c.Assert(err, check.Equals, certstore.ErrCertificateNotFound)
+}
+
 func (s *S) TestGetCertificatesNonTLSRouter(c *check.C) {
 	a := App{Name: "my-test-app", TeamOwner: s.team.Name, CName: []string{"app.io"}}
 	err := CreateApp(&a, s.user)
@@ -3958,6 +4196,26 @@ func (s *S) TestUpdateDescription(c *check.C) {
 	c.Assert(dbApp.Description, check.Equals, "bleble")
 }
 
+func (s *S) TestUpdateDeployToggles(c *check.C) {
+	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name, Description: "blabla"}
+	err := CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.GetDeployToggles(), check.DeepEquals, provision.DeployToggles{})
+	updateData := App{Name: "example", DeployToggles: &provision.DeployToggles{SkipHealthcheck: true}}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.GetDeployToggles(), check.DeepEquals, provision.DeployToggles{SkipHealthcheck: true})
+	c.Assert(dbApp.Description, check.Equals, "blabla")
+	updateData = App{Name: "example", Description: "bleble"}
+	err = dbApp.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.GetDeployToggles(), check.DeepEquals, provision.DeployToggles{SkipHealthcheck: true})
+}
+
 func (s *S) TestUpdateTeamOwner(c *check.C) {
 	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name, Description: "blabla"}
 	err := CreateApp(&app, s.user)
@@ -3987,6 +4245,53 @@ func (s *S) TestUpdateTeamOwnerNotExists(c *check.C) {
 	c.Assert(dbApp.TeamOwner, check.Equals, s.team.Name)
 }
 
+func (s *S) TestUpdatePlatform(c *check.C) {
+	err := s.conn.Platforms().Insert(Platform{Name: "java"})
+	c.Assert(err, check.IsNil)
+	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	updateData := App{Name: "example", Platform: "java"}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Platform, check.Equals, "java")
+	c.Assert(dbApp.UpdatePlatform, check.Equals, true)
+}
+
+func (s *S) TestUpdatePlatformNotAllowedInPool(c *check.C) {
+	err := s.conn.Platforms().Insert(Platform{Name: "java"})
+	c.Assert(err, check.IsNil)
+	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolConstraint(&provision.PoolConstraint{
+		PoolExpr:  "pool1",
+		Field:     "platform",
+		Values:    []string{"java"},
+		Blacklist: true,
+	})
+	c.Assert(err, check.IsNil)
+	updateData := App{Name: "example", Platform: "java"}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.DeepEquals, &errors.ValidationError{
+		Message: "platform \"java\" is not allowed in pool \"pool1\"",
+	})
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Platform, check.Equals, "python")
+}
+
+func (s *S) TestUpdatePlatformNotFound(c *check.C) {
+	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	updateData := App{Name: "example", Platform: "unknown"}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.Equals, InvalidPlatformError)
+}
+
 func (s *S) TestUpdatePool(c *check.C) {
 	opts := provision.AddPoolOptions{Name: "test"}
 	err := provision.AddPool(opts)
@@ -4026,6 +4331,56 @@ func (s *S) TestUpdatePoolNotExists(c *check.C) {
 	c.Assert(dbApp.Pool, check.Equals, "test")
 }
 
+func (s *S) TestUpdatePoolDraining(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "test", Public: true})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "test2", Public: true})
+	c.Assert(err, check.IsNil)
+	err = provision.PoolUpdate("test2", provision.UpdatePoolOptions{State: provision.PoolStateDraining})
+	c.Assert(err, check.IsNil)
+	app := App{Name: "test", TeamOwner: s.team.Name, Pool: "test"}
+	err = CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	updateData := App{Name: "test", Pool: "test2"}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.DeepEquals, &errors.ValidationError{
+		Message: `pool "test2" is draining and doesn't accept new apps`,
+	})
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "test")
+}
+
+func (s *S) TestUpdatePoolQuotaExceeded(c *check.C) {
+	opts := provision.AddPoolOptions{Name: "test"}
+	err := provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	err = provision.AddTeamsToPool("test", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	opts = provision.AddPoolOptions{Name: "test2"}
+	err = provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	err = provision.AddTeamsToPool("test2", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolQuota("test2", provision.PoolQuota{MaxUnits: 1})
+	c.Assert(err, check.IsNil)
+	app := App{Name: "test", TeamOwner: s.team.Name, Pool: "test"}
+	err = CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	err = app.AddUnits(2, "web", nil)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	updateData := App{Name: "test", Pool: "test2"}
+	err = dbApp.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.DeepEquals, &provision.PoolQuotaExceededError{
+		Pool: "test2", Resource: "units", Limit: 1, InUse: 0, Requested: 2,
+	})
+	dbApp, err = GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "test")
+}
+
 func (s *S) TestUpdatePlan(c *check.C) {
 	plan := Plan{Name: "something", CpuShare: 100, Memory: 268435456}
 	err := s.conn.Plans().Insert(plan)
@@ -4313,3 +4668,19 @@ func (s *S) TestAppUpdateRouterNotAvailableForPool(c *check.C) {
 		Message: "router \"fake-tls\" is not available for pool \"pool1\"",
 	})
 }
+
+func (s *S) TestUnitsEvents(c *check.C) {
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	now := time.Now().UTC()
+	s.provisioner.AddUnitEvent(a.Name, provision.UnitEvent{Unit: "u1", Reason: "Pulled", LastSeen: now.Add(-time.Minute)})
+	s.provisioner.AddUnitEvent(a.Name, provision.UnitEvent{Unit: "u1", Reason: "BackOff", LastSeen: now})
+	s.provisioner.AddUnitEvent(a.Name, provision.UnitEvent{Unit: "u1", Reason: "Scheduled", LastSeen: now.Add(-2 * time.Hour)})
+	events, err := a.UnitsEvents(now.Add(-time.Hour))
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.DeepEquals, []provision.UnitEvent{
+		{Unit: "u1", Reason: "BackOff", LastSeen: now},
+		{Unit: "u1", Reason: "Pulled", LastSeen: now.Add(-time.Minute)},
+	})
+}
@@ -0,0 +1,271 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/jobs"
+	"github.com/tsuru/tsuru/log"
+	tsuruNet "github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultAutoRollbackMaxFailures = 3
+	autoRollbackOwner              = "auto-rollback"
+	autoRollbackJob                = "auto-rollback-check"
+)
+
+var (
+	// autoRollbackInterval is the interval between two health checks of an
+	// app being observed after a rollout.
+	autoRollbackInterval = 30 * time.Second
+	// autoRollbackLockWait is how long a rollback waits for the lock of the
+	// app, the check is retried later when it's still locked.
+	autoRollbackLockWait = 10 * time.Second
+
+	healthcheckClient = tsuruNet.Dial5Full60ClientNoKeepAlive
+
+	ErrInvalidAutoRollback = errors.New("invalid auto rollback config: window, max unhealthy and max failures must not be negative, and max unhealthy must not be greater than 100")
+)
+
+// AutoRollback configures the observation window after the rollout of a new
+// deploy. During the window tsuru periodically checks the health of the app,
+// and if MaxFailures checks fail, the app is automatically rolled back to the
+// image deployed before. A zero Window disables the automatic rollback.
+type AutoRollback struct {
+	// Window is how long the app is observed after a rollout.
+	Window time.Duration `json:"window"`
+	// MaxUnhealthy is the percentage of units allowed to be in the error
+	// status without failing a check.
+	MaxUnhealthy int `json:"maxUnhealthy"`
+	// MaxFailures is the number of failed checks that triggers the
+	// rollback. Defaults to 3.
+	MaxFailures int `json:"maxFailures"`
+	// HealthcheckPath is an optional path requested through the router of
+	// the app on each check. Errors and responses with status code 500 or
+	// above fail the check.
+	HealthcheckPath string `json:"healthcheckPath,omitempty"`
+}
+
+func (a AutoRollback) validate() error {
+	if a.Window < 0 || a.MaxUnhealthy < 0 || a.MaxUnhealthy > 100 || a.MaxFailures < 0 {
+		return ErrInvalidAutoRollback
+	}
+	return nil
+}
+
+func (a AutoRollback) maxFailures() int {
+	if a.MaxFailures == 0 {
+		return defaultAutoRollbackMaxFailures
+	}
+	return a.MaxFailures
+}
+
+// SetAutoRollback changes the automatic rollback configuration of the app.
+func (app *App) SetAutoRollback(cfg AutoRollback) error {
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+	if cfg.HealthcheckPath != "" && !strings.HasPrefix(cfg.HealthcheckPath, "/") {
+		cfg.HealthcheckPath = "/" + cfg.HealthcheckPath
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"autorollback": cfg}})
+	if err != nil {
+		return err
+	}
+	app.AutoRollback = cfg
+	return nil
+}
+
+func init() {
+	jobs.Register(autoRollbackJob, runAutoRollbackCheck)
+}
+
+// observeRollout starts watching the app for the configured window after
+// deployedImage is rolled out, rolling back to the previous valid image if the
+// app becomes unhealthy. Each check runs as a job, so the observation is
+// resumed by any API instance after a restart. The observation ends as soon
+// as the window ends, the rollback is done or a newer image is deployed. The
+// rollback event is correlated with deployEvt, the event of the deploy being
+// observed.
+func observeRollout(appName, deployedImage string, deployEvt *event.Event) error {
+	a, err := GetByName(appName)
+	if err != nil || a.AutoRollback.Window <= 0 {
+		return err
+	}
+	previousImage, err := previousValidImage(appName, deployedImage)
+	if err != nil || previousImage == "" {
+		return err
+	}
+	params := jobs.Params{
+		"app":      appName,
+		"image":    deployedImage,
+		"previous": previousImage,
+		"deadline": time.Now().Add(a.AutoRollback.Window).UTC().Format(time.RFC3339Nano),
+		"failures": "",
+	}
+	if deployEvt != nil {
+		params["parent"] = deployEvt.UniqueID.Hex()
+	}
+	_, err = jobs.Enqueue(autoRollbackJob, autoRollbackJob+":"+appName+":"+deployedImage, params)
+	return err
+}
+
+// runAutoRollbackCheck runs one health check of an observed rollout,
+// keeping the failed checks in the params of the job.
+func runAutoRollbackCheck(params jobs.Params) error {
+	appName, deployedImage := params["app"], params["image"]
+	deadline, err := time.Parse(time.RFC3339Nano, params["deadline"])
+	if err != nil {
+		return err
+	}
+	if time.Now().After(deadline) {
+		return nil
+	}
+	current, err := image.AppCurrentImageName(appName)
+	if err != nil || current != deployedImage {
+		return nil
+	}
+	a, err := GetByName(appName)
+	if err == ErrAppNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cfg := a.AutoRollback
+	if cfg.Window <= 0 {
+		return nil
+	}
+	var failures []string
+	if params["failures"] != "" {
+		failures = strings.Split(params["failures"], "\n")
+	}
+	reason := a.rolloutHealthCheck(cfg)
+	if reason != "" {
+		failures = append(failures, reason)
+		params["failures"] = strings.Join(failures, "\n")
+	}
+	if len(failures) < cfg.maxFailures() {
+		return jobs.RunAgain(autoRollbackInterval)
+	}
+	var parent *event.Event
+	if bson.IsObjectIdHex(params["parent"]) {
+		parent, err = event.GetByID(bson.ObjectIdHex(params["parent"]))
+		if err != nil {
+			log.Errorf("[auto rollback] unable to find deploy event of app %q: %s", appName, err)
+		}
+	}
+	err = a.autoRollback(params["previous"], deployedImage, failures, parent)
+	if err != nil {
+		return errors.Wrapf(err, "unable to rollback app %q to %q", appName, params["previous"])
+	}
+	return nil
+}
+
+func previousValidImage(appName, deployedImage string) (string, error) {
+	images, err := image.ListValidAppImages(appName)
+	if err != nil {
+		return "", err
+	}
+	for i := len(images) - 1; i > 0; i-- {
+		if images[i] == deployedImage {
+			return images[i-1], nil
+		}
+	}
+	return "", nil
+}
+
+// rolloutHealthCheck returns the reason why the app is considered unhealthy,
+// or an empty string when it's healthy.
+func (app *App) rolloutHealthCheck(cfg AutoRollback) string {
+	units, err := app.Units()
+	if err != nil {
+		return fmt.Sprintf("unable to list units: %s", err)
+	}
+	var unhealthy int
+	for _, u := range units {
+		if u.Status == provision.StatusError {
+			unhealthy++
+		}
+	}
+	if len(units) > 0 && unhealthy*100/len(units) > cfg.MaxUnhealthy {
+		return fmt.Sprintf("%d of %d units in error status", unhealthy, len(units))
+	}
+	if cfg.HealthcheckPath == "" || app.Ip == "" {
+		return ""
+	}
+	url := fmt.Sprintf("http://%s%s", app.Ip, cfg.HealthcheckPath)
+	rsp, err := healthcheckClient.Get(url)
+	if err != nil {
+		return fmt.Sprintf("healthcheck %s failed: %s", url, err)
+	}
+	defer rsp.Body.Close()
+	ioutil.ReadAll(rsp.Body)
+	if rsp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Sprintf("healthcheck %s returned status %d", url, rsp.StatusCode)
+	}
+	return ""
+}
+
+// autoRollback deploys toImage again, holding the lock of the app like
+// deploys made through the API.
+func (app *App) autoRollback(toImage, fromImage string, reasons []string, parent *event.Event) (err error) {
+	locked, err := AcquireApplicationLockWait(app.Name, autoRollbackOwner, "auto rollback", autoRollbackLockWait)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return errors.Errorf("app %q is locked", app.Name)
+	}
+	defer ReleaseApplicationLock(app.Name)
+	opts := DeployOptions{
+		App:          app,
+		Image:        toImage,
+		Rollback:     true,
+		Origin:       "rollback",
+		User:         autoRollbackOwner,
+		Message:      fmt.Sprintf("automatic rollback of %s: %s", fromImage, strings.Join(reasons, "; ")),
+		OutputStream: ioutil.Discard,
+	}
+	contexts := append(permission.Contexts(permission.CtxTeam, app.Teams),
+		permission.Context(permission.CtxApp, app.Name),
+		permission.Context(permission.CtxPool, app.Pool),
+	)
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: app.Name},
+		Kind:       permission.PermAppDeploy,
+		RawOwner:   event.Owner{Type: event.OwnerTypeInternal, Name: autoRollbackOwner},
+		CustomData: opts,
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contexts...),
+		Parent:     parent,
+	})
+	if err != nil {
+		return err
+	}
+	var imageID string
+	defer func() { evt.DoneCustomData(err, map[string]string{"image": imageID}) }()
+	opts.Event = evt
+	imageID, err = Deploy(opts)
+	return err
+}
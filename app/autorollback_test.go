@@ -0,0 +1,164 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/jobs"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+// runRolloutChecks runs the auto rollback checks until the observation ends.
+func (s *S) runRolloutChecks(c *check.C) {
+	for i := 0; i < 1000; i++ {
+		pending, err := jobs.List(jobs.Filter{Kind: autoRollbackJob, Status: jobs.StatusPending})
+		c.Assert(err, check.IsNil)
+		if len(pending) == 0 {
+			return
+		}
+		_, err = jobs.RunPending()
+		c.Assert(err, check.IsNil)
+		time.Sleep(autoRollbackInterval)
+	}
+	c.Fatal("rollout observation didn't finish")
+}
+
+func (s *S) TestSetAutoRollback(c *check.C) {
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetAutoRollback(AutoRollback{Window: 5 * time.Minute, MaxUnhealthy: 20, HealthcheckPath: "healthcheck"})
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.AutoRollback, check.DeepEquals, AutoRollback{
+		Window:          5 * time.Minute,
+		MaxUnhealthy:    20,
+		HealthcheckPath: "/healthcheck",
+	})
+	c.Assert(dbApp.AutoRollback.maxFailures(), check.Equals, defaultAutoRollbackMaxFailures)
+	err = a.SetAutoRollback(AutoRollback{Window: time.Minute, MaxUnhealthy: 101})
+	c.Assert(err, check.Equals, ErrInvalidAutoRollback)
+	err = a.SetAutoRollback(AutoRollback{Window: -time.Minute})
+	c.Assert(err, check.Equals, ErrInvalidAutoRollback)
+}
+
+func (s *S) TestObserveRolloutRollsBackUnhealthyApp(c *check.C) {
+	oldInterval := autoRollbackInterval
+	autoRollbackInterval = time.Millisecond
+	defer func() { autoRollbackInterval = oldInterval }()
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "registry.somewhere/tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "registry.somewhere/tsuru/app-myapp:v2")
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 2, "web", nil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.SetUnitStatus(units[0], provision.StatusError)
+	c.Assert(err, check.IsNil)
+	err = a.SetAutoRollback(AutoRollback{Window: time.Minute, MaxUnhealthy: 10, MaxFailures: 2})
+	c.Assert(err, check.IsNil)
+	deployEvt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	err = deployEvt.Done(nil)
+	c.Assert(err, check.IsNil)
+	err = observeRollout(a.Name, "registry.somewhere/tsuru/app-myapp:v2", deployEvt)
+	c.Assert(err, check.IsNil)
+	s.runRolloutChecks(c)
+	evts, err := event.List(&event.Filter{
+		Target:    event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		KindName:  "app.deploy",
+		OwnerName: autoRollbackOwner,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Owner.Type, check.Equals, event.OwnerTypeInternal)
+	c.Assert(evts[0].Error, check.Equals, "")
+	c.Assert(evts[0].ParentID, check.Equals, deployEvt.UniqueID)
+	c.Assert(evts[0].CorrelationID, check.Equals, deployEvt.UniqueID)
+	var opts DeployOptions
+	err = evts[0].StartData(&opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(opts.Rollback, check.Equals, true)
+	c.Assert(opts.Image, check.Equals, "registry.somewhere/tsuru/app-myapp:v1")
+	c.Assert(opts.Message, check.Equals, "automatic rollback of registry.somewhere/tsuru/app-myapp:v2: 1 of 2 units in error status; 1 of 2 units in error status")
+}
+
+func (s *S) TestObserveRolloutHealthyApp(c *check.C) {
+	oldInterval := autoRollbackInterval
+	autoRollbackInterval = time.Millisecond
+	defer func() { autoRollbackInterval = oldInterval }()
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "registry.somewhere/tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "registry.somewhere/tsuru/app-myapp:v2")
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 2, "web", nil)
+	err = a.SetAutoRollback(AutoRollback{Window: 20 * time.Millisecond, MaxFailures: 1})
+	c.Assert(err, check.IsNil)
+	err = observeRollout(a.Name, "registry.somewhere/tsuru/app-myapp:v2", nil)
+	c.Assert(err, check.IsNil)
+	s.runRolloutChecks(c)
+	evts, err := event.List(&event.Filter{
+		Target:    event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		KindName:  "app.deploy",
+		OwnerName: autoRollbackOwner,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
+func (s *S) TestObserveRolloutWaitsForAppLock(c *check.C) {
+	oldInterval, oldLockWait := autoRollbackInterval, autoRollbackLockWait
+	autoRollbackInterval, autoRollbackLockWait = time.Millisecond, time.Millisecond
+	defer func() { autoRollbackInterval, autoRollbackLockWait = oldInterval, oldLockWait }()
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "registry.somewhere/tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "registry.somewhere/tsuru/app-myapp:v2")
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 1, "web", nil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.SetUnitStatus(units[0], provision.StatusError)
+	c.Assert(err, check.IsNil)
+	err = a.SetAutoRollback(AutoRollback{Window: time.Minute, MaxFailures: 1})
+	c.Assert(err, check.IsNil)
+	locked, err := AcquireApplicationLock(a.Name, s.user.Email, "deploy")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	err = observeRollout(a.Name, "registry.somewhere/tsuru/app-myapp:v2", nil)
+	c.Assert(err, check.IsNil)
+	_, err = jobs.RunPending()
+	c.Assert(err, check.IsNil)
+	pending, err := jobs.List(jobs.Filter{Kind: autoRollbackJob, Status: jobs.StatusPending})
+	c.Assert(err, check.IsNil)
+	c.Assert(pending, check.HasLen, 1)
+	c.Assert(pending[0].LastError, check.Matches, `unable to rollback app "myapp" .*: app "myapp" is locked`)
+	evts, err := event.List(&event.Filter{
+		Target:    event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		KindName:  "app.deploy",
+		OwnerName: autoRollbackOwner,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
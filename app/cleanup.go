@@ -0,0 +1,53 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/jobs"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2"
+)
+
+const removeRouterBackendJob = "remove-router-backend"
+
+func init() {
+	jobs.Register(removeRouterBackendJob, runRemoveRouterBackendJob)
+}
+
+// enqueueRouterBackendRemoval retries removing the backend of a removed app
+// in background. The backend name is kept in the database until the backend
+// is removed, as routers need it to find the backend.
+func enqueueRouterBackendRemoval(routerName, appName string) error {
+	_, err := jobs.Enqueue(removeRouterBackendJob, removeRouterBackendJob+":"+routerName+":"+appName, jobs.Params{
+		"router": routerName,
+		"app":    appName,
+	})
+	return err
+}
+
+func runRemoveRouterBackendJob(params jobs.Params) error {
+	appName := params["app"]
+	// An app created with the same name owns the backend now.
+	_, err := GetByName(appName)
+	if err == nil {
+		return nil
+	}
+	if err != ErrAppNotFound {
+		return err
+	}
+	r, err := router.Get(params["router"])
+	if err != nil {
+		return err
+	}
+	err = r.RemoveBackend(appName)
+	if err != nil && err != router.ErrBackendNotFound {
+		return err
+	}
+	err = router.Remove(appName)
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	return nil
+}
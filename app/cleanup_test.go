@@ -0,0 +1,45 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/jobs"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDeleteEnqueuesRouterBackendRemoval(c *check.C) {
+	a := App{Name: "ritual", Platform: "ruby", Owner: s.user.Email, TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	routertest.FakeRouter.FailForIp(a.Name)
+	defer routertest.FakeRouter.RemoveFailForIp(a.Name)
+	err = Delete(&a, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, true)
+	_, err = router.Retrieve(a.Name)
+	c.Assert(err, check.IsNil)
+	list, err := jobs.List(jobs.Filter{Kind: removeRouterBackendJob})
+	c.Assert(err, check.IsNil)
+	c.Assert(list, check.HasLen, 1)
+	c.Assert(list[0].Params, check.DeepEquals, jobs.Params{"router": "fake", "app": a.Name})
+	routertest.FakeRouter.RemoveFailForIp(a.Name)
+	n, err := jobs.RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, false)
+	_, err = router.Retrieve(a.Name)
+	c.Assert(err, check.Equals, router.ErrBackendNotFound)
+}
+
+func (s *S) TestRemoveRouterBackendJobAppRecreated(c *check.C) {
+	a := App{Name: "ritual", Platform: "ruby", Owner: s.user.Email, TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = runRemoveRouterBackendJob(jobs.Params{"router": "fake", "app": a.Name})
+	c.Assert(err, check.IsNil)
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, true)
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	lookupCNAME = net.LookupCNAME
+	lookupHost  = net.LookupHost
+
+	cnameRegexp = regexp.MustCompile(`^(\*\.)?[a-zA-Z0-9][\w-.]+$`)
+)
+
+// CNameConflictError is returned when adding a cname that may take traffic
+// from another app, because it overlaps with a wildcard cname, or from
+// another tsuru installation, because the name currently resolves to it.
+type CNameConflictError struct {
+	CName  string
+	Reason string
+}
+
+func (e *CNameConflictError) Error() string {
+	return fmt.Sprintf("cname %q conflicts with %s", e.CName, e.Reason)
+}
+
+// checkCNameConflicts looks for wildcard overlaps between the cname and the
+// cnames of other apps and checks whether the cname resolves to one of the
+// installations listed in the cname:foreign-installations setting.
+func checkCNameConflicts(conn *db.Storage, app *App, cname string) error {
+	var query bson.M
+	if strings.HasPrefix(cname, "*.") {
+		suffix := strings.TrimPrefix(cname, "*")
+		query = bson.M{"cname": bson.M{"$regex": regexp.QuoteMeta(suffix) + "$"}}
+	} else {
+		var wildcards []string
+		parts := strings.Split(cname, ".")
+		for i := 1; i < len(parts)-1; i++ {
+			wildcards = append(wildcards, "*."+strings.Join(parts[i:], "."))
+		}
+		if len(wildcards) > 0 {
+			query = bson.M{"cname": bson.M{"$in": wildcards}}
+		}
+	}
+	if query == nil {
+		return checkForeignInstallation(cname)
+	}
+	query["name"] = bson.M{"$ne": app.Name}
+	var other App
+	err := conn.Apps().Find(query).Select(bson.M{"name": 1}).One(&other)
+	if err == nil {
+		return &CNameConflictError{
+			CName:  cname,
+			Reason: fmt.Sprintf("a cname of app %q", other.Name),
+		}
+	}
+	if strings.HasPrefix(cname, "*.") {
+		return nil
+	}
+	return checkForeignInstallation(cname)
+}
+
+func checkForeignInstallation(cname string) error {
+	installations, _ := config.GetList("cname:foreign-installations")
+	if len(installations) == 0 {
+		return nil
+	}
+	target, err := lookupCNAME(cname)
+	if err == nil {
+		target = strings.TrimSuffix(target, ".")
+		for _, inst := range installations {
+			inst = strings.TrimSuffix(inst, ".")
+			if target == inst || strings.HasSuffix(target, "."+inst) {
+				return &CNameConflictError{
+					CName:  cname,
+					Reason: fmt.Sprintf("another tsuru installation (%s resolves to %s)", cname, target),
+				}
+			}
+		}
+	}
+	addrs, err := lookupHost(cname)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		for _, inst := range installations {
+			if addr == inst {
+				return &CNameConflictError{
+					CName:  cname,
+					Reason: fmt.Sprintf("another tsuru installation (%s resolves to %s)", cname, addr),
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultCNameVerificationPrefix = "_tsuru-challenge"
+
+var lookupTXT = net.LookupTXT
+
+var ErrCNameVerificationNotFound = errors.New("cname verification not found")
+
+// CNameVerification is the DNS challenge proving that the team of an app
+// controls the domain of a cname. The cname is verified once a TXT record
+// with the token is found in the record name.
+type CNameVerification struct {
+	CName      string    `json:"cname"`
+	App        string    `json:"app"`
+	Record     string    `json:"record"`
+	Token      string    `json:"token"`
+	Verified   bool      `json:"verified"`
+	CreatedAt  time.Time `json:"createdAt"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+// CNameNotVerifiedError is returned when adding a cname whose ownership
+// wasn't verified, with cname verification enabled.
+type CNameNotVerifiedError struct {
+	CName  string
+	Record string
+}
+
+func (e *CNameNotVerifiedError) Error() string {
+	return fmt.Sprintf("ownership of cname %q not verified, create a TXT record %s with the verification token and verify it", e.CName, e.Record)
+}
+
+func cnameVerificationEnabled() bool {
+	enabled, _ := config.GetBool("cname:verification:enabled")
+	return enabled
+}
+
+// cnameVerificationRecord returns the name of the TXT record holding the
+// token, wildcard cnames are verified in their parent domain.
+func cnameVerificationRecord(cname string) string {
+	prefix, _ := config.GetString("cname:verification:record-prefix")
+	if prefix == "" {
+		prefix = defaultCNameVerificationPrefix
+	}
+	return prefix + "." + strings.TrimPrefix(cname, "*.")
+}
+
+// GetCNameVerification returns the verification of the cname for the app.
+func (app *App) GetCNameVerification(cname string) (*CNameVerification, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var verification CNameVerification
+	err = conn.CNameVerifications().Find(bson.M{"cname": cname, "app": app.Name}).One(&verification)
+	if err == mgo.ErrNotFound {
+		return nil, ErrCNameVerificationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// VerifyCName looks for the TXT record of the verification of the cname for
+// the app, starting a new verification with a random token when there's
+// none.
+func (app *App) VerifyCName(cname string) (*CNameVerification, error) {
+	if !cnameRegexp.MatchString(cname) {
+		return nil, ErrInvalidCName
+	}
+	verification, err := app.GetCNameVerification(cname)
+	if err == ErrCNameVerificationNotFound {
+		verification, err = app.newCNameVerification(cname)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if verification.Verified {
+		return verification, nil
+	}
+	records, err := lookupTXT(verification.Record)
+	if err != nil {
+		return verification, nil
+	}
+	for _, record := range records {
+		if record == verification.Token {
+			verification.Verified = true
+			verification.VerifiedAt = time.Now().UTC()
+			break
+		}
+	}
+	if !verification.Verified {
+		return verification, nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.CNameVerifications().Update(bson.M{"cname": cname, "app": app.Name}, bson.M{
+		"$set": bson.M{"verified": true, "verifiedat": verification.VerifiedAt},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
+
+func (app *App) newCNameVerification(cname string) (*CNameVerification, error) {
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+	verification := CNameVerification{
+		CName:     cname,
+		App:       app.Name,
+		Record:    cnameVerificationRecord(cname),
+		Token:     fmt.Sprintf("tsuru-verification=%x", randomBytes),
+		CreatedAt: time.Now().UTC(),
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.CNameVerifications().Insert(verification)
+	if mgo.IsDup(err) {
+		return app.GetCNameVerification(cname)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// checkCNameVerified returns an error when cname verification is enabled and
+// the ownership of the cname wasn't verified for the app.
+func checkCNameVerified(conn *db.Storage, app *App, cname string) error {
+	if !cnameVerificationEnabled() {
+		return nil
+	}
+	n, err := conn.CNameVerifications().Find(bson.M{"cname": cname, "app": app.Name, "verified": true}).Count()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &CNameNotVerifiedError{CName: cname, Record: cnameVerificationRecord(cname)}
+	}
+	return nil
+}
+
+func removeCNameVerifications(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.CNameVerifications().RemoveAll(bson.M{"app": appName})
+	return err
+}
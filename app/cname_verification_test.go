@@ -0,0 +1,136 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestVerifyCName(c *check.C) {
+	oldLookupTXT := lookupTXT
+	defer func() { lookupTXT = oldLookupTXT }()
+	var txtRecords []string
+	var lookedUp []string
+	lookupTXT = func(name string) ([]string, error) {
+		lookedUp = append(lookedUp, name)
+		if txtRecords == nil {
+			return nil, errors.New("no such host")
+		}
+		return txtRecords, nil
+	}
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(app, s.user)
+	c.Assert(err, check.IsNil)
+	verification, err := app.VerifyCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(verification.CName, check.Equals, "ktulu.mycompany.com")
+	c.Assert(verification.App, check.Equals, "ktulu")
+	c.Assert(verification.Record, check.Equals, "_tsuru-challenge.ktulu.mycompany.com")
+	c.Assert(verification.Token, check.Matches, "tsuru-verification=[0-9a-f]{32}")
+	c.Assert(verification.Verified, check.Equals, false)
+	token := verification.Token
+	txtRecords = []string{"other"}
+	verification, err = app.VerifyCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(verification.Token, check.Equals, token)
+	c.Assert(verification.Verified, check.Equals, false)
+	txtRecords = []string{"other", token}
+	verification, err = app.VerifyCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(verification.Verified, check.Equals, true)
+	c.Assert(verification.VerifiedAt.IsZero(), check.Equals, false)
+	verification, err = app.GetCNameVerification("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(verification.Verified, check.Equals, true)
+	c.Assert(lookedUp, check.DeepEquals, []string{
+		"_tsuru-challenge.ktulu.mycompany.com",
+		"_tsuru-challenge.ktulu.mycompany.com",
+		"_tsuru-challenge.ktulu.mycompany.com",
+	})
+}
+
+func (s *S) TestVerifyCNameWildcardAndPrefix(c *check.C) {
+	config.Set("cname:verification:record-prefix", "_check")
+	defer config.Unset("cname:verification:record-prefix")
+	oldLookupTXT := lookupTXT
+	defer func() { lookupTXT = oldLookupTXT }()
+	lookupTXT = func(name string) ([]string, error) {
+		return nil, errors.New("no such host")
+	}
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(app, s.user)
+	c.Assert(err, check.IsNil)
+	verification, err := app.VerifyCName("*.mycompany.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(verification.Record, check.Equals, "_check.mycompany.com")
+}
+
+func (s *S) TestVerifyCNameInvalid(c *check.C) {
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(app, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = app.VerifyCName("-invalid")
+	c.Assert(err, check.Equals, ErrInvalidCName)
+}
+
+func (s *S) TestGetCNameVerificationNotFound(c *check.C) {
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
+	_, err := app.GetCNameVerification("ktulu.mycompany.com")
+	c.Assert(err, check.Equals, ErrCNameVerificationNotFound)
+}
+
+func (s *S) TestAddCNameWithVerificationEnabled(c *check.C) {
+	config.Set("cname:verification:enabled", true)
+	defer config.Unset("cname:verification:enabled")
+	oldLookupTXT := lookupTXT
+	defer func() { lookupTXT = oldLookupTXT }()
+	var txtRecords []string
+	lookupTXT = func(name string) ([]string, error) {
+		return txtRecords, nil
+	}
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(app, s.user)
+	c.Assert(err, check.IsNil)
+	err = app.AddCName("ktulu.mycompany.com")
+	c.Assert(err, check.FitsTypeOf, &CNameNotVerifiedError{})
+	c.Assert(err, check.ErrorMatches, `ownership of cname "ktulu.mycompany.com" not verified, .*_tsuru-challenge.ktulu.mycompany.com.*`)
+	verification, err := app.VerifyCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	txtRecords = []string{verification.Token}
+	_, err = app.VerifyCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	err = app.AddCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	other := &App{Name: "ktulu2", TeamOwner: s.team.Name}
+	err = CreateApp(other, s.user)
+	c.Assert(err, check.IsNil)
+	err = other.AddCName("ktulu2.mycompany.com")
+	c.Assert(err, check.FitsTypeOf, &CNameNotVerifiedError{})
+	err = other.ForceAddCName("ktulu2.mycompany.com")
+	c.Assert(err, check.IsNil)
+	app, err = GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.CName, check.DeepEquals, []string{"ktulu.mycompany.com"})
+}
+
+func (s *S) TestRemoveCNameVerifications(c *check.C) {
+	oldLookupTXT := lookupTXT
+	defer func() { lookupTXT = oldLookupTXT }()
+	lookupTXT = func(name string) ([]string, error) {
+		return nil, nil
+	}
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name, Platform: "python"}
+	err := CreateApp(app, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = app.VerifyCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	err = removeCNameVerifications(app.Name)
+	c.Assert(err, check.IsNil)
+	_, err = app.GetCNameVerification("ktulu.mycompany.com")
+	c.Assert(err, check.Equals, ErrCNameVerificationNotFound)
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SetConfigTemplate adds the config template to the app, replacing the
+// template with the same name, if any. Provisioners render the new template
+// in the next deploy or restart of the app.
+func (app *App) SetConfigTemplate(t provision.ConfigTemplate) error {
+	err := t.Validate()
+	if err != nil {
+		return err
+	}
+	templates := withConfigTemplate(app.ConfigTemplates, t)
+	for _, other := range templates {
+		if other.Name != t.Name && other.Path == t.Path {
+			return provision.ErrConfigTemplatePathUsed
+		}
+	}
+	return app.saveConfigTemplates(templates)
+}
+
+// RemoveConfigTemplate removes the config template with the given name.
+func (app *App) RemoveConfigTemplate(name string) error {
+	var templates []provision.ConfigTemplate
+	for _, t := range app.ConfigTemplates {
+		if t.Name != name {
+			templates = append(templates, t)
+		}
+	}
+	if len(templates) == len(app.ConfigTemplates) {
+		return provision.ErrConfigTemplateNotFound
+	}
+	return app.saveConfigTemplates(templates)
+}
+
+// PreviewConfigTemplates renders the config templates of the app for the
+// given process without changing anything. When t is not nil, it's rendered
+// in place of the template with the same name, allowing templates to be
+// validated before being set.
+func (app *App) PreviewConfigTemplates(process string, t *provision.ConfigTemplate) ([]provision.RenderedConfigTemplate, error) {
+	templates := app.ConfigTemplates
+	if t != nil {
+		err := t.Validate()
+		if err != nil {
+			return nil, err
+		}
+		templates = withConfigTemplate(templates, *t)
+	}
+	return provision.RenderConfigTemplates(app, process, templates)
+}
+
+func withConfigTemplate(templates []provision.ConfigTemplate, t provision.ConfigTemplate) []provision.ConfigTemplate {
+	result := make([]provision.ConfigTemplate, 0, len(templates)+1)
+	var replaced bool
+	for _, other := range templates {
+		if other.Name == t.Name {
+			other = t
+			replaced = true
+		}
+		result = append(result, other)
+	}
+	if !replaced {
+		result = append(result, t)
+	}
+	return result
+}
+
+func (app *App) saveConfigTemplates(templates []provision.ConfigTemplate) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var update bson.M
+	if len(templates) == 0 {
+		update = bson.M{"$unset": bson.M{"configtemplates": ""}}
+	} else {
+		update = bson.M{"$set": bson.M{"configtemplates": templates}}
+	}
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	app.ConfigTemplates = templates
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetConfigTemplate(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	nginx := provision.ConfigTemplate{Name: "nginx.conf", Path: "/etc/nginx.conf", Content: "listen {{.Env.PORT}};"}
+	err = a.SetConfigTemplate(nginx)
+	c.Assert(err, check.IsNil)
+	err = a.SetConfigTemplate(provision.ConfigTemplate{Name: "app.ini", Path: "/etc/app.ini", Content: "app={{.App}}"})
+	c.Assert(err, check.IsNil)
+	nginx.Content = "listen 80;"
+	err = a.SetConfigTemplate(nginx)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.GetConfigTemplates(), check.DeepEquals, []provision.ConfigTemplate{
+		nginx,
+		{Name: "app.ini", Path: "/etc/app.ini", Content: "app={{.App}}"},
+	})
+	err = a.SetConfigTemplate(provision.ConfigTemplate{Name: "other.conf", Path: "/etc/nginx.conf"})
+	c.Assert(err, check.Equals, provision.ErrConfigTemplatePathUsed)
+	err = a.SetConfigTemplate(provision.ConfigTemplate{Name: "other.conf", Path: "etc/other.conf"})
+	c.Assert(err, check.Equals, provision.ErrInvalidConfigTemplate)
+}
+
+func (s *S) TestRemoveConfigTemplate(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetConfigTemplate(provision.ConfigTemplate{Name: "nginx.conf", Path: "/etc/nginx.conf"})
+	c.Assert(err, check.IsNil)
+	err = a.RemoveConfigTemplate("nginx.conf")
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ConfigTemplates, check.HasLen, 0)
+	err = a.RemoveConfigTemplate("nginx.conf")
+	c.Assert(err, check.Equals, provision.ErrConfigTemplateNotFound)
+}
+
+func (s *S) TestPreviewConfigTemplates(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetConfigTemplate(provision.ConfigTemplate{Name: "app.ini", Path: "/etc/app.ini", Content: "app={{.App}}"})
+	c.Assert(err, check.IsNil)
+	rendered, err := a.PreviewConfigTemplates("web", &provision.ConfigTemplate{
+		Name: "nginx.conf", Path: "/etc/nginx.conf", Content: "listen {{.Env.PORT}};",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(rendered, check.DeepEquals, []provision.RenderedConfigTemplate{
+		{Name: "app.ini", Path: "/etc/app.ini", Content: "app=myapp"},
+		{Name: "nginx.conf", Path: "/etc/nginx.conf", Content: "listen 8888;"},
+	})
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ConfigTemplates, check.HasLen, 1)
+	_, err = a.PreviewConfigTemplates("web", &provision.ConfigTemplate{
+		Name: "app.ini", Path: "/etc/app.ini", Content: "{{.Env.MISSING}}",
+	})
+	c.Assert(err, check.ErrorMatches, `unable to render config template "app.ini".*`)
+}
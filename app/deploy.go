@@ -14,6 +14,9 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/deployfreeze"
+	"github.com/tsuru/tsuru/deployqueue"
+	"github.com/tsuru/tsuru/deploystatus"
 	"github.com/tsuru/tsuru/event"
 	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/log"
@@ -35,6 +38,7 @@ const (
 	DeployUpload      DeployKind = "upload"
 	DeployUploadBuild DeployKind = "uploadbuild"
 	DeployRebuild     DeployKind = "rebuild"
+	DeployPromote     DeployKind = "promote"
 )
 
 var reImageVersion = regexp.MustCompile("v[0-9]+$")
@@ -53,6 +57,8 @@ type DeployData struct {
 	CanRollback bool
 	RemoveDate  time.Time `bson:",omitempty"`
 	Diff        string
+	SourceApp   string `json:",omitempty"`
+	SourceImage string `json:",omitempty"`
 }
 
 func findValidImages(apps ...App) (set.Set, error) {
@@ -130,6 +136,8 @@ func eventToDeployData(evt *event.Event, validImages set.Set, full bool) *Deploy
 	if err == nil {
 		data.Commit = startOpts.Commit
 		data.Origin = startOpts.GetOrigin()
+		data.SourceApp = startOpts.SourceApp
+		data.SourceImage = startOpts.SourceImage
 	}
 	if full {
 		data.Log = evt.Log
@@ -169,6 +177,9 @@ type DeployOptions struct {
 	Event        *event.Event `bson:"-"`
 	Kind         DeployKind
 	Message      string
+	SourceApp    string `bson:",omitempty"`
+	SourceImage  string `bson:",omitempty"`
+	Repository   string `bson:",omitempty"`
 }
 
 func (o *DeployOptions) GetOrigin() string {
@@ -188,6 +199,9 @@ func (o *DeployOptions) GetKind() (kind DeployKind) {
 	if o.Rollback {
 		return DeployRollback
 	}
+	if o.SourceApp != "" {
+		return DeployPromote
+	}
 	if o.Image != "" {
 		return DeployImage
 	}
@@ -210,6 +224,7 @@ func Deploy(opts DeployOptions) (string, error) {
 	if opts.Event == nil {
 		return "", errors.Errorf("missing event in deploy opts")
 	}
+	notifyDeployStatus(opts.statusDeploy(), deploystatus.StageQueued)
 	if opts.Rollback && !regexp.MustCompile(":v[0-9]+$").MatchString(opts.Image) {
 		validImages, err := findValidImages(*opts.App)
 		if err == nil {
@@ -225,15 +240,61 @@ func Deploy(opts DeployOptions) (string, error) {
 			}
 		}
 	}
+	if opts.SourceApp != "" && opts.SourceImage == "" {
+		err := opts.ResolveSourceImage()
+		if err != nil {
+			return "", err
+		}
+	}
+	err := opts.App.validateNodeRequirements()
+	if err != nil {
+		return "", err
+	}
+	// Rollbacks are allowed during freezes, reverting a broken deploy must
+	// not require an exception.
+	if opts.GetKind() != DeployRollback {
+		err = deployfreeze.Check(opts.App.Name, opts.App.Pool)
+		if err != nil {
+			return "", err
+		}
+	}
 	logWriter := LogWriter{App: opts.App}
 	logWriter.Async()
 	defer logWriter.Close()
 	opts.Event.SetLogWriter(io.MultiWriter(&tsuruIo.NoErrorWriter{Writer: opts.OutputStream}, &logWriter))
+	statusDeploy := opts.statusDeploy()
+	var previous *DeployOptions
+	if opts.GetKind() == DeployRollback {
+		previous, _ = lastDeployOptions(opts.App.Name)
+	}
+	queueDone, err := deployqueue.Wait(opts.App.Name, opts.App.TeamOwner, opts.Event)
+	if err != nil {
+		notifyDeployStatus(statusDeploy, deploystatus.StageFailed)
+		return "", err
+	}
+	defer queueDone()
+	err = opts.Event.Checkpoint("build")
+	if err != nil {
+		notifyDeployStatus(statusDeploy, deploystatus.StageFailed)
+		return "", err
+	}
+	notifyDeployStatus(statusDeploy, deploystatus.StageBuilding)
 	imageId, err := deployToProvisioner(&opts, opts.Event)
 	rebuild.RoutesRebuildOrEnqueue(opts.App.Name)
 	if err != nil {
+		notifyDeployStatus(statusDeploy, deploystatus.StageFailed)
 		return "", err
 	}
+	notifyDeployStatus(statusDeploy, deploystatus.StageRolledOut)
+	if previous != nil {
+		notifyDeployStatus(previous.statusDeploy(), deploystatus.StageRolledBack)
+	}
+	if opts.Kind != DeployRollback && opts.App.AutoRollback.Window > 0 && !opts.App.GetDeployToggles().DisableAutoRollback {
+		err = observeRollout(opts.App.Name, imageId, opts.Event)
+		if err != nil {
+			log.Errorf("[auto rollback] unable to observe rollout of app %q: %s", opts.App.Name, err)
+		}
+	}
 	err = incrementDeploy(opts.App)
 	if err != nil {
 		log.Errorf("WARNING: couldn't increment deploy count, deploy opts: %#v", opts)
@@ -244,6 +305,47 @@ func Deploy(opts DeployOptions) (string, error) {
 	return imageId, nil
 }
 
+func (o *DeployOptions) statusDeploy() deploystatus.Deploy {
+	return deploystatus.Deploy{
+		App:        o.App.Name,
+		Team:       o.App.TeamOwner,
+		Repository: o.Repository,
+		Commit:     o.Commit,
+	}
+}
+
+// notifyDeployStatus reports the stage of the deploy in background, so slow
+// or unavailable providers don't hold the deploy.
+func notifyDeployStatus(d deploystatus.Deploy, stage deploystatus.Stage) {
+	deploystatus.NotifyAsync(d, stage)
+}
+
+// lastDeployOptions returns the options of the last successful deploy of the
+// app, used for reporting the status of the commit replaced by a rollback.
+func lastDeployOptions(appName string) (*DeployOptions, error) {
+	running := false
+	evts, err := event.List(&event.Filter{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: appName},
+		KindName: permission.PermAppDeploy.FullName(),
+		KindType: event.KindTypePermission,
+		Running:  &running,
+		Raw:      bson.M{"error": ""},
+		Limit:    1,
+	})
+	if err != nil || len(evts) == 0 {
+		return nil, err
+	}
+	var opts DeployOptions
+	err = evts[0].StartData(&opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.App == nil {
+		opts.App = &App{Name: appName}
+	}
+	return &opts, nil
+}
+
 func deployToProvisioner(opts *DeployOptions, evt *event.Event) (string, error) {
 	prov, err := opts.App.getProvisioner()
 	if err != nil {
@@ -257,7 +359,7 @@ func deployToProvisioner(opts *DeployOptions, evt *event.Event) (string, error)
 		if deployer, ok := prov.(provision.RollbackableDeployer); ok {
 			return deployer.Rollback(opts.App, opts.Image, evt)
 		}
-	case DeployImage:
+	case DeployImage, DeployPromote:
 		if deployer, ok := prov.(provision.ImageDeployer); ok {
 			return deployer.ImageDeploy(opts.App, opts.Image, evt)
 		}
@@ -278,7 +380,7 @@ func deployToProvisioner(opts *DeployOptions, evt *event.Event) (string, error)
 }
 
 func ValidateOrigin(origin string) bool {
-	originList := []string{"app-deploy", "git", "rollback", "drag-and-drop", "image", "rebuild"}
+	originList := []string{"app-deploy", "git", "rollback", "drag-and-drop", "image", "rebuild", "promote"}
 	for _, ol := range originList {
 		if ol == origin {
 			return true
@@ -287,6 +389,41 @@ func ValidateOrigin(origin string) bool {
 	return false
 }
 
+// ResolveSourceImage finds the image built for o.SourceApp that will be
+// deployed, unchanged, to o.App. If o.Image is empty the current image of the
+// source app is used, otherwise o.Image may be either a complete image name or
+// only its version (e.g. v3). The resolved image is stored in both o.Image and
+// o.SourceImage so the deploy record keeps track of its provenance.
+func (opts *DeployOptions) ResolveSourceImage() error {
+	if opts.SourceApp == opts.App.Name {
+		return errors.New("cannot promote an image to the same app")
+	}
+	source, err := GetByName(opts.SourceApp)
+	if err != nil {
+		return err
+	}
+	if opts.Image == "" {
+		opts.Image, err = image.AppCurrentImageName(source.Name)
+		if err != nil {
+			return errors.Wrapf(err, "unable to find current image for app %q", source.Name)
+		}
+		opts.SourceImage = opts.Image
+		return nil
+	}
+	validImages, err := findValidImages(*source)
+	if err != nil {
+		return err
+	}
+	for img := range validImages {
+		if img == opts.Image || strings.HasSuffix(img, ":"+opts.Image) {
+			opts.Image = img
+			opts.SourceImage = img
+			return nil
+		}
+	}
+	return errors.Errorf("invalid image for app %q: %q", source.Name, opts.Image)
+}
+
 func incrementDeploy(app *App) error {
 	conn, err := db.Conn()
 	if err != nil {
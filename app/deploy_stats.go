@@ -0,0 +1,97 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+var (
+	// DefaultDeployStatsWindows are the windows used by DeployStats when
+	// none is given: the last day, week and 30 days.
+	DefaultDeployStatsWindows = []time.Duration{24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour}
+
+	ErrInvalidDeployStatsWindow = errors.New("invalid deploy stats window: windows must be greater than zero")
+)
+
+// DeployStats summarizes the finished deploys of an app started within
+// Window, up to the moment the stats were computed. Rollbacks are counted as
+// deploys too.
+type DeployStats struct {
+	Window          time.Duration `json:"window"`
+	Deploys         int           `json:"deploys"`
+	Failures        int           `json:"failures"`
+	Rollbacks       int           `json:"rollbacks"`
+	DeploysPerDay   float64       `json:"deploysPerDay"`
+	FailureRate     float64       `json:"failureRate"`
+	AverageDuration time.Duration `json:"averageDuration"`
+}
+
+// DeployStats returns the deploy stats of the app for each window, in the
+// given order. Running deploys are ignored.
+func (app *App) DeployStats(windows []time.Duration) ([]DeployStats, error) {
+	if len(windows) == 0 {
+		windows = DefaultDeployStatsWindows
+	}
+	var longest time.Duration
+	for _, window := range windows {
+		if window <= 0 {
+			return nil, ErrInvalidDeployStatsWindow
+		}
+		if window > longest {
+			longest = window
+		}
+	}
+	now := time.Now().UTC()
+	running := false
+	evts, err := event.List(&event.Filter{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: app.Name},
+		KindType: event.KindTypePermission,
+		KindName: permission.PermAppDeploy.FullName(),
+		Since:    now.Add(-longest),
+		Running:  &running,
+		Limit:    -1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rollbacks := make([]bool, len(evts))
+	for i := range evts {
+		var opts DeployOptions
+		if evts[i].StartData(&opts) == nil {
+			rollbacks[i] = opts.Rollback || opts.Kind == DeployRollback
+		}
+	}
+	stats := make([]DeployStats, len(windows))
+	for i, window := range windows {
+		since := now.Add(-window)
+		s := DeployStats{Window: window}
+		var totalDuration time.Duration
+		for j := range evts {
+			if evts[j].StartTime.Before(since) {
+				continue
+			}
+			s.Deploys++
+			totalDuration += evts[j].EndTime.Sub(evts[j].StartTime)
+			if evts[j].Error != "" {
+				s.Failures++
+			}
+			if rollbacks[j] {
+				s.Rollbacks++
+			}
+		}
+		if s.Deploys > 0 {
+			s.FailureRate = float64(s.Failures) / float64(s.Deploys)
+			s.AverageDuration = totalDuration / time.Duration(s.Deploys)
+		}
+		s.DeploysPerDay = float64(s.Deploys) / (float64(window) / float64(24*time.Hour))
+		stats[i] = s
+	}
+	return stats, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDeployStats(c *check.C) {
+	a := App{Name: "g1", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	deploys := []struct {
+		app      string
+		age      time.Duration
+		rollback bool
+		err      error
+	}{
+		{app: "g1", age: 2 * time.Hour},
+		{app: "g1", age: 3 * 24 * time.Hour, err: errors.New("build failed")},
+		{app: "g1", age: 10 * 24 * time.Hour, rollback: true},
+		{app: "g1", age: 40 * 24 * time.Hour},
+		{app: "other", age: time.Hour},
+	}
+	for _, d := range deploys {
+		evt, err := event.New(&event.Opts{
+			Target:     event.Target{Type: event.TargetTypeApp, Value: d.app},
+			Kind:       permission.PermAppDeploy,
+			RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+			Allowed:    event.Allowed(permission.PermApp),
+			CustomData: DeployOptions{Rollback: d.rollback},
+		})
+		c.Assert(err, check.IsNil)
+		evt.StartTime = time.Now().UTC().Add(-d.age)
+		err = evt.Done(d.err)
+		c.Assert(err, check.IsNil)
+	}
+	_, err = event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: "g1"},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	stats, err := a.DeployStats(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats, check.HasLen, 3)
+	c.Assert(stats[0].Window, check.Equals, 24*time.Hour)
+	c.Assert(stats[0].Deploys, check.Equals, 1)
+	c.Assert(stats[0].Failures, check.Equals, 0)
+	c.Assert(stats[0].FailureRate, check.Equals, 0.0)
+	c.Assert(stats[0].DeploysPerDay, check.Equals, 1.0)
+	c.Assert(stats[1].Window, check.Equals, 7*24*time.Hour)
+	c.Assert(stats[1].Deploys, check.Equals, 2)
+	c.Assert(stats[1].Failures, check.Equals, 1)
+	c.Assert(stats[1].FailureRate, check.Equals, 0.5)
+	c.Assert(stats[1].Rollbacks, check.Equals, 0)
+	c.Assert(stats[2].Window, check.Equals, 30*24*time.Hour)
+	c.Assert(stats[2].Deploys, check.Equals, 3)
+	c.Assert(stats[2].Failures, check.Equals, 1)
+	c.Assert(stats[2].Rollbacks, check.Equals, 1)
+	c.Assert(stats[2].DeploysPerDay, check.Equals, 0.1)
+	c.Assert(stats[2].AverageDuration > stats[0].AverageDuration, check.Equals, true)
+	stats, err = a.DeployStats([]time.Duration{time.Hour})
+	c.Assert(err, check.IsNil)
+	c.Assert(stats, check.DeepEquals, []DeployStats{{Window: time.Hour}})
+}
+
+func (s *S) TestDeployStatsInvalidWindow(c *check.C) {
+	a := App{Name: "g1", TeamOwner: s.team.Name}
+	_, err := a.DeployStats([]time.Duration{time.Hour, 0})
+	c.Assert(err, check.Equals, ErrInvalidDeployStatsWindow)
+}
@@ -10,11 +10,15 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/url"
+	"sync"
 	"time"
 
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/deployfreeze"
+	"github.com/tsuru/tsuru/deploystatus"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
@@ -276,6 +280,81 @@ func (s *S) TestDeployApp(c *check.C) {
 	c.Assert(logs, check.Equals, "Image deploy called")
 }
 
+func (s *S) TestDeployAppFrozen(c *check.C) {
+	err := deployfreeze.Add(deployfreeze.Freeze{
+		Name:  "xmas",
+		Start: time.Now().Add(-time.Hour),
+		End:   time.Now().Add(time.Hour),
+	})
+	c.Assert(err, check.IsNil)
+	a := App{
+		Name:      "some-app",
+		Platform:  "django",
+		Teams:     []string{s.team.Name},
+		TeamOwner: s.team.Name,
+		Router:    "fake",
+	}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		OutputStream: &bytes.Buffer{},
+		Event:        evt,
+	})
+	c.Assert(err, check.FitsTypeOf, &deployfreeze.FrozenError{})
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage:v1",
+		Rollback:     true,
+		OutputStream: &bytes.Buffer{},
+		Event:        evt,
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestDeployAppCanceled(c *check.C) {
+	a := App{
+		Name:      "some-app",
+		Platform:  "django",
+		Teams:     []string{s.team.Name},
+		TeamOwner: s.team.Name,
+		Router:    "fake",
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	evt, err := event.New(&event.Opts{
+		Target:        event.Target{Type: "app", Value: a.Name},
+		Kind:          permission.PermAppDeploy,
+		RawOwner:      event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:       event.Allowed(permission.PermApp),
+		AllowedCancel: event.Allowed(permission.PermApp),
+		Cancelable:    true,
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.TryCancel("wrong commit", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		OutputStream: writer,
+		Event:        evt,
+	})
+	c.Assert(err, check.Equals, event.ErrCanceled)
+	c.Assert(writer.String(), check.Matches, `(?s).* ---> Canceled by admin@example.com at checkpoint "build": wrong commit\n`)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Deploys, check.Equals, uint(0))
+}
+
 func (s *S) TestDeployAppWithUpdatePlatform(c *check.C) {
 	a := App{
 		Name:           "some-app",
@@ -752,6 +831,60 @@ func (s *S) TestRollbackWithWrongVersionImage(c *check.C) {
 	c.Assert(imgID, check.Equals, "")
 }
 
+func (s *S) TestPromoteFromSourceApp(c *check.C) {
+	source := App{Name: "staging", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&source, s.user)
+	c.Assert(err, check.IsNil)
+	a := App{Name: "prod", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName("staging", "registry.somewhere/tsuru/app-staging:v1")
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName("staging", "registry.somewhere/tsuru/app-staging:v2")
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	imgID, err := Deploy(DeployOptions{
+		App:          &a,
+		OutputStream: writer,
+		SourceApp:    "staging",
+		Event:        evt,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, "Image deploy called")
+	c.Assert(imgID, check.Equals, "registry.somewhere/tsuru/app-staging:v2")
+}
+
+func (s *S) TestResolveSourceImage(c *check.C) {
+	source := App{Name: "staging", Platform: "zend", TeamOwner: s.team.Name}
+	err := CreateApp(&source, s.user)
+	c.Assert(err, check.IsNil)
+	a := App{Name: "prod", Platform: "zend", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName("staging", "registry.somewhere/tsuru/app-staging:v1")
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName("staging", "registry.somewhere/tsuru/app-staging:v2")
+	c.Assert(err, check.IsNil)
+	opts := DeployOptions{App: &a, SourceApp: "staging", Image: "v1"}
+	err = opts.ResolveSourceImage()
+	c.Assert(err, check.IsNil)
+	c.Assert(opts.Image, check.Equals, "registry.somewhere/tsuru/app-staging:v1")
+	c.Assert(opts.SourceImage, check.Equals, "registry.somewhere/tsuru/app-staging:v1")
+	opts = DeployOptions{App: &a, SourceApp: "staging", Image: "v9"}
+	err = opts.ResolveSourceImage()
+	c.Assert(err, check.ErrorMatches, `invalid image for app "staging": "v9"`)
+	opts = DeployOptions{App: &a, SourceApp: "prod"}
+	err = opts.ResolveSourceImage()
+	c.Assert(err, check.ErrorMatches, "cannot promote an image to the same app")
+}
+
 func (s *S) TestDeployKind(c *check.C) {
 	var tests = []struct {
 		input    DeployOptions
@@ -765,6 +898,10 @@ func (s *S) TestDeployKind(c *check.C) {
 			DeployOptions{Image: "quay.io/tsuru/python"},
 			DeployImage,
 		},
+		{
+			DeployOptions{SourceApp: "staging", Image: "v2"},
+			DeployPromote,
+		},
 		{
 			DeployOptions{File: ioutil.NopCloser(bytes.NewBuffer(nil))},
 			DeployUpload,
@@ -869,3 +1006,102 @@ func (s *S) TestRebuild(c *check.C) {
 	c.Assert(writer.String(), check.Equals, "Rebuild deploy called")
 	c.Assert(imgID, check.Equals, "app-image")
 }
+
+type fakeStatusProvider struct {
+	mu       sync.Mutex
+	statuses []deploystatus.Status
+}
+
+func (p *fakeStatusProvider) SetStatus(cfg *deploystatus.Config, status deploystatus.Status) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statuses = append(p.statuses, status)
+	return nil
+}
+
+// waitStatuses waits for n statuses to be sent in background, returning and
+// clearing them.
+func (p *fakeStatusProvider) waitStatuses(c *check.C, n int) []deploystatus.Status {
+	timeout := time.After(5 * time.Second)
+	for {
+		p.mu.Lock()
+		if len(p.statuses) >= n {
+			statuses := p.statuses
+			p.statuses = nil
+			p.mu.Unlock()
+			return statuses
+		}
+		p.mu.Unlock()
+		select {
+		case <-timeout:
+			c.Fatalf("timeout waiting for %d deploy statuses", n)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (s *S) TestDeployAppNotifiesDeployStatus(c *check.C) {
+	config.Set("deploy-status:token-key", "my-key")
+	defer config.Unset("deploy-status")
+	provider := &fakeStatusProvider{}
+	deploystatus.Register("fake", provider)
+	err := deploystatus.SetTeamConfig(s.team.Name, deploystatus.Config{Provider: "fake", Token: "abc"})
+	c.Assert(err, check.IsNil)
+	a := App{
+		Name:      "some-app",
+		Platform:  "django",
+		Teams:     []string{s.team.Name},
+		TeamOwner: s.team.Name,
+		Router:    "fake",
+	}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	opts := DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		Commit:       "1ee1f1084927b3a5db59c9033bc5c4abefb7b93c",
+		Repository:   "tsuru/some-app",
+		OutputStream: &bytes.Buffer{},
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: "app", Value: a.Name},
+		Kind:       permission.PermAppDeploy,
+		RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		CustomData: opts,
+		Allowed:    event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	opts.Event = evt
+	_, err = Deploy(opts)
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	var stages []deploystatus.Stage
+	for _, st := range provider.waitStatuses(c, 3) {
+		c.Assert(st.Repository, check.Equals, "tsuru/some-app")
+		c.Assert(st.Commit, check.Equals, opts.Commit)
+		stages = append(stages, st.Stage)
+	}
+	c.Assert(stages, check.DeepEquals, []deploystatus.Stage{
+		deploystatus.StageQueued, deploystatus.StageBuilding, deploystatus.StageRolledOut,
+	})
+	evt, err = event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "registry.somewhere/tsuru/app-some-app:v1",
+		Rollback:     true,
+		OutputStream: &bytes.Buffer{},
+		Event:        evt,
+	})
+	c.Assert(err, check.IsNil)
+	statuses := provider.waitStatuses(c, 1)
+	c.Assert(statuses, check.HasLen, 1)
+	c.Assert(statuses[0].Stage, check.Equals, deploystatus.StageRolledBack)
+	c.Assert(statuses[0].Commit, check.Equals, opts.Commit)
+}
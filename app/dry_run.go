@@ -0,0 +1,102 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/auth"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
+)
+
+// DryRunResult describes what an operation would do. It's only returned when
+// every validation of the operation passes.
+type DryRunResult struct {
+	App         string `json:"app"`
+	Pool        string `json:"pool"`
+	Plan        string `json:"plan"`
+	Router      string `json:"router"`
+	Provisioner string `json:"provisioner"`
+	// Placement holds the nodes where the units would be started, empty
+	// when the provisioner doesn't support planning units.
+	Placement []provision.UnitPlacement `json:"placement"`
+}
+
+// CreateAppDryRun runs the validations of CreateApp, including quota and
+// name availability, and plans where the first unit of the app would be
+// started, without creating anything.
+func CreateAppDryRun(app *App, user *auth.User) (*DryRunResult, error) {
+	err := app.prepareCreation(user)
+	if err != nil {
+		return nil, err
+	}
+	_, err = GetByName(app.Name)
+	if err == nil {
+		return nil, &AppCreationError{app: app.Name, Err: ErrAppAlreadyExists}
+	}
+	if err != ErrAppNotFound {
+		return nil, err
+	}
+	err = auth.CheckAppQuota(user)
+	if err == nil {
+		err = auth.CheckOrganizationAppQuota(app.TeamOwner)
+	}
+	if err != nil {
+		return nil, &AppCreationError{app: app.Name, Err: err}
+	}
+	return app.dryRunResult(1, "")
+}
+
+// AddUnitsDryRun runs the validations of AddUnits and plans where the new
+// units would be started, without adding them.
+func (app *App) AddUnitsDryRun(n uint, process string) (*DryRunResult, error) {
+	if app.Daemon {
+		return nil, ErrDaemonUnits
+	}
+	if n == 0 {
+		return nil, errors.New("Cannot add zero units.")
+	}
+	if app.Plan.HardwareProfile != "" {
+		pool, err := provision.GetPoolByName(app.Pool)
+		if err != nil {
+			return nil, err
+		}
+		err = app.validateHardwareProfile(pool)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := checkUnitsToAdd(app, int(n))
+	if err != nil {
+		return nil, err
+	}
+	_, err = checkAppLimit(app.Name, int(n))
+	if err != nil {
+		return nil, err
+	}
+	return app.dryRunResult(n, process)
+}
+
+func (app *App) dryRunResult(n uint, process string) (*DryRunResult, error) {
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	result := DryRunResult{
+		App:         app.Name,
+		Pool:        app.Pool,
+		Plan:        app.Plan.Name,
+		Router:      app.Router,
+		Provisioner: prov.GetName(),
+		Placement:   []provision.UnitPlacement{},
+	}
+	if planner, ok := prov.(provision.UnitPlannerProvisioner); ok {
+		result.Placement, err = planner.PlanUnits(app, n, process)
+		if err != nil {
+			return nil, &tsuruErrors.ValidationError{Message: err.Error()}
+		}
+	}
+	return &result, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestCreateAppDryRun(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{Address: "http://n1:2375", Metadata: map[string]string{"pool": s.Pool}})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "america", Platform: "python", TeamOwner: s.team.Name}
+	result, err := CreateAppDryRun(&a, s.user)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, &DryRunResult{
+		App:         "america",
+		Pool:        s.Pool,
+		Plan:        s.defaultPlan.Name,
+		Router:      "fake",
+		Provisioner: "fake",
+		Placement:   []provision.UnitPlacement{{Node: "http://n1:2375"}},
+	})
+	_, err = GetByName(a.Name)
+	c.Assert(err, check.Equals, ErrAppNotFound)
+	c.Assert(s.provisioner.Provisioned(&a), check.Equals, false)
+	user, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(user.Quota.InUse, check.Equals, 0)
+}
+
+func (s *S) TestCreateAppDryRunAlreadyExists(c *check.C) {
+	a := App{Name: "america", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	other := App{Name: "america", Platform: "python", TeamOwner: s.team.Name}
+	_, err = CreateAppDryRun(&other, s.user)
+	c.Assert(err, check.FitsTypeOf, &AppCreationError{})
+	c.Assert(err.(*AppCreationError).Err, check.Equals, ErrAppAlreadyExists)
+}
+
+func (s *S) TestCreateAppDryRunUserQuotaExceeded(c *check.C) {
+	s.conn.Users().Update(
+		bson.M{"email": s.user.Email},
+		bson.M{"$set": bson.M{"quota.limit": 0}},
+	)
+	a := App{Name: "america", Platform: "python", TeamOwner: s.team.Name}
+	_, err := CreateAppDryRun(&a, s.user)
+	c.Assert(err, check.FitsTypeOf, &AppCreationError{})
+	_, ok := err.(*AppCreationError).Err.(*quota.QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+}
+
+func (s *S) TestCreateAppDryRunNoNodes(c *check.C) {
+	a := App{Name: "america", Platform: "python", TeamOwner: s.team.Name}
+	_, err := CreateAppDryRun(&a, s.user)
+	c.Assert(err, check.ErrorMatches, `no nodes found in pool "pool1"`)
+}
+
+func (s *S) TestAddUnitsDryRun(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{Address: "http://n1:2375", Metadata: map[string]string{"pool": s.Pool}})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{Address: "http://n2:2375", Metadata: map[string]string{"pool": s.Pool}})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "warpaint", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	result, err := a.AddUnitsDryRun(3, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Placement, check.DeepEquals, []provision.UnitPlacement{
+		{Process: "web", Node: "http://n1:2375"},
+		{Process: "web", Node: "http://n2:2375"},
+		{Process: "web", Node: "http://n1:2375"},
+	})
+	c.Assert(s.provisioner.GetUnits(&a), check.HasLen, 0)
+}
+
+func (s *S) TestAddUnitsDryRunQuotaExceeded(c *check.C) {
+	a := App{Name: "warpaint", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = ChangeQuota(&a, 2)
+	c.Assert(err, check.IsNil)
+	_, err = a.AddUnitsDryRun(3, "web")
+	c.Assert(err, check.DeepEquals, &quota.QuotaExceededError{Available: 2, Requested: 3})
+}
+
+func (s *S) TestAddUnitsDryRunPoolQuotaExceeded(c *check.C) {
+	a := App{Name: "warpaint", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolQuota(s.Pool, provision.PoolQuota{MaxUnits: 2})
+	c.Assert(err, check.IsNil)
+	_, err = a.AddUnitsDryRun(3, "web")
+	c.Assert(err, check.FitsTypeOf, &provision.PoolQuotaExceededError{})
+}
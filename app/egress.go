@@ -0,0 +1,98 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/egress"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrEgressRuleNotFound = errors.New("egress rule not found")
+
+// AddEgressRules allows the units of the app to connect to the hosts in the
+// given rules. Rules already declared by the app are ignored. Rules are
+// rejected with egress.ErrNotEnforced when no egress backend is configured,
+// as they wouldn't block anything.
+func (app *App) AddEgressRules(rules ...egress.Rule) error {
+	if !egress.Enforced() {
+		return egress.ErrNotEnforced
+	}
+	newRules := append([]egress.Rule{}, app.Egress...)
+	for _, r := range rules {
+		err := r.Normalize()
+		if err != nil {
+			return err
+		}
+		if indexOfEgressRule(newRules, r) == -1 {
+			newRules = append(newRules, r)
+		}
+	}
+	egress.SortRules(newRules)
+	return app.saveEgressRules(newRules)
+}
+
+// RemoveEgressRules blocks the outbound connections to the hosts in the
+// given rules, which must be declared by the app.
+func (app *App) RemoveEgressRules(rules ...egress.Rule) error {
+	newRules := append([]egress.Rule{}, app.Egress...)
+	for _, r := range rules {
+		err := r.Normalize()
+		if err != nil {
+			return err
+		}
+		idx := indexOfEgressRule(newRules, r)
+		if idx == -1 {
+			return ErrEgressRuleNotFound
+		}
+		newRules = append(newRules[:idx], newRules[idx+1:]...)
+	}
+	return app.saveEgressRules(newRules)
+}
+
+// BlockedEgressAttempts returns the outbound connections of the app blocked
+// since the given time.
+func (app *App) BlockedEgressAttempts(since time.Time) ([]egress.BlockedAttempt, error) {
+	return egress.BlockedAttempts(app.Name, since)
+}
+
+func indexOfEgressRule(rules []egress.Rule, r egress.Rule) int {
+	for i := range rules {
+		if rules[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func (app *App) saveEgressRules(rules []egress.Rule) error {
+	err := egress.SyncApp(app.Name, rules)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var update bson.M
+	if len(rules) == 0 {
+		update = bson.M{"$unset": bson.M{"egress": ""}}
+	} else {
+		update = bson.M{"$set": bson.M{"egress": rules}}
+	}
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		rules = nil
+	}
+	app.Egress = rules
+	return nil
+}
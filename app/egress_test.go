@@ -0,0 +1,92 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/egress"
+	"github.com/tsuru/tsuru/egress/egresstest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAddEgressRules(c *check.C) {
+	config.Set("egress:backend", "fake")
+	defer config.Unset("egress:backend")
+	defer egresstest.Reset()
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddEgressRules(egress.Rule{Host: "Payments.example.com", Port: 443}, egress.Rule{Host: "10.0.0.0/8"})
+	c.Assert(err, check.IsNil)
+	err = a.AddEgressRules(egress.Rule{Host: "payments.example.com", Port: 443})
+	c.Assert(err, check.IsNil)
+	expected := []egress.Rule{{Host: "10.0.0.0/8"}, {Host: "payments.example.com", Port: 443}}
+	c.Assert(egresstest.Rules(a.Name), check.DeepEquals, expected)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Egress, check.DeepEquals, expected)
+	err = dbApp.RemoveEgressRules(egress.Rule{Host: "10.0.0.0/8"}, egress.Rule{Host: "payments.example.com", Port: 443})
+	c.Assert(err, check.IsNil)
+	c.Assert(egresstest.Rules(a.Name), check.HasLen, 0)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Egress, check.IsNil)
+}
+
+func (s *S) TestAddEgressRulesInvalid(c *check.C) {
+	config.Set("egress:backend", "fake")
+	defer config.Unset("egress:backend")
+	defer egresstest.Reset()
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddEgressRules(egress.Rule{Host: "payments.example.com"}, egress.Rule{Host: "*"})
+	c.Assert(err, check.Equals, egress.ErrInvalidRule)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Egress, check.IsNil)
+}
+
+func (s *S) TestAddEgressRulesNotEnforced(c *check.C) {
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddEgressRules(egress.Rule{Host: "payments.example.com"})
+	c.Assert(err, check.Equals, egress.ErrNotEnforced)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Egress, check.IsNil)
+}
+
+func (s *S) TestRemoveEgressRulesNotFound(c *check.C) {
+	config.Set("egress:backend", "fake")
+	defer config.Unset("egress:backend")
+	defer egresstest.Reset()
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddEgressRules(egress.Rule{Host: "payments.example.com"})
+	c.Assert(err, check.IsNil)
+	err = a.RemoveEgressRules(egress.Rule{Host: "payments.example.com", Port: 443})
+	c.Assert(err, check.Equals, ErrEgressRuleNotFound)
+	c.Assert(a.Egress, check.DeepEquals, []egress.Rule{{Host: "payments.example.com"}})
+}
+
+func (s *S) TestBlockedEgressAttempts(c *check.C) {
+	config.Set("egress:backend", "fake")
+	defer config.Unset("egress:backend")
+	defer egresstest.Reset()
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	now := time.Now().UTC()
+	egresstest.AddBlockedAttempt(a.Name, egress.BlockedAttempt{Host: "old.example.com", Port: 80, Count: 1, LastSeen: now.Add(-2 * time.Hour)})
+	egresstest.AddBlockedAttempt(a.Name, egress.BlockedAttempt{Host: "evil.example.com", Port: 443, Count: 7, LastSeen: now})
+	attempts, err := a.BlockedEgressAttempts(now.Add(-time.Hour))
+	c.Assert(err, check.IsNil)
+	c.Assert(attempts, check.DeepEquals, []egress.BlockedAttempt{
+		{Host: "evil.example.com", Port: 443, Count: 7, LastSeen: now},
+	})
+}
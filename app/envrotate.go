@@ -0,0 +1,61 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrEnvNotFound    = errors.New("environment variable not found")
+	ErrInvalidEnvName = errors.New("invalid environment variable name, names must not be empty, contain dots or start with $")
+)
+
+// ListAppsWithEnv returns the apps that have the environment variable name
+// set to value. Variables managed by service instances are ignored.
+func ListAppsWithEnv(name, value string) ([]App, error) {
+	if !provision.ValidEnvName(name) {
+		return nil, ErrInvalidEnvName
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(bson.M{"env." + name + ".value": value}).Sort("name").All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]App, 0, len(apps))
+	for _, a := range apps {
+		if env, ok := a.Env[name]; ok && env.InstanceName == "" {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
+// RotateEnv replaces the value of an existing environment variable of the
+// app, keeping its visibility. The app is restarted when restart is true and
+// it has units.
+func (app *App) RotateEnv(name, value string, restart bool, w io.Writer) error {
+	env, ok := app.Env[name]
+	if !ok || env.InstanceName != "" {
+		return ErrEnvNotFound
+	}
+	env.Value = value
+	return app.SetEnvs(bind.SetEnvApp{
+		Envs:          []bind.EnvVar{env},
+		PublicOnly:    true,
+		ShouldRestart: restart,
+	}, w)
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/app/bind"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestListAppsWithEnv(c *check.C) {
+	apps := []App{
+		{Name: "app2", Env: map[string]bind.EnvVar{"KEY": {Name: "KEY", Value: "leaked"}}},
+		{Name: "app1", Env: map[string]bind.EnvVar{"KEY": {Name: "KEY", Value: "leaked", Public: true}}},
+		{Name: "app3", Env: map[string]bind.EnvVar{"KEY": {Name: "KEY", Value: "other"}}},
+		{Name: "app4", Env: map[string]bind.EnvVar{"KEY": {Name: "KEY", Value: "leaked", InstanceName: "mysql"}}},
+		{Name: "app5"},
+	}
+	for i := range apps {
+		apps[i].TeamOwner = s.team.Name
+		err := CreateApp(&apps[i], s.user)
+		c.Assert(err, check.IsNil)
+	}
+	result, err := ListAppsWithEnv("KEY", "leaked")
+	c.Assert(err, check.IsNil)
+	var names []string
+	for _, a := range result {
+		names = append(names, a.Name)
+	}
+	c.Assert(names, check.DeepEquals, []string{"app1", "app2"})
+}
+
+func (s *S) TestListAppsWithEnvInvalidName(c *check.C) {
+	for _, name := range []string{"", "KEY.value", "$where"} {
+		_, err := ListAppsWithEnv(name, "leaked")
+		c.Check(err, check.Equals, ErrInvalidEnvName, check.Commentf(name))
+	}
+}
+
+func (s *S) TestRotateEnv(c *check.C) {
+	a := App{
+		Name: "myapp",
+		Env: map[string]bind.EnvVar{
+			"KEY":          {Name: "KEY", Value: "leaked", Public: true},
+			"DATABASE_URL": {Name: "DATABASE_URL", Value: "mysql://", InstanceName: "mysql"},
+		},
+		TeamOwner: s.team.Name,
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.RotateEnv("KEY", "new", true, nil)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["KEY"], check.DeepEquals, bind.EnvVar{Name: "KEY", Value: "new", Public: true})
+	err = a.RotateEnv("DATABASE_URL", "other", true, nil)
+	c.Assert(err, check.Equals, ErrEnvNotFound)
+	err = a.RotateEnv("MISSING", "other", true, nil)
+	c.Assert(err, check.Equals, ErrEnvNotFound)
+}
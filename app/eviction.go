@@ -0,0 +1,109 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	tsuruNet "github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2"
+)
+
+const evictionEventKind = "unit-eviction"
+
+type evictionNotification struct {
+	App      string                 `json:"app"`
+	Pool     string                 `json:"pool"`
+	Eviction provision.UnitEviction `json:"eviction"`
+}
+
+// NotifyUnitEviction reports a unit killed or evicted by the cluster to the
+// app owners: it adds an event to the app, writes the reason to the app log
+// and posts the eviction to the webhook set in eviction:webhook, if any.
+// Provisioners may report the same eviction more than once, only the first
+// report of each eviction is notified.
+func NotifyUnitEviction(appName string, eviction provision.UnitEviction) error {
+	a, err := GetByName(appName)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	id := fmt.Sprintf("%s/%s/%d", eviction.Unit, eviction.Reason, eviction.Time.Unix())
+	err = conn.Collection("unit_evictions").Insert(map[string]interface{}{
+		"_id":  id,
+		"app":  a.Name,
+		"time": time.Now().UTC(),
+	})
+	if mgo.IsDup(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	notification := evictionNotification{App: a.Name, Pool: a.Pool, Eviction: eviction}
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		InternalKind: evictionEventKind,
+		CustomData:   notification,
+		Allowed: event.Allowed(permission.PermAppReadEvents, append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...),
+	})
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("unit %s was killed by the cluster (%s)", eviction.Unit, eviction.Reason)
+	if eviction.Node != "" {
+		msg += " on node " + eviction.Node
+	}
+	if eviction.Message != "" {
+		msg += ": " + eviction.Message
+	}
+	err = a.Log(msg, "tsuru", "api")
+	if err != nil {
+		log.Errorf("[eviction] unable to log eviction of unit %q: %s", eviction.Unit, err)
+	}
+	err = postEvictionWebhook(notification)
+	if err != nil {
+		log.Errorf("[eviction] unable to notify eviction of unit %q: %s", eviction.Unit, err)
+	}
+	return evt.Done(err)
+}
+
+func postEvictionWebhook(notification evictionNotification) error {
+	url, _ := config.GetString("eviction:webhook")
+	if url == "" {
+		return nil
+	}
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	rsp, err := tsuruNet.Dial5Full60ClientNoKeepAlive.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("eviction webhook %s returned status %d", url, rsp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestNotifyUnitEviction(c *check.C) {
+	var notifications []evictionNotification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n evictionNotification
+		err := json.NewDecoder(r.Body).Decode(&n)
+		c.Check(err, check.IsNil)
+		notifications = append(notifications, n)
+	}))
+	defer server.Close()
+	config.Set("eviction:webhook", server.URL)
+	defer config.Unset("eviction:webhook")
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	eviction := provision.UnitEviction{
+		Unit:    "myapp-web-1",
+		Node:    "node1",
+		Reason:  provision.EvictionReasonOOMKilled,
+		Message: "memory limit exceeded",
+		Time:    time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	err = NotifyUnitEviction(a.Name, eviction)
+	c.Assert(err, check.IsNil)
+	err = NotifyUnitEviction(a.Name, eviction)
+	c.Assert(err, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:   evictionEventKind,
+		StartCustomData: map[string]interface{}{
+			"app":             "myapp",
+			"eviction.unit":   "myapp-web-1",
+			"eviction.reason": "OOMKilled",
+		},
+	}, eventtest.HasEvent)
+	logs, err := a.LastLogs(10, Applog{Source: "tsuru"})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 1)
+	c.Assert(logs[0].Message, check.Equals, "unit myapp-web-1 was killed by the cluster (OOMKilled) on node node1: memory limit exceeded")
+	c.Assert(notifications, check.HasLen, 1)
+	c.Assert(notifications[0].App, check.Equals, "myapp")
+	c.Assert(notifications[0].Eviction.Reason, check.Equals, provision.EvictionReasonOOMKilled)
+}
+
+func (s *S) TestNotifyUnitEvictionAppNotFound(c *check.C) {
+	err := NotifyUnitEviction("unknown", provision.UnitEviction{Unit: "u1", Reason: provision.EvictionReasonEvicted})
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrMaintenanceNotSupported = errors.New("router does not support maintenance pages")
+	ErrInvalidMaintenance      = errors.New("invalid maintenance page: either html or an absolute http(s) redirect url must be set, but not both")
+)
+
+// Maintenance is a page served by the router of the app in place of its
+// units, either custom HTML or a redirect. The page is served while Enabled
+// is set, and, when OnSwap is set, it's also served while the app is being
+// swapped with another app.
+type Maintenance struct {
+	HTML        string `json:"html,omitempty"`
+	RedirectURL string `json:"redirectURL,omitempty"`
+	Enabled     bool   `json:"enabled"`
+	OnSwap      bool   `json:"onSwap"`
+}
+
+func (m Maintenance) validate() error {
+	if (m.HTML == "") == (m.RedirectURL == "") {
+		return ErrInvalidMaintenance
+	}
+	if m.RedirectURL != "" {
+		u, err := url.Parse(m.RedirectURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidMaintenance
+		}
+	}
+	return nil
+}
+
+func (m Maintenance) configured() bool {
+	return m.HTML != "" || m.RedirectURL != ""
+}
+
+func (m Maintenance) page() router.MaintenancePage {
+	return router.MaintenancePage{HTML: m.HTML, RedirectURL: m.RedirectURL}
+}
+
+func (app *App) maintenanceRouter() (router.MaintenanceRouter, error) {
+	r, err := app.GetRouter()
+	if err != nil {
+		return nil, err
+	}
+	mRouter, ok := r.(router.MaintenanceRouter)
+	if !ok {
+		return nil, ErrMaintenanceNotSupported
+	}
+	return mRouter, nil
+}
+
+// SetMaintenance stores the maintenance page of the app, enabling or
+// disabling it in the router according to m.Enabled.
+func (app *App) SetMaintenance(m Maintenance) error {
+	err := m.validate()
+	if err != nil {
+		return err
+	}
+	mRouter, err := app.maintenanceRouter()
+	if err != nil {
+		return err
+	}
+	if m.Enabled {
+		err = mRouter.SetMaintenance(app.Name, m.page())
+	} else {
+		err = mRouter.UnsetMaintenance(app.Name)
+	}
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"maintenance": m}})
+	if err != nil {
+		return err
+	}
+	app.Maintenance = m
+	return nil
+}
+
+// RemoveMaintenance disables the maintenance page of the app in the router
+// and discards it.
+func (app *App) RemoveMaintenance() error {
+	mRouter, err := app.maintenanceRouter()
+	if err != nil {
+		return err
+	}
+	err = mRouter.UnsetMaintenance(app.Name)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$unset": bson.M{"maintenance": ""}})
+	if err != nil {
+		return err
+	}
+	app.Maintenance = Maintenance{}
+	return nil
+}
+
+// swapMaintenance enables the maintenance page of the apps with OnSwap set
+// and not already in maintenance, returning a function that disables them
+// once the swap finishes. Routers resolve app names to backends, so after
+// swapping backends the page of each app is reached through the name of the
+// other app. Failures are only logged, as they must not prevent the swap.
+func swapMaintenance(app1, app2 *App) func(backendsSwapped bool) {
+	var enabled []*App
+	for _, a := range []*App{app1, app2} {
+		m := a.Maintenance
+		if !m.OnSwap || m.Enabled || !m.configured() {
+			continue
+		}
+		mRouter, err := a.maintenanceRouter()
+		if err == nil {
+			err = mRouter.SetMaintenance(a.Name, m.page())
+		}
+		if err != nil {
+			log.Errorf("[swap] unable to enable maintenance page for app %q: %s", a.Name, err)
+			continue
+		}
+		enabled = append(enabled, a)
+	}
+	return func(backendsSwapped bool) {
+		for _, a := range enabled {
+			name := a.Name
+			if backendsSwapped {
+				name = app1.Name
+				if a == app1 {
+					name = app2.Name
+				}
+			}
+			mRouter, err := a.maintenanceRouter()
+			if err == nil {
+				err = mRouter.UnsetMaintenance(name)
+			}
+			if err != nil {
+				log.Errorf("[swap] unable to disable maintenance page for app %q: %s", a.Name, err)
+			}
+		}
+	}
+}
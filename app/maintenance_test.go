@@ -0,0 +1,107 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetMaintenance(c *check.C) {
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	m := Maintenance{HTML: "<h1>back soon</h1>", Enabled: true}
+	err = a.SetMaintenance(m)
+	c.Assert(err, check.IsNil)
+	page, ok := routertest.FakeRouter.MaintenancePage(a.Name)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(page, check.DeepEquals, router.MaintenancePage{HTML: "<h1>back soon</h1>"})
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.DeepEquals, m)
+	m = Maintenance{RedirectURL: "https://status.example.com", OnSwap: true}
+	err = a.SetMaintenance(m)
+	c.Assert(err, check.IsNil)
+	_, ok = routertest.FakeRouter.MaintenancePage(a.Name)
+	c.Assert(ok, check.Equals, false)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.DeepEquals, m)
+}
+
+func (s *S) TestSetMaintenanceInvalid(c *check.C) {
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	tests := []Maintenance{
+		{},
+		{HTML: "<h1>down</h1>", RedirectURL: "https://status.example.com"},
+		{RedirectURL: "status.example.com"},
+		{RedirectURL: "ftp://status.example.com"},
+	}
+	for _, m := range tests {
+		err = a.SetMaintenance(m)
+		c.Check(err, check.Equals, ErrInvalidMaintenance, check.Commentf("%#v", m))
+	}
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.DeepEquals, Maintenance{})
+}
+
+func (s *S) TestRemoveMaintenance(c *check.C) {
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetMaintenance(Maintenance{HTML: "<h1>down</h1>", Enabled: true})
+	c.Assert(err, check.IsNil)
+	err = a.RemoveMaintenance()
+	c.Assert(err, check.IsNil)
+	_, ok := routertest.FakeRouter.MaintenancePage(a.Name)
+	c.Assert(ok, check.Equals, false)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Maintenance, check.DeepEquals, Maintenance{})
+}
+
+func (s *S) TestSwapMaintenance(c *check.C) {
+	app1 := &App{Name: "app1", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(app1, s.user)
+	c.Assert(err, check.IsNil)
+	app2 := &App{Name: "app2", TeamOwner: s.team.Name, Router: "fake"}
+	err = CreateApp(app2, s.user)
+	c.Assert(err, check.IsNil)
+	err = app1.SetMaintenance(Maintenance{HTML: "<h1>swapping</h1>", OnSwap: true})
+	c.Assert(err, check.IsNil)
+	restore := swapMaintenance(app1, app2)
+	page, ok := routertest.FakeRouter.MaintenancePage(app1.Name)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(page, check.DeepEquals, router.MaintenancePage{HTML: "<h1>swapping</h1>"})
+	_, ok = routertest.FakeRouter.MaintenancePage(app2.Name)
+	c.Assert(ok, check.Equals, false)
+	err = routertest.FakeRouter.Swap(app1.Name, app2.Name, false)
+	c.Assert(err, check.IsNil)
+	restore(true)
+	_, ok = routertest.FakeRouter.MaintenancePage(app1.Name)
+	c.Assert(ok, check.Equals, false)
+	_, ok = routertest.FakeRouter.MaintenancePage(app2.Name)
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *S) TestSwapMaintenanceAlreadyEnabled(c *check.C) {
+	app1 := &App{Name: "app1", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(app1, s.user)
+	c.Assert(err, check.IsNil)
+	app2 := &App{Name: "app2", TeamOwner: s.team.Name, Router: "fake"}
+	err = CreateApp(app2, s.user)
+	c.Assert(err, check.IsNil)
+	err = app1.SetMaintenance(Maintenance{HTML: "<h1>down</h1>", Enabled: true, OnSwap: true})
+	c.Assert(err, check.IsNil)
+	err = Swap(app1, app2, true)
+	c.Assert(err, check.IsNil)
+	_, ok := routertest.FakeRouter.MaintenancePage(app1.Name)
+	c.Assert(ok, check.Equals, true)
+}
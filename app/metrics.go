@@ -0,0 +1,88 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MetricsTarget is a group of scrape targets, in the format used by the
+// Prometheus HTTP service discovery.
+type MetricsTarget struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// SetMetricsConfig replaces the endpoint where the units of the app expose
+// metrics. Provisioners use the new config in the next deploy or restart of
+// the app.
+func (app *App) SetMetricsConfig(cfg provision.MetricsConfig) error {
+	err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var update bson.M
+	if cfg.IsEmpty() {
+		update = bson.M{"$unset": bson.M{"metrics": ""}}
+	} else {
+		update = bson.M{"$set": bson.M{"metrics": cfg}}
+	}
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	app.Metrics = cfg
+	return nil
+}
+
+// MetricsTargets returns the addresses of the units of the app serving
+// metrics, grouped by process. Units without an IP are ignored.
+func (app *App) MetricsTargets() ([]MetricsTarget, error) {
+	if app.Metrics.Port == 0 {
+		return nil, nil
+	}
+	units, err := app.Units()
+	if err != nil {
+		return nil, err
+	}
+	port := strconv.Itoa(app.Metrics.Port)
+	byProcess := map[string][]string{}
+	for _, u := range units {
+		if u.Ip == "" || !app.Metrics.Scrapes(u.ProcessName) {
+			continue
+		}
+		byProcess[u.ProcessName] = append(byProcess[u.ProcessName], net.JoinHostPort(u.Ip, port))
+	}
+	processes := make([]string, 0, len(byProcess))
+	for p := range byProcess {
+		processes = append(processes, p)
+	}
+	sort.Strings(processes)
+	targets := make([]MetricsTarget, len(processes))
+	for i, p := range processes {
+		sort.Strings(byProcess[p])
+		targets[i] = MetricsTarget{
+			Targets: byProcess[p],
+			Labels: map[string]string{
+				"__metrics_path__": app.Metrics.MetricsPath(),
+				"app":              app.Name,
+				"process":          p,
+				"pool":             app.Pool,
+			},
+		}
+	}
+	return targets, nil
+}
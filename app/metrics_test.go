@@ -0,0 +1,77 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"sort"
+
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetMetricsConfig(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	cfg := provision.MetricsConfig{Port: 9100, Path: "/stats"}
+	err = a.SetMetricsConfig(cfg)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Metrics, check.DeepEquals, cfg)
+	c.Assert(dbApp.GetMetricsConfig(), check.DeepEquals, cfg)
+	err = a.SetMetricsConfig(provision.MetricsConfig{})
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Metrics.IsEmpty(), check.Equals, true)
+}
+
+func (s *S) TestSetMetricsConfigInvalid(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetMetricsConfig(provision.MetricsConfig{Port: 9100, Path: "stats"})
+	c.Assert(err, check.Equals, provision.ErrInvalidMetricsConfig)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Metrics.IsEmpty(), check.Equals, true)
+}
+
+func (s *S) TestMetricsTargets(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", Quota: quota.Unlimited, TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddUnits(2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = a.AddUnits(1, "worker", nil)
+	c.Assert(err, check.IsNil)
+	targets, err := a.MetricsTargets()
+	c.Assert(err, check.IsNil)
+	c.Assert(targets, check.IsNil)
+	err = a.SetMetricsConfig(provision.MetricsConfig{Port: 9100, Process: "web"})
+	c.Assert(err, check.IsNil)
+	var expected []string
+	for _, u := range s.provisioner.GetUnits(&a) {
+		if u.ProcessName == "web" {
+			expected = append(expected, u.Ip+":9100")
+		}
+	}
+	sort.Strings(expected)
+	targets, err = a.MetricsTargets()
+	c.Assert(err, check.IsNil)
+	c.Assert(targets, check.DeepEquals, []MetricsTarget{
+		{
+			Targets: expected,
+			Labels: map[string]string{
+				"__metrics_path__": "/metrics",
+				"app":              "myapp",
+				"process":          "web",
+				"pool":             a.Pool,
+			},
+		},
+	})
+}
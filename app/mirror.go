@@ -0,0 +1,100 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrMirrorNotSupported   = errors.New("request mirroring is not supported by the router of the app, only planb routers support it")
+	ErrInvalidMirror        = errors.New("invalid mirror: percentage must be between 1 and 100 and the mirror must be another app")
+	ErrMirrorRouterMismatch = errors.New("invalid mirror: apps must use the same router")
+)
+
+// Mirror is an app receiving a copy of a percentage of the requests of
+// another app, usually a new version being tested with production traffic.
+// Responses of the mirror are discarded by the router.
+type Mirror struct {
+	App        string `json:"app"`
+	Percentage int    `json:"percentage"`
+}
+
+func (app *App) mirrorRouter() (router.MirrorRouter, error) {
+	r, err := app.GetRouter()
+	if err != nil {
+		return nil, err
+	}
+	mRouter, ok := r.(router.MirrorRouter)
+	if !ok {
+		return nil, ErrMirrorNotSupported
+	}
+	return mRouter, nil
+}
+
+// SetMirror copies the given percentage of the requests of the app to the
+// mirror app, replacing the current mirror, if any.
+func (app *App) SetMirror(mirror *App, percentage int) error {
+	if mirror.Name == app.Name || percentage < 1 || percentage > 100 {
+		return ErrInvalidMirror
+	}
+	routerName, err := app.GetRouterName()
+	if err != nil {
+		return err
+	}
+	mirrorRouterName, err := mirror.GetRouterName()
+	if err != nil {
+		return err
+	}
+	if mirrorRouterName != routerName {
+		return ErrMirrorRouterMismatch
+	}
+	mRouter, err := app.mirrorRouter()
+	if err != nil {
+		return err
+	}
+	err = mRouter.SetMirror(app.Name, mirror.Name, percentage)
+	if err != nil {
+		return err
+	}
+	m := Mirror{App: mirror.Name, Percentage: percentage}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"mirror": m}})
+	if err != nil {
+		return err
+	}
+	app.Mirror = m
+	return nil
+}
+
+// RemoveMirror stops copying the requests of the app to its mirror.
+func (app *App) RemoveMirror() error {
+	mRouter, err := app.mirrorRouter()
+	if err != nil {
+		return err
+	}
+	err = mRouter.UnsetMirror(app.Name)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$unset": bson.M{"mirror": ""}})
+	if err != nil {
+		return err
+	}
+	app.Mirror = Mirror{}
+	return nil
+}
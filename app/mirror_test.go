@@ -0,0 +1,56 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetMirror(c *check.C) {
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	shadow := App{Name: "myapp-next", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err = CreateApp(&shadow, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetMirror(&shadow, 10)
+	c.Assert(err, check.IsNil)
+	mirror, ok := routertest.FakeRouter.GetMirror(a.Name)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(mirror, check.DeepEquals, routertest.Mirror{Backend: shadow.Name, Percentage: 10})
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Mirror, check.DeepEquals, Mirror{App: shadow.Name, Percentage: 10})
+	err = a.RemoveMirror()
+	c.Assert(err, check.IsNil)
+	_, ok = routertest.FakeRouter.GetMirror(a.Name)
+	c.Assert(ok, check.Equals, false)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Mirror, check.DeepEquals, Mirror{})
+}
+
+func (s *S) TestSetMirrorInvalid(c *check.C) {
+	a := App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	shadow := App{Name: "myapp-next", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
+	err = CreateApp(&shadow, s.user)
+	c.Assert(err, check.IsNil)
+	other := App{Name: "other", Platform: "zend", TeamOwner: s.team.Name, Router: "fake-tls"}
+	err = CreateApp(&other, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetMirror(&a, 10)
+	c.Assert(err, check.Equals, ErrInvalidMirror)
+	err = a.SetMirror(&shadow, 0)
+	c.Assert(err, check.Equals, ErrInvalidMirror)
+	err = a.SetMirror(&shadow, 101)
+	c.Assert(err, check.Equals, ErrInvalidMirror)
+	err = a.SetMirror(&other, 10)
+	c.Assert(err, check.Equals, ErrMirrorRouterMismatch)
+	_, ok := routertest.FakeRouter.GetMirror(a.Name)
+	c.Assert(ok, check.Equals, false)
+}
@@ -0,0 +1,82 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrInvalidNodeRequirement = errors.Errorf("invalid node requirement: keys and values must not be empty and %q is managed by tsuru", provision.PoolMetadataName)
+
+// SetNodeRequirements replaces the metadata that nodes must have in order to
+// run units of the app. The requirements are checked against the nodes of the
+// app pool on each deploy.
+func (app *App) SetNodeRequirements(requirements map[string]string) error {
+	for k, v := range requirements {
+		if k == "" || v == "" || k == provision.PoolMetadataName {
+			return ErrInvalidNodeRequirement
+		}
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var update bson.M
+	if len(requirements) == 0 {
+		requirements = nil
+		update = bson.M{"$unset": bson.M{"noderequirements": ""}}
+	} else {
+		update = bson.M{"$set": bson.M{"noderequirements": requirements}}
+	}
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	app.NodeRequirements = requirements
+	return nil
+}
+
+// validateNodeRequirements ensures that at least one node in the app pool
+// satisfies the node requirements of the app. Provisioners that don't manage
+// nodes are not checked.
+func (app *App) validateNodeRequirements() error {
+	if len(app.NodeRequirements) == 0 {
+		return nil
+	}
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return err
+	}
+	nodeProv, ok := prov.(provision.NodeProvisioner)
+	if !ok {
+		return nil
+	}
+	nodes, err := nodeProv.ListNodes(nil)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if n.Pool() == app.Pool && provision.MatchesRequirements(n.Metadata(), app.NodeRequirements) {
+			return nil
+		}
+	}
+	var reqs []string
+	for k, v := range app.NodeRequirements {
+		reqs = append(reqs, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(reqs)
+	return &tsuruErrors.ValidationError{
+		Message: fmt.Sprintf("no node in pool %q matches the required metadata: %s", app.Pool, strings.Join(reqs, ", ")),
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetNodeRequirements(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetNodeRequirements(map[string]string{"disk": "ssd"})
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.NodeRequirements, check.DeepEquals, map[string]string{"disk": "ssd"})
+	err = a.SetNodeRequirements(nil)
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.NodeRequirements, check.IsNil)
+}
+
+func (s *S) TestSetNodeRequirementsInvalid(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	invalid := []map[string]string{
+		{"pool": "other"},
+		{"disk": ""},
+		{"": "ssd"},
+	}
+	for _, reqs := range invalid {
+		err = a.SetNodeRequirements(reqs)
+		c.Check(err, check.Equals, ErrInvalidNodeRequirement)
+	}
+}
+
+func (s *S) TestDeployValidatesNodeRequirements(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://node1:2375",
+		Metadata: map[string]string{"pool": s.Pool, "disk": "hdd"},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://node2:2375",
+		Metadata: map[string]string{"pool": "other", "disk": "ssd"},
+	})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name, Router: "fake"}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetNodeRequirements(map[string]string{"disk": "ssd"})
+	c.Assert(err, check.IsNil)
+	newEvt := func() *event.Event {
+		evt, evtErr := event.New(&event.Opts{
+			Target:   event.Target{Type: "app", Value: a.Name},
+			Kind:     permission.PermAppDeploy,
+			RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+			Allowed:  event.Allowed(permission.PermApp),
+		})
+		c.Assert(evtErr, check.IsNil)
+		return evt
+	}
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		OutputStream: &bytes.Buffer{},
+		Event:        newEvt(),
+	})
+	c.Assert(err, check.DeepEquals, &errors.ValidationError{
+		Message: `no node in pool "pool1" matches the required metadata: disk=ssd`,
+	})
+	err = s.provisioner.UpdateNode(provision.UpdateNodeOptions{
+		Address:  "http://node1:2375",
+		Metadata: map[string]string{"disk": "ssd"},
+	})
+	c.Assert(err, check.IsNil)
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		OutputStream: &bytes.Buffer{},
+		Event:        newEvt(),
+	})
+	c.Assert(err, check.IsNil)
+}
@@ -11,16 +11,22 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/provision"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 type Plan struct {
-	Name     string `bson:"_id" json:"name"`
-	Memory   int64  `json:"memory"`
-	Swap     int64  `json:"swap"`
-	CpuShare int    `json:"cpushare"`
-	Default  bool   `json:"default,omitempty"`
+	Name             string `bson:"_id" json:"name"`
+	Memory           int64  `json:"memory"`
+	Swap             int64  `json:"swap"`
+	CpuShare         int    `json:"cpushare"`
+	EphemeralStorage int64  `json:"ephemeralStorage,omitempty"`
+	Default          bool   `json:"default,omitempty"`
+	HardwareProfile  string `json:"hardwareProfile,omitempty" bson:",omitempty"`
+	// ToleratePreemption allows units of apps using the plan to run on
+	// preemptible nodes, unless overridden by the app.
+	ToleratePreemption bool `json:"toleratePreemption,omitempty" bson:",omitempty"`
 }
 
 type PlanValidationError struct{ field string }
@@ -35,6 +41,7 @@ var (
 	ErrPlanDefaultAmbiguous = errors.New("more than one default plan found")
 	ErrLimitOfCpuShare      = errors.New("The minimum allowed cpu-shares is 2")
 	ErrLimitOfMemory        = errors.New("The minimum allowed memory is 4MB")
+	ErrLimitOfStorage       = errors.New("The minimum allowed ephemeral storage is 4MB")
 )
 
 func (plan *Plan) Save() error {
@@ -47,6 +54,15 @@ func (plan *Plan) Save() error {
 	if plan.Memory > 0 && plan.Memory < 4194304 {
 		return ErrLimitOfMemory
 	}
+	if plan.EphemeralStorage < 0 || (plan.EphemeralStorage > 0 && plan.EphemeralStorage < 4194304) {
+		return ErrLimitOfStorage
+	}
+	if plan.HardwareProfile != "" {
+		_, err := provision.GetHardwareProfile(plan.HardwareProfile)
+		if err != nil {
+			return err
+		}
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return err
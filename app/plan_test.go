@@ -8,6 +8,7 @@ import (
 	"sort"
 
 	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/provision"
 	"gopkg.in/check.v1"
 )
 
@@ -46,14 +47,25 @@ func (s *S) TestPlanAddInvalid(c *check.C) {
 			Swap:     1024,
 			CpuShare: 100,
 		},
+		{
+			Name:             "plan1",
+			CpuShare:         100,
+			EphemeralStorage: 1024,
+		},
 	}
-	expectedError := []error{PlanValidationError{"name"}, ErrLimitOfCpuShare, ErrLimitOfMemory}
+	expectedError := []error{PlanValidationError{"name"}, ErrLimitOfCpuShare, ErrLimitOfMemory, ErrLimitOfStorage}
 	for i, p := range invalidPlans {
 		err := p.Save()
 		c.Assert(err, check.FitsTypeOf, expectedError[i])
 	}
 }
 
+func (s *S) TestPlanAddWithUnknownHardwareProfile(c *check.C) {
+	p := Plan{Name: "myplan", Memory: 4194304, CpuShare: 3, HardwareProfile: "fast"}
+	err := p.Save()
+	c.Assert(err, check.Equals, provision.ErrHardwareProfileNotFound)
+}
+
 func (s *S) TestPlanAddDupp(c *check.C) {
 	p := Plan{
 		Name:     "plan1",
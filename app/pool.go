@@ -0,0 +1,532 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/action"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/naming"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/cluster"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// RenamePool renames the pool, moving every reference to it to the new name:
+// apps, service instances, organizations, pool constraints, nodes, clusters,
+// role assignments and events. Each
+// step is undone if any of the following steps fail. Units are not restarted,
+// provisioners selecting nodes by pool only use the new name in the next
+// deploy or restart of each app.
+func RenamePool(oldName, newName string) error {
+	if oldName == newName {
+		return nil
+	}
+	err := naming.Validate(naming.Target{Kind: naming.KindPool, Name: newName})
+	if err != nil {
+		return err
+	}
+	pool, err := provision.GetPoolByName(oldName)
+	if err != nil {
+		return err
+	}
+	actions := []*action.Action{
+		&insertRenamedPool,
+		&renamePoolConstraints,
+		&renamePoolApps,
+		&renamePoolServiceInstances,
+		&renamePoolOrganization,
+		&renamePoolClusters,
+		&renamePoolNodes,
+		&renamePoolRoles,
+		&removeOldPool,
+		&renamePoolEvents,
+	}
+	return action.NewPipeline(actions...).Execute(pool, newName)
+}
+
+func renamePoolParams(ctx action.FWContext) (*provision.Pool, string, error) {
+	pool, ok := ctx.Params[0].(*provision.Pool)
+	if !ok {
+		return nil, "", errors.New("first parameter must be *provision.Pool")
+	}
+	newName, ok := ctx.Params[1].(string)
+	if !ok {
+		return nil, "", errors.New("second parameter must be a string")
+	}
+	return pool, newName, nil
+}
+
+var insertRenamedPool = action.Action{
+	Name: "insert-renamed-pool",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		pool, newName, err := renamePoolParams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := db.Conn()
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		newPool := *pool
+		newPool.Name = newName
+		err = conn.Pools().Insert(newPool)
+		if mgo.IsDup(err) {
+			return nil, provision.ErrPoolAlreadyExists
+		}
+		return nil, err
+	},
+	Backward: func(ctx action.BWContext) {
+		newName := ctx.Params[1].(string)
+		conn, err := db.Conn()
+		if err != nil {
+			log.Errorf("Could not connect to the database: %s", err)
+			return
+		}
+		defer conn.Close()
+		conn.Pools().RemoveId(newName)
+	},
+	MinParams: 2,
+}
+
+var renamePoolConstraints = action.Action{
+	Name: "rename-pool-constraints",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		pool, newName, err := renamePoolParams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := db.Conn()
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		var docs []struct {
+			ID bson.ObjectId `bson:"_id"`
+		}
+		// Only pool constraints are renamed, group constraints may use a
+		// pool expression with the same value as the pool name.
+		err = conn.PoolsConstraints().Find(bson.M{"poolexpr": pool.Name, "layer": nil}).Select(bson.M{"_id": 1}).All(&docs)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]bson.ObjectId, len(docs))
+		for i := range docs {
+			ids[i] = docs[i].ID
+		}
+		_, err = conn.PoolsConstraints().UpdateAll(bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$set": bson.M{"poolexpr": newName}})
+		return ids, err
+	},
+	Backward: func(ctx action.BWContext) {
+		pool := ctx.Params[0].(*provision.Pool)
+		ids := ctx.FWResult.([]bson.ObjectId)
+		conn, err := db.Conn()
+		if err != nil {
+			log.Errorf("Could not connect to the database: %s", err)
+			return
+		}
+		defer conn.Close()
+		_, err = conn.PoolsConstraints().UpdateAll(bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$set": bson.M{"poolexpr": pool.Name}})
+		if err != nil {
+			log.Errorf("[rename-pool-constraints:Backward] unable to restore constraints of pool %q: %s", pool.Name, err)
+		}
+	},
+	MinParams: 2,
+}
+
+var renamePoolApps = action.Action{
+	Name: "rename-pool-apps",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		pool, newName, err := renamePoolParams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := db.Conn()
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		var names []string
+		err = conn.Apps().Find(bson.M{"pool": pool.Name}).Distinct("name", &names)
+		if err != nil {
+			return nil, err
+		}
+		_, err = conn.Apps().UpdateAll(bson.M{"name": bson.M{"$in": names}}, bson.M{"$set": bson.M{"pool": newName}})
+		return names, err
+	},
+	Backward: func(ctx action.BWContext) {
+		pool := ctx.Params[0].(*provision.Pool)
+		names := ctx.FWResult.([]string)
+		conn, err := db.Conn()
+		if err != nil {
+			log.Errorf("Could not connect to the database: %s", err)
+			return
+		}
+		defer conn.Close()
+		_, err = conn.Apps().UpdateAll(bson.M{"name": bson.M{"$in": names}}, bson.M{"$set": bson.M{"pool": pool.Name}})
+		if err != nil {
+			log.Errorf("[rename-pool-apps:Backward] unable to restore apps of pool %q: %s", pool.Name, err)
+		}
+	},
+	MinParams: 2,
+}
+
+var renamePoolServiceInstances = action.Action{
+	Name: "rename-pool-service-instances",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		pool, newName, err := renamePoolParams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := db.Conn()
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		var docs []struct {
+			ID bson.ObjectId `bson:"_id"`
+		}
+		err = conn.ServiceInstances().Find(bson.M{"pool": pool.Name}).Select(bson.M{"_id": 1}).All(&docs)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]bson.ObjectId, len(docs))
+		for i := range docs {
+			ids[i] = docs[i].ID
+		}
+		_, err = conn.ServiceInstances().UpdateAll(bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$set": bson.M{"pool": newName}})
+		return ids, err
+	},
+	Backward: func(ctx action.BWContext) {
+		pool := ctx.Params[0].(*provision.Pool)
+		ids := ctx.FWResult.([]bson.ObjectId)
+		conn, err := db.Conn()
+		if err != nil {
+			log.Errorf("Could not connect to the database: %s", err)
+			return
+		}
+		defer conn.Close()
+		_, err = conn.ServiceInstances().UpdateAll(bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$set": bson.M{"pool": pool.Name}})
+		if err != nil {
+			log.Errorf("[rename-pool-service-instances:Backward] unable to restore service instances of pool %q: %s", pool.Name, err)
+		}
+	},
+	MinParams: 2,
+}
+
+var renamePoolOrganization = action.Action{
+	Name: "rename-pool-organization",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		pool, newName, err := renamePoolParams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := db.Conn()
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		err = conn.Organizations().Update(bson.M{"pools": pool.Name}, bson.M{"$set": bson.M{"pools.$": newName}})
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return err == nil, err
+	},
+	Backward: func(ctx action.BWContext) {
+		pool := ctx.Params[0].(*provision.Pool)
+		newName := ctx.Params[1].(string)
+		if renamed, _ := ctx.FWResult.(bool); !renamed {
+			return
+		}
+		conn, err := db.Conn()
+		if err != nil {
+			log.Errorf("Could not connect to the database: %s", err)
+			return
+		}
+		defer conn.Close()
+		err = conn.Organizations().Update(bson.M{"pools": newName}, bson.M{"$set": bson.M{"pools.$": pool.Name}})
+		if err != nil {
+			log.Errorf("[rename-pool-organization:Backward] unable to restore organization of pool %q: %s", pool.Name, err)
+		}
+	},
+	MinParams: 2,
+}
+
+func renameClustersPool(clusterNames []string, from, to string) ([]string, error) {
+	clusters, err := cluster.AllClusters()
+	if err != nil {
+		return nil, err
+	}
+	filter := make(map[string]struct{}, len(clusterNames))
+	for _, name := range clusterNames {
+		filter[name] = struct{}{}
+	}
+	var renamed []string
+	for _, c := range clusters {
+		if _, ok := filter[c.Name]; clusterNames != nil && !ok {
+			continue
+		}
+		found := false
+		for i := range c.Pools {
+			if c.Pools[i] == from {
+				c.Pools[i] = to
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+		err = c.Save()
+		if err != nil {
+			return renamed, err
+		}
+		renamed = append(renamed, c.Name)
+	}
+	return renamed, nil
+}
+
+var renamePoolClusters = action.Action{
+	Name: "rename-pool-clusters",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		pool, newName, err := renamePoolParams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		renamed, err := renameClustersPool(nil, pool.Name, newName)
+		if err != nil {
+			if len(renamed) > 0 {
+				renameClustersPool(renamed, newName, pool.Name)
+			}
+			return nil, err
+		}
+		return renamed, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		pool := ctx.Params[0].(*provision.Pool)
+		newName := ctx.Params[1].(string)
+		renamed := ctx.FWResult.([]string)
+		if len(renamed) == 0 {
+			return
+		}
+		_, err := renameClustersPool(renamed, newName, pool.Name)
+		if err != nil {
+			log.Errorf("[rename-pool-clusters:Backward] unable to restore clusters of pool %q: %s", pool.Name, err)
+		}
+	},
+	MinParams: 2,
+}
+
+func renameNodesPool(pool *provision.Pool, addresses []string, from, to string) ([]string, error) {
+	prov, err := pool.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	nodeProv, ok := prov.(provision.NodeProvisioner)
+	if !ok {
+		return nil, nil
+	}
+	nodes, err := nodeProv.ListNodes(addresses)
+	if err != nil {
+		return nil, err
+	}
+	var renamed []string
+	for _, n := range nodes {
+		if n.Pool() != from {
+			continue
+		}
+		metadata := map[string]string{}
+		for k, v := range n.Metadata() {
+			metadata[k] = v
+		}
+		metadata[provision.PoolMetadataName] = to
+		err = nodeProv.UpdateNode(provision.UpdateNodeOptions{
+			Address:  n.Address(),
+			Metadata: metadata,
+		})
+		if err != nil {
+			return renamed, err
+		}
+		renamed = append(renamed, n.Address())
+	}
+	return renamed, nil
+}
+
+var renamePoolNodes = action.Action{
+	Name: "rename-pool-nodes",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		pool, newName, err := renamePoolParams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		renamed, err := renameNodesPool(pool, nil, pool.Name, newName)
+		if err != nil {
+			if len(renamed) > 0 {
+				renameNodesPool(pool, renamed, newName, pool.Name)
+			}
+			return nil, err
+		}
+		return renamed, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		pool := ctx.Params[0].(*provision.Pool)
+		newName := ctx.Params[1].(string)
+		renamed := ctx.FWResult.([]string)
+		if len(renamed) == 0 {
+			return
+		}
+		_, err := renameNodesPool(pool, renamed, newName, pool.Name)
+		if err != nil {
+			log.Errorf("[rename-pool-nodes:Backward] unable to restore nodes of pool %q: %s", pool.Name, err)
+		}
+	},
+	MinParams: 2,
+}
+
+func poolRoleNames() ([]string, error) {
+	roles, err := permission.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, r := range roles {
+		if r.ContextType == permission.CtxPool {
+			names = append(names, r.Name)
+		}
+	}
+	return names, nil
+}
+
+func renameUsersPoolRoles(emails []string, roleNames []string, from, to string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	query := bson.M{"roles": bson.M{"$elemMatch": bson.M{
+		"name":         bson.M{"$in": roleNames},
+		"contextvalue": from,
+	}}}
+	if emails != nil {
+		query["email"] = bson.M{"$in": emails}
+	}
+	// The positional operator only updates the first matching role of each
+	// user, so the update is repeated for users with many roles in the pool.
+	for {
+		info, err := conn.Users().UpdateAll(query, bson.M{"$set": bson.M{"roles.$.contextvalue": to}})
+		if err != nil {
+			return err
+		}
+		if info.Updated == 0 {
+			return nil
+		}
+	}
+}
+
+var renamePoolRoles = action.Action{
+	Name: "rename-pool-roles",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		pool, newName, err := renamePoolParams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		roleNames, err := poolRoleNames()
+		if err != nil {
+			return nil, err
+		}
+		if len(roleNames) == 0 {
+			return []string{}, nil
+		}
+		conn, err := db.Conn()
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		var emails []string
+		err = conn.Users().Find(bson.M{"roles": bson.M{"$elemMatch": bson.M{
+			"name":         bson.M{"$in": roleNames},
+			"contextvalue": pool.Name,
+		}}}).Distinct("email", &emails)
+		if err != nil {
+			return nil, err
+		}
+		if len(emails) == 0 {
+			return []string{}, nil
+		}
+		err = renameUsersPoolRoles(emails, roleNames, pool.Name, newName)
+		if err != nil {
+			renameUsersPoolRoles(emails, roleNames, newName, pool.Name)
+			return nil, err
+		}
+		return emails, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		pool := ctx.Params[0].(*provision.Pool)
+		newName := ctx.Params[1].(string)
+		emails := ctx.FWResult.([]string)
+		if len(emails) == 0 {
+			return
+		}
+		roleNames, err := poolRoleNames()
+		if err == nil {
+			err = renameUsersPoolRoles(emails, roleNames, newName, pool.Name)
+		}
+		if err != nil {
+			log.Errorf("[rename-pool-roles:Backward] unable to restore roles in pool %q: %s", pool.Name, err)
+		}
+	},
+	MinParams: 2,
+}
+
+var removeOldPool = action.Action{
+	Name: "remove-old-pool",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		pool, _, err := renamePoolParams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := db.Conn()
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		return nil, conn.Pools().RemoveId(pool.Name)
+	},
+	Backward: func(ctx action.BWContext) {
+		pool := ctx.Params[0].(*provision.Pool)
+		conn, err := db.Conn()
+		if err != nil {
+			log.Errorf("Could not connect to the database: %s", err)
+			return
+		}
+		defer conn.Close()
+		err = conn.Pools().Insert(pool)
+		if err != nil {
+			log.Errorf("[remove-old-pool:Backward] unable to restore pool %q: %s", pool.Name, err)
+		}
+	},
+	MinParams: 2,
+}
+
+var renamePoolEvents = action.Action{
+	Name: "rename-pool-events",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		pool, newName, err := renamePoolParams(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return nil, event.RenameTarget(
+			event.Target{Type: event.TargetTypePool, Value: pool.Name},
+			event.Target{Type: event.TargetTypePool, Value: newName},
+			permission.Context(permission.CtxPool, pool.Name),
+			permission.Context(permission.CtxPool, newName),
+		)
+	},
+	MinParams: 2,
+}
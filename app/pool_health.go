@@ -0,0 +1,115 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router"
+)
+
+const (
+	PoolHealthOK   = "ok"
+	PoolHealthFail = "fail"
+)
+
+// PoolHealthCheck is the result of one of the checks of a pool.
+type PoolHealthCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// PoolHealthReport is the result of all checks of a pool. The pool is healthy
+// when all checks succeed.
+type PoolHealthReport struct {
+	Pool    string            `json:"pool"`
+	Healthy bool              `json:"healthy"`
+	Checks  []PoolHealthCheck `json:"checks"`
+}
+
+func (r *PoolHealthReport) add(name string, err error, okMessage string) {
+	check := PoolHealthCheck{Name: name, Status: PoolHealthOK, Message: okMessage}
+	if err != nil {
+		check.Status = PoolHealthFail
+		check.Message = err.Error()
+		r.Healthy = false
+	}
+	r.Checks = append(r.Checks, check)
+}
+
+// PoolHealth checks the infrastructure of the pool: whether the cluster of
+// its provisioner is reachable, whether its nodes are ready and whether its
+// routers are available. Checks not supported by the provisioner or by a
+// router are skipped.
+func PoolHealth(poolName string) (*PoolHealthReport, error) {
+	pool, err := provision.GetPoolByName(poolName)
+	if err != nil {
+		return nil, err
+	}
+	prov, err := pool.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	report := &PoolHealthReport{Pool: poolName, Healthy: true}
+	if hcProv, ok := prov.(provision.PoolHealthCheckProvisioner); ok {
+		report.add("cluster", hcProv.PoolHealthCheck(poolName), "")
+	}
+	if nodeProv, ok := prov.(provision.NodeProvisioner); ok {
+		msg, err := checkPoolNodes(nodeProv, poolName)
+		report.add("nodes", err, msg)
+	}
+	routers, err := pool.GetRouters()
+	if err != nil && err != provision.ErrPoolHasNoRouter {
+		return nil, err
+	}
+	if len(routers) == 0 {
+		report.add("routers", provision.ErrPoolHasNoRouter, "")
+	}
+	for _, name := range routers {
+		report.add("router "+name, checkPoolRouter(name), "")
+	}
+	return report, nil
+}
+
+func checkPoolNodes(prov provision.NodeProvisioner, poolName string) (string, error) {
+	nodes, err := prov.ListNodes(nil)
+	if err != nil {
+		return "", err
+	}
+	var total int
+	var notReady []string
+	for _, n := range nodes {
+		if n.Pool() != poolName {
+			continue
+		}
+		total++
+		// Provisioners report nodes able to run units with the ready status.
+		if !strings.EqualFold(n.Status(), "ready") {
+			notReady = append(notReady, fmt.Sprintf("%s (%s)", n.Address(), n.Status()))
+		}
+	}
+	if total == 0 {
+		return "", errors.Errorf("no nodes found in pool %q", poolName)
+	}
+	if len(notReady) > 0 {
+		return "", errors.Errorf("%d of %d nodes not ready: %s", len(notReady), total, strings.Join(notReady, ", "))
+	}
+	return fmt.Sprintf("%d nodes ready", total), nil
+}
+
+func checkPoolRouter(name string) error {
+	r, err := router.Get(name)
+	if err != nil {
+		return err
+	}
+	if hc, ok := r.(router.HealthChecker); ok {
+		return hc.HealthCheck()
+	}
+	return nil
+}
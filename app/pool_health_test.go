@@ -0,0 +1,78 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestPoolHealth(c *check.C) {
+	err := provision.SetPoolConstraint(&provision.PoolConstraint{
+		PoolExpr: s.Pool,
+		Field:    "router",
+		Values:   []string{"fake-hc"},
+	})
+	c.Assert(err, check.IsNil)
+	for _, addr := range []string{"http://n1:2375", "http://n2:2375"} {
+		err = s.provisioner.AddNode(provision.AddNodeOptions{Address: addr, Metadata: map[string]string{"pool": s.Pool}})
+		c.Assert(err, check.IsNil)
+		err = s.provisioner.SetNodeStatus(addr, "ready")
+		c.Assert(err, check.IsNil)
+	}
+	err = s.provisioner.AddNode(provision.AddNodeOptions{Address: "http://n3:2375", Metadata: map[string]string{"pool": "other"}})
+	c.Assert(err, check.IsNil)
+	report, err := PoolHealth(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(report, check.DeepEquals, &PoolHealthReport{
+		Pool:    s.Pool,
+		Healthy: true,
+		Checks: []PoolHealthCheck{
+			{Name: "cluster", Status: PoolHealthOK},
+			{Name: "nodes", Status: PoolHealthOK, Message: "2 nodes ready"},
+			{Name: "router fake-hc", Status: PoolHealthOK},
+		},
+	})
+}
+
+func (s *S) TestPoolHealthFailures(c *check.C) {
+	err := provision.SetPoolConstraint(&provision.PoolConstraint{
+		PoolExpr: s.Pool,
+		Field:    "router",
+		Values:   []string{"fake-hc"},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{Address: "http://n1:2375", Metadata: map[string]string{"pool": s.Pool}})
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareFailure("PoolHealthCheck", errors.New("cluster unreachable"))
+	routertest.HCRouter.SetErr(errors.New("router down"))
+	defer routertest.HCRouter.SetErr(nil)
+	report, err := PoolHealth(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(report, check.DeepEquals, &PoolHealthReport{
+		Pool:    s.Pool,
+		Healthy: false,
+		Checks: []PoolHealthCheck{
+			{Name: "cluster", Status: PoolHealthFail, Message: "cluster unreachable"},
+			{Name: "nodes", Status: PoolHealthFail, Message: "1 of 1 nodes not ready: http://n1:2375 (enabled)"},
+			{Name: "router fake-hc", Status: PoolHealthFail, Message: "router down"},
+		},
+	})
+}
+
+func (s *S) TestPoolHealthNoNodes(c *check.C) {
+	report, err := PoolHealth(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Healthy, check.Equals, false)
+	c.Assert(report.Checks[1], check.DeepEquals, PoolHealthCheck{
+		Name:    "nodes",
+		Status:  PoolHealthFail,
+		Message: `no nodes found in pool "` + s.Pool + `"`,
+	})
+	_, err = PoolHealth("unknown")
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+}
@@ -0,0 +1,326 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/image"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/cluster"
+	"github.com/tsuru/tsuru/router/rebuild"
+)
+
+var (
+	ErrPoolMigrationTargetRequired = &tsuruErrors.ValidationError{Message: "either a provisioner or a cluster is required to migrate a pool"}
+	ErrPoolMigrationHasApps        = &tsuruErrors.ValidationError{Message: "pool has apps, reschedule is required to migrate them"}
+)
+
+type MigratePoolOptions struct {
+	Provisioner string
+	Cluster     string
+	Reschedule  bool
+	Writer      io.Writer
+	Event       *event.Event
+}
+
+// MigratePool moves the pool to another provisioner, optionally assigning it
+// to one of the clusters of the new provisioner. Pools with apps are only
+// migrated when Reschedule is set: each app is then provisioned and has its
+// current image deployed in the new provisioner, with the same number of
+// units per process, and once the new units are started its routes are
+// switched to them and it's removed from the old provisioner. The pool only
+// moves to the new provisioner after every app is rescheduled: when an app
+// fails to be rescheduled, the apps already rescheduled are moved back to the
+// old provisioner and the pool is left unchanged.
+func MigratePool(poolName string, opts MigratePoolOptions) error {
+	w := opts.Writer
+	if w == nil {
+		w = ioutil.Discard
+	}
+	pool, err := provision.GetPoolByName(poolName)
+	if err != nil {
+		return err
+	}
+	var c *cluster.Cluster
+	if opts.Cluster != "" {
+		c, err = cluster.ByName(opts.Cluster)
+		if err != nil {
+			return err
+		}
+		if opts.Provisioner == "" {
+			opts.Provisioner = c.Provisioner
+		}
+		if c.Provisioner != opts.Provisioner {
+			return &tsuruErrors.ValidationError{
+				Message: fmt.Sprintf("cluster %q uses provisioner %q", c.Name, c.Provisioner),
+			}
+		}
+	}
+	if opts.Provisioner == "" {
+		return ErrPoolMigrationTargetRequired
+	}
+	newProv, err := provision.Get(opts.Provisioner)
+	if err != nil {
+		return &tsuruErrors.ValidationError{Message: err.Error()}
+	}
+	oldProv, err := pool.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	if oldProv.GetName() == newProv.GetName() {
+		return &tsuruErrors.ValidationError{
+			Message: fmt.Sprintf("pool %q already uses provisioner %q", poolName, newProv.GetName()),
+		}
+	}
+	apps, err := List(&Filter{Pool: poolName})
+	if err != nil {
+		return err
+	}
+	if len(apps) > 0 {
+		if !opts.Reschedule {
+			return ErrPoolMigrationHasApps
+		}
+		if _, ok := newProv.(provision.ImageDeployer); !ok {
+			return provision.ProvisionerNotSupported{Prov: newProv, Action: "image deploys"}
+		}
+	}
+	// Apps are kept locked until the pool is moved, so they're not deployed
+	// while running in a provisioner other than the one of their pool.
+	for i := range apps {
+		a := &apps[i]
+		var locked bool
+		locked, err = a.InternalLock("pool migration")
+		if err == nil && !locked {
+			err = errors.Errorf("app %q is locked", a.Name)
+		}
+		if err != nil {
+			return err
+		}
+		defer a.Unlock()
+	}
+	addedToCluster := c != nil && !c.Default && !containsString(c.Pools, poolName)
+	if addedToCluster {
+		c.Pools = append(c.Pools, poolName)
+		err = c.Save()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "---- Pool %q added to cluster %q ----\n", poolName, c.Name)
+	}
+	rescheduleOpts := poolRescheduleOptions{
+		fromPool:      poolName,
+		healthTimeout: defaultPoolMoveHealthTimeout,
+		writer:        w,
+		event:         opts.Event,
+	}
+	for i := range apps {
+		a := &apps[i]
+		err = opts.Event.Checkpoint("reschedule app")
+		if err == event.ErrCanceled {
+			fmt.Fprintf(w, " ---> Migration of pool %q canceled, rolling back\n", poolName)
+			rollbackPoolMigration(apps[:i], newProv, oldProv, w)
+			removeMigratedPoolFromCluster(c, addedToCluster)
+			return err
+		}
+		fmt.Fprintf(w, "---- Rescheduling app %q (%d of %d) ----\n", a.Name, i+1, len(apps))
+		err = reschedulePoolApp(a, oldProv, newProv, rescheduleOpts)
+		if _, ok := err.(*poolDrainError); ok {
+			// The app already runs in the new provisioner, only leftovers
+			// remain in the old one.
+			fmt.Fprintf(w, " ---> App %q rescheduled, but %s\n", a.Name, err)
+			err = nil
+		}
+		if err != nil {
+			fmt.Fprintf(w, " ---> Failed to reschedule app %q, rolling back: %s\n", a.Name, err)
+			rollbackPoolMigration(apps[:i], newProv, oldProv, w)
+			removeMigratedPoolFromCluster(c, addedToCluster)
+			return errors.Wrapf(err, "unable to reschedule app %q, pool %q was kept in provisioner %q", a.Name, poolName, oldProv.GetName())
+		}
+	}
+	err = provision.PoolUpdate(poolName, provision.UpdatePoolOptions{Provisioner: newProv.GetName()})
+	if err != nil {
+		rollbackPoolMigration(apps, newProv, oldProv, w)
+		return err
+	}
+	fmt.Fprintf(w, "---- Pool %q moved from provisioner %q to %q ----\n", poolName, oldProv.GetName(), newProv.GetName())
+	return nil
+}
+
+// removeMigratedPoolFromCluster undoes the addition of the pool to the
+// cluster made by MigratePool.
+func removeMigratedPoolFromCluster(c *cluster.Cluster, added bool) {
+	if !added {
+		return
+	}
+	pool := c.Pools[len(c.Pools)-1]
+	c.Pools = c.Pools[:len(c.Pools)-1]
+	err := c.Save()
+	if err != nil {
+		log.Errorf("[pool migration] unable to remove pool %q from cluster %q: %s", pool, c.Name, err)
+	}
+}
+
+// rollbackPoolMigration moves the apps already rescheduled by MigratePool
+// back to the old provisioner. Failures are only logged, as the original
+// failure is the one reported.
+func rollbackPoolMigration(apps []App, newProv, oldProv provision.Provisioner, w io.Writer) {
+	for i := len(apps) - 1; i >= 0; i-- {
+		a := &apps[i]
+		fmt.Fprintf(w, "---- Moving app %q back to provisioner %q ----\n", a.Name, oldProv.GetName())
+		err := reschedulePoolApp(a, newProv, oldProv, poolRescheduleOptions{
+			fromPool:      a.Pool,
+			healthTimeout: defaultPoolMoveHealthTimeout,
+			writer:        w,
+		})
+		if err != nil {
+			fmt.Fprintf(w, " ---> Failed to move app %q back: %s\n", a.Name, err)
+			log.Errorf("[pool migration] unable to move app %q back to provisioner %q: %s", a.Name, oldProv.GetName(), err)
+		}
+	}
+}
+
+// poolRescheduleOptions controls how reschedulePoolApp moves an app between
+// provisioners. fromPool is the pool the app is in the old provisioner,
+// which differs from the current pool of the app when it's moving to
+// another pool. When healthTimeout is zero the new units are not waited.
+type poolRescheduleOptions struct {
+	fromPool      string
+	healthTimeout time.Duration
+	writer        io.Writer
+	event         *event.Event
+}
+
+// poolDrainError is returned by reschedulePoolApp when the app is already
+// running and routed in the new provisioner, but couldn't be removed from
+// the old one.
+type poolDrainError struct {
+	prov string
+	err  error
+}
+
+func (e *poolDrainError) Error() string {
+	return fmt.Sprintf("unable to remove app from provisioner %q: %s", e.prov, e.err)
+}
+
+// appInPool overrides the pool of the app, so the old provisioner finds the
+// app where it was while the app is moving to another pool.
+type appInPool struct {
+	*App
+	pool string
+}
+
+func (a *appInPool) GetPool() string {
+	return a.pool
+}
+
+// reschedulePoolApp moves the app from one provisioner to another. The app
+// is provisioned and has its current image deployed in the new provisioner,
+// with the same number of units per process, then its routes are rebuilt to
+// the new units and it's destroyed in the old provisioner. When anything
+// fails before the routes are rebuilt, the app is destroyed in the new
+// provisioner and keeps running in the old one. The app must be locked by
+// the caller.
+func reschedulePoolApp(a *App, from, to provision.Provisioner, opts poolRescheduleOptions) error {
+	w := opts.writer
+	if w == nil {
+		w = ioutil.Discard
+	}
+	oldApp := &appInPool{App: a, pool: opts.fromPool}
+	units, err := from.Units(oldApp)
+	if err != nil {
+		return err
+	}
+	processUnits := map[string]uint{}
+	for _, u := range units {
+		processUnits[u.ProcessName]++
+	}
+	var routesRebuilt bool
+	err = startPoolRescheduleUnits(a, to, processUnits, w, opts.event)
+	if err == nil && opts.healthTimeout > 0 {
+		fmt.Fprintf(w, "---- Waiting for units in provisioner %q to start ----\n", to.GetName())
+		// All units in the new provisioner are new, unit ids from the old
+		// provisioner may clash with them.
+		err = waitPoolMoveUnits(a, to, nil, opts.healthTimeout, opts.event)
+	}
+	if err == nil {
+		// Units removed from the old provisioner can't be brought back, this
+		// is the last point where the move can be canceled.
+		err = opts.event.Checkpoint("drain units")
+	}
+	if err == nil {
+		routesRebuilt = true
+		_, err = rebuild.RebuildRoutes(a)
+	}
+	if err != nil {
+		destroyErr := to.Destroy(a)
+		if destroyErr != nil {
+			log.Errorf("[pool reschedule] unable to destroy app %q in provisioner %q: %s", a.Name, to.GetName(), destroyErr)
+		}
+		a.provisioner = from
+		if routesRebuilt {
+			rebuild.RoutesRebuildOrEnqueue(a.Name)
+		}
+		return err
+	}
+	fmt.Fprintf(w, "---- Removing app %q from provisioner %q ----\n", a.Name, from.GetName())
+	for process, n := range processUnits {
+		err = from.RemoveUnits(oldApp, n, process, w)
+		if err != nil {
+			return &poolDrainError{prov: from.GetName(), err: err}
+		}
+	}
+	if destroyer, ok := from.(provision.ImageKeepingDestroyer); ok {
+		err = destroyer.DestroyKeepingImages(oldApp)
+	} else {
+		err = from.Destroy(oldApp)
+	}
+	if err != nil {
+		return &poolDrainError{prov: from.GetName(), err: err}
+	}
+	return nil
+}
+
+// startPoolRescheduleUnits provisions the app in the new provisioner and
+// deploys its current image, adding the units missing for each process.
+func startPoolRescheduleUnits(a *App, to provision.Provisioner, processUnits map[string]uint, w io.Writer, evt *event.Event) error {
+	a.provisioner = to
+	err := to.Provision(a)
+	if err != nil {
+		return err
+	}
+	if a.Deploys == 0 {
+		return nil
+	}
+	img, err := image.AppCurrentImageName(a.Name)
+	if err != nil {
+		return err
+	}
+	_, err = to.(provision.ImageDeployer).ImageDeploy(a, img, evt)
+	if err != nil {
+		return err
+	}
+	units, err := to.Units(a)
+	if err != nil {
+		return err
+	}
+	return addPoolMoveUnits(a, to, processUnits, units, w)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
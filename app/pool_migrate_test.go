@@ -0,0 +1,208 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/cluster"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type migrateTargetProvisioner struct {
+	*provisiontest.FakeProvisioner
+	failApp string
+}
+
+func (p *migrateTargetProvisioner) AddUnits(app provision.App, n uint, process string, w io.Writer) error {
+	if app.GetName() == p.failApp {
+		return errors.New("no nodes available")
+	}
+	return p.FakeProvisioner.AddUnits(app, n, process, w)
+}
+
+func (p *migrateTargetProvisioner) GetName() string {
+	return "fake-migrate"
+}
+
+func (s *S) registerMigrateTarget() *migrateTargetProvisioner {
+	p := &migrateTargetProvisioner{FakeProvisioner: provisiontest.NewFakeProvisioner()}
+	provision.Register("fake-migrate", func() (provision.Provisioner, error) {
+		return p, nil
+	})
+	return p
+}
+
+func (s *S) newPoolMigrateEvent(c *check.C) *event.Event {
+	evt, err := event.New(&event.Opts{
+		Target:        event.Target{Type: event.TargetTypePool, Value: s.Pool},
+		Kind:          permission.PermPoolUpdateMigrate,
+		RawOwner:      event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:       event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, s.Pool)),
+		AllowedCancel: event.Allowed(permission.PermPoolUpdateMigrate, permission.Context(permission.CtxPool, s.Pool)),
+		Cancelable:    true,
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *S) TestMigratePool(c *check.C) {
+	target := s.registerMigrateTarget()
+	defer provision.Unregister("fake-migrate")
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "worker", nil)
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": bson.M{"deploys": 1}})
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	err = (&cluster.Cluster{
+		Name:        "c1",
+		Provisioner: "fake-migrate",
+		Addresses:   []string{"addr1"},
+		Pools:       []string{"other"},
+	}).Save()
+	c.Assert(err, check.IsNil)
+	evt := s.newPoolMigrateEvent(c)
+	defer evt.Done(nil)
+	var buf bytes.Buffer
+	err = MigratePool(s.Pool, MigratePoolOptions{
+		Cluster:    "c1",
+		Reschedule: true,
+		Writer:     &buf,
+		Event:      evt,
+	})
+	c.Assert(err, check.IsNil, check.Commentf("%s", buf.String()))
+	pool, err := provision.GetPoolByName(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "fake-migrate")
+	c1, err := cluster.ByName("c1")
+	c.Assert(err, check.IsNil)
+	c.Assert(c1.Pools, check.DeepEquals, []string{"other", s.Pool})
+	c.Assert(target.Provisioned(&a), check.Equals, true)
+	units, err := target.Units(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 3)
+	units, err = s.provisioner.Units(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 0)
+	c.Assert(buf.String(), check.Matches, `(?s).*Rescheduling app "myapp" \(1 of 1\).*`)
+}
+
+func (s *S) TestMigratePoolRollbackOnFailure(c *check.C) {
+	target := s.registerMigrateTarget()
+	defer provision.Unregister("fake-migrate")
+	a1 := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err := CreateApp(&a1, s.user)
+	c.Assert(err, check.IsNil)
+	a2 := App{Name: "otherapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err = CreateApp(&a2, s.user)
+	c.Assert(err, check.IsNil)
+	for _, a := range []*App{&a1, &a2} {
+		err = s.provisioner.AddUnits(a, 1, "web", nil)
+		c.Assert(err, check.IsNil)
+	}
+	apps, err := List(&Filter{Pool: s.Pool})
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 2)
+	target.failApp = apps[1].Name
+	var buf bytes.Buffer
+	err = MigratePool(s.Pool, MigratePoolOptions{Provisioner: "fake-migrate", Reschedule: true, Writer: &buf})
+	c.Assert(err, check.ErrorMatches, `unable to reschedule app "`+apps[1].Name+`", pool ".*" was kept in provisioner "fake": no nodes available`)
+	pool, err := provision.GetPoolByName(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "")
+	for _, a := range []*App{&a1, &a2} {
+		c.Assert(target.Provisioned(a), check.Equals, false)
+		c.Assert(s.provisioner.Provisioned(a), check.Equals, true)
+		units, err := s.provisioner.Units(a)
+		c.Assert(err, check.IsNil)
+		c.Assert(units, check.HasLen, 1)
+	}
+	c.Assert(buf.String(), check.Matches, `(?s).*Moving app "`+apps[0].Name+`" back to provisioner "fake".*`)
+}
+
+func (s *S) TestMigratePoolCanceled(c *check.C) {
+	target := s.registerMigrateTarget()
+	defer provision.Unregister("fake-migrate")
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	evt := s.newPoolMigrateEvent(c)
+	defer evt.Done(nil)
+	err = evt.TryCancel("wrong provisioner", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	err = MigratePool(s.Pool, MigratePoolOptions{Provisioner: "fake-migrate", Reschedule: true, Event: evt})
+	c.Assert(err, check.Equals, event.ErrCanceled)
+	c.Assert(evt.CancelInfo.Checkpoint, check.Equals, "reschedule app")
+	pool, err := provision.GetPoolByName(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "")
+	c.Assert(target.Provisioned(&a), check.Equals, false)
+	units, err := s.provisioner.Units(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+}
+
+func (s *S) TestMigratePoolWithAppsRequiresReschedule(c *check.C) {
+	s.registerMigrateTarget()
+	defer provision.Unregister("fake-migrate")
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = MigratePool(s.Pool, MigratePoolOptions{Provisioner: "fake-migrate"})
+	c.Assert(err, check.Equals, ErrPoolMigrationHasApps)
+	pool, err := provision.GetPoolByName(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "")
+}
+
+func (s *S) TestMigratePoolEmpty(c *check.C) {
+	s.registerMigrateTarget()
+	defer provision.Unregister("fake-migrate")
+	err := MigratePool(s.Pool, MigratePoolOptions{Provisioner: "fake-migrate"})
+	c.Assert(err, check.IsNil)
+	pool, err := provision.GetPoolByName(s.Pool)
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "fake-migrate")
+}
+
+func (s *S) TestMigratePoolInvalid(c *check.C) {
+	s.registerMigrateTarget()
+	defer provision.Unregister("fake-migrate")
+	err := (&cluster.Cluster{
+		Name:        "c1",
+		Provisioner: "fake",
+		Addresses:   []string{"addr1"},
+		Default:     true,
+	}).Save()
+	c.Assert(err, check.IsNil)
+	err = MigratePool("notfound", MigratePoolOptions{Provisioner: "fake-migrate"})
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+	err = MigratePool(s.Pool, MigratePoolOptions{})
+	c.Assert(err, check.Equals, ErrPoolMigrationTargetRequired)
+	err = MigratePool(s.Pool, MigratePoolOptions{Cluster: "c2"})
+	c.Assert(err, check.Equals, cluster.ErrClusterNotFound)
+	err = MigratePool(s.Pool, MigratePoolOptions{Provisioner: "fake-migrate", Cluster: "c1"})
+	c.Assert(err, check.ErrorMatches, `cluster "c1" uses provisioner "fake"`)
+	err = MigratePool(s.Pool, MigratePoolOptions{Provisioner: "fake"})
+	c.Assert(err, check.ErrorMatches, `pool ".*" already uses provisioner "fake"`)
+	err = MigratePool(s.Pool, MigratePoolOptions{Provisioner: "unknown"})
+	c.Assert(err, check.ErrorMatches, `unknown provisioner: "unknown"`)
+}
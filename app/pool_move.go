@@ -0,0 +1,272 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router/rebuild"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultPoolMoveHealthTimeout = 5 * time.Minute
+
+var poolMoveHealthInterval = 3 * time.Second
+
+type MovePoolOptions struct {
+	Pool string
+	// HealthTimeout is how long the units started in the target pool have
+	// to become healthy before the move is rolled back, five minutes by
+	// default.
+	HealthTimeout time.Duration
+	Writer        io.Writer
+	Event         *event.Event
+}
+
+// MoveToPool moves the app to another pool with minimal downtime. The target
+// pool is validated up front, then units are started in the target pool,
+// with the same number of units per process, and once they're healthy the
+// routes are switched to them and the units in the source pool are removed.
+// When the target pool uses another provisioner, the app is rescheduled like
+// in MigratePool, being destroyed in the old provisioner. When the new units
+// fail to become healthy they're removed and the app is left in the source
+// pool, as when the move is canceled through the event before the units in
+// the source pool start being removed. The app must be locked by the caller.
+func (app *App) MoveToPool(opts MovePoolOptions) error {
+	w := opts.Writer
+	if w == nil {
+		w = ioutil.Discard
+	}
+	if opts.HealthTimeout <= 0 {
+		opts.HealthTimeout = defaultPoolMoveHealthTimeout
+	}
+	if opts.Pool == "" {
+		return &tsuruErrors.ValidationError{Message: "target pool is required"}
+	}
+	if opts.Pool == app.Pool {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("app is already in pool %q", app.Pool)}
+	}
+	pool, err := provision.GetPoolByName(opts.Pool)
+	if err != nil {
+		return err
+	}
+	err = validatePoolAcceptsApps(pool)
+	if err != nil {
+		return err
+	}
+	from, err := app.getProvisioner()
+	if err != nil {
+		return err
+	}
+	to, err := pool.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	oldPool := app.Pool
+	app.Pool = pool.Name
+	err = app.validatePool()
+	if err == nil {
+		err = app.checkPoolQuotaForUpdate(oldPool, app.Plan)
+	}
+	app.Pool = oldPool
+	if err != nil {
+		return err
+	}
+	sameProv := from.GetName() == to.GetName()
+	if sameProv {
+		if _, ok := from.(provision.UnitRemoverProvisioner); !ok {
+			return provision.ProvisionerNotSupported{Prov: from, Action: "moving apps between pools"}
+		}
+	} else if _, ok := to.(provision.ImageDeployer); !ok && app.Deploys > 0 {
+		return provision.ProvisionerNotSupported{Prov: to, Action: "image deploys"}
+	}
+	units, err := from.Units(app)
+	if err != nil {
+		return err
+	}
+	oldUnits := map[string]bool{}
+	processUnits := map[string]uint{}
+	for _, u := range units {
+		oldUnits[u.ID] = true
+		processUnits[u.ProcessName]++
+	}
+	err = opts.Event.Checkpoint("start units")
+	if err != nil {
+		return err
+	}
+	err = app.savePool(pool.Name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "---- Starting %d units in pool %q ----\n", len(units), pool.Name)
+	if !sameProv {
+		err = reschedulePoolApp(app, from, to, poolRescheduleOptions{
+			fromPool:      oldPool,
+			healthTimeout: opts.HealthTimeout,
+			writer:        w,
+			event:         opts.Event,
+		})
+		if drainErr, ok := err.(*poolDrainError); ok {
+			return errors.Wrapf(drainErr.err, "app moved to pool %q, but unable to remove it from pool %q", pool.Name, oldPool)
+		}
+		if err == event.ErrCanceled {
+			fmt.Fprintf(w, " ---> Move to pool %q canceled, rolling back\n", pool.Name)
+		} else if err != nil {
+			fmt.Fprintf(w, " ---> Failed to start units in pool %q, rolling back: %s\n", pool.Name, err)
+		}
+		if err != nil {
+			restorePoolMove(app, from, oldPool)
+		}
+		return err
+	}
+	err = addPoolMoveUnits(app, to, processUnits, nil, w)
+	if err == nil {
+		fmt.Fprintf(w, "---- Waiting for units in pool %q to become healthy ----\n", pool.Name)
+		err = waitPoolMoveUnits(app, to, oldUnits, opts.HealthTimeout, opts.Event)
+	}
+	if err == nil {
+		// Units drained from the source pool can't be brought back, this is
+		// the last point where the move can be canceled.
+		err = opts.Event.Checkpoint("drain units")
+	}
+	if err == event.ErrCanceled {
+		fmt.Fprintf(w, " ---> Move to pool %q canceled, rolling back\n", pool.Name)
+		rollbackPoolMove(app, to, oldUnits, oldPool, w)
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(w, " ---> Failed to start units in pool %q, rolling back: %s\n", pool.Name, err)
+		rollbackPoolMove(app, to, oldUnits, oldPool, w)
+		return err
+	}
+	fmt.Fprintf(w, "---- Draining %d units from pool %q ----\n", len(units), oldPool)
+	ids := make([]string, 0, len(oldUnits))
+	for id := range oldUnits {
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		err = from.(provision.UnitRemoverProvisioner).RemoveUnitsByID(app, ids, w)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "app moved to pool %q, but unable to remove units from pool %q", pool.Name, oldPool)
+	}
+	rebuild.RoutesRebuildOrEnqueue(app.Name)
+	return nil
+}
+
+func (app *App) savePool(pool string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"pool": pool}})
+	if err != nil {
+		return err
+	}
+	app.Pool = pool
+	return nil
+}
+
+// addPoolMoveUnits adds the units of each process missing from the given
+// units.
+func addPoolMoveUnits(app *App, to provision.Provisioner, processUnits map[string]uint, existing []provision.Unit, w io.Writer) error {
+	missing := map[string]uint{}
+	for process, n := range processUnits {
+		missing[process] = n
+	}
+	for _, u := range existing {
+		if missing[u.ProcessName] > 0 {
+			missing[u.ProcessName]--
+		}
+	}
+	for process, n := range missing {
+		if n == 0 {
+			continue
+		}
+		err := to.AddUnits(app, n, process, w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitPoolMoveUnits waits until every unit not in oldUnits is started or the
+// move is canceled.
+func waitPoolMoveUnits(app *App, prov provision.Provisioner, oldUnits map[string]bool, timeout time.Duration, evt *event.Event) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := evt.Checkpoint("wait units")
+		if err != nil {
+			return err
+		}
+		units, err := prov.Units(app)
+		if err != nil {
+			return err
+		}
+		healthy := true
+		for _, u := range units {
+			if oldUnits[u.ID] {
+				continue
+			}
+			if u.Status == provision.StatusError {
+				return errors.Errorf("unit %q failed to start", u.ID)
+			}
+			if u.Status != provision.StatusStarted {
+				healthy = false
+			}
+		}
+		if healthy {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("timeout after %v waiting for units to start", timeout)
+		}
+		time.Sleep(poolMoveHealthInterval)
+	}
+}
+
+// rollbackPoolMove removes the units started in the target pool and moves
+// the app back to its source pool.
+func rollbackPoolMove(app *App, to provision.Provisioner, oldUnits map[string]bool, oldPool string, w io.Writer) {
+	units, err := to.Units(app)
+	if err != nil {
+		log.Errorf("[pool move] unable to list units of app %q: %s", app.Name, err)
+	}
+	var ids []string
+	for _, u := range units {
+		if !oldUnits[u.ID] {
+			ids = append(ids, u.ID)
+		}
+	}
+	if len(ids) > 0 {
+		err = to.(provision.UnitRemoverProvisioner).RemoveUnitsByID(app, ids, w)
+		if err != nil {
+			log.Errorf("[pool move] unable to remove new units of app %q: %s", app.Name, err)
+		}
+	}
+	restorePoolMove(app, to, oldPool)
+}
+
+// restorePoolMove moves the app back to its source pool, managed by the
+// given provisioner.
+func restorePoolMove(app *App, prov provision.Provisioner, oldPool string) {
+	app.provisioner = prov
+	err := app.savePool(oldPool)
+	if err != nil {
+		log.Errorf("[pool move] unable to restore pool of app %q: %s", app.Name, err)
+	}
+	rebuild.RoutesRebuildOrEnqueue(app.Name)
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppMoveToPool(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "worker", nil)
+	c.Assert(err, check.IsNil)
+	oldUnits := s.provisioner.GetUnits(&a)
+	var buf bytes.Buffer
+	err = a.MoveToPool(MovePoolOptions{Pool: "pool2", Writer: &buf})
+	c.Assert(err, check.IsNil, check.Commentf("%s", buf.String()))
+	c.Assert(a.Pool, check.Equals, "pool2")
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "pool2")
+	units := s.provisioner.GetUnits(&a)
+	c.Assert(units, check.HasLen, 3)
+	processes := map[string]int{}
+	for _, u := range units {
+		processes[u.ProcessName]++
+		for _, old := range oldUnits {
+			c.Assert(u.ID, check.Not(check.Equals), old.ID)
+		}
+	}
+	c.Assert(processes, check.DeepEquals, map[string]int{"web": 2, "worker": 1})
+	c.Assert(buf.String(), check.Matches, `(?s).*Starting 3 units in pool "pool2".*Draining 3 units from pool "pool1".*`)
+}
+
+func (s *S) TestAppMoveToPoolAnotherProvisioner(c *check.C) {
+	target := s.registerMigrateTarget()
+	defer provision.Unregister("fake-migrate")
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2", Public: true, Provisioner: "fake-migrate"})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = a.MoveToPool(MovePoolOptions{Pool: "pool2", Writer: &buf})
+	c.Assert(err, check.IsNil, check.Commentf("%s", buf.String()))
+	c.Assert(a.Pool, check.Equals, "pool2")
+	c.Assert(s.provisioner.Provisioned(&a), check.Equals, false)
+	c.Assert(target.Provisioned(&a), check.Equals, true)
+	units, err := target.Units(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	for _, u := range units {
+		c.Assert(routertest.FakeRouter.HasRoute(a.Name, u.Address.String()), check.Equals, true)
+	}
+}
+
+func (s *S) TestAppMoveToPoolRollbackOnFailure(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	oldUnits := s.provisioner.GetUnits(&a)
+	s.provisioner.PrepareFailure("AddUnits", errors.New("no nodes available"))
+	err = a.MoveToPool(MovePoolOptions{Pool: "pool2"})
+	c.Assert(err, check.ErrorMatches, "no nodes available")
+	c.Assert(a.Pool, check.Equals, s.Pool)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, s.Pool)
+	c.Assert(s.provisioner.GetUnits(&a), check.DeepEquals, oldUnits)
+}
+
+func (s *S) TestAppMoveToPoolValidatesConstraints(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2"})
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolConstraint(&provision.PoolConstraint{PoolExpr: "pool2", Field: "team", Values: []string{"other-team"}})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	oldUnits := s.provisioner.GetUnits(&a)
+	err = a.MoveToPool(MovePoolOptions{Pool: "pool2"})
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	c.Assert(a.Pool, check.Equals, s.Pool)
+	c.Assert(s.provisioner.GetUnits(&a), check.DeepEquals, oldUnits)
+}
+
+func (s *S) TestAppMoveToPoolInvalidTarget(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.MoveToPool(MovePoolOptions{Pool: s.Pool})
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	err = a.MoveToPool(MovePoolOptions{Pool: "unknown"})
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+}
+
+func (s *S) TestAppMoveToPoolCanceled(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	oldUnits := s.provisioner.GetUnits(&a)
+	evt, err := event.New(&event.Opts{
+		Target:        event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:          permission.PermAppUpdatePool,
+		RawOwner:      event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:       event.Allowed(permission.PermAppReadEvents),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents),
+		Cancelable:    true,
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.TryCancel("wrong pool", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	err = a.MoveToPool(MovePoolOptions{Pool: "pool2", Event: evt})
+	c.Assert(err, check.Equals, event.ErrCanceled)
+	c.Assert(evt.CancelInfo.Checkpoint, check.Equals, "start units")
+	c.Assert(a.Pool, check.Equals, s.Pool)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, s.Pool)
+	c.Assert(s.provisioner.GetUnits(&a), check.DeepEquals, oldUnits)
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestRenamePool(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolConstraint(&provision.PoolConstraint{PoolExpr: s.Pool, Field: "router", Values: []string{"fake"}})
+	c.Assert(err, check.IsNil)
+	err = provision.SetPoolConstraint(&provision.PoolConstraint{PoolExpr: s.Pool, Field: "router", Values: []string{"fake-tls"}, Layer: provision.ConstraintLayerGroup})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://node1:2375",
+		Metadata: map[string]string{"pool": s.Pool, "zone": "a"},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Insert(service.ServiceInstance{Name: "mydb", ServiceName: "mysql", Pool: s.Pool})
+	c.Assert(err, check.IsNil)
+	err = auth.CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	org, err := auth.GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	err = org.AddPool(s.Pool)
+	c.Assert(err, check.IsNil)
+	role, err := permission.NewRole("pool-admin", "pool", "")
+	c.Assert(err, check.IsNil)
+	err = s.user.AddRole(role.Name, s.Pool)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypePool, Value: s.Pool},
+		Kind:     permission.PermPoolUpdate,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, s.Pool)),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	err = RenamePool(s.Pool, "pool2")
+	c.Assert(err, check.IsNil)
+	_, err = provision.GetPoolByName(s.Pool)
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+	pool, err := provision.GetPoolByName("pool2")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Default, check.Equals, true)
+	constraints, err := provision.ListPoolsConstraints(bson.M{"poolexpr": "pool2"})
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 2)
+	constraints, err = provision.ListPoolsConstraints(bson.M{"poolexpr": s.Pool, "layer": provision.ConstraintLayerGroup})
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 1)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "pool2")
+	var instance service.ServiceInstance
+	err = s.conn.ServiceInstances().Find(bson.M{"name": "mydb"}).One(&instance)
+	c.Assert(err, check.IsNil)
+	c.Assert(instance.Pool, check.Equals, "pool2")
+	org, err = auth.GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Pools, check.DeepEquals, []string{"pool2"})
+	node, err := s.provisioner.GetNode("http://node1:2375")
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Metadata(), check.DeepEquals, map[string]string{"pool": "pool2", "zone": "a"})
+	user, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(user.Roles, check.DeepEquals, []auth.RoleInstance{{Name: "pool-admin", ContextValue: "pool2"}})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool2"},
+		Kind:   "pool.update",
+		Owner:  s.user.Email,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestRenamePoolNotFound(c *check.C) {
+	err := RenamePool("unknown", "pool2")
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+}
+
+func (s *S) TestRenamePoolAlreadyExists(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2"})
+	c.Assert(err, check.IsNil)
+	err = RenamePool(s.Pool, "pool2")
+	c.Assert(err, check.Equals, provision.ErrPoolAlreadyExists)
+	_, err = provision.GetPoolByName(s.Pool)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestRenamePoolRollback(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Pool: s.Pool}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "http://node1:2375",
+		Metadata: map[string]string{"pool": s.Pool},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Insert(service.ServiceInstance{Name: "mydb", ServiceName: "mysql", Pool: s.Pool})
+	c.Assert(err, check.IsNil)
+	err = auth.CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	org, err := auth.GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	err = org.AddPool(s.Pool)
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareFailure("UpdateNode", errors.New("update failed"))
+	err = RenamePool(s.Pool, "pool2")
+	c.Assert(err, check.ErrorMatches, "update failed")
+	_, err = provision.GetPoolByName("pool2")
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+	_, err = provision.GetPoolByName(s.Pool)
+	c.Assert(err, check.IsNil)
+	constraints, err := provision.ListPoolsConstraints(bson.M{"poolexpr": s.Pool})
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 1)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, s.Pool)
+	var instance service.ServiceInstance
+	err = s.conn.ServiceInstances().Find(bson.M{"name": "mydb"}).One(&instance)
+	c.Assert(err, check.IsNil)
+	c.Assert(instance.Pool, check.Equals, s.Pool)
+	org, err = auth.GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Pools, check.DeepEquals, []string{s.Pool})
+}
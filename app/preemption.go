@@ -0,0 +1,60 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// PreemptionTolerate allows units of the app to run on preemptible nodes,
+	// which are preferred over stable ones.
+	PreemptionTolerate = "tolerate"
+	// PreemptionAvoid keeps units of the app on stable nodes, even if the
+	// app plan tolerates preemption.
+	PreemptionAvoid = "avoid"
+)
+
+var ErrInvalidPreemptionTolerance = errors.Errorf("invalid preemption tolerance: must be %q, %q or empty to use the plan default", PreemptionTolerate, PreemptionAvoid)
+
+// SetPreemptionTolerance overrides whether units of the app may run on
+// preemptible nodes. An empty tolerance falls back to the app plan. Running
+// units are only moved on the next deploy.
+func (app *App) SetPreemptionTolerance(tolerance string) error {
+	if tolerance != "" && tolerance != PreemptionTolerate && tolerance != PreemptionAvoid {
+		return ErrInvalidPreemptionTolerance
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var update bson.M
+	if tolerance == "" {
+		update = bson.M{"$unset": bson.M{"preemptiontolerance": ""}}
+	} else {
+		update = bson.M{"$set": bson.M{"preemptiontolerance": tolerance}}
+	}
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	app.PreemptionTolerance = tolerance
+	return nil
+}
+
+// ToleratesPreemption returns whether units of the app may run on preemptible
+// nodes.
+func (app *App) ToleratesPreemption() bool {
+	switch app.PreemptionTolerance {
+	case PreemptionTolerate:
+		return true
+	case PreemptionAvoid:
+		return false
+	}
+	return app.Plan.ToleratePreemption
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import "gopkg.in/check.v1"
+
+func (s *S) TestSetPreemptionTolerance(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetPreemptionTolerance(PreemptionTolerate)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.PreemptionTolerance, check.Equals, PreemptionTolerate)
+	err = a.SetPreemptionTolerance("")
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.PreemptionTolerance, check.Equals, "")
+}
+
+func (s *S) TestSetPreemptionToleranceInvalid(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetPreemptionTolerance("sometimes")
+	c.Assert(err, check.Equals, ErrInvalidPreemptionTolerance)
+}
+
+func (s *S) TestToleratesPreemption(c *check.C) {
+	tests := []struct {
+		plan      bool
+		tolerance string
+		expected  bool
+	}{
+		{false, "", false},
+		{true, "", true},
+		{false, PreemptionTolerate, true},
+		{true, PreemptionAvoid, false},
+	}
+	for _, tt := range tests {
+		a := App{Plan: Plan{ToleratePreemption: tt.plan}, PreemptionTolerance: tt.tolerance}
+		c.Check(a.ToleratesPreemption(), check.Equals, tt.expected)
+	}
+}
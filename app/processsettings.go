@@ -0,0 +1,39 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SetProcessSettings replaces the start order and grace periods of the app
+// processes. The new settings are used by the provisioner in the next deploy
+// or restart of the app.
+func (app *App) SetProcessSettings(settings provision.ProcessSettings) error {
+	err := settings.Validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var update bson.M
+	if settings.IsEmpty() {
+		settings = provision.ProcessSettings{}
+		update = bson.M{"$unset": bson.M{"processsettings": ""}}
+	} else {
+		update = bson.M{"$set": bson.M{"processsettings": settings}}
+	}
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	app.ProcessSettings = settings
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetProcessSettings(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	settings := provision.ProcessSettings{
+		StartOrder:   []string{"migrate", "web"},
+		GracePeriods: map[string]int{"web": 30},
+	}
+	err = a.SetProcessSettings(settings)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ProcessSettings, check.DeepEquals, settings)
+	c.Assert(dbApp.GetProcessSettings(), check.DeepEquals, settings)
+	err = a.SetProcessSettings(provision.ProcessSettings{})
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ProcessSettings.IsEmpty(), check.Equals, true)
+}
+
+func (s *S) TestSetProcessSettingsInvalid(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetProcessSettings(provision.ProcessSettings{StartOrder: []string{"web", "web"}})
+	c.Assert(err, check.Equals, provision.ErrInvalidProcessSettings)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ProcessSettings.IsEmpty(), check.Equals, true)
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/provision"
+)
+
+const defaultScaleDownInterval = 30 * time.Second
+
+// ErrScaleDownCanceled is returned when a gradual removal of units is
+// canceled between steps.
+var ErrScaleDownCanceled = errors.New("unit removal canceled")
+
+// ScaleDownOptions controls the gradual removal of units.
+type ScaleDownOptions struct {
+	// Step is the maximum number of units removed at once. Zero removes all
+	// units in a single step.
+	Step uint
+	// Interval is the time waited after each step before checking the
+	// remaining units.
+	Interval time.Duration
+	// Event is checked for cancelation between steps.
+	Event *event.Event
+}
+
+// ScaleDownDefaults returns the options used for removals not setting them
+// explicitly. The step is read from the units:scale-down:max-step setting and
+// the interval from units:scale-down:interval.
+func ScaleDownDefaults() ScaleDownOptions {
+	var opts ScaleDownOptions
+	if step, err := config.GetUint("units:scale-down:max-step"); err == nil {
+		opts.Step = step
+	}
+	interval, err := config.GetDuration("units:scale-down:interval")
+	if err != nil || interval <= 0 {
+		interval = defaultScaleDownInterval
+	}
+	opts.Interval = interval
+	return opts
+}
+
+// IsGradual returns whether removing n units with the options is done in
+// many steps.
+func (o ScaleDownOptions) IsGradual(n uint) bool {
+	return o.Step > 0 && o.Step < n
+}
+
+// RemoveUnitsGradually removes n units from the app in steps of at most
+// opts.Step units. After each step, it waits for opts.Interval and aborts if
+// any remaining unit of the process isn't started, or if the event was
+// canceled.
+func (app *App) RemoveUnitsGradually(n uint, process string, opts ScaleDownOptions, w io.Writer) error {
+	if !opts.IsGradual(n) {
+		return app.RemoveUnits(n, process, w)
+	}
+	var removed uint
+	for removed < n {
+		step := opts.Step
+		if n-removed < step {
+			step = n - removed
+		}
+		fmt.Fprintf(w, "---- Removing %d units (%d of %d removed) ----\n", step, removed, n)
+		err := app.RemoveUnits(step, process, w)
+		if err != nil {
+			return err
+		}
+		removed += step
+		if removed == n {
+			break
+		}
+		time.Sleep(opts.Interval)
+		err = checkScaleDownCanceled(opts.Event)
+		if err != nil {
+			return errors.Wrapf(err, "%d of %d units removed", removed, n)
+		}
+		err = app.checkUnitsStarted(process)
+		if err != nil {
+			return errors.Wrapf(err, "aborting unit removal, %d of %d units removed", removed, n)
+		}
+	}
+	return nil
+}
+
+func checkScaleDownCanceled(evt *event.Event) error {
+	if evt.Checkpoint("unit removal") == event.ErrCanceled {
+		return ErrScaleDownCanceled
+	}
+	return nil
+}
+
+func (app *App) checkUnitsStarted(process string) error {
+	units, err := app.Units()
+	if err != nil {
+		return err
+	}
+	var notStarted []string
+	for _, u := range units {
+		if process != "" && u.ProcessName != process {
+			continue
+		}
+		if u.Status != provision.StatusStarted {
+			notStarted = append(notStarted, fmt.Sprintf("%s (%s)", u.ID, u.Status))
+		}
+	}
+	if len(notStarted) > 0 {
+		return errors.Errorf("units not started: %s", strings.Join(notStarted, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createScaleDownApp(c *check.C, units uint) *App {
+	a := App{Name: "chemistry", Platform: "python", Quota: quota.Unlimited, TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddUnits(units, "web", nil)
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) TestRemoveUnitsGradually(c *check.C) {
+	a := s.createScaleDownApp(c, 7)
+	buf := bytes.NewBuffer(nil)
+	err := a.RemoveUnitsGradually(5, "web", ScaleDownOptions{Step: 2, Interval: time.Millisecond}, buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 2)
+	c.Assert(buf.String(), check.Equals, "---- Removing 2 units (0 of 5 removed) ----\nremoving 2 units"+
+		"---- Removing 2 units (2 of 5 removed) ----\nremoving 2 units"+
+		"---- Removing 1 units (4 of 5 removed) ----\nremoving 1 units")
+}
+
+func (s *S) TestRemoveUnitsGraduallySingleStep(c *check.C) {
+	a := s.createScaleDownApp(c, 3)
+	buf := bytes.NewBuffer(nil)
+	err := a.RemoveUnitsGradually(2, "web", ScaleDownOptions{Step: 2}, buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 1)
+	c.Assert(buf.String(), check.Equals, "removing 2 units")
+}
+
+func (s *S) TestRemoveUnitsGraduallyAbortsOnUnhealthyUnits(c *check.C) {
+	a := s.createScaleDownApp(c, 5)
+	units := s.provisioner.GetUnits(a)
+	err := s.provisioner.SetUnitStatus(units[4], provision.StatusError)
+	c.Assert(err, check.IsNil)
+	err = a.RemoveUnitsGradually(4, "web", ScaleDownOptions{Step: 1, Interval: time.Millisecond}, nil)
+	c.Assert(err, check.ErrorMatches, `aborting unit removal, 1 of 4 units removed: units not started: .* \(error\)`)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 4)
+}
+
+func (s *S) TestRemoveUnitsGraduallyCanceled(c *check.C) {
+	a := s.createScaleDownApp(c, 5)
+	evt, err := event.New(&event.Opts{
+		Target:        event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:          permission.PermAppUpdateUnitRemove,
+		RawOwner:      event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:       event.Allowed(permission.PermAppReadEvents),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents),
+		Cancelable:    true,
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.TryCancel("oops", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	err = a.RemoveUnitsGradually(4, "web", ScaleDownOptions{Step: 1, Interval: time.Millisecond, Event: evt}, nil)
+	c.Assert(err, check.ErrorMatches, "1 of 4 units removed: unit removal canceled")
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 4)
+}
+
+func (s *S) TestScaleDownDefaults(c *check.C) {
+	c.Assert(ScaleDownDefaults(), check.DeepEquals, ScaleDownOptions{Interval: 30 * time.Second})
+	config.Set("units:scale-down:max-step", 10)
+	defer config.Unset("units:scale-down:max-step")
+	config.Set("units:scale-down:interval", "1m")
+	defer config.Unset("units:scale-down:interval")
+	opts := ScaleDownDefaults()
+	c.Assert(opts, check.DeepEquals, ScaleDownOptions{Step: 10, Interval: time.Minute})
+	c.Assert(opts.IsGradual(10), check.Equals, false)
+	c.Assert(opts.IsGradual(11), check.Equals, true)
+}
@@ -0,0 +1,135 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	TimelineDeploy     = "deploy"
+	TimelineEnv        = "env"
+	TimelineAnnotation = "annotation"
+	TimelineUnit       = "unit"
+	TimelineEvent      = "event"
+
+	timelineDefaultLimit = 50
+	timelineMaxLimit     = 100
+)
+
+// TimelineEntry is something that happened to an app. Entries built from
+// events carry the event ID, which can be used to get the full event.
+type TimelineEntry struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Kind      string    `json:"kind"`
+	Owner     string    `json:"owner,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Running   bool      `json:"running,omitempty"`
+	EventID   string    `json:"eventId,omitempty"`
+	Unit      string    `json:"unit,omitempty"`
+}
+
+// TimelineOpts paginates the timeline of an app. Only entries older than
+// Before are returned, when it's set, so the timestamp of the last entry of
+// a page can be used to get the next one.
+type TimelineOpts struct {
+	Before time.Time
+	Limit  int
+}
+
+// Timeline returns the deploys, environment changes, annotations, unit events
+// and other events of the app merged in a single feed, most recent first.
+// Unit events are only included when supported by the provisioner of the
+// app.
+func (app *App) Timeline(opts TimelineOpts) ([]TimelineEntry, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = timelineDefaultLimit
+	}
+	if opts.Limit > timelineMaxLimit {
+		opts.Limit = timelineMaxLimit
+	}
+	filter := &event.Filter{
+		Target: event.Target{Type: event.TargetTypeApp, Value: app.Name},
+		Limit:  opts.Limit,
+	}
+	if !opts.Before.IsZero() {
+		filter.Until = opts.Before
+		filter.Raw = bson.M{"starttime": bson.M{"$lt": opts.Before}}
+	}
+	evts, err := event.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]TimelineEntry, 0, len(evts))
+	for i := range evts {
+		entries = append(entries, eventToTimelineEntry(&evts[i]))
+	}
+	unitEvents, err := app.UnitsEvents(time.Time{})
+	if err != nil {
+		if _, ok := err.(provision.ProvisionerNotSupported); !ok {
+			return nil, err
+		}
+	}
+	for _, ue := range unitEvents {
+		if !opts.Before.IsZero() && !ue.LastSeen.Before(opts.Before) {
+			continue
+		}
+		entries = append(entries, TimelineEntry{
+			Type:      TimelineUnit,
+			Timestamp: ue.LastSeen,
+			Kind:      ue.Reason,
+			Message:   ue.Message,
+			Unit:      ue.Unit,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+	if len(entries) > opts.Limit {
+		entries = entries[:opts.Limit]
+	}
+	return entries, nil
+}
+
+func eventToTimelineEntry(evt *event.Event) TimelineEntry {
+	entry := TimelineEntry{
+		Type:      TimelineEvent,
+		Timestamp: evt.StartTime,
+		Kind:      evt.Kind.Name,
+		Owner:     evt.Owner.Name,
+		Error:     evt.Error,
+		Running:   evt.Running,
+		EventID:   evt.UniqueID.Hex(),
+	}
+	switch evt.Kind.Name {
+	case permission.PermAppDeploy.FullName():
+		entry.Type = TimelineDeploy
+		var opts DeployOptions
+		if evt.StartData(&opts) == nil {
+			entry.Message = opts.Message
+		}
+	case permission.PermAppUpdateEnvSet.FullName(), permission.PermAppUpdateEnvUnset.FullName():
+		entry.Type = TimelineEnv
+	case permission.PermAppUpdateAnnotate.FullName():
+		entry.Type = TimelineAnnotation
+		var form []map[string]interface{}
+		if evt.StartData(&form) == nil {
+			for _, field := range form {
+				if field["name"] == "message" {
+					entry.Message, _ = field["value"].(string)
+				}
+			}
+		}
+	}
+	return entry
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createTimelineEvents(c *check.C, appName string) {
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: appName},
+		Kind:       permission.PermAppDeploy,
+		RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:    event.Allowed(permission.PermApp),
+		CustomData: DeployOptions{Message: "fix login"},
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	evt, err = event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: appName},
+		Kind:       permission.PermAppUpdateAnnotate,
+		RawOwner:   event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:    event.Allowed(permission.PermApp),
+		CustomData: event.FormToCustomData(url.Values{"message": []string{"load test started"}}),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestTimeline(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.createTimelineEvents(c, a.Name)
+	now := time.Now().UTC()
+	s.provisioner.AddUnitEvent(a.Name, provision.UnitEvent{Unit: "u1", Reason: "BackOff", Message: "restarting", LastSeen: now.Add(time.Hour)})
+	s.provisioner.AddUnitEvent(a.Name, provision.UnitEvent{Unit: "u1", Reason: "Pulled", LastSeen: now.Add(-time.Hour)})
+	entries, err := a.Timeline(TimelineOpts{})
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 4)
+	c.Assert(entries[0].Type, check.Equals, TimelineUnit)
+	c.Assert(entries[0].Kind, check.Equals, "BackOff")
+	c.Assert(entries[0].Message, check.Equals, "restarting")
+	c.Assert(entries[0].Unit, check.Equals, "u1")
+	c.Assert(entries[3].Type, check.Equals, TimelineUnit)
+	c.Assert(entries[3].Kind, check.Equals, "Pulled")
+	byType := map[string]TimelineEntry{}
+	for _, e := range entries[1:3] {
+		byType[e.Type] = e
+	}
+	c.Assert(byType[TimelineDeploy].Message, check.Equals, "fix login")
+	c.Assert(byType[TimelineDeploy].Owner, check.Equals, s.user.Email)
+	c.Assert(byType[TimelineDeploy].EventID, check.Not(check.Equals), "")
+	c.Assert(byType[TimelineAnnotation].Message, check.Equals, "load test started")
+	c.Assert(byType[TimelineAnnotation].Kind, check.Equals, permission.PermAppUpdateAnnotate.FullName())
+}
+
+func (s *S) TestTimelinePagination(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.createTimelineEvents(c, a.Name)
+	now := time.Now().UTC()
+	s.provisioner.AddUnitEvent(a.Name, provision.UnitEvent{Unit: "u1", Reason: "BackOff", LastSeen: now.Add(time.Hour)})
+	s.provisioner.AddUnitEvent(a.Name, provision.UnitEvent{Unit: "u1", Reason: "Pulled", LastSeen: now.Add(-time.Hour)})
+	entries, err := a.Timeline(TimelineOpts{Limit: 1})
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].Kind, check.Equals, "BackOff")
+	entries, err = a.Timeline(TimelineOpts{Before: entries[0].Timestamp})
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 3)
+	entries, err = a.Timeline(TimelineOpts{Before: now.Add(-time.Minute)})
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].Kind, check.Equals, "Pulled")
+}
+
+func (s *S) TestTimelineEnvChanges(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:     permission.PermAppUpdateEnvSet,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	entries, err := a.Timeline(TimelineOpts{})
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].Type, check.Equals, TimelineEnv)
+	c.Assert(entries[0].Kind, check.Equals, permission.PermAppUpdateEnvSet.FullName())
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/log"
+)
+
+const (
+	defaultJWTHeader      = "X-Jwt-Assertion"
+	defaultJWTMaxLifetime = time.Hour
+)
+
+func init() {
+	RegisterTokenResolver("client-cert", TokenResolverFunc(clientCertResolver))
+	RegisterTokenResolver("jwt", TokenResolverFunc(jwtResolver))
+}
+
+// clientCertResolver authenticates the user identified by the email address,
+// or the common name, of the client certificate verified by the API server
+// TLS listener.
+func clientCertResolver(r *http.Request) (Token, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	email := cert.Subject.CommonName
+	if len(cert.EmailAddresses) > 0 {
+		email = cert.EmailAddresses[0]
+	}
+	return externalUserToken(email, "client-cert")
+}
+
+type jwtClaims struct {
+	Email string `json:"email"`
+	jwt.StandardClaims
+}
+
+// jwtMaxLifetime returns the maximum lifetime of the JWTs accepted by the jwt
+// token resolver, set in the auth:jwt:max-lifetime setting, in seconds.
+func jwtMaxLifetime() time.Duration {
+	seconds, err := config.GetInt("auth:jwt:max-lifetime")
+	if err != nil || seconds <= 0 {
+		return defaultJWTMaxLifetime
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// jwtResolver authenticates the user in the email claim of a JWT signed with
+// a shared secret, usually set by an internal gateway in front of the API.
+// Tokens must expire, and live no longer than jwtMaxLifetime, counting from
+// the iat claim or from now when it's not set.
+func jwtResolver(r *http.Request) (Token, error) {
+	header, _ := config.GetString("auth:jwt:header")
+	if header == "" {
+		header = defaultJWTHeader
+	}
+	value := r.Header.Get(header)
+	if value == "" {
+		return nil, nil
+	}
+	secret, err := config.GetString("auth:jwt:secret")
+	if err != nil || secret == "" {
+		return nil, errors.New("auth:jwt:secret is required by the jwt token resolver")
+	}
+	var claims jwtClaims
+	_, err = jwt.ParseWithClaims(value, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		log.Debugf("Ignored invalid JWT: %s", err)
+		return nil, ErrInvalidToken
+	}
+	if claims.ExpiresAt == 0 {
+		log.Debugf("Ignored JWT without expiration")
+		return nil, ErrInvalidToken
+	}
+	issuedAt := claims.IssuedAt
+	if issuedAt == 0 {
+		issuedAt = time.Now().Unix()
+	}
+	maxLifetime := jwtMaxLifetime()
+	if time.Duration(claims.ExpiresAt-issuedAt)*time.Second > maxLifetime {
+		log.Debugf("Ignored JWT with lifetime longer than %v", maxLifetime)
+		return nil, ErrInvalidToken
+	}
+	issuer, _ := config.GetString("auth:jwt:issuer")
+	if issuer != "" && !claims.VerifyIssuer(issuer, true) {
+		return nil, ErrInvalidToken
+	}
+	return externalUserToken(claims.Email, "jwt")
+}
@@ -0,0 +1,169 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultImpersonationMaxDuration = time.Hour
+
+var (
+	ErrImpersonateSelf        = errors.New("users cannot impersonate themselves")
+	ErrImpersonationChain     = errors.New("impersonation tokens cannot be used to impersonate other users")
+	ErrImpersonationDuration  = errors.New("invalid impersonation duration")
+	ErrImpersonationAppTokens = errors.New("app tokens cannot impersonate users")
+)
+
+// ImpersonatedToken is a token used by a user, the impersonator, to act as
+// another user. Actions done with it are attributed to both users.
+type ImpersonatedToken interface {
+	Token
+	GetImpersonator() string
+}
+
+// ImpersonationToken is a short lived token allowing ActorEmail to act as
+// UserEmail, with UserEmail's permissions.
+type ImpersonationToken struct {
+	Token      string        `json:"token" bson:"_id"`
+	UserEmail  string        `json:"email"`
+	ActorEmail string        `json:"actor"`
+	Creation   time.Time     `json:"creation"`
+	Expires    time.Duration `json:"expires"`
+}
+
+func (t *ImpersonationToken) GetValue() string {
+	return t.Token
+}
+
+func (t *ImpersonationToken) User() (*User, error) {
+	return GetUserByEmail(t.UserEmail)
+}
+
+func (t *ImpersonationToken) IsAppToken() bool {
+	return false
+}
+
+func (t *ImpersonationToken) GetUserName() string {
+	return t.UserEmail
+}
+
+func (t *ImpersonationToken) GetAppName() string {
+	return ""
+}
+
+func (t *ImpersonationToken) GetImpersonator() string {
+	return t.ActorEmail
+}
+
+func (t *ImpersonationToken) Permissions() ([]permission.Permission, error) {
+	return BaseTokenPermission(t)
+}
+
+// ImpersonationMaxDuration returns the maximum duration of an impersonation
+// token, set in the auth:impersonation:max-duration setting, in seconds.
+func ImpersonationMaxDuration() time.Duration {
+	seconds, err := config.GetInt("auth:impersonation:max-duration")
+	if err != nil || seconds <= 0 {
+		return defaultImpersonationMaxDuration
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// CreateImpersonationToken creates a token allowing the owner of actor to act
+// as user for the given duration. A zero duration uses the maximum allowed
+// duration.
+func CreateImpersonationToken(actor Token, user *User, duration time.Duration) (*ImpersonationToken, error) {
+	if actor.IsAppToken() {
+		return nil, ErrImpersonationAppTokens
+	}
+	if _, ok := actor.(ImpersonatedToken); ok {
+		return nil, ErrImpersonationChain
+	}
+	if actor.GetUserName() == user.Email {
+		return nil, ErrImpersonateSelf
+	}
+	maxDuration := ImpersonationMaxDuration()
+	if duration == 0 {
+		duration = maxDuration
+	}
+	if duration < 0 || duration > maxDuration {
+		return nil, ErrImpersonationDuration
+	}
+	randomBytes := make([]byte, 32)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+	h := crypto.SHA256.New()
+	h.Write([]byte(actor.GetUserName()))
+	h.Write([]byte(user.Email))
+	h.Write(randomBytes)
+	t := ImpersonationToken{
+		Token:      fmt.Sprintf("%x", h.Sum(nil)),
+		UserEmail:  user.Email,
+		ActorEmail: actor.GetUserName(),
+		Creation:   time.Now().UTC(),
+		Expires:    duration,
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.ImpersonationTokens().Insert(t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ImpersonationAuth returns the impersonation token in the given header,
+// ErrInvalidToken is returned if the token doesn't exist or is expired.
+func ImpersonationAuth(header string) (*ImpersonationToken, error) {
+	token, err := ParseToken(header)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var t ImpersonationToken
+	err = conn.ImpersonationTokens().FindId(token).One(&t)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if time.Until(t.Creation.Add(t.Expires)) < 1 {
+		return nil, ErrInvalidToken
+	}
+	return &t, nil
+}
+
+// RemoveImpersonationTokens revokes all impersonation tokens created by the
+// given actor.
+func RemoveImpersonationTokens(actorEmail string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ImpersonationTokens().RemoveAll(bson.M{"actoremail": actorEmail})
+	return err
+}
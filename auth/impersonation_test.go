@@ -0,0 +1,67 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCreateImpersonationToken(c *check.C) {
+	actor := &APIToken{Token: "abc", UserEmail: "support@tsuru.io"}
+	t, err := CreateImpersonationToken(actor, s.user, 10*time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(t.UserEmail, check.Equals, s.user.Email)
+	c.Assert(t.ActorEmail, check.Equals, "support@tsuru.io")
+	c.Assert(t.Expires, check.Equals, 10*time.Minute)
+	found, err := ImpersonationAuth("bearer " + t.Token)
+	c.Assert(err, check.IsNil)
+	c.Assert(found.GetUserName(), check.Equals, s.user.Email)
+	c.Assert(found.GetImpersonator(), check.Equals, "support@tsuru.io")
+	u, err := found.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Email, check.Equals, s.user.Email)
+}
+
+func (s *S) TestCreateImpersonationTokenDefaultDuration(c *check.C) {
+	config.Set("auth:impersonation:max-duration", 120)
+	defer config.Unset("auth:impersonation:max-duration")
+	actor := &APIToken{Token: "abc", UserEmail: "support@tsuru.io"}
+	t, err := CreateImpersonationToken(actor, s.user, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(t.Expires, check.Equals, 2*time.Minute)
+	_, err = CreateImpersonationToken(actor, s.user, 3*time.Minute)
+	c.Assert(err, check.Equals, ErrImpersonationDuration)
+}
+
+func (s *S) TestCreateImpersonationTokenInvalid(c *check.C) {
+	_, err := CreateImpersonationToken(&APIToken{UserEmail: s.user.Email}, s.user, time.Minute)
+	c.Assert(err, check.Equals, ErrImpersonateSelf)
+	_, err = CreateImpersonationToken(&ImpersonationToken{UserEmail: "other@tsuru.io", ActorEmail: "support@tsuru.io"}, s.user, time.Minute)
+	c.Assert(err, check.Equals, ErrImpersonationChain)
+}
+
+func (s *S) TestImpersonationAuthExpired(c *check.C) {
+	actor := &APIToken{Token: "abc", UserEmail: "support@tsuru.io"}
+	t, err := CreateImpersonationToken(actor, s.user, time.Millisecond)
+	c.Assert(err, check.IsNil)
+	time.Sleep(5 * time.Millisecond)
+	_, err = ImpersonationAuth("bearer " + t.Token)
+	c.Assert(err, check.Equals, ErrInvalidToken)
+	_, err = ImpersonationAuth("bearer unknown")
+	c.Assert(err, check.Equals, ErrInvalidToken)
+}
+
+func (s *S) TestRemoveImpersonationTokens(c *check.C) {
+	actor := &APIToken{Token: "abc", UserEmail: "support@tsuru.io"}
+	t, err := CreateImpersonationToken(actor, s.user, time.Minute)
+	c.Assert(err, check.IsNil)
+	err = RemoveImpersonationTokens("support@tsuru.io")
+	c.Assert(err, check.IsNil)
+	_, err = ImpersonationAuth("bearer " + t.Token)
+	c.Assert(err, check.Equals, ErrInvalidToken)
+}
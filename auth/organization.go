@@ -0,0 +1,426 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrInvalidOrganizationName     = errors.New("invalid organization name")
+	ErrOrganizationAlreadyExists   = errors.New("organization already exists")
+	ErrOrganizationNotFound        = errors.New("organization not found")
+	ErrOrganizationStillUsed       = errors.New("organization still has teams")
+	ErrTeamInAnotherOrganization   = errors.New("team already belongs to an organization")
+	ErrPoolInAnotherOrganization   = errors.New("pool already belongs to an organization")
+	ErrOrganizationMemberNotFound  = errors.New("organization member not found")
+	ErrOrganizationQuotaBelowInUse = errors.New("new limit is lesser than the current allocated value")
+)
+
+// Organization groups teams and pools, allowing a single tsuru installation
+// to serve multiple business units. Roles with the organization context are
+// valid for all teams and pools in the organization, and the quota limits
+// the number of apps owned by the teams in the organization.
+type Organization struct {
+	Name         string      `bson:"_id" json:"name"`
+	Teams        []string    `bson:",omitempty" json:"teams"`
+	Pools        []string    `bson:",omitempty" json:"pools"`
+	Quota        quota.Quota `json:"quota"`
+	CreatingUser string      `json:"creatingUser"`
+}
+
+// CreateOrganization creates a new organization, with unlimited quota.
+func CreateOrganization(name string, user *User) error {
+	if user == nil {
+		return errors.New("user cannot be null")
+	}
+	name = strings.TrimSpace(name)
+	if !teamNameRegexp.MatchString(name) {
+		return ErrInvalidOrganizationName
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Organizations().Insert(Organization{
+		Name:         name,
+		Quota:        quota.Unlimited,
+		CreatingUser: user.Email,
+	})
+	if mgo.IsDup(err) {
+		return ErrOrganizationAlreadyExists
+	}
+	return err
+}
+
+// GetOrganization finds an organization by name.
+func GetOrganization(name string) (*Organization, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var org Organization
+	err = conn.Organizations().FindId(name).One(&org)
+	if err == mgo.ErrNotFound {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// OrganizationForTeam returns the organization the team belongs to, or
+// ErrOrganizationNotFound if the team isn't part of any organization.
+func OrganizationForTeam(teamName string) (*Organization, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var org Organization
+	err = conn.Organizations().Find(bson.M{"teams": teamName}).One(&org)
+	if err == mgo.ErrNotFound {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// ListOrganizations returns the organizations with the given names, or all
+// organizations if names is nil.
+func ListOrganizations(names []string) ([]Organization, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var query bson.M
+	if names != nil {
+		query = bson.M{"_id": bson.M{"$in": names}}
+	}
+	var orgs []Organization
+	err = conn.Organizations().Find(query).Sort("_id").All(&orgs)
+	if err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// RemoveOrganization removes an organization without teams.
+func RemoveOrganization(name string) error {
+	org, err := GetOrganization(name)
+	if err != nil {
+		return err
+	}
+	if len(org.Teams) > 0 {
+		return ErrOrganizationStillUsed
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Organizations().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrOrganizationNotFound
+	}
+	return err
+}
+
+// AddTeam adds an existing team to the organization. A team may belong to
+// only one organization. The apps owned by the team are added to the quota
+// of the organization in the same update, and the team is refused when they
+// exceed it.
+func (o *Organization) AddTeam(teamName string) error {
+	_, err := GetTeam(teamName)
+	if err != nil {
+		return err
+	}
+	apps, err := countTeamOwnerApps(teamName)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for {
+		org, err := GetOrganization(o.Name)
+		if err != nil {
+			return err
+		}
+		if org.hasMember(org.Teams, teamName) {
+			*o = *org
+			return nil
+		}
+		if !org.Quota.Unlimited() && org.Quota.InUse+apps > org.Quota.Limit {
+			return &quota.QuotaExceededError{
+				Available: uint(org.Quota.Limit - org.Quota.InUse),
+				Requested: uint(apps),
+			}
+		}
+		// The update only matches while the quota is unchanged, so apps
+		// reserved concurrently are not lost.
+		_, err = conn.Organizations().Find(bson.M{"_id": o.Name, "quota.inuse": org.Quota.InUse}).Apply(mgo.Change{
+			Update: bson.M{
+				"$addToSet": bson.M{"teams": teamName},
+				"$inc":      bson.M{"quota.inuse": apps},
+			},
+			ReturnNew: true,
+		}, o)
+		if mgo.IsDup(err) {
+			return ErrTeamInAnotherOrganization
+		}
+		if err != mgo.ErrNotFound {
+			return err
+		}
+	}
+}
+
+// RemoveTeam removes the team from the organization, releasing the apps
+// owned by the team from the quota of the organization.
+func (o *Organization) RemoveTeam(teamName string) error {
+	if !o.hasMember(o.Teams, teamName) {
+		return ErrOrganizationMemberNotFound
+	}
+	apps, err := countTeamOwnerApps(teamName)
+	if err != nil {
+		return err
+	}
+	org, err := GetOrganization(o.Name)
+	if err != nil {
+		return err
+	}
+	if apps > org.Quota.InUse {
+		apps = org.Quota.InUse
+	}
+	return o.removeMember("teams", teamName, bson.M{"$inc": bson.M{"quota.inuse": -apps}})
+}
+
+func countTeamOwnerApps(teamName string) (int, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	return conn.Apps().Find(bson.M{"teamowner": teamName}).Count()
+}
+
+// AddPool adds a pool to the organization. A pool may belong to only one
+// organization. The caller is responsible for checking whether the pool
+// exists.
+func (o *Organization) AddPool(poolName string) error {
+	err := o.update(bson.M{"$addToSet": bson.M{"pools": poolName}})
+	if mgo.IsDup(err) {
+		return ErrPoolInAnotherOrganization
+	}
+	return err
+}
+
+// RemovePool removes the pool from the organization.
+func (o *Organization) RemovePool(poolName string) error {
+	if !o.hasMember(o.Pools, poolName) {
+		return ErrOrganizationMemberNotFound
+	}
+	return o.removeMember("pools", poolName, nil)
+}
+
+// ChangeQuota changes the limit of apps owned by teams in the organization.
+// A negative limit means unlimited.
+func (o *Organization) ChangeQuota(limit int) error {
+	if limit < 0 {
+		limit = -1
+	} else if limit < o.Quota.InUse {
+		return ErrOrganizationQuotaBelowInUse
+	}
+	return o.update(bson.M{"$set": bson.M{"quota.limit": limit}})
+}
+
+func (o *Organization) hasMember(members []string, name string) bool {
+	for _, m := range members {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// removeMember removes the name from the teams or pools of the organization.
+// Removing the last member unsets the field instead of leaving an empty array,
+// as MongoDB indexes empty arrays even in sparse indexes, and the unique
+// indexes on teams and pools would allow only one organization without them.
+// The extra update is applied along with the removal.
+func (o *Organization) removeMember(field, name string, extra bson.M) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.Organizations()
+	var org Organization
+	update := func(op string, value interface{}) bson.M {
+		change := bson.M{op: bson.M{field: value}}
+		for k, v := range extra {
+			change[k] = v
+		}
+		return change
+	}
+	_, err = coll.Find(bson.M{"_id": o.Name, field: []string{name}}).Apply(mgo.Change{
+		Update:    update("$unset", ""),
+		ReturnNew: true,
+	}, &org)
+	if err == mgo.ErrNotFound {
+		_, err = coll.Find(bson.M{"_id": o.Name, field: name}).Apply(mgo.Change{
+			Update:    update("$pull", name),
+			ReturnNew: true,
+		}, &org)
+	}
+	if err == mgo.ErrNotFound {
+		if _, getErr := GetOrganization(o.Name); getErr != nil {
+			return getErr
+		}
+		return ErrOrganizationMemberNotFound
+	}
+	if err != nil {
+		return err
+	}
+	*o = org
+	return nil
+}
+
+func (o *Organization) update(change bson.M) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Organizations().FindId(o.Name).Apply(mgo.Change{
+		Update:    change,
+		ReturnNew: true,
+	}, o)
+	if err == mgo.ErrNotFound {
+		return ErrOrganizationNotFound
+	}
+	return err
+}
+
+// CheckOrganizationAppQuota returns the error ReserveOrganizationApp would
+// return for the given team, without reserving anything.
+func CheckOrganizationAppQuota(teamName string) error {
+	org, err := OrganizationForTeam(teamName)
+	if err == ErrOrganizationNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !org.Quota.Unlimited() && org.Quota.InUse+1 > org.Quota.Limit {
+		return &quota.QuotaExceededError{
+			Available: uint(org.Quota.Limit - org.Quota.InUse),
+			Requested: 1,
+		}
+	}
+	return nil
+}
+
+// ReserveOrganizationApp reserves an app in the quota of the organization of
+// the given team. It's a no-op when the team isn't part of an organization.
+func ReserveOrganizationApp(teamName string) error {
+	return changeOrganizationAppsInUse(teamName, 1)
+}
+
+// ReleaseOrganizationApp releases an app from the quota of the organization
+// of the given team. It's a no-op when the team isn't part of an
+// organization.
+func ReleaseOrganizationApp(teamName string) error {
+	return changeOrganizationAppsInUse(teamName, -1)
+}
+
+func changeOrganizationAppsInUse(teamName string, delta int) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for {
+		org, err := OrganizationForTeam(teamName)
+		if err == ErrOrganizationNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if delta > 0 && !org.Quota.Unlimited() && org.Quota.InUse+delta > org.Quota.Limit {
+			return &quota.QuotaExceededError{
+				Available: uint(org.Quota.Limit - org.Quota.InUse),
+				Requested: uint(delta),
+			}
+		}
+		if delta < 0 && org.Quota.InUse+delta < 0 {
+			return errors.New("Cannot release unreserved app")
+		}
+		err = conn.Organizations().Update(
+			bson.M{"_id": org.Name, "quota.inuse": org.Quota.InUse},
+			bson.M{"$inc": bson.M{"quota.inuse": delta}},
+		)
+		if err != mgo.ErrNotFound {
+			return err
+		}
+	}
+}
+
+// expandOrganizationPermissions adds, for each permission in the context of
+// an organization, the same permission in the context of each team and pool
+// of the organization, as long as the permission allows these context types.
+func expandOrganizationPermissions(perms []permission.Permission) ([]permission.Permission, error) {
+	orgs := map[string]*Organization{}
+	result := perms
+	for _, perm := range perms {
+		if perm.Context.CtxType != permission.CtxOrganization {
+			continue
+		}
+		org, ok := orgs[perm.Context.Value]
+		if !ok {
+			var err error
+			org, err = GetOrganization(perm.Context.Value)
+			if err != nil && err != ErrOrganizationNotFound {
+				return nil, err
+			}
+			orgs[perm.Context.Value] = org
+		}
+		if org == nil {
+			continue
+		}
+		for _, ctxType := range perm.Scheme.AllowedContexts() {
+			var values []string
+			switch ctxType {
+			case permission.CtxTeam:
+				values = org.Teams
+			case permission.CtxPool:
+				values = org.Pools
+			}
+			for _, v := range values {
+				result = append(result, permission.Permission{
+					Scheme:  perm.Scheme,
+					Context: permission.Context(ctxType, v),
+				})
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestCreateOrganization(c *check.C) {
+	err := CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	org, err := GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Name, check.Equals, "acme")
+	c.Assert(org.Quota, check.DeepEquals, quota.Unlimited)
+	c.Assert(org.CreatingUser, check.Equals, s.user.Email)
+	err = CreateOrganization("acme", s.user)
+	c.Assert(err, check.Equals, ErrOrganizationAlreadyExists)
+	err = CreateOrganization("-invalid", s.user)
+	c.Assert(err, check.Equals, ErrInvalidOrganizationName)
+}
+
+func (s *S) TestGetOrganizationNotFound(c *check.C) {
+	_, err := GetOrganization("acme")
+	c.Assert(err, check.Equals, ErrOrganizationNotFound)
+}
+
+func (s *S) TestOrganizationAddTeam(c *check.C) {
+	err := CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	err = CreateOrganization("other", s.user)
+	c.Assert(err, check.IsNil)
+	org, err := GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	err = org.AddTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Teams, check.DeepEquals, []string{s.team.Name})
+	err = org.AddTeam("unknown")
+	c.Assert(err, check.Equals, ErrTeamNotFound)
+	other, err := GetOrganization("other")
+	c.Assert(err, check.IsNil)
+	err = other.AddTeam(s.team.Name)
+	c.Assert(err, check.Equals, ErrTeamInAnotherOrganization)
+	found, err := OrganizationForTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(found.Name, check.Equals, "acme")
+	err = RemoveOrganization("acme")
+	c.Assert(err, check.Equals, ErrOrganizationStillUsed)
+	err = org.RemoveTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	err = org.RemoveTeam(s.team.Name)
+	c.Assert(err, check.Equals, ErrOrganizationMemberNotFound)
+	err = RemoveOrganization("acme")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestOrganizationAddPool(c *check.C) {
+	err := CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	err = CreateOrganization("other", s.user)
+	c.Assert(err, check.IsNil)
+	org, err := GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	err = org.AddPool("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Pools, check.DeepEquals, []string{"pool1"})
+	other, err := GetOrganization("other")
+	c.Assert(err, check.IsNil)
+	err = other.AddPool("pool1")
+	c.Assert(err, check.Equals, ErrPoolInAnotherOrganization)
+	err = org.RemovePool("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Pools, check.HasLen, 0)
+}
+
+func (s *S) TestOrganizationRemoveLastMembers(c *check.C) {
+	for _, name := range []string{"acme", "other"} {
+		err := CreateOrganization(name, s.user)
+		c.Assert(err, check.IsNil)
+		org, err := GetOrganization(name)
+		c.Assert(err, check.IsNil)
+		err = org.AddPool(name + "-pool1")
+		c.Assert(err, check.IsNil)
+		err = org.AddPool(name + "-pool2")
+		c.Assert(err, check.IsNil)
+		err = org.RemovePool(name + "-pool1")
+		c.Assert(err, check.IsNil)
+		c.Assert(org.Pools, check.DeepEquals, []string{name + "-pool2"})
+		err = org.RemovePool(name + "-pool2")
+		c.Assert(err, check.IsNil)
+		c.Assert(org.Pools, check.HasLen, 0)
+	}
+	other, err := GetOrganization("other")
+	c.Assert(err, check.IsNil)
+	err = other.AddTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	err = other.RemoveTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(other.Teams, check.HasLen, 0)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	n, err := conn.Organizations().Find(bson.M{"$or": []bson.M{
+		{"teams": bson.M{"$exists": true}},
+		{"pools": bson.M{"$exists": true}},
+	}}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
+
+func (s *S) TestListOrganizations(c *check.C) {
+	for _, name := range []string{"b-org", "a-org", "c-org"} {
+		err := CreateOrganization(name, s.user)
+		c.Assert(err, check.IsNil)
+	}
+	orgs, err := ListOrganizations(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(orgs, check.HasLen, 3)
+	c.Assert(orgs[0].Name, check.Equals, "a-org")
+	orgs, err = ListOrganizations([]string{"c-org", "b-org"})
+	c.Assert(err, check.IsNil)
+	c.Assert(orgs, check.HasLen, 2)
+	c.Assert(orgs[0].Name, check.Equals, "b-org")
+	c.Assert(orgs[1].Name, check.Equals, "c-org")
+}
+
+func (s *S) TestOrganizationAppQuota(c *check.C) {
+	err := CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	org, err := GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	err = org.AddTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	err = org.ChangeQuota(1)
+	c.Assert(err, check.IsNil)
+	err = ReserveOrganizationApp(s.team.Name)
+	c.Assert(err, check.IsNil)
+	err = ReserveOrganizationApp(s.team.Name)
+	c.Assert(err, check.DeepEquals, &quota.QuotaExceededError{Available: 0, Requested: 1})
+	org, err = GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Quota.InUse, check.Equals, 1)
+	err = org.ChangeQuota(0)
+	c.Assert(err, check.Equals, ErrOrganizationQuotaBelowInUse)
+	err = ReleaseOrganizationApp(s.team.Name)
+	c.Assert(err, check.IsNil)
+	org, err = GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Quota.InUse, check.Equals, 0)
+	err = ReserveOrganizationApp("team-without-org")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestOrganizationAddAndRemoveTeamUpdatesQuota(c *check.C) {
+	err := s.conn.Apps().Insert(
+		bson.M{"name": "app1", "teamowner": s.team.Name},
+		bson.M{"name": "app2", "teamowner": s.team.Name},
+	)
+	c.Assert(err, check.IsNil)
+	err = CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	org, err := GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	err = org.ChangeQuota(1)
+	c.Assert(err, check.IsNil)
+	err = org.AddTeam(s.team.Name)
+	c.Assert(err, check.DeepEquals, &quota.QuotaExceededError{Available: 1, Requested: 2})
+	org, err = GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Teams, check.HasLen, 0)
+	c.Assert(org.Quota.InUse, check.Equals, 0)
+	err = org.ChangeQuota(3)
+	c.Assert(err, check.IsNil)
+	err = org.AddTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Teams, check.DeepEquals, []string{s.team.Name})
+	c.Assert(org.Quota.InUse, check.Equals, 2)
+	err = org.AddTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Quota.InUse, check.Equals, 2)
+	err = org.RemoveTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(org.Teams, check.HasLen, 0)
+	c.Assert(org.Quota.InUse, check.Equals, 0)
+}
+
+func (s *S) TestUserPermissionsExpandOrganization(c *check.C) {
+	err := CreateOrganization("acme", s.user)
+	c.Assert(err, check.IsNil)
+	org, err := GetOrganization("acme")
+	c.Assert(err, check.IsNil)
+	err = org.AddTeam(s.team.Name)
+	c.Assert(err, check.IsNil)
+	err = org.AddPool("pool1")
+	c.Assert(err, check.IsNil)
+	r, err := permission.NewRole("org-admin", "organization", "")
+	c.Assert(err, check.IsNil)
+	err = r.AddPermissions("app.deploy", "pool.read", "organization.read")
+	c.Assert(err, check.IsNil)
+	u := &User{Email: "org@user.com", Password: "123456"}
+	err = u.Create()
+	c.Assert(err, check.IsNil)
+	err = u.AddRole("org-admin", "acme")
+	c.Assert(err, check.IsNil)
+	c.Assert(permission.Check(u, permission.PermAppDeploy, permission.Context(permission.CtxTeam, s.team.Name)), check.Equals, true)
+	c.Assert(permission.Check(u, permission.PermAppDeploy, permission.Context(permission.CtxTeam, "other")), check.Equals, false)
+	c.Assert(permission.Check(u, permission.PermPoolRead, permission.Context(permission.CtxPool, "pool1")), check.Equals, true)
+	c.Assert(permission.Check(u, permission.PermOrganizationRead, permission.Context(permission.CtxOrganization, "acme")), check.Equals, true)
+	c.Assert(permission.Check(u, permission.PermOrganizationRead, permission.Context(permission.CtxTeam, s.team.Name)), check.Equals, false)
+}
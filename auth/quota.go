@@ -42,6 +42,13 @@ func ReserveApp(user *User) error {
 	return err
 }
 
+// CheckAppQuota returns the error ReserveApp would return for the user,
+// without reserving anything.
+func CheckAppQuota(user *User) error {
+	_, err := checkUser(user.Email)
+	return err
+}
+
 func checkUser(email string) (*User, error) {
 	user, err := GetUserByEmail(email)
 	if err != nil {
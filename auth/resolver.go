@@ -0,0 +1,106 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/validation"
+)
+
+const defaultTokenResolver = "authorization"
+
+var tokenResolvers = map[string]TokenResolver{}
+
+// TokenResolver resolves the token authenticating an API request. Resolvers
+// return a nil token and a nil error when the request has no credentials
+// handled by them, so the next resolver in the chain is tried, and
+// ErrInvalidToken when the credentials are not valid.
+type TokenResolver interface {
+	ResolveToken(r *http.Request) (Token, error)
+}
+
+// TokenResolverFunc is a function implementing TokenResolver.
+type TokenResolverFunc func(r *http.Request) (Token, error)
+
+func (f TokenResolverFunc) ResolveToken(r *http.Request) (Token, error) {
+	return f(r)
+}
+
+// RegisterTokenResolver registers a new token resolver, that can be later
+// added to the chain in the auth:token-resolvers setting.
+func RegisterTokenResolver(name string, resolver TokenResolver) {
+	tokenResolvers[name] = resolver
+}
+
+// TokenResolverChain returns the resolvers set in the auth:token-resolvers
+// setting, in order. The default chain has only the "authorization" resolver,
+// which handles the tokens sent in the Authorization header.
+func TokenResolverChain() ([]TokenResolver, error) {
+	names, err := config.GetList("auth:token-resolvers")
+	if err != nil || len(names) == 0 {
+		names = []string{defaultTokenResolver}
+	}
+	chain := make([]TokenResolver, len(names))
+	for i, name := range names {
+		resolver, ok := tokenResolvers[name]
+		if !ok {
+			return nil, errors.Errorf("unknown token resolver %q", name)
+		}
+		chain[i] = resolver
+	}
+	return chain, nil
+}
+
+// ExternalUserToken is the token of a user authenticated by an external
+// scheme, like a client certificate or a header set by a trusted gateway. It
+// has no value and is valid only in the request it was resolved for.
+type ExternalUserToken struct {
+	UserEmail string
+	Scheme    string
+}
+
+func (t *ExternalUserToken) GetValue() string {
+	return ""
+}
+
+func (t *ExternalUserToken) User() (*User, error) {
+	return GetUserByEmail(t.UserEmail)
+}
+
+func (t *ExternalUserToken) IsAppToken() bool {
+	return false
+}
+
+func (t *ExternalUserToken) GetUserName() string {
+	return t.UserEmail
+}
+
+func (t *ExternalUserToken) GetAppName() string {
+	return ""
+}
+
+func (t *ExternalUserToken) Permissions() ([]permission.Permission, error) {
+	return BaseTokenPermission(t)
+}
+
+// externalUserToken returns the token of the user authenticated by the given
+// scheme. Unknown users are rejected, external schemes never create users.
+func externalUserToken(email, scheme string) (Token, error) {
+	if !validation.ValidateEmail(email) {
+		return nil, ErrInvalidToken
+	}
+	_, err := GetUserByEmail(email)
+	if err == ErrUserNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalUserToken{UserEmail: email, Scheme: scheme}, nil
+}
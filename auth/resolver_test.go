@@ -0,0 +1,153 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestTokenResolverChain(c *check.C) {
+	first := TokenResolverFunc(func(r *http.Request) (Token, error) { return nil, nil })
+	RegisterTokenResolver("authorization", first)
+	defer delete(tokenResolvers, "authorization")
+	chain, err := TokenResolverChain()
+	c.Assert(err, check.IsNil)
+	c.Assert(chain, check.HasLen, 1)
+	config.Set("auth:token-resolvers", []interface{}{"jwt", "authorization"})
+	defer config.Unset("auth:token-resolvers")
+	chain, err = TokenResolverChain()
+	c.Assert(err, check.IsNil)
+	c.Assert(chain, check.HasLen, 2)
+}
+
+func (s *S) TestTokenResolverChainUnknownResolver(c *check.C) {
+	config.Set("auth:token-resolvers", []interface{}{"jwt", "kerberos"})
+	defer config.Unset("auth:token-resolvers")
+	_, err := TokenResolverChain()
+	c.Assert(err, check.ErrorMatches, `unknown token resolver "kerberos"`)
+}
+
+func (s *S) TestClientCertResolver(c *check.C) {
+	r, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	t, err := clientCertResolver(r)
+	c.Assert(err, check.IsNil)
+	c.Assert(t, check.IsNil)
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}, EmailAddresses: []string{s.user.Email}}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	t, err = clientCertResolver(r)
+	c.Assert(err, check.IsNil)
+	c.Assert(t, check.DeepEquals, &ExternalUserToken{UserEmail: s.user.Email, Scheme: "client-cert"})
+	perms, err := t.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(perms, check.NotNil)
+}
+
+func (s *S) TestClientCertResolverUnknownUser(c *check.C) {
+	r, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "nobody@tsuru.io"}}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	_, err = clientCertResolver(r)
+	c.Assert(err, check.Equals, ErrInvalidToken)
+}
+
+func (s *S) signedJWT(c *check.C, claims jwtClaims, secret string) string {
+	value, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	c.Assert(err, check.IsNil)
+	return value
+}
+
+func (s *S) TestJWTResolver(c *check.C) {
+	config.Set("auth:jwt:secret", "gateway-secret")
+	defer config.Unset("auth:jwt:secret")
+	config.Set("auth:jwt:issuer", "gateway")
+	defer config.Unset("auth:jwt:issuer")
+	r, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	t, err := jwtResolver(r)
+	c.Assert(err, check.IsNil)
+	c.Assert(t, check.IsNil)
+	claims := jwtClaims{Email: s.user.Email}
+	claims.Issuer = "gateway"
+	claims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+	r.Header.Set("X-Jwt-Assertion", s.signedJWT(c, claims, "gateway-secret"))
+	t, err = jwtResolver(r)
+	c.Assert(err, check.IsNil)
+	c.Assert(t, check.DeepEquals, &ExternalUserToken{UserEmail: s.user.Email, Scheme: "jwt"})
+}
+
+func (s *S) TestJWTResolverInvalidTokens(c *check.C) {
+	config.Set("auth:jwt:secret", "gateway-secret")
+	defer config.Unset("auth:jwt:secret")
+	config.Set("auth:jwt:issuer", "gateway")
+	defer config.Unset("auth:jwt:issuer")
+	config.Set("auth:jwt:header", "X-Gateway-Token")
+	defer config.Unset("auth:jwt:header")
+	valid := jwtClaims{Email: s.user.Email}
+	valid.Issuer = "gateway"
+	noExpiration := valid
+	valid.ExpiresAt = time.Now().Add(time.Minute).Unix()
+	expired := valid
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	otherIssuer := valid
+	otherIssuer.Issuer = "someone"
+	unknownUser := valid
+	unknownUser.Email = "nobody@tsuru.io"
+	tooLong := valid
+	tooLong.IssuedAt = time.Now().Unix()
+	tooLong.ExpiresAt = time.Now().Add(2 * time.Hour).Unix()
+	tooLongWithoutIssuedAt := valid
+	tooLongWithoutIssuedAt.ExpiresAt = time.Now().Add(2 * time.Hour).Unix()
+	tokens := []string{
+		"not-a-jwt",
+		s.signedJWT(c, valid, "wrong-secret"),
+		s.signedJWT(c, expired, "gateway-secret"),
+		s.signedJWT(c, otherIssuer, "gateway-secret"),
+		s.signedJWT(c, unknownUser, "gateway-secret"),
+		s.signedJWT(c, noExpiration, "gateway-secret"),
+		s.signedJWT(c, tooLong, "gateway-secret"),
+		s.signedJWT(c, tooLongWithoutIssuedAt, "gateway-secret"),
+	}
+	for _, value := range tokens {
+		r, err := http.NewRequest("GET", "/", nil)
+		c.Assert(err, check.IsNil)
+		r.Header.Set("X-Gateway-Token", value)
+		_, err = jwtResolver(r)
+		c.Check(err, check.Equals, ErrInvalidToken, check.Commentf(value))
+	}
+}
+
+func (s *S) TestJWTResolverMaxLifetime(c *check.C) {
+	config.Set("auth:jwt:secret", "gateway-secret")
+	defer config.Unset("auth:jwt:secret")
+	config.Set("auth:jwt:max-lifetime", 3*3600)
+	defer config.Unset("auth:jwt:max-lifetime")
+	claims := jwtClaims{Email: s.user.Email}
+	claims.IssuedAt = time.Now().Unix()
+	claims.ExpiresAt = time.Now().Add(2 * time.Hour).Unix()
+	r, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	r.Header.Set("X-Jwt-Assertion", s.signedJWT(c, claims, "gateway-secret"))
+	t, err := jwtResolver(r)
+	c.Assert(err, check.IsNil)
+	c.Assert(t, check.DeepEquals, &ExternalUserToken{UserEmail: s.user.Email, Scheme: "jwt"})
+}
+
+func (s *S) TestJWTResolverWithoutSecret(c *check.C) {
+	r, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	r.Header.Set("X-Jwt-Assertion", "some.jwt.value")
+	_, err = jwtResolver(r)
+	c.Assert(err, check.ErrorMatches, `auth:jwt:secret is required by the jwt token resolver`)
+}
@@ -0,0 +1,140 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/validation"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type DigestFrequency string
+
+const (
+	DigestNever  = DigestFrequency("never")
+	DigestDaily  = DigestFrequency("daily")
+	DigestWeekly = DigestFrequency("weekly")
+)
+
+type NotificationChannelType string
+
+const (
+	NotificationEmail   = NotificationChannelType("email")
+	NotificationWebhook = NotificationChannelType("webhook")
+)
+
+// NotificationChannel is a destination where notifications to the user are
+// sent. Target is an email address for email channels and an URL for webhook
+// channels.
+type NotificationChannel struct {
+	Type   NotificationChannelType `json:"type"`
+	Target string                  `json:"target"`
+}
+
+// UserSettings holds the preferences of a user, consulted by the subsystems
+// acting on their behalf.
+type UserSettings struct {
+	NotificationChannels []NotificationChannel `json:"notificationChannels" bson:",omitempty"`
+	// DefaultTeam is used as the team owner of apps created by the user
+	// without an explicit team.
+	DefaultTeam string `json:"defaultTeam"`
+	// TimeZone is the IANA name of the time zone used to interpret the
+	// schedules set by the user, UTC when empty.
+	TimeZone    string          `json:"timeZone"`
+	EventDigest DigestFrequency `json:"eventDigest"`
+}
+
+// Location returns the time zone set in the settings.
+func (s *UserSettings) Location() *time.Location {
+	if s.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Digest returns the event digest frequency, DigestNever when it's not set.
+func (s *UserSettings) Digest() DigestFrequency {
+	if s.EventDigest == "" {
+		return DigestNever
+	}
+	return s.EventDigest
+}
+
+func (s *UserSettings) validate() error {
+	for _, ch := range s.NotificationChannels {
+		switch ch.Type {
+		case NotificationEmail:
+			if !validation.ValidateEmail(ch.Target) {
+				return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid email for notification channel: %q", ch.Target)}
+			}
+		case NotificationWebhook:
+			u, err := url.Parse(ch.Target)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid url for notification channel: %q", ch.Target)}
+			}
+		default:
+			return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid notification channel type: %q", ch.Type)}
+		}
+	}
+	if s.TimeZone != "" {
+		if _, err := time.LoadLocation(s.TimeZone); err != nil {
+			return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid time zone: %q", s.TimeZone)}
+		}
+	}
+	switch s.EventDigest {
+	case "", DigestNever, DigestDaily, DigestWeekly:
+	default:
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid event digest frequency: %q", s.EventDigest)}
+	}
+	if s.DefaultTeam != "" {
+		_, err := GetTeam(s.DefaultTeam)
+		if err == ErrTeamNotFound {
+			return &tsuruErrors.ValidationError{Message: err.Error()}
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateSettings validates and stores the settings of the user.
+func (u *User) UpdateSettings(s UserSettings) error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Users().Update(bson.M{"email": u.Email}, bson.M{"$set": bson.M{"settings": s}})
+	if err != nil {
+		return errors.Wrapf(err, "unable to update settings for %q", u.Email)
+	}
+	u.Settings = s
+	return nil
+}
+
+// ListUsersWithDigest returns the users that asked for event digests with
+// the given frequency.
+func ListUsersWithDigest(freq DigestFrequency) ([]User, error) {
+	if freq == DigestNever {
+		return listUsers(bson.M{"$or": []bson.M{
+			{"settings.eventdigest": bson.M{"$in": []DigestFrequency{"", DigestNever}}},
+			{"settings.eventdigest": bson.M{"$exists": false}},
+		}})
+	}
+	return listUsers(bson.M{"settings.eventdigest": freq})
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestUserUpdateSettings(c *check.C) {
+	settings := UserSettings{
+		NotificationChannels: []NotificationChannel{
+			{Type: NotificationEmail, Target: "ops@example.com"},
+			{Type: NotificationWebhook, Target: "https://hooks.example.com/tsuru"},
+		},
+		DefaultTeam: s.team.Name,
+		TimeZone:    "America/Sao_Paulo",
+		EventDigest: DigestDaily,
+	}
+	err := s.user.UpdateSettings(settings)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.user.Settings, check.DeepEquals, settings)
+	u, err := GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Settings, check.DeepEquals, settings)
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Settings.Location(), check.DeepEquals, loc)
+}
+
+func (s *S) TestUserUpdateSettingsInvalid(c *check.C) {
+	tests := []struct {
+		settings UserSettings
+		msg      string
+	}{
+		{UserSettings{TimeZone: "Mars/Olympus_Mons"}, `invalid time zone: "Mars/Olympus_Mons"`},
+		{UserSettings{EventDigest: "hourly"}, `invalid event digest frequency: "hourly"`},
+		{UserSettings{DefaultTeam: "unknown"}, "team not found"},
+		{UserSettings{NotificationChannels: []NotificationChannel{{Type: "sms", Target: "555"}}}, `invalid notification channel type: "sms"`},
+		{UserSettings{NotificationChannels: []NotificationChannel{{Type: NotificationEmail, Target: "ops"}}}, `invalid email for notification channel: "ops"`},
+		{UserSettings{NotificationChannels: []NotificationChannel{{Type: NotificationWebhook, Target: "ftp://x"}}}, `invalid url for notification channel: "ftp://x"`},
+	}
+	for _, tt := range tests {
+		err := s.user.UpdateSettings(tt.settings)
+		c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+		c.Assert(err.Error(), check.Equals, tt.msg)
+	}
+	u, err := GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Settings, check.DeepEquals, UserSettings{})
+}
+
+func (s *S) TestUserSettingsDefaults(c *check.C) {
+	var settings UserSettings
+	c.Assert(settings.Location(), check.Equals, time.UTC)
+	c.Assert(settings.Digest(), check.Equals, DigestNever)
+}
+
+func (s *S) TestListUsersWithDigest(c *check.C) {
+	u := User{Email: "digest@example.com", Password: "123456"}
+	err := u.Create()
+	c.Assert(err, check.IsNil)
+	defer u.Delete()
+	err = u.UpdateSettings(UserSettings{EventDigest: DigestWeekly})
+	c.Assert(err, check.IsNil)
+	users, err := ListUsersWithDigest(DigestWeekly)
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.HasLen, 1)
+	c.Assert(users[0].Email, check.Equals, u.Email)
+	users, err = ListUsersWithDigest(DigestNever)
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.HasLen, 1)
+	c.Assert(users[0].Email, check.Equals, s.user.Email)
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/tag"
+)
+
+// expandTagPermissions adds, for each permission in the context of a tag, the
+// same permission in the context of each app, pool and service instance with
+// the tag, as long as the permission allows these context types.
+func expandTagPermissions(perms []permission.Permission) ([]permission.Permission, error) {
+	names := map[string][]string{}
+	result := perms
+	for _, perm := range perms {
+		if perm.Context.CtxType != permission.CtxTag {
+			continue
+		}
+		for _, ctxType := range perm.Scheme.AllowedContexts() {
+			var kind string
+			switch ctxType {
+			case permission.CtxApp:
+				kind = tag.KindApp
+			case permission.CtxPool:
+				kind = tag.KindPool
+			case permission.CtxServiceInstance:
+				kind = tag.KindServiceInstance
+			default:
+				continue
+			}
+			key := kind + "\x00" + perm.Context.Value
+			values, ok := names[key]
+			if !ok {
+				var err error
+				values, err = tag.Names(kind, perm.Context.Value)
+				if err != nil {
+					return nil, err
+				}
+				names[key] = values
+			}
+			for _, v := range values {
+				result = append(result, permission.Permission{
+					Scheme:  perm.Scheme,
+					Context: permission.Context(ctxType, v),
+				})
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestUserPermissionsExpandTag(c *check.C) {
+	err := s.conn.Apps().Insert(bson.M{"name": "euapp", "tags": []string{"region=eu"}}, bson.M{"name": "usapp"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Pools().Insert(bson.M{"_id": "eupool", "tags": []string{"region=eu"}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Insert(bson.M{"name": "db1", "service_name": "mysql", "tags": []string{"region=eu"}})
+	c.Assert(err, check.IsNil)
+	r, err := permission.NewRole("eu-operator", "tag", "")
+	c.Assert(err, check.IsNil)
+	err = r.AddPermissions("app.deploy", "pool.read", "service-instance.read")
+	c.Assert(err, check.IsNil)
+	u := &User{Email: "eu@user.com", Password: "123456"}
+	err = u.Create()
+	c.Assert(err, check.IsNil)
+	err = u.AddRole("eu-operator", "region=eu")
+	c.Assert(err, check.IsNil)
+	c.Assert(permission.Check(u, permission.PermAppDeploy, permission.Context(permission.CtxApp, "euapp")), check.Equals, true)
+	c.Assert(permission.Check(u, permission.PermAppDeploy, permission.Context(permission.CtxApp, "usapp")), check.Equals, false)
+	c.Assert(permission.Check(u, permission.PermAppDeploy, permission.Context(permission.CtxPool, "eupool")), check.Equals, true)
+	c.Assert(permission.Check(u, permission.PermPoolRead, permission.Context(permission.CtxPool, "eupool")), check.Equals, true)
+	c.Assert(permission.Check(u, permission.PermServiceInstanceRead, permission.Context(permission.CtxServiceInstance, "mysql/db1")), check.Equals, true)
+	c.Assert(permission.Check(u, permission.PermServiceInstanceRead, permission.Context(permission.CtxServiceInstance, "mysql/db2")), check.Equals, false)
+}
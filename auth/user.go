@@ -40,6 +40,7 @@ type User struct {
 	Password string
 	APIKey   string
 	Roles    []RoleInstance `bson:",omitempty"`
+	Settings UserSettings
 }
 
 func listUsers(filter bson.M) ([]User, error) {
@@ -248,7 +249,11 @@ func (u *User) Permissions() ([]permission.Permission, error) {
 		}
 		permissions = append(permissions, role.PermissionsFor(roleData.ContextValue)...)
 	}
-	return permissions, nil
+	permissions, err := expandOrganizationPermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+	return expandTagPermissions(permissions)
 }
 
 func (u *User) AddRole(roleName string, contextValue string) error {
@@ -0,0 +1,314 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package autosleep puts idle apps in development pools to sleep, routing
+// their requests to a proxy that wakes them up on demand.
+package autosleep
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Owner is the name of the internal owner of the sleep and wake events.
+const Owner = "autosleep"
+
+var globalConfig *Config
+
+type Config struct {
+	// Pools are the pools whose apps are put to sleep when idle.
+	Pools []string
+	// IdleTime is how long an app must be idle before being put to sleep.
+	IdleTime time.Duration
+	// WakeTimeout is how long a request waits for the app to wake up.
+	WakeTimeout time.Duration
+	RunInterval time.Duration
+	// ProxyURL is the address registered in the router of sleeping apps,
+	// pointing to the wake proxy.
+	ProxyURL *url.URL
+	// ProxyListen is the address the wake proxy listens on.
+	ProxyListen string
+	Enabled     bool
+	done        chan bool
+	server      *http.Server
+	waking      map[string]*wakeCall
+	wakingMu    sync.Mutex
+}
+
+type wakeCall struct {
+	done chan struct{}
+	err  error
+}
+
+func Initialize() error {
+	var err error
+	globalConfig, err = newConfig()
+	if err != nil {
+		return err
+	}
+	if !globalConfig.Enabled {
+		return nil
+	}
+	shutdown.Register(globalConfig)
+	globalConfig.server = &http.Server{Addr: globalConfig.ProxyListen, Handler: globalConfig}
+	go func() {
+		proxyErr := globalConfig.server.ListenAndServe()
+		if proxyErr != nil && proxyErr != http.ErrServerClosed {
+			globalConfig.logError("wake proxy stopped: %s", proxyErr)
+		}
+	}()
+	go globalConfig.run()
+	return nil
+}
+
+func newConfig() (*Config, error) {
+	enabled, _ := config.GetBool("autosleep:enabled")
+	pools, _ := config.GetList("autosleep:pools")
+	idleTime, _ := config.GetInt("autosleep:idle-time")
+	wakeTimeout, _ := config.GetInt("autosleep:wake-timeout")
+	runInterval, _ := config.GetInt("autosleep:run-interval")
+	listen, _ := config.GetString("autosleep:proxy-listen")
+	c := &Config{
+		Pools:       pools,
+		IdleTime:    time.Duration(idleTime) * time.Second,
+		WakeTimeout: time.Duration(wakeTimeout) * time.Second,
+		RunInterval: time.Duration(runInterval) * time.Second,
+		ProxyListen: listen,
+		Enabled:     enabled,
+		done:        make(chan bool),
+		waking:      make(map[string]*wakeCall),
+	}
+	if c.IdleTime == 0 {
+		c.IdleTime = time.Hour
+	}
+	if c.WakeTimeout == 0 {
+		c.WakeTimeout = 2 * time.Minute
+	}
+	if c.RunInterval == 0 {
+		c.RunInterval = 5 * time.Minute
+	}
+	if c.ProxyListen == "" {
+		c.ProxyListen = ":8081"
+	}
+	if !c.Enabled {
+		return c, nil
+	}
+	proxyURL, err := config.GetString("autosleep:proxy-url")
+	if err != nil {
+		return nil, errors.Wrap(err, "autosleep:proxy-url is required when autosleep is enabled")
+	}
+	c.ProxyURL, err = url.Parse(proxyURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid autosleep:proxy-url")
+	}
+	return c, nil
+}
+
+func (c *Config) run() {
+	for {
+		err := c.runOnce()
+		if err != nil {
+			c.logError(err.Error())
+		}
+		select {
+		case <-c.done:
+			return
+		case <-time.After(c.RunInterval):
+		}
+	}
+}
+
+func (c *Config) Shutdown() {
+	if c.Enabled {
+		c.done <- true
+		c.server.Close()
+		c.Enabled = false
+	}
+}
+
+func (c *Config) String() string {
+	return "app autosleep"
+}
+
+func (c *Config) logError(msg string, params ...interface{}) {
+	log.Errorf("[autosleep] "+msg, params...)
+}
+
+// runOnce puts to sleep all awake apps in the configured pools that have been
+// idle for longer than IdleTime.
+func (c *Config) runOnce() error {
+	if len(c.Pools) == 0 {
+		return nil
+	}
+	apps, err := app.List(&app.Filter{Pools: c.Pools})
+	if err != nil {
+		return errors.Wrap(err, "unable to list apps")
+	}
+	for i := range apps {
+		a := &apps[i]
+		idle, err := c.isIdle(a)
+		if err != nil {
+			c.logError("unable to check activity of app %q: %s", a.Name, err)
+			continue
+		}
+		if !idle {
+			continue
+		}
+		err = c.sleep(a)
+		if err != nil {
+			c.logError("unable to put app %q to sleep: %s", a.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) isIdle(a *app.App) (bool, error) {
+	units, err := a.Units()
+	if err != nil {
+		return false, err
+	}
+	var awake bool
+	for _, u := range units {
+		if u.Available() {
+			awake = true
+			break
+		}
+	}
+	if !awake {
+		return false, nil
+	}
+	last, err := lastActivity(a)
+	if err != nil {
+		return false, err
+	}
+	return time.Since(last) > c.IdleTime, nil
+}
+
+// lastActivity returns the time of the most recent event targeting the app or
+// log line written by its units, whichever is newer.
+func lastActivity(a *app.App) (time.Time, error) {
+	var last time.Time
+	evts, err := event.List(&event.Filter{
+		Target: event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Sort:   "-starttime",
+		Limit:  1,
+	})
+	if err != nil {
+		return last, err
+	}
+	if len(evts) > 0 {
+		last = evts[0].StartTime
+	}
+	conn, err := db.LogConn()
+	if err != nil {
+		return last, err
+	}
+	defer conn.Close()
+	var applog app.Applog
+	err = conn.Logs(a.Name).Find(bson.M{"source": bson.M{"$ne": "tsuru"}}).Sort("-$natural").One(&applog)
+	if err == nil && applog.Date.After(last) {
+		last = applog.Date
+	}
+	return last, nil
+}
+
+func appContexts(a *app.App) []permission.PermissionContext {
+	return append(permission.Contexts(permission.CtxTeam, a.Teams),
+		permission.Context(permission.CtxApp, a.Name),
+		permission.Context(permission.CtxPool, a.Pool),
+	)
+}
+
+func (c *Config) sleep(a *app.App) (err error) {
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:     permission.PermAppUpdateSleep,
+		RawOwner: event.Owner{Type: event.OwnerTypeInternal, Name: Owner},
+		Allowed:  event.Allowed(permission.PermAppReadEvents, appContexts(a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	fmt.Fprintf(evt, "app idle for more than %s\n", c.IdleTime)
+	return a.Sleep(evt, "", c.ProxyURL)
+}
+
+// wake starts the app and waits for one of its units to be available. A
+// single wake is run for concurrent requests to the same app.
+func (c *Config) wake(a *app.App) error {
+	c.wakingMu.Lock()
+	call, ok := c.waking[a.Name]
+	if !ok {
+		call = &wakeCall{done: make(chan struct{})}
+		c.waking[a.Name] = call
+		go func() {
+			call.err = c.doWake(a)
+			c.wakingMu.Lock()
+			delete(c.waking, a.Name)
+			c.wakingMu.Unlock()
+			close(call.done)
+		}()
+	}
+	c.wakingMu.Unlock()
+	select {
+	case <-call.done:
+		return call.err
+	case <-time.After(c.WakeTimeout):
+		return errors.Errorf("timeout waiting for app %q to wake up", a.Name)
+	}
+}
+
+var wakeCheckInterval = time.Second
+
+func (c *Config) doWake(a *app.App) (err error) {
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:     permission.PermAppUpdateStart,
+		RawOwner: event.Owner{Type: event.OwnerTypeInternal, Name: Owner},
+		Allowed:  event.Allowed(permission.PermAppReadEvents, appContexts(a)...),
+	})
+	if err != nil {
+		if _, ok := err.(event.ErrEventLocked); !ok {
+			return err
+		}
+	} else {
+		defer func() { evt.Done(err) }()
+		err = a.Start(evt, "")
+		if err != nil {
+			return err
+		}
+	}
+	timeout := time.After(c.WakeTimeout)
+	for {
+		units, err := a.Units()
+		if err != nil {
+			return err
+		}
+		for _, u := range units {
+			if u.Status == provision.StatusStarted {
+				return nil
+			}
+		}
+		select {
+		case <-timeout:
+			return errors.Errorf("no unit of app %q started after %s", a.Name, c.WakeTimeout)
+		case <-time.After(wakeCheckInterval):
+		}
+	}
+}
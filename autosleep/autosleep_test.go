@@ -0,0 +1,63 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autosleep
+
+import (
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestRunOnceSleepsIdleApps(c *check.C) {
+	a := s.newApp(c, "myapp", "dev")
+	other := s.newApp(c, "otherapp", "prod")
+	err := s.cfg.runOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.p.Sleeps(a, ""), check.Equals, 1)
+	c.Assert(s.p.Sleeps(other, ""), check.Equals, 0)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units[0].Status, check.Equals, provision.StatusAsleep)
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, s.cfg.ProxyURL.String()), check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:   "app.update.sleep",
+		Owner:  Owner,
+	}, eventtest.HasEvent)
+	err = s.cfg.runOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.p.Sleeps(a, ""), check.Equals, 1)
+}
+
+func (s *S) TestRunOnceKeepsActiveApps(c *check.C) {
+	a := s.newApp(c, "myapp", "dev")
+	err := a.Log("GET / 200", "web", "unit1")
+	c.Assert(err, check.IsNil)
+	err = s.cfg.runOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.p.Sleeps(a, ""), check.Equals, 0)
+}
+
+func (s *S) TestWake(c *check.C) {
+	a := s.newApp(c, "myapp", "dev")
+	err := s.cfg.wake(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.p.Starts(a, ""), check.Equals, 1)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:   "app.update.start",
+		Owner:  Owner,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestWakeTimeout(c *check.C) {
+	a := s.newApp(c, "myapp", "dev")
+	err := s.p.Sleep(a, "")
+	c.Assert(err, check.IsNil)
+	err = s.cfg.wake(a)
+	c.Assert(err, check.ErrorMatches, `.*app "myapp".*`)
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autosleep
+
+import (
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// wakeHeader marks requests forwarded by the proxy, so a request reaching the
+// proxy again, because the router still points to it, isn't forwarded in a
+// loop.
+const wakeHeader = "X-Tsuru-Autosleep-Wake"
+
+// ServeHTTP handles requests sent by the router to sleeping apps. The request
+// is held while the app is woken up, and then forwarded to the router, which
+// now points to the app units. Only apps in the autosleep pools whose routes
+// point to the proxy are woken up, requests to any other app are answered with
+// 404.
+func (c *Config) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(wakeHeader) != "" {
+		http.Error(w, "app is still waking up", http.StatusServiceUnavailable)
+		return
+	}
+	a, err := appForHost(r.Host)
+	if err == mgo.ErrNotFound {
+		http.Error(w, "app not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.logError("unable to find app for host %q: %s", r.Host, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sleeping, err := c.isSleeping(a)
+	if err != nil {
+		c.logError("unable to check if app %q is sleeping: %s", a.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !sleeping {
+		http.Error(w, "app not found", http.StatusNotFound)
+		return
+	}
+	err = c.wake(a)
+	if err != nil {
+		c.logError("unable to wake app %q up: %s", a.Name, err)
+		http.Error(w, "unable to wake app up", http.StatusServiceUnavailable)
+		return
+	}
+	target, err := routerURL(a)
+	if err != nil {
+		c.logError("unable to get router address of app %q: %s", a.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = r.Host
+		req.Header.Set(wakeHeader, a.Name)
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+func appForHost(host string) (*app.App, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var a app.App
+	err = conn.Apps().Find(bson.M{"$or": []bson.M{{"ip": host}, {"cname": host}}}).One(&a)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// isSleeping returns whether the app was put to sleep by autosleep, which
+// means it's in one of the autosleep pools and routed to the proxy.
+func (c *Config) isSleeping(a *app.App) (bool, error) {
+	var inPool bool
+	for _, p := range c.Pools {
+		if p == a.Pool {
+			inPool = true
+			break
+		}
+	}
+	if !inPool || c.ProxyURL == nil {
+		return false, nil
+	}
+	r, err := a.GetRouter()
+	if err != nil {
+		return false, err
+	}
+	routes, err := r.Routes(a.Name)
+	if err != nil {
+		return false, err
+	}
+	for _, route := range routes {
+		if route.Host == c.ProxyURL.Host {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func routerURL(a *app.App) (*url.URL, error) {
+	r, err := a.GetRouter()
+	if err != nil {
+		return nil, err
+	}
+	addr, err := r.Addr(a.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "http", Host: addr}, nil
+}
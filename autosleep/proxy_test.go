@@ -0,0 +1,87 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autosleep
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2"
+)
+
+func (s *S) TestAppForHost(c *check.C) {
+	a := s.newApp(c, "myapp", "dev")
+	err := s.conn.Apps().UpdateId(a.Name, map[string]interface{}{"$set": map[string]interface{}{"cname": []string{"myapp.example.com"}}})
+	c.Assert(err, check.IsNil)
+	found, err := appForHost("myapp.fakerouter.com:80")
+	c.Assert(err, check.IsNil)
+	c.Assert(found.Name, check.Equals, "myapp")
+	found, err = appForHost("myapp.example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(found.Name, check.Equals, "myapp")
+	_, err = appForHost("unknown.example.com")
+	c.Assert(err, check.Equals, mgo.ErrNotFound)
+}
+
+func (s *S) TestProxyUnknownHost(c *check.C) {
+	request, err := http.NewRequest("GET", "http://unknown.example.com/", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	s.cfg.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestProxyForwardedRequestNotForwardedAgain(c *check.C) {
+	a := s.newApp(c, "myapp", "dev")
+	request, err := http.NewRequest("GET", "http://myapp.fakerouter.com/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set(wakeHeader, a.Name)
+	recorder := httptest.NewRecorder()
+	s.cfg.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusServiceUnavailable)
+	c.Assert(s.p.Starts(a, ""), check.Equals, 0)
+}
+
+func (s *S) TestProxyAppNotSleeping(c *check.C) {
+	a := s.newApp(c, "myapp", "dev")
+	request, err := http.NewRequest("GET", "http://myapp.fakerouter.com/", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	s.cfg.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(s.p.Starts(a, ""), check.Equals, 0)
+}
+
+func (s *S) TestProxyAppOutsideAutosleepPools(c *check.C) {
+	a := s.newApp(c, "myapp", "prod")
+	err := routertest.FakeRouter.AddRoute(a.Name, s.cfg.ProxyURL)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "http://myapp.fakerouter.com/", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	s.cfg.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(s.p.Starts(a, ""), check.Equals, 0)
+}
+
+func (s *S) TestIsSleeping(c *check.C) {
+	a := s.newApp(c, "myapp", "dev")
+	sleeping, err := s.cfg.isSleeping(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(sleeping, check.Equals, false)
+	err = routertest.FakeRouter.AddRoute(a.Name, s.cfg.ProxyURL)
+	c.Assert(err, check.IsNil)
+	sleeping, err = s.cfg.isSleeping(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(sleeping, check.Equals, true)
+	other := s.newApp(c, "otherapp", "prod")
+	err = routertest.FakeRouter.AddRoute(other.Name, s.cfg.ProxyURL)
+	c.Assert(err, check.IsNil)
+	sleeping, err = s.cfg.isSleeping(other)
+	c.Assert(err, check.IsNil)
+	c.Assert(sleeping, check.Equals, false)
+}
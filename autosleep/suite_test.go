@@ -0,0 +1,82 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autosleep
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+var _ = check.Suite(&S{})
+
+type S struct {
+	conn *db.Storage
+	p    *provisiontest.FakeProvisioner
+	cfg  *Config
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "autosleep_tests_s")
+	wakeCheckInterval = 10 * time.Millisecond
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	routertest.FakeRouter.Reset()
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+	dbtest.ClearAllCollections(s.conn.Apps().Database)
+	provisiontest.ProvisionerInstance.Reset()
+	s.p = provisiontest.ProvisionerInstance
+	err = provision.AddPool(provision.AddPoolOptions{Name: "dev", Provisioner: "fake"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "prod", Provisioner: "fake"})
+	c.Assert(err, check.IsNil)
+	proxyURL, _ := url.Parse("http://autosleep.tsuru.io:8081")
+	s.cfg = &Config{
+		Pools:       []string{"dev"},
+		IdleTime:    time.Minute,
+		WakeTimeout: time.Second,
+		ProxyURL:    proxyURL,
+		waking:      make(map[string]*wakeCall),
+	}
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.conn.Close()
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	conn.Apps().Database.DropDatabase()
+}
+
+func (s *S) newApp(c *check.C, name, pool string) *app.App {
+	a := &app.App{Name: name, Pool: pool, Router: "fake", Ip: name + ".fakerouter.com", Teams: []string{"devs"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = s.p.Provision(a)
+	c.Assert(err, check.IsNil)
+	err = s.p.AddUnits(a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = routertest.FakeRouter.AddBackend(name)
+	c.Assert(err, check.IsNil)
+	return a
+}
@@ -0,0 +1,368 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package chaos runs opt-in failure experiments against apps to support
+// resilience game days: killing a random unit, delaying requests in the
+// router or failing a node running units of the app. Experiments last for a
+// time window, after which their effects are reverted.
+package chaos
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	KindKillUnit      = "kill-unit"
+	KindRouterLatency = "router-latency"
+	KindNodeFailure   = "node-failure"
+)
+
+var (
+	ErrDisabled                = errors.New("chaos experiments are disabled in this installation")
+	ErrInvalidKind             = errors.Errorf("invalid experiment kind: must be %q, %q or %q", KindKillUnit, KindRouterLatency, KindNodeFailure)
+	ErrInvalidDuration         = errors.New("invalid experiment duration: must be positive and not exceed the maximum duration")
+	ErrInvalidLatency          = errors.New("invalid experiment latency: must be positive")
+	ErrNoUnits                 = errors.New("app has no units to run the experiment")
+	ErrExperimentRunning       = errors.New("app already has a running experiment of this kind")
+	ErrExperimentNotFound      = errors.New("chaos experiment not found")
+	ErrKillUnitNotSupported    = errors.New("provisioner does not support killing units")
+	ErrLatencyNotSupported     = errors.New("router does not support latency injection")
+	ErrNodeFailureNotSupported = errors.New("provisioner does not support node evacuation")
+)
+
+var (
+	now         = time.Now
+	randomIndex = rand.Intn
+)
+
+// Experiment is a failure injected in an app. Experiments with lasting
+// effects, like router latency and node failures, are reverted at End.
+type Experiment struct {
+	ID      bson.ObjectId `bson:"_id" json:"id"`
+	App     string        `json:"app"`
+	Kind    string        `json:"kind"`
+	Process string        `json:"process,omitempty"`
+	Latency time.Duration `json:"latency,omitempty"`
+	// Target is the ID of the killed unit or the address of the failed node.
+	Target   string    `json:"target,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Owner    string    `json:"owner"`
+	Reverted bool      `json:"reverted"`
+}
+
+type Opts struct {
+	Kind string
+	// Process restricts the units considered by kill-unit and node-failure
+	// experiments.
+	Process  string
+	Latency  time.Duration
+	Duration time.Duration
+	Owner    string
+	Writer   io.Writer
+}
+
+func collection(conn *db.Storage) *storage.Collection {
+	return conn.Collection("chaos_experiments")
+}
+
+// Enabled returns whether chaos experiments are enabled in the installation.
+func Enabled() bool {
+	enabled, _ := config.GetBool("chaos:enabled")
+	return enabled
+}
+
+// MaxDuration returns the maximum duration of experiments.
+func MaxDuration() time.Duration {
+	seconds, _ := config.GetInt("chaos:max-duration")
+	if seconds <= 0 {
+		return time.Hour
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Run injects the failure described by opts in the app. Killing a unit has no
+// lasting effect, other experiments last for opts.Duration.
+func Run(a *app.App, opts Opts) (*Experiment, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+	exp := Experiment{
+		ID:      bson.NewObjectId(),
+		App:     a.Name,
+		Kind:    opts.Kind,
+		Process: opts.Process,
+		Start:   now().UTC(),
+		Owner:   opts.Owner,
+	}
+	switch opts.Kind {
+	case KindKillUnit:
+		exp.End = exp.Start
+		exp.Reverted = true
+	case KindRouterLatency, KindNodeFailure:
+		if opts.Duration <= 0 || opts.Duration > MaxDuration() {
+			return nil, ErrInvalidDuration
+		}
+		exp.End = exp.Start.Add(opts.Duration)
+	default:
+		return nil, ErrInvalidKind
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if !exp.Reverted {
+		var count int
+		count, err = collection(conn).Find(bson.M{"app": a.Name, "kind": opts.Kind, "reverted": false}).Count()
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, ErrExperimentRunning
+		}
+	}
+	switch opts.Kind {
+	case KindKillUnit:
+		exp.Target, err = killUnit(a, opts.Process)
+	case KindRouterLatency:
+		exp.Latency = opts.Latency
+		err = injectLatency(a, opts.Latency)
+	case KindNodeFailure:
+		exp.Target, err = failNode(a, opts.Process, opts.Writer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = collection(conn).Insert(exp)
+	if err != nil {
+		return nil, err
+	}
+	return &exp, nil
+}
+
+// List returns the experiments of the app, most recent first.
+func List(appName string) ([]Experiment, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var experiments []Experiment
+	err = collection(conn).Find(bson.M{"app": appName}).Sort("-start").All(&experiments)
+	if err != nil {
+		return nil, err
+	}
+	return experiments, nil
+}
+
+// Stop reverts the effects of a running experiment before its end.
+func Stop(appName, id string) (*Experiment, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrExperimentNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var exp Experiment
+	err = collection(conn).Find(bson.M{"_id": bson.ObjectIdHex(id), "app": appName}).One(&exp)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, ErrExperimentNotFound
+		}
+		return nil, err
+	}
+	if exp.Reverted {
+		return &exp, nil
+	}
+	exp.End = now().UTC()
+	err = revert(conn, &exp)
+	if err != nil {
+		return nil, err
+	}
+	return &exp, nil
+}
+
+// Expired returns the experiments whose window ended and that weren't
+// reverted yet.
+func Expired() ([]Experiment, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var experiments []Experiment
+	err = collection(conn).Find(bson.M{"reverted": false, "end": bson.M{"$lte": now().UTC()}}).All(&experiments)
+	if err != nil {
+		return nil, err
+	}
+	return experiments, nil
+}
+
+// Revert undoes the effects of the experiment.
+func Revert(exp *Experiment) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return revert(conn, exp)
+}
+
+func revert(conn *db.Storage, exp *Experiment) error {
+	var err error
+	switch exp.Kind {
+	case KindRouterLatency:
+		err = removeLatency(exp.App)
+	case KindNodeFailure:
+		err = restoreNode(exp.Target)
+	}
+	if err != nil {
+		return err
+	}
+	exp.Reverted = true
+	return collection(conn).UpdateId(exp.ID, bson.M{"$set": bson.M{"reverted": true, "end": exp.End}})
+}
+
+func provisionerForApp(a *app.App) (provision.Provisioner, error) {
+	if a.Pool == "" {
+		return provision.GetDefault()
+	}
+	pool, err := provision.GetPoolByName(a.Pool)
+	if err != nil {
+		return nil, err
+	}
+	return pool.GetProvisioner()
+}
+
+func randomUnit(a *app.App, process string) (provision.Unit, error) {
+	units, err := a.Units()
+	if err != nil {
+		return provision.Unit{}, err
+	}
+	var candidates []provision.Unit
+	for _, u := range units {
+		if process == "" || u.ProcessName == process {
+			candidates = append(candidates, u)
+		}
+	}
+	if len(candidates) == 0 {
+		return provision.Unit{}, ErrNoUnits
+	}
+	return candidates[randomIndex(len(candidates))], nil
+}
+
+func killUnit(a *app.App, process string) (string, error) {
+	prov, err := provisionerForApp(a)
+	if err != nil {
+		return "", err
+	}
+	killer, ok := prov.(provision.UnitKillerProvisioner)
+	if !ok {
+		return "", ErrKillUnitNotSupported
+	}
+	unit, err := randomUnit(a, process)
+	if err != nil {
+		return "", err
+	}
+	return unit.ID, killer.KillUnit(a, unit.ID)
+}
+
+func latencyRouter(appName string) (router.FaultInjectionRouter, error) {
+	a, err := app.GetByName(appName)
+	if err != nil {
+		return nil, err
+	}
+	r, err := a.GetRouter()
+	if err != nil {
+		return nil, err
+	}
+	fRouter, ok := r.(router.FaultInjectionRouter)
+	if !ok {
+		return nil, ErrLatencyNotSupported
+	}
+	return fRouter, nil
+}
+
+func injectLatency(a *app.App, latency time.Duration) error {
+	if latency <= 0 {
+		return ErrInvalidLatency
+	}
+	fRouter, err := latencyRouter(a.Name)
+	if err != nil {
+		return err
+	}
+	return fRouter.SetLatency(a.Name, latency)
+}
+
+// removeLatency is a no-op for removed apps, as their backends were removed
+// from the router.
+func removeLatency(appName string) error {
+	fRouter, err := latencyRouter(appName)
+	if err == app.ErrAppNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fRouter.UnsetLatency(appName)
+}
+
+func failNode(a *app.App, process string, w io.Writer) (string, error) {
+	prov, err := provisionerForApp(a)
+	if err != nil {
+		return "", err
+	}
+	nodeProv, ok := prov.(provision.NodeProvisioner)
+	if !ok {
+		return "", ErrNodeFailureNotSupported
+	}
+	evacuationProv, ok := prov.(provision.NodeEvacuationProvisioner)
+	if !ok {
+		return "", ErrNodeFailureNotSupported
+	}
+	unit, err := randomUnit(a, process)
+	if err != nil {
+		return "", err
+	}
+	node, err := provision.FindNodeByAddrs(nodeProv, []string{unit.Ip})
+	if err != nil {
+		return "", err
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	return node.Address(), evacuationProv.EvacuateNode(node.Address(), w)
+}
+
+// restoreNode enables the failed node again. Its units aren't moved back,
+// they're balanced on the next rebalance or deploy.
+func restoreNode(address string) error {
+	prov, _, err := provision.FindNode(address)
+	if err == provision.ErrNodeNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return prov.(provision.NodeProvisioner).UpdateNode(provision.UpdateNodeOptions{
+		Address: address,
+		Enable:  true,
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chaos
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestRunDisabled(c *check.C) {
+	config.Set("chaos:enabled", false)
+	a := s.newApp(c, "myapp")
+	_, err := Run(a, Opts{Kind: KindKillUnit})
+	c.Assert(err, check.Equals, ErrDisabled)
+}
+
+func (s *S) TestRunInvalid(c *check.C) {
+	a := s.newApp(c, "myapp")
+	_, err := Run(a, Opts{Kind: "meteor"})
+	c.Assert(err, check.Equals, ErrInvalidKind)
+	_, err = Run(a, Opts{Kind: KindRouterLatency, Latency: time.Second})
+	c.Assert(err, check.Equals, ErrInvalidDuration)
+	_, err = Run(a, Opts{Kind: KindRouterLatency, Latency: time.Second, Duration: 2 * time.Hour})
+	c.Assert(err, check.Equals, ErrInvalidDuration)
+	_, err = Run(a, Opts{Kind: KindRouterLatency, Duration: time.Minute})
+	c.Assert(err, check.Equals, ErrInvalidLatency)
+	_, err = Run(a, Opts{Kind: KindKillUnit, Process: "worker"})
+	c.Assert(err, check.Equals, ErrNoUnits)
+	experiments, err := List(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(experiments, check.HasLen, 0)
+}
+
+func (s *S) TestRunKillUnit(c *check.C) {
+	a := s.newApp(c, "myapp")
+	exp, err := Run(a, Opts{Kind: KindKillUnit, Owner: "majortom@groundcontrol.com"})
+	c.Assert(err, check.IsNil)
+	c.Assert(exp.Target, check.Equals, "myapp-0")
+	c.Assert(exp.Reverted, check.Equals, true)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units[0].Status, check.Equals, provision.StatusError)
+	c.Assert(units[1].Status, check.Equals, provision.StatusStarted)
+	experiments, err := List(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(experiments, check.HasLen, 1)
+	c.Assert(experiments[0].ID, check.Equals, exp.ID)
+	c.Assert(experiments[0].Owner, check.Equals, "majortom@groundcontrol.com")
+}
+
+func (s *S) TestRunRouterLatency(c *check.C) {
+	a := s.newApp(c, "myapp")
+	exp, err := Run(a, Opts{Kind: KindRouterLatency, Latency: 500 * time.Millisecond, Duration: time.Minute})
+	c.Assert(err, check.IsNil)
+	c.Assert(exp.Reverted, check.Equals, false)
+	c.Assert(exp.End.Sub(exp.Start), check.Equals, time.Minute)
+	latency, ok := routertest.FakeRouter.GetLatency(a.Name)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(latency, check.Equals, 500*time.Millisecond)
+	_, err = Run(a, Opts{Kind: KindRouterLatency, Latency: time.Second, Duration: time.Minute})
+	c.Assert(err, check.Equals, ErrExperimentRunning)
+	stopped, err := Stop(a.Name, exp.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(stopped.Reverted, check.Equals, true)
+	_, ok = routertest.FakeRouter.GetLatency(a.Name)
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *S) TestRunNodeFailure(c *check.C) {
+	err := s.p.AddNode(provision.AddNodeOptions{
+		Address:  "http://node1:2375",
+		Metadata: map[string]string{"pool": "pool1"},
+	})
+	c.Assert(err, check.IsNil)
+	a := s.newApp(c, "myapp")
+	exp, err := Run(a, Opts{Kind: KindNodeFailure, Duration: time.Minute})
+	c.Assert(err, check.IsNil)
+	c.Assert(exp.Target, check.Equals, "http://node1:2375")
+	node, err := s.p.GetNode("http://node1:2375")
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Status(), check.Equals, "disabled")
+	stopped, err := Stop(a.Name, exp.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(stopped.Reverted, check.Equals, true)
+	node, err = s.p.GetNode("http://node1:2375")
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Status(), check.Equals, "enabled")
+}
+
+func (s *S) TestStopNotFound(c *check.C) {
+	_, err := Stop("myapp", "invalid")
+	c.Assert(err, check.Equals, ErrExperimentNotFound)
+	a := s.newApp(c, "myapp")
+	exp, err := Run(a, Opts{Kind: KindRouterLatency, Latency: time.Second, Duration: time.Minute})
+	c.Assert(err, check.IsNil)
+	_, err = Stop("otherapp", exp.ID.Hex())
+	c.Assert(err, check.Equals, ErrExperimentNotFound)
+}
+
+func (s *S) TestRevertExpired(c *check.C) {
+	a := s.newApp(c, "myapp")
+	exp, err := Run(a, Opts{Kind: KindRouterLatency, Latency: time.Second, Duration: time.Minute})
+	c.Assert(err, check.IsNil)
+	RevertExpired()
+	_, ok := routertest.FakeRouter.GetLatency(a.Name)
+	c.Assert(ok, check.Equals, true)
+	now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	RevertExpired()
+	_, ok = routertest.FakeRouter.GetLatency(a.Name)
+	c.Assert(ok, check.Equals, false)
+	experiments, err := List(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(experiments, check.HasLen, 1)
+	c.Assert(experiments[0].ID, check.Equals, exp.ID)
+	c.Assert(experiments[0].Reverted, check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:   "app.update.chaos.stop",
+		Owner:  Owner,
+	}, eventtest.HasEvent)
+}
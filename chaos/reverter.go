@@ -0,0 +1,101 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chaos
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// Owner is the name of the internal owner of the events reverting expired
+// experiments.
+const Owner = "chaos"
+
+type reverter struct {
+	interval time.Duration
+	done     chan bool
+}
+
+// Initialize starts reverting experiments once their window ends, when chaos
+// experiments are enabled.
+func Initialize() error {
+	if !Enabled() {
+		return nil
+	}
+	interval, _ := config.GetInt("chaos:run-interval")
+	r := &reverter{
+		interval: time.Duration(interval) * time.Second,
+		done:     make(chan bool),
+	}
+	if r.interval == 0 {
+		r.interval = 30 * time.Second
+	}
+	shutdown.Register(r)
+	go r.run()
+	return nil
+}
+
+func (r *reverter) run() {
+	for {
+		RevertExpired()
+		select {
+		case <-r.done:
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+func (r *reverter) Shutdown() {
+	r.done <- true
+}
+
+func (r *reverter) String() string {
+	return "chaos experiments reverter"
+}
+
+// RevertExpired reverts the experiments whose window ended, recording an
+// event for each one. Experiments failing to revert are retried on the next
+// run.
+func RevertExpired() {
+	experiments, err := Expired()
+	if err != nil {
+		log.Errorf("[chaos] unable to list expired experiments: %s", err)
+		return
+	}
+	for i := range experiments {
+		err = revertWithEvent(&experiments[i])
+		if err != nil {
+			log.Errorf("[chaos] unable to revert %s experiment %s of app %q: %s", experiments[i].Kind, experiments[i].ID.Hex(), experiments[i].App, err)
+		}
+	}
+}
+
+func revertWithEvent(exp *Experiment) (err error) {
+	contexts := []permission.PermissionContext{permission.Context(permission.CtxApp, exp.App)}
+	if a, errGet := app.GetByName(exp.App); errGet == nil {
+		contexts = append(contexts, permission.Context(permission.CtxPool, a.Pool))
+		contexts = append(contexts, permission.Contexts(permission.CtxTeam, a.Teams)...)
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      event.Target{Type: event.TargetTypeApp, Value: exp.App},
+		Kind:        permission.PermAppUpdateChaosStop,
+		RawOwner:    event.Owner{Type: event.OwnerTypeInternal, Name: Owner},
+		CustomData:  exp,
+		DisableLock: true,
+		Allowed:     event.Allowed(permission.PermAppReadEvents, contexts...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return Revert(exp)
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chaos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+var _ = check.Suite(&S{})
+
+type S struct {
+	conn *db.Storage
+	p    *provisiontest.FakeProvisioner
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "chaos_tests_s")
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	routertest.FakeRouter.Reset()
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+	dbtest.ClearAllCollections(s.conn.Apps().Database)
+	provisiontest.ProvisionerInstance.Reset()
+	s.p = provisiontest.ProvisionerInstance
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool1", Provisioner: "fake"})
+	c.Assert(err, check.IsNil)
+	config.Set("chaos:enabled", true)
+	now = time.Now
+	randomIndex = func(int) int { return 0 }
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("chaos")
+	s.conn.Close()
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	conn.Apps().Database.DropDatabase()
+}
+
+func (s *S) newApp(c *check.C, name string) *app.App {
+	a := &app.App{Name: name, Pool: "pool1", Router: "fake", Teams: []string{"devs"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = s.p.Provision(a)
+	c.Assert(err, check.IsNil)
+	err = s.p.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = routertest.FakeRouter.AddBackend(name)
+	c.Assert(err, check.IsNil)
+	return a
+}
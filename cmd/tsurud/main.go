@@ -14,6 +14,7 @@ import (
 	"github.com/tsuru/tsuru/api"
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/iaas/dockermachine"
+	_ "github.com/tsuru/tsuru/policy/opa"
 	_ "github.com/tsuru/tsuru/provision/docker"
 	_ "github.com/tsuru/tsuru/provision/kubernetes"
 	_ "github.com/tsuru/tsuru/provision/mesos"
@@ -16,6 +16,7 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
 	evtMigrate "github.com/tsuru/tsuru/event/migrate"
 	"github.com/tsuru/tsuru/migration"
 	"github.com/tsuru/tsuru/permission"
@@ -78,10 +79,18 @@ func init() {
 	if err != nil {
 		log.Fatalf("unable to register migration: %s", err)
 	}
+	err = migration.Register("migrate-pool-constraints-index", provision.MigratePoolConstraintsIndex)
+	if err != nil {
+		log.Fatalf("unable to register migration: %s", err)
+	}
 	err = migration.RegisterOptional("migrate-roles", migrateRoles)
 	if err != nil {
 		log.Fatalf("unable to register migration: %s", err)
 	}
+	err = migration.RegisterOptional("migrate-events-partitions", event.MovePartitioned)
+	if err != nil {
+		log.Fatalf("unable to register migration: %s", err)
+	}
 }
 
 func getProvisioner() (string, error) {
@@ -0,0 +1,335 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package compliance generates periodic snapshots of the state of the tsuru
+// installation regarding a set of compliance checks, like users with admin
+// roles and apps without healthchecks. Reports are stored in the database so
+// they can be compared with older ones.
+package compliance
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultCertExpiryDays = 30
+	defaultListLimit      = 20
+)
+
+var (
+	ErrReportNotFound = errors.New("compliance report not found")
+
+	now = time.Now
+)
+
+// CertificateFinding is a certificate of an app that expires soon or is
+// already expired.
+type CertificateFinding struct {
+	App      string    `json:"app"`
+	CName    string    `json:"cname"`
+	NotAfter time.Time `json:"notAfter"`
+}
+
+func (f CertificateFinding) key() string {
+	return f.App + " " + f.CName
+}
+
+// Report is a snapshot of the compliance checks.
+type Report struct {
+	ID                      bson.ObjectId        `bson:"_id" json:"id"`
+	CreatedAt               time.Time            `json:"createdAt"`
+	AdminUsers              []string             `json:"adminUsers"`
+	AppsWithoutHealthcheck  []string             `json:"appsWithoutHealthcheck"`
+	CertificatesNearExpiry  []CertificateFinding `json:"certificatesNearExpiry"`
+	PoolsWithoutConstraints []string             `json:"poolsWithoutConstraints"`
+}
+
+// FindingsDiff holds the findings of a check that appeared and disappeared
+// between two reports.
+type FindingsDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// CertificatesDiff is the FindingsDiff of certificates near expiry.
+type CertificatesDiff struct {
+	Added   []CertificateFinding `json:"added"`
+	Removed []CertificateFinding `json:"removed"`
+}
+
+// Comparison holds the differences between two reports.
+type Comparison struct {
+	From                    *Report          `json:"from"`
+	To                      *Report          `json:"to"`
+	AdminUsers              FindingsDiff     `json:"adminUsers"`
+	AppsWithoutHealthcheck  FindingsDiff     `json:"appsWithoutHealthcheck"`
+	CertificatesNearExpiry  CertificatesDiff `json:"certificatesNearExpiry"`
+	PoolsWithoutConstraints FindingsDiff     `json:"poolsWithoutConstraints"`
+}
+
+func collection(conn *db.Storage) *storage.Collection {
+	coll := conn.Collection("compliance_reports")
+	coll.EnsureIndex(mgo.Index{Key: []string{"-createdat"}})
+	return coll
+}
+
+// Generate runs every compliance check and stores the resulting report.
+func Generate() (*Report, error) {
+	report := Report{
+		ID:        bson.NewObjectId(),
+		CreatedAt: now().UTC(),
+	}
+	var err error
+	report.AdminUsers, err = adminUsers()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list admin users")
+	}
+	apps, err := app.List(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list apps")
+	}
+	report.AppsWithoutHealthcheck, err = appsWithoutHealthcheck(apps)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to check app healthchecks")
+	}
+	report.CertificatesNearExpiry = certificatesNearExpiry(apps)
+	report.PoolsWithoutConstraints, err = poolsWithoutConstraints()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to check pool constraints")
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = collection(conn).Insert(report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func adminUsers() ([]string, error) {
+	users, err := auth.ListUsersWithPermissions(permission.Permission{
+		Scheme:  permission.PermAll,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+	emails := make([]string, len(users))
+	for i, u := range users {
+		emails[i] = u.Email
+	}
+	sort.Strings(emails)
+	return emails, nil
+}
+
+// appsWithoutHealthcheck returns the deployed apps whose current image has
+// no healthcheck set. Apps never deployed are ignored.
+func appsWithoutHealthcheck(apps []app.App) ([]string, error) {
+	names := []string{}
+	for _, a := range apps {
+		if a.Deploys == 0 {
+			continue
+		}
+		imgName, err := image.AppCurrentImageName(a.Name)
+		if err == image.ErrNoImagesAvailable {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := image.GetImageTsuruYamlData(imgName)
+		if err != nil {
+			return nil, err
+		}
+		hc := data.Healthcheck
+		if hc.Path == "" && hc.Command == "" && hc.GRPC == nil {
+			names = append(names, a.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// certificatesNearExpiry returns the certificates of apps expiring in less
+// than compliance:cert-expiry-days days. Apps whose certificates can't be
+// read, like the ones using routers without TLS support, are ignored.
+func certificatesNearExpiry(apps []app.App) []CertificateFinding {
+	days, err := config.GetInt("compliance:cert-expiry-days")
+	if err != nil || days <= 0 {
+		days = defaultCertExpiryDays
+	}
+	limit := now().Add(time.Duration(days) * 24 * time.Hour)
+	findings := []CertificateFinding{}
+	for i := range apps {
+		a := &apps[i]
+		certs, err := a.GetCertificates()
+		if err != nil {
+			continue
+		}
+		for cname, data := range certs {
+			if data == "" {
+				continue
+			}
+			block, _ := pem.Decode([]byte(data))
+			if block == nil {
+				log.Errorf("[compliance] invalid certificate for %q in app %q", cname, a.Name)
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				log.Errorf("[compliance] invalid certificate for %q in app %q: %s", cname, a.Name, err)
+				continue
+			}
+			if cert.NotAfter.Before(limit) {
+				findings = append(findings, CertificateFinding{App: a.Name, CName: cname, NotAfter: cert.NotAfter.UTC()})
+			}
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].key() < findings[j].key()
+	})
+	return findings
+}
+
+// poolsWithoutConstraints returns the pools with no constraint applied,
+// including the ones set for their group and the global ones.
+func poolsWithoutConstraints() ([]string, error) {
+	pools, err := provision.ListPools()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, p := range pools {
+		constraints, err := provision.MatchingPoolConstraints(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		if len(constraints) == 0 {
+			names = append(names, p.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Get returns the report with the given id.
+func Get(id string) (*Report, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrReportNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var report Report
+	err = collection(conn).FindId(bson.ObjectIdHex(id)).One(&report)
+	if err == mgo.ErrNotFound {
+		return nil, ErrReportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// List returns the most recent reports, newest first. A limit of zero
+// returns the last 20 reports.
+func List(limit int) ([]Report, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var reports []Report
+	err = collection(conn).Find(nil).Sort("-createdat").Limit(limit).All(&reports)
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// Latest returns the most recent report.
+func Latest() (*Report, error) {
+	reports, err := List(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, ErrReportNotFound
+	}
+	return &reports[0], nil
+}
+
+// Compare returns the findings added and removed from one report to the
+// other.
+func Compare(from, to *Report) *Comparison {
+	cmp := Comparison{
+		From:                    from,
+		To:                      to,
+		AdminUsers:              diff(from.AdminUsers, to.AdminUsers),
+		AppsWithoutHealthcheck:  diff(from.AppsWithoutHealthcheck, to.AppsWithoutHealthcheck),
+		PoolsWithoutConstraints: diff(from.PoolsWithoutConstraints, to.PoolsWithoutConstraints),
+	}
+	fromCerts := map[string]bool{}
+	for _, f := range from.CertificatesNearExpiry {
+		fromCerts[f.key()] = true
+	}
+	toCerts := map[string]bool{}
+	for _, f := range to.CertificatesNearExpiry {
+		toCerts[f.key()] = true
+		if !fromCerts[f.key()] {
+			cmp.CertificatesNearExpiry.Added = append(cmp.CertificatesNearExpiry.Added, f)
+		}
+	}
+	for _, f := range from.CertificatesNearExpiry {
+		if !toCerts[f.key()] {
+			cmp.CertificatesNearExpiry.Removed = append(cmp.CertificatesNearExpiry.Removed, f)
+		}
+	}
+	return &cmp
+}
+
+func diff(from, to []string) FindingsDiff {
+	var d FindingsDiff
+	fromSet := map[string]bool{}
+	for _, v := range from {
+		fromSet[v] = true
+	}
+	toSet := map[string]bool{}
+	for _, v := range to {
+		toSet[v] = true
+		if !fromSet[v] {
+			d.Added = append(d.Added, v)
+		}
+	}
+	for _, v := range from {
+		if !toSet[v] {
+			d.Removed = append(d.Removed, v)
+		}
+	}
+	return d
+}
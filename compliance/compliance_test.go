@@ -0,0 +1,133 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compliance
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestGenerate(c *check.C) {
+	role, err := permission.NewRole("admin", string(permission.CtxGlobal), "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("*")
+	c.Assert(err, check.IsNil)
+	admin := auth.User{Email: "admin@tsuru.io"}
+	err = admin.Create()
+	c.Assert(err, check.IsNil)
+	err = admin.AddRole(role.Name, "")
+	c.Assert(err, check.IsNil)
+	user := auth.User{Email: "user@tsuru.io"}
+	err = user.Create()
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool1", Provisioner: "fake"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool2", Provisioner: "fake", Public: true})
+	c.Assert(err, check.IsNil)
+	for _, a := range []app.App{
+		{Name: "checked", Pool: "pool2", Deploys: 1},
+		{Name: "unchecked", Pool: "pool2", Deploys: 1},
+		{Name: "undeployed", Pool: "pool2"},
+	} {
+		err = s.conn.Apps().Insert(a)
+		c.Assert(err, check.IsNil)
+	}
+	err = image.AppendAppImageName("checked", "tsuru/app-checked:v1")
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData("tsuru/app-checked:v1", map[string]interface{}{
+		"healthcheck": map[string]interface{}{"path": "/healthcheck"},
+	})
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName("unchecked", "tsuru/app-unchecked:v1")
+	c.Assert(err, check.IsNil)
+	report, err := Generate()
+	c.Assert(err, check.IsNil)
+	c.Assert(report.ID.Valid(), check.Equals, true)
+	c.Assert(report.AdminUsers, check.DeepEquals, []string{"admin@tsuru.io"})
+	c.Assert(report.AppsWithoutHealthcheck, check.DeepEquals, []string{"unchecked"})
+	c.Assert(report.CertificatesNearExpiry, check.DeepEquals, []CertificateFinding{})
+	c.Assert(report.PoolsWithoutConstraints, check.DeepEquals, []string{"pool1"})
+	dbReport, err := Get(report.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbReport.AdminUsers, check.DeepEquals, report.AdminUsers)
+	c.Assert(dbReport.PoolsWithoutConstraints, check.DeepEquals, report.PoolsWithoutConstraints)
+}
+
+func (s *S) TestGetNotFound(c *check.C) {
+	_, err := Get("invalid")
+	c.Assert(err, check.Equals, ErrReportNotFound)
+	_, err = Get(bson.NewObjectId().Hex())
+	c.Assert(err, check.Equals, ErrReportNotFound)
+}
+
+func (s *S) TestListAndLatest(c *check.C) {
+	_, err := Latest()
+	c.Assert(err, check.Equals, ErrReportNotFound)
+	base := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		now = func() time.Time { return base.Add(time.Duration(i) * time.Hour) }
+		_, err = Generate()
+		c.Assert(err, check.IsNil)
+	}
+	reports, err := List(2)
+	c.Assert(err, check.IsNil)
+	c.Assert(reports, check.HasLen, 2)
+	c.Assert(reports[0].CreatedAt.Equal(base.Add(2*time.Hour)), check.Equals, true)
+	c.Assert(reports[1].CreatedAt.Equal(base.Add(time.Hour)), check.Equals, true)
+	latest, err := Latest()
+	c.Assert(err, check.IsNil)
+	c.Assert(latest.ID, check.Equals, reports[0].ID)
+}
+
+func (s *S) TestCompare(c *check.C) {
+	expiry := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	from := &Report{
+		AdminUsers:              []string{"a@tsuru.io", "b@tsuru.io"},
+		AppsWithoutHealthcheck:  []string{"app1"},
+		CertificatesNearExpiry:  []CertificateFinding{{App: "app1", CName: "app1.io", NotAfter: expiry}},
+		PoolsWithoutConstraints: []string{"pool1"},
+	}
+	to := &Report{
+		AdminUsers:              []string{"b@tsuru.io", "c@tsuru.io"},
+		AppsWithoutHealthcheck:  []string{"app1"},
+		CertificatesNearExpiry:  []CertificateFinding{{App: "app2", CName: "app2.io", NotAfter: expiry}},
+		PoolsWithoutConstraints: []string{},
+	}
+	cmp := Compare(from, to)
+	c.Assert(cmp.AdminUsers, check.DeepEquals, FindingsDiff{Added: []string{"c@tsuru.io"}, Removed: []string{"a@tsuru.io"}})
+	c.Assert(cmp.AppsWithoutHealthcheck, check.DeepEquals, FindingsDiff{})
+	c.Assert(cmp.CertificatesNearExpiry, check.DeepEquals, CertificatesDiff{
+		Added:   []CertificateFinding{{App: "app2", CName: "app2.io", NotAfter: expiry}},
+		Removed: []CertificateFinding{{App: "app1", CName: "app1.io", NotAfter: expiry}},
+	})
+	c.Assert(cmp.PoolsWithoutConstraints, check.DeepEquals, FindingsDiff{Removed: []string{"pool1"}})
+}
+
+func (s *S) TestRunnerGenerateIfDue(c *check.C) {
+	base := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return base }
+	r := &runner{interval: time.Hour}
+	err := r.generateIfDue()
+	c.Assert(err, check.IsNil)
+	now = func() time.Time { return base.Add(30 * time.Minute) }
+	err = r.generateIfDue()
+	c.Assert(err, check.IsNil)
+	reports, err := List(0)
+	c.Assert(err, check.IsNil)
+	c.Assert(reports, check.HasLen, 1)
+	now = func() time.Time { return base.Add(time.Hour) }
+	err = r.generateIfDue()
+	c.Assert(err, check.IsNil)
+	reports, err = List(0)
+	c.Assert(err, check.IsNil)
+	c.Assert(reports, check.HasLen, 2)
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compliance
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/log"
+)
+
+const defaultInterval = 24 * time.Hour
+
+type runner struct {
+	interval time.Duration
+	done     chan bool
+}
+
+// Initialize starts generating reports in background, when enabled in the
+// compliance:enabled config.
+func Initialize() error {
+	enabled, _ := config.GetBool("compliance:enabled")
+	if !enabled {
+		return nil
+	}
+	interval, _ := config.GetInt("compliance:interval")
+	r := &runner{
+		interval: time.Duration(interval) * time.Second,
+		done:     make(chan bool),
+	}
+	if r.interval <= 0 {
+		r.interval = defaultInterval
+	}
+	shutdown.Register(r)
+	go r.run()
+	return nil
+}
+
+func (r *runner) run() {
+	check := r.interval
+	if check > time.Hour {
+		check = time.Hour
+	}
+	for {
+		err := r.generateIfDue()
+		if err != nil {
+			log.Errorf("[compliance] unable to generate report: %s", err)
+		}
+		select {
+		case <-r.done:
+			return
+		case <-time.After(check):
+		}
+	}
+}
+
+// generateIfDue generates a report when the latest one is older than the
+// interval, so restarting the API doesn't generate extra reports.
+func (r *runner) generateIfDue() error {
+	latest, err := Latest()
+	if err != nil && err != ErrReportNotFound {
+		return err
+	}
+	if latest != nil && now().Sub(latest.CreatedAt) < r.interval {
+		return nil
+	}
+	_, err = Generate()
+	return err
+}
+
+func (r *runner) Shutdown() {
+	r.done <- true
+}
+
+func (r *runner) String() string {
+	return "compliance reports runner"
+}
@@ -13,6 +13,7 @@ package db
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db/storage"
@@ -23,8 +24,14 @@ import (
 const (
 	DefaultDatabaseURL  = "127.0.0.1:27017"
 	DefaultDatabaseName = "tsuru"
+
+	EventsPartitionPrefix = "events_"
 )
 
+// eventsPartitionRegexp matches the name of events partitions, one per month,
+// like events_201710.
+var eventsPartitionRegexp = regexp.MustCompile("^" + EventsPartitionPrefix + `(\d{4}(0[1-9]|1[0-2]))$`)
+
 type Storage struct {
 	*storage.Storage
 }
@@ -123,7 +130,7 @@ func (s *Storage) Pools() *storage.Collection {
 
 // PoolsConstraints return the pool constraints collection.
 func (s *Storage) PoolsConstraints() *storage.Collection {
-	poolConstraintIndex := mgo.Index{Key: []string{"poolexpr", "field"}, Unique: true}
+	poolConstraintIndex := mgo.Index{Key: []string{"poolexpr", "field", "layer"}, Unique: true}
 	c := s.Collection("pool_constraints")
 	c.EnsureIndex(poolConstraintIndex)
 	return c
@@ -143,6 +150,12 @@ func (s *Storage) Tokens() *storage.Collection {
 	return coll
 }
 
+func (s *Storage) ImpersonationTokens() *storage.Collection {
+	coll := s.Collection("impersonation_tokens")
+	coll.EnsureIndex(mgo.Index{Key: []string{"actoremail"}})
+	return coll
+}
+
 func (s *Storage) PasswordTokens() *storage.Collection {
 	return s.Collection("password_tokens")
 }
@@ -156,6 +169,17 @@ func (s *Storage) Teams() *storage.Collection {
 	return s.Collection("teams")
 }
 
+// Organizations returns the organizations collection from MongoDB. Teams and
+// pools may belong to only one organization.
+func (s *Storage) Organizations() *storage.Collection {
+	teamsIndex := mgo.Index{Key: []string{"teams"}, Unique: true, Sparse: true}
+	poolsIndex := mgo.Index{Key: []string{"pools"}, Unique: true, Sparse: true}
+	c := s.Collection("organizations")
+	c.EnsureIndex(teamsIndex)
+	c.EnsureIndex(poolsIndex)
+	return c
+}
+
 // Quota returns the quota collection from MongoDB.
 func (s *Storage) Quota() *storage.Collection {
 	userIndex := mgo.Index{Key: []string{"owner"}, Unique: true}
@@ -222,13 +246,50 @@ func (s *Storage) Events() *storage.Collection {
 	ownerIndex := mgo.Index{Key: []string{"owner"}}
 	kindIndex := mgo.Index{Key: []string{"kind"}}
 	startTimeIndex := mgo.Index{Key: []string{"-starttime"}}
+	correlationIndex := mgo.Index{Key: []string{"correlationid"}, Sparse: true}
 	c := s.Collection("events")
 	c.EnsureIndex(ownerIndex)
 	c.EnsureIndex(kindIndex)
 	c.EnsureIndex(startTimeIndex)
+	c.EnsureIndex(correlationIndex)
+	return c
+}
+
+// EventsPartition returns the collection holding finished events for the
+// given partition. The name of the collection is "events_" followed by the
+// partition name.
+func (s *Storage) EventsPartition(partition string) *storage.Collection {
+	ownerIndex := mgo.Index{Key: []string{"owner"}}
+	kindIndex := mgo.Index{Key: []string{"kind"}}
+	startTimeIndex := mgo.Index{Key: []string{"-starttime"}}
+	uniqueIDIndex := mgo.Index{Key: []string{"uniqueid"}}
+	correlationIndex := mgo.Index{Key: []string{"correlationid"}, Sparse: true}
+	c := s.Collection(EventsPartitionPrefix + partition)
+	c.EnsureIndex(ownerIndex)
+	c.EnsureIndex(kindIndex)
+	c.EnsureIndex(startTimeIndex)
+	c.EnsureIndex(uniqueIDIndex)
+	c.EnsureIndex(correlationIndex)
 	return c
 }
 
+// EventsPartitions returns the name of all existing events partitions.
+// Collections starting with the partition prefix but not named after a month
+// are ignored.
+func (s *Storage) EventsPartitions() ([]string, error) {
+	names, err := s.Events().Database.CollectionNames()
+	if err != nil {
+		return nil, err
+	}
+	var partitions []string
+	for _, n := range names {
+		if m := eventsPartitionRegexp.FindStringSubmatch(n); m != nil {
+			partitions = append(partitions, m[1])
+		}
+	}
+	return partitions, nil
+}
+
 func (s *Storage) EventBlocks() *storage.Collection {
 	index := mgo.Index{Key: []string{"ownername", "kindname", "target"}}
 	startTimeIndex := mgo.Index{Key: []string{"-starttime"}}
@@ -238,6 +299,52 @@ func (s *Storage) EventBlocks() *storage.Collection {
 	return c
 }
 
+// EventRetentionRules returns the collection holding the retention rules of
+// events set through the API.
+func (s *Storage) EventRetentionRules() *storage.Collection {
+	return s.Collection("event_retention_rules")
+}
+
+// EventWebhooks returns the collection holding the webhooks notified of
+// finished events.
+func (s *Storage) EventWebhooks() *storage.Collection {
+	kindsIndex := mgo.Index{Key: []string{"filter.kinds"}}
+	targetTypeIndex := mgo.Index{Key: []string{"filter.targets.type"}}
+	c := s.Collection("event_webhooks")
+	c.EnsureIndex(kindsIndex)
+	c.EnsureIndex(targetTypeIndex)
+	return c
+}
+
+// EventWebhookDeliveries returns the collection holding the log of the
+// deliveries of events to webhooks.
+func (s *Storage) EventWebhookDeliveries() *storage.Collection {
+	webhookIndex := mgo.Index{Key: []string{"webhook", "-timestamp"}}
+	c := s.Collection("event_webhook_deliveries")
+	c.EnsureIndex(webhookIndex)
+	return c
+}
+
+// ArchivedEvents returns the collection holding events archived by the
+// retention rules. Archived events aren't listed with the other events.
+func (s *Storage) ArchivedEvents() *storage.Collection {
+	kindIndex := mgo.Index{Key: []string{"kind"}}
+	startTimeIndex := mgo.Index{Key: []string{"-starttime"}}
+	c := s.Collection("archived_events")
+	c.EnsureIndex(kindIndex)
+	c.EnsureIndex(startTimeIndex)
+	return c
+}
+
+// CNameVerifications returns the collection holding the DNS challenges used
+// to verify the ownership of app cnames.
+func (s *Storage) CNameVerifications() *storage.Collection {
+	index := mgo.Index{Key: []string{"cname", "app"}, Unique: true}
+	c := s.Collection("cname_verifications")
+	c.EnsureIndex(index)
+	return c
+}
+
 func (s *Storage) InstallHosts() *storage.Collection {
 	nameIndex := mgo.Index{Key: []string{"name"}, Unique: true}
 	c := s.Collection("install_hosts")
@@ -6,6 +6,7 @@ package db
 
 import (
 	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/tsuru/config"
@@ -211,3 +212,17 @@ func (s *S) TestInstallHosts(c *check.C) {
 	hostsc := strg.Collection("install_hosts")
 	c.Assert(hosts, check.DeepEquals, hostsc)
 }
+
+func (s *S) TestEventsPartitions(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	for _, name := range []string{"events_201710", "events_201801", "events_webhooks", "events_2017", "events_201713", "events_201710_old"} {
+		err = strg.Collection(name).Insert(map[string]string{"a": "b"})
+		c.Assert(err, check.IsNil)
+	}
+	partitions, err := strg.EventsPartitions()
+	c.Assert(err, check.IsNil)
+	sort.Strings(partitions)
+	c.Assert(partitions, check.DeepEquals, []string{"201710", "201801"})
+}
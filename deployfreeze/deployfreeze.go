@@ -0,0 +1,317 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package deployfreeze manages the installation-wide calendar of deploy
+// freezes, like holiday freezes. Deploys to the pools covered by an active
+// freeze are blocked, unless the app has an approved exception.
+package deployfreeze
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ExceptionStatus is the review status of an exception request.
+type ExceptionStatus string
+
+const (
+	ExceptionPending  = ExceptionStatus("pending")
+	ExceptionApproved = ExceptionStatus("approved")
+	ExceptionRejected = ExceptionStatus("rejected")
+)
+
+var (
+	ErrNameRequired             = errors.New("deploy freeze name is required")
+	ErrInvalidPeriod            = errors.New("invalid deploy freeze period: end must be after start")
+	ErrFreezeNotFound           = errors.New("deploy freeze not found")
+	ErrFreezeAlreadyExists      = errors.New("deploy freeze already exists")
+	ErrFreezeEnded              = errors.New("deploy freeze has already ended")
+	ErrExceptionNotFound        = errors.New("deploy freeze exception not found")
+	ErrExceptionAlreadyExists   = errors.New("app already has an exception request for this deploy freeze")
+	ErrExceptionAlreadyReviewed = errors.New("deploy freeze exception has already been reviewed")
+	ErrExceptionReasonRequired  = errors.New("reason is required to request a deploy freeze exception")
+)
+
+var now = time.Now
+
+// Freeze blocks deploys to the given pools from Start to End. A freeze without
+// pools applies to all pools.
+type Freeze struct {
+	Name      string    `bson:"_id" json:"name"`
+	Pools     []string  `json:"pools,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedBy string    `json:"createdBy"`
+}
+
+// Exception is a request to deploy an app during a freeze. Approved
+// exceptions allow deploys of the app until the end of the freeze.
+type Exception struct {
+	ID          bson.ObjectId   `bson:"_id" json:"id"`
+	Freeze      string          `json:"freeze"`
+	App         string          `json:"app"`
+	Reason      string          `json:"reason"`
+	Status      ExceptionStatus `json:"status"`
+	RequestedBy string          `json:"requestedBy"`
+	RequestedAt time.Time       `json:"requestedAt"`
+	ReviewedBy  string          `json:"reviewedBy,omitempty" bson:",omitempty"`
+	ReviewedAt  time.Time       `json:"reviewedAt,omitempty" bson:",omitempty"`
+}
+
+// FrozenError is returned when deploys of an app are blocked by a freeze.
+type FrozenError struct {
+	Freeze Freeze
+	App    string
+	Pool   string
+}
+
+func (e *FrozenError) Error() string {
+	msg := fmt.Sprintf("deploys to pool %q are frozen by %q until %s", e.Pool, e.Freeze.Name, e.Freeze.End.UTC().Format(time.RFC3339))
+	if e.Freeze.Reason != "" {
+		msg += " (" + e.Freeze.Reason + ")"
+	}
+	return msg + ", an approved exception is required to deploy app " + e.App
+}
+
+func freezesCollection(conn *db.Storage) *storage.Collection {
+	return conn.Collection("deploy_freezes")
+}
+
+func exceptionsCollection(conn *db.Storage) *storage.Collection {
+	coll := conn.Collection("deploy_freeze_exceptions")
+	coll.EnsureIndex(mgo.Index{Key: []string{"freeze", "app"}})
+	return coll
+}
+
+// IsActive returns whether the freeze is active at the given time.
+func (f *Freeze) IsActive(t time.Time) bool {
+	return !t.Before(f.Start) && t.Before(f.End)
+}
+
+// Covers returns whether the freeze applies to the given pool.
+func (f *Freeze) Covers(pool string) bool {
+	if len(f.Pools) == 0 {
+		return true
+	}
+	for _, p := range f.Pools {
+		if p == pool {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds a new freeze to the calendar.
+func Add(f Freeze) error {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" {
+		return ErrNameRequired
+	}
+	if !f.End.After(f.Start) {
+		return ErrInvalidPeriod
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = freezesCollection(conn).Insert(f)
+	if mgo.IsDup(err) {
+		return ErrFreezeAlreadyExists
+	}
+	return err
+}
+
+// Get returns the freeze with the given name.
+func Get(name string) (*Freeze, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var f Freeze
+	err = freezesCollection(conn).FindId(name).One(&f)
+	if err == mgo.ErrNotFound {
+		return nil, ErrFreezeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// List returns the freezes that have not ended, sorted by start.
+func List() ([]Freeze, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var freezes []Freeze
+	err = freezesCollection(conn).Find(bson.M{"end": bson.M{"$gt": now()}}).Sort("start", "_id").All(&freezes)
+	if err != nil {
+		return nil, err
+	}
+	return freezes, nil
+}
+
+// Remove removes the freeze and its exceptions from the calendar.
+func Remove(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = freezesCollection(conn).RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrFreezeNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = exceptionsCollection(conn).RemoveAll(bson.M{"freeze": name})
+	return err
+}
+
+// Check returns a *FrozenError when deploys of the app to the given pool are
+// blocked by an active freeze without an approved exception for the app.
+func Check(appName, pool string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	t := now()
+	var active []Freeze
+	err = freezesCollection(conn).Find(bson.M{
+		"start": bson.M{"$lte": t},
+		"end":   bson.M{"$gt": t},
+		"$or":   []bson.M{{"pools": pool}, {"pools": bson.M{"$size": 0}}, {"pools": nil}},
+	}).Sort("end").All(&active)
+	if err != nil {
+		return err
+	}
+	for _, f := range active {
+		n, err := exceptionsCollection(conn).Find(bson.M{
+			"freeze": f.Name,
+			"app":    appName,
+			"status": ExceptionApproved,
+		}).Count()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return &FrozenError{Freeze: f, App: appName, Pool: pool}
+		}
+	}
+	return nil
+}
+
+// RequestException requests an exception for deploying the app during the
+// freeze. Each app may have a single request for a freeze.
+func RequestException(freezeName, appName, reason, requestedBy string) (*Exception, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, ErrExceptionReasonRequired
+	}
+	f, err := Get(freezeName)
+	if err != nil {
+		return nil, err
+	}
+	if !now().Before(f.End) {
+		return nil, ErrFreezeEnded
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	coll := exceptionsCollection(conn)
+	n, err := coll.Find(bson.M{"freeze": freezeName, "app": appName}).Count()
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return nil, ErrExceptionAlreadyExists
+	}
+	e := Exception{
+		ID:          bson.NewObjectId(),
+		Freeze:      freezeName,
+		App:         appName,
+		Reason:      reason,
+		Status:      ExceptionPending,
+		RequestedBy: requestedBy,
+		RequestedAt: now(),
+	}
+	err = coll.Insert(e)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ListExceptions returns the exception requests of the freeze, oldest first.
+func ListExceptions(freezeName string) ([]Exception, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var exceptions []Exception
+	err = exceptionsCollection(conn).Find(bson.M{"freeze": freezeName}).Sort("requestedat").All(&exceptions)
+	if err != nil {
+		return nil, err
+	}
+	return exceptions, nil
+}
+
+// ReviewException approves or rejects a pending exception request of the
+// freeze.
+func ReviewException(freezeName, id string, approve bool, reviewedBy string) (*Exception, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrExceptionNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	coll := exceptionsCollection(conn)
+	var e Exception
+	err = coll.Find(bson.M{"_id": bson.ObjectIdHex(id), "freeze": freezeName}).One(&e)
+	if err == mgo.ErrNotFound {
+		return nil, ErrExceptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.Status != ExceptionPending {
+		return nil, ErrExceptionAlreadyReviewed
+	}
+	e.Status = ExceptionRejected
+	if approve {
+		e.Status = ExceptionApproved
+	}
+	e.ReviewedBy = reviewedBy
+	e.ReviewedAt = now()
+	err = coll.Update(bson.M{"_id": e.ID, "status": ExceptionPending}, bson.M{"$set": bson.M{
+		"status":     e.Status,
+		"reviewedby": e.ReviewedBy,
+		"reviewedat": e.ReviewedAt,
+	}})
+	if err == mgo.ErrNotFound {
+		return nil, ErrExceptionAlreadyReviewed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deployfreeze
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+var (
+	xmasStart = time.Date(2017, 12, 20, 0, 0, 0, 0, time.UTC)
+	xmasEnd   = time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+)
+
+func fakeNow(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+func (s *S) TestAddAndGet(c *check.C) {
+	err := Add(Freeze{Name: "xmas", Pools: []string{"prod"}, Start: xmasStart, End: xmasEnd, Reason: "holidays", CreatedBy: "admin@tsuru.io"})
+	c.Assert(err, check.IsNil)
+	f, err := Get("xmas")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Pools, check.DeepEquals, []string{"prod"})
+	c.Assert(f.Start.Equal(xmasStart), check.Equals, true)
+	c.Assert(f.End.Equal(xmasEnd), check.Equals, true)
+	c.Assert(f.CreatedBy, check.Equals, "admin@tsuru.io")
+	err = Add(Freeze{Name: "xmas", Start: xmasStart, End: xmasEnd})
+	c.Assert(err, check.Equals, ErrFreezeAlreadyExists)
+	_, err = Get("easter")
+	c.Assert(err, check.Equals, ErrFreezeNotFound)
+}
+
+func (s *S) TestAddInvalid(c *check.C) {
+	err := Add(Freeze{Name: " ", Start: xmasStart, End: xmasEnd})
+	c.Assert(err, check.Equals, ErrNameRequired)
+	err = Add(Freeze{Name: "xmas", Start: xmasEnd, End: xmasStart})
+	c.Assert(err, check.Equals, ErrInvalidPeriod)
+	err = Add(Freeze{Name: "xmas", Start: xmasStart, End: xmasStart})
+	c.Assert(err, check.Equals, ErrInvalidPeriod)
+}
+
+func (s *S) TestListSkipsEndedFreezes(c *check.C) {
+	now = fakeNow(xmasStart.Add(-time.Hour))
+	err := Add(Freeze{Name: "xmas", Start: xmasStart, End: xmasEnd})
+	c.Assert(err, check.IsNil)
+	err = Add(Freeze{Name: "black-friday", Start: xmasStart.Add(-30 * 24 * time.Hour), End: xmasStart.Add(-29 * 24 * time.Hour)})
+	c.Assert(err, check.IsNil)
+	err = Add(Freeze{Name: "migration", Start: xmasStart.Add(-2 * time.Hour), End: xmasStart.Add(time.Hour)})
+	c.Assert(err, check.IsNil)
+	freezes, err := List()
+	c.Assert(err, check.IsNil)
+	c.Assert(freezes, check.HasLen, 2)
+	c.Assert(freezes[0].Name, check.Equals, "migration")
+	c.Assert(freezes[1].Name, check.Equals, "xmas")
+}
+
+func (s *S) TestCheck(c *check.C) {
+	err := Add(Freeze{Name: "xmas", Pools: []string{"prod"}, Start: xmasStart, End: xmasEnd, Reason: "holidays"})
+	c.Assert(err, check.IsNil)
+	now = fakeNow(xmasStart.Add(-time.Minute))
+	c.Assert(Check("myapp", "prod"), check.IsNil)
+	now = fakeNow(xmasStart)
+	err = Check("myapp", "prod")
+	c.Assert(err, check.FitsTypeOf, &FrozenError{})
+	c.Assert(err, check.ErrorMatches, `deploys to pool "prod" are frozen by "xmas" until 2018-01-02T00:00:00Z \(holidays\), an approved exception is required to deploy app myapp`)
+	c.Assert(Check("myapp", "dev"), check.IsNil)
+	now = fakeNow(xmasEnd)
+	c.Assert(Check("myapp", "prod"), check.IsNil)
+}
+
+func (s *S) TestCheckFreezeWithoutPools(c *check.C) {
+	err := Add(Freeze{Name: "xmas", Start: xmasStart, End: xmasEnd})
+	c.Assert(err, check.IsNil)
+	now = fakeNow(xmasStart.Add(time.Hour))
+	c.Assert(Check("myapp", "dev"), check.FitsTypeOf, &FrozenError{})
+	c.Assert(Check("myapp", "prod"), check.FitsTypeOf, &FrozenError{})
+}
+
+func (s *S) TestCheckWithApprovedException(c *check.C) {
+	err := Add(Freeze{Name: "xmas", Start: xmasStart, End: xmasEnd})
+	c.Assert(err, check.IsNil)
+	now = fakeNow(xmasStart.Add(time.Hour))
+	e, err := RequestException("xmas", "myapp", "payment gateway fix", "dev@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(e.Status, check.Equals, ExceptionPending)
+	c.Assert(Check("myapp", "prod"), check.FitsTypeOf, &FrozenError{})
+	reviewed, err := ReviewException("xmas", e.ID.Hex(), true, "cto@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(reviewed.Status, check.Equals, ExceptionApproved)
+	c.Assert(reviewed.ReviewedBy, check.Equals, "cto@tsuru.io")
+	c.Assert(Check("myapp", "prod"), check.IsNil)
+	c.Assert(Check("otherapp", "prod"), check.FitsTypeOf, &FrozenError{})
+	_, err = ReviewException("xmas", e.ID.Hex(), false, "cto@tsuru.io")
+	c.Assert(err, check.Equals, ErrExceptionAlreadyReviewed)
+}
+
+func (s *S) TestCheckWithRejectedException(c *check.C) {
+	err := Add(Freeze{Name: "xmas", Start: xmasStart, End: xmasEnd})
+	c.Assert(err, check.IsNil)
+	now = fakeNow(xmasStart.Add(time.Hour))
+	e, err := RequestException("xmas", "myapp", "new feature", "dev@tsuru.io")
+	c.Assert(err, check.IsNil)
+	reviewed, err := ReviewException("xmas", e.ID.Hex(), false, "cto@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(reviewed.Status, check.Equals, ExceptionRejected)
+	c.Assert(Check("myapp", "prod"), check.FitsTypeOf, &FrozenError{})
+	_, err = RequestException("xmas", "myapp", "new feature, again", "dev@tsuru.io")
+	c.Assert(err, check.Equals, ErrExceptionAlreadyExists)
+}
+
+func (s *S) TestRequestExceptionInvalid(c *check.C) {
+	err := Add(Freeze{Name: "xmas", Start: xmasStart, End: xmasEnd})
+	c.Assert(err, check.IsNil)
+	_, err = RequestException("xmas", "myapp", " ", "dev@tsuru.io")
+	c.Assert(err, check.Equals, ErrExceptionReasonRequired)
+	_, err = RequestException("easter", "myapp", "fix", "dev@tsuru.io")
+	c.Assert(err, check.Equals, ErrFreezeNotFound)
+	now = fakeNow(xmasEnd)
+	_, err = RequestException("xmas", "myapp", "fix", "dev@tsuru.io")
+	c.Assert(err, check.Equals, ErrFreezeEnded)
+}
+
+func (s *S) TestReviewExceptionNotFound(c *check.C) {
+	_, err := ReviewException("xmas", "invalid", true, "cto@tsuru.io")
+	c.Assert(err, check.Equals, ErrExceptionNotFound)
+	_, err = ReviewException("xmas", "5a0b0b7a3f8a1b0001000000", true, "cto@tsuru.io")
+	c.Assert(err, check.Equals, ErrExceptionNotFound)
+}
+
+func (s *S) TestRemoveRemovesExceptions(c *check.C) {
+	err := Add(Freeze{Name: "xmas", Start: xmasStart, End: xmasEnd})
+	c.Assert(err, check.IsNil)
+	now = fakeNow(xmasStart)
+	_, err = RequestException("xmas", "myapp", "fix", "dev@tsuru.io")
+	c.Assert(err, check.IsNil)
+	err = Remove("xmas")
+	c.Assert(err, check.IsNil)
+	exceptions, err := ListExceptions("xmas")
+	c.Assert(err, check.IsNil)
+	c.Assert(exceptions, check.HasLen, 0)
+	err = Remove("xmas")
+	c.Assert(err, check.Equals, ErrFreezeNotFound)
+}
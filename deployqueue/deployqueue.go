@@ -0,0 +1,419 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package deployqueue limits the number of deploys running at the same time
+// across all API servers. When the limit is reached, new deploys wait in a
+// queue ordered by priority, so that critical deploys run before bulk
+// redeploys. Priorities are configured for apps or for all apps of a team.
+package deployqueue
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Priority is the priority of deploys of an app in the queue.
+type Priority string
+
+const (
+	PriorityCritical = Priority("critical")
+	PriorityNormal   = Priority("normal")
+	PriorityLow      = Priority("low")
+)
+
+var weights = map[Priority]int{
+	PriorityCritical: 2,
+	PriorityNormal:   1,
+	PriorityLow:      0,
+}
+
+var (
+	ErrInvalidPriority  = errors.New("invalid deploy priority, valid values are: critical, normal and low")
+	ErrPriorityNotFound = errors.New("deploy priority not found")
+)
+
+var (
+	pollInterval = time.Second
+	maxStale     = time.Minute
+)
+
+// runningSlotsID is the id of the document counting running deploys in the
+// deploy_slots collection.
+const runningSlotsID = "running"
+
+type priorityEntry struct {
+	ID       string `bson:"_id"`
+	Priority Priority
+}
+
+// Entry is a deploy in the queue. Position is the position of a waiting
+// deploy in the queue, starting at 1, and is zero for running deploys.
+type Entry struct {
+	ID       bson.ObjectId `bson:"_id" json:"id"`
+	App      string        `json:"app"`
+	Priority Priority      `json:"priority"`
+	Weight   int           `json:"-"`
+	Running  bool          `json:"running"`
+	Position int           `bson:"-" json:"position,omitempty"`
+	Created  time.Time     `json:"created"`
+	Updated  time.Time     `json:"-"`
+}
+
+func (p Priority) Validate() error {
+	if _, ok := weights[p]; !ok {
+		return ErrInvalidPriority
+	}
+	return nil
+}
+
+func appPriorityID(appName string) string {
+	return "app:" + appName
+}
+
+func teamPriorityID(teamName string) string {
+	return "team:" + teamName
+}
+
+func prioritiesCollection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("deploy_priorities"), nil
+}
+
+func queueCollection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("deploy_queue"), nil
+}
+
+func slotsCollection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("deploy_slots"), nil
+}
+
+func setPriority(id string, p Priority) error {
+	err := p.Validate()
+	if err != nil {
+		return err
+	}
+	coll, err := prioritiesCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	_, err = coll.UpsertId(id, priorityEntry{ID: id, Priority: p})
+	return err
+}
+
+func removePriority(id string) error {
+	coll, err := prioritiesCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return ErrPriorityNotFound
+	}
+	return err
+}
+
+// SetAppPriority sets the priority of deploys of the given app, overriding
+// the priority of its team.
+func SetAppPriority(appName string, p Priority) error {
+	return setPriority(appPriorityID(appName), p)
+}
+
+// SetTeamPriority sets the priority of deploys of apps owned by the given
+// team.
+func SetTeamPriority(teamName string, p Priority) error {
+	return setPriority(teamPriorityID(teamName), p)
+}
+
+// RemoveAppPriority removes the priority of the given app.
+func RemoveAppPriority(appName string) error {
+	return removePriority(appPriorityID(appName))
+}
+
+// RemoveTeamPriority removes the priority of the given team.
+func RemoveTeamPriority(teamName string) error {
+	return removePriority(teamPriorityID(teamName))
+}
+
+// PriorityFor returns the priority of the app, falling back to the priority
+// of its team owner and then to PriorityNormal.
+func PriorityFor(appName, teamName string) (Priority, error) {
+	coll, err := prioritiesCollection()
+	if err != nil {
+		return "", err
+	}
+	defer coll.Close()
+	for _, id := range []string{appPriorityID(appName), teamPriorityID(teamName)} {
+		var entry priorityEntry
+		err = coll.FindId(id).One(&entry)
+		if err == nil {
+			return entry.Priority, nil
+		}
+		if err != mgo.ErrNotFound {
+			return "", err
+		}
+	}
+	return PriorityNormal, nil
+}
+
+func maxConcurrent() int {
+	limit, _ := config.GetInt("deploy:max-concurrent")
+	return limit
+}
+
+// Wait blocks until the deploy of the app can run, reporting the position of
+// the deploy in the queue to w while it waits. The returned function must be
+// called once the deploy finishes, releasing its place to the next deploy in
+// the queue. Deploys never wait when deploy:max-concurrent is not set.
+func Wait(appName, teamName string, w io.Writer) (func(), error) {
+	if maxConcurrent() <= 0 {
+		return func() {}, nil
+	}
+	priority, err := PriorityFor(appName, teamName)
+	if err != nil {
+		return nil, err
+	}
+	coll, err := queueCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	now := time.Now().UTC()
+	entry := Entry{
+		ID:       bson.NewObjectId(),
+		App:      appName,
+		Priority: priority,
+		Weight:   weights[priority],
+		Created:  now,
+		Updated:  now,
+	}
+	err = coll.Insert(entry)
+	if err != nil {
+		return nil, err
+	}
+	lastPosition := 0
+	for {
+		var position int
+		position, err = tryStart(coll, &entry)
+		if err != nil {
+			coll.RemoveId(entry.ID)
+			return nil, err
+		}
+		if position == 0 {
+			break
+		}
+		if position != lastPosition && w != nil {
+			fmt.Fprintf(w, " ---> Deploy queued with %s priority, position %d in the queue\n", priority, position)
+		}
+		lastPosition = position
+		time.Sleep(pollInterval)
+		entry.Updated = time.Now().UTC()
+		_, err = coll.UpsertId(entry.ID, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keepRunning(entry), nil
+}
+
+// acquireSlot atomically increments the running deploys counter, unless it
+// already reached deploy:max-concurrent. It returns whether the slot was
+// taken. When the counter is full, the upsert conflicts with the existing
+// document, so concurrent API servers can never exceed the limit.
+func acquireSlot() (bool, error) {
+	coll, err := slotsCollection()
+	if err != nil {
+		return false, err
+	}
+	defer coll.Close()
+	_, err = coll.Find(bson.M{"_id": runningSlotsID, "count": bson.M{"$lt": maxConcurrent()}}).Apply(mgo.Change{
+		Update: bson.M{"$inc": bson.M{"count": 1}},
+		Upsert: true,
+	}, nil)
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// releaseSlots decrements the running deploys counter by n.
+func releaseSlots(n int) error {
+	coll, err := slotsCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.UpdateId(runningSlotsID, bson.M{"$inc": bson.M{"count": -n}})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// syncSlots sets the running deploys counter to the number of running
+// entries, fixing slots leaked by API servers that stopped before releasing
+// them.
+func syncSlots(coll *storage.Collection) error {
+	running, err := coll.Find(bson.M{"running": true}).Count()
+	if err != nil {
+		return err
+	}
+	slots, err := slotsCollection()
+	if err != nil {
+		return err
+	}
+	defer slots.Close()
+	_, err = slots.UpsertId(runningSlotsID, bson.M{"$set": bson.M{"count": running}})
+	return err
+}
+
+// removeStale removes entries that weren't refreshed recently and recomputes
+// the running deploys counter, releasing the slots of stale running deploys.
+func removeStale(coll *storage.Collection) error {
+	limit := time.Now().Add(-maxStale).UTC()
+	_, err := coll.RemoveAll(bson.M{"updated": bson.M{"$lt": limit}})
+	if err != nil {
+		return err
+	}
+	return syncSlots(coll)
+}
+
+// tryStart marks the entry as running when there's room for another deploy
+// and no other waiting deploy is ahead of it. It returns the position of the
+// entry in the queue, or zero when the entry was started. The room for the
+// deploy is taken with acquireSlot, so checking it and taking it is a single
+// atomic operation. The entry is marked as running before taking the slot,
+// so a concurrent syncSlots never counts fewer deploys than the running ones.
+func tryStart(coll *storage.Collection, entry *Entry) (int, error) {
+	err := removeStale(coll)
+	if err != nil {
+		return 0, err
+	}
+	ahead, err := coll.Find(bson.M{
+		"running": false,
+		"$or": []bson.M{
+			{"weight": bson.M{"$gt": entry.Weight}},
+			{"weight": entry.Weight, "_id": bson.M{"$lt": entry.ID}},
+		},
+	}).Count()
+	if err != nil {
+		return 0, err
+	}
+	if ahead > 0 {
+		return ahead + 1, nil
+	}
+	entry.Running = true
+	entry.Updated = time.Now().UTC()
+	_, err = coll.UpsertId(entry.ID, entry)
+	if err != nil {
+		entry.Running = false
+		return 0, err
+	}
+	acquired, err := acquireSlot()
+	if err == nil && acquired {
+		return 0, nil
+	}
+	entry.Running = false
+	updateErr := coll.UpdateId(entry.ID, bson.M{"$set": bson.M{"running": false}})
+	if err != nil {
+		return 0, err
+	}
+	if updateErr != nil {
+		return 0, updateErr
+	}
+	return 1, nil
+}
+
+// keepRunning refreshes the running entry until the returned function is
+// called, preventing it from being removed as stale during long deploys.
+func keepRunning(entry Entry) func() {
+	quit := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(maxStale / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+			coll, err := queueCollection()
+			if err != nil {
+				log.Errorf("[deploy queue] unable to refresh deploy of app %q: %s", entry.App, err)
+				continue
+			}
+			err = coll.UpdateId(entry.ID, bson.M{"$set": bson.M{"updated": time.Now().UTC()}})
+			coll.Close()
+			if err != nil {
+				log.Errorf("[deploy queue] unable to refresh deploy of app %q: %s", entry.App, err)
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		coll, err := queueCollection()
+		if err != nil {
+			log.Errorf("[deploy queue] unable to remove deploy of app %q: %s", entry.App, err)
+			return
+		}
+		defer coll.Close()
+		err = coll.RemoveId(entry.ID)
+		if err != nil {
+			// The entry was removed as stale, which already released its
+			// slot.
+			if err != mgo.ErrNotFound {
+				log.Errorf("[deploy queue] unable to remove deploy of app %q: %s", entry.App, err)
+			}
+			return
+		}
+		err = releaseSlots(1)
+		if err != nil {
+			log.Errorf("[deploy queue] unable to release slot of app %q: %s", entry.App, err)
+		}
+	}
+}
+
+// List returns the deploys in the queue: running deploys first, followed by
+// waiting deploys in the order they will run.
+func List() ([]Entry, error) {
+	coll, err := queueCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var entries []Entry
+	err = coll.Find(bson.M{"updated": bson.M{"$gte": time.Now().Add(-maxStale).UTC()}}).Sort("-running", "-weight", "_id").All(&entries)
+	if err != nil {
+		return nil, err
+	}
+	position := 1
+	for i := range entries {
+		if !entries[i].Running {
+			entries[i].Position = position
+			position++
+		}
+	}
+	return entries, nil
+}
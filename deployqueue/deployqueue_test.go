@@ -0,0 +1,250 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deployqueue
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestPriorityFor(c *check.C) {
+	p, err := PriorityFor("myapp", "myteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.Equals, PriorityNormal)
+	err = SetTeamPriority("myteam", PriorityLow)
+	c.Assert(err, check.IsNil)
+	p, err = PriorityFor("myapp", "myteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.Equals, PriorityLow)
+	err = SetAppPriority("myapp", PriorityCritical)
+	c.Assert(err, check.IsNil)
+	p, err = PriorityFor("myapp", "myteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.Equals, PriorityCritical)
+	err = RemoveAppPriority("myapp")
+	c.Assert(err, check.IsNil)
+	p, err = PriorityFor("myapp", "myteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.Equals, PriorityLow)
+	err = RemoveAppPriority("myapp")
+	c.Assert(err, check.Equals, ErrPriorityNotFound)
+}
+
+func (s *S) TestSetPriorityInvalid(c *check.C) {
+	err := SetAppPriority("myapp", Priority("urgent"))
+	c.Assert(err, check.Equals, ErrInvalidPriority)
+	err = SetTeamPriority("myteam", Priority(""))
+	c.Assert(err, check.Equals, ErrInvalidPriority)
+}
+
+func (s *S) TestWaitUnlimited(c *check.C) {
+	config.Unset("deploy:max-concurrent")
+	done, err := Wait("myapp", "myteam", nil)
+	c.Assert(err, check.IsNil)
+	defer done()
+	entries, err := List()
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 0)
+}
+
+func (s *S) TestWaitQueuesDeploys(c *check.C) {
+	done1, err := Wait("app1", "myteam", nil)
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	started := make(chan func())
+	go func() {
+		done2, waitErr := Wait("app2", "myteam", &buf)
+		c.Check(waitErr, check.IsNil)
+		started <- done2
+	}()
+	var entries []Entry
+	timeout := time.After(5 * time.Second)
+	for len(entries) < 2 {
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for deploy to be queued")
+		case <-time.After(10 * time.Millisecond):
+		}
+		entries, err = List()
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(entries[0].App, check.Equals, "app1")
+	c.Assert(entries[0].Running, check.Equals, true)
+	c.Assert(entries[0].Position, check.Equals, 0)
+	c.Assert(entries[1].App, check.Equals, "app2")
+	c.Assert(entries[1].Running, check.Equals, false)
+	c.Assert(entries[1].Position, check.Equals, 1)
+	done1()
+	select {
+	case done2 := <-started:
+		done2()
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for queued deploy to start")
+	}
+	c.Assert(buf.String(), check.Matches, `(?s).*Deploy queued with normal priority, position 1 in the queue.*`)
+	entries, err = List()
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 0)
+}
+
+func (s *S) TestListOrdersByPriority(c *check.C) {
+	coll, err := queueCollection()
+	c.Assert(err, check.IsNil)
+	defer coll.Close()
+	now := time.Now().UTC()
+	entries := []Entry{
+		{App: "running", Priority: PriorityLow, Running: true},
+		{App: "low", Priority: PriorityLow},
+		{App: "normal1", Priority: PriorityNormal},
+		{App: "critical", Priority: PriorityCritical},
+		{App: "normal2", Priority: PriorityNormal},
+		{App: "stale", Priority: PriorityCritical},
+	}
+	for i := range entries {
+		entries[i].ID = bson.NewObjectId()
+		entries[i].Weight = weights[entries[i].Priority]
+		entries[i].Created = now
+		entries[i].Updated = now
+		if entries[i].App == "stale" {
+			entries[i].Updated = now.Add(-2 * maxStale)
+		}
+		err = coll.Insert(entries[i])
+		c.Assert(err, check.IsNil)
+	}
+	acquired, err := acquireSlot()
+	c.Assert(err, check.IsNil)
+	c.Assert(acquired, check.Equals, true)
+	result, err := List()
+	c.Assert(err, check.IsNil)
+	var apps []string
+	var positions []int
+	for _, e := range result {
+		apps = append(apps, e.App)
+		positions = append(positions, e.Position)
+	}
+	c.Assert(apps, check.DeepEquals, []string{"running", "critical", "normal1", "normal2", "low"})
+	c.Assert(positions, check.DeepEquals, []int{0, 1, 2, 3, 4})
+	entry := entries[4]
+	position, err := tryStart(coll, &entry)
+	c.Assert(err, check.IsNil)
+	c.Assert(position, check.Equals, 3)
+	entry = entries[3]
+	position, err = tryStart(coll, &entry)
+	c.Assert(err, check.IsNil)
+	c.Assert(position, check.Equals, 1)
+	config.Set("deploy:max-concurrent", 2)
+	position, err = tryStart(coll, &entry)
+	c.Assert(err, check.IsNil)
+	c.Assert(position, check.Equals, 0)
+	c.Assert(entry.Running, check.Equals, true)
+}
+
+func (s *S) TestAcquireSlot(c *check.C) {
+	config.Set("deploy:max-concurrent", 2)
+	for i := 0; i < 2; i++ {
+		acquired, err := acquireSlot()
+		c.Assert(err, check.IsNil)
+		c.Assert(acquired, check.Equals, true)
+	}
+	acquired, err := acquireSlot()
+	c.Assert(err, check.IsNil)
+	c.Assert(acquired, check.Equals, false)
+	err = releaseSlots(1)
+	c.Assert(err, check.IsNil)
+	acquired, err = acquireSlot()
+	c.Assert(err, check.IsNil)
+	c.Assert(acquired, check.Equals, true)
+}
+
+func (s *S) TestAcquireSlotConcurrent(c *check.C) {
+	config.Set("deploy:max-concurrent", 3)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var acquiredCount int
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			acquired, err := acquireSlot()
+			c.Check(err, check.IsNil)
+			if acquired {
+				mu.Lock()
+				acquiredCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	c.Assert(acquiredCount, check.Equals, 3)
+}
+
+func (s *S) TestTryStartReleasesStaleSlots(c *check.C) {
+	coll, err := queueCollection()
+	c.Assert(err, check.IsNil)
+	defer coll.Close()
+	acquired, err := acquireSlot()
+	c.Assert(err, check.IsNil)
+	c.Assert(acquired, check.Equals, true)
+	now := time.Now().UTC()
+	stale := Entry{ID: bson.NewObjectId(), App: "stale", Running: true, Created: now, Updated: now.Add(-2 * maxStale)}
+	err = coll.Insert(stale)
+	c.Assert(err, check.IsNil)
+	entry := Entry{ID: bson.NewObjectId(), App: "myapp", Priority: PriorityNormal, Weight: weights[PriorityNormal], Created: now, Updated: now}
+	err = coll.Insert(entry)
+	c.Assert(err, check.IsNil)
+	position, err := tryStart(coll, &entry)
+	c.Assert(err, check.IsNil)
+	c.Assert(position, check.Equals, 0)
+	c.Assert(entry.Running, check.Equals, true)
+}
+
+func (s *S) TestTryStartRecomputesLeakedSlots(c *check.C) {
+	coll, err := queueCollection()
+	c.Assert(err, check.IsNil)
+	defer coll.Close()
+	slots, err := slotsCollection()
+	c.Assert(err, check.IsNil)
+	defer slots.Close()
+	_, err = slots.UpsertId(runningSlotsID, bson.M{"$set": bson.M{"count": 3}})
+	c.Assert(err, check.IsNil)
+	now := time.Now().UTC()
+	entry := Entry{ID: bson.NewObjectId(), App: "myapp", Priority: PriorityNormal, Weight: weights[PriorityNormal], Created: now, Updated: now}
+	err = coll.Insert(entry)
+	c.Assert(err, check.IsNil)
+	position, err := tryStart(coll, &entry)
+	c.Assert(err, check.IsNil)
+	c.Assert(position, check.Equals, 0)
+	c.Assert(entry.Running, check.Equals, true)
+	var result struct{ Count int }
+	err = slots.FindId(runningSlotsID).One(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Count, check.Equals, 1)
+}
+
+func (s *S) TestTryStartWithoutSlotKeepsWaiting(c *check.C) {
+	coll, err := queueCollection()
+	c.Assert(err, check.IsNil)
+	defer coll.Close()
+	now := time.Now().UTC()
+	running := Entry{ID: bson.NewObjectId(), App: "other", Running: true, Created: now, Updated: now}
+	err = coll.Insert(running)
+	c.Assert(err, check.IsNil)
+	entry := Entry{ID: bson.NewObjectId(), App: "myapp", Priority: PriorityNormal, Weight: weights[PriorityNormal], Created: now, Updated: now}
+	err = coll.Insert(entry)
+	c.Assert(err, check.IsNil)
+	position, err := tryStart(coll, &entry)
+	c.Assert(err, check.IsNil)
+	c.Assert(position, check.Equals, 1)
+	c.Assert(entry.Running, check.Equals, false)
+	var dbEntry Entry
+	err = coll.FindId(entry.ID).One(&dbEntry)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbEntry.Running, check.Equals, false)
+}
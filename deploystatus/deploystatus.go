@@ -0,0 +1,309 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package deploystatus reports the progress of deploys as commit statuses in
+// source code hosting services, like GitHub and GitLab. Statuses are only
+// reported for deploys carrying git metadata (the repository and the commit
+// being deployed), and for apps that, either directly or through their team
+// owner, have a provider configured.
+package deploystatus
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/log"
+	tsuruNet "github.com/tsuru/tsuru/net"
+	"gopkg.in/mgo.v2"
+)
+
+// Stage is a step in the lifecycle of a deploy.
+type Stage string
+
+const (
+	StageQueued     = Stage("queued")
+	StageBuilding   = Stage("building")
+	StageRolledOut  = Stage("rolled-out")
+	StageFailed     = Stage("failed")
+	StageRolledBack = Stage("rolled-back")
+)
+
+var descriptions = map[Stage]string{
+	StageQueued:     "Deploy queued",
+	StageBuilding:   "Deploy in progress",
+	StageRolledOut:  "Deploy rolled out",
+	StageFailed:     "Deploy failed",
+	StageRolledBack: "Deploy rolled back",
+}
+
+var (
+	ErrConfigNotFound  = errors.New("deploy status config not found")
+	ErrTokenRequired   = errors.New("a token is required to report deploy statuses")
+	ErrInvalidProvider = errors.New("invalid deploy status provider")
+)
+
+var (
+	// notifyTimeout is how long a status request may take.
+	notifyTimeout = 10 * time.Second
+	// notifyQueueSize is the number of notifications waiting to be sent
+	// by NotifyAsync, more notifications are dropped.
+	notifyQueueSize = 100
+	notifyQueue     chan notification
+	notifyOnce      sync.Once
+)
+
+var providers map[string]Provider
+
+// Status is a commit status sent to a provider.
+type Status struct {
+	Stage       Stage
+	Repository  string
+	Commit      string
+	Context     string
+	Description string
+	TargetURL   string
+}
+
+// Provider represents a source code hosting service able to store commit
+// statuses.
+type Provider interface {
+	SetStatus(cfg *Config, status Status) error
+}
+
+// Config is the configuration used to report statuses for an app or for all
+// apps of a team. URL is the base URL of the provider API, and may be empty
+// when the provider's public service is used. Token is stored encrypted and
+// is never serialized.
+type Config struct {
+	Provider  string `json:"provider"`
+	URL       string `json:"url,omitempty"`
+	Token     string `json:"-" bson:"-"`
+	TargetURL string `json:"targetURL,omitempty"`
+}
+
+type configEntry struct {
+	ID             string `bson:"_id"`
+	Config         `bson:",inline"`
+	EncryptedToken string
+}
+
+type notification struct {
+	deploy Deploy
+	stage  Stage
+}
+
+// Deploy holds the information of a deploy needed to report its status.
+type Deploy struct {
+	App        string
+	Team       string
+	Repository string
+	Commit     string
+}
+
+// Register registers a new provider, that can later be used in app and team
+// configs.
+func Register(name string, provider Provider) {
+	if providers == nil {
+		providers = make(map[string]Provider)
+	}
+	providers[name] = provider
+}
+
+func appConfigID(appName string) string {
+	return "app:" + appName
+}
+
+func teamConfigID(teamName string) string {
+	return "team:" + teamName
+}
+
+func collection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("deploy_status"), nil
+}
+
+func (c *Config) validate() error {
+	if _, ok := providers[c.Provider]; !ok {
+		return ErrInvalidProvider
+	}
+	if c.Token == "" {
+		return ErrTokenRequired
+	}
+	if c.URL != "" {
+		return tsuruNet.ValidateExternalURL(c.URL)
+	}
+	return nil
+}
+
+func setConfig(id string, cfg Config) error {
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+	coll, err := collection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	encrypted, err := encryptToken(cfg.Token)
+	if err != nil {
+		return err
+	}
+	_, err = coll.UpsertId(id, configEntry{ID: id, Config: cfg, EncryptedToken: encrypted})
+	return err
+}
+
+func removeConfig(id string) error {
+	coll, err := collection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return ErrConfigNotFound
+	}
+	return err
+}
+
+func findConfig(ids ...string) (*Config, error) {
+	coll, err := collection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	for _, id := range ids {
+		var entry configEntry
+		err = coll.FindId(id).One(&entry)
+		if err == nil {
+			entry.Config.Token, err = decryptToken(entry.EncryptedToken)
+			if err != nil {
+				return nil, err
+			}
+			return &entry.Config, nil
+		}
+		if err != mgo.ErrNotFound {
+			return nil, err
+		}
+	}
+	return nil, ErrConfigNotFound
+}
+
+// SetAppConfig stores the config used for reporting statuses of deploys of
+// the given app, overriding the config of its team.
+func SetAppConfig(appName string, cfg Config) error {
+	return setConfig(appConfigID(appName), cfg)
+}
+
+// SetTeamConfig stores the config used for reporting statuses of deploys of
+// apps owned by the given team.
+func SetTeamConfig(teamName string, cfg Config) error {
+	return setConfig(teamConfigID(teamName), cfg)
+}
+
+// RemoveAppConfig removes the config of the given app.
+func RemoveAppConfig(appName string) error {
+	return removeConfig(appConfigID(appName))
+}
+
+// RemoveTeamConfig removes the config of the given team.
+func RemoveTeamConfig(teamName string) error {
+	return removeConfig(teamConfigID(teamName))
+}
+
+// ConfigFor returns the config of the app, falling back to the config of its
+// team owner.
+func ConfigFor(appName, teamName string) (*Config, error) {
+	return findConfig(appConfigID(appName), teamConfigID(teamName))
+}
+
+// Notify reports the stage of the deploy to the provider configured for the
+// app. It's a no-op for deploys without git metadata or for apps without
+// config.
+func Notify(d Deploy, stage Stage) error {
+	if d.Repository == "" || d.Commit == "" {
+		return nil
+	}
+	cfg, err := ConfigFor(d.App, d.Team)
+	if err == ErrConfigNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	provider, ok := providers[cfg.Provider]
+	if !ok {
+		return ErrInvalidProvider
+	}
+	status := Status{
+		Stage:       stage,
+		Repository:  RepositoryPath(d.Repository),
+		Commit:      d.Commit,
+		Context:     "tsuru/" + d.App,
+		Description: descriptions[stage],
+		TargetURL:   strings.Replace(cfg.TargetURL, "{app}", d.App, -1),
+	}
+	err = provider.SetStatus(cfg, status)
+	if err != nil {
+		return errors.Wrapf(err, "unable to set %s status for commit %s in %s", cfg.Provider, d.Commit, status.Repository)
+	}
+	return nil
+}
+
+// NotifyAsync queues the notification of the stage of the deploy, like
+// Notify, without waiting for it. Notifications are sent in background in the
+// order they're queued, and failures are only logged. Notifications are
+// dropped when too many are waiting to be sent.
+func NotifyAsync(d Deploy, stage Stage) {
+	if d.Repository == "" || d.Commit == "" {
+		return
+	}
+	notifyOnce.Do(func() {
+		notifyQueue = make(chan notification, notifyQueueSize)
+		go sendNotifications()
+	})
+	select {
+	case notifyQueue <- notification{deploy: d, stage: stage}:
+	default:
+		log.Errorf("[deploy status] too many pending notifications, dropping %s notification for app %q", stage, d.App)
+	}
+}
+
+func sendNotifications() {
+	for n := range notifyQueue {
+		err := Notify(n.deploy, n.stage)
+		if err != nil {
+			log.Errorf("[deploy status] unable to notify %s for app %q: %s", n.stage, n.deploy.App, err)
+		}
+	}
+}
+
+// RepositoryPath returns the path of the repository in the provider (e.g.
+// owner/repo). It accepts both paths and clone URLs, like
+// https://github.com/owner/repo.git or git@github.com:owner/repo.git.
+func RepositoryPath(repository string) string {
+	repository = strings.TrimSuffix(repository, ".git")
+	if u, err := url.Parse(repository); err == nil && u.Host != "" {
+		repository = u.Path
+	} else if idx := strings.Index(repository, ":"); idx >= 0 && strings.Contains(repository[:idx], "@") {
+		repository = repository[idx+1:]
+	}
+	return strings.Trim(repository, "/")
+}
+
+func statusURL(base, defaultBase, format string, args ...interface{}) string {
+	if base == "" {
+		base = defaultBase
+	}
+	return strings.TrimRight(base, "/") + fmt.Sprintf(format, args...)
+}
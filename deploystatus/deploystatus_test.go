@@ -0,0 +1,245 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deploystatus
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	tsuruNet "github.com/tsuru/tsuru/net"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type fakeProvider struct {
+	mu       sync.Mutex
+	statuses []Status
+	err      error
+}
+
+func (p *fakeProvider) SetStatus(cfg *Config, status Status) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statuses = append(p.statuses, status)
+	return p.err
+}
+
+type recordedRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+func recordingServer(reqs *[]recordedRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*reqs = append(*reqs, recordedRequest{path: r.URL.EscapedPath(), header: r.Header, body: body})
+		w.WriteHeader(http.StatusCreated)
+	}))
+}
+
+func (s *S) TestSetConfigValidation(c *check.C) {
+	err := SetAppConfig("myapp", Config{Provider: "unknown", Token: "abc"})
+	c.Assert(err, check.Equals, ErrInvalidProvider)
+	err = SetAppConfig("myapp", Config{Provider: "github"})
+	c.Assert(err, check.Equals, ErrTokenRequired)
+	err = SetAppConfig("myapp", Config{Provider: "github", Token: "abc", URL: "github.example.com"})
+	c.Assert(err, check.Equals, tsuruNet.ErrInvalidExternalURL)
+	err = SetAppConfig("myapp", Config{Provider: "github", Token: "abc", URL: "http://10.0.0.1/api/v3"})
+	c.Assert(err, check.ErrorMatches, "10.0.0.1 is an internal address")
+}
+
+func (s *S) TestSetConfigEncryptsToken(c *check.C) {
+	err := SetAppConfig("myapp", Config{Provider: "github", Token: "my-token"})
+	c.Assert(err, check.IsNil)
+	var entry bson.M
+	err = s.conn.Collection("deploy_status").FindId(appConfigID("myapp")).One(&entry)
+	c.Assert(err, check.IsNil)
+	c.Assert(entry["token"], check.IsNil)
+	c.Assert(entry["encryptedtoken"], check.Not(check.Equals), "")
+	c.Assert(entry["encryptedtoken"], check.Not(check.Matches), ".*my-token.*")
+	cfg, err := ConfigFor("myapp", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.Token, check.Equals, "my-token")
+	config.Set("deploy-status:token-key", "another-key")
+	defer config.Set("deploy-status:token-key", "deploystatus-tests")
+	_, err = ConfigFor("myapp", "")
+	c.Assert(err, check.Equals, ErrInvalidToken)
+}
+
+func (s *S) TestSetConfigRequiresTokenKey(c *check.C) {
+	config.Unset("deploy-status:token-key")
+	defer config.Set("deploy-status:token-key", "deploystatus-tests")
+	err := SetAppConfig("myapp", Config{Provider: "github", Token: "my-token"})
+	c.Assert(err, check.Equals, ErrTokenKeyRequired)
+}
+
+func (s *S) TestConfigForFallsBackToTeam(c *check.C) {
+	_, err := ConfigFor("myapp", "myteam")
+	c.Assert(err, check.Equals, ErrConfigNotFound)
+	err = SetTeamConfig("myteam", Config{Provider: "gitlab", Token: "team-token"})
+	c.Assert(err, check.IsNil)
+	cfg, err := ConfigFor("myapp", "myteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg, check.DeepEquals, &Config{Provider: "gitlab", Token: "team-token"})
+	err = SetAppConfig("myapp", Config{Provider: "github", Token: "app-token"})
+	c.Assert(err, check.IsNil)
+	cfg, err = ConfigFor("myapp", "myteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg, check.DeepEquals, &Config{Provider: "github", Token: "app-token"})
+	err = RemoveAppConfig("myapp")
+	c.Assert(err, check.IsNil)
+	err = RemoveAppConfig("myapp")
+	c.Assert(err, check.Equals, ErrConfigNotFound)
+	cfg, err = ConfigFor("myapp", "myteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(cfg.Provider, check.Equals, "gitlab")
+}
+
+func (s *S) TestNotify(c *check.C) {
+	fake := &fakeProvider{}
+	Register("fake", fake)
+	defer delete(providers, "fake")
+	err := SetTeamConfig("myteam", Config{Provider: "fake", Token: "abc", TargetURL: "https://tsuru.example.com/apps/{app}"})
+	c.Assert(err, check.IsNil)
+	d := Deploy{App: "myapp", Team: "myteam", Repository: "https://github.com/tsuru/tsuru.git", Commit: "f1a2b3"}
+	err = Notify(d, StageBuilding)
+	c.Assert(err, check.IsNil)
+	c.Assert(fake.statuses, check.DeepEquals, []Status{{
+		Stage:       StageBuilding,
+		Repository:  "tsuru/tsuru",
+		Commit:      "f1a2b3",
+		Context:     "tsuru/myapp",
+		Description: "Deploy in progress",
+		TargetURL:   "https://tsuru.example.com/apps/myapp",
+	}})
+	fake.err = errors.New("my error")
+	err = Notify(d, StageFailed)
+	c.Assert(err, check.ErrorMatches, "unable to set fake status for commit f1a2b3 in tsuru/tsuru: my error")
+}
+
+func (s *S) TestNotifyAsync(c *check.C) {
+	fake := &fakeProvider{}
+	Register("fake", fake)
+	defer delete(providers, "fake")
+	err := SetAppConfig("myapp", Config{Provider: "fake", Token: "abc"})
+	c.Assert(err, check.IsNil)
+	d := Deploy{App: "myapp", Team: "myteam", Repository: "tsuru/tsuru", Commit: "f1a2b3"}
+	NotifyAsync(d, StageQueued)
+	NotifyAsync(d, StageBuilding)
+	NotifyAsync(d, StageRolledOut)
+	var stages []Stage
+	timeout := time.After(5 * time.Second)
+	for len(stages) < 3 {
+		select {
+		case <-timeout:
+			c.Fatalf("timeout waiting for statuses, got %v", stages)
+		case <-time.After(10 * time.Millisecond):
+		}
+		fake.mu.Lock()
+		stages = nil
+		for _, st := range fake.statuses {
+			stages = append(stages, st.Stage)
+		}
+		fake.mu.Unlock()
+	}
+	c.Assert(stages, check.DeepEquals, []Stage{StageQueued, StageBuilding, StageRolledOut})
+}
+
+func (s *S) TestNotifyWithoutGitMetadataOrConfig(c *check.C) {
+	fake := &fakeProvider{}
+	Register("fake", fake)
+	defer delete(providers, "fake")
+	err := Notify(Deploy{App: "myapp", Team: "myteam", Repository: "tsuru/tsuru", Commit: "f1a2b3"}, StageQueued)
+	c.Assert(err, check.IsNil)
+	err = SetAppConfig("myapp", Config{Provider: "fake", Token: "abc"})
+	c.Assert(err, check.IsNil)
+	err = Notify(Deploy{App: "myapp", Team: "myteam", Commit: "f1a2b3"}, StageQueued)
+	c.Assert(err, check.IsNil)
+	c.Assert(fake.statuses, check.HasLen, 0)
+}
+
+func (s *S) TestRepositoryPath(c *check.C) {
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"tsuru/tsuru", "tsuru/tsuru"},
+		{"https://github.com/tsuru/tsuru.git", "tsuru/tsuru"},
+		{"https://gitlab.example.com/group/sub/project", "group/sub/project"},
+		{"git@github.com:tsuru/tsuru.git", "tsuru/tsuru"},
+		{"/tsuru/tsuru/", "tsuru/tsuru"},
+	}
+	for _, t := range tests {
+		c.Check(RepositoryPath(t.input), check.Equals, t.expected)
+	}
+}
+
+func (s *S) TestGithubSetStatus(c *check.C) {
+	var reqs []recordedRequest
+	srv := recordingServer(&reqs)
+	defer srv.Close()
+	cfg := &Config{Provider: "github", URL: srv.URL, Token: "secret"}
+	err := providers["github"].SetStatus(cfg, Status{
+		Stage:       StageRolledBack,
+		Repository:  "tsuru/tsuru",
+		Commit:      "f1a2b3",
+		Context:     "tsuru/myapp",
+		Description: "Deploy rolled back",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(reqs, check.HasLen, 1)
+	c.Assert(reqs[0].path, check.Equals, "/repos/tsuru/tsuru/statuses/f1a2b3")
+	c.Assert(reqs[0].header.Get("Authorization"), check.Equals, "token secret")
+	var body map[string]string
+	err = json.Unmarshal(reqs[0].body, &body)
+	c.Assert(err, check.IsNil)
+	c.Assert(body, check.DeepEquals, map[string]string{
+		"state":       "error",
+		"context":     "tsuru/myapp",
+		"description": "Deploy rolled back",
+		"target_url":  "",
+	})
+}
+
+func (s *S) TestGitlabSetStatus(c *check.C) {
+	var reqs []recordedRequest
+	srv := recordingServer(&reqs)
+	defer srv.Close()
+	cfg := &Config{Provider: "gitlab", URL: srv.URL + "/api/v4", Token: "secret"}
+	err := providers["gitlab"].SetStatus(cfg, Status{
+		Stage:       StageBuilding,
+		Repository:  "group/project",
+		Commit:      "f1a2b3",
+		Context:     "tsuru/myapp",
+		Description: "Deploy in progress",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(reqs, check.HasLen, 1)
+	c.Assert(reqs[0].path, check.Equals, "/api/v4/projects/group%2Fproject/statuses/f1a2b3")
+	c.Assert(reqs[0].header.Get("PRIVATE-TOKEN"), check.Equals, "secret")
+	values, err := url.ParseQuery(string(reqs[0].body))
+	c.Assert(err, check.IsNil)
+	c.Assert(values.Get("state"), check.Equals, "running")
+	c.Assert(values.Get("name"), check.Equals, "tsuru/myapp")
+}
+
+func (s *S) TestSetStatusInvalidResponse(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("bad credentials"))
+	}))
+	defer srv.Close()
+	cfg := &Config{Provider: "github", URL: srv.URL, Token: "secret"}
+	err := providers["github"].SetStatus(cfg, Status{Stage: StageQueued, Repository: "a/b", Commit: "c"})
+	c.Assert(err, check.ErrorMatches, "invalid response code 401: bad credentials")
+}
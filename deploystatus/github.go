@@ -0,0 +1,71 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deploystatus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+const githubAPIURL = "https://api.github.com"
+
+var githubStates = map[Stage]string{
+	StageQueued:     "pending",
+	StageBuilding:   "pending",
+	StageRolledOut:  "success",
+	StageFailed:     "failure",
+	StageRolledBack: "error",
+}
+
+func init() {
+	Register("github", &githubProvider{})
+}
+
+type githubProvider struct{}
+
+func (p *githubProvider) SetStatus(cfg *Config, status Status) error {
+	body, err := json.Marshal(map[string]string{
+		"state":       githubStates[status.Stage],
+		"context":     status.Context,
+		"description": status.Description,
+		"target_url":  status.TargetURL,
+	})
+	if err != nil {
+		return err
+	}
+	u := statusURL(cfg.URL, githubAPIURL, "/repos/%s/statuses/%s", status.Repository, status.Commit)
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "token "+cfg.Token)
+	return doStatusRequest(req)
+}
+
+// statusClient is used for status requests, the URLs of the providers are
+// given by users.
+var statusClient = tsuruNet.ExternalClient
+
+func doStatusRequest(req *http.Request) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	rsp, err := statusClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(rsp.Body)
+		return errors.Errorf("invalid response code %d: %s", rsp.StatusCode, data)
+	}
+	return nil
+}
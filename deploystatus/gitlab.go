@@ -0,0 +1,45 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deploystatus
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const gitlabAPIURL = "https://gitlab.com/api/v4"
+
+var gitlabStates = map[Stage]string{
+	StageQueued:     "pending",
+	StageBuilding:   "running",
+	StageRolledOut:  "success",
+	StageFailed:     "failed",
+	StageRolledBack: "canceled",
+}
+
+func init() {
+	Register("gitlab", &gitlabProvider{})
+}
+
+type gitlabProvider struct{}
+
+func (p *gitlabProvider) SetStatus(cfg *Config, status Status) error {
+	params := url.Values{}
+	params.Set("state", gitlabStates[status.Stage])
+	params.Set("name", status.Context)
+	params.Set("description", status.Description)
+	if status.TargetURL != "" {
+		params.Set("target_url", status.TargetURL)
+	}
+	u := statusURL(cfg.URL, gitlabAPIURL, "/projects/%s/statuses/%s", url.QueryEscape(status.Repository), status.Commit)
+	req, err := http.NewRequest("POST", u, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("PRIVATE-TOKEN", cfg.Token)
+	return doStatusRequest(req)
+}
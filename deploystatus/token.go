@@ -0,0 +1,67 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deploystatus
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+var (
+	ErrTokenKeyRequired = errors.New("deploy-status:token-key must be set to store deploy status tokens")
+	ErrInvalidToken     = errors.New("unable to decrypt deploy status token, deploy-status:token-key may have changed")
+)
+
+// tokenCipher returns the AES-GCM cipher used for the provider tokens, whose
+// key is derived from the deploy-status:token-key setting.
+func tokenCipher() (cipher.AEAD, error) {
+	secret, _ := config.GetString("deploy-status:token-key")
+	if secret == "" {
+		return nil, ErrTokenKeyRequired
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptToken(token string) (string, error) {
+	aead, err := tokenCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(token), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptToken(encrypted string) (string, error) {
+	aead, err := tokenCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", ErrInvalidToken
+	}
+	token, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	return string(token), nil
+}
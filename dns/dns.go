@@ -0,0 +1,135 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dns provides an extension point for publishing internal DNS
+// records for apps, allowing apps to discover each other without relying on
+// router addresses. For each app, tsuru publishes one record with all the
+// available units of the app (e.g. myapp.tsuru.internal) and one record per
+// process (e.g. web.myapp.tsuru.internal).
+package dns
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/provision"
+)
+
+const (
+	defaultBackend = "nop"
+	defaultDomain  = "tsuru.internal"
+)
+
+var backends map[string]Backend
+
+// Record is a DNS name and the addresses, in the form host:port, it points
+// to. Backends may publish A records using the hosts and SRV records using
+// both hosts and ports.
+type Record struct {
+	Name      string
+	Addresses []string
+}
+
+// Backend represents a DNS server, or a service backing a DNS server, able
+// to store records.
+type Backend interface {
+	// SetRecords replaces all the records of the given app.
+	SetRecords(appName string, records []Record) error
+	// RemoveRecords removes all the records of the given app.
+	RemoveRecords(appName string) error
+}
+
+// App is an app whose units are published in DNS records.
+type App interface {
+	GetName() string
+	Units() ([]provision.Unit, error)
+}
+
+// Register registers a new DNS backend, that can be later configured and
+// used.
+func Register(name string, backend Backend) {
+	if backends == nil {
+		backends = make(map[string]Backend)
+	}
+	backends[name] = backend
+}
+
+// GetBackend returns the current configured backend, as defined in the
+// configuration file.
+func GetBackend() Backend {
+	name, err := config.GetString("dns:backend")
+	if err != nil {
+		name = defaultBackend
+	}
+	if _, ok := backends[name]; !ok {
+		name = defaultBackend
+	}
+	return backends[name]
+}
+
+// Domain returns the domain used as suffix for all records.
+func Domain() string {
+	domain, _ := config.GetString("dns:domain")
+	if domain == "" {
+		domain = defaultDomain
+	}
+	return domain
+}
+
+// AppRecords returns the records that must be published for the given app,
+// sorted by name. Units that are not available are not included.
+func AppRecords(app App) ([]Record, error) {
+	units, err := app.Units()
+	if err != nil {
+		return nil, err
+	}
+	domain := Domain()
+	appName := fmt.Sprintf("%s.%s", app.GetName(), domain)
+	recordsMap := map[string]*Record{appName: {Name: appName}}
+	for _, u := range units {
+		if !u.Available() || u.Address == nil {
+			continue
+		}
+		names := []string{appName}
+		if u.ProcessName != "" {
+			names = append(names, fmt.Sprintf("%s.%s", u.ProcessName, appName))
+		}
+		for _, name := range names {
+			if recordsMap[name] == nil {
+				recordsMap[name] = &Record{Name: name}
+			}
+			recordsMap[name].Addresses = append(recordsMap[name].Addresses, u.Address.Host)
+		}
+	}
+	records := make([]Record, 0, len(recordsMap))
+	for _, r := range recordsMap {
+		sort.Strings(r.Addresses)
+		records = append(records, *r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Name < records[j].Name
+	})
+	return records, nil
+}
+
+// SyncApp publishes the current records of the app in the configured
+// backend.
+func SyncApp(app App) error {
+	records, err := AppRecords(app)
+	if err != nil {
+		return errors.Wrapf(err, "unable to list units for app %q", app.GetName())
+	}
+	err = GetBackend().SetRecords(app.GetName(), records)
+	if err != nil {
+		return errors.Wrapf(err, "unable to set dns records for app %q", app.GetName())
+	}
+	return nil
+}
+
+// RemoveApp removes all records of the app from the configured backend.
+func RemoveApp(appName string) error {
+	return GetBackend().RemoveRecords(appName)
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+type fakeApp struct {
+	name  string
+	units []provision.Unit
+	err   error
+}
+
+func (a *fakeApp) GetName() string {
+	return a.name
+}
+
+func (a *fakeApp) Units() ([]provision.Unit, error) {
+	return a.units, a.err
+}
+
+type recordingBackend struct {
+	records map[string][]Record
+}
+
+func (b *recordingBackend) SetRecords(appName string, records []Record) error {
+	b.records[appName] = records
+	return nil
+}
+
+func (b *recordingBackend) RemoveRecords(appName string) error {
+	delete(b.records, appName)
+	return nil
+}
+
+func (s *S) TestGetBackendDefault(c *check.C) {
+	c.Assert(GetBackend(), check.Equals, nopBackend{})
+	config.Set("dns:backend", "unknown")
+	defer config.Unset("dns:backend")
+	c.Assert(GetBackend(), check.Equals, nopBackend{})
+}
+
+func (s *S) TestDomain(c *check.C) {
+	c.Assert(Domain(), check.Equals, "tsuru.internal")
+	config.Set("dns:domain", "apps.local")
+	defer config.Unset("dns:domain")
+	c.Assert(Domain(), check.Equals, "apps.local")
+}
+
+func (s *S) TestAppRecords(c *check.C) {
+	app := &fakeApp{name: "myapp", units: []provision.Unit{
+		{ProcessName: "web", Status: provision.StatusStarted, Address: &url.URL{Host: "10.0.0.2:8080"}},
+		{ProcessName: "web", Status: provision.StatusStarted, Address: &url.URL{Host: "10.0.0.1:8080"}},
+		{ProcessName: "worker", Status: provision.StatusStarting, Address: &url.URL{Host: "10.0.0.3:8080"}},
+		{ProcessName: "worker", Status: provision.StatusStopped, Address: &url.URL{Host: "10.0.0.4:8080"}},
+	}}
+	records, err := AppRecords(app)
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.DeepEquals, []Record{
+		{Name: "myapp.tsuru.internal", Addresses: []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080"}},
+		{Name: "web.myapp.tsuru.internal", Addresses: []string{"10.0.0.1:8080", "10.0.0.2:8080"}},
+		{Name: "worker.myapp.tsuru.internal", Addresses: []string{"10.0.0.3:8080"}},
+	})
+}
+
+func (s *S) TestAppRecordsNoUnits(c *check.C) {
+	records, err := AppRecords(&fakeApp{name: "myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.DeepEquals, []Record{{Name: "myapp.tsuru.internal"}})
+}
+
+func (s *S) TestSyncApp(c *check.C) {
+	backend := &recordingBackend{records: map[string][]Record{}}
+	Register("recording", backend)
+	defer delete(backends, "recording")
+	config.Set("dns:backend", "recording")
+	defer config.Unset("dns:backend")
+	app := &fakeApp{name: "myapp", units: []provision.Unit{
+		{ProcessName: "web", Status: provision.StatusStarted, Address: &url.URL{Host: "10.0.0.1:8080"}},
+	}}
+	err := SyncApp(app)
+	c.Assert(err, check.IsNil)
+	c.Assert(backend.records["myapp"], check.DeepEquals, []Record{
+		{Name: "myapp.tsuru.internal", Addresses: []string{"10.0.0.1:8080"}},
+		{Name: "web.myapp.tsuru.internal", Addresses: []string{"10.0.0.1:8080"}},
+	})
+	err = RemoveApp("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(backend.records, check.HasLen, 0)
+}
+
+func (s *S) TestSyncAppUnitsError(c *check.C) {
+	err := SyncApp(&fakeApp{name: "myapp", err: errors.New("my error")})
+	c.Assert(err, check.ErrorMatches, `unable to list units for app "myapp": my error`)
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dnstest provides a fake DNS backend for use in tests.
+//
+// Users can use the fake backend by just importing this package and setting
+// the "dns:backend" setting to "fake".
+package dnstest
+
+import (
+	"sync"
+
+	"github.com/tsuru/tsuru/dns"
+)
+
+func init() {
+	dns.Register("fake", &backend)
+}
+
+var backend = fakeBackend{records: make(map[string][]dns.Record)}
+
+type fakeBackend struct {
+	mu      sync.Mutex
+	records map[string][]dns.Record
+	err     error
+}
+
+func (b *fakeBackend) SetRecords(appName string, records []dns.Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.records[appName] = records
+	return nil
+}
+
+func (b *fakeBackend) RemoveRecords(appName string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.records, appName)
+	return nil
+}
+
+// Records returns the records stored by the fake backend for the given app.
+func Records(appName string) []dns.Record {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	return backend.records[appName]
+}
+
+// FailSetRecords makes the fake backend return err when setting records,
+// until Reset is called.
+func FailSetRecords(err error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.err = err
+}
+
+// Reset removes all records stored in the fake backend.
+func Reset() {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.records = make(map[string][]dns.Record)
+	backend.err = nil
+}
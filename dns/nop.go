@@ -0,0 +1,19 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+func init() {
+	Register("nop", nopBackend{})
+}
+
+type nopBackend struct{}
+
+func (nopBackend) SetRecords(appName string, records []Record) error {
+	return nil
+}
+
+func (nopBackend) RemoveRecords(appName string) error {
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+const (
+	defaultPowerDNSServer = "localhost"
+	defaultPowerDNSTTL    = 30
+)
+
+func init() {
+	Register("powerdns", powerDNSBackend{})
+}
+
+// powerDNSBackend publishes A records in a zone of a PowerDNS authoritative
+// server, using its HTTP API. The zone is the configured domain and must
+// already exist in the server. Only addresses with IP hosts are published,
+// with one A record per distinct IP.
+type powerDNSBackend struct{}
+
+type powerDNSRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type powerDNSRRSet struct {
+	Name       string           `json:"name"`
+	Type       string           `json:"type"`
+	TTL        int              `json:"ttl,omitempty"`
+	ChangeType string           `json:"changetype,omitempty"`
+	Records    []powerDNSRecord `json:"records"`
+}
+
+type powerDNSZone struct {
+	RRSets []powerDNSRRSet `json:"rrsets"`
+}
+
+func canonicalName(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+func powerDNSZoneURL() (string, error) {
+	apiURL, err := config.GetString("dns:powerdns:url")
+	if err != nil || apiURL == "" {
+		return "", errors.New("dns:powerdns:url is required by the powerdns dns backend")
+	}
+	server, _ := config.GetString("dns:powerdns:server-id")
+	if server == "" {
+		server = defaultPowerDNSServer
+	}
+	return fmt.Sprintf("%s/api/v1/servers/%s/zones/%s", strings.TrimSuffix(apiURL, "/"), server, canonicalName(Domain())), nil
+}
+
+func (powerDNSBackend) do(method string, body interface{}, result interface{}) error {
+	zoneURL, err := powerDNSZoneURL()
+	if err != nil {
+		return err
+	}
+	var reqBody bytes.Buffer
+	if body != nil {
+		err = json.NewEncoder(&reqBody).Encode(body)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, zoneURL, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key, _ := config.GetString("dns:powerdns:api-key"); key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	resp, err := tsuruNet.Dial5Full60ClientNoKeepAlive.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "unable to reach powerdns")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("powerdns returned status %d: %s", resp.StatusCode, data)
+	}
+	if result != nil {
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
+
+// appRRSetNames returns the names of the A records of the app stored in the
+// zone, i.e. the app record and its process records.
+func (b powerDNSBackend) appRRSetNames(appName string) ([]string, error) {
+	var zone powerDNSZone
+	err := b.do(http.MethodGet, nil, &zone)
+	if err != nil {
+		return nil, err
+	}
+	appRecord := canonicalName(appName + "." + Domain())
+	var names []string
+	for _, rrset := range zone.RRSets {
+		if rrset.Type != "A" {
+			continue
+		}
+		if rrset.Name == appRecord {
+			names = append(names, rrset.Name)
+			continue
+		}
+		process := strings.TrimSuffix(rrset.Name, "."+appRecord)
+		if process != rrset.Name && !strings.Contains(process, ".") {
+			names = append(names, rrset.Name)
+		}
+	}
+	return names, nil
+}
+
+func recordIPs(addresses []string) []powerDNSRecord {
+	seen := make(map[string]bool)
+	var ips []string
+	for _, addr := range addresses {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if net.ParseIP(host) == nil || seen[host] {
+			continue
+		}
+		seen[host] = true
+		ips = append(ips, host)
+	}
+	sort.Strings(ips)
+	records := make([]powerDNSRecord, len(ips))
+	for i, ip := range ips {
+		records[i] = powerDNSRecord{Content: ip}
+	}
+	return records
+}
+
+func powerDNSTTL() int {
+	ttl, err := config.GetInt("dns:powerdns:ttl")
+	if err != nil || ttl <= 0 {
+		return defaultPowerDNSTTL
+	}
+	return ttl
+}
+
+func (b powerDNSBackend) SetRecords(appName string, records []Record) error {
+	current, err := b.appRRSetNames(appName)
+	if err != nil {
+		return err
+	}
+	ttl := powerDNSTTL()
+	var rrsets []powerDNSRRSet
+	kept := make(map[string]bool)
+	for _, r := range records {
+		ips := recordIPs(r.Addresses)
+		if len(ips) == 0 {
+			continue
+		}
+		name := canonicalName(r.Name)
+		kept[name] = true
+		rrsets = append(rrsets, powerDNSRRSet{Name: name, Type: "A", TTL: ttl, ChangeType: "REPLACE", Records: ips})
+	}
+	for _, name := range current {
+		if !kept[name] {
+			rrsets = append(rrsets, powerDNSRRSet{Name: name, Type: "A", ChangeType: "DELETE", Records: []powerDNSRecord{}})
+		}
+	}
+	if len(rrsets) == 0 {
+		return nil
+	}
+	return b.do(http.MethodPatch, powerDNSZone{RRSets: rrsets}, nil)
+}
+
+func (b powerDNSBackend) RemoveRecords(appName string) error {
+	return b.SetRecords(appName, nil)
+}
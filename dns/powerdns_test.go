@@ -0,0 +1,100 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type fakePowerDNS struct {
+	zone    powerDNSZone
+	patches []powerDNSZone
+	paths   []string
+	keys    []string
+}
+
+func (f *fakePowerDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.paths = append(f.paths, r.Method+" "+r.URL.Path)
+	f.keys = append(f.keys, r.Header.Get("X-API-Key"))
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(f.zone)
+	case http.MethodPatch:
+		var patch powerDNSZone
+		json.NewDecoder(r.Body).Decode(&patch)
+		f.patches = append(f.patches, patch)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *S) setUpPowerDNS(c *check.C, zone powerDNSZone) (*fakePowerDNS, func()) {
+	fake := &fakePowerDNS{zone: zone}
+	server := httptest.NewServer(fake)
+	config.Set("dns:powerdns:url", server.URL)
+	config.Set("dns:powerdns:api-key", "secret")
+	return fake, func() {
+		server.Close()
+		config.Unset("dns:powerdns")
+	}
+}
+
+func (s *S) TestPowerDNSSetRecords(c *check.C) {
+	fake, cleanup := s.setUpPowerDNS(c, powerDNSZone{RRSets: []powerDNSRRSet{
+		{Name: "myapp.tsuru.internal.", Type: "A", Records: []powerDNSRecord{{Content: "10.0.0.9"}}},
+		{Name: "worker.myapp.tsuru.internal.", Type: "A", Records: []powerDNSRecord{{Content: "10.0.0.9"}}},
+		{Name: "web.otherapp.tsuru.internal.", Type: "A", Records: []powerDNSRecord{{Content: "10.0.0.8"}}},
+		{Name: "tsuru.internal.", Type: "SOA"},
+	}})
+	defer cleanup()
+	err := powerDNSBackend{}.SetRecords("myapp", []Record{
+		{Name: "myapp.tsuru.internal", Addresses: []string{"10.0.0.2:8080", "10.0.0.1:8080", "10.0.0.1:8081"}},
+		{Name: "web.myapp.tsuru.internal", Addresses: []string{"10.0.0.2:8080", "node1:8080"}},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(fake.paths, check.DeepEquals, []string{
+		"GET /api/v1/servers/localhost/zones/tsuru.internal.",
+		"PATCH /api/v1/servers/localhost/zones/tsuru.internal.",
+	})
+	c.Assert(fake.keys, check.DeepEquals, []string{"secret", "secret"})
+	c.Assert(fake.patches, check.DeepEquals, []powerDNSZone{{RRSets: []powerDNSRRSet{
+		{Name: "myapp.tsuru.internal.", Type: "A", TTL: 30, ChangeType: "REPLACE", Records: []powerDNSRecord{{Content: "10.0.0.1"}, {Content: "10.0.0.2"}}},
+		{Name: "web.myapp.tsuru.internal.", Type: "A", TTL: 30, ChangeType: "REPLACE", Records: []powerDNSRecord{{Content: "10.0.0.2"}}},
+		{Name: "worker.myapp.tsuru.internal.", Type: "A", ChangeType: "DELETE", Records: []powerDNSRecord{}},
+	}}})
+}
+
+func (s *S) TestPowerDNSRemoveRecords(c *check.C) {
+	fake, cleanup := s.setUpPowerDNS(c, powerDNSZone{RRSets: []powerDNSRRSet{
+		{Name: "myapp.tsuru.internal.", Type: "A", Records: []powerDNSRecord{{Content: "10.0.0.9"}}},
+		{Name: "myapp2.tsuru.internal.", Type: "A", Records: []powerDNSRecord{{Content: "10.0.0.8"}}},
+	}})
+	defer cleanup()
+	err := powerDNSBackend{}.RemoveRecords("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(fake.patches, check.DeepEquals, []powerDNSZone{{RRSets: []powerDNSRRSet{
+		{Name: "myapp.tsuru.internal.", Type: "A", ChangeType: "DELETE", Records: []powerDNSRecord{}},
+	}}})
+}
+
+func (s *S) TestPowerDNSError(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "zone not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+	config.Set("dns:powerdns:url", server.URL)
+	defer config.Unset("dns:powerdns:url")
+	err := powerDNSBackend{}.SetRecords("myapp", nil)
+	c.Assert(err, check.ErrorMatches, "powerdns returned status 404: zone not found\n")
+}
+
+func (s *S) TestPowerDNSWithoutURL(c *check.C) {
+	err := powerDNSBackend{}.SetRecords("myapp", nil)
+	c.Assert(err, check.ErrorMatches, "dns:powerdns:url is required by the powerdns dns backend")
+}
@@ -0,0 +1,155 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package egress provides an extension point for controlling the outbound
+// access of apps. Apps declare the external hosts they need to reach and
+// tsuru keeps the rules of the configured backend, usually an egress proxy
+// or a NAT gateway, in sync with them. Outbound connections to hosts not
+// declared by the app are blocked by the backend.
+package egress
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+const defaultBackend = "nop"
+
+var (
+	backends map[string]Backend
+
+	ErrInvalidRule = errors.New("invalid egress rule: host must be a hostname, a wildcard like *.example.com, an IP or a CIDR, and port must be between 0 and 65535")
+
+	ErrNotEnforced = errors.New("egress rules are not supported, there's no egress backend configured to enforce them")
+
+	hostnameRegexp = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+)
+
+// Rule allows the units of an app to connect to the given host. A zero Port
+// allows connections to any port of the host.
+type Rule struct {
+	Host string `json:"host"`
+	Port int    `json:"port,omitempty"`
+}
+
+// BlockedAttempt represents the outbound connections of an app to a host
+// blocked by the backend.
+type BlockedAttempt struct {
+	Host     string    `json:"host"`
+	Port     int       `json:"port"`
+	Count    int       `json:"count"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// Backend represents the egress proxy, or the service managing the network
+// rules, used to control the outbound access of apps.
+type Backend interface {
+	// SetRules replaces all the rules of the given app.
+	SetRules(appName string, rules []Rule) error
+	// RemoveRules removes all the rules of the given app.
+	RemoveRules(appName string) error
+	// BlockedAttempts returns the outbound connections of the app blocked
+	// since the given time.
+	BlockedAttempts(appName string, since time.Time) ([]BlockedAttempt, error)
+}
+
+// Register registers a new egress backend, that can be later configured and
+// used.
+func Register(name string, backend Backend) {
+	if backends == nil {
+		backends = make(map[string]Backend)
+	}
+	backends[name] = backend
+}
+
+// GetBackend returns the current configured backend, as defined in the
+// configuration file.
+func GetBackend() Backend {
+	name, err := config.GetString("egress:backend")
+	if err != nil {
+		name = defaultBackend
+	}
+	if _, ok := backends[name]; !ok {
+		name = defaultBackend
+	}
+	return backends[name]
+}
+
+// Enforced returns whether the configured backend enforces the egress rules.
+// The nop backend, used when no backend is configured, doesn't block any
+// connection, so rules can't be added while it's in use.
+func Enforced() bool {
+	_, isNop := GetBackend().(nopBackend)
+	return !isNop
+}
+
+// Normalize lowercases the host of the rule and checks whether the rule is
+// valid.
+func (r *Rule) Normalize() error {
+	r.Host = strings.ToLower(strings.TrimSpace(r.Host))
+	if r.Port < 0 || r.Port > 65535 || r.Host == "" {
+		return ErrInvalidRule
+	}
+	if net.ParseIP(r.Host) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(r.Host); err == nil {
+		return nil
+	}
+	if !hostnameRegexp.MatchString(strings.TrimPrefix(r.Host, "*.")) {
+		return ErrInvalidRule
+	}
+	return nil
+}
+
+func (r Rule) String() string {
+	if r.Port == 0 {
+		return r.Host
+	}
+	return fmt.Sprintf("%s:%d", r.Host, r.Port)
+}
+
+// SortRules sorts the rules by host and port.
+func SortRules(rules []Rule) {
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Host == rules[j].Host {
+			return rules[i].Port < rules[j].Port
+		}
+		return rules[i].Host < rules[j].Host
+	})
+}
+
+// SyncApp replaces the rules of the app in the configured backend.
+func SyncApp(appName string, rules []Rule) error {
+	err := GetBackend().SetRules(appName, rules)
+	if err != nil {
+		return errors.Wrapf(err, "unable to set egress rules for app %q", appName)
+	}
+	return nil
+}
+
+// RemoveApp removes all rules of the app from the configured backend.
+func RemoveApp(appName string) error {
+	return GetBackend().RemoveRules(appName)
+}
+
+// BlockedAttempts returns the outbound connections of the app blocked by the
+// configured backend since the given time, most recent first.
+func BlockedAttempts(appName string, since time.Time) ([]BlockedAttempt, error) {
+	attempts, err := GetBackend().BlockedAttempts(appName, since)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list blocked egress attempts for app %q", appName)
+	}
+	sort.SliceStable(attempts, func(i, j int) bool {
+		return attempts[i].LastSeen.After(attempts[j].LastSeen)
+	})
+	return attempts, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package egress
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type recordingBackend struct {
+	rules   map[string][]Rule
+	blocked []BlockedAttempt
+}
+
+func (b *recordingBackend) SetRules(appName string, rules []Rule) error {
+	b.rules[appName] = rules
+	return nil
+}
+
+func (b *recordingBackend) RemoveRules(appName string) error {
+	delete(b.rules, appName)
+	return nil
+}
+
+func (b *recordingBackend) BlockedAttempts(appName string, since time.Time) ([]BlockedAttempt, error) {
+	return b.blocked, nil
+}
+
+func (s *S) TestRuleNormalize(c *check.C) {
+	valid := []struct {
+		rule     Rule
+		expected Rule
+	}{
+		{Rule{Host: "api.example.com", Port: 443}, Rule{Host: "api.example.com", Port: 443}},
+		{Rule{Host: " API.Example.com "}, Rule{Host: "api.example.com"}},
+		{Rule{Host: "*.example.com"}, Rule{Host: "*.example.com"}},
+		{Rule{Host: "10.0.0.1", Port: 5432}, Rule{Host: "10.0.0.1", Port: 5432}},
+		{Rule{Host: "10.0.0.0/8"}, Rule{Host: "10.0.0.0/8"}},
+	}
+	for _, tt := range valid {
+		err := tt.rule.Normalize()
+		c.Check(err, check.IsNil)
+		c.Check(tt.rule, check.Equals, tt.expected)
+	}
+	invalid := []Rule{
+		{},
+		{Host: "api.example.com", Port: -1},
+		{Host: "api.example.com", Port: 65536},
+		{Host: "api_example.com"},
+		{Host: "*"},
+		{Host: "api.*.com"},
+		{Host: "http://api.example.com"},
+	}
+	for _, r := range invalid {
+		c.Check(r.Normalize(), check.Equals, ErrInvalidRule, check.Commentf("%#v", r))
+	}
+}
+
+func (s *S) TestRuleString(c *check.C) {
+	c.Assert(Rule{Host: "api.example.com"}.String(), check.Equals, "api.example.com")
+	c.Assert(Rule{Host: "api.example.com", Port: 443}.String(), check.Equals, "api.example.com:443")
+}
+
+func (s *S) TestGetBackendDefault(c *check.C) {
+	c.Assert(GetBackend(), check.Equals, nopBackend{})
+	config.Set("egress:backend", "unknown")
+	defer config.Unset("egress:backend")
+	c.Assert(GetBackend(), check.Equals, nopBackend{})
+}
+
+func (s *S) TestEnforced(c *check.C) {
+	c.Assert(Enforced(), check.Equals, false)
+	config.Set("egress:backend", "unknown")
+	defer config.Unset("egress:backend")
+	c.Assert(Enforced(), check.Equals, false)
+	Register("recording", &recordingBackend{})
+	config.Set("egress:backend", "recording")
+	c.Assert(Enforced(), check.Equals, true)
+}
+
+func (s *S) TestSyncAppAndRemoveApp(c *check.C) {
+	backend := &recordingBackend{rules: map[string][]Rule{}}
+	Register("recording", backend)
+	config.Set("egress:backend", "recording")
+	defer config.Unset("egress:backend")
+	rules := []Rule{{Host: "api.example.com", Port: 443}}
+	err := SyncApp("myapp", rules)
+	c.Assert(err, check.IsNil)
+	c.Assert(backend.rules["myapp"], check.DeepEquals, rules)
+	err = RemoveApp("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(backend.rules, check.HasLen, 0)
+}
+
+func (s *S) TestBlockedAttemptsSorted(c *check.C) {
+	now := time.Now()
+	backend := &recordingBackend{blocked: []BlockedAttempt{
+		{Host: "a.example.com", Port: 80, Count: 1, LastSeen: now.Add(-time.Minute)},
+		{Host: "b.example.com", Port: 443, Count: 3, LastSeen: now},
+	}}
+	Register("recording", backend)
+	config.Set("egress:backend", "recording")
+	defer config.Unset("egress:backend")
+	attempts, err := BlockedAttempts("myapp", now.Add(-time.Hour))
+	c.Assert(err, check.IsNil)
+	c.Assert(attempts, check.HasLen, 2)
+	c.Assert(attempts[0].Host, check.Equals, "b.example.com")
+	c.Assert(attempts[1].Host, check.Equals, "a.example.com")
+}
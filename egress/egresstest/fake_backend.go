@@ -0,0 +1,80 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package egresstest provides a fake egress backend for use in tests.
+//
+// Users can use the fake backend by just importing this package and setting
+// the "egress:backend" setting to "fake".
+package egresstest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tsuru/tsuru/egress"
+)
+
+func init() {
+	egress.Register("fake", &backend)
+}
+
+var backend = fakeBackend{
+	rules:   make(map[string][]egress.Rule),
+	blocked: make(map[string][]egress.BlockedAttempt),
+}
+
+type fakeBackend struct {
+	mu      sync.Mutex
+	rules   map[string][]egress.Rule
+	blocked map[string][]egress.BlockedAttempt
+}
+
+func (b *fakeBackend) SetRules(appName string, rules []egress.Rule) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rules[appName] = rules
+	return nil
+}
+
+func (b *fakeBackend) RemoveRules(appName string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.rules, appName)
+	delete(b.blocked, appName)
+	return nil
+}
+
+func (b *fakeBackend) BlockedAttempts(appName string, since time.Time) ([]egress.BlockedAttempt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var attempts []egress.BlockedAttempt
+	for _, a := range b.blocked[appName] {
+		if !a.LastSeen.Before(since) {
+			attempts = append(attempts, a)
+		}
+	}
+	return attempts, nil
+}
+
+// Rules returns the rules stored by the fake backend for the given app.
+func Rules(appName string) []egress.Rule {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	return backend.rules[appName]
+}
+
+// AddBlockedAttempt records a blocked outbound connection of the app.
+func AddBlockedAttempt(appName string, attempt egress.BlockedAttempt) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.blocked[appName] = append(backend.blocked[appName], attempt)
+}
+
+// Reset removes all rules and blocked attempts stored in the fake backend.
+func Reset() {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.rules = make(map[string][]egress.Rule)
+	backend.blocked = make(map[string][]egress.BlockedAttempt)
+}
@@ -5,7 +5,48 @@
 // Package errors provides facilities with error handling.
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
+
+// Machine-readable error codes returned by the API along with the error
+// message. Clients should rely on them instead of matching messages.
+const (
+	ErrorCodeBadRequest         = "bad_request"
+	ErrorCodeUnauthorized       = "unauthorized"
+	ErrorCodeForbidden          = "forbidden"
+	ErrorCodeNotFound           = "not_found"
+	ErrorCodeConflict           = "conflict"
+	ErrorCodePreconditionFailed = "precondition_failed"
+	ErrorCodePayloadTooLarge    = "payload_too_large"
+	ErrorCodeTooManyRequests    = "too_many_requests"
+	ErrorCodeClient             = "client_error"
+	ErrorCodeInternal           = "internal_error"
+	ErrorCodeBadGateway         = "bad_gateway"
+	ErrorCodeUnavailable        = "unavailable"
+	ErrorCodeAppNotFound        = "app_not_found"
+	ErrorCodeAppAlreadyExists   = "app_already_exists"
+	ErrorCodeAppLocked          = "app_locked"
+	ErrorCodePoolNotFound       = "pool_not_found"
+	ErrorCodeTeamNotFound       = "team_not_found"
+	ErrorCodeClusterNotFound    = "cluster_not_found"
+	ErrorCodeQuotaExceeded      = "quota_exceeded"
+)
+
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:            ErrorCodeBadRequest,
+	http.StatusUnauthorized:          ErrorCodeUnauthorized,
+	http.StatusForbidden:             ErrorCodeForbidden,
+	http.StatusNotFound:              ErrorCodeNotFound,
+	http.StatusConflict:              ErrorCodeConflict,
+	http.StatusPreconditionFailed:    ErrorCodePreconditionFailed,
+	http.StatusRequestEntityTooLarge: ErrorCodePayloadTooLarge,
+	http.StatusTooManyRequests:       ErrorCodeTooManyRequests,
+	http.StatusInternalServerError:   ErrorCodeInternal,
+	http.StatusBadGateway:            ErrorCodeBadGateway,
+	http.StatusServiceUnavailable:    ErrorCodeUnavailable,
+}
 
 // HTTP represents an HTTP error. It implements the error interface.
 //
@@ -22,6 +63,19 @@ func (e *HTTP) Error() string {
 	return e.Message
 }
 
+// StatusErrorCode returns the generic error code for an HTTP status code.
+// Client errors without a specific code are reported as client_error, and
+// server errors as internal_error.
+func StatusErrorCode(status int) string {
+	if code, ok := statusErrorCodes[status]; ok {
+		return code
+	}
+	if status >= 400 && status < 500 {
+		return ErrorCodeClient
+	}
+	return ErrorCodeInternal
+}
+
 // ValidationError is an error implementation used whenever a validation
 // failure occurs.
 type ValidationError struct {
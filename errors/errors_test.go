@@ -21,6 +21,20 @@ func (s *S) TestHTTPError(c *check.C) {
 	c.Assert(e.Error(), check.Equals, e.Message)
 }
 
+func (s *S) TestStatusErrorCode(c *check.C) {
+	tests := map[int]string{
+		404: "not_found",
+		412: "precondition_failed",
+		413: "payload_too_large",
+		405: "client_error",
+		502: "bad_gateway",
+		504: "internal_error",
+	}
+	for status, code := range tests {
+		c.Check(StatusErrorCode(status), check.Equals, code, check.Commentf("status %d", status))
+	}
+}
+
 func (s *S) TestValidationError(c *check.C) {
 	e := ValidationError{Message: "something"}
 	c.Assert(e.Error(), check.Equals, "something")
@@ -0,0 +1,101 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Change is a value changed by an update-style event. Field is the dotted
+// path of the value in the BSON document of the changed object, Before and
+// After are nil when the field was added or removed.
+type Change struct {
+	Field  string
+	Before interface{}
+	After  interface{}
+}
+
+// Diff returns the changes between before and after, comparing their BSON
+// documents field by field. Nested documents are compared recursively, any
+// other value, including lists, is compared as a whole. Null fields are
+// handled as missing.
+func Diff(before, after interface{}) ([]Change, error) {
+	beforeFields, err := flattenDocument(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := flattenDocument(after)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for field, beforeValue := range beforeFields {
+		afterValue, ok := afterFields[field]
+		if ok && reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		changes = append(changes, Change{Field: field, Before: beforeValue, After: afterValue})
+	}
+	for field, afterValue := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes = append(changes, Change{Field: field, After: afterValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+// DoneWithDiff marks the event as done, storing after as the end custom data
+// and the changes between before and after. Changes are only stored for
+// successful events.
+func (e *Event) DoneWithDiff(evtErr error, before, after interface{}) error {
+	if evtErr == nil {
+		changes, err := Diff(before, after)
+		if err != nil {
+			log.Errorf("[events] unable to diff custom data for event %s: %s", e.UniqueID.Hex(), err)
+		}
+		e.Changes = changes
+	}
+	return e.done(evtErr, after, false)
+}
+
+func flattenDocument(value interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if value == nil {
+		return fields, nil
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && v.IsNil() {
+		return fields, nil
+	}
+	data, err := bson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	flattenInto(fields, "", doc)
+	return fields, nil
+}
+
+func flattenInto(fields map[string]interface{}, prefix string, doc bson.M) {
+	for k, v := range doc {
+		switch v := v.(type) {
+		case nil:
+		case bson.M:
+			flattenInto(fields, prefix+k+".", v)
+		default:
+			fields[prefix+k] = v
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"errors"
+
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+type diffPool struct {
+	Name    string
+	Default bool
+	Labels  map[string]string `bson:",omitempty"`
+	Tags    []string
+}
+
+func (s *S) TestDiff(c *check.C) {
+	before := diffPool{
+		Name:   "pool1",
+		Labels: map[string]string{"team": "a", "zone": "x"},
+		Tags:   []string{"a"},
+	}
+	after := diffPool{
+		Name:    "pool1",
+		Default: true,
+		Labels:  map[string]string{"team": "b", "region": "y"},
+		Tags:    []string{"a", "b"},
+	}
+	changes, err := Diff(before, after)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []Change{
+		{Field: "default", Before: false, After: true},
+		{Field: "labels.region", After: "y"},
+		{Field: "labels.team", Before: "a", After: "b"},
+		{Field: "labels.zone", Before: "x"},
+		{Field: "tags", Before: []interface{}{"a"}, After: []interface{}{"a", "b"}},
+	})
+}
+
+func (s *S) TestDiffNil(c *check.C) {
+	var before *diffPool
+	changes, err := Diff(before, &diffPool{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []Change{
+		{Field: "default", After: false},
+		{Field: "name", After: "pool1"},
+	})
+	changes, err = Diff(diffPool{Name: "pool1"}, diffPool{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.IsNil)
+}
+
+func (s *S) TestDoneWithDiff(c *check.C) {
+	evt, err := New(&Opts{
+		Target:  Target{Type: "pool", Value: "pool1"},
+		Kind:    permission.PermPoolUpdate,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermPoolReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.DoneWithDiff(nil, diffPool{Name: "pool1"}, diffPool{Name: "pool1", Default: true})
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Changes, check.DeepEquals, []Change{
+		{Field: "default", Before: false, After: true},
+	})
+	var endData diffPool
+	err = evts[0].EndData(&endData)
+	c.Assert(err, check.IsNil)
+	c.Assert(endData, check.DeepEquals, diffPool{Name: "pool1", Default: true})
+}
+
+func (s *S) TestDoneWithDiffError(c *check.C) {
+	evt, err := New(&Opts{
+		Target:  Target{Type: "pool", Value: "pool1"},
+		Kind:    permission.PermPoolUpdate,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermPoolReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	var after *diffPool
+	err = evt.DoneWithDiff(errors.New("update failed"), diffPool{Name: "pool1"}, after)
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Error, check.Equals, "update failed")
+	c.Assert(evts[0].Changes, check.IsNil)
+}
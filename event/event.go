@@ -36,6 +36,7 @@ var (
 	errInvalidQuery = errors.New("invalid query")
 
 	ErrNotCancelable     = errors.New("event is not cancelable")
+	ErrCanceled          = errors.New("canceled by user request")
 	ErrEventNotFound     = errors.New("event not found")
 	ErrNoTarget          = ErrValidation("event target is mandatory")
 	ErrNoKind            = ErrValidation("event kind is mandatory")
@@ -55,22 +56,30 @@ var (
 	KindTypePermission = kindType("permission")
 	KindTypeInternal   = kindType("internal")
 
-	TargetTypeApp             = TargetType("app")
-	TargetTypeNode            = TargetType("node")
-	TargetTypeContainer       = TargetType("container")
-	TargetTypePool            = TargetType("pool")
-	TargetTypeService         = TargetType("service")
-	TargetTypeServiceInstance = TargetType("service-instance")
-	TargetTypeTeam            = TargetType("team")
-	TargetTypeUser            = TargetType("user")
-	TargetTypeIaas            = TargetType("iaas")
-	TargetTypeRole            = TargetType("role")
-	TargetTypePlatform        = TargetType("platform")
-	TargetTypePlan            = TargetType("plan")
-	TargetTypeNodeContainer   = TargetType("node-container")
-	TargetTypeInstallHost     = TargetType("install-host")
-	TargetTypeEventBlock      = TargetType("event-block")
-	TargetTypeCluster         = TargetType("cluster")
+	TargetTypeApp                = TargetType("app")
+	TargetTypeNode               = TargetType("node")
+	TargetTypeContainer          = TargetType("container")
+	TargetTypePool               = TargetType("pool")
+	TargetTypeService            = TargetType("service")
+	TargetTypeServiceInstance    = TargetType("service-instance")
+	TargetTypeTeam               = TargetType("team")
+	TargetTypeUser               = TargetType("user")
+	TargetTypeIaas               = TargetType("iaas")
+	TargetTypeRole               = TargetType("role")
+	TargetTypePlatform           = TargetType("platform")
+	TargetTypePlan               = TargetType("plan")
+	TargetTypeNodeContainer      = TargetType("node-container")
+	TargetTypeInstallHost        = TargetType("install-host")
+	TargetTypeEventBlock         = TargetType("event-block")
+	TargetTypeCluster            = TargetType("cluster")
+	TargetTypeOrganization       = TargetType("organization")
+	TargetTypeHardwareProfile    = TargetType("hardware-profile")
+	TargetTypeConstraintTemplate = TargetType("constraint-template")
+	TargetTypeDeployFreeze       = TargetType("deploy-freeze")
+	TargetTypeJob                = TargetType("job")
+	TargetTypeComplianceReport   = TargetType("compliance-report")
+	TargetTypeEventRetentionRule = TargetType("event-retention-rule")
+	TargetTypeEventWebhook       = TargetType("event-webhook")
 )
 
 const (
@@ -151,6 +160,7 @@ type eventData struct {
 	StartCustomData bson.Raw  `bson:",omitempty"`
 	EndCustomData   bson.Raw  `bson:",omitempty"`
 	OtherCustomData bson.Raw  `bson:",omitempty"`
+	Changes         []Change  `bson:",omitempty"`
 	Kind            Kind
 	Owner           Owner
 	LockUpdateTime  time.Time
@@ -162,6 +172,8 @@ type eventData struct {
 	Running         bool
 	Allowed         AllowedPermission
 	AllowedCancel   AllowedPermission
+	ParentID        bson.ObjectId `bson:",omitempty"`
+	CorrelationID   bson.ObjectId `bson:",omitempty"`
 }
 
 type cancelInfo struct {
@@ -171,6 +183,8 @@ type cancelInfo struct {
 	Reason    string
 	Asked     bool
 	Canceled  bool
+	// Checkpoint is the checkpoint where the operation was interrupted.
+	Checkpoint string `bson:",omitempty"`
 }
 
 type ownerType string
@@ -204,6 +218,9 @@ func GetTargetType(t string) (TargetType, error) {
 type Owner struct {
 	Type ownerType
 	Name string
+	// Impersonator is the user acting as the owner, when the action was
+	// done with an impersonation token.
+	Impersonator string `json:",omitempty" bson:",omitempty"`
 }
 
 type Kind struct {
@@ -212,6 +229,9 @@ type Kind struct {
 }
 
 func (o Owner) String() string {
+	if o.Impersonator != "" {
+		return fmt.Sprintf("%s %s (impersonated by %s)", o.Type, o.Name, o.Impersonator)
+	}
 	return fmt.Sprintf("%s %s", o.Type, o.Name)
 }
 
@@ -262,6 +282,13 @@ type Opts struct {
 	Cancelable    bool
 	Allowed       AllowedPermission
 	AllowedCancel AllowedPermission
+	// Parent is the event of the operation that triggered this one. The
+	// unique ID of the root event is used as the correlation ID of all its
+	// descendants, so the whole tree can be listed filtering by it. Only
+	// automatic rollbacks set it for now, with the deploy that triggered
+	// them as parent: router updates run inside the deploy event, and node
+	// autoscale runs in its own loop, not triggered by deploys.
+	Parent *Event
 }
 
 func Allowed(scheme *permission.PermissionScheme, contexts ...permission.PermissionContext) AllowedPermission {
@@ -316,6 +343,7 @@ type Filter struct {
 	Running        *bool
 	IncludeRemoved bool
 	ErrorOnly      bool
+	CorrelationID  string `form:"correlation"`
 	Raw            bson.M
 	AllowedTargets []TargetFilter
 	Permissions    []permission.Permission
@@ -396,15 +424,27 @@ func (f *Filter) toQuery() (bson.M, error) {
 	if f.OwnerName != "" {
 		query["owner.name"] = f.OwnerName
 	}
-	var timeParts []bson.M
+	var andParts []bson.M
 	if !f.Since.IsZero() {
-		timeParts = append(timeParts, bson.M{"starttime": bson.M{"$gte": f.Since}})
+		andParts = append(andParts, bson.M{"starttime": bson.M{"$gte": f.Since}})
 	}
 	if !f.Until.IsZero() {
-		timeParts = append(timeParts, bson.M{"starttime": bson.M{"$lte": f.Until}})
+		andParts = append(andParts, bson.M{"starttime": bson.M{"$lte": f.Until}})
+	}
+	if f.CorrelationID != "" {
+		if !bson.IsObjectIdHex(f.CorrelationID) {
+			return nil, errInvalidQuery
+		}
+		// The root event of a tree has no correlation ID, it's the ID
+		// shared by its descendants.
+		correlationID := bson.ObjectIdHex(f.CorrelationID)
+		andParts = append(andParts, bson.M{"$or": []bson.M{
+			{"uniqueid": correlationID},
+			{"correlationid": correlationID},
+		}})
 	}
-	if len(timeParts) != 0 {
-		query["$and"] = timeParts
+	if len(andParts) != 0 {
+		query["$and"] = andParts
 	}
 	if f.Running != nil {
 		query["running"] = *f.Running
@@ -429,12 +469,25 @@ func GetKinds() ([]Kind, error) {
 		return nil, err
 	}
 	defer conn.Close()
-	coll := conn.Events()
-	var kinds []Kind
-	err = coll.Find(nil).Distinct("kind", &kinds)
+	colls, err := queryColls(conn, time.Time{}, time.Time{})
 	if err != nil {
 		return nil, err
 	}
+	var kinds []Kind
+	seen := map[Kind]struct{}{}
+	for _, coll := range colls {
+		var collKinds []Kind
+		err = coll.Find(nil).Distinct("kind", &collKinds)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range collKinds {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				kinds = append(kinds, k)
+			}
+		}
+	}
 	return kinds, nil
 }
 
@@ -466,18 +519,11 @@ func GetByID(id bson.ObjectId) (*Event, error) {
 		return nil, err
 	}
 	defer conn.Close()
-	coll := conn.Events()
-	var evt Event
-	err = coll.Find(bson.M{
-		"uniqueid": id,
-	}).One(&evt.eventData)
+	data, err := findByUniqueID(conn, id)
 	if err != nil {
-		if err == mgo.ErrNotFound {
-			return nil, ErrEventNotFound
-		}
 		return nil, err
 	}
-	return &evt, nil
+	return &Event{eventData: *data}, nil
 }
 
 func All() ([]Event, error) {
@@ -489,8 +535,10 @@ func List(filter *Filter) ([]Event, error) {
 	skip := 0
 	var query bson.M
 	var err error
+	var since, until time.Time
 	sort := "-starttime"
 	if filter != nil {
+		since, until = filter.Since, filter.Until
 		limit = filterMaxLimit
 		if filter.Limit != 0 {
 			limit = filter.Limit
@@ -514,16 +562,11 @@ func List(filter *Filter) ([]Event, error) {
 		return nil, err
 	}
 	defer conn.Close()
-	coll := conn.Events()
-	find := coll.Find(query).Sort(sort)
-	if limit > 0 {
-		find = find.Limit(limit)
-	}
-	if skip > 0 {
-		find = find.Skip(skip)
+	colls, err := queryColls(conn, since, until)
+	if err != nil {
+		return nil, err
 	}
-	var allData []eventData
-	err = find.All(&allData)
+	allData, err := listFromColls(colls, query, sort, skip, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -540,13 +583,59 @@ func MarkAsRemoved(target Target) error {
 		return err
 	}
 	defer conn.Close()
-	coll := conn.Events()
+	colls, err := queryColls(conn, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
 	now := time.Now().UTC()
-	_, err = coll.UpdateAll(bson.M{
-		"target":     target,
-		"removedate": bson.M{"$exists": false},
-	}, bson.M{"$set": bson.M{"removedate": now}})
-	return err
+	for _, coll := range colls {
+		_, err = coll.UpdateAll(bson.M{
+			"target":     target,
+			"removedate": bson.M{"$exists": false},
+		}, bson.M{"$set": bson.M{"removedate": now}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenameTarget moves the finished events of the target from to the target to,
+// also replacing the permission context fromCtx with toCtx in the permissions
+// required to see or cancel any event. Running events are kept unchanged, as
+// their target is used as the lock of the event.
+func RenameTarget(from, to Target, fromCtx, toCtx permission.PermissionContext) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	colls, err := queryColls(conn, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	ctxQuery := bson.M{"$elemMatch": bson.M{"ctxtype": fromCtx.CtxType, "value": fromCtx.Value}}
+	for _, coll := range colls {
+		_, err = coll.UpdateAll(bson.M{
+			"target":  from,
+			"running": false,
+		}, bson.M{"$set": bson.M{"target": to}})
+		if err != nil {
+			return err
+		}
+		for _, field := range []string{"allowed", "allowedcancel"} {
+			_, err = coll.UpdateAll(bson.M{
+				field + ".contexts": ctxQuery,
+			}, bson.M{"$set": bson.M{field + ".contexts.$": bson.D{
+				{Name: "ctxtype", Value: toCtx.CtxType},
+				{Name: "value", Value: toCtx.Value},
+			}}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func New(opts *Opts) (*Event, error) {
@@ -649,6 +738,9 @@ func newEvt(opts *Opts) (*Event, error) {
 	} else {
 		o.Type = OwnerTypeUser
 		o.Name = opts.Owner.GetUserName()
+		if impersonated, ok := opts.Owner.(auth.ImpersonatedToken); ok {
+			o.Impersonator = impersonated.GetImpersonator()
+		}
 	}
 	conn, err := db.Conn()
 	if err != nil {
@@ -666,11 +758,20 @@ func newEvt(opts *Opts) (*Event, error) {
 		if tSpec.KindName != "" {
 			query["kind.name"] = tSpec.KindName
 		}
-		var c int
-		c, err = coll.Find(query).Count()
+		var colls []*storage.Collection
+		colls, err = queryColls(conn, time.Now().UTC().Add(-tSpec.Time), time.Time{})
 		if err != nil {
 			return nil, err
 		}
+		var c int
+		for _, countColl := range colls {
+			var n int
+			n, err = countColl.Find(query).Count()
+			if err != nil {
+				return nil, err
+			}
+			c += n
+		}
 		if c >= tSpec.Max {
 			return nil, ErrThrottled{Spec: tSpec, Target: opts.Target}
 		}
@@ -681,6 +782,14 @@ func newEvt(opts *Opts) (*Event, error) {
 		return nil, err
 	}
 	uniqID := bson.NewObjectId()
+	var parentID, correlationID bson.ObjectId
+	if opts.Parent != nil {
+		parentID = opts.Parent.UniqueID
+		correlationID = opts.Parent.CorrelationID
+		if correlationID == "" {
+			correlationID = parentID
+		}
+	}
 	var id eventID
 	if opts.DisableLock {
 		id.ObjId = uniqID
@@ -700,6 +809,8 @@ func newEvt(opts *Opts) (*Event, error) {
 		Cancelable:      opts.Cancelable,
 		Allowed:         opts.Allowed,
 		AllowedCancel:   opts.AllowedCancel,
+		ParentID:        parentID,
+		CorrelationID:   correlationID,
 	}}
 	maxRetries := 1
 	for i := 0; i < maxRetries+1; i++ {
@@ -753,8 +864,7 @@ func (e *Event) RawInsert(start, other, end interface{}) error {
 		return err
 	}
 	defer conn.Close()
-	coll := conn.Events()
-	return coll.Insert(e.eventData)
+	return finishedColl(conn, e.StartTime).Insert(e.eventData)
 }
 
 func (e *Event) Abort() error {
@@ -830,6 +940,35 @@ func (e *Event) TryCancel(reason, owner string) error {
 }
 
 func (e *Event) AckCancel() (bool, error) {
+	return e.ackCancel("")
+}
+
+// Checkpoint marks a point where the operation of the event may be
+// interrupted cleanly. When the event was asked to be canceled, the
+// cancellation is acknowledged at the checkpoint and ErrCanceled is returned,
+// so the caller must undo its partial work and return. Errors checking the
+// cancellation are logged and ignored. It's safe to call Checkpoint on nil
+// events.
+func (e *Event) Checkpoint(name string) error {
+	if e == nil {
+		return nil
+	}
+	if e.CancelInfo.Canceled {
+		return ErrCanceled
+	}
+	canceled, err := e.ackCancel(name)
+	if err != nil {
+		log.Errorf("[events] unable to check if event %s should be canceled at checkpoint %q, ignoring: %s", e.UniqueID.Hex(), name, err)
+		return nil
+	}
+	if !canceled {
+		return nil
+	}
+	e.Logf(" ---> Canceled by %s at checkpoint %q: %s", e.CancelInfo.Owner, name, e.CancelInfo.Reason)
+	return ErrCanceled
+}
+
+func (e *Event) ackCancel(checkpoint string) (bool, error) {
 	if !e.Cancelable || !e.Running {
 		return false, nil
 	}
@@ -839,11 +978,15 @@ func (e *Event) AckCancel() (bool, error) {
 	}
 	defer conn.Close()
 	coll := conn.Events()
+	set := bson.M{
+		"cancelinfo.acktime":  time.Now().UTC(),
+		"cancelinfo.canceled": true,
+	}
+	if checkpoint != "" {
+		set["cancelinfo.checkpoint"] = checkpoint
+	}
 	change := mgo.Change{
-		Update: bson.M{"$set": bson.M{
-			"cancelinfo.acktime":  time.Now().UTC(),
-			"cancelinfo.canceled": true,
-		}},
+		Update:    bson.M{"$set": set},
 		ReturnNew: true,
 	}
 	_, err = coll.Find(bson.M{"_id": e.ID, "cancelinfo.asked": true}).Apply(change, &e.eventData)
@@ -895,7 +1038,7 @@ func (e *Event) done(evtErr error, customData interface{}, abort bool) (err erro
 	if evtErr != nil {
 		e.Error = evtErr.Error()
 	} else if e.CancelInfo.Canceled {
-		e.Error = "canceled by user request"
+		e.Error = ErrCanceled.Error()
 	}
 	e.EndTime = time.Now().UTC()
 	e.EndCustomData, err = makeBSONRaw(customData)
@@ -909,12 +1052,26 @@ func (e *Event) done(evtErr error, customData interface{}, abort bool) (err erro
 	if err == nil {
 		e.OtherCustomData = dbEvt.OtherCustomData
 	}
+	finished := finishedColl(conn, e.StartTime)
 	if len(e.ID.ObjId) != 0 {
-		return coll.UpdateId(e.ID, e.eventData)
+		if finished.Name == coll.Name {
+			err = coll.UpdateId(e.ID, e.eventData)
+		} else {
+			err = finished.Insert(e.eventData)
+			if err == nil {
+				err = coll.RemoveId(e.ID)
+			}
+		}
+	} else {
+		defer coll.RemoveId(e.ID)
+		e.ID = eventID{ObjId: e.UniqueID}
+		err = finished.Insert(e.eventData)
 	}
-	defer coll.RemoveId(e.ID)
-	e.ID = eventID{ObjId: e.UniqueID}
-	return coll.Insert(e.eventData)
+	if err != nil {
+		return err
+	}
+	enqueueWebhookDeliveries(conn, e)
+	return nil
 }
 
 type lockUpdater struct {
@@ -1004,19 +1161,28 @@ func Migrate(query bson.M, cb func(*Event) error) error {
 		return err
 	}
 	defer conn.Close()
-	coll := conn.Events()
-	iter := coll.Find(query).Iter()
-	var evtData eventData
-	for iter.Next(&evtData) {
-		evt := &Event{eventData: evtData}
-		err = cb(evt)
-		if err != nil {
-			return errors.Wrapf(err, "unable to migrate %#v", evt)
+	colls, err := queryColls(conn, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	for _, coll := range colls {
+		iter := coll.Find(query).Iter()
+		var evtData eventData
+		for iter.Next(&evtData) {
+			evt := &Event{eventData: evtData}
+			err = cb(evt)
+			if err != nil {
+				return errors.Wrapf(err, "unable to migrate %#v", evt)
+			}
+			err = coll.UpdateId(evt.ID, evt.eventData)
+			if err != nil {
+				return errors.Wrapf(err, "unable to update %#v", evt)
+			}
 		}
-		err = coll.UpdateId(evt.ID, evt.eventData)
+		err = iter.Close()
 		if err != nil {
-			return errors.Wrapf(err, "unable to update %#v", evt)
+			return err
 		}
 	}
-	return iter.Close()
+	return nil
 }
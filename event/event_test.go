@@ -547,6 +547,51 @@ func (s *S) TestEventCancelDoneCustomError(c *check.C) {
 	c.Assert(evts[0].Error, check.Equals, "my err")
 }
 
+func (s *S) TestEventCheckpoint(c *check.C) {
+	var logBuffer bytes.Buffer
+	evt, err := New(&Opts{
+		Target:        Target{Type: "app", Value: "myapp"},
+		Kind:          permission.PermAppUpdateEnvSet,
+		Owner:         s.token,
+		Cancelable:    true,
+		Allowed:       Allowed(permission.PermAppReadEvents),
+		AllowedCancel: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	evt.SetLogWriter(&logBuffer)
+	err = evt.Checkpoint("first")
+	c.Assert(err, check.IsNil)
+	err = evt.TryCancel("yes", "admin@admin.com")
+	c.Assert(err, check.IsNil)
+	err = evt.Checkpoint("second")
+	c.Assert(err, check.Equals, ErrCanceled)
+	err = evt.Checkpoint("third")
+	c.Assert(err, check.Equals, ErrCanceled)
+	c.Assert(evt.CancelInfo.Canceled, check.Equals, true)
+	c.Assert(evt.CancelInfo.Checkpoint, check.Equals, "second")
+	c.Assert(logBuffer.String(), check.Equals, " ---> Canceled by admin@admin.com at checkpoint \"second\": yes\n")
+	err = evt.Done(err)
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Error, check.Equals, "canceled by user request")
+	c.Assert(evts[0].CancelInfo.Checkpoint, check.Equals, "second")
+}
+
+func (s *S) TestEventCheckpointNotCancelable(c *check.C) {
+	var evt *Event
+	c.Assert(evt.Checkpoint("nil"), check.IsNil)
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Checkpoint("first"), check.IsNil)
+}
+
 func (s *S) TestEventNewValidation(c *check.C) {
 	_, err := New(nil)
 	c.Assert(err, check.Equals, ErrNoOpts)
@@ -676,6 +721,65 @@ func (s *S) TestListFilterEmpty(c *check.C) {
 	c.Assert(evts, check.HasLen, 0)
 }
 
+func (s *S) TestListFilterCorrelation(c *check.C) {
+	root, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(root.ParentID, check.Equals, bson.ObjectId(""))
+	c.Assert(root.CorrelationID, check.Equals, bson.ObjectId(""))
+	child, err := New(&Opts{
+		Target:      Target{Type: "node", Value: "http://10.0.0.1"},
+		Kind:        permission.PermNodeCreate,
+		Owner:       s.token,
+		Allowed:     Allowed(permission.PermPoolReadEvents),
+		DisableLock: true,
+		Parent:      root,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(child.ParentID, check.Equals, root.UniqueID)
+	c.Assert(child.CorrelationID, check.Equals, root.UniqueID)
+	err = root.Done(nil)
+	c.Assert(err, check.IsNil)
+	grandchild, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateCnameAdd,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+		Parent:  child,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(grandchild.ParentID, check.Equals, child.UniqueID)
+	c.Assert(grandchild.CorrelationID, check.Equals, root.UniqueID)
+	_, err = New(&Opts{
+		Target:  Target{Type: "app", Value: "otherapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	evts, err := List(&Filter{CorrelationID: root.UniqueID.Hex()})
+	c.Assert(err, check.IsNil)
+	ids := map[bson.ObjectId]bool{}
+	for i := range evts {
+		ids[evts[i].UniqueID] = true
+	}
+	c.Assert(ids, check.DeepEquals, map[bson.ObjectId]bool{
+		root.UniqueID:       true,
+		child.UniqueID:      true,
+		grandchild.UniqueID: true,
+	})
+	evts, err = List(&Filter{CorrelationID: child.UniqueID.Hex()})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	evts, err = List(&Filter{CorrelationID: "invalid"})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
 func (s *S) TestListFilterPruneUserValues(c *check.C) {
 	t := true
 	f := Filter{
@@ -688,6 +792,7 @@ func (s *S) TestListFilterPruneUserValues(c *check.C) {
 		Until:          time.Now(),
 		Running:        &t,
 		IncludeRemoved: true,
+		CorrelationID:  bson.NewObjectId().Hex(),
 		Raw:            bson.M{"a": 1},
 		AllowedTargets: []TargetFilter{{Type: TargetTypeApp, Values: []string{"a1"}}},
 		Limit:          50,
@@ -22,6 +22,7 @@ type EventDesc struct {
 	StartCustomData interface{}
 	EndCustomData   interface{}
 	OtherCustomData interface{}
+	Changes         []event.Change
 	LogMatches      string
 	ErrorMatches    string
 	IsEmpty         bool
@@ -88,6 +89,9 @@ func (hasEventChecker) Check(params []interface{}, names []string) (bool, string
 	queryPartCustom(query, "startcustomdata", evt.StartCustomData)
 	queryPartCustom(query, "endcustomdata", evt.EndCustomData)
 	queryPartCustom(query, "othercustomdata", evt.OtherCustomData)
+	if evt.Changes != nil {
+		query["changes"] = evt.Changes
+	}
 	if evt.LogMatches != "" {
 		query["log"] = bson.M{"$regex": evt.LogMatches}
 	}
@@ -0,0 +1,248 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// RetentionForever is the retention of events that are never pruned.
+const RetentionForever = time.Duration(-1)
+
+const defaultPruneInterval = time.Hour
+
+// RetentionPolicy controls how long finished events are kept before being
+// removed by the pruner.
+type RetentionPolicy struct {
+	// Default is the retention of all events. Zero disables pruning.
+	Default time.Duration
+	// Exemptions maps tags to the retention of events targeting apps and
+	// pools with the tag. Exemptions only extend the default retention, and
+	// targets with many exempted tags use the longest retention.
+	Exemptions map[string]time.Duration
+}
+
+// RetentionPolicyFromConfig reads the policy from the event:retention and
+// event:retention-exemptions settings. Retentions are durations, like
+// 2160h, exemptions may also be set to "forever".
+func RetentionPolicyFromConfig() (*RetentionPolicy, error) {
+	policy := &RetentionPolicy{Exemptions: map[string]time.Duration{}}
+	if _, err := config.Get("event:retention"); err == nil {
+		retention, err := config.GetDuration("event:retention")
+		if err != nil || retention < 0 {
+			return nil, errors.New("invalid event:retention, it must be a duration, like 2160h")
+		}
+		policy.Default = retention
+	}
+	raw, err := config.Get("event:retention-exemptions")
+	if err != nil {
+		return policy, nil
+	}
+	exemptions, ok := raw.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("invalid event:retention-exemptions, it must map tags to retentions")
+	}
+	for k, v := range exemptions {
+		tag := fmt.Sprint(k)
+		value := fmt.Sprint(v)
+		if value == "forever" {
+			policy.Exemptions[tag] = RetentionForever
+			continue
+		}
+		retention, err := time.ParseDuration(value)
+		if err != nil || retention <= 0 {
+			return nil, errors.Errorf("invalid retention %q for tag %q, it must be a duration, like 8760h, or forever", value, tag)
+		}
+		policy.Exemptions[tag] = retention
+	}
+	return policy, nil
+}
+
+func longestRetention(a, b time.Duration) time.Duration {
+	if a == RetentionForever || b == RetentionForever {
+		return RetentionForever
+	}
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// exemptTargets returns the retention of each app and pool tagged with an
+// exempted tag.
+func (p *RetentionPolicy) exemptTargets(conn *db.Storage) (map[Target]time.Duration, error) {
+	targets := map[Target]time.Duration{}
+	if len(p.Exemptions) == 0 {
+		return targets, nil
+	}
+	tags := make([]string, 0, len(p.Exemptions))
+	for tag := range p.Exemptions {
+		tags = append(tags, tag)
+	}
+	query := bson.M{"tags": bson.M{"$in": tags}}
+	var apps []struct {
+		Name string
+		Tags []string
+	}
+	err := conn.Apps().Find(query).Select(bson.M{"name": 1, "tags": 1}).All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	var pools []struct {
+		Name string `bson:"_id"`
+		Tags []string
+	}
+	err = conn.Pools().Find(query).Select(bson.M{"_id": 1, "tags": 1}).All(&pools)
+	if err != nil {
+		return nil, err
+	}
+	add := func(target Target, targetTags []string) {
+		retention := p.Default
+		for _, tag := range targetTags {
+			if exemption, ok := p.Exemptions[tag]; ok {
+				retention = longestRetention(retention, exemption)
+			}
+		}
+		targets[target] = retention
+	}
+	for _, a := range apps {
+		add(Target{Type: TargetTypeApp, Value: a.Name}, a.Tags)
+	}
+	for _, pool := range pools {
+		add(Target{Type: TargetTypePool, Value: pool.Name}, pool.Tags)
+	}
+	return targets, nil
+}
+
+// Prune applies the retention rules and removes the remaining finished events
+// started before now minus their retention, returning the number of removed
+// or archived events. Events targeting exempted apps and pools are kept for
+// as long as their exemption requires.
+func (p *RetentionPolicy) Prune(now time.Time) (int, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	rules, err := listRetentionRules(conn)
+	if err != nil {
+		return 0, err
+	}
+	if p.Default <= 0 && len(rules) == 0 {
+		return 0, nil
+	}
+	exempt, err := p.exemptTargets(conn)
+	if err != nil {
+		return 0, err
+	}
+	colls, err := queryColls(conn, time.Time{}, now)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := applyRetentionRules(conn, rules, exempt, colls, now)
+	if err != nil || p.Default <= 0 {
+		return removed, err
+	}
+	byRetention := map[time.Duration][]Target{}
+	exemptList := make([]Target, 0, len(exempt))
+	for target, retention := range exempt {
+		exemptList = append(exemptList, target)
+		if retention != RetentionForever {
+			byRetention[retention] = append(byRetention[retention], target)
+		}
+	}
+	queries := []bson.M{{
+		"running":   false,
+		"starttime": bson.M{"$lt": now.Add(-p.Default)},
+		"target":    bson.M{"$nin": exemptList},
+	}}
+	retentions := make([]time.Duration, 0, len(byRetention))
+	for retention := range byRetention {
+		retentions = append(retentions, retention)
+	}
+	sort.Slice(retentions, func(i, j int) bool { return retentions[i] < retentions[j] })
+	for _, retention := range retentions {
+		queries = append(queries, bson.M{
+			"running":   false,
+			"starttime": bson.M{"$lt": now.Add(-retention)},
+			"target":    bson.M{"$in": byRetention[retention]},
+		})
+	}
+	if len(rules) > 0 {
+		ruleMatches := make([]bson.M, len(rules))
+		for i := range rules {
+			ruleMatches[i] = rules[i].match()
+		}
+		for _, query := range queries {
+			query["$nor"] = ruleMatches
+		}
+	}
+	for _, coll := range colls {
+		for _, query := range queries {
+			info, err := coll.RemoveAll(query)
+			if err != nil {
+				return removed, errors.Wrapf(err, "unable to prune events in %s", coll.Name)
+			}
+			removed += info.Removed
+		}
+	}
+	return removed, nil
+}
+
+type pruner struct {
+	policy   *RetentionPolicy
+	interval time.Duration
+	done     chan bool
+}
+
+// StartPruner periodically applies the retention policy and the retention
+// rules, every event:prune-interval.
+func StartPruner() error {
+	policy, err := RetentionPolicyFromConfig()
+	if err != nil {
+		return err
+	}
+	interval, err := config.GetDuration("event:prune-interval")
+	if err != nil || interval <= 0 {
+		interval = defaultPruneInterval
+	}
+	p := &pruner{policy: policy, interval: interval, done: make(chan bool)}
+	shutdown.Register(p)
+	go p.run()
+	return nil
+}
+
+func (p *pruner) run() {
+	for {
+		removed, err := p.policy.Prune(time.Now().UTC())
+		if err != nil {
+			log.Errorf("[events-pruner] %s", err)
+		} else if removed > 0 {
+			log.Debugf("[events-pruner] %d events removed", removed)
+		}
+		select {
+		case <-p.done:
+			return
+		case <-time.After(p.interval):
+		}
+	}
+}
+
+func (p *pruner) Shutdown() {
+	p.done <- true
+}
+
+func (p *pruner) String() string {
+	return "events pruner"
+}
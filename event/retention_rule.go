@@ -0,0 +1,189 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// RetentionAction is what happens to events once their retention ends.
+type RetentionAction string
+
+const (
+	// RetentionActionExpire removes the events.
+	RetentionActionExpire = RetentionAction("expire")
+	// RetentionActionArchive moves the events to the archived events
+	// collection, where they are kept out of the event listing.
+	RetentionActionArchive = RetentionAction("archive")
+)
+
+const archiveBatchSize = 1000
+
+var (
+	ErrRetentionRuleNotFound = errors.New("retention rule not found")
+	ErrInvalidRetentionRule  = errors.New("invalid retention rule: name is required, retention must be greater than zero and action must be expire or archive")
+)
+
+// RetentionRule sets the retention of finished events of a kind, a target
+// type or both. Events matching a rule are handled by it instead of the
+// event:retention setting, and when many rules match an event, the one with
+// the shortest retention is applied first. Events targeting apps and pools
+// exempted by tag are skipped by rules with a retention shorter than the
+// exemption.
+type RetentionRule struct {
+	Name       string          `bson:"_id" json:"name"`
+	Kind       string          `json:"kind,omitempty"`
+	TargetType TargetType      `json:"targetType,omitempty"`
+	Retention  time.Duration   `json:"retention"`
+	Action     RetentionAction `json:"action"`
+}
+
+func (r *RetentionRule) validate() error {
+	if r.Name == "" || r.Retention <= 0 {
+		return ErrInvalidRetentionRule
+	}
+	if r.Action != RetentionActionExpire && r.Action != RetentionActionArchive {
+		return ErrInvalidRetentionRule
+	}
+	return nil
+}
+
+// match returns the query matching the events handled by the rule,
+// regardless of their start time.
+func (r *RetentionRule) match() bson.M {
+	query := bson.M{}
+	if r.Kind != "" {
+		query["kind.name"] = r.Kind
+	}
+	if r.TargetType != "" {
+		query["target.type"] = r.TargetType
+	}
+	return query
+}
+
+// SetRetentionRule adds the rule, replacing any rule with the same name.
+func SetRetentionRule(rule RetentionRule) error {
+	err := rule.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.EventRetentionRules().UpsertId(rule.Name, rule)
+	return err
+}
+
+// RemoveRetentionRule removes the rule with the given name.
+func RemoveRetentionRule(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.EventRetentionRules().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrRetentionRuleNotFound
+	}
+	return err
+}
+
+// ListRetentionRules returns the retention rules, from the shortest
+// retention to the longest.
+func ListRetentionRules() ([]RetentionRule, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return listRetentionRules(conn)
+}
+
+func listRetentionRules(conn *db.Storage) ([]RetentionRule, error) {
+	var rules []RetentionRule
+	err := conn.EventRetentionRules().Find(nil).Sort("retention", "_id").All(&rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// applyRetentionRules expires or archives the events matching the rules,
+// returning the number of events removed from the events collections.
+func applyRetentionRules(conn *db.Storage, rules []RetentionRule, exempt map[Target]time.Duration, colls []*storage.Collection, now time.Time) (int, error) {
+	var removed int
+	for _, rule := range rules {
+		skip := []Target{}
+		for target, retention := range exempt {
+			if retention == RetentionForever || retention > rule.Retention {
+				skip = append(skip, target)
+			}
+		}
+		query := rule.match()
+		query["running"] = false
+		query["starttime"] = bson.M{"$lt": now.Add(-rule.Retention)}
+		query["target"] = bson.M{"$nin": skip}
+		for _, coll := range colls {
+			var n int
+			var err error
+			if rule.Action == RetentionActionArchive {
+				n, err = archiveEvents(conn, coll, query)
+			} else {
+				var info *mgo.ChangeInfo
+				info, err = coll.RemoveAll(query)
+				if info != nil {
+					n = info.Removed
+				}
+			}
+			removed += n
+			if err != nil {
+				return removed, errors.Wrapf(err, "unable to apply retention rule %q in %s", rule.Name, coll.Name)
+			}
+		}
+	}
+	return removed, nil
+}
+
+// archiveEvents moves the events matching query from coll to the archived
+// events collection, in batches. Events already archived, by an interrupted
+// previous run, are only removed from coll.
+func archiveEvents(conn *db.Storage, coll *storage.Collection, query bson.M) (int, error) {
+	archive := conn.ArchivedEvents()
+	var moved int
+	for {
+		var docs []bson.M
+		err := coll.Find(query).Limit(archiveBatchSize).All(&docs)
+		if err != nil {
+			return moved, err
+		}
+		if len(docs) == 0 {
+			return moved, nil
+		}
+		ids := make([]interface{}, len(docs))
+		for i, doc := range docs {
+			ids[i] = doc["_id"]
+			err = archive.Insert(doc)
+			if err != nil && !mgo.IsDup(err) {
+				return moved, err
+			}
+		}
+		info, err := coll.RemoveAll(bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return moved, err
+		}
+		moved += info.Removed
+		if len(docs) < archiveBatchSize {
+			return moved, nil
+		}
+	}
+}
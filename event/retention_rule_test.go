@@ -0,0 +1,140 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) insertFinishedEventWithKind(c *check.C, target Target, kind string, start time.Time) {
+	evt := &Event{eventData: eventData{
+		UniqueID:  bson.NewObjectId(),
+		Target:    target,
+		Kind:      Kind{Type: KindTypePermission, Name: kind},
+		Owner:     Owner{Type: OwnerTypeUser, Name: "me@me.com"},
+		StartTime: start,
+		EndTime:   start.Add(time.Minute),
+	}}
+	evt.ID = eventID{ObjId: evt.UniqueID}
+	err := evt.RawInsert(nil, nil, nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestSetRetentionRule(c *check.C) {
+	rule := RetentionRule{Name: "nodes", TargetType: TargetTypeNode, Retention: time.Hour, Action: RetentionActionExpire}
+	err := SetRetentionRule(rule)
+	c.Assert(err, check.IsNil)
+	rule.Retention = 2 * time.Hour
+	err = SetRetentionRule(rule)
+	c.Assert(err, check.IsNil)
+	err = SetRetentionRule(RetentionRule{Name: "deploys", Kind: "app.deploy", Retention: time.Minute, Action: RetentionActionArchive})
+	c.Assert(err, check.IsNil)
+	rules, err := ListRetentionRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []RetentionRule{
+		{Name: "deploys", Kind: "app.deploy", Retention: time.Minute, Action: RetentionActionArchive},
+		{Name: "nodes", TargetType: TargetTypeNode, Retention: 2 * time.Hour, Action: RetentionActionExpire},
+	})
+}
+
+func (s *S) TestSetRetentionRuleInvalid(c *check.C) {
+	rules := []RetentionRule{
+		{Retention: time.Hour, Action: RetentionActionExpire},
+		{Name: "r", Action: RetentionActionExpire},
+		{Name: "r", Retention: -time.Hour, Action: RetentionActionExpire},
+		{Name: "r", Retention: time.Hour},
+		{Name: "r", Retention: time.Hour, Action: "delete"},
+	}
+	for _, rule := range rules {
+		err := SetRetentionRule(rule)
+		c.Check(err, check.Equals, ErrInvalidRetentionRule)
+	}
+}
+
+func (s *S) TestRemoveRetentionRule(c *check.C) {
+	err := SetRetentionRule(RetentionRule{Name: "nodes", TargetType: TargetTypeNode, Retention: time.Hour, Action: RetentionActionExpire})
+	c.Assert(err, check.IsNil)
+	err = RemoveRetentionRule("nodes")
+	c.Assert(err, check.IsNil)
+	rules, err := ListRetentionRules()
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 0)
+	err = RemoveRetentionRule("nodes")
+	c.Assert(err, check.Equals, ErrRetentionRuleNotFound)
+}
+
+func (s *S) TestRetentionPolicyPruneWithRules(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Insert(bson.M{"name": "regulated", "tags": []string{"regulated"}})
+	c.Assert(err, check.IsNil)
+	now := time.Date(2017, time.October, 10, 10, 0, 0, 0, time.UTC)
+	old := now.Add(-100 * 24 * time.Hour)
+	app := Target{Type: TargetTypeApp, Value: "myapp"}
+	regulated := Target{Type: TargetTypeApp, Value: "regulated"}
+	node := Target{Type: TargetTypeNode, Value: "n1"}
+	s.insertFinishedEventWithKind(c, app, "app.deploy", old)
+	s.insertFinishedEventWithKind(c, app, "app.deploy", now.Add(-time.Hour))
+	s.insertFinishedEventWithKind(c, app, "app.update.env.set", old)
+	s.insertFinishedEventWithKind(c, regulated, "app.deploy", old)
+	s.insertFinishedEventWithKind(c, node, "node.update", old)
+	err = SetRetentionRule(RetentionRule{Name: "deploys", Kind: "app.deploy", Retention: 24 * time.Hour, Action: RetentionActionArchive})
+	c.Assert(err, check.IsNil)
+	err = SetRetentionRule(RetentionRule{Name: "nodes", TargetType: TargetTypeNode, Retention: 24 * time.Hour, Action: RetentionActionExpire})
+	c.Assert(err, check.IsNil)
+	policy := RetentionPolicy{
+		Default:    365 * 24 * time.Hour,
+		Exemptions: map[string]time.Duration{"regulated": RetentionForever},
+	}
+	removed, err := policy.Prune(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(removed, check.Equals, 2)
+	n, err := conn.Events().Find(bson.M{"kind.name": "app.deploy", "target.value": "myapp"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	n, err = conn.Events().Find(bson.M{"target.value": "regulated"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	n, err = conn.Events().Find(bson.M{"kind.name": "app.update.env.set"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	n, err = conn.Events().Find(bson.M{"target.type": TargetTypeNode}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+	var archived []eventData
+	err = conn.ArchivedEvents().Find(nil).All(&archived)
+	c.Assert(err, check.IsNil)
+	c.Assert(archived, check.HasLen, 1)
+	c.Assert(archived[0].Target, check.Equals, app)
+	c.Assert(archived[0].Kind.Name, check.Equals, "app.deploy")
+}
+
+func (s *S) TestRetentionPolicyPruneRulesOverrideDefault(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	now := time.Date(2017, time.October, 10, 10, 0, 0, 0, time.UTC)
+	old := now.Add(-100 * 24 * time.Hour)
+	app := Target{Type: TargetTypeApp, Value: "myapp"}
+	s.insertFinishedEventWithKind(c, app, "app.deploy", old)
+	s.insertFinishedEventWithKind(c, app, "app.update.env.set", old)
+	err = SetRetentionRule(RetentionRule{Name: "deploys", Kind: "app.deploy", Retention: 365 * 24 * time.Hour, Action: RetentionActionArchive})
+	c.Assert(err, check.IsNil)
+	policy := RetentionPolicy{Default: 30 * 24 * time.Hour}
+	removed, err := policy.Prune(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(removed, check.Equals, 1)
+	n, err := conn.Events().Find(bson.M{"kind.name": "app.deploy"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	n, err = conn.ArchivedEvents().Find(nil).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
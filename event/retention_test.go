@@ -0,0 +1,100 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) insertFinishedEvent(c *check.C, target Target, start time.Time) {
+	evt := &Event{eventData: eventData{
+		UniqueID:  bson.NewObjectId(),
+		Target:    target,
+		Kind:      Kind{Type: KindTypePermission, Name: "app.update.env.set"},
+		Owner:     Owner{Type: OwnerTypeUser, Name: "me@me.com"},
+		StartTime: start,
+		EndTime:   start.Add(time.Minute),
+	}}
+	evt.ID = eventID{ObjId: evt.UniqueID}
+	err := evt.RawInsert(nil, nil, nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestRetentionPolicyFromConfig(c *check.C) {
+	policy, err := RetentionPolicyFromConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, check.DeepEquals, &RetentionPolicy{Exemptions: map[string]time.Duration{}})
+	config.Set("event:retention", "720h")
+	config.Set("event:retention-exemptions", map[interface{}]interface{}{
+		"regulated": "forever",
+		"audited":   "8760h",
+	})
+	defer config.Unset("event:retention")
+	defer config.Unset("event:retention-exemptions")
+	policy, err = RetentionPolicyFromConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, check.DeepEquals, &RetentionPolicy{
+		Default: 720 * time.Hour,
+		Exemptions: map[string]time.Duration{
+			"regulated": RetentionForever,
+			"audited":   8760 * time.Hour,
+		},
+	})
+	config.Set("event:retention-exemptions", map[interface{}]interface{}{"regulated": "always"})
+	_, err = RetentionPolicyFromConfig()
+	c.Assert(err, check.ErrorMatches, `invalid retention "always" for tag "regulated", .*`)
+}
+
+func (s *S) TestRetentionPolicyPrune(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Insert(
+		bson.M{"name": "regular"},
+		bson.M{"name": "regulated", "tags": []string{"regulated"}},
+		bson.M{"name": "audited", "tags": []string{"audited"}},
+	)
+	c.Assert(err, check.IsNil)
+	err = conn.Pools().Insert(bson.M{"_id": "pci", "tags": []string{"regulated"}})
+	c.Assert(err, check.IsNil)
+	now := time.Date(2017, time.October, 10, 10, 0, 0, 0, time.UTC)
+	old := now.Add(-100 * 24 * time.Hour)
+	veryOld := now.Add(-400 * 24 * time.Hour)
+	targets := []Target{
+		{Type: TargetTypeApp, Value: "regular"},
+		{Type: TargetTypeApp, Value: "regulated"},
+		{Type: TargetTypeApp, Value: "audited"},
+		{Type: TargetTypePool, Value: "pci"},
+	}
+	for _, target := range targets {
+		s.insertFinishedEvent(c, target, now.Add(-time.Hour))
+		s.insertFinishedEvent(c, target, old)
+		s.insertFinishedEvent(c, target, veryOld)
+	}
+	policy := RetentionPolicy{
+		Default: 30 * 24 * time.Hour,
+		Exemptions: map[string]time.Duration{
+			"regulated": RetentionForever,
+			"audited":   365 * 24 * time.Hour,
+		},
+	}
+	removed, err := policy.Prune(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(removed, check.Equals, 3)
+	expected := map[string]int{"regular": 1, "regulated": 3, "audited": 2, "pci": 3}
+	for name, count := range expected {
+		n, err := conn.Events().Find(bson.M{"target.value": name}).Count()
+		c.Assert(err, check.IsNil)
+		c.Check(n, check.Equals, count, check.Commentf("target %s", name))
+	}
+	removed, err = (&RetentionPolicy{}).Prune(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(removed, check.Equals, 0)
+}
@@ -0,0 +1,199 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// When partitioning is enabled, by setting event:partitioning to "monthly",
+// the main events collection only holds running events, which are also used
+// as locks. Finished events are moved to one collection per month, named
+// after the month in which the event started (e.g. events_201710). This keeps
+// lock operations and queries on recent events fast regardless of the total
+// number of stored events, and allows operators to drop or archive old
+// partitions as a whole.
+
+const partitionLayout = "200601"
+
+func partitioningEnabled() bool {
+	mode, _ := config.GetString("event:partitioning")
+	return mode == "monthly"
+}
+
+func partitionFor(t time.Time) string {
+	return t.UTC().Format(partitionLayout)
+}
+
+// finishedColl returns the collection where a finished event, started at t,
+// must be stored.
+func finishedColl(conn *db.Storage, t time.Time) *storage.Collection {
+	if partitioningEnabled() {
+		return conn.EventsPartition(partitionFor(t))
+	}
+	return conn.Events()
+}
+
+// queryColls returns the collections that may hold events started between
+// since and until (zero values meaning unbounded). The main collection comes
+// first, followed by partitions from the newest to the oldest one. Existing
+// partitions are always considered, even if partitioning has been disabled
+// after being used.
+func queryColls(conn *db.Storage, since, until time.Time) ([]*storage.Collection, error) {
+	colls := []*storage.Collection{conn.Events()}
+	partitions, err := conn.EventsPartitions()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(partitions)))
+	for _, p := range partitions {
+		if !since.IsZero() && p < partitionFor(since) {
+			continue
+		}
+		if !until.IsZero() && p > partitionFor(until) {
+			continue
+		}
+		colls = append(colls, conn.EventsPartition(p))
+	}
+	return colls, nil
+}
+
+// findByUniqueID looks for the event in the main collection, then in the
+// partition matching the id creation time, falling back to the remaining
+// partitions.
+func findByUniqueID(conn *db.Storage, id bson.ObjectId) (*eventData, error) {
+	all, err := queryColls(conn, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	expected := db.EventsPartitionPrefix + partitionFor(id.Time())
+	colls := []*storage.Collection{all[0]}
+	var others []*storage.Collection
+	for _, coll := range all[1:] {
+		if coll.Name == expected {
+			colls = append(colls, coll)
+		} else {
+			others = append(others, coll)
+		}
+	}
+	colls = append(colls, others...)
+	var data eventData
+	for _, coll := range colls {
+		err := coll.Find(bson.M{"uniqueid": id}).One(&data)
+		if err == nil {
+			return &data, nil
+		}
+		if err != mgo.ErrNotFound {
+			return nil, err
+		}
+	}
+	return nil, ErrEventNotFound
+}
+
+type eventDataList struct {
+	data  []eventData
+	field string
+	desc  bool
+}
+
+func newEventDataList(data []eventData, sortField string) *eventDataList {
+	l := &eventDataList{data: data, field: strings.TrimPrefix(sortField, "-")}
+	l.desc = strings.HasPrefix(sortField, "-")
+	return l
+}
+
+func (l *eventDataList) Len() int      { return len(l.data) }
+func (l *eventDataList) Swap(i, j int) { l.data[i], l.data[j] = l.data[j], l.data[i] }
+func (l *eventDataList) Less(i, j int) bool {
+	if l.desc {
+		i, j = j, i
+	}
+	a, b := &l.data[i], &l.data[j]
+	switch l.field {
+	case "_id", "uniqueid":
+		return a.UniqueID < b.UniqueID
+	case "endtime":
+		return a.EndTime.Before(b.EndTime)
+	case "lockupdatetime":
+		return a.LockUpdateTime.Before(b.LockUpdateTime)
+	}
+	return a.StartTime.Before(b.StartTime)
+}
+
+// listFromColls runs the query in each collection and merges the results,
+// respecting the requested sort, skip and limit.
+func listFromColls(colls []*storage.Collection, query bson.M, sortField string, skip, limit int) ([]eventData, error) {
+	if len(colls) == 1 {
+		find := colls[0].Find(query).Sort(sortField)
+		if limit > 0 {
+			find = find.Limit(limit)
+		}
+		if skip > 0 {
+			find = find.Skip(skip)
+		}
+		var allData []eventData
+		err := find.All(&allData)
+		return allData, err
+	}
+	var allData []eventData
+	for _, coll := range colls {
+		find := coll.Find(query).Sort(sortField)
+		if limit > 0 {
+			find = find.Limit(limit + skip)
+		}
+		var data []eventData
+		err := find.All(&data)
+		if err != nil {
+			return nil, err
+		}
+		allData = append(allData, data...)
+	}
+	sort.Stable(newEventDataList(allData, sortField))
+	if skip >= len(allData) {
+		return nil, nil
+	}
+	allData = allData[skip:]
+	if limit > 0 && len(allData) > limit {
+		allData = allData[:limit]
+	}
+	return allData, nil
+}
+
+// MovePartitioned moves finished events stored in the main events collection
+// to their monthly partitions. It's a no-op unless partitioning is enabled.
+func MovePartitioned() error {
+	if !partitioningEnabled() {
+		return nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.Events()
+	iter := coll.Find(bson.M{"running": false}).Iter()
+	var data eventData
+	for iter.Next(&data) {
+		data.ID = eventID{ObjId: data.UniqueID}
+		err = finishedColl(conn, data.StartTime).Insert(data)
+		if err != nil && !mgo.IsDup(err) {
+			return errors.Wrapf(err, "unable to move event %s", data.UniqueID.Hex())
+		}
+		err = coll.Remove(bson.M{"uniqueid": data.UniqueID, "running": false})
+		if err != nil && err != mgo.ErrNotFound {
+			return errors.Wrapf(err, "unable to remove event %s", data.UniqueID.Hex())
+		}
+	}
+	return iter.Close()
+}
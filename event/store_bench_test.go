@@ -0,0 +1,141 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The benchmarks below compare the single events collection with monthly
+// partitions. Run them with:
+//
+//   go test ./event -run NONE -bench Events
+
+const benchmarkStoredEvents = 5000
+
+var benchmarkModes = []struct {
+	name         string
+	partitioning string
+}{
+	{name: "single"},
+	{name: "monthly", partitioning: "monthly"},
+}
+
+func setUpEventsBenchmark(b *testing.B, partitioning string) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_events_benchmarks")
+	if partitioning == "" {
+		config.Unset("event:partitioning")
+	} else {
+		config.Set("event:partitioning", partitioning)
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	err = dbtest.ClearAllCollections(conn.Events().Database)
+	if err != nil {
+		b.Fatal(err)
+	}
+	partitions, err := conn.EventsPartitions()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, p := range partitions {
+		conn.EventsPartition(p).DropCollection()
+	}
+}
+
+// insertBenchmarkEvents stores finished events spread over the last year, so
+// partitioned runs have a partition per month.
+func insertBenchmarkEvents(b *testing.B, n int) {
+	base := time.Now().UTC().AddDate(-1, 0, 0)
+	step := 365 * 24 * time.Hour / time.Duration(n)
+	for i := 0; i < n; i++ {
+		start := base.Add(time.Duration(i) * step)
+		evt := &Event{eventData: eventData{
+			UniqueID:  bson.NewObjectIdWithTime(start),
+			Target:    Target{Type: TargetTypeApp, Value: fmt.Sprintf("app%d", i%10)},
+			Kind:      Kind{Type: KindTypePermission, Name: permission.PermAppUpdateEnvSet.FullName()},
+			Owner:     Owner{Type: OwnerTypeUser, Name: "me@me.com"},
+			StartTime: start,
+			EndTime:   start.Add(time.Minute),
+			Allowed:   Allowed(permission.PermAppReadEvents),
+		}}
+		err := evt.RawInsert(nil, nil, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEventsNewDone(b *testing.B) {
+	defer config.Unset("event:partitioning")
+	for _, mode := range benchmarkModes {
+		b.Run(mode.name, func(b *testing.B) {
+			setUpEventsBenchmark(b, mode.partitioning)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				evt, err := New(&Opts{
+					Target:   Target{Type: TargetTypeApp, Value: fmt.Sprintf("app%d", i)},
+					Kind:     permission.PermAppUpdateEnvSet,
+					RawOwner: Owner{Type: OwnerTypeUser, Name: "me@me.com"},
+					Allowed:  Allowed(permission.PermAppReadEvents),
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				err = evt.Done(nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEventsList(b *testing.B) {
+	defer config.Unset("event:partitioning")
+	for _, mode := range benchmarkModes {
+		b.Run(mode.name, func(b *testing.B) {
+			setUpEventsBenchmark(b, mode.partitioning)
+			insertBenchmarkEvents(b, benchmarkStoredEvents)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := List(&Filter{Limit: filterMaxLimit})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEventsListLastWeek(b *testing.B) {
+	defer config.Unset("event:partitioning")
+	for _, mode := range benchmarkModes {
+		b.Run(mode.name, func(b *testing.B) {
+			setUpEventsBenchmark(b, mode.partitioning)
+			insertBenchmarkEvents(b, benchmarkStoredEvents)
+			since := time.Now().UTC().AddDate(0, 0, -7)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := List(&Filter{Since: since, Limit: filterMaxLimit})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
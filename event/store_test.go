@@ -0,0 +1,178 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestPartitionFor(c *check.C) {
+	t := time.Date(2017, time.March, 31, 23, 59, 0, 0, time.UTC)
+	c.Assert(partitionFor(t), check.Equals, "201703")
+	loc := time.FixedZone("x", -3*60*60)
+	t = time.Date(2017, time.March, 31, 22, 0, 0, 0, loc)
+	c.Assert(partitionFor(t), check.Equals, "201704")
+}
+
+func (s *S) TestEventDataListSort(c *check.C) {
+	now := time.Now()
+	data := []eventData{
+		{StartTime: now.Add(time.Minute), EndTime: now},
+		{StartTime: now, EndTime: now.Add(2 * time.Minute)},
+		{StartTime: now.Add(2 * time.Minute), EndTime: now.Add(time.Minute)},
+	}
+	sort.Stable(newEventDataList(data, "-starttime"))
+	c.Assert(data[0].StartTime, check.DeepEquals, now.Add(2*time.Minute))
+	c.Assert(data[1].StartTime, check.DeepEquals, now.Add(time.Minute))
+	c.Assert(data[2].StartTime, check.DeepEquals, now)
+	sort.Stable(newEventDataList(data, "endtime"))
+	c.Assert(data[0].EndTime, check.DeepEquals, now)
+	c.Assert(data[1].EndTime, check.DeepEquals, now.Add(time.Minute))
+	c.Assert(data[2].EndTime, check.DeepEquals, now.Add(2*time.Minute))
+}
+
+func (s *S) TestNewDonePartitioned(c *check.C) {
+	config.Set("event:partitioning", "monthly")
+	defer config.Unset("event:partitioning")
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	n, err := conn.Events().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+	n, err = conn.EventsPartition(partitionFor(evt.StartTime)).Find(bson.M{"uniqueid": evt.UniqueID}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].UniqueID, check.Equals, evt.UniqueID)
+	c.Assert(evts[0].Running, check.Equals, false)
+	dbEvt, err := GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbEvt.UniqueID, check.Equals, evt.UniqueID)
+}
+
+func (s *S) TestListPartitionedMergesCollections(c *check.C) {
+	config.Set("event:partitioning", "monthly")
+	defer config.Unset("event:partitioning")
+	base := time.Date(2017, time.January, 15, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		start := base.AddDate(0, i, 0)
+		evt := &Event{eventData: eventData{
+			UniqueID:  bson.NewObjectId(),
+			Target:    Target{Type: "app", Value: fmt.Sprintf("app%d", i)},
+			Kind:      Kind{Type: KindTypePermission, Name: "app.update.env.set"},
+			Owner:     Owner{Type: OwnerTypeUser, Name: "me@me.com"},
+			StartTime: start,
+			EndTime:   start.Add(time.Minute),
+		}}
+		evt.ID = eventID{ObjId: evt.UniqueID}
+		err := evt.RawInsert(nil, nil, nil)
+		c.Assert(err, check.IsNil)
+	}
+	running, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "running"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	defer running.Abort()
+	evts, err := List(&Filter{Limit: 3})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 3)
+	c.Assert(evts[0].Target.Value, check.Equals, "running")
+	c.Assert(evts[1].Target.Value, check.Equals, "app3")
+	c.Assert(evts[2].Target.Value, check.Equals, "app2")
+	evts, err = List(&Filter{Limit: 2, Skip: 2})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 2)
+	c.Assert(evts[0].Target.Value, check.Equals, "app2")
+	c.Assert(evts[1].Target.Value, check.Equals, "app1")
+	evts, err = List(&Filter{Since: base.AddDate(0, 1, 0), Until: base.AddDate(0, 2, 0)})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 2)
+	c.Assert(evts[0].Target.Value, check.Equals, "app2")
+	c.Assert(evts[1].Target.Value, check.Equals, "app1")
+	kinds, err := GetKinds()
+	c.Assert(err, check.IsNil)
+	c.Assert(kinds, check.HasLen, 1)
+}
+
+func (s *S) TestMovePartitioned(c *check.C) {
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	running, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "other"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	defer running.Abort()
+	config.Set("event:partitioning", "monthly")
+	defer config.Unset("event:partitioning")
+	err = MovePartitioned()
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	var mainEvts []eventData
+	err = conn.Events().Find(nil).All(&mainEvts)
+	c.Assert(err, check.IsNil)
+	c.Assert(mainEvts, check.HasLen, 1)
+	c.Assert(mainEvts[0].UniqueID, check.Equals, running.UniqueID)
+	n, err := conn.EventsPartition(partitionFor(evt.StartTime)).Find(bson.M{"uniqueid": evt.UniqueID}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 2)
+}
+
+func (s *S) TestMovePartitionedDisabled(c *check.C) {
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	err = MovePartitioned()
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	partitions, err := conn.EventsPartitions()
+	c.Assert(err, check.IsNil)
+	c.Assert(partitions, check.HasLen, 0)
+}
@@ -0,0 +1,416 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/jobs"
+	"github.com/tsuru/tsuru/log"
+	tsuruNet "github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	webhookDeliveryJob = "event-webhook-delivery"
+
+	// WebhookSignatureHeader holds the hex encoded HMAC-SHA256 of the body
+	// of deliveries, keyed by the secret of the webhook.
+	WebhookSignatureHeader = "X-Tsuru-Signature"
+	WebhookEventHeader     = "X-Tsuru-Event"
+	WebhookDeliveryHeader  = "X-Tsuru-Delivery"
+
+	defaultWebhookDeliveriesLimit = 100
+)
+
+var (
+	ErrWebhookNotFound        = errors.New("webhook not found")
+	ErrInvalidWebhook         = errors.New("invalid webhook: name is required and url must be an absolute http or https url")
+	ErrWebhookInternalAddress = errors.New("invalid webhook: url must not point to an internal address")
+
+	webhookClient      = tsuruNet.ExternalClient
+	validateWebhookURL = tsuruNet.ValidateExternalURL
+)
+
+func init() {
+	jobs.Register(webhookDeliveryJob, runWebhookDelivery)
+}
+
+// WebhookFilter selects the finished events delivered to a webhook. Kinds
+// match the event kind or any kind below it, like app.update matching
+// app.update.env.set. Targets without value match all targets of their
+// type. Empty fields match all events.
+type WebhookFilter struct {
+	Kinds     []string `json:"kinds,omitempty" bson:",omitempty"`
+	Targets   []Target `json:"targets,omitempty" bson:",omitempty"`
+	ErrorOnly bool     `json:"errorOnly,omitempty"`
+}
+
+func (f *WebhookFilter) match(e *Event) bool {
+	if f.ErrorOnly && e.Error == "" {
+		return false
+	}
+	if len(f.Kinds) > 0 {
+		var found bool
+		for _, kind := range f.Kinds {
+			if e.Kind.Name == kind || strings.HasPrefix(e.Kind.Name, kind+".") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Targets) > 0 {
+		var found bool
+		for _, target := range f.Targets {
+			if e.Target.Type == target.Type && (target.Value == "" || e.Target.Value == target.Value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Webhook is an HTTP endpoint receiving, as a JSON POST, the finished events
+// matching its filter. Deliveries are signed with the secret of the webhook
+// and retried with exponential backoff by background jobs until the endpoint
+// answers with a 2xx status.
+//
+// Only events the owner of the webhook is allowed to read are delivered.
+// Webhooks with a team owner are managed by the members of the team with
+// the event-webhook permissions.
+type Webhook struct {
+	Name      string        `bson:"_id" json:"name"`
+	URL       string        `json:"url"`
+	Secret    string        `json:"secret,omitempty"`
+	Filter    WebhookFilter `json:"filter"`
+	TeamOwner string        `json:"teamOwner,omitempty" bson:",omitempty"`
+	Owner     string        `json:"owner,omitempty" bson:",omitempty"`
+}
+
+func (w *Webhook) validate() error {
+	if w.Name == "" {
+		return ErrInvalidWebhook
+	}
+	err := validateWebhookURL(w.URL)
+	if _, ok := err.(*tsuruNet.InternalAddressError); ok {
+		return ErrWebhookInternalAddress
+	}
+	if err != nil {
+		return ErrInvalidWebhook
+	}
+	for _, target := range w.Filter.Targets {
+		if target.Type == "" {
+			return ErrInvalidWebhook
+		}
+	}
+	return nil
+}
+
+// WebhookDelivery is an attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID         bson.ObjectId `bson:"_id" json:"id"`
+	Webhook    string        `json:"webhook"`
+	EventID    bson.ObjectId `json:"eventID"`
+	EventKind  string        `json:"eventKind"`
+	Timestamp  time.Time     `json:"timestamp"`
+	Duration   time.Duration `json:"duration"`
+	StatusCode int           `json:"statusCode,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// SetWebhook adds the webhook, replacing any webhook with the same name.
+// When the secret is empty, the secret of the replaced webhook is kept, or a
+// random one is generated for new webhooks.
+func SetWebhook(w *Webhook) error {
+	err := w.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if w.Secret == "" {
+		var existing Webhook
+		err = conn.EventWebhooks().FindId(w.Name).One(&existing)
+		if err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+		w.Secret = existing.Secret
+	}
+	if w.Secret == "" {
+		randomBytes := make([]byte, 32)
+		_, err = rand.Read(randomBytes)
+		if err != nil {
+			return err
+		}
+		w.Secret = hex.EncodeToString(randomBytes)
+	}
+	_, err = conn.EventWebhooks().UpsertId(w.Name, w)
+	return err
+}
+
+// GetWebhook returns the webhook with the given name.
+func GetWebhook(name string) (*Webhook, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var w Webhook
+	err = conn.EventWebhooks().FindId(name).One(&w)
+	if err == mgo.ErrNotFound {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// ListWebhooks returns the webhooks sorted by name, without their secrets.
+func ListWebhooks() ([]Webhook, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var webhooks []Webhook
+	err = conn.EventWebhooks().Find(nil).Sort("_id").All(&webhooks)
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// RemoveWebhook removes the webhook with the given name and its delivery
+// log. Pending deliveries to the webhook are dropped.
+func RemoveWebhook(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.EventWebhooks().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrWebhookNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.EventWebhookDeliveries().RemoveAll(bson.M{"webhook": name})
+	return err
+}
+
+// ListWebhookDeliveries returns the most recent deliveries to the webhook,
+// up to limit, or 100 when limit isn't greater than zero.
+func ListWebhookDeliveries(name string, limit int) ([]WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = defaultWebhookDeliveriesLimit
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	n, err := conn.EventWebhooks().FindId(name).Count()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrWebhookNotFound
+	}
+	var deliveries []WebhookDelivery
+	err = conn.EventWebhookDeliveries().Find(bson.M{"webhook": name}).Sort("-timestamp").Limit(limit).All(&deliveries)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// webhookCandidatesQuery selects, using the indexes on the filter kinds and
+// target types, the webhooks that may match the event. The candidates are
+// then checked with WebhookFilter.match, which also compares target values.
+func webhookCandidatesQuery(e *Event) bson.M {
+	var kinds []string
+	parts := strings.Split(e.Kind.Name, ".")
+	for i := range parts {
+		kinds = append(kinds, strings.Join(parts[:i+1], "."))
+	}
+	query := bson.M{"$and": []bson.M{
+		{"$or": []bson.M{
+			{"filter.kinds": bson.M{"$in": kinds}},
+			{"filter.kinds": bson.M{"$exists": false}},
+		}},
+		{"$or": []bson.M{
+			{"filter.targets.type": e.Target.Type},
+			{"filter.targets": bson.M{"$exists": false}},
+		}},
+	}}
+	if e.Error == "" {
+		query["filter.erroronly"] = false
+	}
+	return query
+}
+
+// enqueueWebhookDeliveries adds a delivery job for each webhook matching the
+// finished event. Errors are only logged, as they must not fail the event.
+func enqueueWebhookDeliveries(conn *db.Storage, e *Event) {
+	var webhooks []Webhook
+	err := conn.EventWebhooks().Find(webhookCandidatesQuery(e)).All(&webhooks)
+	if err != nil {
+		log.Errorf("[events] unable to list webhooks for event %s: %s", e.UniqueID.Hex(), err)
+		return
+	}
+	for _, w := range webhooks {
+		if !w.Filter.match(e) {
+			continue
+		}
+		key := fmt.Sprintf("%s:%s:%s", webhookDeliveryJob, w.Name, e.UniqueID.Hex())
+		_, err = jobs.Enqueue(webhookDeliveryJob, key, jobs.Params{
+			"webhook": w.Name,
+			"event":   e.UniqueID.Hex(),
+		})
+		if err != nil {
+			log.Errorf("[events] unable to enqueue delivery of event %s to webhook %q: %s", e.UniqueID.Hex(), w.Name, err)
+		}
+	}
+}
+
+// webhookOwnerAllowed returns whether the owner of the webhook is allowed to
+// read the event. Webhooks created before owners were recorded could only be
+// set with the global permission, and keep receiving every event.
+func webhookOwnerAllowed(w *Webhook, evt *Event) (bool, error) {
+	if w.Owner == "" && w.TeamOwner == "" {
+		return true, nil
+	}
+	if w.Owner == "" {
+		return false, nil
+	}
+	user, err := auth.GetUserByEmail(w.Owner)
+	if err == auth.ErrUserNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	perms, err := user.Permissions()
+	if err != nil {
+		return false, err
+	}
+	scheme, err := permission.SafeGet(evt.Allowed.Scheme)
+	if err != nil {
+		return false, nil
+	}
+	return permission.CheckFromPermList(perms, scheme, evt.Allowed.Contexts...), nil
+}
+
+// runWebhookDelivery posts the event to the webhook, logging the attempt.
+// Deliveries of removed events or to removed webhooks are dropped, as are
+// deliveries of events the owner of the webhook is not allowed to read.
+func runWebhookDelivery(params jobs.Params) error {
+	w, err := GetWebhook(params["webhook"])
+	if err == ErrWebhookNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !bson.IsObjectIdHex(params["event"]) {
+		return nil
+	}
+	evt, err := GetByID(bson.ObjectIdHex(params["event"]))
+	if err == ErrEventNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	allowed, err := webhookOwnerAllowed(w, evt)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		log.Debugf("[events] dropping delivery of event %s to webhook %q: owner %q is not allowed to read it", evt.UniqueID.Hex(), w.Name, w.Owner)
+		return nil
+	}
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	delivery := WebhookDelivery{
+		ID:        bson.NewObjectId(),
+		Webhook:   w.Name,
+		EventID:   evt.UniqueID,
+		EventKind: evt.Kind.Name,
+		Timestamp: time.Now().UTC(),
+	}
+	deliveryErr := postWebhook(w, &delivery, body)
+	if deliveryErr != nil {
+		delivery.Error = deliveryErr.Error()
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.EventWebhookDeliveries().Insert(delivery)
+	if err != nil {
+		log.Errorf("[events] unable to log delivery of event %s to webhook %q: %s", evt.UniqueID.Hex(), w.Name, err)
+	}
+	return deliveryErr
+}
+
+func postWebhook(w *Webhook, delivery *WebhookDelivery, body []byte) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write(body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set(WebhookEventHeader, delivery.EventKind)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID.Hex())
+	rsp, err := webhookClient.Do(req)
+	delivery.Duration = time.Since(delivery.Timestamp)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	io.Copy(ioutil.Discard, rsp.Body)
+	delivery.StatusCode = rsp.StatusCode
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return errors.Errorf("webhook %q answered with status %d", w.Name, rsp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/jobs"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetWebhook(c *check.C) {
+	webhook := Webhook{
+		Name:   "chat",
+		URL:    "https://chat.example.com/hook",
+		Filter: WebhookFilter{Kinds: []string{"app.deploy"}},
+	}
+	err := SetWebhook(&webhook)
+	c.Assert(err, check.IsNil)
+	c.Assert(webhook.Secret, check.Matches, "[0-9a-f]{64}")
+	secret := webhook.Secret
+	webhook.Secret = ""
+	webhook.Filter.ErrorOnly = true
+	err = SetWebhook(&webhook)
+	c.Assert(err, check.IsNil)
+	c.Assert(webhook.Secret, check.Equals, secret)
+	err = SetWebhook(&Webhook{Name: "incidents", URL: "http://incidents.example.com", Secret: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	webhooks, err := ListWebhooks()
+	c.Assert(err, check.IsNil)
+	c.Assert(webhooks, check.DeepEquals, []Webhook{
+		{Name: "chat", URL: "https://chat.example.com/hook", Filter: WebhookFilter{Kinds: []string{"app.deploy"}, ErrorOnly: true}},
+		{Name: "incidents", URL: "http://incidents.example.com"},
+	})
+	stored, err := GetWebhook("incidents")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Secret, check.Equals, "s3cr3t")
+}
+
+func (s *S) TestSetWebhookInvalid(c *check.C) {
+	webhooks := []Webhook{
+		{URL: "http://chat.example.com"},
+		{Name: "chat"},
+		{Name: "chat", URL: "ftp://chat.example.com"},
+		{Name: "chat", URL: "/hook"},
+		{Name: "chat", URL: "http://chat.example.com", Filter: WebhookFilter{Targets: []Target{{Value: "myapp"}}}},
+	}
+	for _, webhook := range webhooks {
+		err := SetWebhook(&webhook)
+		c.Check(err, check.Equals, ErrInvalidWebhook)
+	}
+	webhooks = []Webhook{
+		{Name: "chat", URL: "http://localhost:8080/hook"},
+		{Name: "chat", URL: "http://10.0.0.1/hook"},
+		{Name: "chat", URL: "http://169.254.169.254/latest/meta-data/"},
+	}
+	for _, webhook := range webhooks {
+		err := SetWebhook(&webhook)
+		c.Check(err, check.Equals, ErrWebhookInternalAddress)
+	}
+}
+
+// allowLocalWebhooks lets webhooks reach the httptest servers used in the
+// tests, which listen on the loopback interface.
+func allowLocalWebhooks() func() {
+	oldValidate, oldClient := validateWebhookURL, webhookClient
+	validateWebhookURL = func(string) error { return nil }
+	webhookClient = http.DefaultClient
+	return func() {
+		validateWebhookURL, webhookClient = oldValidate, oldClient
+	}
+}
+
+func (s *S) TestRemoveWebhook(c *check.C) {
+	err := SetWebhook(&Webhook{Name: "chat", URL: "http://chat.example.com"})
+	c.Assert(err, check.IsNil)
+	err = RemoveWebhook("chat")
+	c.Assert(err, check.IsNil)
+	_, err = GetWebhook("chat")
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+	err = RemoveWebhook("chat")
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+}
+
+func (s *S) TestWebhookFilterMatch(c *check.C) {
+	evt := &Event{eventData: eventData{
+		Target: Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:   Kind{Type: KindTypePermission, Name: "app.update.env.set"},
+	}}
+	tests := []struct {
+		filter WebhookFilter
+		match  bool
+	}{
+		{WebhookFilter{}, true},
+		{WebhookFilter{Kinds: []string{"app.update.env.set"}}, true},
+		{WebhookFilter{Kinds: []string{"app.deploy", "app.update"}}, true},
+		{WebhookFilter{Kinds: []string{"app.update.env"}}, true},
+		{WebhookFilter{Kinds: []string{"app.up"}}, false},
+		{WebhookFilter{Targets: []Target{{Type: TargetTypeApp}}}, true},
+		{WebhookFilter{Targets: []Target{{Type: TargetTypeApp, Value: "myapp"}}}, true},
+		{WebhookFilter{Targets: []Target{{Type: TargetTypeApp, Value: "other"}}}, false},
+		{WebhookFilter{Targets: []Target{{Type: TargetTypePool}}}, false},
+		{WebhookFilter{ErrorOnly: true}, false},
+	}
+	for i, tt := range tests {
+		c.Check(tt.filter.match(evt), check.Equals, tt.match, check.Commentf("test %d", i))
+	}
+	evt.Error = "failed"
+	c.Assert((&WebhookFilter{ErrorOnly: true}).match(evt), check.Equals, true)
+}
+
+func (s *S) TestWebhookDelivery(c *check.C) {
+	var received []map[string]interface{}
+	var kinds []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("s3cr3t"))
+		mac.Write(body)
+		c.Check(r.Header.Get(WebhookSignatureHeader), check.Equals, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		kinds = append(kinds, r.Header.Get(WebhookEventHeader))
+		var data map[string]interface{}
+		json.Unmarshal(body, &data)
+		received = append(received, data)
+	}))
+	defer server.Close()
+	defer allowLocalWebhooks()()
+	err := SetWebhook(&Webhook{
+		Name:   "chat",
+		URL:    server.URL,
+		Secret: "s3cr3t",
+		Filter: WebhookFilter{Kinds: []string{"app.update"}, Targets: []Target{{Type: TargetTypeApp}}},
+	})
+	c.Assert(err, check.IsNil)
+	evt, err := New(&Opts{
+		Target:  Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	other, err := New(&Opts{
+		Target:  Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = other.Done(nil)
+	c.Assert(err, check.IsNil)
+	pending, err := jobs.List(jobs.Filter{Kind: webhookDeliveryJob})
+	c.Assert(err, check.IsNil)
+	c.Assert(pending, check.HasLen, 1)
+	c.Assert(pending[0].Params, check.DeepEquals, jobs.Params{"webhook": "chat", "event": evt.UniqueID.Hex()})
+	err = runWebhookDelivery(pending[0].Params)
+	c.Assert(err, check.IsNil)
+	c.Assert(received, check.HasLen, 1)
+	c.Assert(received[0]["UniqueID"], check.Equals, evt.UniqueID.Hex())
+	c.Assert(kinds, check.DeepEquals, []string{"app.update.env.set"})
+	deliveries, err := ListWebhookDeliveries("chat", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 1)
+	c.Assert(deliveries[0].EventID, check.Equals, evt.UniqueID)
+	c.Assert(deliveries[0].EventKind, check.Equals, "app.update.env.set")
+	c.Assert(deliveries[0].StatusCode, check.Equals, http.StatusOK)
+	c.Assert(deliveries[0].Error, check.Equals, "")
+}
+
+func (s *S) TestWebhookDeliveryFailure(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	defer allowLocalWebhooks()()
+	err := SetWebhook(&Webhook{Name: "chat", URL: server.URL})
+	c.Assert(err, check.IsNil)
+	evt, err := New(&Opts{
+		Target:  Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(errors.New("env set failed"))
+	c.Assert(err, check.IsNil)
+	params := jobs.Params{"webhook": "chat", "event": evt.UniqueID.Hex()}
+	err = runWebhookDelivery(params)
+	c.Assert(err, check.ErrorMatches, `webhook "chat" answered with status 503`)
+	err = runWebhookDelivery(params)
+	c.Assert(err, check.NotNil)
+	deliveries, err := ListWebhookDeliveries("chat", 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 1)
+	c.Assert(deliveries[0].StatusCode, check.Equals, http.StatusServiceUnavailable)
+	c.Assert(deliveries[0].Error, check.Equals, `webhook "chat" answered with status 503`)
+	deliveries, err = ListWebhookDeliveries("chat", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 2)
+	err = RemoveWebhook("chat")
+	c.Assert(err, check.IsNil)
+	err = runWebhookDelivery(params)
+	c.Assert(err, check.IsNil)
+	_, err = ListWebhookDeliveries("chat", 0)
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+}
+
+func (s *S) TestWebhookDeliveryOwnerNotAllowed(c *check.C) {
+	var called int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+	}))
+	defer server.Close()
+	defer allowLocalWebhooks()()
+	err := SetWebhook(&Webhook{Name: "chat", URL: server.URL, TeamOwner: "myteam", Owner: s.token.GetUserName()})
+	c.Assert(err, check.IsNil)
+	evt, err := New(&Opts{
+		Target:  Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxApp, "myapp")),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	params := jobs.Params{"webhook": "chat", "event": evt.UniqueID.Hex()}
+	err = runWebhookDelivery(params)
+	c.Assert(err, check.IsNil)
+	c.Assert(called, check.Equals, 0)
+	deliveries, err := ListWebhookDeliveries("chat", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 0)
+	role, err := permission.NewRole("app-reader", string(permission.CtxApp), "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.read.events")
+	c.Assert(err, check.IsNil)
+	user, err := auth.GetUserByEmail(s.token.GetUserName())
+	c.Assert(err, check.IsNil)
+	err = user.AddRole("app-reader", "myapp")
+	c.Assert(err, check.IsNil)
+	err = runWebhookDelivery(params)
+	c.Assert(err, check.IsNil)
+	c.Assert(called, check.Equals, 1)
+}
+
+func (s *S) TestWebhookCandidatesQuery(c *check.C) {
+	webhooks := []Webhook{
+		{Name: "all", URL: "https://all.example.com"},
+		{Name: "app-update", URL: "https://a.example.com", Filter: WebhookFilter{Kinds: []string{"app.update"}}},
+		{Name: "app-deploy", URL: "https://b.example.com", Filter: WebhookFilter{Kinds: []string{"app.deploy"}}},
+		{Name: "apps", URL: "https://c.example.com", Filter: WebhookFilter{Targets: []Target{{Type: TargetTypeApp}}}},
+		{Name: "pools", URL: "https://d.example.com", Filter: WebhookFilter{Targets: []Target{{Type: TargetTypePool}}}},
+		{Name: "errors", URL: "https://e.example.com", Filter: WebhookFilter{ErrorOnly: true}},
+	}
+	for i := range webhooks {
+		err := SetWebhook(&webhooks[i])
+		c.Assert(err, check.IsNil)
+	}
+	evt := &Event{eventData: eventData{
+		Target: Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:   Kind{Type: KindTypePermission, Name: "app.update.env.set"},
+	}}
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	var candidates []Webhook
+	err = conn.EventWebhooks().Find(webhookCandidatesQuery(evt)).Sort("_id").All(&candidates)
+	c.Assert(err, check.IsNil)
+	var names []string
+	for _, w := range candidates {
+		names = append(names, w.Name)
+	}
+	c.Assert(names, check.DeepEquals, []string{"all", "app-update", "apps"})
+}
@@ -27,6 +27,7 @@ import (
 const (
 	nodeHealerConfigCollection = "node-healer"
 	poolMetadataName           = "pool"
+	nodeChecksWindow           = 10
 )
 
 type NodeHealer struct {
@@ -48,9 +49,13 @@ type NodeHealerConfig struct {
 	Enabled                      *bool
 	MaxTimeSinceSuccess          *int
 	MaxUnresponsiveTime          *int
+	MaxRiskScore                 *float64
+	CordonOnRisk                 *bool
 	EnabledInherited             bool
 	MaxTimeSinceSuccessInherited bool
 	MaxUnresponsiveTimeInherited bool
+	MaxRiskScoreInherited        bool
+	CordonOnRiskInherited        bool
 }
 
 type NodeStatusData struct {
@@ -58,6 +63,7 @@ type NodeStatusData struct {
 	Checks      []NodeChecks `bson:",omitempty"`
 	LastSuccess time.Time    `bson:",omitempty"`
 	LastUpdate  time.Time
+	Cordoned    bool `bson:",omitempty"`
 }
 
 type NodeChecks struct {
@@ -301,7 +307,7 @@ func (h *NodeHealer) UpdateNodeData(node provision.Node, checks []provision.Node
 		"$push": bson.M{
 			"checks": bson.D([]bson.DocElem{
 				{Name: "$each", Value: []NodeChecks{{Time: now, Checks: checks}}},
-				{Name: "$slice", Value: -10},
+				{Name: "$slice", Value: -nodeChecksWindow},
 			}),
 		},
 	})
@@ -470,6 +476,7 @@ func (h *NodeHealer) runActiveHealing() {
 			log.Errorf("[node healer active] %s", err)
 		}
 	}
+	h.cordonRiskyNodes(nodesAddrMap)
 }
 
 func UpdateConfig(pool string, config NodeHealerConfig) error {
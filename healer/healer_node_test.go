@@ -7,6 +7,7 @@ package healer
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"runtime"
 	"sync"
 	"time"
@@ -838,6 +839,8 @@ func (s *S) TestUpdateConfigIgnoresEmpty(c *check.C) {
 		EnabledInherited:             true,
 		MaxUnresponsiveTimeInherited: true,
 		MaxTimeSinceSuccessInherited: true,
+		MaxRiskScoreInherited:        true,
+		CordonOnRiskInherited:        true,
 	})
 	err = UpdateConfig("p1", NodeHealerConfig{
 		MaxTimeSinceSuccess: intPtr(2),
@@ -853,6 +856,8 @@ func (s *S) TestUpdateConfigIgnoresEmpty(c *check.C) {
 		EnabledInherited:             true,
 		MaxUnresponsiveTimeInherited: true,
 		MaxTimeSinceSuccessInherited: false,
+		MaxRiskScoreInherited:        true,
+		CordonOnRiskInherited:        true,
 	})
 	err = UpdateConfig("p1", NodeHealerConfig{
 		MaxTimeSinceSuccess: intPtr(2),
@@ -869,9 +874,148 @@ func (s *S) TestUpdateConfigIgnoresEmpty(c *check.C) {
 		EnabledInherited:             true,
 		MaxUnresponsiveTimeInherited: false,
 		MaxTimeSinceSuccessInherited: false,
+		MaxRiskScoreInherited:        true,
+		CordonOnRiskInherited:        true,
 	})
 }
 
+func riskChecks(start time.Time, failed []bool, latency []time.Duration) []NodeChecks {
+	checks := make([]NodeChecks, len(failed))
+	for i := range failed {
+		checks[i] = NodeChecks{
+			Time: start.Add(time.Duration(i) * time.Minute),
+			Checks: []provision.NodeCheckResult{
+				{Name: "docker", Successful: !failed[i], Duration: latency[i]},
+			},
+		}
+	}
+	return checks
+}
+
+func (s *S) TestCalculateRisk(c *check.C) {
+	start := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	ms := time.Millisecond
+	data := NodeStatusData{
+		Address: "http://addr1:1",
+		Checks: riskChecks(start,
+			[]bool{false, false, false, true},
+			[]time.Duration{10 * ms, 10 * ms, 15 * ms, 15 * ms},
+		),
+		LastUpdate: start.Add(3 * time.Minute),
+	}
+	risk := calculateRisk(data, start.Add(5*time.Minute))
+	c.Assert(math.Abs(risk.Score-0.35) < 1e-9, check.Equals, true)
+	risk.Score = 0
+	c.Assert(risk, check.DeepEquals, NodeRisk{
+		Address:      "http://addr1:1",
+		FailureRate:  0.25,
+		FailureTrend: 0.5,
+		LatencyTrend: 0.5,
+		Staleness:    0.25,
+		Checks:       4,
+	})
+}
+
+func (s *S) TestCalculateRiskHealthyNode(c *check.C) {
+	start := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	data := NodeStatusData{
+		Checks:     riskChecks(start, make([]bool, 6), make([]time.Duration, 6)),
+		LastUpdate: start.Add(5 * time.Minute),
+	}
+	risk := calculateRisk(data, start.Add(5*time.Minute))
+	c.Assert(risk.Score, check.Equals, 0.0)
+	c.Assert(risk.Checks, check.Equals, 6)
+}
+
+func (s *S) TestCalculateRiskNotEnoughChecks(c *check.C) {
+	start := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	data := NodeStatusData{
+		Checks:     riskChecks(start, []bool{true, true}, make([]time.Duration, 2)),
+		LastUpdate: start,
+	}
+	risk := calculateRisk(data, start.Add(time.Hour))
+	c.Assert(risk, check.DeepEquals, NodeRisk{Checks: 2})
+}
+
+func (s *S) TestCordonRiskyNodes(c *check.C) {
+	conf := healerConfig()
+	err := conf.SaveBase(NodeHealerConfig{MaxRiskScore: floatPtr(0.3), CordonOnRisk: boolPtr(true)})
+	c.Assert(err, check.IsNil)
+	p := provisiontest.ProvisionerInstance
+	nodeAddr := "http://addr1:1"
+	err = p.AddNode(provision.AddNodeOptions{Address: nodeAddr})
+	c.Assert(err, check.IsNil)
+	node, err := p.GetNode(nodeAddr)
+	c.Assert(err, check.IsNil)
+	healer := newNodeHealer(nodeHealerArgs{})
+	healer.Shutdown()
+	coll, err := nodeDataCollection()
+	c.Assert(err, check.IsNil)
+	defer coll.Close()
+	now := time.Now().UTC()
+	err = coll.Insert(NodeStatusData{
+		Address:    nodeAddr,
+		Checks:     riskChecks(now.Add(-3*time.Minute), []bool{true, true, true, true}, make([]time.Duration, 4)),
+		LastUpdate: now,
+	})
+	c.Assert(err, check.IsNil)
+	healer.cordonRiskyNodes(map[string]provision.Node{nodeAddr: node})
+	node, err = p.GetNode(nodeAddr)
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Status(), check.Equals, "disabled")
+	var data NodeStatusData
+	err = coll.FindId(nodeAddr).One(&data)
+	c.Assert(err, check.IsNil)
+	c.Assert(data.Cordoned, check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNode, Value: nodeAddr},
+		Kind:   "healer-cordon",
+	}, eventtest.HasEvent)
+	err = coll.UpdateId(nodeAddr, bson.M{"$set": bson.M{
+		"checks": riskChecks(now.Add(-3*time.Minute), make([]bool, 4), make([]time.Duration, 4)),
+	}})
+	c.Assert(err, check.IsNil)
+	healer.cordonRiskyNodes(map[string]provision.Node{nodeAddr: node})
+	node, err = p.GetNode(nodeAddr)
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Status(), check.Equals, "enabled")
+	err = coll.FindId(nodeAddr).One(&data)
+	c.Assert(err, check.IsNil)
+	c.Assert(data.Cordoned, check.Equals, false)
+}
+
+func (s *S) TestCordonRiskyNodesDisabled(c *check.C) {
+	conf := healerConfig()
+	err := conf.SaveBase(NodeHealerConfig{MaxRiskScore: floatPtr(0.3)})
+	c.Assert(err, check.IsNil)
+	p := provisiontest.ProvisionerInstance
+	nodeAddr := "http://addr1:1"
+	err = p.AddNode(provision.AddNodeOptions{Address: nodeAddr})
+	c.Assert(err, check.IsNil)
+	node, err := p.GetNode(nodeAddr)
+	c.Assert(err, check.IsNil)
+	healer := newNodeHealer(nodeHealerArgs{})
+	healer.Shutdown()
+	coll, err := nodeDataCollection()
+	c.Assert(err, check.IsNil)
+	defer coll.Close()
+	now := time.Now().UTC()
+	err = coll.Insert(NodeStatusData{
+		Address:    nodeAddr,
+		Checks:     riskChecks(now.Add(-3*time.Minute), []bool{true, true, true, true}, make([]time.Duration, 4)),
+		LastUpdate: now,
+	})
+	c.Assert(err, check.IsNil)
+	healer.cordonRiskyNodes(map[string]provision.Node{nodeAddr: node})
+	node, err = p.GetNode(nodeAddr)
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Status(), check.Not(check.Equals), "disabled")
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
 func boolPtr(b bool) *bool {
 	return &b
 }
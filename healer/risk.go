@@ -0,0 +1,206 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package healer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// minRiskChecks is the minimum number of check rounds in the window of a node
+// before a risk score is calculated for it.
+const minRiskChecks = 4
+
+// NodeRisk is the likelihood, from 0 to 1, of a node becoming unresponsive,
+// calculated from the trend of the last checks reported by the node.
+type NodeRisk struct {
+	Address      string
+	Pool         string
+	Score        float64
+	FailureRate  float64
+	FailureTrend float64
+	LatencyTrend float64
+	Staleness    float64
+	Checks       int
+	Cordoned     bool
+}
+
+// calculateRisk scores the rolling window of checks of a node. The score is
+// a weighted sum of the ratio of failed check rounds, the growth of failures
+// and of check latency in the most recent half of the window and how late the
+// node is in reporting its checks, compared to its usual interval.
+func calculateRisk(data NodeStatusData, now time.Time) NodeRisk {
+	risk := NodeRisk{
+		Address:  data.Address,
+		Checks:   len(data.Checks),
+		Cordoned: data.Cordoned,
+	}
+	if len(data.Checks) < minRiskChecks {
+		return risk
+	}
+	failed := make([]float64, len(data.Checks))
+	latency := make([]float64, len(data.Checks))
+	for i, round := range data.Checks {
+		for _, c := range round.Checks {
+			if !c.Successful {
+				failed[i] = 1
+			}
+			if d := float64(c.Duration); d > latency[i] {
+				latency[i] = d
+			}
+		}
+	}
+	half := len(data.Checks) / 2
+	risk.FailureRate = mean(failed)
+	risk.FailureTrend = clamp(mean(failed[half:]) - mean(failed[:half]))
+	if older := mean(latency[:half]); older > 0 {
+		risk.LatencyTrend = clamp(mean(latency[half:])/older - 1)
+	}
+	first, last := data.Checks[0].Time, data.Checks[len(data.Checks)-1].Time
+	interval := last.Sub(first) / time.Duration(len(data.Checks)-1)
+	if interval > 0 {
+		late := float64(now.Sub(data.LastUpdate)) / float64(interval)
+		risk.Staleness = clamp((late - 1) / 4)
+	}
+	risk.Score = clamp(0.4*risk.FailureRate + 0.2*risk.FailureTrend + 0.2*risk.LatencyTrend + 0.2*risk.Staleness)
+	return risk
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// NodesRisk returns the risk score of each of the given nodes that have
+// reported their status.
+func NodesRisk(nodes []provision.Node) ([]NodeRisk, error) {
+	addresses := make([]string, len(nodes))
+	pools := make(map[string]string, len(nodes))
+	for i, n := range nodes {
+		addresses[i] = n.Address()
+		pools[n.Address()] = n.Pool()
+	}
+	coll, err := nodeDataCollection()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get node data collection")
+	}
+	defer coll.Close()
+	var nodesStatus []NodeStatusData
+	err = coll.Find(bson.M{"_id": bson.M{"$in": addresses}}).All(&nodesStatus)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find nodes status")
+	}
+	now := time.Now().UTC()
+	result := make([]NodeRisk, len(nodesStatus))
+	for i, data := range nodesStatus {
+		result[i] = calculateRisk(data, now)
+		result[i].Pool = pools[data.Address]
+	}
+	return result, nil
+}
+
+// cordonRiskyNodes disables nodes whose risk score reached the MaxRiskScore
+// of their pool, when CordonOnRisk is set, so that no new units are placed on
+// them before they fail. Nodes cordoned by the healer are enabled again once
+// their score drops below the limit.
+func (h *NodeHealer) cordonRiskyNodes(nodesAddrMap map[string]provision.Node) {
+	if len(nodesAddrMap) == 0 {
+		return
+	}
+	nodes := make([]provision.Node, 0, len(nodesAddrMap))
+	for _, n := range nodesAddrMap {
+		nodes = append(nodes, n)
+	}
+	risks, err := NodesRisk(nodes)
+	if err != nil {
+		log.Errorf("[node healer risk] %s", err)
+		return
+	}
+	conf := healerConfig()
+	configs := map[string]*NodeHealerConfig{}
+	for _, risk := range risks {
+		config, ok := configs[risk.Pool]
+		if !ok {
+			config = &NodeHealerConfig{}
+			err = conf.Load(risk.Pool, config)
+			if err != nil {
+				log.Errorf("[node healer risk] unable to load config for pool %q: %s", risk.Pool, err)
+				continue
+			}
+			configs[risk.Pool] = config
+		}
+		if config.CordonOnRisk == nil || !*config.CordonOnRisk ||
+			config.MaxRiskScore == nil || *config.MaxRiskScore <= 0 {
+			continue
+		}
+		atRisk := risk.Score >= *config.MaxRiskScore
+		if atRisk == risk.Cordoned {
+			continue
+		}
+		err = h.setNodeCordoned(nodesAddrMap[risk.Address], risk, atRisk)
+		if err != nil {
+			log.Errorf("[node healer risk] %s", err)
+		}
+	}
+}
+
+func (h *NodeHealer) setNodeCordoned(node provision.Node, risk NodeRisk, cordoned bool) (err error) {
+	reason := fmt.Sprintf("risk score %.2f", risk.Score)
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeNode, Value: node.Address()},
+		InternalKind: "healer-cordon",
+		CustomData: map[string]interface{}{
+			"risk":     risk,
+			"cordoned": cordoned,
+		},
+		Allowed: event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, risk.Pool)),
+	})
+	if err != nil {
+		if _, ok := err.(event.ErrEventLocked); ok {
+			return nil
+		}
+		return errors.Wrap(err, "Error trying to insert node cordon event")
+	}
+	defer func() { evt.Done(err) }()
+	opts := provision.UpdateNodeOptions{Address: node.Address(), Enable: !cordoned, Disable: cordoned}
+	err = node.Provisioner().UpdateNode(opts)
+	if err != nil {
+		return errors.Wrapf(err, "unable to update node %s with %s", node.Address(), reason)
+	}
+	coll, err := nodeDataCollection()
+	if err != nil {
+		return errors.Wrap(err, "unable to get node data collection")
+	}
+	defer coll.Close()
+	err = coll.UpdateId(node.Address(), bson.M{"$set": bson.M{"cordoned": cordoned}})
+	if err != nil {
+		return errors.Wrapf(err, "unable to store cordon state of node %s", node.Address())
+	}
+	log.Errorf("node %q cordoned=%v by healer due to %s", node.Address(), cordoned, reason)
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// FrameContentType is the content type of responses streaming structured
+// JSON frames, one frame per line.
+const FrameContentType = "application/x-json-frames"
+
+type FrameType string
+
+const (
+	// FrameStep starts a new step of a long running operation, e.g. building
+	// the image of an app.
+	FrameStep FrameType = "step"
+	// FrameProgress reports the progress of a transfer, e.g. an image pull.
+	FrameProgress FrameType = "progress"
+	// FrameMessage carries a line of output.
+	FrameMessage FrameType = "message"
+	// FrameError reports the error that interrupted the operation.
+	FrameError FrameType = "error"
+	// FrameKeepAlive is periodically sent when there's no output, and may be
+	// ignored by clients.
+	FrameKeepAlive FrameType = "keepalive"
+)
+
+// Frame is a structured message in the output of a streaming endpoint.
+type Frame struct {
+	Type     FrameType       `json:"type"`
+	Message  string          `json:"message,omitempty"`
+	ID       string          `json:"id,omitempty"`
+	Progress *ProgressDetail `json:"progress,omitempty"`
+}
+
+type ProgressDetail struct {
+	Current int64 `json:"current"`
+	Total   int64 `json:"total"`
+}
+
+// FrameWriter converts raw output, including the JSON messages sent by the
+// docker daemon, into frames encoded as JSON, one per line.
+type FrameWriter struct {
+	encoder *json.Encoder
+	buf     []byte
+	mu      sync.Mutex
+}
+
+func NewFrameWriter(w io.Writer) *FrameWriter {
+	return &FrameWriter{encoder: json.NewEncoder(w)}
+}
+
+// KeepAliveFrame returns the encoded frame to be used as the message of a
+// keepalive writer.
+func KeepAliveFrame() string {
+	data, _ := json.Marshal(Frame{Type: FrameKeepAlive})
+	return string(data)
+}
+
+func (w *FrameWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, b...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := string(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+		err := w.writeLine(line)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// WriteFrame encodes the frame after any pending output.
+func (w *FrameWriter) WriteFrame(f Frame) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.flush()
+	if err != nil {
+		return err
+	}
+	return w.encoder.Encode(f)
+}
+
+// Close writes any pending output not terminated by a new line.
+func (w *FrameWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+func (w *FrameWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := string(w.buf)
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+func (w *FrameWriter) writeLine(line string) error {
+	line = strings.TrimRight(line, "\r")
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	return w.encoder.Encode(parseFrame(line))
+}
+
+func parseFrame(line string) Frame {
+	trimmed := strings.TrimSpace(line)
+	if likeJSON(trimmed) {
+		var msg jsonmessage.JSONMessage
+		if json.Unmarshal([]byte(trimmed), &msg) == nil {
+			return frameFromJSONMessage(msg)
+		}
+	}
+	if strings.HasPrefix(trimmed, "---- ") {
+		step := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(trimmed, "---- "), "----"))
+		return Frame{Type: FrameStep, Message: step}
+	}
+	return Frame{Type: FrameMessage, Message: line}
+}
+
+func frameFromJSONMessage(msg jsonmessage.JSONMessage) Frame {
+	if msg.Error != nil {
+		return Frame{Type: FrameError, ID: msg.ID, Message: msg.Error.Message}
+	}
+	if msg.ErrorMessage != "" {
+		return Frame{Type: FrameError, ID: msg.ID, Message: msg.ErrorMessage}
+	}
+	if msg.Progress != nil && msg.Progress.Total > 0 {
+		return Frame{
+			Type:     FrameProgress,
+			ID:       msg.ID,
+			Message:  msg.Status,
+			Progress: &ProgressDetail{Current: msg.Progress.Current, Total: msg.Progress.Total},
+		}
+	}
+	text := msg.Status
+	if msg.Stream != "" {
+		text = strings.TrimRight(msg.Stream, "\n")
+	}
+	return Frame{Type: FrameMessage, ID: msg.ID, Message: text}
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"gopkg.in/check.v1"
+)
+
+func decodeFrames(c *check.C, data []byte) []Frame {
+	var frames []Frame
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var f Frame
+		err := decoder.Decode(&f)
+		c.Assert(err, check.IsNil)
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+func (s *S) TestFrameWriterPlainText(c *check.C) {
+	var buf bytes.Buffer
+	w := NewFrameWriter(&buf)
+	w.Write([]byte("---- Building application image ----\n ---> Sending image to reg"))
+	w.Write([]byte("istry\n\nsome output\n"))
+	w.Write([]byte("no new line"))
+	c.Assert(decodeFrames(c, buf.Bytes()), check.HasLen, 3)
+	err := w.Close()
+	c.Assert(err, check.IsNil)
+	c.Assert(decodeFrames(c, buf.Bytes()), check.DeepEquals, []Frame{
+		{Type: FrameStep, Message: "Building application image"},
+		{Type: FrameMessage, Message: " ---> Sending image to registry"},
+		{Type: FrameMessage, Message: "some output"},
+		{Type: FrameMessage, Message: "no new line"},
+	})
+}
+
+func (s *S) TestFrameWriterDockerMessages(c *check.C) {
+	var buf bytes.Buffer
+	w := NewFrameWriter(&buf)
+	w.Write([]byte(`{"status":"Downloading","progressDetail":{"current":10,"total":100},"id":"a1b2"}` + "\n"))
+	w.Write([]byte(`{"stream":"Step 1/3 : FROM tsuru/python\n"}` + "\n"))
+	w.Write([]byte(`{"errorDetail":{"message":"pull failed"},"error":"pull failed"}` + "\n"))
+	c.Assert(decodeFrames(c, buf.Bytes()), check.DeepEquals, []Frame{
+		{Type: FrameProgress, ID: "a1b2", Message: "Downloading", Progress: &ProgressDetail{Current: 10, Total: 100}},
+		{Type: FrameMessage, Message: "Step 1/3 : FROM tsuru/python"},
+		{Type: FrameError, Message: "pull failed"},
+	})
+}
+
+func (s *S) TestFrameWriterWriteFrameFlushesPending(c *check.C) {
+	var buf bytes.Buffer
+	w := NewFrameWriter(&buf)
+	w.Write([]byte("partial"))
+	err := w.WriteFrame(Frame{Type: FrameError, Message: errors.New("failed").Error()})
+	c.Assert(err, check.IsNil)
+	c.Assert(decodeFrames(c, buf.Bytes()), check.DeepEquals, []Frame{
+		{Type: FrameMessage, Message: "partial"},
+		{Type: FrameError, Message: "failed"},
+	})
+}
+
+func (s *S) TestKeepAliveFrame(c *check.C) {
+	c.Assert(KeepAliveFrame(), check.Equals, `{"type":"keepalive"}`)
+}
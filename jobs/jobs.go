@@ -0,0 +1,417 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jobs runs background work that must survive API restarts, like
+// removing old images and cleaning up routes of removed apps. Jobs are stored
+// in the database and retried with exponential backoff until they succeed or
+// run out of attempts, so their handlers must be idempotent.
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Status is the state of a job.
+type Status string
+
+const (
+	StatusPending   = Status("pending")
+	StatusRunning   = Status("running")
+	StatusSucceeded = Status("succeeded")
+	StatusFailed    = Status("failed")
+)
+
+var (
+	ErrJobNotFound      = errors.New("job not found")
+	ErrJobNotFailed     = errors.New("only failed jobs can be retried")
+	ErrJobKeyInUse      = errors.New("there's already an unfinished job with the same key")
+	ErrKindRequired     = errors.New("job kind is required")
+	ErrInvalidJobStatus = errors.Errorf("invalid job status: must be %q, %q, %q or %q", StatusPending, StatusRunning, StatusSucceeded, StatusFailed)
+)
+
+var (
+	now = time.Now
+	// backoffBase is the delay before the first retry of a job, doubled on
+	// each following attempt up to backoffMax.
+	backoffBase = 10 * time.Second
+	backoffMax  = time.Hour
+	// lockTimeout is how long a running job is owned by the API instance
+	// running it. Jobs running for longer are considered lost, like when the
+	// instance is restarted, and run again.
+	lockTimeout = 10 * time.Minute
+	// enqueueRetries is the number of times Enqueue looks up the unfinished
+	// job with the same key after losing the race to create it.
+	enqueueRetries = 3
+)
+
+// Params are the parameters given to the handler of a job.
+type Params map[string]string
+
+// Handler runs jobs of a kind. Handlers may be called more than once for the
+// same job, so running a job that already did its work must succeed.
+type Handler func(params Params) error
+
+// RunAgainError is returned by handlers of jobs that aren't finished yet,
+// like periodic checks, to have them run again after Delay. The attempt isn't
+// counted as a failure and the params changed by the handler are saved.
+type RunAgainError struct {
+	Delay time.Duration
+}
+
+func (e *RunAgainError) Error() string {
+	return fmt.Sprintf("job must run again in %v", e.Delay)
+}
+
+// RunAgain returns a RunAgainError with the given delay.
+func RunAgain(delay time.Duration) error {
+	return &RunAgainError{Delay: delay}
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+)
+
+// Register sets the handler of jobs of the given kind. Jobs of kinds without
+// a handler are kept pending.
+func Register(kind string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[kind] = handler
+}
+
+func getHandler(kind string) Handler {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	return handlers[kind]
+}
+
+func registeredKinds() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	kinds := make([]string, 0, len(handlers))
+	for k := range handlers {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Job is a unit of background work. Jobs enqueued with the same Key are the
+// same job while it isn't finished. Active is set while the job is pending or
+// running, and is what the unique index of keys is restricted to.
+type Job struct {
+	ID          bson.ObjectId `bson:"_id" json:"id"`
+	Kind        string        `json:"kind"`
+	Key         string        `json:"key,omitempty" bson:",omitempty"`
+	Params      Params        `json:"params,omitempty"`
+	Status      Status        `json:"status"`
+	Attempts    int           `json:"attempts"`
+	MaxAttempts int           `json:"maxAttempts"`
+	LastError   string        `json:"lastError,omitempty"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+	NextRun     time.Time     `json:"nextRun"`
+	LockedUntil time.Time     `json:"-"`
+	Active      bool          `json:"-"`
+}
+
+// Filter narrows the listing of jobs. Empty fields are ignored.
+type Filter struct {
+	Kind   string
+	Status Status
+	Limit  int
+}
+
+func collection(conn *db.Storage) *storage.Collection {
+	coll := conn.Collection("jobs")
+	coll.EnsureIndex(mgo.Index{Key: []string{"status", "nextrun"}})
+	return coll
+}
+
+// ensureKeyIndex creates the unique index of keys of active jobs, which
+// prevents concurrent Enqueue calls from creating two unfinished jobs with the
+// same key. mgo.Index doesn't support partial indexes, so the index is
+// created with the createIndexes command, which requires MongoDB 3.2 or
+// later.
+func ensureKeyIndex() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := collection(conn)
+	err = coll.Database.Run(bson.D{
+		{Name: "createIndexes", Value: coll.Name},
+		{Name: "indexes", Value: []bson.M{{
+			"key":    bson.M{"key": 1},
+			"name":   "key_active_unique",
+			"unique": true,
+			"partialFilterExpression": bson.M{
+				"key":    bson.M{"$exists": true},
+				"active": true,
+			},
+		}}},
+	}, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to create the unique index of job keys, partial indexes require MongoDB 3.2 or later")
+	}
+	return nil
+}
+
+// maxAttempts returns the number of times a job is run before being marked
+// as failed.
+func maxAttempts() int {
+	attempts, _ := config.GetInt("jobs:max-attempts")
+	if attempts <= 0 {
+		return 10
+	}
+	return attempts
+}
+
+// Enqueue adds a job of the given kind. When key isn't empty and there's an
+// unfinished job with the same key, the existing job is returned instead.
+func Enqueue(kind, key string, params Params) (*Job, error) {
+	if kind == "" {
+		return nil, ErrKindRequired
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	t := now().UTC()
+	job := Job{
+		ID:          bson.NewObjectId(),
+		Kind:        kind,
+		Key:         key,
+		Params:      params,
+		Status:      StatusPending,
+		Active:      true,
+		MaxAttempts: maxAttempts(),
+		CreatedAt:   t,
+		UpdatedAt:   t,
+		NextRun:     t,
+	}
+	if key == "" {
+		err = collection(conn).Insert(job)
+		if err != nil {
+			return nil, err
+		}
+		return &job, nil
+	}
+	// Concurrent upserts may all miss the unfinished job and try to insert
+	// it, the unique index lets only one of them succeed and the others
+	// find the inserted job when trying again.
+	query := bson.M{"key": key, "status": bson.M{"$in": []Status{StatusPending, StatusRunning}}}
+	for i := 0; ; i++ {
+		_, err = collection(conn).Find(query).Apply(mgo.Change{
+			Update:    bson.M{"$setOnInsert": job},
+			Upsert:    true,
+			ReturnNew: true,
+		}, &job)
+		if mgo.IsDup(err) && i < enqueueRetries {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &job, nil
+	}
+}
+
+// Get returns the job with the given ID.
+func Get(id string) (*Job, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrJobNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var job Job
+	err = collection(conn).FindId(bson.ObjectIdHex(id)).One(&job)
+	if err == mgo.ErrNotFound {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// List returns the jobs matching the filter, most recent first.
+func List(filter Filter) ([]Job, error) {
+	query := bson.M{}
+	if filter.Kind != "" {
+		query["kind"] = filter.Kind
+	}
+	if filter.Status != "" {
+		switch filter.Status {
+		case StatusPending, StatusRunning, StatusSucceeded, StatusFailed:
+		default:
+			return nil, ErrInvalidJobStatus
+		}
+		query["status"] = filter.Status
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	q := collection(conn).Find(query).Sort("-createdat")
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+	var jobs []Job
+	err = q.All(&jobs)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Retry enqueues a failed job again, resetting its attempts.
+func Retry(id string) (*Job, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrJobNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	t := now().UTC()
+	var job Job
+	_, err = collection(conn).Find(bson.M{"_id": bson.ObjectIdHex(id), "status": StatusFailed}).Apply(mgo.Change{
+		Update: bson.M{"$set": bson.M{
+			"status":    StatusPending,
+			"active":    true,
+			"attempts":  0,
+			"nextrun":   t,
+			"updatedat": t,
+		}},
+		ReturnNew: true,
+	}, &job)
+	if err == mgo.ErrNotFound {
+		if _, getErr := Get(id); getErr != nil {
+			return nil, getErr
+		}
+		return nil, ErrJobNotFailed
+	}
+	if mgo.IsDup(err) {
+		return nil, ErrJobKeyInUse
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RunPending runs the jobs that are due, including running jobs whose lock
+// expired. It returns the number of jobs run.
+func RunPending() (int, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	kinds := registeredKinds()
+	var count int
+	for {
+		job, err := claim(conn, kinds)
+		if err == mgo.ErrNotFound {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		err = runJob(conn, job)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func claim(conn *db.Storage, kinds []string) (*Job, error) {
+	t := now().UTC()
+	query := bson.M{
+		"kind": bson.M{"$in": kinds},
+		"$or": []bson.M{
+			{"status": StatusPending, "nextrun": bson.M{"$lte": t}},
+			{"status": StatusRunning, "lockeduntil": bson.M{"$lte": t}},
+		},
+	}
+	var job Job
+	_, err := collection(conn).Find(query).Sort("nextrun").Apply(mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{"status": StatusRunning, "lockeduntil": t.Add(lockTimeout), "updatedat": t},
+			"$inc": bson.M{"attempts": 1},
+		},
+		ReturnNew: true,
+	}, &job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func runJob(conn *db.Storage, job *Job) error {
+	handler := getHandler(job.Kind)
+	var runErr error
+	if handler == nil {
+		runErr = errors.Errorf("no handler registered for jobs of kind %q", job.Kind)
+	} else {
+		runErr = handler(job.Params)
+	}
+	t := now().UTC()
+	update := bson.M{"updatedat": t, "lockeduntil": time.Time{}}
+	again, isRunAgain := runErr.(*RunAgainError)
+	switch {
+	case isRunAgain:
+		update["status"] = StatusPending
+		update["attempts"] = job.Attempts - 1
+		update["params"] = job.Params
+		update["lasterror"] = ""
+		update["nextrun"] = t.Add(again.Delay)
+	case runErr == nil:
+		update["status"] = StatusSucceeded
+		update["active"] = false
+		update["lasterror"] = ""
+	case job.Attempts >= job.MaxAttempts:
+		update["status"] = StatusFailed
+		update["active"] = false
+		update["lasterror"] = runErr.Error()
+	default:
+		update["status"] = StatusPending
+		update["lasterror"] = runErr.Error()
+		update["nextrun"] = t.Add(backoff(job.Attempts))
+	}
+	return collection(conn).UpdateId(job.ID, bson.M{"$set": update})
+}
+
+// backoff returns the delay before running a job again after the given
+// number of failed attempts.
+func backoff(attempts int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= backoffMax {
+			return backoffMax
+		}
+	}
+	return delay
+}
@@ -0,0 +1,246 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestEnqueueAndRun(c *check.C) {
+	var got []Params
+	Register("cleanup", func(params Params) error {
+		got = append(got, params)
+		return nil
+	})
+	job, err := Enqueue("cleanup", "", Params{"app": "myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Status, check.Equals, StatusPending)
+	c.Assert(job.MaxAttempts, check.Equals, 10)
+	n, err := RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	c.Assert(got, check.DeepEquals, []Params{{"app": "myapp"}})
+	job, err = Get(job.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Status, check.Equals, StatusSucceeded)
+	c.Assert(job.Attempts, check.Equals, 1)
+	n, err = RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
+
+func (s *S) TestEnqueueKindRequired(c *check.C) {
+	_, err := Enqueue("", "", nil)
+	c.Assert(err, check.Equals, ErrKindRequired)
+}
+
+func (s *S) TestEnqueueSameKey(c *check.C) {
+	Register("cleanup", func(params Params) error { return nil })
+	job1, err := Enqueue("cleanup", "cleanup:myapp", Params{"app": "myapp"})
+	c.Assert(err, check.IsNil)
+	job2, err := Enqueue("cleanup", "cleanup:myapp", Params{"app": "myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(job2.ID, check.Equals, job1.ID)
+	_, err = RunPending()
+	c.Assert(err, check.IsNil)
+	job3, err := Enqueue("cleanup", "cleanup:myapp", Params{"app": "myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(job3.ID, check.Not(check.Equals), job1.ID)
+	list, err := List(Filter{})
+	c.Assert(err, check.IsNil)
+	c.Assert(list, check.HasLen, 2)
+}
+
+func (s *S) TestEnqueueSameKeyConcurrently(c *check.C) {
+	var wg sync.WaitGroup
+	ids := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job, err := Enqueue("cleanup", "cleanup:myapp", nil)
+			c.Check(err, check.IsNil)
+			if job != nil {
+				ids <- job.ID.Hex()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	unique := map[string]struct{}{}
+	for id := range ids {
+		unique[id] = struct{}{}
+	}
+	c.Assert(unique, check.HasLen, 1)
+	list, err := List(Filter{})
+	c.Assert(err, check.IsNil)
+	c.Assert(list, check.HasLen, 1)
+}
+
+func (s *S) TestEnsureKeyIndex(c *check.C) {
+	err := ensureKeyIndex()
+	c.Assert(err, check.IsNil)
+	indexes, err := s.conn.Collection("jobs").Indexes()
+	c.Assert(err, check.IsNil)
+	var names []string
+	for _, index := range indexes {
+		names = append(names, index.Name)
+	}
+	c.Assert(names, check.DeepEquals, []string{"_id_", "key_active_unique", "status_1_nextrun_1"})
+}
+
+func (s *S) TestRunPendingRetriesWithBackoff(c *check.C) {
+	config.Set("jobs:max-attempts", 2)
+	defer config.Unset("jobs")
+	t := time.Date(2017, 10, 1, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return t }
+	Register("cleanup", func(params Params) error { return errors.New("router unavailable") })
+	job, err := Enqueue("cleanup", "", nil)
+	c.Assert(err, check.IsNil)
+	n, err := RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	job, err = Get(job.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Status, check.Equals, StatusPending)
+	c.Assert(job.Attempts, check.Equals, 1)
+	c.Assert(job.LastError, check.Equals, "router unavailable")
+	c.Assert(job.NextRun.Equal(t.Add(backoffBase)), check.Equals, true)
+	n, err = RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+	t = t.Add(backoffBase)
+	n, err = RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	job, err = Get(job.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Status, check.Equals, StatusFailed)
+	c.Assert(job.Attempts, check.Equals, 2)
+	list, err := List(Filter{Status: StatusFailed})
+	c.Assert(err, check.IsNil)
+	c.Assert(list, check.HasLen, 1)
+}
+
+func (s *S) TestRunPendingRunAgain(c *check.C) {
+	t := time.Date(2017, 10, 1, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return t }
+	Register("check", func(params Params) error {
+		params["checks"] += "x"
+		if len(params["checks"]) < 2 {
+			return RunAgain(time.Minute)
+		}
+		return nil
+	})
+	job, err := Enqueue("check", "", Params{"checks": ""})
+	c.Assert(err, check.IsNil)
+	n, err := RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	job, err = Get(job.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Status, check.Equals, StatusPending)
+	c.Assert(job.Attempts, check.Equals, 0)
+	c.Assert(job.LastError, check.Equals, "")
+	c.Assert(job.Params, check.DeepEquals, Params{"checks": "x"})
+	c.Assert(job.NextRun.Equal(t.Add(time.Minute)), check.Equals, true)
+	t = t.Add(time.Minute)
+	n, err = RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	job, err = Get(job.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Status, check.Equals, StatusSucceeded)
+	c.Assert(job.Params, check.DeepEquals, Params{"checks": "xx"})
+}
+
+func (s *S) TestRunPendingKeepsJobsWithoutHandler(c *check.C) {
+	job, err := Enqueue("unknown", "", nil)
+	c.Assert(err, check.IsNil)
+	n, err := RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+	job, err = Get(job.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Status, check.Equals, StatusPending)
+	c.Assert(job.Attempts, check.Equals, 0)
+}
+
+func (s *S) TestRunPendingReclaimsLostJobs(c *check.C) {
+	t := time.Date(2017, 10, 1, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return t }
+	Register("cleanup", func(params Params) error { return nil })
+	job, err := Enqueue("cleanup", "", nil)
+	c.Assert(err, check.IsNil)
+	_, err = claim(s.conn, []string{"cleanup"})
+	c.Assert(err, check.IsNil)
+	n, err := RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+	t = t.Add(lockTimeout)
+	n, err = RunPending()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+	job, err = Get(job.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Status, check.Equals, StatusSucceeded)
+	c.Assert(job.Attempts, check.Equals, 2)
+}
+
+func (s *S) TestRetry(c *check.C) {
+	config.Set("jobs:max-attempts", 1)
+	defer config.Unset("jobs")
+	Register("cleanup", func(params Params) error { return errors.New("router unavailable") })
+	job, err := Enqueue("cleanup", "", nil)
+	c.Assert(err, check.IsNil)
+	_, err = Retry(job.ID.Hex())
+	c.Assert(err, check.Equals, ErrJobNotFailed)
+	_, err = RunPending()
+	c.Assert(err, check.IsNil)
+	job, err = Retry(job.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Status, check.Equals, StatusPending)
+	c.Assert(job.Attempts, check.Equals, 0)
+	_, err = Retry("000000000000000000000000")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+}
+
+func (s *S) TestRetrySameKeyEnqueued(c *check.C) {
+	config.Set("jobs:max-attempts", 1)
+	defer config.Unset("jobs")
+	Register("cleanup", func(params Params) error { return errors.New("router unavailable") })
+	failed, err := Enqueue("cleanup", "cleanup:myapp", nil)
+	c.Assert(err, check.IsNil)
+	_, err = RunPending()
+	c.Assert(err, check.IsNil)
+	_, err = Enqueue("cleanup", "cleanup:myapp", nil)
+	c.Assert(err, check.IsNil)
+	_, err = Retry(failed.ID.Hex())
+	c.Assert(err, check.Equals, ErrJobKeyInUse)
+}
+
+func (s *S) TestGetNotFound(c *check.C) {
+	_, err := Get("invalid")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+	_, err = Get("000000000000000000000000")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+}
+
+func (s *S) TestListInvalidStatus(c *check.C) {
+	_, err := List(Filter{Status: "stuck"})
+	c.Assert(err, check.Equals, ErrInvalidJobStatus)
+}
+
+func (s *S) TestBackoff(c *check.C) {
+	c.Assert(backoff(1), check.Equals, backoffBase)
+	c.Assert(backoff(2), check.Equals, 2*backoffBase)
+	c.Assert(backoff(3), check.Equals, 4*backoffBase)
+	c.Assert(backoff(50), check.Equals, backoffMax)
+}
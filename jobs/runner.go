@@ -0,0 +1,62 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jobs
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/log"
+)
+
+type runner struct {
+	interval time.Duration
+	done     chan bool
+}
+
+// Initialize creates the indexes of the jobs collection and starts running
+// pending jobs in background. Jobs still run when the unique index of keys
+// can't be created, but concurrent Enqueue calls with the same key may then
+// create duplicated jobs.
+func Initialize() error {
+	err := ensureKeyIndex()
+	if err != nil {
+		log.Errorf("[jobs] WARNING: %s. Jobs enqueued concurrently with the same key may run more than once.", err)
+	}
+	interval, _ := config.GetInt("jobs:run-interval")
+	r := &runner{
+		interval: time.Duration(interval) * time.Second,
+		done:     make(chan bool),
+	}
+	if r.interval == 0 {
+		r.interval = 5 * time.Second
+	}
+	shutdown.Register(r)
+	go r.run()
+	return nil
+}
+
+func (r *runner) run() {
+	for {
+		_, err := RunPending()
+		if err != nil {
+			log.Errorf("[jobs] unable to run pending jobs: %s", err)
+		}
+		select {
+		case <-r.done:
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+func (r *runner) Shutdown() {
+	r.done <- true
+}
+
+func (r *runner) String() string {
+	return "background jobs runner"
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package naming provides an extension point for validating the names of
+// apps, pools and service instances. Before creating any of these objects,
+// tsuru runs the name through the policies listed in the configuration file,
+// stopping at the first policy that rejects it.
+package naming
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+// Kind is the kind of object being named.
+type Kind string
+
+const (
+	KindApp             = Kind("app")
+	KindPool            = Kind("pool")
+	KindServiceInstance = Kind("service-instance")
+)
+
+var defaultPolicies = []string{"regex", "reserved", "team-prefix"}
+
+var policies map[string]Policy
+
+// Target is the object being named. Team is the team owning the object, and
+// is empty for objects that aren't owned by teams, like pools.
+type Target struct {
+	Kind Kind
+	Name string
+	Team string
+}
+
+// Policy decides whether a name is valid. Policies must return an
+// *InvalidNameError when rejecting a name, any other error is considered a
+// failure in the policy itself.
+type Policy interface {
+	Validate(t Target) error
+}
+
+// InvalidNameError is returned when a name is rejected by a policy. The
+// reason should tell the user how to pick a valid name.
+type InvalidNameError struct {
+	Kind   Kind
+	Name   string
+	Reason string
+}
+
+func (e *InvalidNameError) Error() string {
+	return fmt.Sprintf("Invalid %s name, %s", e.Kind.label(), e.Reason)
+}
+
+func (k Kind) label() string {
+	if k == KindServiceInstance {
+		return "service instance"
+	}
+	return string(k)
+}
+
+// Register registers a new naming policy, that can be later enabled in the
+// configuration file.
+func Register(name string, policy Policy) {
+	if policies == nil {
+		policies = make(map[string]Policy)
+	}
+	policies[name] = policy
+}
+
+// Validate runs the name through all the configured policies, returning the
+// error from the first policy that rejects it.
+func Validate(t Target) error {
+	names, err := config.GetList("naming:policies")
+	if err != nil {
+		names = defaultPolicies
+	}
+	for _, name := range names {
+		policy, ok := policies[name]
+		if !ok {
+			return errors.Errorf("unknown naming policy: %q", name)
+		}
+		err = policy.Validate(t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package naming
+
+import (
+	"errors"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type fakePolicy struct {
+	targets []Target
+	err     error
+}
+
+func (p *fakePolicy) Validate(t Target) error {
+	p.targets = append(p.targets, t)
+	return p.err
+}
+
+func (s *S) TestValidateDefaultRegex(c *check.C) {
+	var tests = []struct {
+		target Target
+		valid  bool
+	}{
+		{Target{Kind: KindApp, Name: "myapp"}, true},
+		{Target{Kind: KindApp, Name: "my-app1"}, true},
+		{Target{Kind: KindApp, Name: "myApp"}, false},
+		{Target{Kind: KindApp, Name: "1app"}, false},
+		{Target{Kind: KindServiceInstance, Name: "My_instance"}, true},
+		{Target{Kind: KindServiceInstance, Name: "a"}, false},
+		{Target{Kind: KindPool, Name: "Any pool.name"}, true},
+	}
+	for _, t := range tests {
+		err := Validate(t.target)
+		if t.valid {
+			c.Check(err, check.IsNil, check.Commentf("%#v", t.target))
+		} else {
+			c.Check(err, check.FitsTypeOf, &InvalidNameError{}, check.Commentf("%#v", t.target))
+		}
+	}
+	err := Validate(Target{Kind: KindApp, Name: "my_app"})
+	c.Assert(err, check.ErrorMatches, "Invalid app name, your app should have at most 63 characters, containing only lower case letters, numbers or dashes, starting with a letter.")
+}
+
+func (s *S) TestValidateConfiguredRegex(c *check.C) {
+	config.Set("naming:pool:regex", `^pool-[a-z]+$`)
+	err := Validate(Target{Kind: KindPool, Name: "pool-prod"})
+	c.Assert(err, check.IsNil)
+	err = Validate(Target{Kind: KindPool, Name: "prod"})
+	c.Assert(err, check.ErrorMatches, `Invalid pool name, it must match the regular expression "\^pool-\[a-z\]\+\$".`)
+	config.Set("naming:pool:message", "pool names must start with pool-.")
+	err = Validate(Target{Kind: KindPool, Name: "prod"})
+	c.Assert(err, check.ErrorMatches, "Invalid pool name, pool names must start with pool-.")
+}
+
+func (s *S) TestValidateServiceInstanceDefaultError(c *check.C) {
+	err := Validate(Target{Kind: KindServiceInstance, Name: "a@123"})
+	c.Assert(err, check.Equals, ErrInvalidServiceInstanceName)
+	config.Set("naming:service-instance:message", "instance names must start with a letter.")
+	err = Validate(Target{Kind: KindServiceInstance, Name: "a@123"})
+	c.Assert(err, check.ErrorMatches, "Invalid service instance name, instance names must start with a letter.")
+}
+
+func (s *S) TestValidateInvalidRegex(c *check.C) {
+	config.Set("naming:app:regex", `^[a-z`)
+	err := Validate(Target{Kind: KindApp, Name: "myapp"})
+	c.Assert(err, check.ErrorMatches, "invalid naming regex for app: .*")
+	_, ok := err.(*InvalidNameError)
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *S) TestValidateReserved(c *check.C) {
+	config.Set("naming:reserved", []interface{}{"admin"})
+	config.Set("naming:app:reserved", []interface{}{"api"})
+	err := Validate(Target{Kind: KindApp, Name: "admin"})
+	c.Assert(err, check.ErrorMatches, `Invalid app name, "admin" is a reserved name.`)
+	err = Validate(Target{Kind: KindApp, Name: "api"})
+	c.Assert(err, check.ErrorMatches, `Invalid app name, "api" is a reserved name.`)
+	err = Validate(Target{Kind: KindPool, Name: "api"})
+	c.Assert(err, check.IsNil)
+	err = Validate(Target{Kind: KindPool, Name: "admin"})
+	c.Assert(err, check.ErrorMatches, `Invalid pool name, "admin" is a reserved name.`)
+}
+
+func (s *S) TestValidateTeamPrefix(c *check.C) {
+	config.Set("naming:app:team-prefixes:payments", []interface{}{"pay-", "billing-"})
+	err := Validate(Target{Kind: KindApp, Name: "pay-api", Team: "payments"})
+	c.Assert(err, check.IsNil)
+	err = Validate(Target{Kind: KindApp, Name: "billing-api", Team: "payments"})
+	c.Assert(err, check.IsNil)
+	err = Validate(Target{Kind: KindApp, Name: "api", Team: "payments"})
+	c.Assert(err, check.ErrorMatches, `Invalid app name, names owned by the team "payments" must start with one of: pay-, billing-.`)
+	err = Validate(Target{Kind: KindApp, Name: "api", Team: "other"})
+	c.Assert(err, check.IsNil)
+	err = Validate(Target{Kind: KindServiceInstance, Name: "api", Team: "payments"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestValidateConfiguredPolicies(c *check.C) {
+	fake := &fakePolicy{}
+	Register("fake", fake)
+	defer delete(policies, "fake")
+	config.Set("naming:policies", []interface{}{"fake"})
+	target := Target{Kind: KindApp, Name: "Not_A_Valid_App", Team: "team"}
+	err := Validate(target)
+	c.Assert(err, check.IsNil)
+	c.Assert(fake.targets, check.DeepEquals, []Target{target})
+	fake.err = errors.New("policy failure")
+	err = Validate(target)
+	c.Assert(err, check.Equals, fake.err)
+}
+
+func (s *S) TestValidateUnknownPolicy(c *check.C) {
+	config.Set("naming:policies", []interface{}{"regex", "unknown"})
+	err := Validate(Target{Kind: KindApp, Name: "myapp"})
+	c.Assert(err, check.ErrorMatches, `unknown naming policy: "unknown"`)
+}
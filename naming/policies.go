@@ -0,0 +1,132 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package naming
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+const serviceInstanceNameMessage = "your service instance should have at least 2 characters, " +
+	"containing only letters, numbers, dashes or underscores, starting with a letter."
+
+// ErrInvalidServiceInstanceName is returned by the regex policy for service
+// instance names that don't match the default expression, unless a custom
+// message is configured.
+var ErrInvalidServiceInstanceName = &InvalidNameError{
+	Kind:   KindServiceInstance,
+	Reason: serviceInstanceNameMessage,
+}
+
+type regexDefault struct {
+	expr    string
+	message string
+	// err, when set, is returned instead of a new error for names that don't
+	// match the default expression.
+	err error
+}
+
+var defaultRegexes = map[Kind]regexDefault{
+	KindApp: {
+		expr: `^[a-z][a-z0-9-]{0,62}$`,
+		message: "your app should have at most 63 characters, containing only " +
+			"lower case letters, numbers or dashes, starting with a letter.",
+	},
+	KindServiceInstance: {
+		expr:    `^[A-Za-z][-a-zA-Z0-9_]+$`,
+		message: serviceInstanceNameMessage,
+		err:     ErrInvalidServiceInstanceName,
+	},
+}
+
+func init() {
+	Register("regex", regexPolicy{})
+	Register("reserved", reservedPolicy{})
+	Register("team-prefix", teamPrefixPolicy{})
+}
+
+// regexPolicy requires names to match the regular expression in
+// naming:<kind>:regex, falling back to the historical expression for the
+// kind. The message in naming:<kind>:message is used as the reason when the
+// name doesn't match.
+type regexPolicy struct{}
+
+func (regexPolicy) Validate(t Target) error {
+	def := defaultRegexes[t.Kind]
+	expr, err := config.GetString(fmt.Sprintf("naming:%s:regex", t.Kind))
+	if err == nil {
+		def = regexDefault{expr: expr}
+	}
+	if def.expr == "" {
+		return nil
+	}
+	re, err := regexp.Compile(def.expr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid naming regex for %s", t.Kind)
+	}
+	if re.MatchString(t.Name) {
+		return nil
+	}
+	msg, _ := config.GetString(fmt.Sprintf("naming:%s:message", t.Kind))
+	if msg == "" {
+		if def.err != nil {
+			return def.err
+		}
+		msg = def.message
+	}
+	if msg == "" {
+		msg = fmt.Sprintf("it must match the regular expression %q.", def.expr)
+	}
+	return &InvalidNameError{Kind: t.Kind, Name: t.Name, Reason: msg}
+}
+
+// reservedPolicy rejects names listed in naming:reserved, which apply to all
+// kinds, or in naming:<kind>:reserved.
+type reservedPolicy struct{}
+
+func (reservedPolicy) Validate(t Target) error {
+	global, _ := config.GetList("naming:reserved")
+	reserved, _ := config.GetList(fmt.Sprintf("naming:%s:reserved", t.Kind))
+	for _, word := range append(global, reserved...) {
+		if word == t.Name {
+			return &InvalidNameError{
+				Kind:   t.Kind,
+				Name:   t.Name,
+				Reason: fmt.Sprintf("%q is a reserved name.", t.Name),
+			}
+		}
+	}
+	return nil
+}
+
+// teamPrefixPolicy requires names of objects owned by a team to start with
+// one of the prefixes in naming:<kind>:team-prefixes:<team>. Teams without
+// prefixes may use any name.
+type teamPrefixPolicy struct{}
+
+func (teamPrefixPolicy) Validate(t Target) error {
+	if t.Team == "" {
+		return nil
+	}
+	prefixes, err := config.GetList(fmt.Sprintf("naming:%s:team-prefixes:%s", t.Kind, t.Team))
+	if err != nil || len(prefixes) == 0 {
+		return nil
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(t.Name, prefix) {
+			return nil
+		}
+	}
+	return &InvalidNameError{
+		Kind: t.Kind,
+		Name: t.Name,
+		Reason: fmt.Sprintf("names owned by the team %q must start with one of: %s.",
+			t.Team, strings.Join(prefixes, ", ")),
+	}
+}
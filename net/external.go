@@ -0,0 +1,138 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidExternalURL is returned by ValidateExternalURL for URLs that
+// aren't absolute http or https URLs.
+var ErrInvalidExternalURL = errors.New("url must be an absolute http or https url")
+
+var internalNetworks = parseNetworks(
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7",
+)
+
+// InternalAddressError is returned when an URL or a connection targets an
+// internal address.
+type InternalAddressError struct {
+	Host string
+}
+
+func (e *InternalAddressError) Error() string {
+	return fmt.Sprintf("%s is an internal address", e.Host)
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// IsInternalIP returns whether the ip is a loopback, private, link-local or
+// unspecified address, which must not be reached by requests to URLs
+// provided by users.
+func IsInternalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateExternalURL checks that rawURL is an absolute http or https URL
+// whose host isn't localhost or an internal IP address. Host names are not
+// resolved, the resolved addresses are checked when ExternalClient connects,
+// so DNS records changed after the validation are also covered.
+func ValidateExternalURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidExternalURL
+	}
+	host := u.Hostname()
+	if host == "" {
+		return ErrInvalidExternalURL
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return &InternalAddressError{Host: host}
+	}
+	if ip := net.ParseIP(host); ip != nil && IsInternalIP(ip) {
+		return &InternalAddressError{Host: host}
+	}
+	return nil
+}
+
+func externalDial(dialer *net.Dialer) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if IsInternalIP(ip) {
+				return nil, &InternalAddressError{Host: host}
+			}
+		}
+		var conn net.Conn
+		for _, ip := range ips {
+			conn, err = dialer.Dial(network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+func makeExternalHTTPClient(dialTimeout time.Duration, fullTimeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Dial:                externalDial(dialer),
+			TLSHandshakeTimeout: dialTimeout,
+			MaxIdleConnsPerHost: -1,
+		},
+		Timeout: fullTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return ValidateExternalURL(req.URL.String())
+		},
+	}
+}
+
+// ExternalClient is an HTTP client for URLs provided by users, like webhooks.
+// It refuses to connect to internal addresses, checking every address the
+// host resolves to.
+var ExternalClient = makeExternalHTTPClient(5*time.Second, time.Minute)
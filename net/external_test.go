@@ -0,0 +1,67 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestIsInternalIP(c *check.C) {
+	tests := map[string]bool{
+		"127.0.0.1":       true,
+		"10.1.2.3":        true,
+		"172.16.0.1":      true,
+		"172.32.0.1":      false,
+		"192.168.50.4":    true,
+		"169.254.169.254": true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"::1":             true,
+		"fe80::1":         true,
+		"fd00::1":         true,
+		"8.8.8.8":         false,
+		"2001:4860::8888": false,
+	}
+	for address, internal := range tests {
+		c.Check(IsInternalIP(net.ParseIP(address)), check.Equals, internal, check.Commentf("address %s", address))
+	}
+}
+
+func (s *S) TestValidateExternalURL(c *check.C) {
+	tests := map[string]string{
+		"https://chat.example.com/hook":            "",
+		"http://8.8.8.8:8080":                      "",
+		"ftp://chat.example.com":                   ErrInvalidExternalURL.Error(),
+		"/hook":                                    ErrInvalidExternalURL.Error(),
+		"chat.example.com":                         ErrInvalidExternalURL.Error(),
+		"http://localhost:8080":                    "localhost is an internal address",
+		"http://127.0.0.1/hook":                    "127.0.0.1 is an internal address",
+		"http://169.254.169.254/latest/meta-data/": "169.254.169.254 is an internal address",
+		"http://[::1]:8080":                        "::1 is an internal address",
+	}
+	for rawURL, expected := range tests {
+		err := ValidateExternalURL(rawURL)
+		if expected == "" {
+			c.Check(err, check.IsNil, check.Commentf("url %s", rawURL))
+		} else {
+			c.Check(err, check.ErrorMatches, expected, check.Commentf("url %s", rawURL))
+		}
+	}
+}
+
+func (s *S) TestExternalClientRefusesInternalAddresses(c *check.C) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+	_, err := ExternalClient.Get(server.URL)
+	c.Assert(err, check.ErrorMatches, `.*127\.0\.0\.1 is an internal address`)
+	c.Assert(called, check.Equals, false)
+}
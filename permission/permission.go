@@ -53,9 +53,11 @@ var (
 	CtxIaaS            = contextType("iaas")
 	CtxService         = contextType("service")
 	CtxServiceInstance = contextType("service-instance")
+	CtxOrganization    = contextType("organization")
+	CtxTag             = contextType("tag")
 
 	ContextTypes = []contextType{
-		CtxGlobal, CtxApp, CtxTeam, CtxPool, CtxIaaS, CtxService, CtxServiceInstance,
+		CtxGlobal, CtxApp, CtxTeam, CtxPool, CtxIaaS, CtxService, CtxServiceInstance, CtxOrganization, CtxTag,
 	}
 )
 
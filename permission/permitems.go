@@ -9,78 +9,137 @@ package permission
 
 var (
 	PermAll                              = PermissionRegistry.get("")                                    // [global]
-	PermApp                              = PermissionRegistry.get("app")                                 // [global app team pool]
-	PermAppAdmin                         = PermissionRegistry.get("app.admin")                           // [global app team pool]
-	PermAppAdminQuota                    = PermissionRegistry.get("app.admin.quota")                     // [global app team pool]
-	PermAppAdminRoutes                   = PermissionRegistry.get("app.admin.routes")                    // [global app team pool]
-	PermAppAdminUnlock                   = PermissionRegistry.get("app.admin.unlock")                    // [global app team pool]
-	PermAppCreate                        = PermissionRegistry.get("app.create")                          // [global team]
-	PermAppDelete                        = PermissionRegistry.get("app.delete")                          // [global app team pool]
-	PermAppDeploy                        = PermissionRegistry.get("app.deploy")                          // [global app team pool]
-	PermAppDeployArchiveUrl              = PermissionRegistry.get("app.deploy.archive-url")              // [global app team pool]
-	PermAppDeployBuild                   = PermissionRegistry.get("app.deploy.build")                    // [global app team pool]
-	PermAppDeployGit                     = PermissionRegistry.get("app.deploy.git")                      // [global app team pool]
-	PermAppDeployImage                   = PermissionRegistry.get("app.deploy.image")                    // [global app team pool]
-	PermAppDeployRollback                = PermissionRegistry.get("app.deploy.rollback")                 // [global app team pool]
-	PermAppDeployUpload                  = PermissionRegistry.get("app.deploy.upload")                   // [global app team pool]
-	PermAppRead                          = PermissionRegistry.get("app.read")                            // [global app team pool]
-	PermAppReadCertificate               = PermissionRegistry.get("app.read.certificate")                // [global app team pool]
-	PermAppReadDeploy                    = PermissionRegistry.get("app.read.deploy")                     // [global app team pool]
-	PermAppReadEnv                       = PermissionRegistry.get("app.read.env")                        // [global app team pool]
-	PermAppReadEvents                    = PermissionRegistry.get("app.read.events")                     // [global app team pool]
-	PermAppReadLog                       = PermissionRegistry.get("app.read.log")                        // [global app team pool]
-	PermAppReadMetric                    = PermissionRegistry.get("app.read.metric")                     // [global app team pool]
-	PermAppRun                           = PermissionRegistry.get("app.run")                             // [global app team pool]
-	PermAppRunShell                      = PermissionRegistry.get("app.run.shell")                       // [global app team pool]
-	PermAppUpdate                        = PermissionRegistry.get("app.update")                          // [global app team pool]
-	PermAppUpdateBind                    = PermissionRegistry.get("app.update.bind")                     // [global app team pool]
-	PermAppUpdateCertificate             = PermissionRegistry.get("app.update.certificate")              // [global app team pool]
-	PermAppUpdateCertificateSet          = PermissionRegistry.get("app.update.certificate.set")          // [global app team pool]
-	PermAppUpdateCertificateUnset        = PermissionRegistry.get("app.update.certificate.unset")        // [global app team pool]
-	PermAppUpdateCname                   = PermissionRegistry.get("app.update.cname")                    // [global app team pool]
-	PermAppUpdateCnameAdd                = PermissionRegistry.get("app.update.cname.add")                // [global app team pool]
-	PermAppUpdateCnameRemove             = PermissionRegistry.get("app.update.cname.remove")             // [global app team pool]
-	PermAppUpdateDescription             = PermissionRegistry.get("app.update.description")              // [global app team pool]
-	PermAppUpdateEnv                     = PermissionRegistry.get("app.update.env")                      // [global app team pool]
-	PermAppUpdateEnvSet                  = PermissionRegistry.get("app.update.env.set")                  // [global app team pool]
-	PermAppUpdateEnvUnset                = PermissionRegistry.get("app.update.env.unset")                // [global app team pool]
-	PermAppUpdateEvents                  = PermissionRegistry.get("app.update.events")                   // [global app team pool]
-	PermAppUpdateGrant                   = PermissionRegistry.get("app.update.grant")                    // [global app team pool]
-	PermAppUpdateLog                     = PermissionRegistry.get("app.update.log")                      // [global app team pool]
-	PermAppUpdatePlan                    = PermissionRegistry.get("app.update.plan")                     // [global app team pool]
-	PermAppUpdatePool                    = PermissionRegistry.get("app.update.pool")                     // [global app team pool]
-	PermAppUpdateRestart                 = PermissionRegistry.get("app.update.restart")                  // [global app team pool]
-	PermAppUpdateRevoke                  = PermissionRegistry.get("app.update.revoke")                   // [global app team pool]
-	PermAppUpdateRouter                  = PermissionRegistry.get("app.update.router")                   // [global app team pool]
-	PermAppUpdateSleep                   = PermissionRegistry.get("app.update.sleep")                    // [global app team pool]
-	PermAppUpdateStart                   = PermissionRegistry.get("app.update.start")                    // [global app team pool]
-	PermAppUpdateStop                    = PermissionRegistry.get("app.update.stop")                     // [global app team pool]
-	PermAppUpdateSwap                    = PermissionRegistry.get("app.update.swap")                     // [global app team pool]
-	PermAppUpdateTags                    = PermissionRegistry.get("app.update.tags")                     // [global app team pool]
-	PermAppUpdateTeamowner               = PermissionRegistry.get("app.update.teamowner")                // [global app team pool]
-	PermAppUpdateUnbind                  = PermissionRegistry.get("app.update.unbind")                   // [global app team pool]
-	PermAppUpdateUnit                    = PermissionRegistry.get("app.update.unit")                     // [global app team pool]
-	PermAppUpdateUnitAdd                 = PermissionRegistry.get("app.update.unit.add")                 // [global app team pool]
-	PermAppUpdateUnitRegister            = PermissionRegistry.get("app.update.unit.register")            // [global app team pool]
-	PermAppUpdateUnitRemove              = PermissionRegistry.get("app.update.unit.remove")              // [global app team pool]
-	PermAppUpdateUnitStatus              = PermissionRegistry.get("app.update.unit.status")              // [global app team pool]
+	PermApp                              = PermissionRegistry.get("app")                                 // [global app team pool organization tag]
+	PermAppAdmin                         = PermissionRegistry.get("app.admin")                           // [global app team pool organization tag]
+	PermAppAdminCname                    = PermissionRegistry.get("app.admin.cname")                     // [global app team pool organization tag]
+	PermAppAdminEnv                      = PermissionRegistry.get("app.admin.env")                       // [global app team pool organization tag]
+	PermAppAdminEnvRotate                = PermissionRegistry.get("app.admin.env.rotate")                // [global app team pool organization tag]
+	PermAppAdminMetricsTargets           = PermissionRegistry.get("app.admin.metrics-targets")           // [global app team pool organization tag]
+	PermAppAdminQuota                    = PermissionRegistry.get("app.admin.quota")                     // [global app team pool organization tag]
+	PermAppAdminRoutes                   = PermissionRegistry.get("app.admin.routes")                    // [global app team pool organization tag]
+	PermAppAdminUnlock                   = PermissionRegistry.get("app.admin.unlock")                    // [global app team pool organization tag]
+	PermAppCreate                        = PermissionRegistry.get("app.create")                          // [global team organization]
+	PermAppDelete                        = PermissionRegistry.get("app.delete")                          // [global app team pool organization tag]
+	PermAppDeploy                        = PermissionRegistry.get("app.deploy")                          // [global app team pool organization tag]
+	PermAppDeployArchiveUrl              = PermissionRegistry.get("app.deploy.archive-url")              // [global app team pool organization tag]
+	PermAppDeployBuild                   = PermissionRegistry.get("app.deploy.build")                    // [global app team pool organization tag]
+	PermAppDeployFreezeException         = PermissionRegistry.get("app.deploy.freeze-exception")         // [global app team pool organization tag]
+	PermAppDeployGit                     = PermissionRegistry.get("app.deploy.git")                      // [global app team pool organization tag]
+	PermAppDeployImage                   = PermissionRegistry.get("app.deploy.image")                    // [global app team pool organization tag]
+	PermAppDeployPromote                 = PermissionRegistry.get("app.deploy.promote")                  // [global app team pool organization tag]
+	PermAppDeployRollback                = PermissionRegistry.get("app.deploy.rollback")                 // [global app team pool organization tag]
+	PermAppDeployUpload                  = PermissionRegistry.get("app.deploy.upload")                   // [global app team pool organization tag]
+	PermAppRead                          = PermissionRegistry.get("app.read")                            // [global app team pool organization tag]
+	PermAppReadCertificate               = PermissionRegistry.get("app.read.certificate")                // [global app team pool organization tag]
+	PermAppReadDeploy                    = PermissionRegistry.get("app.read.deploy")                     // [global app team pool organization tag]
+	PermAppReadEnv                       = PermissionRegistry.get("app.read.env")                        // [global app team pool organization tag]
+	PermAppReadEvents                    = PermissionRegistry.get("app.read.events")                     // [global app team pool organization tag]
+	PermAppReadLog                       = PermissionRegistry.get("app.read.log")                        // [global app team pool organization tag]
+	PermAppReadMetric                    = PermissionRegistry.get("app.read.metric")                     // [global app team pool organization tag]
+	PermAppRun                           = PermissionRegistry.get("app.run")                             // [global app team pool organization tag]
+	PermAppRunShell                      = PermissionRegistry.get("app.run.shell")                       // [global app team pool organization tag]
+	PermAppUpdate                        = PermissionRegistry.get("app.update")                          // [global app team pool organization tag]
+	PermAppUpdateAnnotate                = PermissionRegistry.get("app.update.annotate")                 // [global app team pool organization tag]
+	PermAppUpdateAutoRollback            = PermissionRegistry.get("app.update.auto-rollback")            // [global app team pool organization tag]
+	PermAppUpdateBind                    = PermissionRegistry.get("app.update.bind")                     // [global app team pool organization tag]
+	PermAppUpdateCertificate             = PermissionRegistry.get("app.update.certificate")              // [global app team pool organization tag]
+	PermAppUpdateCertificateSet          = PermissionRegistry.get("app.update.certificate.set")          // [global app team pool organization tag]
+	PermAppUpdateCertificateUnset        = PermissionRegistry.get("app.update.certificate.unset")        // [global app team pool organization tag]
+	PermAppUpdateChaos                   = PermissionRegistry.get("app.update.chaos")                    // [global app team pool organization tag]
+	PermAppUpdateChaosRun                = PermissionRegistry.get("app.update.chaos.run")                // [global app team pool organization tag]
+	PermAppUpdateChaosStop               = PermissionRegistry.get("app.update.chaos.stop")               // [global app team pool organization tag]
+	PermAppUpdateCname                   = PermissionRegistry.get("app.update.cname")                    // [global app team pool organization tag]
+	PermAppUpdateCnameAdd                = PermissionRegistry.get("app.update.cname.add")                // [global app team pool organization tag]
+	PermAppUpdateCnameRemove             = PermissionRegistry.get("app.update.cname.remove")             // [global app team pool organization tag]
+	PermAppUpdateConfigTemplates         = PermissionRegistry.get("app.update.config-templates")         // [global app team pool organization tag]
+	PermAppUpdateDeployPriority          = PermissionRegistry.get("app.update.deploy-priority")          // [global app team pool organization tag]
+	PermAppUpdateDeployStatus            = PermissionRegistry.get("app.update.deploy-status")            // [global app team pool organization tag]
+	PermAppUpdateDeployToggles           = PermissionRegistry.get("app.update.deploy-toggles")           // [global app team pool organization tag]
+	PermAppUpdateDescription             = PermissionRegistry.get("app.update.description")              // [global app team pool organization tag]
+	PermAppUpdateEgress                  = PermissionRegistry.get("app.update.egress")                   // [global app team pool organization tag]
+	PermAppUpdateEnv                     = PermissionRegistry.get("app.update.env")                      // [global app team pool organization tag]
+	PermAppUpdateEnvSet                  = PermissionRegistry.get("app.update.env.set")                  // [global app team pool organization tag]
+	PermAppUpdateEnvUnset                = PermissionRegistry.get("app.update.env.unset")                // [global app team pool organization tag]
+	PermAppUpdateEvents                  = PermissionRegistry.get("app.update.events")                   // [global app team pool organization tag]
+	PermAppUpdateGrant                   = PermissionRegistry.get("app.update.grant")                    // [global app team pool organization tag]
+	PermAppUpdateLog                     = PermissionRegistry.get("app.update.log")                      // [global app team pool organization tag]
+	PermAppUpdateMaintenance             = PermissionRegistry.get("app.update.maintenance")              // [global app team pool organization tag]
+	PermAppUpdateMetrics                 = PermissionRegistry.get("app.update.metrics")                  // [global app team pool organization tag]
+	PermAppUpdateMirror                  = PermissionRegistry.get("app.update.mirror")                   // [global app team pool organization tag]
+	PermAppUpdateNodeRequirements        = PermissionRegistry.get("app.update.node-requirements")        // [global app team pool organization tag]
+	PermAppUpdatePlan                    = PermissionRegistry.get("app.update.plan")                     // [global app team pool organization tag]
+	PermAppUpdatePlatform                = PermissionRegistry.get("app.update.platform")                 // [global app team pool organization tag]
+	PermAppUpdatePool                    = PermissionRegistry.get("app.update.pool")                     // [global app team pool organization tag]
+	PermAppUpdatePreemptionTolerance     = PermissionRegistry.get("app.update.preemption-tolerance")     // [global app team pool organization tag]
+	PermAppUpdateProcessSettings         = PermissionRegistry.get("app.update.process-settings")         // [global app team pool organization tag]
+	PermAppUpdateRestart                 = PermissionRegistry.get("app.update.restart")                  // [global app team pool organization tag]
+	PermAppUpdateRevoke                  = PermissionRegistry.get("app.update.revoke")                   // [global app team pool organization tag]
+	PermAppUpdateRouter                  = PermissionRegistry.get("app.update.router")                   // [global app team pool organization tag]
+	PermAppUpdateSleep                   = PermissionRegistry.get("app.update.sleep")                    // [global app team pool organization tag]
+	PermAppUpdateStart                   = PermissionRegistry.get("app.update.start")                    // [global app team pool organization tag]
+	PermAppUpdateStop                    = PermissionRegistry.get("app.update.stop")                     // [global app team pool organization tag]
+	PermAppUpdateSwap                    = PermissionRegistry.get("app.update.swap")                     // [global app team pool organization tag]
+	PermAppUpdateTags                    = PermissionRegistry.get("app.update.tags")                     // [global app team pool organization tag]
+	PermAppUpdateTeamowner               = PermissionRegistry.get("app.update.teamowner")                // [global app team pool organization tag]
+	PermAppUpdateUnbind                  = PermissionRegistry.get("app.update.unbind")                   // [global app team pool organization tag]
+	PermAppUpdateUnit                    = PermissionRegistry.get("app.update.unit")                     // [global app team pool organization tag]
+	PermAppUpdateUnitAdd                 = PermissionRegistry.get("app.update.unit.add")                 // [global app team pool organization tag]
+	PermAppUpdateUnitRegister            = PermissionRegistry.get("app.update.unit.register")            // [global app team pool organization tag]
+	PermAppUpdateUnitRemove              = PermissionRegistry.get("app.update.unit.remove")              // [global app team pool organization tag]
+	PermAppUpdateUnitStatus              = PermissionRegistry.get("app.update.unit.status")              // [global app team pool organization tag]
 	PermCluster                          = PermissionRegistry.get("cluster")                             // [global]
 	PermClusterDelete                    = PermissionRegistry.get("cluster.delete")                      // [global]
 	PermClusterRead                      = PermissionRegistry.get("cluster.read")                        // [global]
 	PermClusterReadEvents                = PermissionRegistry.get("cluster.read.events")                 // [global]
 	PermClusterUpdate                    = PermissionRegistry.get("cluster.update")                      // [global]
+	PermComplianceReport                 = PermissionRegistry.get("compliance-report")                   // [global]
+	PermComplianceReportCreate           = PermissionRegistry.get("compliance-report.create")            // [global]
+	PermComplianceReportRead             = PermissionRegistry.get("compliance-report.read")              // [global]
+	PermComplianceReportReadEvents       = PermissionRegistry.get("compliance-report.read.events")       // [global]
+	PermConstraintTemplate               = PermissionRegistry.get("constraint-template")                 // [global]
+	PermConstraintTemplateCreate         = PermissionRegistry.get("constraint-template.create")          // [global]
+	PermConstraintTemplateDelete         = PermissionRegistry.get("constraint-template.delete")          // [global]
+	PermConstraintTemplateRead           = PermissionRegistry.get("constraint-template.read")            // [global]
+	PermConstraintTemplateReadEvents     = PermissionRegistry.get("constraint-template.read.events")     // [global]
 	PermDebug                            = PermissionRegistry.get("debug")                               // [global]
+	PermDeployFreeze                     = PermissionRegistry.get("deploy-freeze")                       // [global]
+	PermDeployFreezeCreate               = PermissionRegistry.get("deploy-freeze.create")                // [global]
+	PermDeployFreezeDelete               = PermissionRegistry.get("deploy-freeze.delete")                // [global]
+	PermDeployFreezeException            = PermissionRegistry.get("deploy-freeze.exception")             // [global]
+	PermDeployFreezeExceptionApprove     = PermissionRegistry.get("deploy-freeze.exception.approve")     // [global]
+	PermDeployFreezeRead                 = PermissionRegistry.get("deploy-freeze.read")                  // [global]
+	PermDeployFreezeReadEvents           = PermissionRegistry.get("deploy-freeze.read.events")           // [global]
 	PermEventBlock                       = PermissionRegistry.get("event-block")                         // [global]
 	PermEventBlockAdd                    = PermissionRegistry.get("event-block.add")                     // [global]
 	PermEventBlockRead                   = PermissionRegistry.get("event-block.read")                    // [global]
 	PermEventBlockReadEvents             = PermissionRegistry.get("event-block.read.events")             // [global]
 	PermEventBlockRemove                 = PermissionRegistry.get("event-block.remove")                  // [global]
+	PermEventRetentionRule               = PermissionRegistry.get("event-retention-rule")                // [global]
+	PermEventRetentionRuleRead           = PermissionRegistry.get("event-retention-rule.read")           // [global]
+	PermEventRetentionRuleReadEvents     = PermissionRegistry.get("event-retention-rule.read.events")    // [global]
+	PermEventRetentionRuleRemove         = PermissionRegistry.get("event-retention-rule.remove")         // [global]
+	PermEventRetentionRuleSet            = PermissionRegistry.get("event-retention-rule.set")            // [global]
+	PermEventWebhook                     = PermissionRegistry.get("event-webhook")                       // [global team app]
+	PermEventWebhookRead                 = PermissionRegistry.get("event-webhook.read")                  // [global team app]
+	PermEventWebhookReadEvents           = PermissionRegistry.get("event-webhook.read.events")           // [global team app]
+	PermEventWebhookRemove               = PermissionRegistry.get("event-webhook.remove")                // [global team app]
+	PermEventWebhookSet                  = PermissionRegistry.get("event-webhook.set")                   // [global team app]
+	PermHardwareProfile                  = PermissionRegistry.get("hardware-profile")                    // [global]
+	PermHardwareProfileCreate            = PermissionRegistry.get("hardware-profile.create")             // [global]
+	PermHardwareProfileDelete            = PermissionRegistry.get("hardware-profile.delete")             // [global]
+	PermHardwareProfileRead              = PermissionRegistry.get("hardware-profile.read")               // [global]
+	PermHardwareProfileReadEvents        = PermissionRegistry.get("hardware-profile.read.events")        // [global]
 	PermHealing                          = PermissionRegistry.get("healing")                             // [global pool]
 	PermHealingDelete                    = PermissionRegistry.get("healing.delete")                      // [global pool]
 	PermHealingRead                      = PermissionRegistry.get("healing.read")                        // [global pool]
 	PermHealingUpdate                    = PermissionRegistry.get("healing.update")                      // [global pool]
 	PermInstall                          = PermissionRegistry.get("install")                             // [global]
 	PermInstallManage                    = PermissionRegistry.get("install.manage")                      // [global]
+	PermJob                              = PermissionRegistry.get("job")                                 // [global]
+	PermJobRead                          = PermissionRegistry.get("job.read")                            // [global]
+	PermJobReadEvents                    = PermissionRegistry.get("job.read.events")                     // [global]
+	PermJobUpdate                        = PermissionRegistry.get("job.update")                          // [global]
+	PermJobUpdateRetry                   = PermissionRegistry.get("job.update.retry")                    // [global]
 	PermMachine                          = PermissionRegistry.get("machine")                             // [global iaas]
 	PermMachineCreate                    = PermissionRegistry.get("machine.create")                      // [global iaas]
 	PermMachineDelete                    = PermissionRegistry.get("machine.delete")                      // [global iaas]
@@ -100,17 +159,33 @@ var (
 	PermNodeCreate                       = PermissionRegistry.get("node.create")                         // [global pool]
 	PermNodeDelete                       = PermissionRegistry.get("node.delete")                         // [global pool]
 	PermNodeRead                         = PermissionRegistry.get("node.read")                           // [global pool]
+	PermNodeReadDiagnostics              = PermissionRegistry.get("node.read.diagnostics")               // [global pool]
 	PermNodeUpdate                       = PermissionRegistry.get("node.update")                         // [global pool]
+	PermNodeUpdateEvacuate               = PermissionRegistry.get("node.update.evacuate")                // [global pool]
 	PermNodeUpdateMove                   = PermissionRegistry.get("node.update.move")                    // [global pool]
 	PermNodeUpdateMoveContainer          = PermissionRegistry.get("node.update.move.container")          // [global pool]
 	PermNodeUpdateMoveContainers         = PermissionRegistry.get("node.update.move.containers")         // [global pool]
 	PermNodeUpdateRebalance              = PermissionRegistry.get("node.update.rebalance")               // [global pool]
+	PermNodeUpdateReconcile              = PermissionRegistry.get("node.update.reconcile")               // [global pool]
 	PermNodecontainer                    = PermissionRegistry.get("nodecontainer")                       // [global pool]
 	PermNodecontainerCreate              = PermissionRegistry.get("nodecontainer.create")                // [global pool]
 	PermNodecontainerDelete              = PermissionRegistry.get("nodecontainer.delete")                // [global pool]
 	PermNodecontainerRead                = PermissionRegistry.get("nodecontainer.read")                  // [global pool]
 	PermNodecontainerUpdate              = PermissionRegistry.get("nodecontainer.update")                // [global pool]
 	PermNodecontainerUpdateUpgrade       = PermissionRegistry.get("nodecontainer.update.upgrade")        // [global pool]
+	PermOrganization                     = PermissionRegistry.get("organization")                        // [global organization]
+	PermOrganizationCreate               = PermissionRegistry.get("organization.create")                 // [global]
+	PermOrganizationDelete               = PermissionRegistry.get("organization.delete")                 // [global organization]
+	PermOrganizationRead                 = PermissionRegistry.get("organization.read")                   // [global organization]
+	PermOrganizationReadEvents           = PermissionRegistry.get("organization.read.events")            // [global organization]
+	PermOrganizationUpdate               = PermissionRegistry.get("organization.update")                 // [global organization]
+	PermOrganizationUpdatePool           = PermissionRegistry.get("organization.update.pool")            // [global]
+	PermOrganizationUpdatePoolAdd        = PermissionRegistry.get("organization.update.pool.add")        // [global]
+	PermOrganizationUpdatePoolRemove     = PermissionRegistry.get("organization.update.pool.remove")     // [global]
+	PermOrganizationUpdateQuota          = PermissionRegistry.get("organization.update.quota")           // [global]
+	PermOrganizationUpdateTeam           = PermissionRegistry.get("organization.update.team")            // [global]
+	PermOrganizationUpdateTeamAdd        = PermissionRegistry.get("organization.update.team.add")        // [global]
+	PermOrganizationUpdateTeamRemove     = PermissionRegistry.get("organization.update.team.remove")     // [global]
 	PermPlan                             = PermissionRegistry.get("plan")                                // [global]
 	PermPlanCreate                       = PermissionRegistry.get("plan.create")                         // [global]
 	PermPlanDelete                       = PermissionRegistry.get("plan.delete")                         // [global]
@@ -122,19 +197,27 @@ var (
 	PermPlatformRead                     = PermissionRegistry.get("platform.read")                       // [global]
 	PermPlatformReadEvents               = PermissionRegistry.get("platform.read.events")                // [global]
 	PermPlatformUpdate                   = PermissionRegistry.get("platform.update")                     // [global]
-	PermPool                             = PermissionRegistry.get("pool")                                // [global pool]
+	PermPool                             = PermissionRegistry.get("pool")                                // [global pool organization tag]
 	PermPoolCreate                       = PermissionRegistry.get("pool.create")                         // [global]
-	PermPoolDelete                       = PermissionRegistry.get("pool.delete")                         // [global pool]
-	PermPoolRead                         = PermissionRegistry.get("pool.read")                           // [global pool]
-	PermPoolReadConstraints              = PermissionRegistry.get("pool.read.constraints")               // [global pool]
-	PermPoolReadEvents                   = PermissionRegistry.get("pool.read.events")                    // [global pool]
-	PermPoolUpdate                       = PermissionRegistry.get("pool.update")                         // [global pool]
-	PermPoolUpdateConstraints            = PermissionRegistry.get("pool.update.constraints")             // [global pool]
-	PermPoolUpdateConstraintsSet         = PermissionRegistry.get("pool.update.constraints.set")         // [global pool]
-	PermPoolUpdateLogs                   = PermissionRegistry.get("pool.update.logs")                    // [global pool]
-	PermPoolUpdateTeam                   = PermissionRegistry.get("pool.update.team")                    // [global pool]
-	PermPoolUpdateTeamAdd                = PermissionRegistry.get("pool.update.team.add")                // [global pool]
-	PermPoolUpdateTeamRemove             = PermissionRegistry.get("pool.update.team.remove")             // [global pool]
+	PermPoolDelete                       = PermissionRegistry.get("pool.delete")                         // [global pool organization tag]
+	PermPoolRead                         = PermissionRegistry.get("pool.read")                           // [global pool organization tag]
+	PermPoolReadConstraints              = PermissionRegistry.get("pool.read.constraints")               // [global pool organization tag]
+	PermPoolReadEvents                   = PermissionRegistry.get("pool.read.events")                    // [global pool organization tag]
+	PermPoolReadHealth                   = PermissionRegistry.get("pool.read.health")                    // [global pool organization tag]
+	PermPoolUpdate                       = PermissionRegistry.get("pool.update")                         // [global pool organization tag]
+	PermPoolUpdateConstraints            = PermissionRegistry.get("pool.update.constraints")             // [global pool organization tag]
+	PermPoolUpdateConstraintsRemove      = PermissionRegistry.get("pool.update.constraints.remove")      // [global pool organization tag]
+	PermPoolUpdateConstraintsSet         = PermissionRegistry.get("pool.update.constraints.set")         // [global pool organization tag]
+	PermPoolUpdateEnv                    = PermissionRegistry.get("pool.update.env")                     // [global pool organization tag]
+	PermPoolUpdateLogs                   = PermissionRegistry.get("pool.update.logs")                    // [global pool organization tag]
+	PermPoolUpdateMigrate                = PermissionRegistry.get("pool.update.migrate")                 // [global pool organization tag]
+	PermPoolUpdateQuota                  = PermissionRegistry.get("pool.update.quota")                   // [global pool organization tag]
+	PermPoolUpdateRename                 = PermissionRegistry.get("pool.update.rename")                  // [global pool organization tag]
+	PermPoolUpdateTags                   = PermissionRegistry.get("pool.update.tags")                    // [global pool organization tag]
+	PermPoolUpdateTeam                   = PermissionRegistry.get("pool.update.team")                    // [global pool organization tag]
+	PermPoolUpdateTeamAdd                = PermissionRegistry.get("pool.update.team.add")                // [global pool organization tag]
+	PermPoolUpdateTeamRemove             = PermissionRegistry.get("pool.update.team.remove")             // [global pool organization tag]
+	PermPoolUpdateTeamSet                = PermissionRegistry.get("pool.update.team.set")                // [global pool organization tag]
 	PermRole                             = PermissionRegistry.get("role")                                // [global]
 	PermRoleCreate                       = PermissionRegistry.get("role.create")                         // [global]
 	PermRoleDefault                      = PermissionRegistry.get("role.default")                        // [global]
@@ -149,40 +232,46 @@ var (
 	PermRoleUpdatePermission             = PermissionRegistry.get("role.update.permission")              // [global]
 	PermRoleUpdatePermissionAdd          = PermissionRegistry.get("role.update.permission.add")          // [global]
 	PermRoleUpdatePermissionRemove       = PermissionRegistry.get("role.update.permission.remove")       // [global]
-	PermService                          = PermissionRegistry.get("service")                             // [global service team]
-	PermServiceInstance                  = PermissionRegistry.get("service-instance")                    // [global service-instance team]
-	PermServiceInstanceCreate            = PermissionRegistry.get("service-instance.create")             // [global team]
-	PermServiceInstanceDelete            = PermissionRegistry.get("service-instance.delete")             // [global service-instance team]
-	PermServiceInstanceRead              = PermissionRegistry.get("service-instance.read")               // [global service-instance team]
-	PermServiceInstanceReadEvents        = PermissionRegistry.get("service-instance.read.events")        // [global service-instance team]
-	PermServiceInstanceReadStatus        = PermissionRegistry.get("service-instance.read.status")        // [global service-instance team]
-	PermServiceInstanceUpdate            = PermissionRegistry.get("service-instance.update")             // [global service-instance team]
-	PermServiceInstanceUpdateBind        = PermissionRegistry.get("service-instance.update.bind")        // [global service-instance team]
-	PermServiceInstanceUpdateDescription = PermissionRegistry.get("service-instance.update.description") // [global service-instance team]
-	PermServiceInstanceUpdateGrant       = PermissionRegistry.get("service-instance.update.grant")       // [global service-instance team]
-	PermServiceInstanceUpdateProxy       = PermissionRegistry.get("service-instance.update.proxy")       // [global service-instance team]
-	PermServiceInstanceUpdateRevoke      = PermissionRegistry.get("service-instance.update.revoke")      // [global service-instance team]
-	PermServiceInstanceUpdateTags        = PermissionRegistry.get("service-instance.update.tags")        // [global service-instance team]
-	PermServiceInstanceUpdateUnbind      = PermissionRegistry.get("service-instance.update.unbind")      // [global service-instance team]
-	PermServiceCreate                    = PermissionRegistry.get("service.create")                      // [global team]
-	PermServiceDelete                    = PermissionRegistry.get("service.delete")                      // [global service team]
-	PermServiceRead                      = PermissionRegistry.get("service.read")                        // [global service team]
-	PermServiceReadDoc                   = PermissionRegistry.get("service.read.doc")                    // [global service team]
-	PermServiceReadEvents                = PermissionRegistry.get("service.read.events")                 // [global service team]
-	PermServiceReadPlans                 = PermissionRegistry.get("service.read.plans")                  // [global service team]
-	PermServiceUpdate                    = PermissionRegistry.get("service.update")                      // [global service team]
-	PermServiceUpdateDoc                 = PermissionRegistry.get("service.update.doc")                  // [global service team]
-	PermServiceUpdateGrantAccess         = PermissionRegistry.get("service.update.grant-access")         // [global service team]
-	PermServiceUpdateProxy               = PermissionRegistry.get("service.update.proxy")                // [global service team]
-	PermServiceUpdateRevokeAccess        = PermissionRegistry.get("service.update.revoke-access")        // [global service team]
-	PermTeam                             = PermissionRegistry.get("team")                                // [global team]
+	PermService                          = PermissionRegistry.get("service")                             // [global service team organization]
+	PermServiceInstance                  = PermissionRegistry.get("service-instance")                    // [global service-instance team organization tag]
+	PermServiceInstanceCreate            = PermissionRegistry.get("service-instance.create")             // [global team organization]
+	PermServiceInstanceDelete            = PermissionRegistry.get("service-instance.delete")             // [global service-instance team organization tag]
+	PermServiceInstanceRead              = PermissionRegistry.get("service-instance.read")               // [global service-instance team organization tag]
+	PermServiceInstanceReadEvents        = PermissionRegistry.get("service-instance.read.events")        // [global service-instance team organization tag]
+	PermServiceInstanceReadStatus        = PermissionRegistry.get("service-instance.read.status")        // [global service-instance team organization tag]
+	PermServiceInstanceUpdate            = PermissionRegistry.get("service-instance.update")             // [global service-instance team organization tag]
+	PermServiceInstanceUpdateBind        = PermissionRegistry.get("service-instance.update.bind")        // [global service-instance team organization tag]
+	PermServiceInstanceUpdateDescription = PermissionRegistry.get("service-instance.update.description") // [global service-instance team organization tag]
+	PermServiceInstanceUpdateGrant       = PermissionRegistry.get("service-instance.update.grant")       // [global service-instance team organization tag]
+	PermServiceInstanceUpdateProxy       = PermissionRegistry.get("service-instance.update.proxy")       // [global service-instance team organization tag]
+	PermServiceInstanceUpdateRevoke      = PermissionRegistry.get("service-instance.update.revoke")      // [global service-instance team organization tag]
+	PermServiceInstanceUpdateTags        = PermissionRegistry.get("service-instance.update.tags")        // [global service-instance team organization tag]
+	PermServiceInstanceUpdateUnbind      = PermissionRegistry.get("service-instance.update.unbind")      // [global service-instance team organization tag]
+	PermServiceCreate                    = PermissionRegistry.get("service.create")                      // [global team organization]
+	PermServiceDelete                    = PermissionRegistry.get("service.delete")                      // [global service team organization]
+	PermServiceRead                      = PermissionRegistry.get("service.read")                        // [global service team organization]
+	PermServiceReadDoc                   = PermissionRegistry.get("service.read.doc")                    // [global service team organization]
+	PermServiceReadEvents                = PermissionRegistry.get("service.read.events")                 // [global service team organization]
+	PermServiceReadPlans                 = PermissionRegistry.get("service.read.plans")                  // [global service team organization]
+	PermServiceUpdate                    = PermissionRegistry.get("service.update")                      // [global service team organization]
+	PermServiceUpdateDoc                 = PermissionRegistry.get("service.update.doc")                  // [global service team organization]
+	PermServiceUpdateGrantAccess         = PermissionRegistry.get("service.update.grant-access")         // [global service team organization]
+	PermServiceUpdateProxy               = PermissionRegistry.get("service.update.proxy")                // [global service team organization]
+	PermServiceUpdateRevokeAccess        = PermissionRegistry.get("service.update.revoke-access")        // [global service team organization]
+	PermTeam                             = PermissionRegistry.get("team")                                // [global team organization]
 	PermTeamCreate                       = PermissionRegistry.get("team.create")                         // [global]
-	PermTeamDelete                       = PermissionRegistry.get("team.delete")                         // [global team]
-	PermTeamRead                         = PermissionRegistry.get("team.read")                           // [global team]
-	PermTeamReadEvents                   = PermissionRegistry.get("team.read.events")                    // [global team]
+	PermTeamDelete                       = PermissionRegistry.get("team.delete")                         // [global team organization]
+	PermTeamRead                         = PermissionRegistry.get("team.read")                           // [global team organization]
+	PermTeamReadApiUsage                 = PermissionRegistry.get("team.read.api-usage")                 // [global team organization]
+	PermTeamReadEvents                   = PermissionRegistry.get("team.read.events")                    // [global team organization]
+	PermTeamUpdate                       = PermissionRegistry.get("team.update")                         // [global team organization]
+	PermTeamUpdateApiQuota               = PermissionRegistry.get("team.update.api-quota")               // [global team organization]
+	PermTeamUpdateDeployPriority         = PermissionRegistry.get("team.update.deploy-priority")         // [global team organization]
+	PermTeamUpdateDeployStatus           = PermissionRegistry.get("team.update.deploy-status")           // [global team organization]
 	PermUser                             = PermissionRegistry.get("user")                                // [global user]
 	PermUserCreate                       = PermissionRegistry.get("user.create")                         // [global]
 	PermUserDelete                       = PermissionRegistry.get("user.delete")                         // [global user]
+	PermUserImpersonate                  = PermissionRegistry.get("user.impersonate")                    // [global user]
 	PermUserRead                         = PermissionRegistry.get("user.read")                           // [global user]
 	PermUserReadEvents                   = PermissionRegistry.get("user.read.events")                    // [global user]
 	PermUserUpdate                       = PermissionRegistry.get("user.update")                         // [global user]
@@ -192,5 +281,6 @@ var (
 	PermUserUpdatePassword               = PermissionRegistry.get("user.update.password")                // [global user]
 	PermUserUpdateQuota                  = PermissionRegistry.get("user.update.quota")                   // [global user]
 	PermUserUpdateReset                  = PermissionRegistry.get("user.update.reset")                   // [global user]
+	PermUserUpdateSettings               = PermissionRegistry.get("user.update.settings")                // [global user]
 	PermUserUpdateToken                  = PermissionRegistry.get("user.update.token")                   // [global user]
 )
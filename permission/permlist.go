@@ -7,9 +7,9 @@ package permission
 //go:generate bash -c "rm -f permitems.go && go run ./generator/main.go -o permitems.go"
 
 var PermissionRegistry = (&registry{}).addWithCtx(
-	"app", []contextType{CtxApp, CtxTeam, CtxPool},
+	"app", []contextType{CtxApp, CtxTeam, CtxPool, CtxOrganization, CtxTag},
 ).addWithCtx(
-	"app.create", []contextType{CtxTeam},
+	"app.create", []contextType{CtxTeam, CtxOrganization},
 ).add(
 	"app.update.description",
 	"app.update.tags",
@@ -33,16 +33,34 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.update.cname.remove",
 	"app.update.plan",
 	"app.update.router",
+	"app.update.platform",
 	"app.update.bind",
 	"app.update.events",
 	"app.update.unbind",
 	"app.update.certificate.set",
 	"app.update.certificate.unset",
+	"app.update.deploy-status",
+	"app.update.deploy-priority",
+	"app.update.auto-rollback",
+	"app.update.deploy-toggles",
+	"app.update.maintenance",
+	"app.update.mirror",
+	"app.update.egress",
+	"app.update.node-requirements",
+	"app.update.preemption-tolerance",
+	"app.update.process-settings",
+	"app.update.metrics",
+	"app.update.config-templates",
+	"app.update.chaos.run",
+	"app.update.chaos.stop",
+	"app.update.annotate",
 	"app.deploy",
 	"app.deploy.archive-url",
 	"app.deploy.build",
+	"app.deploy.freeze-exception",
 	"app.deploy.git",
 	"app.deploy.image",
+	"app.deploy.promote",
 	"app.deploy.rollback",
 	"app.deploy.upload",
 	"app.read",
@@ -58,14 +76,20 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.admin.unlock",
 	"app.admin.routes",
 	"app.admin.quota",
+	"app.admin.cname",
+	"app.admin.env.rotate",
+	"app.admin.metrics-targets",
 ).addWithCtx(
 	"node", []contextType{CtxPool},
 ).add(
 	"node.create",
 	"node.read",
+	"node.read.diagnostics",
 	"node.update.move.container",
 	"node.update.move.containers",
 	"node.update.rebalance",
+	"node.update.evacuate",
+	"node.update.reconcile",
 	"node.delete",
 ).addWithCtx(
 	"node.autoscale", []contextType{},
@@ -86,11 +110,15 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"machine.template.update",
 	"machine.template.read",
 ).addWithCtx(
-	"team", []contextType{CtxTeam},
+	"team", []contextType{CtxTeam, CtxOrganization},
 ).addWithCtx(
 	"team.create", []contextType{},
 ).add(
 	"team.read.events",
+	"team.update.deploy-status",
+	"team.update.deploy-priority",
+	"team.update.api-quota",
+	"team.read.api-usage",
 	"team.delete",
 ).addWithCtx(
 	"user", []contextType{CtxUser},
@@ -103,12 +131,14 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"user.update.quota",
 	"user.update.password",
 	"user.update.reset",
+	"user.update.settings",
 	"user.update.key.add",
 	"user.update.key.remove",
+	"user.impersonate",
 ).addWithCtx(
-	"service", []contextType{CtxService, CtxTeam},
+	"service", []contextType{CtxService, CtxTeam, CtxOrganization},
 ).addWithCtx(
-	"service.create", []contextType{CtxTeam},
+	"service.create", []contextType{CtxTeam, CtxOrganization},
 ).add(
 	"service.read.doc",
 	"service.read.plans",
@@ -119,9 +149,9 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"service.update.doc",
 	"service.delete",
 ).addWithCtx(
-	"service-instance", []contextType{CtxServiceInstance, CtxTeam},
+	"service-instance", []contextType{CtxServiceInstance, CtxTeam, CtxOrganization, CtxTag},
 ).addWithCtx(
-	"service-instance.create", []contextType{CtxTeam},
+	"service-instance.create", []contextType{CtxTeam, CtxOrganization},
 ).add(
 	"service-instance.read.events",
 	"service-instance.read.status",
@@ -152,17 +182,38 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"plan.create",
 	"plan.delete",
 	"plan.read.events",
+).add(
+	"hardware-profile.create",
+	"hardware-profile.delete",
+	"hardware-profile.read.events",
+).add(
+	"constraint-template.create",
+	"constraint-template.delete",
+	"constraint-template.read.events",
+).add(
+	"deploy-freeze.create",
+	"deploy-freeze.delete",
+	"deploy-freeze.exception.approve",
+	"deploy-freeze.read.events",
 ).addWithCtx(
-	"pool", []contextType{CtxPool},
+	"pool", []contextType{CtxPool, CtxOrganization, CtxTag},
 ).addWithCtx(
 	"pool.create", []contextType{},
 ).add(
 	"pool.read.events",
 	"pool.update.team.add",
 	"pool.update.team.remove",
+	"pool.update.team.set",
 	"pool.update.constraints.set",
+	"pool.update.constraints.remove",
 	"pool.read.constraints",
+	"pool.read.health",
 	"pool.update.logs",
+	"pool.update.rename",
+	"pool.update.env",
+	"pool.update.quota",
+	"pool.update.migrate",
+	"pool.update.tags",
 	"pool.delete",
 ).add(
 	"debug",
@@ -189,8 +240,48 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"event-block.read.events",
 	"event-block.add",
 	"event-block.remove",
+).add(
+	"event-retention-rule.read",
+	"event-retention-rule.read.events",
+	"event-retention-rule.set",
+	"event-retention-rule.remove",
+).addWithCtx(
+	"event-webhook", []contextType{CtxTeam, CtxApp},
+).add(
+	"event-webhook.read",
+	"event-webhook.read.events",
+	"event-webhook.set",
+	"event-webhook.remove",
+).add(
+	"compliance-report.read",
+	"compliance-report.read.events",
+	"compliance-report.create",
+).add(
+	"job.read",
+	"job.read.events",
+	"job.update.retry",
 ).add(
 	"cluster.read.events",
 	"cluster.update",
 	"cluster.delete",
+).addWithCtx(
+	"organization", []contextType{CtxOrganization},
+).addWithCtx(
+	"organization.create", []contextType{},
+).addWithCtx(
+	"organization.update.team", []contextType{},
+).add(
+	"organization.update.team.add",
+	"organization.update.team.remove",
+).addWithCtx(
+	"organization.update.pool", []contextType{},
+).add(
+	"organization.update.pool.add",
+	"organization.update.pool.remove",
+).addWithCtx(
+	"organization.update.quota", []contextType{},
+).add(
+	"organization.read",
+	"organization.read.events",
+	"organization.delete",
 )
@@ -0,0 +1,115 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package opa provides an implementation of the policy Engine that evaluates
+// inputs against Rego policies loaded in an Open Policy Agent server, using
+// its REST API. This package doesn't expose any public types, in order to use
+// it, users need to import the package and then configure tsuru to use the
+// "opa" policy engine.
+//
+//     import _ "github.com/tsuru/tsuru/policy/opa"
+//
+// The policy package is expected to define a "deny" set of messages. Any
+// message present in the set denies the operation:
+//
+//     package tsuru.admission
+//
+//     deny[msg] {
+//         input.action == "app.create"
+//         not startswith(input.data.name, "team-")
+//         msg := "app names must start with team-"
+//     }
+package opa
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/policy"
+)
+
+const defaultPackage = "tsuru/admission"
+
+func init() {
+	policy.Register("opa", opaEngine{})
+	hc.AddChecker("OPA", healthCheck)
+}
+
+type opaEngine struct{}
+
+type opaResult struct {
+	Result *struct {
+		Deny []string `json:"deny"`
+	} `json:"result"`
+}
+
+func serverURL() (string, error) {
+	url, err := config.GetString("policy:opa:url")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(url, "/"), nil
+}
+
+func healthCheck() error {
+	if engine, _ := config.GetString("policy:engine"); engine != "opa" {
+		return hc.ErrDisabledComponent
+	}
+	url, err := serverURL()
+	if err != nil {
+		return err
+	}
+	rsp, err := net.Dial5Full60ClientNoKeepAlive.Get(url + "/health")
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status code - %d", rsp.StatusCode)
+	}
+	return nil
+}
+
+func (opaEngine) Evaluate(input *policy.Input) (*policy.Decision, error) {
+	url, err := serverURL()
+	if err != nil {
+		return nil, err
+	}
+	pkg, _ := config.GetString("policy:opa:package")
+	if pkg == "" {
+		pkg = defaultPackage
+	}
+	pkg = strings.Replace(strings.Trim(pkg, "/"), ".", "/", -1)
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := net.Dial5Full60ClientNoKeepAlive.Post(fmt.Sprintf("%s/v1/data/%s", url, pkg), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to evaluate policy")
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unable to evaluate policy: unexpected status code %d", rsp.StatusCode)
+	}
+	var result opaResult
+	err = json.NewDecoder(rsp.Body).Decode(&result)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse policy result")
+	}
+	if result.Result == nil {
+		return nil, errors.Errorf("policy package %q not found", pkg)
+	}
+	return &policy.Decision{
+		Allowed: len(result.Result.Deny) == 0,
+		Reasons: result.Result.Deny,
+	}, nil
+}
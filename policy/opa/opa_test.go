@@ -0,0 +1,87 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opa
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/policy"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("policy")
+}
+
+func (s *S) TestEvaluate(c *check.C) {
+	var path string
+	var received map[string]policy.Input
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{"result": {"deny": ["app names must start with team-"]}}`))
+	}))
+	defer server.Close()
+	config.Set("policy:opa:url", server.URL)
+	config.Set("policy:opa:package", "custom.rules")
+	input := &policy.Input{Action: "app.create", User: "me@tsuru.io", TargetType: "app", TargetValue: "myapp"}
+	decision, err := opaEngine{}.Evaluate(input)
+	c.Assert(err, check.IsNil)
+	c.Assert(decision, check.DeepEquals, &policy.Decision{
+		Allowed: false,
+		Reasons: []string{"app names must start with team-"},
+	})
+	c.Assert(path, check.Equals, "/v1/data/custom/rules")
+	c.Assert(received["input"], check.DeepEquals, *input)
+}
+
+func (s *S) TestEvaluateAllowed(c *check.C) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`{"result": {"deny": []}}`))
+	}))
+	defer server.Close()
+	config.Set("policy:opa:url", server.URL)
+	decision, err := opaEngine{}.Evaluate(&policy.Input{Action: "app.create"})
+	c.Assert(err, check.IsNil)
+	c.Assert(decision.Allowed, check.Equals, true)
+	c.Assert(path, check.Equals, "/v1/data/tsuru/admission")
+}
+
+func (s *S) TestEvaluatePackageNotFound(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	config.Set("policy:opa:url", server.URL)
+	_, err := opaEngine{}.Evaluate(&policy.Input{Action: "app.create"})
+	c.Assert(err, check.ErrorMatches, `policy package "tsuru/admission" not found`)
+}
+
+func (s *S) TestEvaluateServerError(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	config.Set("policy:opa:url", server.URL)
+	_, err := opaEngine{}.Evaluate(&policy.Input{Action: "app.create"})
+	c.Assert(err, check.ErrorMatches, "unable to evaluate policy: unexpected status code 500")
+}
+
+func (s *S) TestHealthCheckDisabled(c *check.C) {
+	c.Assert(healthCheck(), check.Equals, hc.ErrDisabledComponent)
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package policy provides an extension point for admission decisions. Before
+// operations like app creation, deploy, env set and pool update, tsuru
+// describes the operation as an Input and asks the configured Engine whether
+// it should be allowed.
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+const defaultEngine = "nop"
+
+var engines map[string]Engine
+
+// Input describes an operation that is about to be executed.
+type Input struct {
+	// Action is the name of the permission associated with the operation,
+	// e.g. app.create or pool.update.
+	Action string `json:"action"`
+	// User is the name of the user (or app token) executing the operation.
+	User string `json:"user"`
+	// TargetType and TargetValue identify the object being changed.
+	TargetType  string `json:"targetType"`
+	TargetValue string `json:"targetValue"`
+	// Data holds action specific data, like the app being created or the
+	// list of environment variables being set.
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Decision is the result of the evaluation of an Input by an Engine.
+type Decision struct {
+	Allowed bool
+	Reasons []string
+}
+
+// Engine represents a policy engine, able to evaluate inputs against a set
+// of rules defined by the operator.
+type Engine interface {
+	Evaluate(input *Input) (*Decision, error)
+}
+
+// ErrDenied is returned by Check when the engine denies the operation.
+type ErrDenied struct {
+	Action  string
+	Reasons []string
+}
+
+func (e *ErrDenied) Error() string {
+	msg := fmt.Sprintf("%s denied by policy", e.Action)
+	if len(e.Reasons) > 0 {
+		msg += ": " + strings.Join(e.Reasons, "; ")
+	}
+	return msg
+}
+
+// Register registers a new policy engine, that can be later configured and
+// used.
+func Register(name string, engine Engine) {
+	if engines == nil {
+		engines = make(map[string]Engine)
+	}
+	engines[name] = engine
+}
+
+// GetEngine returns the current configured engine, as defined in the
+// configuration file. When no engine is configured, the nop engine is used.
+// An unknown engine is an error, so enforcement never fails open because of
+// a misspelled name.
+func GetEngine() (Engine, error) {
+	name, err := config.GetString("policy:engine")
+	if err != nil {
+		name = defaultEngine
+	}
+	engine, ok := engines[name]
+	if !ok {
+		return nil, errors.Errorf("unknown policy engine: %q", name)
+	}
+	return engine, nil
+}
+
+// Check evaluates the input using the configured engine, returning an
+// *ErrDenied when the operation is not allowed.
+func Check(input *Input) error {
+	engine, err := GetEngine()
+	if err != nil {
+		return err
+	}
+	decision, err := engine.Evaluate(input)
+	if err != nil {
+		return err
+	}
+	if !decision.Allowed {
+		return &ErrDenied{Action: input.Action, Reasons: decision.Reasons}
+	}
+	return nil
+}
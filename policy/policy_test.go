@@ -0,0 +1,84 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type denyEngine struct {
+	reasons []string
+	inputs  []*Input
+}
+
+func (e *denyEngine) Evaluate(input *Input) (*Decision, error) {
+	e.inputs = append(e.inputs, input)
+	return &Decision{Allowed: len(e.reasons) == 0, Reasons: e.reasons}, nil
+}
+
+func (s *S) TestRegister(c *check.C) {
+	engine := &denyEngine{}
+	Register("deny", engine)
+	defer delete(engines, "deny")
+	c.Assert(engines["deny"], check.Equals, engine)
+}
+
+func (s *S) TestGetEngine(c *check.C) {
+	engine := &denyEngine{}
+	Register("deny", engine)
+	defer delete(engines, "deny")
+	config.Set("policy:engine", "deny")
+	defer config.Unset("policy:engine")
+	e, err := GetEngine()
+	c.Assert(err, check.IsNil)
+	c.Assert(e, check.Equals, engine)
+}
+
+func (s *S) TestGetEngineUnconfigured(c *check.C) {
+	config.Unset("policy:engine")
+	e, err := GetEngine()
+	c.Assert(err, check.IsNil)
+	c.Assert(e, check.FitsTypeOf, nopEngine{})
+}
+
+func (s *S) TestGetEngineUnknown(c *check.C) {
+	config.Set("policy:engine", "something")
+	defer config.Unset("policy:engine")
+	e, err := GetEngine()
+	c.Assert(err, check.ErrorMatches, `unknown policy engine: "something"`)
+	c.Assert(e, check.IsNil)
+}
+
+func (s *S) TestCheckUnknownEngine(c *check.C) {
+	config.Set("policy:engine", "something")
+	defer config.Unset("policy:engine")
+	err := Check(&Input{Action: "app.create"})
+	c.Assert(err, check.ErrorMatches, `unknown policy engine: "something"`)
+}
+
+func (s *S) TestCheck(c *check.C) {
+	engine := &denyEngine{}
+	Register("deny", engine)
+	defer delete(engines, "deny")
+	config.Set("policy:engine", "deny")
+	defer config.Unset("policy:engine")
+	input := &Input{Action: "app.create", User: "me@tsuru.io", TargetType: "app", TargetValue: "myapp"}
+	err := Check(input)
+	c.Assert(err, check.IsNil)
+	c.Assert(engine.inputs, check.DeepEquals, []*Input{input})
+}
+
+func (s *S) TestCheckDenied(c *check.C) {
+	engine := &denyEngine{reasons: []string{"no apps on fridays", "name too short"}}
+	Register("deny", engine)
+	defer delete(engines, "deny")
+	config.Set("policy:engine", "deny")
+	defer config.Unset("policy:engine")
+	err := Check(&Input{Action: "app.create"})
+	c.Assert(err, check.FitsTypeOf, &ErrDenied{})
+	c.Assert(err.(*ErrDenied).Reasons, check.DeepEquals, []string{"no apps on fridays", "name too short"})
+	c.Assert(err, check.ErrorMatches, "app.create denied by policy: no apps on fridays; name too short")
+}
@@ -5,6 +5,7 @@
 package cluster
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -65,6 +66,16 @@ func (c *Cluster) validate() error {
 			return errors.WithStack(&tsuruErrors.ValidationError{Message: "either default or a list of pools must be set"})
 		}
 	}
+	for _, poolName := range c.Pools {
+		pool := provision.Pool{Name: poolName}
+		allowed, err := pool.AllowsCluster(c.Name)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return errors.WithStack(&tsuruErrors.ValidationError{Message: fmt.Sprintf("cluster %q is not allowed in pool %q", c.Name, poolName)})
+		}
+	}
 	return nil
 }
 
@@ -118,6 +129,23 @@ func ForProvisioner(provisioner string) ([]*Cluster, error) {
 	return listClusters(bson.M{"provisioner": provisioner})
 }
 
+func ByName(clusterName string) (*Cluster, error) {
+	coll, err := clusterCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var c Cluster
+	err = coll.FindId(clusterName).One(&c)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, ErrClusterNotFound
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &c, nil
+}
+
 func ForPool(provisioner, pool string) (*Cluster, error) {
 	coll, err := clusterCollection()
 	if err != nil {
@@ -13,6 +13,7 @@ import (
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
 	_ "github.com/tsuru/tsuru/provision/provisiontest"
 	"gopkg.in/check.v1"
 )
@@ -258,6 +259,27 @@ func (s *S) TestClusterSaveValidation(c *check.C) {
 	}
 }
 
+func (s *S) TestClusterSaveNotAllowedInPool(c *check.C) {
+	err := provision.SetPoolConstraint(&provision.PoolConstraint{
+		PoolExpr: "prod*",
+		Field:    "cluster",
+		Values:   []string{"prod-cluster"},
+	})
+	c.Assert(err, check.IsNil)
+	cluster := Cluster{
+		Name:        "c1",
+		Addresses:   []string{"addr1"},
+		Pools:       []string{"dev", "prod1"},
+		Provisioner: "fake",
+	}
+	err = cluster.Save()
+	c.Assert(err, check.ErrorMatches, `cluster "c1" is not allowed in pool "prod1"`)
+	c.Assert(errors.Cause(err), check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	cluster.Name = "prod-cluster"
+	err = cluster.Save()
+	c.Assert(err, check.IsNil)
+}
+
 func (s *S) TestAllClusters(c *check.C) {
 	c1 := Cluster{
 		Name:        "c1",
@@ -284,6 +306,23 @@ func (s *S) TestAllClusters(c *check.C) {
 	c.Assert(clusters, check.DeepEquals, []*Cluster{&c1, &c2})
 }
 
+func (s *S) TestByName(c *check.C) {
+	c1 := Cluster{
+		Name:        "c1",
+		Addresses:   []string{"addr1"},
+		Pools:       []string{"p1"},
+		Provisioner: "fake",
+	}
+	err := c1.Save()
+	c.Assert(err, check.IsNil)
+	cluster, err := ByName("c1")
+	c.Assert(err, check.IsNil)
+	c.Assert(cluster.Name, check.Equals, "c1")
+	c.Assert(cluster.Pools, check.DeepEquals, []string{"p1"})
+	_, err = ByName("c2")
+	c.Assert(err, check.Equals, ErrClusterNotFound)
+}
+
 func (s *S) TestForPool(c *check.C) {
 	c1 := Cluster{
 		Name:        "c1",
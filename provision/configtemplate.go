@@ -0,0 +1,114 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidConfigTemplate  = errors.New("invalid config template: name must contain only letters, numbers, dots, dashes and underscores, and path must be absolute")
+	ErrConfigTemplateNotFound = errors.New("config template not found")
+	ErrConfigTemplatePathUsed = errors.New("config template path is used by another template")
+
+	configTemplateNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+)
+
+// ConfigTemplate is a configuration file of an app, like nginx.conf, rendered
+// with the app environment when the app is deployed or restarted. Content is
+// a Go text/template.
+type ConfigTemplate struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// ConfigTemplateData is the data available to config templates.
+type ConfigTemplateData struct {
+	App     string
+	Pool    string
+	Process string
+	Env     map[string]string
+}
+
+// RenderedConfigTemplate is a config template rendered for a process of an
+// app.
+type RenderedConfigTemplate struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+func (t ConfigTemplate) Validate() error {
+	if !configTemplateNameRegexp.MatchString(t.Name) {
+		return ErrInvalidConfigTemplate
+	}
+	if !path.IsAbs(t.Path) || path.Clean(t.Path) != t.Path || t.Path == "/" {
+		return ErrInvalidConfigTemplate
+	}
+	_, err := t.parse()
+	return err
+}
+
+func (t ConfigTemplate) parse() (*template.Template, error) {
+	tpl, err := template.New(t.Name).Option("missingkey=error").Parse(t.Content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid config template %q", t.Name)
+	}
+	return tpl, nil
+}
+
+// Render executes the template with the given data. Referencing undefined
+// environment variables is an error.
+func (t ConfigTemplate) Render(data ConfigTemplateData) (string, error) {
+	tpl, err := t.parse()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, data)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to render config template %q", t.Name)
+	}
+	return buf.String(), nil
+}
+
+// ConfigTemplateDataForApp returns the data used to render the config
+// templates of the given process of the app, with the same environment
+// variables set in its units.
+func ConfigTemplateDataForApp(a App, process string) ConfigTemplateData {
+	env := map[string]string{}
+	for _, e := range EnvsForApp(a, process, false) {
+		env[e.Name] = e.Value
+	}
+	return ConfigTemplateData{
+		App:     a.GetName(),
+		Pool:    a.GetPool(),
+		Process: process,
+		Env:     env,
+	}
+}
+
+// RenderConfigTemplates renders the given templates for a process of the app.
+func RenderConfigTemplates(a App, process string, templates []ConfigTemplate) ([]RenderedConfigTemplate, error) {
+	if len(templates) == 0 {
+		return nil, nil
+	}
+	data := ConfigTemplateDataForApp(a, process)
+	rendered := make([]RenderedConfigTemplate, len(templates))
+	for i, t := range templates {
+		content, err := t.Render(data)
+		if err != nil {
+			return nil, err
+		}
+		rendered[i] = RenderedConfigTemplate{Name: t.Name, Path: t.Path, Content: content}
+	}
+	return rendered, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision_test
+
+import (
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestConfigTemplateValidate(c *check.C) {
+	valid := []provision.ConfigTemplate{
+		{Name: "nginx.conf", Path: "/etc/nginx/nginx.conf"},
+		{Name: "settings_1", Path: "/home/application/settings.py", Content: "DEBUG = {{.Env.DEBUG}}"},
+	}
+	for _, t := range valid {
+		c.Check(t.Validate(), check.IsNil)
+	}
+	invalid := []provision.ConfigTemplate{
+		{Path: "/etc/nginx/nginx.conf"},
+		{Name: "../nginx.conf", Path: "/etc/nginx/nginx.conf"},
+		{Name: "nginx.conf"},
+		{Name: "nginx.conf", Path: "etc/nginx.conf"},
+		{Name: "nginx.conf", Path: "/etc/../nginx.conf"},
+		{Name: "nginx.conf", Path: "/"},
+	}
+	for _, t := range invalid {
+		c.Check(t.Validate(), check.Equals, provision.ErrInvalidConfigTemplate)
+	}
+	t := provision.ConfigTemplate{Name: "nginx.conf", Path: "/etc/nginx.conf", Content: "{{.Env.PORT"}
+	c.Assert(t.Validate(), check.ErrorMatches, `invalid config template "nginx.conf".*`)
+}
+
+func (s *S) TestRenderConfigTemplates(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	a.SetEnv(bind.EnvVar{Name: "WORKERS", Value: "4"})
+	templates := []provision.ConfigTemplate{
+		{Name: "nginx.conf", Path: "/etc/nginx.conf", Content: "listen {{.Env.PORT}};"},
+		{Name: "app.ini", Path: "/etc/app.ini", Content: "app={{.App}} process={{.Process}} workers={{.Env.WORKERS}}"},
+	}
+	rendered, err := provision.RenderConfigTemplates(a, "worker", templates)
+	c.Assert(err, check.IsNil)
+	c.Assert(rendered, check.DeepEquals, []provision.RenderedConfigTemplate{
+		{Name: "nginx.conf", Path: "/etc/nginx.conf", Content: "listen 8888;"},
+		{Name: "app.ini", Path: "/etc/app.ini", Content: "app=myapp process=worker workers=4"},
+	})
+	rendered, err = provision.RenderConfigTemplates(a, "web", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(rendered, check.IsNil)
+}
+
+func (s *S) TestRenderConfigTemplatesUndefinedEnv(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	templates := []provision.ConfigTemplate{
+		{Name: "app.ini", Path: "/etc/app.ini", Content: "workers={{.Env.WORKERS}}"},
+	}
+	_, err := provision.RenderConfigTemplates(a, "web", templates)
+	c.Assert(err, check.ErrorMatches, `unable to render config template "app.ini".*map has no entry for key "WORKERS".*`)
+}
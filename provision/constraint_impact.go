@@ -0,0 +1,227 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Kinds of objects violating constraints.
+const (
+	ViolationKindApp     = "app"
+	ViolationKindCluster = "cluster"
+)
+
+// ConstraintViolation is an existing app, or cluster, whose value for the
+// field of a constraint would no longer be allowed in its pool.
+type ConstraintViolation struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Pool  string `json:"pool"`
+	Value string `json:"value"`
+}
+
+// ConstraintViolationsError is returned when a constraint change is blocked
+// because it would make existing objects non-compliant.
+type ConstraintViolationsError struct {
+	Violations []ConstraintViolation
+}
+
+func (e *ConstraintViolationsError) Error() string {
+	descs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		descs[i] = fmt.Sprintf("%s %q in pool %q (%s)", v.Kind, v.Name, v.Pool, v.Value)
+	}
+	return fmt.Sprintf("constraint change would make %d objects non-compliant: %s", len(descs), strings.Join(descs, ", "))
+}
+
+// PoolConstraintImpact returns the existing apps and clusters allowed by the
+// constraints in place that wouldn't be allowed anymore after setting the
+// given constraint, or appending its values when appendValues is true. No
+// constraint is changed.
+func PoolConstraintImpact(c *PoolConstraint, appendValues bool) ([]ConstraintViolation, error) {
+	err := validateConstraintType(c.Field)
+	if err != nil {
+		return nil, err
+	}
+	candidate := *c
+	err = candidate.validateLayer()
+	if err != nil {
+		return nil, err
+	}
+	err = candidate.validatePoolExpr()
+	if err != nil {
+		return nil, err
+	}
+	if appendValues {
+		existing, err := ListPoolsConstraints(constraintKey(candidate.PoolExpr, candidate.Field, candidate.Layer))
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) > 0 {
+			candidate.Blacklist = existing[0].Blacklist
+			candidate.Values = append(existing[0].Values, candidate.Values...)
+		}
+	}
+	pools, err := listPools(nil)
+	if err != nil {
+		return nil, err
+	}
+	violations := []ConstraintViolation{}
+	for _, p := range pools {
+		affected, err := candidate.affectsPool(&p)
+		if err != nil {
+			return nil, err
+		}
+		if !affected {
+			continue
+		}
+		current, err := getConstraintsForPool(p.Name, c.Field)
+		if err != nil {
+			return nil, err
+		}
+		changed, err := resolveConstraintsForPool(p.Name, &candidate, c.Field)
+		if err != nil {
+			return nil, err
+		}
+		objects, err := constraintFieldObjects(p.Name, c.Field)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range objects {
+			if constraintAllows(current[c.Field], v.Value) && !constraintAllows(changed[c.Field], v.Value) {
+				v.Pool = p.Name
+				violations = append(violations, v)
+			}
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Pool == violations[j].Pool {
+			return violations[i].Name < violations[j].Name
+		}
+		return violations[i].Pool < violations[j].Pool
+	})
+	return violations, nil
+}
+
+// SetPoolConstraintEnforced sets the constraint, or appends its values, only
+// if no existing app or cluster would become non-compliant, returning a
+// *ConstraintViolationsError otherwise.
+func SetPoolConstraintEnforced(c *PoolConstraint, appendValues bool) error {
+	violations, err := PoolConstraintImpact(c, appendValues)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &ConstraintViolationsError{Violations: violations}
+	}
+	if appendValues {
+		return AppendPoolConstraint(c)
+	}
+	return SetPoolConstraint(c)
+}
+
+func (c *PoolConstraint) affectsPool(p *Pool) (bool, error) {
+	switch c.Layer {
+	case ConstraintLayerGlobal:
+		return true, nil
+	case ConstraintLayerGroup:
+		return p.Group == c.PoolExpr, nil
+	}
+	return poolExprMatches(c.PoolExpr, p.Name)
+}
+
+func (c *PoolConstraint) sameKey(other *PoolConstraint) bool {
+	return c.PoolExpr == other.PoolExpr && c.Field == other.Field && c.Layer == other.Layer
+}
+
+// withCandidate replaces the constraint with the same key of the candidate
+// in the list, adding the candidate when include is true.
+func withCandidate(constraints []*PoolConstraint, candidate *PoolConstraint, include bool) []*PoolConstraint {
+	result := make([]*PoolConstraint, 0, len(constraints)+1)
+	for _, c := range constraints {
+		if !c.sameKey(candidate) {
+			result = append(result, c)
+		}
+	}
+	if include && len(candidate.Values) > 0 && (len(candidate.Values) > 1 || candidate.Values[0] != "") {
+		result = append(result, candidate)
+	}
+	return result
+}
+
+// constraintAllows returns whether the value is allowed by the constraint
+// applied to a pool. Fields without a constraint allow every value.
+func constraintAllows(c *PoolConstraint, v string) bool {
+	if c == nil {
+		return true
+	}
+	return c.check(v)
+}
+
+// constraintFieldObjects returns the apps, or clusters, of the pool along
+// with their values for the field.
+func constraintFieldObjects(pool, field string) ([]ConstraintViolation, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var objects []ConstraintViolation
+	if field == "cluster" {
+		var clusters []struct {
+			Name string `bson:"_id"`
+		}
+		err = conn.ProvisionerClusters().Find(bson.M{"pools": pool}).Select(bson.M{"_id": 1}).All(&clusters)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range clusters {
+			objects = append(objects, ConstraintViolation{Kind: ViolationKindCluster, Name: c.Name, Value: c.Name})
+		}
+		return objects, nil
+	}
+	var apps []struct {
+		Name      string
+		TeamOwner string
+		Router    string
+		Platform  string `bson:"framework"`
+		Plan      struct {
+			Name string
+		}
+	}
+	err = conn.Apps().Find(bson.M{"pool": pool}).Select(bson.M{
+		"name":      1,
+		"teamowner": 1,
+		"router":    1,
+		"framework": 1,
+		"plan.name": 1,
+	}).All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range apps {
+		var value string
+		switch field {
+		case "team":
+			value = a.TeamOwner
+		case "router":
+			value = a.Router
+		case "platform":
+			value = a.Platform
+		case "plan":
+			value = a.Plan.Name
+		}
+		if value != "" {
+			objects = append(objects, ConstraintViolation{Kind: ViolationKindApp, Name: a.Name, Value: value})
+		}
+	}
+	return objects, nil
+}
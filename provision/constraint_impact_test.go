@@ -0,0 +1,100 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) addImpactFixtures(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "prod1", Group: "prod"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "prod2", Group: "prod"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "dev1"})
+	c.Assert(err, check.IsNil)
+	apps := []bson.M{
+		{"name": "a1", "pool": "prod1", "router": "planb", "teamowner": "ateam", "framework": "python", "plan": bson.M{"name": "small"}},
+		{"name": "a2", "pool": "prod2", "router": "galeb", "teamowner": "ateam", "framework": "go", "plan": bson.M{"name": "large"}},
+		{"name": "a3", "pool": "dev1", "router": "planb", "teamowner": "test", "framework": "python"},
+	}
+	for _, a := range apps {
+		err = s.storage.Apps().Insert(a)
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *S) TestPoolConstraintImpact(c *check.C) {
+	s.addImpactFixtures(c)
+	violations, err := PoolConstraintImpact(&PoolConstraint{PoolExpr: "prod*", Field: "router", Values: []string{"galeb"}}, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(violations, check.DeepEquals, []ConstraintViolation{
+		{Kind: ViolationKindApp, Name: "a1", Pool: "prod1", Value: "planb"},
+	})
+	violations, err = PoolConstraintImpact(&PoolConstraint{Layer: ConstraintLayerGlobal, Field: "platform", Values: []string{"python"}, Blacklist: true}, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(violations, check.DeepEquals, []ConstraintViolation{
+		{Kind: ViolationKindApp, Name: "a3", Pool: "dev1", Value: "python"},
+		{Kind: ViolationKindApp, Name: "a1", Pool: "prod1", Value: "python"},
+	})
+	violations, err = PoolConstraintImpact(&PoolConstraint{Layer: ConstraintLayerGroup, PoolExpr: "prod", Field: "plan", Values: []string{"large"}}, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(violations, check.DeepEquals, []ConstraintViolation{
+		{Kind: ViolationKindApp, Name: "a1", Pool: "prod1", Value: "small"},
+	})
+	constraints, err := ListPoolsConstraints(bson.M{"field": bson.M{"$in": []string{"router", "platform", "plan"}}})
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 0)
+}
+
+func (s *S) TestPoolConstraintImpactIgnoresNonCompliant(c *check.C) {
+	s.addImpactFixtures(c)
+	err := SetPoolConstraint(&PoolConstraint{PoolExpr: "prod1", Field: "router", Values: []string{"galeb"}})
+	c.Assert(err, check.IsNil)
+	violations, err := PoolConstraintImpact(&PoolConstraint{PoolExpr: "prod1", Field: "router", Values: []string{"hipache"}}, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(violations, check.HasLen, 0)
+	violations, err = PoolConstraintImpact(&PoolConstraint{PoolExpr: "prod1", Field: "router", Values: []string{"planb"}}, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(violations, check.HasLen, 0)
+}
+
+func (s *S) TestPoolConstraintImpactAppend(c *check.C) {
+	s.addImpactFixtures(c)
+	err := SetPoolConstraint(&PoolConstraint{PoolExpr: "prod*", Field: "router", Values: []string{"hipache"}, Blacklist: true})
+	c.Assert(err, check.IsNil)
+	violations, err := PoolConstraintImpact(&PoolConstraint{PoolExpr: "prod*", Field: "router", Values: []string{"galeb"}}, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(violations, check.DeepEquals, []ConstraintViolation{
+		{Kind: ViolationKindApp, Name: "a2", Pool: "prod2", Value: "galeb"},
+	})
+}
+
+func (s *S) TestPoolConstraintImpactCluster(c *check.C) {
+	s.addImpactFixtures(c)
+	err := s.storage.ProvisionerClusters().Insert(bson.M{"_id": "c1", "pools": []string{"prod1", "prod2"}})
+	c.Assert(err, check.IsNil)
+	violations, err := PoolConstraintImpact(&PoolConstraint{PoolExpr: "prod2", Field: "cluster", Values: []string{"c2"}}, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(violations, check.DeepEquals, []ConstraintViolation{
+		{Kind: ViolationKindCluster, Name: "c1", Pool: "prod2", Value: "c1"},
+	})
+}
+
+func (s *S) TestSetPoolConstraintEnforced(c *check.C) {
+	s.addImpactFixtures(c)
+	err := SetPoolConstraintEnforced(&PoolConstraint{PoolExpr: "prod*", Field: "router", Values: []string{"galeb"}}, false)
+	c.Assert(err, check.FitsTypeOf, &ConstraintViolationsError{})
+	c.Assert(err, check.ErrorMatches, `constraint change would make 1 objects non-compliant: app "a1" in pool "prod1" \(planb\)`)
+	constraints, err := getConstraintsForPool("prod1", "router")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 0)
+	err = SetPoolConstraintEnforced(&PoolConstraint{PoolExpr: "prod*", Field: "router", Values: []string{"galeb", "planb"}}, false)
+	c.Assert(err, check.IsNil)
+	constraints, err = getConstraintsForPool("prod1", "router")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints["router"].Values, check.DeepEquals, []string{"galeb", "planb"})
+}
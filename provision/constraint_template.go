@@ -0,0 +1,157 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+)
+
+var (
+	ErrConstraintTemplateNameRequired  = errors.New("constraint template name is required")
+	ErrConstraintTemplateNotFound      = errors.New("constraint template not found")
+	ErrConstraintTemplateAlreadyExists = errors.New("constraint template already exists")
+	ErrInvalidConstraintTemplate       = errors.New("invalid constraint template: it must have at least one constraint, with values, per field")
+)
+
+// ConstraintTemplate is a named set of constraints applied to pools when
+// they're created with the template. Changing or removing a template doesn't
+// affect the constraints of existing pools.
+type ConstraintTemplate struct {
+	Name        string `bson:"_id"`
+	Constraints []TemplateConstraint
+}
+
+// TemplateConstraint is a constraint of a template, applied to the field of
+// each pool created with the template.
+type TemplateConstraint struct {
+	Field     string
+	Values    []string
+	Blacklist bool
+}
+
+func constraintTemplatesCollection(conn *db.Storage) *storage.Collection {
+	return conn.Collection("constraint_templates")
+}
+
+func (t *ConstraintTemplate) validate() error {
+	if t.Name == "" {
+		return ErrConstraintTemplateNameRequired
+	}
+	if len(t.Constraints) == 0 {
+		return ErrInvalidConstraintTemplate
+	}
+	fields := map[string]bool{}
+	for _, c := range t.Constraints {
+		err := validateConstraintType(c.Field)
+		if err != nil {
+			return err
+		}
+		if len(c.Values) == 0 || fields[c.Field] {
+			return ErrInvalidConstraintTemplate
+		}
+		fields[c.Field] = true
+	}
+	return nil
+}
+
+// AddConstraintTemplate registers a new constraint template.
+func AddConstraintTemplate(t ConstraintTemplate) error {
+	err := t.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = constraintTemplatesCollection(conn).Insert(t)
+	if mgo.IsDup(err) {
+		return ErrConstraintTemplateAlreadyExists
+	}
+	return err
+}
+
+// GetConstraintTemplate returns the constraint template with the given name.
+func GetConstraintTemplate(name string) (*ConstraintTemplate, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var t ConstraintTemplate
+	err = constraintTemplatesCollection(conn).FindId(name).One(&t)
+	if err == mgo.ErrNotFound {
+		return nil, ErrConstraintTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListConstraintTemplates returns all constraint templates sorted by name.
+func ListConstraintTemplates() ([]ConstraintTemplate, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var templates []ConstraintTemplate
+	err = constraintTemplatesCollection(conn).Find(nil).Sort("_id").All(&templates)
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// RemoveConstraintTemplate removes the constraint template. Pools created
+// with it keep their constraints.
+func RemoveConstraintTemplate(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = constraintTemplatesCollection(conn).RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrConstraintTemplateNotFound
+	}
+	return err
+}
+
+func getConstraintTemplates(names []string) ([]ConstraintTemplate, error) {
+	templates := make([]ConstraintTemplate, len(names))
+	for i, name := range names {
+		t, err := GetConstraintTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+		templates[i] = *t
+	}
+	return templates, nil
+}
+
+// applyConstraintTemplates sets the constraints of the templates in the pool.
+// When many templates constrain the same field, the last one wins.
+func applyConstraintTemplates(pool string, templates []ConstraintTemplate) error {
+	for _, t := range templates {
+		for _, c := range t.Constraints {
+			err := SetPoolConstraint(&PoolConstraint{
+				PoolExpr:  pool,
+				Field:     c.Field,
+				Values:    c.Values,
+				Blacklist: c.Blacklist,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "unable to apply constraint template %q", t.Name)
+			}
+		}
+	}
+	return nil
+}
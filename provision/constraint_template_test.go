@@ -0,0 +1,105 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import "gopkg.in/check.v1"
+
+func (s *S) TestAddConstraintTemplate(c *check.C) {
+	tpl := ConstraintTemplate{Name: "standard", Constraints: []TemplateConstraint{
+		{Field: "router", Values: []string{"planb"}},
+		{Field: "team", Values: []string{"ops"}, Blacklist: true},
+	}}
+	err := AddConstraintTemplate(tpl)
+	c.Assert(err, check.IsNil)
+	t, err := GetConstraintTemplate("standard")
+	c.Assert(err, check.IsNil)
+	c.Assert(*t, check.DeepEquals, tpl)
+	err = AddConstraintTemplate(tpl)
+	c.Assert(err, check.Equals, ErrConstraintTemplateAlreadyExists)
+	_, err = GetConstraintTemplate("other")
+	c.Assert(err, check.Equals, ErrConstraintTemplateNotFound)
+}
+
+func (s *S) TestAddConstraintTemplateInvalid(c *check.C) {
+	tt := []struct {
+		tpl ConstraintTemplate
+		err error
+	}{
+		{ConstraintTemplate{Constraints: []TemplateConstraint{{Field: "router", Values: []string{"planb"}}}}, ErrConstraintTemplateNameRequired},
+		{ConstraintTemplate{Name: "standard"}, ErrInvalidConstraintTemplate},
+		{ConstraintTemplate{Name: "standard", Constraints: []TemplateConstraint{{Field: "router"}}}, ErrInvalidConstraintTemplate},
+		{ConstraintTemplate{Name: "standard", Constraints: []TemplateConstraint{{Field: "invalid", Values: []string{"x"}}}}, ErrInvalidConstraintType},
+		{ConstraintTemplate{Name: "standard", Constraints: []TemplateConstraint{
+			{Field: "router", Values: []string{"planb"}},
+			{Field: "router", Values: []string{"galeb"}},
+		}}, ErrInvalidConstraintTemplate},
+	}
+	for _, t := range tt {
+		c.Check(AddConstraintTemplate(t.tpl), check.Equals, t.err)
+	}
+	templates, err := ListConstraintTemplates()
+	c.Assert(err, check.IsNil)
+	c.Assert(templates, check.HasLen, 0)
+}
+
+func (s *S) TestListAndRemoveConstraintTemplates(c *check.C) {
+	err := AddConstraintTemplate(ConstraintTemplate{Name: "web", Constraints: []TemplateConstraint{{Field: "router", Values: []string{"planb"}}}})
+	c.Assert(err, check.IsNil)
+	err = AddConstraintTemplate(ConstraintTemplate{Name: "batch", Constraints: []TemplateConstraint{{Field: "service", Values: []string{"mysql"}}}})
+	c.Assert(err, check.IsNil)
+	templates, err := ListConstraintTemplates()
+	c.Assert(err, check.IsNil)
+	c.Assert(templates, check.HasLen, 2)
+	c.Assert(templates[0].Name, check.Equals, "batch")
+	c.Assert(templates[1].Name, check.Equals, "web")
+	err = RemoveConstraintTemplate("web")
+	c.Assert(err, check.IsNil)
+	err = RemoveConstraintTemplate("web")
+	c.Assert(err, check.Equals, ErrConstraintTemplateNotFound)
+	templates, err = ListConstraintTemplates()
+	c.Assert(err, check.IsNil)
+	c.Assert(templates, check.HasLen, 1)
+}
+
+func (s *S) TestAddPoolWithConstraintTemplates(c *check.C) {
+	err := AddConstraintTemplate(ConstraintTemplate{Name: "web", Constraints: []TemplateConstraint{
+		{Field: "router", Values: []string{"planb"}},
+		{Field: "team", Values: []string{"ops"}},
+	}})
+	c.Assert(err, check.IsNil)
+	err = AddConstraintTemplate(ConstraintTemplate{Name: "restricted", Constraints: []TemplateConstraint{
+		{Field: "router", Values: []string{"galeb"}, Blacklist: true},
+		{Field: "service", Values: []string{"mysql"}},
+	}})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool1", ConstraintTemplates: []string{"web", "restricted"}})
+	c.Assert(err, check.IsNil)
+	constraints, err := getConstraintsForPool("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.DeepEquals, map[string]*PoolConstraint{
+		"router":  {PoolExpr: "pool1", Field: "router", Values: []string{"galeb"}, Blacklist: true},
+		"team":    {PoolExpr: "pool1", Field: "team", Values: []string{"ops"}},
+		"service": {PoolExpr: "pool1", Field: "service", Values: []string{"mysql"}},
+	})
+}
+
+func (s *S) TestAddPoolWithConstraintTemplatesPublic(c *check.C) {
+	err := AddConstraintTemplate(ConstraintTemplate{Name: "web", Constraints: []TemplateConstraint{
+		{Field: "team", Values: []string{"ops"}},
+	}})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool1", Public: true, ConstraintTemplates: []string{"web"}})
+	c.Assert(err, check.IsNil)
+	constraints, err := getConstraintsForPool("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints["team"].Values, check.DeepEquals, []string{"*"})
+}
+
+func (s *S) TestAddPoolWithConstraintTemplateNotFound(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", ConstraintTemplates: []string{"web"}})
+	c.Assert(err, check.Equals, ErrConstraintTemplateNotFound)
+	_, err = GetPoolByName("pool1")
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+}
@@ -0,0 +1,23 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+// DeployToggles holds per-app switches changing how deploys of the app are
+// executed. The zero value keeps the default deploy behavior.
+type DeployToggles struct {
+	// DisableAutoRollback prevents the app from being observed, and
+	// automatically rolled back, after new deploys, even when auto
+	// rollback is configured.
+	DisableAutoRollback bool `json:"disableAutoRollback"`
+	// VerboseBuild asks the deploy agent to output detailed build logs.
+	VerboseBuild bool `json:"verboseBuild"`
+	// SkipHealthcheck rolls out new units without waiting for the
+	// healthcheck of the app to pass.
+	SkipHealthcheck bool `json:"skipHealthcheck"`
+}
+
+func (t DeployToggles) IsEmpty() bool {
+	return !t.DisableAutoRollback && !t.VerboseBuild && !t.SkipHealthcheck
+}
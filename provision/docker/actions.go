@@ -43,6 +43,8 @@ type runContainerActionsArgs struct {
 type containersToAdd struct {
 	Quantity int
 	Status   provision.Status
+	// Hosts, when set, has the host where each unit must be started.
+	Hosts []string
 }
 
 type changeUnitsPipelineArgs struct {
@@ -118,15 +120,7 @@ func runInContainers(containers []container.Container, callback callbackFunc, ro
 }
 
 func checkCanceled(evt *event.Event) error {
-	if evt == nil {
-		return nil
-	}
-	canceled, err := evt.AckCancel()
-	if err != nil {
-		log.Errorf("unable to check if event should be canceled, ignoring: %s", err)
-		return nil
-	}
-	if canceled {
+	if evt.Checkpoint("containers") == event.ErrCanceled {
 		return ErrDeployCanceled
 	}
 	return nil
@@ -376,7 +370,7 @@ var bindAndHealthcheck = action.Action{
 		if writer == nil {
 			writer = ioutil.Discard
 		}
-		doHealthcheck := true
+		doHealthcheck := !args.app.GetDeployToggles().SkipHealthcheck
 		for _, c := range args.toRemove {
 			if c.Status == provision.StatusError.String() || c.Status == provision.StatusStopped.String() {
 				doHealthcheck = false
@@ -392,7 +386,7 @@ var bindAndHealthcheck = action.Action{
 			}
 			toRollback <- c
 			if doHealthcheck && c.ProcessName == webProcessName {
-				err = runHealthcheck(c, writer)
+				err = runHealthcheck(args.provisioner, c, writer)
 				if err != nil {
 					return err
 				}
@@ -782,6 +776,11 @@ var followLogsAndCommit = action.Action{
 				return nil, errors.Errorf("Exit status %d", result.status)
 			}
 		}
+		// Commit also pushes the image, a canceled deploy must not leave
+		// it in the registry.
+		if err := checkCanceled(args.event); err != nil {
+			return nil, err
+		}
 		fmt.Fprintf(args.writer, "\n---- Building application image ----\n")
 		imageId, err := c.Commit(args.provisioner, args.writer)
 		if err != nil {
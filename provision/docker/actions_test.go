@@ -1110,6 +1110,54 @@ func (s *S) TestBindAndHealthcheckDontHealtcheckForErroredApps(c *check.C) {
 	c.Assert(fakeApp.HasBind(&u2), check.Equals, true)
 }
 
+func (s *S) TestBindAndHealthcheckSkipHealthcheckToggle(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	dbApp := &app.App{Name: "myapp"}
+	err := s.storage.Apps().Insert(dbApp)
+	c.Assert(err, check.IsNil)
+	imageName := "tsuru/app-" + dbApp.Name
+	customData := map[string]interface{}{
+		"healthcheck": map[string]interface{}{
+			"path":   "/x/y",
+			"status": http.StatusOK,
+		},
+		"processes": map[string]interface{}{
+			"web": "python myapp.py",
+		},
+	}
+	err = s.newFakeImage(s.p, imageName, customData)
+	c.Assert(err, check.IsNil)
+	fakeApp := provisiontest.NewFakeApp(dbApp.Name, "python", 0)
+	fakeApp.Toggles.SkipHealthcheck = true
+	s.p.Provision(fakeApp)
+	defer s.p.Destroy(fakeApp)
+	buf := safe.NewBuffer(nil)
+	args := changeUnitsPipelineArgs{
+		app:         fakeApp,
+		provisioner: s.p,
+		writer:      buf,
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 2}},
+		imageId:     "tsuru/app-" + dbApp.Name,
+	}
+	containers, err := addContainersWithHost(&args)
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+	url, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(url.Host)
+	containers[0].HostAddr = host
+	containers[0].HostPort = port
+	containers[1].HostAddr = host
+	containers[1].HostPort = port
+	context := action.FWContext{Params: []interface{}{args}, Previous: containers}
+	result, err := bindAndHealthcheck.Forward(context)
+	c.Assert(err, check.IsNil)
+	resultContainers := result.([]container.Container)
+	c.Assert(resultContainers, check.DeepEquals, containers)
+}
+
 func (s *S) TestBindAndHealthcheckDontHealtcheckForStoppedApps(c *check.C) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -33,7 +33,7 @@ func (p *dockerProvisioner) buildImage(app provision.App, archiveFile io.ReadClo
 		AppName:       app.GetName(),
 		ActionLimiter: p.ActionLimiter(),
 	}
-	addr, cont, err := cluster.CreateContainerSchedulerOpts(options, schedOpts, net.StreamInactivityTimeout)
+	addr, cont, err := container.CreateInCluster(cluster, options, schedOpts)
 	hostAddr := net.URLToHost(addr)
 	if schedOpts.LimiterDone != nil {
 		schedOpts.LimiterDone()
@@ -71,7 +71,7 @@ func (p *dockerProvisioner) rebuildImage(app provision.App) (string, string, err
 		AppName:       app.GetName(),
 		ActionLimiter: p.ActionLimiter(),
 	}
-	addr, cont, err := cluster.CreateContainerSchedulerOpts(createOptions, schedOpts, net.StreamInactivityTimeout)
+	addr, cont, err := container.CreateInCluster(cluster, createOptions, schedOpts)
 	if schedOpts.LimiterDone != nil {
 		schedOpts.LimiterDone()
 	}
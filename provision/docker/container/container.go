@@ -10,6 +10,7 @@ import (
 	"io"
 	"math/rand"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,6 +24,7 @@ import (
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/types"
 	"github.com/tsuru/tsuru/provision/dockercommon"
+	"github.com/tsuru/tsuru/registryauth"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -43,6 +45,9 @@ type SchedulerOpts struct {
 	ProcessName   string
 	ActionLimiter provision.ActionLimiter
 	LimiterDone   func()
+	// PrepareNode, when set, is called by the scheduler with the address of
+	// the chosen node, before the container is created in it.
+	PrepareNode func(address string) error
 }
 
 type SchedulerError struct {
@@ -105,6 +110,7 @@ func (c *Container) Create(args *CreateArgs) error {
 	if err != nil {
 		return err
 	}
+	c.StopTimeout = int(args.App.GetProcessSettings().GracePeriod(c.ProcessName) / time.Second)
 	labelSet, err := provision.ProcessLabels(provision.ProcessLabelsOpts{
 		App:         args.App,
 		Process:     c.ProcessName,
@@ -144,7 +150,7 @@ func (c *Container) Create(args *CreateArgs) error {
 		ProcessName:   args.ProcessName,
 		ActionLimiter: args.Provisioner.ActionLimiter(),
 	}
-	addr, cont, err := args.Provisioner.Cluster().CreateContainerSchedulerOpts(opts, schedulerOpts, net.StreamInactivityTimeout, nodeList...)
+	addr, cont, err := CreateInCluster(args.Provisioner.Cluster(), opts, schedulerOpts, nodeList...)
 	hostAddr := net.URLToHost(addr)
 	if schedulerOpts.LimiterDone != nil {
 		schedulerOpts.LimiterDone()
@@ -158,6 +164,86 @@ func (c *Container) Create(args *CreateArgs) error {
 	return nil
 }
 
+// CreateInCluster creates a container in the cluster, like
+// CreateContainerSchedulerOpts, pulling its image with the credentials issued
+// by the registry credentials broker.
+//
+// The cluster pulls images hosted in registries without credentials before
+// creating containers, so images that need credentials are pulled to the
+// chosen node by CreateInCluster and tagged with a name without registry,
+// which the cluster doesn't try to pull again.
+func CreateInCluster(c *cluster.Cluster, opts docker.CreateContainerOptions, schedulerOpts *SchedulerOpts, nodes ...string) (string, *docker.Container, error) {
+	image := opts.Config.Image
+	creds, err := registryauth.PullCredentials(image)
+	if err != nil {
+		return "", nil, err
+	}
+	if creds.Empty() {
+		return c.CreateContainerSchedulerOpts(opts, schedulerOpts, net.StreamInactivityTimeout, nodes...)
+	}
+	prepare := func(address string) error {
+		return pullToNode(c, image, creds, address)
+	}
+	config := *opts.Config
+	config.Image = nodeImageName(image)
+	opts.Config = &config
+	if len(nodes) > 0 {
+		// The scheduler isn't called when the nodes are given, the
+		// cluster picks one of them at random.
+		for _, address := range nodes {
+			err = prepare(address)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+	} else {
+		schedulerOpts.PrepareNode = prepare
+	}
+	return c.CreateContainerSchedulerOpts(opts, schedulerOpts, net.StreamInactivityTimeout, nodes...)
+}
+
+// pullToNode pulls the image to the node using the given credentials, and
+// tags it with the name returned by nodeImageName.
+func pullToNode(c *cluster.Cluster, image string, creds registryauth.Credentials, address string) error {
+	err := c.PullImage(docker.PullImageOptions{
+		Repository:        image,
+		InactivityTimeout: net.StreamInactivityTimeout,
+		RawJSONStream:     true,
+	}, creds.AuthConfiguration(), address)
+	if err != nil {
+		return err
+	}
+	node, err := c.GetNode(address)
+	if err != nil {
+		return err
+	}
+	client, err := node.Client()
+	if err != nil {
+		return err
+	}
+	repository, tag := splitNodeImageName(nodeImageName(image))
+	return client.TagImage(image, docker.TagImageOptions{Repo: repository, Tag: tag, Force: true})
+}
+
+// nodeImageName returns the name of the image in the node after being pulled
+// by pullToNode: the repository without the registry and with slashes
+// replaced by dashes, keeping the tag or digest of the image.
+func nodeImageName(image string) string {
+	_, repository := registryauth.ParseImage(image)
+	tag := "latest"
+	if i := strings.Index(image, "@"); i != -1 {
+		tag = strings.Replace(image[i+1:], ":", "-", -1)
+	} else if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		tag = image[i+1:]
+	}
+	return strings.Replace(repository, "/", "-", -1) + ":" + tag
+}
+
+func splitNodeImageName(name string) (string, string) {
+	i := strings.LastIndex(name, ":")
+	return name[:i], name[i+1:]
+}
+
 func (c *Container) addEnvsToConfig(args *CreateArgs, port string, cfg *docker.Config) {
 	envs := provision.EnvsForApp(args.App, c.ProcessName, args.Deploy)
 	for _, envData := range envs {
@@ -393,12 +479,21 @@ func (c *Container) Commit(p DockerProvisioner, writer io.Writer) (string, error
 	return c.BuildingImage, nil
 }
 
+const defaultStopTimeout = 10
+
+func (c *Container) stopTimeout() uint {
+	if c.StopTimeout > 0 {
+		return uint(c.StopTimeout)
+	}
+	return defaultStopTimeout
+}
+
 func (c *Container) Sleep(p DockerProvisioner) error {
 	if c.Status != provision.StatusStarted.String() && c.Status != provision.StatusStarting.String() {
 		return errors.Errorf("container %s is not starting or started", c.ID)
 	}
 	done := p.ActionLimiter().Start(c.HostAddr)
-	err := p.Cluster().StopContainer(c.ID, 10)
+	err := p.Cluster().StopContainer(c.ID, c.stopTimeout())
 	done()
 	if err != nil {
 		log.Errorf("error on stop container %s: %s", c.ID, err)
@@ -411,7 +506,7 @@ func (c *Container) Stop(p DockerProvisioner) error {
 		return nil
 	}
 	done := p.ActionLimiter().Start(c.HostAddr)
-	err := p.Cluster().StopContainer(c.ID, 10)
+	err := p.Cluster().StopContainer(c.ID, c.stopTimeout())
 	done()
 	if err != nil {
 		log.Errorf("error on stop container %s: %s", c.ID, err)
@@ -438,6 +533,9 @@ func (c *Container) hostConfig(app provision.App, isDeploy bool) (*docker.HostCo
 	if !isDeploy {
 		hostConfig.Memory = app.GetMemory()
 		hostConfig.MemorySwap = app.GetMemory() + app.GetSwap()
+		if storage := app.GetEphemeralStorage(); storage > 0 {
+			hostConfig.StorageOpt = map[string]string{"size": strconv.FormatInt(storage, 10)}
+		}
 		hostConfig.RestartPolicy = docker.AlwaysRestart()
 		hostConfig.PortBindings = map[docker.Port][]docker.PortBinding{
 			docker.Port(c.ExposedPort): {{HostIP: "", HostPort: ""}},
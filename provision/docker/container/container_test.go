@@ -153,6 +153,65 @@ func (s *S) TestContainerCreate(c *check.C) {
 	c.Assert(cont.Status, check.Equals, "created")
 }
 
+func (s *S) TestContainerCreateWithEphemeralStorage(c *check.C) {
+	app := provisiontest.NewFakeApp("app-name", "brainfuck", 1)
+	app.Storage = 8388608
+	routertest.FakeRouter.AddBackend(app.GetName())
+	defer routertest.FakeRouter.RemoveBackend(app.GetName())
+	img := "tsuru/brainfuck:latest"
+	s.p.Cluster().PullImage(docker.PullImageOptions{Repository: img}, docker.AuthConfiguration{})
+	cont := Container{Container: types.Container{
+		Name:        "myName",
+		AppName:     app.GetName(),
+		Type:        app.GetPlatform(),
+		Status:      "created",
+		ProcessName: "web",
+		ExposedPort: "8888/tcp",
+	}}
+	err := cont.Create(&CreateArgs{
+		App:         app,
+		ImageID:     img,
+		Commands:    []string{"docker", "run"},
+		Provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(&cont)
+	dcli, err := docker.NewClient(s.server.URL())
+	c.Assert(err, check.IsNil)
+	container, err := dcli.InspectContainer(cont.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(container.HostConfig.StorageOpt, check.DeepEquals, map[string]string{"size": "8388608"})
+}
+
+func (s *S) TestContainerCreateWithGracePeriod(c *check.C) {
+	app := provisiontest.NewFakeApp("app-name", "brainfuck", 1)
+	app.Processes = provision.ProcessSettings{GracePeriods: map[string]int{"web": 30}}
+	routertest.FakeRouter.AddBackend(app.GetName())
+	defer routertest.FakeRouter.RemoveBackend(app.GetName())
+	img := "tsuru/brainfuck:latest"
+	s.p.Cluster().PullImage(docker.PullImageOptions{Repository: img}, docker.AuthConfiguration{})
+	cont := Container{Container: types.Container{
+		Name:        "myName",
+		AppName:     app.GetName(),
+		Type:        app.GetPlatform(),
+		Status:      "created",
+		ProcessName: "web",
+		ExposedPort: "8888/tcp",
+	}}
+	err := cont.Create(&CreateArgs{
+		App:         app,
+		ImageID:     img,
+		Commands:    []string{"docker", "run"},
+		Provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(&cont)
+	c.Assert(cont.StopTimeout, check.Equals, 30)
+	c.Assert(cont.stopTimeout(), check.Equals, uint(30))
+	cont.StopTimeout = 0
+	c.Assert(cont.stopTimeout(), check.Equals, uint(defaultStopTimeout))
+}
+
 func (s *S) TestContainerCreateCustomLog(c *check.C) {
 	client, err := docker.NewClient(s.server.URL())
 	c.Assert(err, check.IsNil)
@@ -1037,3 +1096,17 @@ func (s *S) TestContainerValidAddr(c *check.C) {
 	c.Assert((&Container{Container: types.Container{HostAddr: "1.1.1.1", HostPort: "0"}}).ValidAddr(), check.Equals, false)
 	c.Assert((&Container{Container: types.Container{HostAddr: "1.1.1.1", HostPort: "123"}}).ValidAddr(), check.Equals, true)
 }
+
+func (s *S) TestNodeImageName(c *check.C) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"registry.example.com/tsuru/app-myapp:v1", "tsuru-app-myapp:v1"},
+		{"registry.example.com:5000/tsuru/app-myapp", "tsuru-app-myapp:latest"},
+		{"registry.example.com/tsuru/app-myapp@sha256:abc", "tsuru-app-myapp:sha256-abc"},
+	}
+	for _, tt := range tests {
+		c.Check(nodeImageName(tt.image), check.Equals, tt.expected, check.Commentf(tt.image))
+	}
+}
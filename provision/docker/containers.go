@@ -322,7 +322,7 @@ func (p *dockerProvisioner) runCommandInContainer(image string, command string,
 		AppName:       app.GetName(),
 		ActionLimiter: p.ActionLimiter(),
 	}
-	addr, cont, err := cluster.CreateContainerSchedulerOpts(createOptions, schedOpts, net.StreamInactivityTimeout)
+	addr, cont, err := container.CreateInCluster(cluster, createOptions, schedOpts)
 	hostAddr := net.URLToHost(addr)
 	if schedOpts.LimiterDone != nil {
 		schedOpts.LimiterDone()
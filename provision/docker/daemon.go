@@ -0,0 +1,168 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/docker-cluster/cluster"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/image"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
+)
+
+// daemonUnitsHook removes the units of daemon apps from nodes being
+// unregistered.
+type daemonUnitsHook struct {
+	p *dockerProvisioner
+}
+
+func (h daemonUnitsHook) RunClusterHook(evt cluster.HookEvent, node *cluster.Node) error {
+	err := h.p.removeDaemonUnits(net.URLToHost(node.Address), nil)
+	if err != nil {
+		log.Errorf("[daemon-units] unable to remove daemon units from node %q: %s", node.Address, err)
+	}
+	return nil
+}
+
+// NodeReady starts the units of daemon apps in nodes added to the cluster,
+// it's called once the node containers are running in the node.
+func (p *dockerProvisioner) NodeReady(node cluster.Node) error {
+	return p.addDaemonUnits(node, nil)
+}
+
+// daemonHosts returns the sorted hosts of the nodes able to run the daemon
+// app a.
+func (p *dockerProvisioner) daemonHosts(a provision.App) ([]string, error) {
+	nodes, err := p.Nodes(a)
+	if err != nil {
+		return nil, err
+	}
+	var hosts []string
+	for _, n := range nodes {
+		if provision.MatchesRequirements(n.Metadata, a.GetNodeRequirements()) {
+			hosts = append(hosts, net.URLToHost(n.Address))
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+// setDaemonUnits pins one unit of each process of daemon apps to every node
+// able to run the app.
+func (p *dockerProvisioner) setDaemonUnits(a provision.App, toAdd map[string]*containersToAdd) error {
+	if !a.IsDaemon() {
+		return nil
+	}
+	hosts, err := p.daemonHosts(a)
+	if err != nil {
+		return err
+	}
+	for _, ct := range toAdd {
+		if ct.Quantity > 0 {
+			ct.Quantity = len(hosts)
+			ct.Hosts = hosts
+		}
+	}
+	return nil
+}
+
+// addDaemonUnits starts the missing units of the deployed daemon apps of the
+// node pool in the node.
+func (p *dockerProvisioner) addDaemonUnits(node cluster.Node, w io.Writer) error {
+	pool := node.Metadata[provision.PoolMetadataName]
+	if pool == "" {
+		return nil
+	}
+	apps, err := app.List(&app.Filter{Pool: pool})
+	if err != nil {
+		return err
+	}
+	host := net.URLToHost(node.Address)
+	multiErr := tsuruErrors.NewMultiError()
+	for i := range apps {
+		a := &apps[i]
+		if !a.IsDaemon() || a.GetDeploys() == 0 || !provision.MatchesRequirements(node.Metadata, a.GetNodeRequirements()) {
+			continue
+		}
+		err = p.addDaemonUnitsToHost(a, host, w)
+		if err != nil {
+			multiErr.Add(errors.Wrapf(err, "unable to add units of daemon app %q", a.Name))
+		}
+	}
+	if multiErr.Len() > 0 {
+		return multiErr
+	}
+	return nil
+}
+
+func (p *dockerProvisioner) addDaemonUnitsToHost(a provision.App, host string, w io.Writer) error {
+	imageId, err := image.AppCurrentImageName(a.GetName())
+	if err != nil {
+		return err
+	}
+	imageData, err := image.GetImageCustomData(imageId)
+	if err != nil {
+		return err
+	}
+	containers, err := p.listContainersByAppAndHost([]string{a.GetName()}, []string{host})
+	if err != nil {
+		return err
+	}
+	running := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		running[c.ProcessName] = true
+	}
+	toAdd := make(map[string]*containersToAdd)
+	for processName := range imageData.Processes {
+		if !running[processName] {
+			toAdd[processName] = &containersToAdd{Quantity: 1, Hosts: []string{host}}
+		}
+	}
+	if len(toAdd) == 0 {
+		return nil
+	}
+	_, err = p.runCreateUnitsPipeline(w, a, toAdd, imageId, imageData.ExposedPort)
+	return err
+}
+
+// removeDaemonUnits removes the units of daemon apps running in host.
+func (p *dockerProvisioner) removeDaemonUnits(host string, w io.Writer) error {
+	containers, err := p.listContainersByHost(host)
+	if err != nil {
+		return err
+	}
+	appUnits := make(map[string][]string)
+	for _, c := range containers {
+		appUnits[c.AppName] = append(appUnits[c.AppName], c.ID)
+	}
+	appNames := make([]string, 0, len(appUnits))
+	for appName := range appUnits {
+		appNames = append(appNames, appName)
+	}
+	sort.Strings(appNames)
+	for _, appName := range appNames {
+		a, err := app.GetByName(appName)
+		if err == app.ErrAppNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !a.IsDaemon() {
+			continue
+		}
+		err = p.RemoveUnitsByID(a, appUnits[appName], w)
+		if err != nil {
+			return errors.Wrapf(err, "unable to remove units of daemon app %q", appName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"github.com/tsuru/docker-cluster/cluster"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetDaemonUnits(c *check.C) {
+	err := s.p.Cluster().Register(cluster.Node{
+		Address:  "http://127.0.0.1:2375",
+		Metadata: map[string]string{"pool": "test-default", "disk": "ssd"},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.p.Cluster().Register(cluster.Node{
+		Address:  "http://localhost:2375",
+		Metadata: map[string]string{"pool": "other"},
+	})
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	toAdd := map[string]*containersToAdd{"web": {Quantity: 3}, "worker": {Quantity: 1}}
+	err = s.p.setDaemonUnits(a, toAdd)
+	c.Assert(err, check.IsNil)
+	c.Assert(toAdd["web"].Quantity, check.Equals, 3)
+	c.Assert(toAdd["web"].Hosts, check.IsNil)
+	a.Daemon = true
+	err = s.p.setDaemonUnits(a, toAdd)
+	c.Assert(err, check.IsNil)
+	c.Assert(toAdd["web"].Quantity, check.Equals, 2)
+	c.Assert(toAdd["web"].Hosts, check.DeepEquals, []string{"127.0.0.1", "localhost"})
+	c.Assert(toAdd["worker"].Quantity, check.Equals, 2)
+	c.Assert(toAdd["worker"].Hosts, check.DeepEquals, []string{"127.0.0.1", "localhost"})
+	a.Requirements = map[string]string{"disk": "ssd"}
+	err = s.p.setDaemonUnits(a, toAdd)
+	c.Assert(err, check.IsNil)
+	c.Assert(toAdd["web"].Quantity, check.Equals, 1)
+	c.Assert(toAdd["web"].Hosts, check.DeepEquals, []string{"127.0.0.1"})
+	c.Assert(toAdd["worker"].Quantity, check.Equals, 1)
+}
+
+func (s *S) TestAddContainersWithHostPinnedHosts(c *check.C) {
+	p, err := s.startMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(p, "tsuru/app-myapp", nil)
+	c.Assert(err, check.IsNil)
+	appInstance := provisiontest.NewFakeApp("myapp", "python", 0)
+	p.Provision(appInstance)
+	imageId, err := image.AppCurrentImageName(appInstance.GetName())
+	c.Assert(err, check.IsNil)
+	containers, err := addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 4, Hosts: []string{"localhost", "localhost", "localhost", "localhost"}}},
+		app:         appInstance,
+		imageId:     imageId,
+		provisioner: p,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 4)
+	for _, cont := range containers {
+		c.Check(cont.HostAddr, check.Equals, "localhost")
+	}
+}
+
+func (s *S) TestNodeReadyAddsDaemonUnits(c *check.C) {
+	p, err := s.startMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(p, "tsuru/app-myapp", nil)
+	c.Assert(err, check.IsNil)
+	appInstance := provisiontest.NewFakeApp("myapp", "python", 0)
+	p.Provision(appInstance)
+	imageId, err := image.AppCurrentImageName(appInstance.GetName())
+	c.Assert(err, check.IsNil)
+	_, err = addContainersWithHost(&changeUnitsPipelineArgs{
+		toHost:      "127.0.0.1",
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 1}},
+		app:         appInstance,
+		imageId:     imageId,
+		provisioner: p,
+	})
+	c.Assert(err, check.IsNil)
+	appStruct := s.newAppFromFake(appInstance)
+	appStruct.Pool = "test-default"
+	appStruct.Daemon = true
+	appStruct.Deploys = 1
+	err = s.storage.Apps().Insert(appStruct)
+	c.Assert(err, check.IsNil)
+	nodes, err := p.Cluster().Nodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 2)
+	for _, node := range nodes {
+		err = p.NodeReady(node)
+		c.Assert(err, check.IsNil)
+	}
+	for _, host := range []string{"127.0.0.1", "localhost"} {
+		containers, err := p.listContainersByHost(host)
+		c.Assert(err, check.IsNil)
+		c.Assert(containers, check.HasLen, 1, check.Commentf("host %s", host))
+		c.Assert(containers[0].ProcessName, check.Equals, "web")
+	}
+}
+
+func (s *S) TestRemoveNodeRebalanceRemovesDaemonUnits(c *check.C) {
+	p, err := s.startMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	mainDockerProvisioner = p
+	err = s.newFakeImage(p, "tsuru/app-myapp", nil)
+	c.Assert(err, check.IsNil)
+	appInstance := provisiontest.NewFakeApp("myapp", "python", 0)
+	p.Provision(appInstance)
+	imageId, err := image.AppCurrentImageName(appInstance.GetName())
+	c.Assert(err, check.IsNil)
+	_, err = addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 2, Hosts: []string{"127.0.0.1", "localhost"}}},
+		app:         appInstance,
+		imageId:     imageId,
+		provisioner: p,
+	})
+	c.Assert(err, check.IsNil)
+	appStruct := s.newAppFromFake(appInstance)
+	appStruct.Daemon = true
+	err = s.storage.Apps().Insert(appStruct)
+	c.Assert(err, check.IsNil)
+	nodes, err := p.Cluster().Nodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(net.URLToHost(nodes[0].Address), check.Equals, "127.0.0.1")
+	err = p.RemoveNode(provision.RemoveNodeOptions{
+		Address:   nodes[0].Address,
+		Rebalance: true,
+		Writer:    safe.NewBuffer(nil),
+	})
+	c.Assert(err, check.IsNil)
+	containers, err := p.listContainersByHost("127.0.0.1")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 0)
+	containers, err = p.listContainersByHost("localhost")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 1)
+}
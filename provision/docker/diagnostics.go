@@ -0,0 +1,88 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"github.com/docker/go-units"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	clusterStorage "github.com/tsuru/docker-cluster/storage"
+	"github.com/tsuru/tsuru/provision"
+)
+
+const defaultDiskUsageThreshold = 90
+
+// DiagnoseNode checks whether the docker daemon of the node is responding,
+// the disk usage reported by the storage driver, when available, and whether
+// the daemon is able to reach the registry.
+func (p *dockerProvisioner) DiagnoseNode(address string) ([]provision.NodeCheckResult, error) {
+	node, err := p.Cluster().GetNode(address)
+	if err != nil {
+		if err == clusterStorage.ErrNoSuchNode {
+			return nil, provision.ErrNodeNotFound
+		}
+		return nil, err
+	}
+	client, err := node.Client()
+	if err != nil {
+		return nil, err
+	}
+	var info *docker.DockerInfo
+	results := []provision.NodeCheckResult{
+		provision.RunNodeCheck("docker", func() error {
+			var infoErr error
+			info, infoErr = client.Info()
+			return infoErr
+		}),
+	}
+	if info == nil {
+		return results, nil
+	}
+	if used, total, ok := driverSpace(info); ok {
+		results = append(results, provision.RunNodeCheck("disk", func() error {
+			return checkDiskUsage(used, total)
+		}))
+	}
+	if registry, _ := config.GetString("docker:registry"); registry != "" {
+		results = append(results, provision.RunNodeCheck("registry", func() error {
+			authConfig := p.RegistryAuthConfig()
+			_, authErr := client.AuthCheck(&authConfig)
+			return authErr
+		}))
+	}
+	return results, nil
+}
+
+// driverSpace returns the data space used and available to the storage
+// driver, which is only reported by some drivers, like devicemapper.
+func driverSpace(info *docker.DockerInfo) (int64, int64, bool) {
+	var used, total int64
+	for _, status := range info.DriverStatus {
+		var err error
+		switch status[0] {
+		case "Data Space Used":
+			used, err = units.FromHumanSize(status[1])
+		case "Data Space Total":
+			total, err = units.FromHumanSize(status[1])
+		}
+		if err != nil {
+			return 0, 0, false
+		}
+	}
+	return used, total, total > 0
+}
+
+func checkDiskUsage(used, total int64) error {
+	threshold, err := config.GetInt("docker:diagnostics:disk-usage-threshold")
+	if err != nil || threshold <= 0 {
+		threshold = defaultDiskUsageThreshold
+	}
+	usage := used * 100 / total
+	if usage >= int64(threshold) {
+		return errors.Errorf("%d%% of the data space in use, over the threshold of %d%%", usage, threshold)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDiagnoseNode(c *check.C) {
+	results, err := s.p.DiagnoseNode(s.server.URL())
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 1)
+	c.Assert(results[0].Name, check.Equals, "docker")
+	c.Assert(results[0].Successful, check.Equals, true)
+}
+
+func (s *S) TestDiagnoseNodeDiskUsage(c *check.C) {
+	s.server.CustomHandler("/info", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(docker.DockerInfo{
+			Driver: "devicemapper",
+			DriverStatus: [][2]string{
+				{"Data Space Used", "95 GB"},
+				{"Data Space Total", "100 GB"},
+			},
+		})
+	}))
+	results, err := s.p.DiagnoseNode(s.server.URL())
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 2)
+	c.Assert(results[0].Successful, check.Equals, true)
+	c.Assert(results[1].Name, check.Equals, "disk")
+	c.Assert(results[1].Successful, check.Equals, false)
+	c.Assert(results[1].Err, check.Equals, "95% of the data space in use, over the threshold of 90%")
+	config.Set("docker:diagnostics:disk-usage-threshold", 98)
+	defer config.Unset("docker:diagnostics:disk-usage-threshold")
+	results, err = s.p.DiagnoseNode(s.server.URL())
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 2)
+	c.Assert(results[1].Successful, check.Equals, true)
+}
+
+func (s *S) TestDiagnoseNodeRegistry(c *check.C) {
+	config.Set("docker:registry", "registry.tsuru.io")
+	defer config.Unset("docker:registry")
+	var authConfig docker.AuthConfiguration
+	s.server.CustomHandler("/auth", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&authConfig)
+		json.NewEncoder(w).Encode(docker.AuthStatus{Status: "Login Succeeded"})
+	}))
+	results, err := s.p.DiagnoseNode(s.server.URL())
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 2)
+	c.Assert(results[1].Name, check.Equals, "registry")
+	c.Assert(results[1].Successful, check.Equals, true)
+	c.Assert(authConfig.ServerAddress, check.Equals, "registry.tsuru.io")
+}
+
+func (s *S) TestDiagnoseNodeDockerUnavailable(c *check.C) {
+	s.server.CustomHandler("/info", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	results, err := s.p.DiagnoseNode(s.server.URL())
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 1)
+	c.Assert(results[0].Name, check.Equals, "docker")
+	c.Assert(results[0].Successful, check.Equals, false)
+}
+
+func (s *S) TestDiagnoseNodeNotFound(c *check.C) {
+	_, err := s.p.DiagnoseNode("http://unknown:2375")
+	c.Assert(err, check.Equals, provision.ErrNodeNotFound)
+}
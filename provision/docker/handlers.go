@@ -39,6 +39,8 @@ func init() {
 	api.RegisterHandler("/docker/bs", "GET", api.AuthorizationRequiredHandler(bsConfigGetHandler))
 	api.RegisterHandler("/docker/logs", "GET", api.AuthorizationRequiredHandler(logsConfigGetHandler))
 	api.RegisterHandler("/docker/logs", "POST", api.AuthorizationRequiredHandler(logsConfigSetHandler))
+	api.RegisterHandler("/docker/units/stale", "GET", api.AuthorizationRequiredHandler(staleUnitsHandler))
+	api.RegisterHandler("/docker/units/reconcile", "POST", api.AuthorizationRequiredHandler(reconcileUnitsHandler))
 }
 
 // title: move container
@@ -321,3 +323,77 @@ func tryRestartAppsByFilter(filter *app.Filter, writer io.Writer) error {
 	wg.Wait()
 	return nil
 }
+
+// title: stale units
+// path: /docker/units/stale
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   401: Unauthorized
+func staleUnitsHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	pool := r.URL.Query().Get("pool")
+	var ctxs []permission.PermissionContext
+	if pool != "" {
+		ctxs = append(ctxs, permission.Context(permission.CtxPool, pool))
+	}
+	if !permission.Check(t, permission.PermNodeRead, ctxs...) {
+		return permission.ErrUnauthorized
+	}
+	report, _, err := mainDockerProvisioner.staleUnits(pool)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(report)
+}
+
+// title: reconcile units
+// path: /docker/units/reconcile
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+func reconcileUnitsHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	opts := reconcileUnitsOpts{
+		Pool:         r.FormValue("pool"),
+		OrphanAction: r.FormValue("orphans"),
+	}
+	opts.DryRun, _ = strconv.ParseBool(r.FormValue("dry"))
+	if opts.OrphanAction != "" && opts.OrphanAction != orphanActionAdopt && opts.OrphanAction != orphanActionRemove {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: errInvalidOrphanAction.Error()}
+	}
+	var ctxs []permission.PermissionContext
+	if opts.Pool != "" {
+		ctxs = append(ctxs, permission.Context(permission.CtxPool, opts.Pool))
+	}
+	if !permission.Check(t, permission.PermNodeUpdateReconcile, ctxs...) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      event.Target{Type: event.TargetTypePool, Value: opts.Pool},
+		Kind:        permission.PermNodeUpdateReconcile,
+		Owner:       t,
+		CustomData:  event.FormToCustomData(r.Form),
+		DisableLock: true,
+		Allowed:     event.Allowed(permission.PermPoolReadEvents, ctxs...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	report, err := mainDockerProvisioner.reconcileUnits(opts)
+	if err != nil {
+		return err
+	}
+	evt.SetOtherCustomData(report)
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(report)
+}
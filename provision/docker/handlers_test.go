@@ -362,3 +362,67 @@ func (s *HandlersSuite) TestDockerLogsInfoHandler(c *check.C) {
 		"p1": {DockerLogConfig: types.DockerLogConfig{Driver: "syslog", LogOpts: map[string]string{}}},
 	})
 }
+
+func (s *S) TestStaleUnitsHandler(c *check.C) {
+	s.newGhostContainer(c, "myapp")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/docker/units/stale?pool=test-default", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var report staleUnitsReport
+	err = json.Unmarshal(recorder.Body.Bytes(), &report)
+	c.Assert(err, check.IsNil)
+	c.Assert(report, check.DeepEquals, staleUnitsReport{
+		Orphans: []staleUnit{},
+		Ghosts: []staleUnit{
+			{ID: "ghost-id", Name: "ghost", AppName: "myapp", ProcessName: "web", HostAddr: "127.0.0.1"},
+		},
+	})
+}
+
+func (s *S) TestReconcileUnitsHandler(c *check.C) {
+	s.newGhostContainer(c, "myapp")
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("pool=test-default&orphans=remove")
+	request, err := http.NewRequest("POST", "/docker/units/reconcile", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var report staleUnitsReport
+	err = json.Unmarshal(recorder.Body.Bytes(), &report)
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Ghosts, check.HasLen, 1)
+	c.Assert(report.Ghosts[0].Action, check.Equals, staleUnitRemoved)
+	containers, err := s.p.listContainersByApp("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "test-default"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.update.reconcile",
+		StartCustomData: []map[string]interface{}{
+			{"name": "pool", "value": "test-default"},
+			{"name": "orphans", "value": "remove"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestReconcileUnitsHandlerInvalidOrphanAction(c *check.C) {
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("orphans=kill")
+	request, err := http.NewRequest("POST", "/docker/units/reconcile", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, errInvalidOrphanAction.Error()+"\n")
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"time"
 
+	"github.com/fsouza/go-dockerclient"
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/event"
@@ -72,19 +73,37 @@ func (h *ContainerHealer) healContainer(cont container.Container) (container.Con
 	return createdContainer, err
 }
 
-func (h *ContainerHealer) isAsExpected(cont container.Container) (bool, error) {
+func (h *ContainerHealer) isAsExpected(cont container.Container) (bool, *docker.Container, error) {
 	container, err := h.provisioner.Cluster().InspectContainer(cont.ID)
 	if err != nil {
-		return false, err
+		return false, nil, err
 	}
 	if container.State.Dead || container.State.RemovalInProgress {
-		return false, nil
+		return false, container, nil
 	}
 	isRunning := container.State.Running || container.State.Restarting
 	if cont.ExpectedStatus() == provision.StatusStopped {
-		return !isRunning, nil
+		return !isRunning, container, nil
+	}
+	return isRunning, container, nil
+}
+
+// notifyOOMKilled notifies the app owners when the container being healed was
+// killed by docker for running out of memory.
+func notifyOOMKilled(cont container.Container, dockerCont *docker.Container) {
+	if dockerCont == nil || !dockerCont.State.OOMKilled {
+		return
+	}
+	err := app.NotifyUnitEviction(cont.AppName, provision.UnitEviction{
+		Unit:    cont.ID,
+		Node:    cont.HostAddr,
+		Reason:  provision.EvictionReasonOOMKilled,
+		Message: dockerCont.State.Error,
+		Time:    dockerCont.State.FinishedAt,
+	})
+	if err != nil {
+		log.Errorf("Containers healing: couldn't notify container %q was killed: %s", cont.ID, err)
 	}
-	return isRunning, nil
 }
 
 func (h *ContainerHealer) healContainerIfNeeded(cont container.Container) error {
@@ -93,7 +112,7 @@ func (h *ContainerHealer) healContainerIfNeeded(cont container.Container) error
 			return nil
 		}
 	}
-	isAsExpected, err := h.isAsExpected(cont)
+	isAsExpected, dockerCont, err := h.isAsExpected(cont)
 	if err != nil {
 		log.Errorf("Containers healing: couldn't verify running processes in container %q: %s", cont.ID, err)
 	}
@@ -119,6 +138,7 @@ func (h *ContainerHealer) healContainerIfNeeded(cont container.Container) error
 		return errors.Wrapf(err, "Containers healing: unable to heal %q couldn't get app %q", cont.ID, cont.AppName)
 	}
 	log.Errorf("Initiating healing process for container %q, unresponsive since %s.", cont.ID, cont.LastSuccessStatusUpdate)
+	notifyOOMKilled(cont, dockerCont)
 	evt, err := event.NewInternal(&event.Opts{
 		Target:       event.Target{Type: event.TargetTypeContainer, Value: cont.ID},
 		InternalKind: "healer",
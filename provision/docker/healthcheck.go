@@ -5,11 +5,13 @@
 package docker
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,14 +19,31 @@ import (
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
 )
 
-func runHealthcheck(cont *container.Container, w io.Writer) error {
+func healthcheckMaxWaitTime() time.Duration {
+	maxWaitTime, _ := config.GetInt("docker:healthcheck:max-time")
+	if maxWaitTime == 0 {
+		maxWaitTime = 120
+	}
+	return time.Duration(maxWaitTime) * time.Second
+}
+
+func runHealthcheck(p *dockerProvisioner, cont *container.Container, w io.Writer) error {
 	yamlData, err := image.GetImageTsuruYamlData(cont.Image)
 	if err != nil {
 		return err
 	}
+	err = yamlData.Healthcheck.Validate()
+	if err != nil {
+		return err
+	}
+	port, _ := strconv.Atoi(provision.WebProcessDefaultPort())
+	if cmd := yamlData.Healthcheck.ExecCommand(port); cmd != "" {
+		return runExecHealthcheck(p, cont, cmd, yamlData.Healthcheck.AllowedFailures, w)
+	}
 	path := yamlData.Healthcheck.Path
 	method := yamlData.Healthcheck.Method
 	match := yamlData.Healthcheck.Match
@@ -49,11 +68,7 @@ func runHealthcheck(cont *container.Container, w io.Writer) error {
 			return err
 		}
 	}
-	maxWaitTime, _ := config.GetInt("docker:healthcheck:max-time")
-	if maxWaitTime == 0 {
-		maxWaitTime = 120
-	}
-	maxWaitTime = maxWaitTime * int(time.Second)
+	maxWaitTime := healthcheckMaxWaitTime()
 	sleepTime := 3 * time.Second
 	startedTime := time.Now()
 	url := fmt.Sprintf("http://%s:%s/%s", cont.HostAddr, cont.HostPort, path)
@@ -91,7 +106,33 @@ func runHealthcheck(cont *container.Container, w io.Writer) error {
 			fmt.Fprintf(w, " ---> healthcheck successful(%s)\n", cont.ShortID())
 			return nil
 		}
-		if time.Since(startedTime) > time.Duration(maxWaitTime) {
+		if time.Since(startedTime) > maxWaitTime {
+			return lastError
+		}
+		fmt.Fprintf(w, " ---> %s. Trying again in %s\n", lastError.Error(), sleepTime)
+		time.Sleep(sleepTime)
+	}
+}
+
+// runExecHealthcheck runs the healthcheck command inside the container until
+// it exits successfully, for command and gRPC healthchecks.
+func runExecHealthcheck(p *dockerProvisioner, cont *container.Container, cmd string, allowedFailures int, w io.Writer) error {
+	maxWaitTime := healthcheckMaxWaitTime()
+	sleepTime := 3 * time.Second
+	startedTime := time.Now()
+	for {
+		var output bytes.Buffer
+		err := cont.Exec(p, &output, &output, cmd)
+		if err == nil {
+			fmt.Fprintf(w, " ---> healthcheck successful(%s)\n", cont.ShortID())
+			return nil
+		}
+		lastError := errors.Errorf("healthcheck fail(%s): command %q failed: %s %s", cont.ShortID(), cmd, err, strings.TrimSpace(output.String()))
+		if allowedFailures == 0 {
+			return lastError
+		}
+		allowedFailures--
+		if time.Since(startedTime) > maxWaitTime {
 			return lastError
 		}
 		fmt.Fprintf(w, " ---> %s. Trying again in %s\n", lastError.Error(), sleepTime)
@@ -16,6 +16,7 @@ import (
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
 	"github.com/tsuru/tsuru/provision/docker/types"
 	"gopkg.in/check.v1"
@@ -47,7 +48,7 @@ func (s *S) TestHealthcheck(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{Container: types.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(requests, check.HasLen, 1)
 	c.Assert(requests[0].URL.Path, check.Equals, "/x/y")
@@ -85,11 +86,11 @@ func (s *S) TestHealthcheckWithMatch(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{Container: types.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.ErrorMatches, ".*unexpected result, expected \"(?s).*some.*\", got: invalid")
 	c.Assert(requests, check.HasLen, 1)
 	c.Assert(requests[0].Method, check.Equals, "GET")
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(requests, check.HasLen, 2)
 	c.Assert(requests[1].URL.Path, check.Equals, "/x/y")
@@ -119,7 +120,7 @@ func (s *S) TestHealthcheckDefaultCheck(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{Container: types.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(requests, check.HasLen, 1)
 	c.Assert(requests[0].Method, check.Equals, "GET")
@@ -141,7 +142,7 @@ func (s *S) TestHealthcheckNoHealthcheck(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{Container: types.Container{AppName: a.Name, HostAddr: host, HostPort: port}}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(requests, check.HasLen, 0)
 }
@@ -170,7 +171,7 @@ func (s *S) TestHealthcheckNoPath(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{Container: types.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(requests, check.HasLen, 0)
 }
@@ -209,7 +210,7 @@ func (s *S) TestHealthcheckKeepsTryingWithServerDown(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{Container: types.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(buf.String(), check.Matches, `(?s).*---> healthcheck fail.*?Trying again in 3s.*---> healthcheck successful.*`)
 	c.Assert(requests, check.HasLen, 2)
@@ -240,7 +241,7 @@ func (s *S) TestHealthcheckErrorsAfterMaxTime(c *check.C) {
 	defer config.Unset("docker:healthcheck:max-time")
 	done := make(chan struct{})
 	go func() {
-		err = runHealthcheck(&cont, &buf)
+		err = runHealthcheck(s.p, &cont, &buf)
 		close(done)
 	}()
 	select {
@@ -288,7 +289,7 @@ func (s *S) TestHealthcheckSuccessfulWithAllowedFailures(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{Container: types.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(buf.String(), check.Matches, `(?s).*---> healthcheck fail.*?Trying again in 3s.*---> healthcheck fail.*?Trying again in 3s.*---> healthcheck successful.*`)
 	c.Assert(requests, check.HasLen, 3)
@@ -299,3 +300,41 @@ func (s *S) TestHealthcheckSuccessfulWithAllowedFailures(c *check.C) {
 	c.Assert(requests[2].Method, check.Equals, "GET")
 	c.Assert(requests[2].URL.Path, check.Equals, "/x/y")
 }
+
+func (s *S) TestHealthcheckCommand(c *check.C) {
+	cont, err := s.newContainer(&newContainerOpts{
+		AppName: "myapp1",
+		ImageCustomData: map[string]interface{}{
+			"healthcheck": map[string]interface{}{
+				"command": "/bin/check-health",
+			},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	var executed bool
+	s.server.PrepareExec("*", func() {
+		executed = true
+	})
+	buf := bytes.Buffer{}
+	err = runHealthcheck(s.p, cont, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(executed, check.Equals, true)
+	c.Assert(buf.String(), check.Equals, " ---> healthcheck successful("+cont.ShortID()+")\n")
+}
+
+func (s *S) TestHealthcheckConflictingChecks(c *check.C) {
+	imageName := "tsuru/app"
+	customData := map[string]interface{}{
+		"healthcheck": map[string]interface{}{
+			"path":    "/hc",
+			"command": "/bin/check-health",
+		},
+	}
+	err := image.SaveImageCustomData(imageName, customData)
+	c.Assert(err, check.IsNil)
+	cont := container.Container{Container: types.Container{AppName: "myapp1", Image: imageName}}
+	buf := bytes.Buffer{}
+	err = runHealthcheck(s.p, &cont, &buf)
+	c.Assert(err, check.Equals, provision.ErrHealthcheckConflict)
+}
@@ -6,13 +6,16 @@ package docker
 
 import (
 	"github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
 	"github.com/tsuru/config"
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/docker-cluster/storage"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/jobs"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -64,24 +67,41 @@ func MigrateImages() error {
 	return nil
 }
 
+const removeImageJob = "docker-remove-image"
+
+// cleanImage removes the image from the nodes, the registry and the list of
+// images of the app. Failed removals are retried later in background.
 func (p *dockerProvisioner) cleanImage(appName, imgName string) {
-	shouldRemove := true
-	err := p.Cluster().RemoveImage(imgName)
+	err := p.removeImage(appName, imgName)
+	if err == nil {
+		return
+	}
+	log.Errorf("Ignored error removing old image %q: %s. Removal enqueued to retry later.", imgName, err)
+	_, err = jobs.Enqueue(removeImageJob, removeImageJob+":"+imgName, jobs.Params{
+		"app":   appName,
+		"image": imgName,
+	})
 	if err != nil {
-		shouldRemove = false
-		log.Errorf("Ignored error removing old image %q: %s. Image kept on list to retry later.",
-			imgName, err.Error())
+		log.Errorf("Unable to enqueue removal of old image %q: %s", imgName, err)
+	}
+}
+
+func (p *dockerProvisioner) removeImage(appName, imgName string) error {
+	err := p.Cluster().RemoveImage(imgName)
+	if err != nil && err != storage.ErrNoSuchImage {
+		return errors.Wrap(err, "unable to remove image from nodes")
 	}
 	err = p.Cluster().RemoveFromRegistry(imgName)
 	if err != nil {
-		shouldRemove = false
-		log.Errorf("Ignored error removing old image from registry %q: %s. Image kept on list to retry later.",
-			imgName, err.Error())
+		return errors.Wrap(err, "unable to remove image from registry")
 	}
-	if shouldRemove {
-		err = image.PullAppImageNames(appName, []string{imgName})
-		if err != nil {
-			log.Errorf("Ignored error pulling old images from database: %s", err)
-		}
+	err = image.PullAppImageNames(appName, []string{imgName})
+	if err != nil && err != mgo.ErrNotFound {
+		return err
 	}
+	return nil
+}
+
+func (p *dockerProvisioner) runRemoveImageJob(params jobs.Params) error {
+	return p.removeImage(params["app"], params["image"])
 }
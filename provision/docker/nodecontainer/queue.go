@@ -24,6 +24,12 @@ func RegisterQueueTask(p DockerProvisioner) error {
 	return q.RegisterTask(&runBs{provisioner: p})
 }
 
+// NodeReadyHook is implemented by provisioners that must act on nodes added
+// to the cluster once the node containers are running in them.
+type NodeReadyHook interface {
+	NodeReady(node cluster.Node) error
+}
+
 type runBs struct {
 	provisioner DockerProvisioner
 }
@@ -63,5 +69,12 @@ func (t *runBs) Run(job monsterqueue.Job) {
 		job.Error(err)
 		return
 	}
+	if hook, ok := t.provisioner.(NodeReadyHook); ok {
+		err = hook.NodeReady(node)
+		if err != nil {
+			job.Error(err)
+			return
+		}
+	}
 	job.Success(nil)
 }
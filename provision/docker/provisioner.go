@@ -12,6 +12,7 @@ import (
 	"io/ioutil"
 	"math"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -32,6 +33,7 @@ import (
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	tsuruHealer "github.com/tsuru/tsuru/healer"
+	"github.com/tsuru/tsuru/jobs"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision"
@@ -42,6 +44,7 @@ import (
 	"github.com/tsuru/tsuru/provision/dockercommon"
 	"github.com/tsuru/tsuru/provision/nodecontainer"
 	"github.com/tsuru/tsuru/queue"
+	"github.com/tsuru/tsuru/registryauth"
 	"github.com/tsuru/tsuru/router"
 	_ "github.com/tsuru/tsuru/router/fusis"
 	_ "github.com/tsuru/tsuru/router/galeb"
@@ -49,6 +52,7 @@ import (
 	_ "github.com/tsuru/tsuru/router/routertest"
 	_ "github.com/tsuru/tsuru/router/vulcand"
 	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/mgo.v2/bson"
 )
 
 var (
@@ -106,6 +110,8 @@ var (
 	_ provision.UnitFinderProvisioner    = &dockerProvisioner{}
 	_ provision.AppFilterProvisioner     = &dockerProvisioner{}
 	_ provision.ExtensibleProvisioner    = &dockerProvisioner{}
+	_ provision.PoolCapacityProvisioner  = &dockerProvisioner{}
+	_ provision.ImageKeepingDestroyer    = &dockerProvisioner{}
 )
 
 type hookHealer struct {
@@ -156,6 +162,7 @@ func (p *dockerProvisioner) initDockerCluster() error {
 		return err
 	}
 	p.cluster.AddHook(cluster.HookEventBeforeContainerCreate, &internalNodeContainer.ClusterHook{Provisioner: p})
+	p.cluster.AddHook(cluster.HookEventBeforeNodeUnregister, daemonUnitsHook{p: p})
 	if tsuruHealer.HealerInstance != nil {
 		healer := hookHealer{p: p}
 		p.cluster.Healer = healer
@@ -290,6 +297,7 @@ func (p *dockerProvisioner) Initialize() error {
 	if err != nil {
 		return err
 	}
+	jobs.Register(removeImageJob, p.runRemoveImageJob)
 	return p.initDockerCluster()
 }
 
@@ -423,7 +431,11 @@ func (p *dockerProvisioner) ImageDeploy(app provision.App, imageId string, evt *
 	if err != nil {
 		return "", err
 	}
-	err = cluster.PullImage(pullOpts, docker.AuthConfiguration{}, node)
+	creds, err := registryauth.PullCredentials(imageId)
+	if err != nil {
+		return "", err
+	}
+	err = cluster.PullImage(pullOpts, creds.AuthConfiguration(), node)
 	if err != nil {
 		return "", err
 	}
@@ -504,12 +516,18 @@ func (p *dockerProvisioner) deploy(a provision.App, imageId string, evt *event.E
 			}
 			toAdd[processName].Quantity++
 		}
+		if err = p.setDaemonUnits(a, toAdd); err != nil {
+			return err
+		}
 		if err = setQuota(a, toAdd); err != nil {
 			return err
 		}
 		_, err = p.runCreateUnitsPipeline(evt, a, toAdd, imageId, imageData.ExposedPort)
 	} else {
 		toAdd := getContainersToAdd(imageData, containers)
+		if err = p.setDaemonUnits(a, toAdd); err != nil {
+			return err
+		}
 		if err = setQuota(a, toAdd); err != nil {
 			return err
 		}
@@ -559,24 +577,7 @@ func getContainersToAdd(data image.ImageMetadata, oldContainers []container.Cont
 }
 
 func (p *dockerProvisioner) Destroy(app provision.App) error {
-	containers, err := p.listContainersByApp(app.GetName())
-	if err != nil {
-		log.Errorf("Failed to list app containers: %s", err)
-		return err
-	}
-	args := changeUnitsPipelineArgs{
-		app:         app,
-		toRemove:    containers,
-		writer:      ioutil.Discard,
-		provisioner: p,
-		appDestroy:  true,
-	}
-	pipeline := action.NewPipeline(
-		&removeOldRoutes,
-		&provisionRemoveOldUnits,
-		&provisionUnbindOldUnits,
-	)
-	err = pipeline.Execute(args)
+	err := p.DestroyKeepingImages(app)
 	if err != nil {
 		return err
 	}
@@ -602,6 +603,29 @@ func (p *dockerProvisioner) Destroy(app provision.App) error {
 	return nil
 }
 
+// DestroyKeepingImages removes the containers of the app, keeping its images
+// in the registry for the provisioner the app is moving to.
+func (p *dockerProvisioner) DestroyKeepingImages(app provision.App) error {
+	containers, err := p.listContainersByApp(app.GetName())
+	if err != nil {
+		log.Errorf("Failed to list app containers: %s", err)
+		return err
+	}
+	args := changeUnitsPipelineArgs{
+		app:         app,
+		toRemove:    containers,
+		writer:      ioutil.Discard,
+		provisioner: p,
+		appDestroy:  true,
+	}
+	pipeline := action.NewPipeline(
+		&removeOldRoutes,
+		&provisionRemoveOldUnits,
+		&provisionUnbindOldUnits,
+	)
+	return pipeline.Execute(args)
+}
+
 func (p *dockerProvisioner) runRestartAfterHooks(cont *container.Container, w io.Writer) error {
 	yamlData, err := image.GetImageTsuruYamlData(cont.Image)
 	if err != nil {
@@ -638,16 +662,9 @@ func addContainersWithHost(args *changeUnitsPipelineArgs) ([]container.Container
 		w = ioutil.Discard
 	}
 	fmt.Fprintf(w, "\n---- Starting %d new %s %s ----\n", units, pluralize("unit", units), strings.Join(processMsg, " "))
-	oldContainers := make([]container.Container, 0, units)
-	for processName, cont := range args.toAdd {
-		for i := 0; i < cont.Quantity; i++ {
-			oldContainers = append(oldContainers, container.Container{
-				Container: types.Container{
-					ProcessName: processName,
-					Status:      cont.Status.String(),
-				},
-			})
-		}
+	processes := make([]string, 0, len(args.toAdd))
+	for processName := range args.toAdd {
+		processes = append(processes, processName)
 	}
 	rollbackCallback := func(c *container.Container) {
 		log.Errorf("Removing container %q due failed add units.", c.ID)
@@ -660,20 +677,49 @@ func addContainersWithHost(args *changeUnitsPipelineArgs) ([]container.Container
 		createdContainers []*container.Container
 		m                 sync.Mutex
 	)
-	err := runInContainers(oldContainers, func(c *container.Container, toRollback chan *container.Container) error {
-		c, startErr := args.provisioner.start(c, a, imageId, w, args.exposedPort, destinationHost...)
-		if startErr != nil {
-			return startErr
+	// Processes in the app start order are started one after the other,
+	// each stage only begins after all units of the previous one started.
+	for _, stage := range a.GetProcessSettings().StartStages(processes) {
+		previousStages := createdContainers
+		oldContainers := make([]container.Container, 0, units)
+		for _, processName := range stage {
+			cont := args.toAdd[processName]
+			for i := 0; i < cont.Quantity; i++ {
+				var hostAddr string
+				if i < len(cont.Hosts) {
+					hostAddr = cont.Hosts[i]
+				}
+				oldContainers = append(oldContainers, container.Container{
+					Container: types.Container{
+						ProcessName: processName,
+						Status:      cont.Status.String(),
+						HostAddr:    hostAddr,
+					},
+				})
+			}
+		}
+		err := runInContainers(oldContainers, func(c *container.Container, toRollback chan *container.Container) error {
+			destinationHosts := destinationHost
+			if c.HostAddr != "" {
+				destinationHosts = []string{c.HostAddr}
+			}
+			c, startErr := args.provisioner.start(c, a, imageId, w, args.exposedPort, destinationHosts...)
+			if startErr != nil {
+				return startErr
+			}
+			toRollback <- c
+			m.Lock()
+			createdContainers = append(createdContainers, c)
+			m.Unlock()
+			fmt.Fprintf(w, " ---> Started unit %s [%s]\n", c.ShortID(), c.ProcessName)
+			return nil
+		}, rollbackCallback, true)
+		if err != nil {
+			for _, c := range previousStages {
+				rollbackCallback(c)
+			}
+			return nil, err
 		}
-		toRollback <- c
-		m.Lock()
-		createdContainers = append(createdContainers, c)
-		m.Unlock()
-		fmt.Fprintf(w, " ---> Started unit %s [%s]\n", c.ShortID(), c.ProcessName)
-		return nil
-	}, rollbackCallback, true)
-	if err != nil {
-		return nil, err
 	}
 	result := make([]container.Container, len(createdContainers))
 	i := 0
@@ -772,6 +818,62 @@ func (p *dockerProvisioner) RemoveUnits(a provision.App, units uint, processName
 	return nil
 }
 
+func (p *dockerProvisioner) RemoveUnitsByID(a provision.App, ids []string, w io.Writer) error {
+	if len(ids) == 0 {
+		return errors.New("cannot remove zero units")
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	toRemove := make([]container.Container, 0, len(ids))
+	for _, id := range ids {
+		cont, err := p.GetContainer(id)
+		if err != nil {
+			return err
+		}
+		if cont.AppName != a.GetName() {
+			return &provision.UnitNotFoundError{ID: id}
+		}
+		toRemove = append(toRemove, *cont)
+	}
+	fmt.Fprintf(w, "\n---- Removing %d %s ----\n", len(toRemove), pluralize("unit", len(toRemove)))
+	args := changeUnitsPipelineArgs{
+		app:         a,
+		toRemove:    toRemove,
+		writer:      w,
+		provisioner: p,
+	}
+	pipeline := action.NewPipeline(
+		&removeOldRoutes,
+		&provisionRemoveOldUnits,
+		&provisionUnbindOldUnits,
+	)
+	err := pipeline.Execute(args)
+	if err != nil {
+		return errors.Wrap(err, "error removing routes, units weren't removed")
+	}
+	return nil
+}
+
+// PlanUnits simulates the scheduling of the units in a dry mode copy of the
+// provisioner, so no container is created.
+func (p *dockerProvisioner) PlanUnits(provApp provision.App, n uint, process string) ([]provision.UnitPlacement, error) {
+	a, ok := provApp.(*app.App)
+	if !ok {
+		var err error
+		a, err = app.GetByName(provApp.GetName())
+		if err != nil {
+			return nil, err
+		}
+	}
+	dry, err := p.dryMode(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer dry.stopDryMode()
+	return dry.scheduler.planUnits(a, n, process)
+}
+
 func (p *dockerProvisioner) SetUnitStatus(unit provision.Unit, status provision.Status) error {
 	cont, err := p.GetContainer(unit.ID)
 	if _, ok := err.(*provision.UnitNotFoundError); ok && unit.Name != "" {
@@ -951,6 +1053,80 @@ func (p *dockerProvisioner) Units(app provision.App) ([]provision.Unit, error) {
 	return units, nil
 }
 
+func (p *dockerProvisioner) UnitsDiskUsage(app provision.App) ([]provision.UnitDiskUsage, error) {
+	containers, err := p.listContainersByApp(app.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(containers))
+	for i, c := range containers {
+		ids[i] = c.ID
+	}
+	apiContainers, err := p.Cluster().ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Size:    true,
+		Filters: map[string][]string{"id": ids},
+	})
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int64, len(apiContainers))
+	for _, c := range apiContainers {
+		sizes[c.ID] = c.SizeRw
+	}
+	usage := make([]provision.UnitDiskUsage, len(containers))
+	for i, c := range containers {
+		usage[i] = provision.UnitDiskUsage{ID: c.ID, Usage: sizes[c.ID]}
+	}
+	return usage, nil
+}
+
+// PoolCapacity sums the memory declared by the nodes of the pool, in the
+// metadata configured by docker:scheduler:total-memory-metadata, and the
+// memory of the plans of the containers running on them.
+func (p *dockerProvisioner) PoolCapacity(pool string) (*provision.PoolCapacity, error) {
+	nodes, err := p.Cluster().UnfilteredNodesForMetadata(map[string]string{provision.PoolMetadataName: pool})
+	if err != nil {
+		return nil, err
+	}
+	var capacity provision.PoolCapacity
+	if len(nodes) == 0 {
+		return &capacity, nil
+	}
+	totalMemoryMetadata, _ := config.GetString("docker:scheduler:total-memory-metadata")
+	hosts := make([]string, len(nodes))
+	for i, n := range nodes {
+		hosts[i] = net.URLToHost(n.Address)
+		if totalMemoryMetadata != "" {
+			memory, _ := strconv.ParseInt(n.Metadata[totalMemoryMetadata], 10, 64)
+			capacity.Memory += memory
+		}
+	}
+	containers, err := p.ListContainers(bson.M{"hostaddr": bson.M{"$in": hosts}})
+	if err != nil {
+		return nil, err
+	}
+	appsMemory := make(map[string]int64)
+	for _, c := range containers {
+		memory, ok := appsMemory[c.AppName]
+		if !ok {
+			a, err := app.GetByName(c.AppName)
+			if err != nil && err != app.ErrAppNotFound {
+				return nil, err
+			}
+			if a != nil {
+				memory = a.Plan.Memory
+			}
+			appsMemory[c.AppName] = memory
+		}
+		capacity.ReservedMemory += memory
+	}
+	return &capacity, nil
+}
+
 func (p *dockerProvisioner) RoutableAddresses(app provision.App) ([]url.URL, error) {
 	imageId, err := image.AppCurrentImageName(app.GetName())
 	if err != nil && err != image.ErrNoImagesAvailable {
@@ -991,6 +1167,19 @@ func (p *dockerProvisioner) RegisterUnit(a provision.App, unitId string, customD
 	return p.checkContainer(cont)
 }
 
+// KillUnit kills the container of the unit, which is then restarted by docker
+// or replaced by the container healer.
+func (p *dockerProvisioner) KillUnit(a provision.App, unitID string) error {
+	cont, err := p.GetContainer(unitID)
+	if err != nil {
+		return err
+	}
+	if cont.AppName != a.GetName() {
+		return &provision.UnitNotFoundError{ID: unitID}
+	}
+	return p.Cluster().KillContainer(docker.KillContainerOptions{ID: cont.ID, Signal: docker.SIGKILL})
+}
+
 func (p *dockerProvisioner) Shell(opts provision.ShellOptions) error {
 	var (
 		c   *container.Container
@@ -1243,6 +1432,9 @@ func (p *dockerProvisioner) AddNode(opts provision.AddNodeOptions) error {
 	jobParams := monsterqueue.JobParams{"endpoint": opts.Address, "metadata": opts.Metadata}
 	var job monsterqueue.Job
 	if opts.WaitTO != 0 {
+		if opts.Writer != nil {
+			fmt.Fprintf(opts.Writer, " ---> Waiting up to %s for node containers to start\n", opts.WaitTO)
+		}
 		job, err = q.EnqueueWait(internalNodeContainer.QueueTaskName, jobParams, opts.WaitTO)
 	} else {
 		_, err = q.Enqueue(internalNodeContainer.QueueTaskName, jobParams)
@@ -1293,6 +1485,12 @@ func (p *dockerProvisioner) RemoveNode(opts provision.RemoveNodeOptions) error {
 		return err
 	}
 	if opts.Rebalance {
+		// Daemon units are removed before rebalancing, otherwise they would
+		// be moved to nodes already running them.
+		err = p.removeDaemonUnits(net.URLToHost(opts.Address), opts.Writer)
+		if err != nil {
+			return err
+		}
 		err = p.rebalanceContainersByHost(net.URLToHost(opts.Address), opts.Writer)
 		if err != nil {
 			return err
@@ -1301,6 +1499,24 @@ func (p *dockerProvisioner) RemoveNode(opts provision.RemoveNodeOptions) error {
 	return p.Cluster().Unregister(opts.Address)
 }
 
+// EvacuateNode disables the node, so that the scheduler no longer chooses it,
+// and moves its containers to other nodes.
+func (p *dockerProvisioner) EvacuateNode(address string, w io.Writer) error {
+	node, err := p.Cluster().GetNode(address)
+	if err != nil {
+		if err == clusterStorage.ErrNoSuchNode {
+			return provision.ErrNodeNotFound
+		}
+		return err
+	}
+	node.CreationStatus = cluster.NodeCreationStatusDisabled
+	_, err = p.Cluster().UpdateNode(node)
+	if err != nil {
+		return err
+	}
+	return p.rebalanceContainersByHost(net.URLToHost(address), w)
+}
+
 func (p *dockerProvisioner) UpgradeNodeContainer(name string, pool string, writer io.Writer) error {
 	return internalNodeContainer.RecreateNamedContainers(p, writer, name, pool)
 }
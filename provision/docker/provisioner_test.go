@@ -412,6 +412,41 @@ func (s *S) TestDeployWithLimiterGlobalActive(c *check.C) {
 	c.Assert(p.ActionLimiter().Len(hostAddr), check.Equals, 0)
 }
 
+func (s *S) TestPoolCapacity(c *check.C) {
+	config.Set("docker:scheduler:total-memory-metadata", "totalMemory")
+	defer config.Unset("docker:scheduler:total-memory-metadata")
+	err := s.p.Cluster().Register(cluster.Node{
+		Address:  "http://127.0.0.1:2375",
+		Metadata: map[string]string{"pool": "mypool", "totalMemory": "100000"},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.p.Cluster().Register(cluster.Node{
+		Address:  "http://localhost:2375",
+		Metadata: map[string]string{"pool": "other", "totalMemory": "50000"},
+	})
+	c.Assert(err, check.IsNil)
+	app1 := app.App{Name: "skyrim", Plan: app.Plan{Memory: 20000}, Pool: "mypool"}
+	err = s.storage.Apps().Insert(app1)
+	c.Assert(err, check.IsNil)
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	for i, host := range []string{"127.0.0.1", "127.0.0.1", "localhost"} {
+		cont := container.Container{Container: types.Container{
+			ID:       fmt.Sprintf("cont%d", i),
+			AppName:  app1.Name,
+			HostAddr: host,
+		}}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+	}
+	capacity, err := s.p.PoolCapacity("mypool")
+	c.Assert(err, check.IsNil)
+	c.Assert(capacity, check.DeepEquals, &provision.PoolCapacity{Memory: 100000, ReservedMemory: 40000})
+	capacity, err = s.p.PoolCapacity("empty")
+	c.Assert(err, check.IsNil)
+	c.Assert(capacity, check.DeepEquals, &provision.PoolCapacity{})
+}
+
 func (s *S) TestDeployQuotaExceeded(c *check.C) {
 	stopCh := s.stopContainers(s.server.URL(), 1)
 	defer func() { <-stopCh }()
@@ -1416,6 +1451,27 @@ func (s *S) TestProvisionerAddUnits(c *check.C) {
 	c.Assert(count, check.Equals, 4)
 }
 
+func (s *S) TestProvisionerPlanUnits(c *check.C) {
+	p, err := s.startMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	a := &app.App{Name: "myapp", Pool: "test-default"}
+	placement, err := p.PlanUnits(a, 4, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(placement, check.HasLen, 4)
+	perNode := map[string]int{}
+	for _, u := range placement {
+		c.Assert(u.Process, check.Equals, "web")
+		perNode[u.Node]++
+	}
+	c.Assert(perNode, check.HasLen, 2)
+	for _, n := range perNode {
+		c.Assert(n, check.Equals, 2)
+	}
+	containers, err := p.listAllContainers()
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 0)
+}
+
 func (s *S) TestProvisionerAddUnitsInvalidProcess(c *check.C) {
 	err := s.newFakeImage(s.p, "tsuru/app-myapp", nil)
 	c.Assert(err, check.IsNil)
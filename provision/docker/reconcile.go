@@ -0,0 +1,259 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	"github.com/tsuru/docker-cluster/cluster"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/provision/docker/types"
+	"github.com/tsuru/tsuru/router/rebuild"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	orphanActionAdopt  = "adopt"
+	orphanActionRemove = "remove"
+
+	staleUnitAdopted = "adopted"
+	staleUnitRemoved = "removed"
+)
+
+// staleUnitGracePeriod is the minimum age of a container running in a node
+// without a record in the database to be considered an orphan, younger
+// containers may belong to a pipeline that hasn't stored them yet.
+var staleUnitGracePeriod = 5 * time.Minute
+
+var errInvalidOrphanAction = errors.Errorf("invalid orphan action, it must be %q or %q", orphanActionAdopt, orphanActionRemove)
+
+type staleUnit struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	AppName     string `json:"app"`
+	ProcessName string `json:"process"`
+	HostAddr    string `json:"host"`
+	Action      string `json:"action,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// staleUnitsReport lists the orphans, containers running in the nodes
+// without a record in the database, and the ghosts, records in the database
+// whose container doesn't exist anymore. Units in unreachable nodes aren't
+// checked.
+type staleUnitsReport struct {
+	Orphans          []staleUnit `json:"orphans"`
+	Ghosts           []staleUnit `json:"ghosts"`
+	UnreachableNodes []string    `json:"unreachableNodes,omitempty"`
+	DryRun           bool        `json:"dryRun,omitempty"`
+}
+
+type reconcileUnitsOpts struct {
+	Pool         string
+	OrphanAction string
+	DryRun       bool
+}
+
+type orphanContainer struct {
+	staleUnit
+	container *docker.Container
+	client    *docker.Client
+}
+
+func (p *dockerProvisioner) staleUnits(pool string) (*staleUnitsReport, []orphanContainer, error) {
+	var nodes []cluster.Node
+	var err error
+	if pool == "" {
+		nodes, err = p.Cluster().UnfilteredNodes()
+	} else {
+		nodes, err = p.Cluster().UnfilteredNodesForMetadata(map[string]string{provision.PoolMetadataName: pool})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	report := staleUnitsReport{Orphans: []staleUnit{}, Ghosts: []staleUnit{}}
+	running := map[string]string{}
+	clients := map[string]*docker.Client{}
+	unreachable := map[string]bool{}
+	hosts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		host := net.URLToHost(node.Address)
+		hosts = append(hosts, host)
+		client, err := node.Client()
+		if err == nil {
+			var containers []docker.APIContainers
+			containers, err = client.ListContainers(docker.ListContainersOptions{All: true})
+			for _, c := range containers {
+				running[c.ID] = host
+			}
+		}
+		if err != nil {
+			unreachable[host] = true
+			report.UnreachableNodes = append(report.UnreachableNodes, node.Address)
+			continue
+		}
+		clients[host] = client
+	}
+	query := bson.M{}
+	if pool != "" {
+		query["hostaddr"] = bson.M{"$in": hosts}
+	}
+	records, err := p.ListContainers(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, record := range records {
+		if _, ok := running[record.ID]; ok {
+			delete(running, record.ID)
+			continue
+		}
+		if record.ID == "" || unreachable[record.HostAddr] ||
+			record.Status == provision.StatusCreated.String() ||
+			record.Status == provision.StatusBuilding.String() {
+			continue
+		}
+		report.Ghosts = append(report.Ghosts, staleUnit{
+			ID:          record.ID,
+			Name:        record.Name,
+			AppName:     record.AppName,
+			ProcessName: record.ProcessName,
+			HostAddr:    record.HostAddr,
+		})
+	}
+	var orphans []orphanContainer
+	for id, host := range running {
+		client := clients[host]
+		cont, err := client.InspectContainer(id)
+		if err != nil {
+			if _, ok := err.(*docker.NoSuchContainer); ok {
+				continue
+			}
+			return nil, nil, err
+		}
+		labelSet := provision.LabelSet{Labels: cont.Config.Labels}
+		if labelSet.AppName() == "" || time.Since(cont.Created) < staleUnitGracePeriod {
+			continue
+		}
+		orphan := orphanContainer{
+			staleUnit: staleUnit{
+				ID:          cont.ID,
+				Name:        strings.TrimPrefix(cont.Name, "/"),
+				AppName:     labelSet.AppName(),
+				ProcessName: labelSet.AppProcess(),
+				HostAddr:    host,
+			},
+			container: cont,
+			client:    client,
+		}
+		orphans = append(orphans, orphan)
+		report.Orphans = append(report.Orphans, orphan.staleUnit)
+	}
+	return &report, orphans, nil
+}
+
+// reconcileUnits removes the ghost records from the database and, depending
+// on the orphan action, stores the orphan containers as units of their apps
+// or removes them from the nodes. In dry mode the actions are only reported.
+func (p *dockerProvisioner) reconcileUnits(opts reconcileUnitsOpts) (*staleUnitsReport, error) {
+	if opts.OrphanAction != "" && opts.OrphanAction != orphanActionAdopt && opts.OrphanAction != orphanActionRemove {
+		return nil, errInvalidOrphanAction
+	}
+	report, orphans, err := p.staleUnits(opts.Pool)
+	if err != nil {
+		return nil, err
+	}
+	report.DryRun = opts.DryRun
+	changedApps := map[string]struct{}{}
+	for i := range report.Ghosts {
+		ghost := &report.Ghosts[i]
+		ghost.Action = staleUnitRemoved
+		if opts.DryRun {
+			continue
+		}
+		if err = p.removeGhostUnit(ghost); err != nil {
+			ghost.Action = ""
+			ghost.Error = err.Error()
+			continue
+		}
+		changedApps[ghost.AppName] = struct{}{}
+	}
+	for i, orphan := range orphans {
+		unit := &report.Orphans[i]
+		switch opts.OrphanAction {
+		case orphanActionAdopt:
+			unit.Action = staleUnitAdopted
+			if !opts.DryRun {
+				err = p.adoptOrphanUnit(orphan)
+			}
+		case orphanActionRemove:
+			unit.Action = staleUnitRemoved
+			if !opts.DryRun {
+				err = orphan.client.RemoveContainer(docker.RemoveContainerOptions{ID: orphan.ID, Force: true})
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			unit.Action = ""
+			unit.Error = err.Error()
+			err = nil
+			continue
+		}
+		changedApps[unit.AppName] = struct{}{}
+	}
+	if !opts.DryRun {
+		for appName := range changedApps {
+			rebuild.LockedRoutesRebuildOrEnqueue(appName)
+		}
+	}
+	return report, nil
+}
+
+func (p *dockerProvisioner) removeGhostUnit(ghost *staleUnit) error {
+	coll := p.Collection()
+	defer coll.Close()
+	return coll.Remove(bson.M{"id": ghost.ID})
+}
+
+func (p *dockerProvisioner) adoptOrphanUnit(orphan orphanContainer) error {
+	a, err := app.GetByName(orphan.AppName)
+	if err != nil {
+		return err
+	}
+	cont := orphan.container
+	status := provision.StatusStopped
+	if cont.State.Running {
+		status = provision.StatusStarted
+	}
+	unit := container.Container{Container: types.Container{
+		ID:          orphan.ID,
+		Name:        orphan.Name,
+		AppName:     a.Name,
+		ProcessName: orphan.ProcessName,
+		Type:        a.Platform,
+		HostAddr:    orphan.HostAddr,
+		Image:       cont.Config.Image,
+		Status:      status.String(),
+	}}
+	if cont.NetworkSettings != nil {
+		unit.IP = cont.NetworkSettings.IPAddress
+		for port, bindings := range cont.NetworkSettings.Ports {
+			if len(bindings) > 0 && bindings[0].HostPort != "" {
+				unit.ExposedPort = string(port)
+				unit.HostPort = bindings[0].HostPort
+				break
+			}
+		}
+	}
+	coll := p.Collection()
+	defer coll.Close()
+	return coll.Insert(unit)
+}
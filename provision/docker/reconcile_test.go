@@ -0,0 +1,159 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/provision/docker/types"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) newOrphanContainer(c *check.C, appName string) *docker.Container {
+	imageName := "tsuru/app-" + appName
+	err := s.newFakeImage(s.p, imageName, nil)
+	c.Assert(err, check.IsNil)
+	_, cont, err := s.p.Cluster().CreateContainer(docker.CreateContainerOptions{
+		Name: randomString(),
+		Config: &docker.Config{
+			Image: imageName,
+			Labels: map[string]string{
+				"is-tsuru":    "true",
+				"app-name":    appName,
+				"app-process": "web",
+			},
+		},
+	}, net.StreamInactivityTimeout)
+	c.Assert(err, check.IsNil)
+	return cont
+}
+
+func (s *S) newGhostContainer(c *check.C, appName string) {
+	coll := s.p.Collection()
+	defer coll.Close()
+	err := coll.Insert(container.Container{Container: types.Container{
+		ID:          "ghost-id",
+		Name:        "ghost",
+		AppName:     appName,
+		ProcessName: "web",
+		HostAddr:    "127.0.0.1",
+		Status:      provision.StatusStarted.String(),
+	}})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestStaleUnits(c *check.C) {
+	defer func(period time.Duration) { staleUnitGracePeriod = period }(staleUnitGracePeriod)
+	staleUnitGracePeriod = 0
+	_, err := s.newContainer(&newContainerOpts{AppName: "myapp", Status: provision.StatusStarted.String()}, nil)
+	c.Assert(err, check.IsNil)
+	orphan := s.newOrphanContainer(c, "myapp")
+	s.newGhostContainer(c, "myapp")
+	report, _, err := s.p.staleUnits("")
+	c.Assert(err, check.IsNil)
+	c.Assert(report, check.DeepEquals, &staleUnitsReport{
+		Orphans: []staleUnit{
+			{ID: orphan.ID, Name: orphan.Name, AppName: "myapp", ProcessName: "web", HostAddr: "127.0.0.1"},
+		},
+		Ghosts: []staleUnit{
+			{ID: "ghost-id", Name: "ghost", AppName: "myapp", ProcessName: "web", HostAddr: "127.0.0.1"},
+		},
+	})
+	report, _, err = s.p.staleUnits("other-pool")
+	c.Assert(err, check.IsNil)
+	c.Assert(report, check.DeepEquals, &staleUnitsReport{Orphans: []staleUnit{}, Ghosts: []staleUnit{}})
+}
+
+func (s *S) TestStaleUnitsGracePeriod(c *check.C) {
+	s.newOrphanContainer(c, "myapp")
+	report, _, err := s.p.staleUnits("")
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Orphans, check.HasLen, 0)
+}
+
+func (s *S) TestReconcileUnitsAdopt(c *check.C) {
+	defer func(period time.Duration) { staleUnitGracePeriod = period }(staleUnitGracePeriod)
+	staleUnitGracePeriod = 0
+	err := s.storage.Apps().Insert(app.App{Name: "myapp", Platform: "python"})
+	c.Assert(err, check.IsNil)
+	orphan := s.newOrphanContainer(c, "myapp")
+	s.newGhostContainer(c, "myapp")
+	report, err := s.p.reconcileUnits(reconcileUnitsOpts{OrphanAction: orphanActionAdopt})
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Orphans, check.HasLen, 1)
+	c.Assert(report.Orphans[0].Action, check.Equals, staleUnitAdopted)
+	c.Assert(report.Ghosts, check.HasLen, 1)
+	c.Assert(report.Ghosts[0].Action, check.Equals, staleUnitRemoved)
+	containers, err := s.p.listContainersByApp("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 1)
+	c.Assert(containers[0].ID, check.Equals, orphan.ID)
+	c.Assert(containers[0].ProcessName, check.Equals, "web")
+	c.Assert(containers[0].Type, check.Equals, "python")
+	c.Assert(containers[0].HostAddr, check.Equals, "127.0.0.1")
+	report, _, err = s.p.staleUnits("")
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Orphans, check.HasLen, 0)
+	c.Assert(report.Ghosts, check.HasLen, 0)
+}
+
+func (s *S) TestReconcileUnitsAdoptUnknownApp(c *check.C) {
+	defer func(period time.Duration) { staleUnitGracePeriod = period }(staleUnitGracePeriod)
+	staleUnitGracePeriod = 0
+	s.newOrphanContainer(c, "unknown")
+	report, err := s.p.reconcileUnits(reconcileUnitsOpts{OrphanAction: orphanActionAdopt})
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Orphans, check.HasLen, 1)
+	c.Assert(report.Orphans[0].Action, check.Equals, "")
+	c.Assert(report.Orphans[0].Error, check.Equals, app.ErrAppNotFound.Error())
+}
+
+func (s *S) TestReconcileUnitsRemove(c *check.C) {
+	defer func(period time.Duration) { staleUnitGracePeriod = period }(staleUnitGracePeriod)
+	staleUnitGracePeriod = 0
+	orphan := s.newOrphanContainer(c, "myapp")
+	report, err := s.p.reconcileUnits(reconcileUnitsOpts{OrphanAction: orphanActionRemove})
+	c.Assert(err, check.IsNil)
+	c.Assert(report.Orphans, check.HasLen, 1)
+	c.Assert(report.Orphans[0].Action, check.Equals, staleUnitRemoved)
+	client, err := docker.NewClient(s.server.URL())
+	c.Assert(err, check.IsNil)
+	_, err = client.InspectContainer(orphan.ID)
+	c.Assert(err, check.FitsTypeOf, &docker.NoSuchContainer{})
+}
+
+func (s *S) TestReconcileUnitsDryRun(c *check.C) {
+	defer func(period time.Duration) { staleUnitGracePeriod = period }(staleUnitGracePeriod)
+	staleUnitGracePeriod = 0
+	orphan := s.newOrphanContainer(c, "myapp")
+	s.newGhostContainer(c, "myapp")
+	report, err := s.p.reconcileUnits(reconcileUnitsOpts{OrphanAction: orphanActionRemove, DryRun: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(report.DryRun, check.Equals, true)
+	c.Assert(report.Orphans, check.HasLen, 1)
+	c.Assert(report.Orphans[0].Action, check.Equals, staleUnitRemoved)
+	c.Assert(report.Ghosts, check.HasLen, 1)
+	c.Assert(report.Ghosts[0].Action, check.Equals, staleUnitRemoved)
+	client, err := docker.NewClient(s.server.URL())
+	c.Assert(err, check.IsNil)
+	_, err = client.InspectContainer(orphan.ID)
+	c.Assert(err, check.IsNil)
+	coll := s.p.Collection()
+	defer coll.Close()
+	n, err := coll.Find(bson.M{"id": "ghost-id"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+}
+
+func (s *S) TestReconcileUnitsInvalidOrphanAction(c *check.C) {
+	_, err := s.p.reconcileUnits(reconcileUnitsOpts{OrphanAction: "kill"})
+	c.Assert(err, check.Equals, errInvalidOrphanAction)
+}
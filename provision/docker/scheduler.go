@@ -19,6 +19,7 @@ import (
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/provision/docker/types"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -46,10 +47,22 @@ func (s *segregatedScheduler) Schedule(c *cluster.Cluster, opts docker.CreateCon
 	if err != nil {
 		return cluster.Node{}, &container.SchedulerError{Base: err}
 	}
+	nodes, err = s.filterByNodeRequirements(a, nodes)
+	if err != nil {
+		return cluster.Node{}, &container.SchedulerError{Base: err}
+	}
+	nodes, err = s.filterByHardwareProfile(a, nodes)
+	if err != nil {
+		return cluster.Node{}, &container.SchedulerError{Base: err}
+	}
 	nodes, err = s.filterByMemoryUsage(a, nodes, s.maxMemoryRatio, s.TotalMemoryMetadata)
 	if err != nil {
 		return cluster.Node{}, &container.SchedulerError{Base: err}
 	}
+	nodes, err = s.filterByPreemption(a, nodes)
+	if err != nil {
+		return cluster.Node{}, &container.SchedulerError{Base: err}
+	}
 	node, err := s.chooseNodeToAdd(nodes, opts.Name, schedOpts.AppName, schedOpts.ProcessName)
 	if err != nil {
 		return cluster.Node{}, &container.SchedulerError{Base: err}
@@ -57,9 +70,74 @@ func (s *segregatedScheduler) Schedule(c *cluster.Cluster, opts docker.CreateCon
 	if schedOpts.ActionLimiter != nil {
 		schedOpts.LimiterDone = schedOpts.ActionLimiter.Start(net.URLToHost(node))
 	}
+	if schedOpts.PrepareNode != nil {
+		err = schedOpts.PrepareNode(node)
+		if err != nil {
+			return cluster.Node{}, &container.SchedulerError{Base: err}
+		}
+	}
 	return cluster.Node{Address: node}, nil
 }
 
+func (s *segregatedScheduler) filterByNodeRequirements(a *app.App, nodes []cluster.Node) ([]cluster.Node, error) {
+	if a == nil || len(a.NodeRequirements) == 0 {
+		return nodes, nil
+	}
+	nodeList := make([]cluster.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if provision.MatchesRequirements(node.Metadata, a.NodeRequirements) {
+			nodeList = append(nodeList, node)
+		}
+	}
+	if len(nodeList) == 0 {
+		return nil, errors.Errorf("no nodes found matching the node requirements of app %q: %v", a.Name, a.NodeRequirements)
+	}
+	return nodeList, nil
+}
+
+// filterByHardwareProfile removes the nodes whose hardware profile differs
+// from the one required by the plan of the app. Nodes without a profile in
+// their metadata are assumed to match the profile of their pool.
+func (s *segregatedScheduler) filterByHardwareProfile(a *app.App, nodes []cluster.Node) ([]cluster.Node, error) {
+	if a == nil || a.Plan.HardwareProfile == "" {
+		return nodes, nil
+	}
+	nodeList := make([]cluster.Node, 0, len(nodes))
+	for _, node := range nodes {
+		profile := node.Metadata[provision.HardwareProfileMetadataKey]
+		if profile == "" || profile == a.Plan.HardwareProfile {
+			nodeList = append(nodeList, node)
+		}
+	}
+	if len(nodeList) == 0 {
+		return nil, errors.Errorf("no nodes found with hardware profile %q required by plan %q of app %q", a.Plan.HardwareProfile, a.Plan.Name, a.Name)
+	}
+	return nodeList, nil
+}
+
+// filterByPreemption keeps units of apps that don't tolerate preemption away
+// from preemptible nodes, while apps tolerating it only use preemptible nodes
+// when there's any available. Daemon apps run in every node and are ignored.
+func (s *segregatedScheduler) filterByPreemption(a *app.App, nodes []cluster.Node) ([]cluster.Node, error) {
+	if a == nil || a.Daemon {
+		return nodes, nil
+	}
+	tolerates := a.ToleratesPreemption()
+	nodeList := make([]cluster.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if provision.IsPreemptible(node.Metadata) == tolerates {
+			nodeList = append(nodeList, node)
+		}
+	}
+	if len(nodeList) > 0 {
+		return nodeList, nil
+	}
+	if tolerates {
+		return nodes, nil
+	}
+	return nil, errors.Errorf("no stable nodes found for app %q, which doesn't tolerate preemption", a.Name)
+}
+
 func (s *segregatedScheduler) filterByMemoryUsage(a *app.App, nodes []cluster.Node, maxMemoryRatio float32, TotalMemoryMetadata string) ([]cluster.Node, error) {
 	if maxMemoryRatio == 0 || TotalMemoryMetadata == "" {
 		return nodes, nil
@@ -74,6 +152,11 @@ func (s *segregatedScheduler) filterByMemoryUsage(a *app.App, nodes []cluster.No
 	}
 	hostReserved := make(map[string]int64)
 	for _, cont := range containers {
+		if cont.AppName == a.Name {
+			// The app may not exist yet when planning units in dry mode.
+			hostReserved[cont.HostAddr] += a.Plan.Memory
+			continue
+		}
 		contApp, err := app.GetByName(cont.AppName)
 		if err != nil {
 			return nil, err
@@ -126,6 +209,61 @@ func (s *segregatedScheduler) filterByMemoryUsage(a *app.App, nodes []cluster.No
 	return nodeList, nil
 }
 
+// planUnits returns the nodes where n new units of the app process would be
+// placed. Each planned unit is stored in the containers collection of the
+// provisioner, which must be in dry mode, so the following ones account for
+// it.
+func (s *segregatedScheduler) planUnits(a *app.App, n uint, process string) ([]provision.UnitPlacement, error) {
+	sched, err := provision.GetPoolScheduler(a.Pool)
+	if err != nil {
+		return nil, err
+	}
+	coll := s.provisioner.Collection()
+	defer coll.Close()
+	placement := make([]provision.UnitPlacement, 0, n)
+	for i := uint(0); i < n; i++ {
+		nodes, err := s.provisioner.Nodes(a)
+		if err != nil {
+			return nil, err
+		}
+		nodes, err = s.filterByNodeRequirements(a, nodes)
+		if err != nil {
+			return nil, err
+		}
+		nodes, err = s.filterByHardwareProfile(a, nodes)
+		if err != nil {
+			return nil, err
+		}
+		nodes, err = s.filterByMemoryUsage(a, nodes, s.maxMemoryRatio, s.TotalMemoryMetadata)
+		if err != nil {
+			return nil, err
+		}
+		nodes, err = s.filterByPreemption(a, nodes)
+		if err != nil {
+			return nil, err
+		}
+		nodes, err = s.filterByPoolScheduler(nodes, sched, a.Name, process)
+		if err != nil {
+			return nil, err
+		}
+		node, _, err := s.minMaxNodesWithScheduler(nodes, a.Name, process, sched)
+		if err != nil {
+			return nil, err
+		}
+		err = coll.Insert(container.Container{Container: types.Container{
+			ID:          "planned-" + randomString(),
+			AppName:     a.Name,
+			ProcessName: process,
+			HostAddr:    net.URLToHost(node),
+		}})
+		if err != nil {
+			return nil, err
+		}
+		placement = append(placement, provision.UnitPlacement{Process: process, Node: node})
+	}
+	return placement, nil
+}
+
 type nodeAggregate struct {
 	HostAddr string `bson:"_id"`
 	Count    int
@@ -232,7 +370,15 @@ func (s *segregatedScheduler) chooseNodeToAdd(nodes []cluster.Node, contName str
 	log.Debugf("[scheduler] Possible nodes for container %s: %#v", contName, nodes)
 	s.hostMutex.Lock()
 	defer s.hostMutex.Unlock()
-	chosenNode, _, err := s.minMaxNodes(nodes, appName, process)
+	sched, err := s.poolScheduler(appName)
+	if err != nil {
+		return "", err
+	}
+	nodes, err = s.filterByPoolScheduler(nodes, sched, appName, process)
+	if err != nil {
+		return "", err
+	}
+	chosenNode, _, err := s.minMaxNodesWithScheduler(nodes, appName, process, sched)
 	if err != nil {
 		return "", err
 	}
@@ -272,10 +418,69 @@ func appGroupCount(hostGroups map[string]int, appCountHost map[string]int) map[s
 	return result
 }
 
+// poolScheduler returns the scheduler configuration of the pool of the app.
+// Unknown apps use the default configuration.
+func (s *segregatedScheduler) poolScheduler(appName string) (provision.PoolScheduler, error) {
+	a, err := app.GetByName(appName)
+	if err != nil {
+		return provision.PoolScheduler{}, nil
+	}
+	return provision.GetPoolScheduler(a.Pool)
+}
+
+// filterByPoolScheduler removes the nodes that reached the max units per node
+// of the pool and, when anti affinity is required, the nodes that already run
+// units of the app process.
+func (s *segregatedScheduler) filterByPoolScheduler(nodes []cluster.Node, sched provision.PoolScheduler, appName, process string) ([]cluster.Node, error) {
+	required := sched.GetAntiAffinity() == provision.AntiAffinityRequired
+	if sched.MaxUnitsPerNode == 0 && !required {
+		return nodes, nil
+	}
+	hosts, _ := s.nodesToHosts(nodes)
+	hostCountMap, err := s.aggregateContainersByHost(hosts)
+	if err != nil {
+		return nil, err
+	}
+	appCountMap := map[string]int{}
+	if required {
+		appCountMap, err = s.aggregateContainersByHostAppProcess(hosts, appName, process)
+		if err != nil {
+			return nil, err
+		}
+	}
+	nodeList := make([]cluster.Node, 0, len(nodes))
+	for i, node := range nodes {
+		if sched.MaxUnitsPerNode > 0 && hostCountMap[hosts[i]] >= sched.MaxUnitsPerNode {
+			continue
+		}
+		if appCountMap[hosts[i]] > 0 {
+			continue
+		}
+		nodeList = append(nodeList, node)
+	}
+	if len(nodeList) == 0 {
+		return nil, errors.Errorf("no nodes found for a new unit of %q (process %q) with max units per node %d and %s anti affinity",
+			appName, process, sched.MaxUnitsPerNode, sched.GetAntiAffinity())
+	}
+	return nodeList, nil
+}
+
 // Find the host with the minimum (good to add a new container) and maximum
 // (good to remove a container) value for the pair [(number of containers for
 // app-process), (number of containers in host)]
 func (s *segregatedScheduler) minMaxNodes(nodes []cluster.Node, appName, process string) (string, string, error) {
+	sched, err := s.poolScheduler(appName)
+	if err != nil {
+		return "", "", err
+	}
+	return s.minMaxNodesWithScheduler(nodes, appName, process, sched)
+}
+
+// minMaxNodesWithScheduler is minMaxNodes following the scheduler
+// configuration of the pool: the number of containers of the app-process is
+// ignored without anti affinity, and with the binpack strategy the hosts with
+// more containers are preferred to add a new one.
+func (s *segregatedScheduler) minMaxNodesWithScheduler(nodes []cluster.Node, appName, process string, sched provision.PoolScheduler) (string, string, error) {
 	nodesList := make(provision.NodeList, len(nodes))
 	for i := range nodes {
 		nodesList[i] = &clusterNodeWrapper{Node: &nodes[i], prov: s.provisioner}
@@ -299,7 +504,14 @@ func (s *segregatedScheduler) minMaxNodes(nodes []cluster.Node, appName, process
 	if err != nil {
 		return "", "", err
 	}
-	priorityEntries := []map[string]int{appGroupCount(hostGroupMap, appCountMap), appCountMap, hostCountMap}
+	var priorityEntries []map[string]int
+	if sched.GetAntiAffinity() != provision.AntiAffinityNone {
+		priorityEntries = append(priorityEntries, appGroupCount(hostGroupMap, appCountMap), appCountMap)
+	}
+	if sched.GetStrategy() == provision.SchedulerStrategyBinpack {
+		hostCountMap = invertCounts(hosts, hostCountMap)
+	}
+	priorityEntries = append(priorityEntries, hostCountMap)
 	var minHost, maxHost string
 	var minScore uint64 = math.MaxUint64
 	var maxScore uint64 = 0
@@ -319,3 +531,19 @@ func (s *segregatedScheduler) minMaxNodes(nodes []cluster.Node, appName, process
 	}
 	return hostsMap[minHost], hostsMap[maxHost], nil
 }
+
+// invertCounts returns the distance of the count of each host to the highest
+// count, so that hosts with more containers get lower values.
+func invertCounts(hosts []string, counts map[string]int) map[string]int {
+	var max int
+	for _, host := range hosts {
+		if counts[host] > max {
+			max = counts[host]
+		}
+	}
+	result := make(map[string]int, len(hosts))
+	for _, host := range hosts {
+		result[host] = max - counts[host]
+	}
+	return result
+}
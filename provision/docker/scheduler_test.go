@@ -162,6 +162,145 @@ func (s *S) TestSchedulerNoNodes(c *check.C) {
 	c.Assert(err.Error(), check.Matches, "error in scheduler: No nodes found with one of the following metadata: pool=mypool")
 }
 
+func (s *S) TestSchedulerScheduleWithNodeRequirements(c *check.C) {
+	a1 := app.App{Name: "impius", Teams: []string{"tsuruteam"}, Pool: "pool1", NodeRequirements: map[string]string{"disk": "ssd"}}
+	a2 := app.App{Name: "mirror", Teams: []string{"tsuruteam"}, Pool: "pool1", NodeRequirements: map[string]string{"network": "dmz"}}
+	err := s.storage.Apps().Insert(a1, a2)
+	c.Assert(err, check.IsNil)
+	defer s.storage.Apps().RemoveAll(bson.M{"name": bson.M{"$in": []string{a1.Name, a2.Name}}})
+	o := provision.AddPoolOptions{Name: "pool1"}
+	err = provision.AddPool(o)
+	c.Assert(err, check.IsNil)
+	err = provision.AddTeamsToPool("pool1", []string{"tsuruteam"})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	scheduler := segregatedScheduler{provisioner: s.p}
+	clusterInstance, err := cluster.New(&scheduler, &cluster.MapStorage{}, "")
+	c.Assert(err, check.IsNil)
+	s.p.cluster = clusterInstance
+	server1, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server1.Stop()
+	server2, err := testing.NewServer("localhost:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server2.Stop()
+	err = clusterInstance.Register(cluster.Node{
+		Address:  server1.URL(),
+		Metadata: map[string]string{"pool": "pool1"},
+	})
+	c.Assert(err, check.IsNil)
+	localURL := strings.Replace(server2.URL(), "127.0.0.1", "localhost", -1)
+	err = clusterInstance.Register(cluster.Node{
+		Address:  localURL,
+		Metadata: map[string]string{"pool": "pool1", "disk": "ssd"},
+	})
+	c.Assert(err, check.IsNil)
+	opts := docker.CreateContainerOptions{Name: "impius1"}
+	for i := 0; i < 2; i++ {
+		node, err := scheduler.Schedule(clusterInstance, opts, &container.SchedulerOpts{AppName: a1.Name, ProcessName: "web"})
+		c.Assert(err, check.IsNil)
+		c.Check(node.Address, check.Equals, localURL)
+	}
+	opts = docker.CreateContainerOptions{Name: "mirror1"}
+	_, err = scheduler.Schedule(clusterInstance, opts, &container.SchedulerOpts{AppName: a2.Name, ProcessName: "web"})
+	c.Assert(err, check.ErrorMatches, `.*no nodes found matching the node requirements of app "mirror": map\[network:dmz\]`)
+}
+
+func (s *S) TestSchedulerScheduleWithHardwareProfile(c *check.C) {
+	a1 := app.App{Name: "impius", Teams: []string{"tsuruteam"}, Pool: "pool1", Plan: app.Plan{Name: "fast", HardwareProfile: "ssd"}}
+	a2 := app.App{Name: "mirror", Teams: []string{"tsuruteam"}, Pool: "pool1", Plan: app.Plan{Name: "big", HardwareProfile: "nvme"}}
+	err := s.storage.Apps().Insert(a1, a2)
+	c.Assert(err, check.IsNil)
+	defer s.storage.Apps().RemoveAll(bson.M{"name": bson.M{"$in": []string{a1.Name, a2.Name}}})
+	o := provision.AddPoolOptions{Name: "pool1"}
+	err = provision.AddPool(o)
+	c.Assert(err, check.IsNil)
+	err = provision.AddTeamsToPool("pool1", []string{"tsuruteam"})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	scheduler := segregatedScheduler{provisioner: s.p}
+	clusterInstance, err := cluster.New(&scheduler, &cluster.MapStorage{}, "")
+	c.Assert(err, check.IsNil)
+	s.p.cluster = clusterInstance
+	server1, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server1.Stop()
+	server2, err := testing.NewServer("localhost:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server2.Stop()
+	err = clusterInstance.Register(cluster.Node{
+		Address:  server1.URL(),
+		Metadata: map[string]string{"pool": "pool1", provision.HardwareProfileMetadataKey: "hdd"},
+	})
+	c.Assert(err, check.IsNil)
+	localURL := strings.Replace(server2.URL(), "127.0.0.1", "localhost", -1)
+	err = clusterInstance.Register(cluster.Node{
+		Address:  localURL,
+		Metadata: map[string]string{"pool": "pool1", provision.HardwareProfileMetadataKey: "ssd"},
+	})
+	c.Assert(err, check.IsNil)
+	opts := docker.CreateContainerOptions{Name: "impius1"}
+	for i := 0; i < 2; i++ {
+		node, err := scheduler.Schedule(clusterInstance, opts, &container.SchedulerOpts{AppName: a1.Name, ProcessName: "web"})
+		c.Assert(err, check.IsNil)
+		c.Check(node.Address, check.Equals, localURL)
+	}
+	opts = docker.CreateContainerOptions{Name: "mirror1"}
+	_, err = scheduler.Schedule(clusterInstance, opts, &container.SchedulerOpts{AppName: a2.Name, ProcessName: "web"})
+	c.Assert(err, check.ErrorMatches, `.*no nodes found with hardware profile "nvme" required by plan "big" of app "mirror"`)
+}
+
+func (s *S) TestSchedulerScheduleWithPreemption(c *check.C) {
+	a1 := app.App{Name: "impius", Teams: []string{"tsuruteam"}, Pool: "pool1", Plan: app.Plan{Name: "cheap", ToleratePreemption: true}}
+	a2 := app.App{Name: "mirror", Teams: []string{"tsuruteam"}, Pool: "pool1"}
+	a3 := app.App{Name: "dedication", Teams: []string{"tsuruteam"}, Pool: "pool1", Plan: app.Plan{Name: "cheap", ToleratePreemption: true}, PreemptionTolerance: app.PreemptionAvoid}
+	err := s.storage.Apps().Insert(a1, a2, a3)
+	c.Assert(err, check.IsNil)
+	defer s.storage.Apps().RemoveAll(bson.M{"name": bson.M{"$in": []string{a1.Name, a2.Name, a3.Name}}})
+	o := provision.AddPoolOptions{Name: "pool1"}
+	err = provision.AddPool(o)
+	c.Assert(err, check.IsNil)
+	err = provision.AddTeamsToPool("pool1", []string{"tsuruteam"})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	scheduler := segregatedScheduler{provisioner: s.p}
+	clusterInstance, err := cluster.New(&scheduler, &cluster.MapStorage{}, "")
+	c.Assert(err, check.IsNil)
+	s.p.cluster = clusterInstance
+	server1, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server1.Stop()
+	server2, err := testing.NewServer("localhost:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server2.Stop()
+	err = clusterInstance.Register(cluster.Node{
+		Address:  server1.URL(),
+		Metadata: map[string]string{"pool": "pool1"},
+	})
+	c.Assert(err, check.IsNil)
+	localURL := strings.Replace(server2.URL(), "127.0.0.1", "localhost", -1)
+	err = clusterInstance.Register(cluster.Node{
+		Address:  localURL,
+		Metadata: map[string]string{"pool": "pool1", provision.PreemptibleMetadataKey: "true"},
+	})
+	c.Assert(err, check.IsNil)
+	for i := 0; i < 2; i++ {
+		node, err := scheduler.Schedule(clusterInstance, docker.CreateContainerOptions{Name: "impius1"}, &container.SchedulerOpts{AppName: a1.Name, ProcessName: "web"})
+		c.Assert(err, check.IsNil)
+		c.Check(node.Address, check.Equals, localURL)
+		node, err = scheduler.Schedule(clusterInstance, docker.CreateContainerOptions{Name: "mirror1"}, &container.SchedulerOpts{AppName: a2.Name, ProcessName: "web"})
+		c.Assert(err, check.IsNil)
+		c.Check(node.Address, check.Equals, server1.URL())
+		node, err = scheduler.Schedule(clusterInstance, docker.CreateContainerOptions{Name: "dedication1"}, &container.SchedulerOpts{AppName: a3.Name, ProcessName: "web"})
+		c.Assert(err, check.IsNil)
+		c.Check(node.Address, check.Equals, server1.URL())
+	}
+	err = clusterInstance.Unregister(server1.URL())
+	c.Assert(err, check.IsNil)
+	_, err = scheduler.Schedule(clusterInstance, docker.CreateContainerOptions{Name: "mirror2"}, &container.SchedulerOpts{AppName: a2.Name, ProcessName: "web"})
+	c.Assert(err, check.ErrorMatches, `.*no stable nodes found for app "mirror", which doesn't tolerate preemption`)
+}
+
 func (s *S) TestSchedulerScheduleWithMemoryAwareness(c *check.C) {
 	logBuf := bytes.NewBuffer(nil)
 	log.SetLogger(log.NewWriterLogger(logBuf, false))
@@ -948,3 +1087,71 @@ func (s *S) TestChooseContainerToBeRemovedTable(c *check.C) {
 		c.Assert(found, check.Equals, true, check.Commentf("test %d: containerID: %s, expected: %v", i, containerID, tt.expected))
 	}
 }
+
+func (s *S) TestChooseNodePoolSchedulerBinpack(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{
+		Name:      "binpack",
+		Scheduler: provision.PoolScheduler{Strategy: provision.SchedulerStrategyBinpack, AntiAffinity: provision.AntiAffinityNone, MaxUnitsPerNode: 2},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.storage.Apps().Insert(app.App{Name: "packed", Pool: "binpack"})
+	c.Assert(err, check.IsNil)
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234"},
+		{Address: "http://server2:1234"},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	sched := segregatedScheduler{provisioner: s.p}
+	var chosen []string
+	for i := 0; i < 4; i++ {
+		cont := container.Container{Container: types.Container{Name: fmt.Sprintf("unit%d", i), AppName: "packed", ProcessName: "web"}}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		node, err := sched.chooseNodeToAdd(nodes, cont.Name, "packed", "web")
+		c.Assert(err, check.IsNil)
+		chosen = append(chosen, node)
+	}
+	c.Assert(chosen[0], check.Equals, chosen[1])
+	c.Assert(chosen[2], check.Equals, chosen[3])
+	c.Assert(chosen[0], check.Not(check.Equals), chosen[2])
+	cont := container.Container{Container: types.Container{Name: "unit4", AppName: "packed", ProcessName: "web"}}
+	err = contColl.Insert(cont)
+	c.Assert(err, check.IsNil)
+	_, err = sched.chooseNodeToAdd(nodes, cont.Name, "packed", "web")
+	c.Assert(err, check.ErrorMatches, `no nodes found for a new unit of "packed" \(process "web"\) with max units per node 2 and none anti affinity`)
+}
+
+func (s *S) TestChooseNodePoolSchedulerRequiredAntiAffinity(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{
+		Name:      "spread",
+		Scheduler: provision.PoolScheduler{AntiAffinity: provision.AntiAffinityRequired},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.storage.Apps().Insert(app.App{Name: "spreaded", Pool: "spread"})
+	c.Assert(err, check.IsNil)
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234"},
+		{Address: "http://server2:1234"},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	sched := segregatedScheduler{provisioner: s.p}
+	for i := 0; i < 2; i++ {
+		cont := container.Container{Container: types.Container{Name: fmt.Sprintf("unit%d", i), AppName: "spreaded", ProcessName: "web"}}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		_, err = sched.chooseNodeToAdd(nodes, cont.Name, "spreaded", "web")
+		c.Assert(err, check.IsNil)
+	}
+	cont := container.Container{Container: types.Container{Name: "worker", AppName: "spreaded", ProcessName: "worker"}}
+	err = contColl.Insert(cont)
+	c.Assert(err, check.IsNil)
+	_, err = sched.chooseNodeToAdd(nodes, cont.Name, "spreaded", "worker")
+	c.Assert(err, check.IsNil)
+	cont = container.Container{Container: types.Container{Name: "unit2", AppName: "spreaded", ProcessName: "web"}}
+	err = contColl.Insert(cont)
+	c.Assert(err, check.IsNil)
+	_, err = sched.chooseNodeToAdd(nodes, cont.Name, "spreaded", "web")
+	c.Assert(err, check.ErrorMatches, `no nodes found for a new unit of "spreaded" .*required anti affinity`)
+}
@@ -33,6 +33,9 @@ type Container struct {
 	LockedUntil             time.Time
 	Routable                bool `bson:"-"`
 	ExposedPort             string
+	// StopTimeout is the time, in seconds, the container has to stop before
+	// being killed, zero means the default timeout.
+	StopTimeout int
 }
 
 type DockerLogConfig struct {
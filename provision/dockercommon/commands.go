@@ -24,6 +24,9 @@ func DeployCmds(app provision.App, params ...string) []string {
 		deployCmd = "/var/lib/tsuru/deploy"
 	}
 	cmds := append([]string{deployCmd}, params...)
+	if app.GetDeployToggles().VerboseBuild {
+		cmds = append([]string{"TSURU_BUILD_VERBOSE=1"}, cmds...)
+	}
 	host, _ := config.GetString("host")
 	token := app.Envs()["TSURU_APP_TOKEN"].Value
 	unitAgentCmds := []string{"tsuru_unit_agent", host, token, app.GetName(), `"` + strings.Join(cmds, " ") + `"`, "deploy"}
@@ -63,6 +63,24 @@ func (s *S) TestArchiveDeployCmds(c *check.C) {
 	c.Assert(cmds, check.DeepEquals, []string{"/bin/sh", "-lc", expectedAgent})
 }
 
+func (s *S) TestDeployCmdsVerboseBuild(c *check.C) {
+	app := provisiontest.NewFakeApp("app-name", "python", 1)
+	app.Toggles.VerboseBuild = true
+	config.Set("host", "tsuru_host")
+	defer config.Unset("host")
+	tokenEnv := bind.EnvVar{
+		Name:   "TSURU_APP_TOKEN",
+		Value:  "app_token",
+		Public: true,
+	}
+	app.SetEnv(tokenEnv)
+	archiveURL := "https://s3.amazonaws.com/wat/archive.tar.gz"
+	expectedPart1 := fmt.Sprintf("TSURU_BUILD_VERBOSE=1 /var/lib/tsuru/deploy archive %s", archiveURL)
+	expectedAgent := fmt.Sprintf(`tsuru_unit_agent tsuru_host app_token app-name "%s" deploy`, expectedPart1)
+	cmds := ArchiveDeployCmds(app, archiveURL)
+	c.Assert(cmds, check.DeepEquals, []string{"/bin/sh", "-lc", expectedAgent})
+}
+
 func (s *S) TestRunWithAgentCmds(c *check.C) {
 	app := provisiontest.NewFakeApp("app-name", "python", 1)
 	config.Set("host", "tsuru_host")
@@ -6,9 +6,11 @@ package provision
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/log"
 )
 
 func WebProcessDefaultPort() string {
@@ -19,10 +21,19 @@ func WebProcessDefaultPort() string {
 	return fmt.Sprint(port)
 }
 
+// EnvsForApp returns the environment variables set in units of the app. The
+// envs of the app pool are included, unless the app sets a variable with the
+// same name.
 func EnvsForApp(a App, process string, isDeploy bool) []bind.EnvVar {
 	var envs []bind.EnvVar
 	if !isDeploy {
-		for _, envData := range a.Envs() {
+		appEnvs := a.Envs()
+		for _, envData := range poolEnvs(a.GetPool()) {
+			if _, ok := appEnvs[envData.Name]; !ok {
+				envs = append(envs, envData)
+			}
+		}
+		for _, envData := range appEnvs {
 			envs = append(envs, envData)
 		}
 		envs = append(envs, bind.EnvVar{Name: "TSURU_PROCESSNAME", Value: process})
@@ -38,3 +49,26 @@ func EnvsForApp(a App, process string, isDeploy bool) []bind.EnvVar {
 	}
 	return envs
 }
+
+func poolEnvs(poolName string) []bind.EnvVar {
+	if poolName == "" {
+		return nil
+	}
+	pool, err := GetPoolByName(poolName)
+	if err != nil {
+		if err != ErrPoolNotFound {
+			log.Errorf("[envs] unable to get envs for pool %q: %s", poolName, err)
+		}
+		return nil
+	}
+	names := make([]string, 0, len(pool.Env))
+	for name := range pool.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	envs := make([]bind.EnvVar, len(names))
+	for i, name := range names {
+		envs[i] = bind.EnvVar{Name: name, Value: pool.Env[name], Public: true}
+	}
+	return envs
+}
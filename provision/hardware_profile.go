@@ -0,0 +1,179 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// HardwareProfileMetadataKey is the node metadata key holding the hardware
+// profile of the node. Nodes without it are assumed to match the profile of
+// their pool.
+const HardwareProfileMetadataKey = "hardware-profile"
+
+var (
+	ErrHardwareProfileNameRequired  = errors.New("hardware profile name is required")
+	ErrHardwareProfileNotFound      = errors.New("hardware profile not found")
+	ErrHardwareProfileAlreadyExists = errors.New("hardware profile already exists")
+	ErrHardwareProfileInUse         = errors.New("hardware profile is in use by pools")
+)
+
+// HardwareProfile describes the hardware of the nodes of a pool. Plans may
+// require a profile, restricting the pools their apps may be placed in.
+type HardwareProfile struct {
+	Name          string `bson:"_id" json:"name"`
+	CPUGeneration string `json:"cpuGeneration,omitempty" bson:",omitempty"`
+	MemoryClass   string `json:"memoryClass,omitempty" bson:",omitempty"`
+	DiskType      string `json:"diskType,omitempty" bson:",omitempty"`
+}
+
+// HardwareProfileMismatchError is returned when an app is placed in a pool
+// without the hardware profile required by its plan.
+type HardwareProfileMismatchError struct {
+	Plan            string
+	Profile         string
+	Pool            string
+	CompatiblePools []string
+}
+
+func (e *HardwareProfileMismatchError) Error() string {
+	msg := fmt.Sprintf("plan %q requires hardware profile %q, which is not available in pool %q", e.Plan, e.Profile, e.Pool)
+	if len(e.CompatiblePools) == 0 {
+		return msg + ", no pools are compatible"
+	}
+	return fmt.Sprintf("%s, compatible pools: %s", msg, strings.Join(e.CompatiblePools, ", "))
+}
+
+func hardwareProfilesCollection(conn *db.Storage) *storage.Collection {
+	return conn.Collection("hardware_profiles")
+}
+
+// AddHardwareProfile registers a new hardware profile.
+func AddHardwareProfile(profile HardwareProfile) error {
+	if profile.Name == "" {
+		return ErrHardwareProfileNameRequired
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = hardwareProfilesCollection(conn).Insert(profile)
+	if mgo.IsDup(err) {
+		return ErrHardwareProfileAlreadyExists
+	}
+	return err
+}
+
+// GetHardwareProfile returns the hardware profile with the given name.
+func GetHardwareProfile(name string) (*HardwareProfile, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var profile HardwareProfile
+	err = hardwareProfilesCollection(conn).FindId(name).One(&profile)
+	if err == mgo.ErrNotFound {
+		return nil, ErrHardwareProfileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// ListHardwareProfiles returns all hardware profiles sorted by name.
+func ListHardwareProfiles() ([]HardwareProfile, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var profiles []HardwareProfile
+	err = hardwareProfilesCollection(conn).Find(nil).Sort("_id").All(&profiles)
+	if err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// RemoveHardwareProfile removes the hardware profile, failing if any pool
+// still uses it.
+func RemoveHardwareProfile(name string) error {
+	pools, err := PoolsWithHardwareProfile(name)
+	if err != nil {
+		return err
+	}
+	if len(pools) > 0 {
+		return ErrHardwareProfileInUse
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = hardwareProfilesCollection(conn).RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrHardwareProfileNotFound
+	}
+	return err
+}
+
+// PoolsWithHardwareProfile returns the sorted names of the pools with the
+// hardware profile.
+func PoolsWithHardwareProfile(profile string) ([]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var pools []Pool
+	err = conn.Pools().Find(bson.M{"hardwareprofile": profile}).Select(bson.M{"_id": 1}).All(&pools)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(pools))
+	for i, p := range pools {
+		names[i] = p.Name
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CheckPoolHardwareProfile returns a *HardwareProfileMismatchError listing
+// the compatible pools when the pool doesn't have the hardware profile
+// required by the plan. Plans without a profile are accepted by any pool.
+func CheckPoolHardwareProfile(pool *Pool, plan, profile string) error {
+	if profile == "" || pool.HardwareProfile == profile {
+		return nil
+	}
+	compatible, err := PoolsWithHardwareProfile(profile)
+	if err != nil {
+		return err
+	}
+	return &HardwareProfileMismatchError{
+		Plan:            plan,
+		Profile:         profile,
+		Pool:            pool.Name,
+		CompatiblePools: compatible,
+	}
+}
+
+func validateHardwareProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	_, err := GetHardwareProfile(name)
+	return err
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import "gopkg.in/check.v1"
+
+func (s *S) TestAddHardwareProfile(c *check.C) {
+	profile := HardwareProfile{Name: "fast", CPUGeneration: "skylake", MemoryClass: "ddr4", DiskType: "ssd"}
+	err := AddHardwareProfile(profile)
+	c.Assert(err, check.IsNil)
+	p, err := GetHardwareProfile("fast")
+	c.Assert(err, check.IsNil)
+	c.Assert(*p, check.DeepEquals, profile)
+	err = AddHardwareProfile(profile)
+	c.Assert(err, check.Equals, ErrHardwareProfileAlreadyExists)
+	err = AddHardwareProfile(HardwareProfile{DiskType: "ssd"})
+	c.Assert(err, check.Equals, ErrHardwareProfileNameRequired)
+	_, err = GetHardwareProfile("slow")
+	c.Assert(err, check.Equals, ErrHardwareProfileNotFound)
+}
+
+func (s *S) TestListHardwareProfiles(c *check.C) {
+	err := AddHardwareProfile(HardwareProfile{Name: "slow", DiskType: "hdd"})
+	c.Assert(err, check.IsNil)
+	err = AddHardwareProfile(HardwareProfile{Name: "fast", DiskType: "ssd"})
+	c.Assert(err, check.IsNil)
+	profiles, err := ListHardwareProfiles()
+	c.Assert(err, check.IsNil)
+	c.Assert(profiles, check.DeepEquals, []HardwareProfile{
+		{Name: "fast", DiskType: "ssd"},
+		{Name: "slow", DiskType: "hdd"},
+	})
+}
+
+func (s *S) TestRemoveHardwareProfile(c *check.C) {
+	err := AddHardwareProfile(HardwareProfile{Name: "fast"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool1", HardwareProfile: "fast"})
+	c.Assert(err, check.IsNil)
+	err = RemoveHardwareProfile("fast")
+	c.Assert(err, check.Equals, ErrHardwareProfileInUse)
+	empty := ""
+	err = PoolUpdate("pool1", UpdatePoolOptions{HardwareProfile: &empty})
+	c.Assert(err, check.IsNil)
+	err = RemoveHardwareProfile("fast")
+	c.Assert(err, check.IsNil)
+	err = RemoveHardwareProfile("fast")
+	c.Assert(err, check.Equals, ErrHardwareProfileNotFound)
+}
+
+func (s *S) TestPoolHardwareProfile(c *check.C) {
+	err := AddHardwareProfile(HardwareProfile{Name: "fast"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool1", HardwareProfile: "slow"})
+	c.Assert(err, check.Equals, ErrHardwareProfileNotFound)
+	err = AddPool(AddPoolOptions{Name: "pool1", HardwareProfile: "fast"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool2"})
+	c.Assert(err, check.IsNil)
+	slow := "slow"
+	err = PoolUpdate("pool2", UpdatePoolOptions{HardwareProfile: &slow})
+	c.Assert(err, check.Equals, ErrHardwareProfileNotFound)
+	fast := "fast"
+	err = PoolUpdate("pool2", UpdatePoolOptions{HardwareProfile: &fast})
+	c.Assert(err, check.IsNil)
+	pools, err := PoolsWithHardwareProfile("fast")
+	c.Assert(err, check.IsNil)
+	c.Assert(pools, check.DeepEquals, []string{"pool1", "pool2"})
+}
+
+func (s *S) TestCheckPoolHardwareProfile(c *check.C) {
+	err := AddHardwareProfile(HardwareProfile{Name: "fast"})
+	c.Assert(err, check.IsNil)
+	err = AddHardwareProfile(HardwareProfile{Name: "gpu"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool1", HardwareProfile: "fast"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool2", HardwareProfile: "fast"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool3"})
+	c.Assert(err, check.IsNil)
+	pool1, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	pool3, err := GetPoolByName("pool3")
+	c.Assert(err, check.IsNil)
+	c.Assert(CheckPoolHardwareProfile(pool1, "plan1", "fast"), check.IsNil)
+	c.Assert(CheckPoolHardwareProfile(pool3, "plan1", ""), check.IsNil)
+	err = CheckPoolHardwareProfile(pool3, "plan1", "fast")
+	c.Assert(err, check.DeepEquals, &HardwareProfileMismatchError{
+		Plan:            "plan1",
+		Profile:         "fast",
+		Pool:            "pool3",
+		CompatiblePools: []string{"pool1", "pool2"},
+	})
+	c.Assert(err.Error(), check.Equals, `plan "plan1" requires hardware profile "fast", which is not available in pool "pool3", compatible pools: pool1, pool2`)
+	err = CheckPoolHardwareProfile(pool1, "plan2", "gpu")
+	c.Assert(err.Error(), check.Equals, `plan "plan2" requires hardware profile "gpu", which is not available in pool "pool1", no pools are compatible`)
+}
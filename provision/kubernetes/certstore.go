@@ -0,0 +1,86 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/tsuru/tsuru/router/certstore"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func init() {
+	certstore.Register("kubernetes", func() (certstore.CertificateStore, error) {
+		return &secretCertificateStore{}, nil
+	})
+}
+
+// secretCertificateStore keeps certificates as TLS secrets in the cluster of
+// the app pool.
+type secretCertificateStore struct{}
+
+func certificateSecretName(ref certstore.CertificateRef) string {
+	return fmt.Sprintf("%s-cert-%s", ref.App, ref.CName)
+}
+
+func (s *secretCertificateStore) Save(cert certstore.Certificate) error {
+	client, err := clusterForPool(cert.Pool)
+	if err != nil {
+		return err
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: certificateSecretName(cert.CertificateRef),
+			Labels: map[string]string{
+				tsuruLabelPrefix + "is-tsuru": "true",
+				tsuruLabelPrefix + "app-name": cert.App,
+			},
+		},
+		Type: v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:       []byte(cert.Certificate),
+			v1.TLSPrivateKeyKey: []byte(cert.Key),
+		},
+	}
+	secrets := client.Core().Secrets(client.Namespace())
+	_, err = secrets.Update(secret)
+	if k8sErrors.IsNotFound(err) {
+		_, err = secrets.Create(secret)
+	}
+	return err
+}
+
+func (s *secretCertificateStore) Get(ref certstore.CertificateRef) (*certstore.Certificate, error) {
+	client, err := clusterForPool(ref.Pool)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.Core().Secrets(client.Namespace()).Get(certificateSecretName(ref), metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return nil, certstore.ErrCertificateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &certstore.Certificate{
+		CertificateRef: ref,
+		Certificate:    string(secret.Data[v1.TLSCertKey]),
+		Key:            string(secret.Data[v1.TLSPrivateKeyKey]),
+	}, nil
+}
+
+func (s *secretCertificateStore) Remove(ref certstore.CertificateRef) error {
+	client, err := clusterForPool(ref.Pool)
+	if err != nil {
+		return err
+	}
+	err = client.Core().Secrets(client.Namespace()).Delete(certificateSecretName(ref), &metav1.DeleteOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return certstore.ErrCertificateNotFound
+	}
+	return err
+}
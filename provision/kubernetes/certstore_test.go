@@ -0,0 +1,37 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"github.com/tsuru/tsuru/router/certstore"
+	"gopkg.in/check.v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func (s *S) TestSecretCertificateStore(c *check.C) {
+	store := &secretCertificateStore{}
+	ref := certstore.CertificateRef{App: "myapp", Pool: "bonehunters", CName: "myapp.example.com"}
+	_, err := store.Get(ref)
+	c.Assert(err, check.Equals, certstore.ErrCertificateNotFound)
+	err = store.Save(certstore.Certificate{CertificateRef: ref, Certificate: "cert", Key: "key"})
+	c.Assert(err, check.IsNil)
+	secret, err := s.client.Core().Secrets(s.client.Namespace()).Get("myapp-cert-myapp.example.com", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(secret.Type, check.Equals, v1.SecretTypeTLS)
+	c.Assert(secret.Labels, check.DeepEquals, map[string]string{
+		"tsuru.io/is-tsuru": "true",
+		"tsuru.io/app-name": "myapp",
+	})
+	err = store.Save(certstore.Certificate{CertificateRef: ref, Certificate: "cert2", Key: "key2"})
+	c.Assert(err, check.IsNil)
+	cert, err := store.Get(ref)
+	c.Assert(err, check.IsNil)
+	c.Assert(cert, check.DeepEquals, &certstore.Certificate{CertificateRef: ref, Certificate: "cert2", Key: "key2"})
+	err = store.Remove(ref)
+	c.Assert(err, check.IsNil)
+	_, err = store.Get(ref)
+	c.Assert(err, check.Equals, certstore.ErrCertificateNotFound)
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/dockercommon"
 	"github.com/tsuru/tsuru/provision/servicecommon"
+	"github.com/tsuru/tsuru/registryauth"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -35,6 +36,11 @@ import (
 
 const (
 	dockerSockPath = "/var/run/docker.sock"
+
+	// resourceEphemeralStorage is not available in the vendored client-go
+	// version, it's the resource used by the kubelet to limit and evict pods
+	// based on their local storage usage.
+	resourceEphemeralStorage = v1.ResourceName("ephemeral-storage")
 )
 
 func doAttach(client *clusterClient, stdin io.Reader, stdout io.Writer, podName, container string) error {
@@ -201,6 +207,19 @@ func extraRegisterCmds(a provision.App) string {
 }
 
 func probeFromHC(hc provision.TsuruYamlHealthcheck, port int) (*v1.Probe, error) {
+	err := hc.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if cmd := hc.ExecCommand(port); cmd != "" {
+		return &v1.Probe{
+			Handler: v1.Handler{
+				Exec: &v1.ExecAction{
+					Command: []string{"/bin/sh", "-c", cmd},
+				},
+			},
+		}, nil
+	}
 	if hc.Path == "" {
 		return nil, nil
 	}
@@ -218,45 +237,281 @@ func probeFromHC(hc provision.TsuruYamlHealthcheck, port int) (*v1.Probe, error)
 	}, nil
 }
 
-func createAppDeployment(client *clusterClient, oldDeployment *extensions.Deployment, a provision.App, process, imageName string, replicas int, labels *provision.LabelSet) (*extensions.Deployment, *provision.LabelSet, error) {
-	provision.ExtendServiceLabels(labels, provision.ServiceLabelExtendedOpts{
-		Provisioner: provisionerName,
-		Prefix:      tsuruLabelPrefix,
-	})
-	realReplicas := int32(replicas)
+// podAntiAffinity returns the anti affinity between pods of the same app
+// process set in the scheduler configuration of the app pool. Pools without
+// an explicit anti affinity policy leave placement to the kubernetes
+// scheduler.
+func podAntiAffinity(a provision.App, labels *provision.LabelSet) (*v1.Affinity, error) {
+	sched, err := provision.GetPoolScheduler(a.GetPool())
+	if err != nil {
+		return nil, err
+	}
+	term := v1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{MatchLabels: labels.ToSelector()},
+		TopologyKey:   "kubernetes.io/hostname",
+	}
+	switch sched.AntiAffinity {
+	case provision.AntiAffinityRequired:
+		return &v1.Affinity{
+			PodAntiAffinity: &v1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{term},
+			},
+		}, nil
+	case provision.AntiAffinityPreferred:
+		return &v1.Affinity{
+			PodAntiAffinity: &v1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+					{Weight: 100, PodAffinityTerm: term},
+				},
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+// preemptionNodeAffinity prefers preemptible nodes for units of apps
+// tolerating preemption and keeps units of other apps on stable nodes. Daemon
+// apps run in every node and are ignored.
+func preemptionNodeAffinity(a provision.App) *v1.NodeAffinity {
+	if a.IsDaemon() {
+		return nil
+	}
+	if a.ToleratesPreemption() {
+		return &v1.NodeAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []v1.PreferredSchedulingTerm{
+				{
+					Weight: 100,
+					Preference: v1.NodeSelectorTerm{
+						MatchExpressions: []v1.NodeSelectorRequirement{
+							{Key: provision.PreemptibleMetadataKey, Operator: v1.NodeSelectorOpIn, Values: []string{"true"}},
+						},
+					},
+				},
+			},
+		}
+	}
+	return &v1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{
+				{
+					MatchExpressions: []v1.NodeSelectorRequirement{
+						{Key: provision.PreemptibleMetadataKey, Operator: v1.NodeSelectorOpNotIn, Values: []string{"true"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func appPodTemplate(a provision.App, process, imageName string, labels *provision.LabelSet) (*v1.PodTemplateSpec, error) {
 	extra := []string{extraRegisterCmds(a)}
 	cmds, _, err := dockercommon.LeanContainerCmdsWithExtra(process, imageName, a, extra)
 	if err != nil {
-		return nil, nil, errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 	appEnvs := provision.EnvsForApp(a, process, false)
 	var envs []v1.EnvVar
 	for _, envData := range appEnvs {
 		envs = append(envs, v1.EnvVar{Name: envData.Name, Value: envData.Value})
 	}
-	depName := deploymentNameForApp(a, process)
-	tenRevs := int32(10)
 	yamlData, err := image.GetImageTsuruYamlData(imageName)
 	if err != nil {
-		return nil, nil, errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 	port := provision.WebProcessDefaultPort()
 	portInt, _ := strconv.Atoi(port)
 	probe, err := probeFromHC(yamlData.Healthcheck, portInt)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	maxSurge := intstr.FromString("100%")
-	maxUnavailable := intstr.FromInt(0)
 	nodeSelector := provision.NodeLabels(provision.NodeLabelsOpts{
 		Pool: a.GetPool(),
 	}).ToNodeByPoolSelector()
+	for k, v := range a.GetNodeRequirements() {
+		nodeSelector[k] = v
+	}
 	_, uid := dockercommon.UserForContainer()
 	resourceLimits := v1.ResourceList{}
 	memory := a.GetMemory()
 	if memory != 0 {
 		resourceLimits[v1.ResourceMemory] = *resource.NewQuantity(memory, resource.BinarySI)
 	}
+	storage := a.GetEphemeralStorage()
+	if storage != 0 {
+		resourceLimits[resourceEphemeralStorage] = *resource.NewQuantity(storage, resource.BinarySI)
+	}
+	var gracePeriod *int64
+	if period := a.GetProcessSettings().GracePeriod(process); period > 0 {
+		seconds := int64(period / time.Second)
+		gracePeriod = &seconds
+	}
+	affinity, err := podAntiAffinity(a, labels)
+	if err != nil {
+		return nil, err
+	}
+	if nodeAffinity := preemptionNodeAffinity(a); nodeAffinity != nil {
+		if affinity == nil {
+			affinity = &v1.Affinity{}
+		}
+		affinity.NodeAffinity = nodeAffinity
+	}
+	volumes, mounts := configTemplatesVolumes(a, process)
+	return &v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      labels.ToLabels(),
+			Annotations: a.GetMetricsConfig().Annotations(process),
+		},
+		Spec: v1.PodSpec{
+			SecurityContext: &v1.PodSecurityContext{
+				RunAsUser: uid,
+			},
+			RestartPolicy:                 v1.RestartPolicyAlways,
+			NodeSelector:                  nodeSelector,
+			Affinity:                      affinity,
+			TerminationGracePeriodSeconds: gracePeriod,
+			Volumes:                       volumes,
+			Containers: []v1.Container{
+				{
+					Name:           deploymentNameForApp(a, process),
+					Image:          imageName,
+					Command:        cmds,
+					Env:            envs,
+					ReadinessProbe: probe,
+					Resources: v1.ResourceRequirements{
+						Limits: resourceLimits,
+					},
+					VolumeMounts: mounts,
+				},
+			},
+		},
+	}, nil
+}
+
+// configTemplatesVolumes returns the volume holding the config templates of
+// the app, rendered by syncConfigTemplates, and the mounts of each template
+// in its path.
+func configTemplatesVolumes(a provision.App, process string) ([]v1.Volume, []v1.VolumeMount) {
+	templates := a.GetConfigTemplates()
+	if len(templates) == 0 {
+		return nil, nil
+	}
+	volumeName := "config-templates"
+	mounts := make([]v1.VolumeMount, len(templates))
+	for i, t := range templates {
+		mounts[i] = v1.VolumeMount{
+			Name:      volumeName,
+			MountPath: t.Path,
+			SubPath:   t.Name,
+			ReadOnly:  true,
+		}
+	}
+	volumes := []v1.Volume{
+		{
+			Name: volumeName,
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: configMapNameForApp(a, process),
+					},
+				},
+			},
+		},
+	}
+	return volumes, mounts
+}
+
+// syncConfigTemplates renders the config templates of the process in a config
+// map, removing the config map when the app has no templates.
+func syncConfigTemplates(client *clusterClient, a provision.App, process string) error {
+	name := configMapNameForApp(a, process)
+	rendered, err := provision.RenderConfigTemplates(a, process, a.GetConfigTemplates())
+	if err != nil {
+		return err
+	}
+	if len(rendered) == 0 {
+		err = client.Core().ConfigMaps(client.Namespace()).Delete(name, &metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	configMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: client.Namespace(),
+		},
+		Data: map[string]string{},
+	}
+	for _, t := range rendered {
+		configMap.Data[t.Name] = t.Content
+	}
+	_, err = client.Core().ConfigMaps(client.Namespace()).Update(&configMap)
+	if k8sErrors.IsNotFound(err) {
+		_, err = client.Core().ConfigMaps(client.Namespace()).Create(&configMap)
+	}
+	return errors.WithStack(err)
+}
+
+// syncRegistrySecret stores the credentials issued by the registry broker to
+// pull the image of the app in an image pull secret, returning the references
+// to be used in the pods of the app. Anonymous pulls use no secret.
+func syncRegistrySecret(client *clusterClient, a provision.App, imageName string) ([]v1.LocalObjectReference, error) {
+	creds, err := registryauth.PullCredentials(imageName)
+	if err != nil {
+		return nil, err
+	}
+	name := registrySecretNameForApp(a)
+	if creds.Empty() {
+		err = client.Core().Secrets(client.Namespace()).Delete(name, &metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return nil, errors.WithStack(err)
+		}
+		return nil, nil
+	}
+	data, err := creds.DockerConfigJSON()
+	if err != nil {
+		return nil, err
+	}
+	secret := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: client.Namespace(),
+		},
+		Type: v1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{v1.DockerConfigJsonKey: data},
+	}
+	_, err = client.Core().Secrets(client.Namespace()).Update(&secret)
+	if k8sErrors.IsNotFound(err) {
+		_, err = client.Core().Secrets(client.Namespace()).Create(&secret)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return []v1.LocalObjectReference{{Name: name}}, nil
+}
+
+func createAppDeployment(client *clusterClient, oldDeployment *extensions.Deployment, a provision.App, process, imageName string, replicas int, labels *provision.LabelSet) (*extensions.Deployment, *provision.LabelSet, error) {
+	provision.ExtendServiceLabels(labels, provision.ServiceLabelExtendedOpts{
+		Provisioner: provisionerName,
+		Prefix:      tsuruLabelPrefix,
+	})
+	realReplicas := int32(replicas)
+	depName := deploymentNameForApp(a, process)
+	tenRevs := int32(10)
+	template, err := appPodTemplate(a, process, imageName, labels)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = syncConfigTemplates(client, a, process)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.Spec.ImagePullSecrets, err = syncRegistrySecret(client, a, imageName)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxSurge := intstr.FromString("100%")
+	maxUnavailable := intstr.FromInt(0)
 	deployment := extensions.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      depName,
@@ -275,30 +530,7 @@ func createAppDeployment(client *clusterClient, oldDeployment *extensions.Deploy
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels.ToSelector(),
 			},
-			Template: v1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels.ToLabels(),
-				},
-				Spec: v1.PodSpec{
-					SecurityContext: &v1.PodSecurityContext{
-						RunAsUser: uid,
-					},
-					RestartPolicy: v1.RestartPolicyAlways,
-					NodeSelector:  nodeSelector,
-					Containers: []v1.Container{
-						{
-							Name:           depName,
-							Image:          imageName,
-							Command:        cmds,
-							Env:            envs,
-							ReadinessProbe: probe,
-							Resources: v1.ResourceRequirements{
-								Limits: resourceLimits,
-							},
-						},
-					},
-				},
-			},
+			Template: *template,
 		},
 	}
 	var newDep *extensions.Deployment
@@ -310,6 +542,53 @@ func createAppDeployment(client *clusterClient, oldDeployment *extensions.Deploy
 	return newDep, labels, errors.WithStack(err)
 }
 
+// createAppDaemonSet runs the process of a daemon app in every node of the app
+// pool. Stopped daemon apps keep their daemon set, with a node selector that
+// matches no node, so the state labels of the process are preserved.
+func createAppDaemonSet(client *clusterClient, oldDaemonSet *extensions.DaemonSet, a provision.App, process, imageName string, replicas int, labels *provision.LabelSet) (*provision.LabelSet, error) {
+	provision.ExtendServiceLabels(labels, provision.ServiceLabelExtendedOpts{
+		Provisioner: provisionerName,
+		Prefix:      tsuruLabelPrefix,
+	})
+	template, err := appPodTemplate(a, process, imageName, labels)
+	if err != nil {
+		return nil, err
+	}
+	err = syncConfigTemplates(client, a, process)
+	if err != nil {
+		return nil, err
+	}
+	template.Spec.ImagePullSecrets, err = syncRegistrySecret(client, a, imageName)
+	if err != nil {
+		return nil, err
+	}
+	if replicas == 0 {
+		template.Spec.NodeSelector[tsuruLabelPrefix+"daemon-stopped"] = "true"
+	}
+	ds := extensions.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploymentNameForApp(a, process),
+			Namespace: client.Namespace(),
+		},
+		Spec: extensions.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels.ToSelector(),
+			},
+			Template: *template,
+		},
+	}
+	if oldDaemonSet != nil {
+		// Like node containers, daemon sets are recreated because
+		// kubernetes <=1.5 does not support rolling updating them.
+		err = cleanupAppDaemonSet(client, a, process)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = client.Extensions().DaemonSets(client.Namespace()).Create(&ds)
+	return labels, errors.WithStack(err)
+}
+
 type serviceManager struct {
 	client *clusterClient
 	writer io.Writer
@@ -323,6 +602,12 @@ func (m *serviceManager) RemoveService(a provision.App, process string) error {
 	if err != nil && !k8sErrors.IsNotFound(err) {
 		multiErrors.Add(err)
 	}
+	if a.IsDaemon() {
+		err = cleanupAppDaemonSet(m.client, a, process)
+		if err != nil {
+			multiErrors.Add(err)
+		}
+	}
 	depName := deploymentNameForApp(a, process)
 	err = m.client.Core().Services(m.client.Namespace()).Delete(depName, &metav1.DeleteOptions{
 		PropagationPolicy: propagationPtr(metav1.DeletePropagationForeground),
@@ -330,6 +615,10 @@ func (m *serviceManager) RemoveService(a provision.App, process string) error {
 	if err != nil && !k8sErrors.IsNotFound(err) {
 		multiErrors.Add(errors.WithStack(err))
 	}
+	err = m.client.Core().ConfigMaps(m.client.Namespace()).Delete(configMapNameForApp(a, process), &metav1.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		multiErrors.Add(errors.WithStack(err))
+	}
 	if multiErrors.Len() > 0 {
 		return multiErrors
 	}
@@ -338,6 +627,16 @@ func (m *serviceManager) RemoveService(a provision.App, process string) error {
 
 func (m *serviceManager) CurrentLabels(a provision.App, process string) (*provision.LabelSet, error) {
 	depName := deploymentNameForApp(a, process)
+	if a.IsDaemon() {
+		ds, err := m.client.Extensions().DaemonSets(m.client.Namespace()).Get(depName, metav1.GetOptions{})
+		if err != nil {
+			if k8sErrors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, errors.WithStack(err)
+		}
+		return labelSetFromMeta(&ds.Spec.Template.ObjectMeta), nil
+	}
 	dep, err := m.client.Extensions().Deployments(m.client.Namespace()).Get(depName, metav1.GetOptions{})
 	if err != nil {
 		if k8sErrors.IsNotFound(err) {
@@ -440,29 +739,13 @@ func monitorDeployment(client *clusterClient, dep *extensions.Deployment, a prov
 
 func (m *serviceManager) DeployService(a provision.App, process string, labels *provision.LabelSet, replicas int, image string) error {
 	depName := deploymentNameForApp(a, process)
-	dep, err := m.client.Extensions().Deployments(m.client.Namespace()).Get(depName, metav1.GetOptions{})
-	if err != nil {
-		if !k8sErrors.IsNotFound(err) {
-			return errors.WithStack(err)
-		}
-		dep = nil
-	}
-	dep, labels, err = createAppDeployment(m.client, dep, a, process, image, replicas, labels)
-	if err != nil {
-		return err
-	}
-	if m.writer == nil {
-		m.writer = ioutil.Discard
+	var err error
+	if a.IsDaemon() {
+		labels, err = m.deployDaemonSet(a, process, labels, replicas, image)
+	} else {
+		labels, err = m.deployDeployment(a, process, labels, replicas, image)
 	}
-	err = monitorDeployment(m.client, dep, a, process, m.writer)
 	if err != nil {
-		fmt.Fprintf(m.writer, "\n**** ROLLING BACK AFTER FAILURE ****\n ---> %s <---\n", err)
-		rollbackErr := m.client.Extensions().Deployments(m.client.Namespace()).Rollback(&extensions.DeploymentRollback{
-			Name: depName,
-		})
-		if rollbackErr != nil {
-			fmt.Fprintf(m.writer, "\n**** ERROR DURING ROLLBACK ****\n ---> %s <---\n", rollbackErr)
-		}
 		return err
 	}
 	port := provision.WebProcessDefaultPort()
@@ -491,6 +774,48 @@ func (m *serviceManager) DeployService(a provision.App, process string, labels *
 	return err
 }
 
+func (m *serviceManager) deployDeployment(a provision.App, process string, labels *provision.LabelSet, replicas int, image string) (*provision.LabelSet, error) {
+	depName := deploymentNameForApp(a, process)
+	dep, err := m.client.Extensions().Deployments(m.client.Namespace()).Get(depName, metav1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return nil, errors.WithStack(err)
+		}
+		dep = nil
+	}
+	dep, labels, err = createAppDeployment(m.client, dep, a, process, image, replicas, labels)
+	if err != nil {
+		return nil, err
+	}
+	if m.writer == nil {
+		m.writer = ioutil.Discard
+	}
+	err = monitorDeployment(m.client, dep, a, process, m.writer)
+	if err != nil {
+		fmt.Fprintf(m.writer, "\n**** ROLLING BACK AFTER FAILURE ****\n ---> %s <---\n", err)
+		rollbackErr := m.client.Extensions().Deployments(m.client.Namespace()).Rollback(&extensions.DeploymentRollback{
+			Name: depName,
+		})
+		if rollbackErr != nil {
+			fmt.Fprintf(m.writer, "\n**** ERROR DURING ROLLBACK ****\n ---> %s <---\n", rollbackErr)
+		}
+		return nil, err
+	}
+	return labels, nil
+}
+
+func (m *serviceManager) deployDaemonSet(a provision.App, process string, labels *provision.LabelSet, replicas int, image string) (*provision.LabelSet, error) {
+	dsName := deploymentNameForApp(a, process)
+	ds, err := m.client.Extensions().DaemonSets(m.client.Namespace()).Get(dsName, metav1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return nil, errors.WithStack(err)
+		}
+		ds = nil
+	}
+	return createAppDaemonSet(m.client, ds, a, process, image, replicas, labels)
+}
+
 func procfileInspectPod(client *clusterClient, a provision.App, image string) (string, error) {
 	deployPodName := deployPodNameForApp(a)
 	labels, err := provision.ServiceLabels(provision.ServiceLabelsOpts{
@@ -504,15 +829,20 @@ func procfileInspectPod(client *clusterClient, a provision.App, image string) (s
 	if err != nil {
 		return "", err
 	}
+	pullSecrets, err := syncRegistrySecret(client, a, image)
+	if err != nil {
+		return "", err
+	}
 	cmds := []string{"sh", "-c", "(cat /home/application/current/Procfile || cat /app/user/Procfile || cat /Procfile || true) 2>/dev/null"}
 	buf := &bytes.Buffer{}
 	err = runPod(runSinglePodArgs{
-		client: client,
-		stdout: buf,
-		labels: labels,
-		cmds:   cmds,
-		name:   deployPodName,
-		image:  image,
+		client:      client,
+		stdout:      buf,
+		labels:      labels,
+		cmds:        cmds,
+		name:        deployPodName,
+		image:       image,
+		pullSecrets: pullSecrets,
 	})
 	if err != nil {
 		return "", errors.Wrapf(err, "unable to inspect Procfile: %q", buf.String())
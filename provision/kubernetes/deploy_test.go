@@ -13,7 +13,9 @@ import (
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/servicecommon"
+	"github.com/tsuru/tsuru/registryauth/registryauthtest"
 	"gopkg.in/check.v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -98,6 +100,19 @@ func (s *S) TestServiceManagerDeployService(c *check.C) {
 					NodeSelector: map[string]string{
 						"pool": "bonehunters",
 					},
+					Affinity: &v1.Affinity{
+						NodeAffinity: &v1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+								NodeSelectorTerms: []v1.NodeSelectorTerm{
+									{
+										MatchExpressions: []v1.NodeSelectorRequirement{
+											{Key: "preemptible", Operator: v1.NodeSelectorOpNotIn, Values: []string{"true"}},
+										},
+									},
+								},
+							},
+						},
+					},
 					RestartPolicy: "Always",
 					Containers: []v1.Container{
 						{
@@ -332,6 +347,58 @@ func (s *S) TestServiceManagerDeployServiceWithHC(c *check.C) {
 	})
 }
 
+func (s *S) TestServiceManagerDeployServiceWithGRPCHC(c *check.C) {
+	waitDep := s.deploymentReactions(c)
+	defer waitDep()
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
+		},
+		"healthcheck": provision.TsuruYamlHealthcheck{
+			GRPC: &provision.TsuruYamlGRPCHealthcheck{Service: "myservice"},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", servicecommon.ProcessSpec{
+		"p1": servicecommon.ProcessState{Start: true},
+	})
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.Containers[0].ReadinessProbe, check.DeepEquals, &v1.Probe{
+		Handler: v1.Handler{
+			Exec: &v1.ExecAction{
+				Command: []string{"/bin/sh", "-c", "grpc_health_probe -addr=localhost:8888 -service=myservice"},
+			},
+		},
+	})
+}
+
+func (s *S) TestServiceManagerDeployServiceWithConflictingHC(c *check.C) {
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
+		},
+		"healthcheck": provision.TsuruYamlHealthcheck{
+			Path:    "/hc",
+			Command: "check-health",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", servicecommon.ProcessSpec{
+		"p1": servicecommon.ProcessState{Start: true},
+	})
+	c.Assert(err, check.Equals, provision.ErrHealthcheckConflict)
+}
+
 func (s *S) TestServiceManagerDeployServiceWithHCInvalidMethod(c *check.C) {
 	m := serviceManager{client: s.client.clusterClient}
 	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
@@ -388,7 +455,7 @@ func (s *S) TestServiceManagerDeployServiceWithLimits(c *check.C) {
 	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
 	err := app.CreateApp(a, s.user)
 	c.Assert(err, check.IsNil)
-	a.Plan = app.Plan{Memory: 1024}
+	a.Plan = app.Plan{Memory: 1024, EphemeralStorage: 8388608}
 	err = image.SaveImageCustomData("myimg", map[string]interface{}{
 		"processes": map[string]interface{}{
 			"p1": "cm1",
@@ -402,11 +469,283 @@ func (s *S) TestServiceManagerDeployServiceWithLimits(c *check.C) {
 	dep, err := s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
 	c.Assert(err, check.IsNil)
 	expectedMemory := resource.NewQuantity(1024, resource.BinarySI)
+	expectedStorage := resource.NewQuantity(8388608, resource.BinarySI)
 	c.Assert(dep.Spec.Template.Spec.Containers[0].Resources, check.DeepEquals, v1.ResourceRequirements{
 		Limits: v1.ResourceList{
-			v1.ResourceMemory: *expectedMemory,
+			v1.ResourceMemory:        *expectedMemory,
+			resourceEphemeralStorage: *expectedStorage,
+		},
+	})
+}
+
+func (s *S) TestServiceManagerDeployServiceWithNodeRequirements(c *check.C) {
+	waitDep := s.deploymentReactions(c)
+	defer waitDep()
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	a.NodeRequirements = map[string]string{"disk": "ssd", "network": "dmz"}
+	err = image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
 		},
 	})
+	c.Assert(err, check.IsNil)
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", servicecommon.ProcessSpec{
+		"p1": servicecommon.ProcessState{Start: true},
+	})
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.NodeSelector, check.DeepEquals, map[string]string{
+		"pool":    a.Pool,
+		"disk":    "ssd",
+		"network": "dmz",
+	})
+}
+
+func (s *S) TestServiceManagerDeployServiceWithPreemptionTolerance(c *check.C) {
+	waitDep := s.deploymentReactions(c)
+	defer waitDep()
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	a.PreemptionTolerance = app.PreemptionTolerate
+	err = image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", servicecommon.ProcessSpec{
+		"p1": servicecommon.ProcessState{Start: true},
+	})
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.Affinity, check.DeepEquals, &v1.Affinity{
+		NodeAffinity: &v1.NodeAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []v1.PreferredSchedulingTerm{
+				{
+					Weight: 100,
+					Preference: v1.NodeSelectorTerm{
+						MatchExpressions: []v1.NodeSelectorRequirement{
+							{Key: "preemptible", Operator: v1.NodeSelectorOpIn, Values: []string{"true"}},
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func (s *S) TestServiceManagerDeployServiceWithProcessSettings(c *check.C) {
+	waitDep := s.deploymentReactions(c)
+	defer waitDep()
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	a.ProcessSettings = provision.ProcessSettings{
+		StartOrder:   []string{"p2"},
+		GracePeriods: map[string]int{"p1": 45},
+	}
+	err = image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
+			"p2": "cm2",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	var created []string
+	s.client.PrependReactor("create", "deployments", func(action ktesting.Action) (bool, runtime.Object, error) {
+		dep := action.(ktesting.CreateAction).GetObject().(*extensions.Deployment)
+		created = append(created, dep.Name)
+		return false, nil, nil
+	})
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(created, check.DeepEquals, []string{"myapp-p2", "myapp-p1"})
+	dep, err := s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.TerminationGracePeriodSeconds, check.NotNil)
+	c.Assert(*dep.Spec.Template.Spec.TerminationGracePeriodSeconds, check.Equals, int64(45))
+	dep, err = s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p2", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.TerminationGracePeriodSeconds, check.IsNil)
+}
+
+func (s *S) TestServiceManagerDeployServiceWithMetricsConfig(c *check.C) {
+	waitDep := s.deploymentReactions(c)
+	defer waitDep()
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	a.Metrics = provision.MetricsConfig{Port: 9100, Path: "/stats", Process: "p1"}
+	err = image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
+			"p2": "cm2",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", nil)
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.ObjectMeta.Annotations, check.DeepEquals, map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   "9100",
+		"prometheus.io/path":   "/stats",
+	})
+	dep, err = s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p2", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.ObjectMeta.Annotations, check.IsNil)
+}
+
+func (s *S) TestServiceManagerDeployServiceWithConfigTemplates(c *check.C) {
+	waitDep := s.deploymentReactions(c)
+	defer waitDep()
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	a.ConfigTemplates = []provision.ConfigTemplate{
+		{Name: "app.ini", Path: "/etc/app.ini", Content: "process={{.Process}}"},
+	}
+	err = image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", nil)
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.Volumes, check.DeepEquals, []v1.Volume{
+		{
+			Name: "config-templates",
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: "myapp-p1-config"},
+				},
+			},
+		},
+	})
+	c.Assert(dep.Spec.Template.Spec.Containers[0].VolumeMounts, check.DeepEquals, []v1.VolumeMount{
+		{Name: "config-templates", MountPath: "/etc/app.ini", SubPath: "app.ini", ReadOnly: true},
+	})
+	configMap, err := s.client.Core().ConfigMaps(s.client.Namespace()).Get("myapp-p1-config", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(configMap.Data, check.DeepEquals, map[string]string{"app.ini": "process=p1"})
+	err = m.RemoveService(a, "p1")
+	c.Assert(err, check.IsNil)
+	_, err = s.client.Core().ConfigMaps(s.client.Namespace()).Get("myapp-p1-config", metav1.GetOptions{})
+	c.Assert(k8sErrors.IsNotFound(err), check.Equals, true)
+}
+
+func (s *S) TestServiceManagerDeployServiceWithRegistryCredentials(c *check.C) {
+	config.Set("docker:registry", "registry.example.com")
+	defer config.Unset("docker:registry")
+	config.Set("registry-auth:broker", "fake")
+	defer config.Unset("registry-auth:broker")
+	defer registryauthtest.Reset()
+	waitDep := s.deploymentReactions(c)
+	defer waitDep()
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	imgName := "registry.example.com/tsuru/app-myapp:v1"
+	err = image.SaveImageCustomData(imgName, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = servicecommon.RunServicePipeline(&m, a, imgName, nil)
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.ImagePullSecrets, check.DeepEquals, []v1.LocalObjectReference{
+		{Name: "myapp-registry"},
+	})
+	secret, err := s.client.Core().Secrets(s.client.Namespace()).Get("myapp-registry", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(secret.Type, check.Equals, v1.SecretTypeDockerConfigJson)
+	c.Assert(string(secret.Data[v1.DockerConfigJsonKey]), check.Matches, `.*"registry.example.com":\{"username":"tsuru/app-myapp","password":"token-tsuru/app-myapp".*`)
+	requests := registryauthtest.Requests()
+	c.Assert(requests, check.HasLen, 1)
+	c.Assert(requests[0].Repository, check.Equals, "tsuru/app-myapp")
+}
+
+func (s *S) TestServiceManagerDeployServiceAnonymousRegistry(c *check.C) {
+	waitDep := s.deploymentReactions(c)
+	defer waitDep()
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", nil)
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.ImagePullSecrets, check.IsNil)
+	_, err = s.client.Core().Secrets(s.client.Namespace()).Get("myapp-registry", metav1.GetOptions{})
+	c.Assert(k8sErrors.IsNotFound(err), check.Equals, true)
+}
+
+func (s *S) TestServiceManagerDeployServiceDaemonApp(c *check.C) {
+	m := serviceManager{client: s.client.clusterClient}
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name, Daemon: true}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"p1": "cm1",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", servicecommon.ProcessSpec{
+		"p1": servicecommon.ProcessState{Start: true},
+	})
+	c.Assert(err, check.IsNil)
+	_, err = s.client.Extensions().Deployments(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(k8sErrors.IsNotFound(err), check.Equals, true)
+	ds, err := s.client.Extensions().DaemonSets(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(ds.Spec.Template.Spec.NodeSelector, check.DeepEquals, map[string]string{"pool": a.Pool})
+	c.Assert(ds.Spec.Template.Spec.Containers[0].Image, check.Equals, "myimg")
+	labels, err := m.CurrentLabels(a, "p1")
+	c.Assert(err, check.IsNil)
+	c.Assert(labels.IsStopped(), check.Equals, false)
+	err = servicecommon.RunServicePipeline(&m, a, "myimg", servicecommon.ProcessSpec{
+		"p1": servicecommon.ProcessState{Stop: true},
+	})
+	c.Assert(err, check.IsNil)
+	ds, err = s.client.Extensions().DaemonSets(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(ds.Spec.Template.Spec.NodeSelector, check.DeepEquals, map[string]string{
+		"pool":                    a.Pool,
+		"tsuru.io/daemon-stopped": "true",
+	})
+	labels, err = m.CurrentLabels(a, "p1")
+	c.Assert(err, check.IsNil)
+	c.Assert(labels.IsStopped(), check.Equals, true)
+	err = m.RemoveService(a, "p1")
+	c.Assert(err, check.IsNil)
+	_, err = s.client.Extensions().DaemonSets(s.client.Namespace()).Get("myapp-p1", metav1.GetOptions{})
+	c.Assert(k8sErrors.IsNotFound(err), check.Equals, true)
 }
 
 func (s *S) prepareRollbackTest(c *check.C) (*serviceManager, **extensions.DeploymentRollback, func()) {
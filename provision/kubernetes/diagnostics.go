@@ -0,0 +1,52 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/provision"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// nodeConditionChecks maps the checks reported by DiagnoseNode to the node
+// conditions they're based on and the status expected for healthy nodes.
+var nodeConditionChecks = []struct {
+	name      string
+	condition v1.NodeConditionType
+	healthy   v1.ConditionStatus
+}{
+	{"kubelet", v1.NodeReady, v1.ConditionTrue},
+	{"disk", v1.NodeDiskPressure, v1.ConditionFalse},
+	{"memory", v1.NodeMemoryPressure, v1.ConditionFalse},
+	{"network", v1.NodeNetworkUnavailable, v1.ConditionFalse},
+}
+
+// DiagnoseNode reports the status of the kubelet, disk, memory and network of
+// the node, based on the latest conditions reported by the kubelet.
+// Conditions not reported by the kubelet are skipped.
+func (p *kubernetesProvisioner) DiagnoseNode(address string) ([]provision.NodeCheckResult, error) {
+	_, nodeWrapper, err := p.findNodeByAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	conditions := map[v1.NodeConditionType]v1.NodeCondition{}
+	for _, cond := range nodeWrapper.node.Status.Conditions {
+		conditions[cond.Type] = cond
+	}
+	var results []provision.NodeCheckResult
+	for _, check := range nodeConditionChecks {
+		cond, ok := conditions[check.condition]
+		if !ok {
+			continue
+		}
+		results = append(results, provision.RunNodeCheck(check.name, func() error {
+			if cond.Status != check.healthy {
+				return errors.Errorf("condition %s is %s: %s", cond.Type, cond.Status, cond.Message)
+			}
+			return nil
+		}))
+	}
+	return results, nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func (s *S) TestDiagnoseNode(c *check.C) {
+	_, err := s.client.Core().Nodes().Create(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "n1"},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.99.1"},
+			},
+			Conditions: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: v1.ConditionTrue},
+				{Type: v1.NodeDiskPressure, Status: v1.ConditionTrue, Message: "kubelet has disk pressure"},
+				{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse},
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	results, err := s.p.DiagnoseNode("192.168.99.1")
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 3)
+	c.Assert(results[0].Name, check.Equals, "kubelet")
+	c.Assert(results[0].Successful, check.Equals, true)
+	c.Assert(results[1].Name, check.Equals, "disk")
+	c.Assert(results[1].Successful, check.Equals, false)
+	c.Assert(results[1].Err, check.Equals, "condition DiskPressure is True: kubelet has disk pressure")
+	c.Assert(results[2].Name, check.Equals, "memory")
+	c.Assert(results[2].Successful, check.Equals, true)
+}
+
+func (s *S) TestDiagnoseNodeNotFound(c *check.C) {
+	s.mockfakeNodes(c)
+	_, err := s.p.DiagnoseNode("192.168.99.9")
+	c.Assert(err, check.Equals, provision.ErrNodeNotFound)
+}
@@ -32,6 +32,14 @@ func deploymentNameForApp(a provision.App, process string) string {
 	return fmt.Sprintf("%s-%s", a.GetName(), process)
 }
 
+func configMapNameForApp(a provision.App, process string) string {
+	return fmt.Sprintf("%s-%s-config", a.GetName(), process)
+}
+
+func registrySecretNameForApp(a provision.App) string {
+	return fmt.Sprintf("%s-registry", a.GetName())
+}
+
 func deployPodNameForApp(a provision.App) string {
 	return fmt.Sprintf("%s-deploy", a.GetName())
 }
@@ -211,6 +219,30 @@ func cleanupDeployment(client *clusterClient, a provision.App, process string) e
 	})
 }
 
+func cleanupAppDaemonSet(client *clusterClient, a provision.App, process string) error {
+	dsName := deploymentNameForApp(a, process)
+	err := client.Extensions().DaemonSets(client.Namespace()).Delete(dsName, &metav1.DeleteOptions{
+		PropagationPolicy: propagationPtr(metav1.DeletePropagationForeground),
+	})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return errors.WithStack(err)
+	}
+	l, err := provision.ServiceLabels(provision.ServiceLabelsOpts{
+		App:     a,
+		Process: process,
+		ServiceLabelExtendedOpts: provision.ServiceLabelExtendedOpts{
+			Prefix:      tsuruLabelPrefix,
+			Provisioner: provisionerName,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return cleanupPods(client, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set(l.ToSelector())).String(),
+	})
+}
+
 func cleanupDaemonSet(client *clusterClient, name, pool string) error {
 	dsName := daemonSetName(name, pool)
 	err := client.Extensions().DaemonSets(client.Namespace()).Delete(dsName, &metav1.DeleteOptions{
@@ -383,14 +415,15 @@ func execCommand(opts execOpts) error {
 }
 
 type runSinglePodArgs struct {
-	client     *clusterClient
-	stdout     io.Writer
-	labels     *provision.LabelSet
-	cmds       []string
-	envs       []v1.EnvVar
-	name       string
-	image      string
-	dockerSock bool
+	client      *clusterClient
+	stdout      io.Writer
+	labels      *provision.LabelSet
+	cmds        []string
+	envs        []v1.EnvVar
+	name        string
+	image       string
+	dockerSock  bool
+	pullSecrets []v1.LocalObjectReference
 }
 
 func runPod(args runSinglePodArgs) error {
@@ -401,7 +434,8 @@ func runPod(args runSinglePodArgs) error {
 			Labels:    args.labels.ToLabels(),
 		},
 		Spec: v1.PodSpec{
-			RestartPolicy: v1.RestartPolicyNever,
+			RestartPolicy:    v1.RestartPolicyNever,
+			ImagePullSecrets: args.pullSecrets,
 			Containers: []v1.Container{
 				{
 					Name:    args.name,
@@ -23,6 +23,7 @@ import (
 	"github.com/tsuru/tsuru/provision/dockercommon"
 	"github.com/tsuru/tsuru/provision/servicecommon"
 	"github.com/tsuru/tsuru/set"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/pkg/api/v1"
@@ -50,6 +51,8 @@ var (
 	_ provision.MessageProvisioner       = &kubernetesProvisioner{}
 	_ provision.SleepableProvisioner     = &kubernetesProvisioner{}
 	_ provision.ImageDeployer            = &kubernetesProvisioner{}
+	_ provision.PoolCapacityProvisioner  = &kubernetesProvisioner{}
+	_ provision.UnitEventsProvisioner    = &kubernetesProvisioner{}
 	// _ provision.ArchiveDeployer          = &kubernetesProvisioner{}
 	// _ provision.InitializableProvisioner = &kubernetesProvisioner{}
 	// _ provision.RollbackableDeployer     = &kubernetesProvisioner{}
@@ -98,6 +101,10 @@ func (p *kubernetesProvisioner) Destroy(a provision.App) error {
 			multiErrors.Add(err)
 		}
 	}
+	err = client.Core().Secrets(client.Namespace()).Delete(registrySecretNameForApp(a), &metav1.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		multiErrors.Add(errors.WithStack(err))
+	}
 	if multiErrors.Len() > 0 {
 		return multiErrors
 	}
@@ -154,6 +161,34 @@ var stateMap = map[v1.PodPhase]provision.Status{
 	v1.PodUnknown:   provision.StatusError,
 }
 
+// podEviction returns the eviction of the pod when it was evicted from its
+// node or when one of its containers was killed for running out of memory.
+func podEviction(pod *v1.Pod) *provision.UnitEviction {
+	if pod.Status.Reason == provision.EvictionReasonEvicted {
+		return &provision.UnitEviction{
+			Unit:    pod.Name,
+			Node:    pod.Spec.NodeName,
+			Reason:  provision.EvictionReasonEvicted,
+			Message: pod.Status.Message,
+			Time:    pod.CreationTimestamp.Time,
+		}
+	}
+	for _, contStatus := range pod.Status.ContainerStatuses {
+		for _, state := range []v1.ContainerState{contStatus.State, contStatus.LastTerminationState} {
+			if state.Terminated != nil && state.Terminated.Reason == provision.EvictionReasonOOMKilled {
+				return &provision.UnitEviction{
+					Unit:    pod.Name,
+					Node:    pod.Spec.NodeName,
+					Reason:  provision.EvictionReasonOOMKilled,
+					Message: state.Terminated.Message,
+					Time:    state.Terminated.FinishedAt.Time,
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (p *kubernetesProvisioner) podsToUnits(client *clusterClient, pods []v1.Pod, baseApp provision.App, baseNode *v1.Node) ([]provision.Unit, error) {
 	var err error
 	if len(pods) == 0 {
@@ -229,6 +264,13 @@ func (p *kubernetesProvisioner) podsToUnits(client *clusterClient, pods []v1.Pod
 			Status:      stateMap[pod.Status.Phase],
 			Address:     url,
 		}
+		if eviction := podEviction(&pod); eviction != nil {
+			units[i].StatusReason = eviction.Reason
+			err = app.NotifyUnitEviction(l.AppName(), *eviction)
+			if err != nil {
+				log.Errorf("unable to notify eviction of pod %q: %v", pod.Name, err)
+			}
+		}
 	}
 	return units, nil
 }
@@ -257,6 +299,65 @@ func (p *kubernetesProvisioner) Units(a provision.App) ([]provision.Unit, error)
 	return p.podsToUnits(client, pods.Items, a, nil)
 }
 
+// UnitsEvents returns the events of the pods of the app, like scheduling
+// failures and image pull errors, seen since the given time.
+func (p *kubernetesProvisioner) UnitsEvents(a provision.App, since time.Time) ([]provision.UnitEvent, error) {
+	client, err := clusterForPool(a.GetPool())
+	if err != nil {
+		return nil, err
+	}
+	l, err := provision.ServiceLabels(provision.ServiceLabelsOpts{
+		App: a,
+		ServiceLabelExtendedOpts: provision.ServiceLabelExtendedOpts{
+			Prefix:      tsuruLabelPrefix,
+			Provisioner: provisionerName,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.Core().Pods(client.Namespace()).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set(l.ToAppSelector())).String(),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, nil
+	}
+	podNames := set.Set{}
+	for _, pod := range pods.Items {
+		podNames.Add(pod.Name)
+	}
+	events, err := client.Core().Events(client.Namespace()).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var unitEvents []provision.UnitEvent
+	for _, evt := range events.Items {
+		if evt.InvolvedObject.Kind != "Pod" || !podNames.Includes(evt.InvolvedObject.Name) {
+			continue
+		}
+		lastSeen := evt.LastTimestamp.Time
+		if lastSeen.IsZero() {
+			lastSeen = evt.FirstTimestamp.Time
+		}
+		if lastSeen.Before(since) {
+			continue
+		}
+		unitEvents = append(unitEvents, provision.UnitEvent{
+			Unit:      evt.InvolvedObject.Name,
+			Type:      evt.Type,
+			Reason:    evt.Reason,
+			Message:   evt.Message,
+			Count:     int(evt.Count),
+			FirstSeen: evt.FirstTimestamp.Time,
+			LastSeen:  lastSeen,
+		})
+	}
+	return unitEvents, nil
+}
+
 func (p *kubernetesProvisioner) RoutableAddresses(a provision.App) ([]url.URL, error) {
 	client, err := clusterForPool(a.GetPool())
 	if err != nil {
@@ -298,6 +399,30 @@ func (p *kubernetesProvisioner) RoutableAddresses(a provision.App) ([]url.URL, e
 	return addrs, nil
 }
 
+// KillUnit deletes the pod of the unit without waiting for a graceful
+// shutdown, the pod is then recreated by its controller.
+func (p *kubernetesProvisioner) KillUnit(a provision.App, unitID string) error {
+	client, err := clusterForPool(a.GetPool())
+	if err != nil {
+		return err
+	}
+	pod, err := client.Core().Pods(client.Namespace()).Get(unitID, metav1.GetOptions{})
+	if err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return &provision.UnitNotFoundError{ID: unitID}
+		}
+		return errors.WithStack(err)
+	}
+	if labelSetFromMeta(&pod.ObjectMeta).AppName() != a.GetName() {
+		return &provision.UnitNotFoundError{ID: unitID}
+	}
+	var gracePeriod int64
+	err = client.Core().Pods(client.Namespace()).Delete(unitID, &metav1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriod,
+	})
+	return errors.WithStack(err)
+}
+
 func (p *kubernetesProvisioner) RegisterUnit(a provision.App, unitID string, customData map[string]interface{}) error {
 	client, err := clusterForPool(a.GetPool())
 	if err != nil {
@@ -375,6 +500,59 @@ func (p *kubernetesProvisioner) listNodesForCluster(cluster *clusterClient, addr
 	return nodes, nil
 }
 
+// PoolCapacity sums the allocatable memory of the nodes of the pool and the
+// memory limits of the app pods running in it.
+func (p *kubernetesProvisioner) PoolCapacity(pool string) (*provision.PoolCapacity, error) {
+	client, err := clusterForPool(pool)
+	if err != nil {
+		return nil, err
+	}
+	nodeSelector := provision.NodeLabels(provision.NodeLabelsOpts{
+		Pool: pool,
+	}).ToNodeByPoolSelector()
+	nodeList, err := client.Core().Nodes().List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set(nodeSelector)).String(),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var capacity provision.PoolCapacity
+	for _, n := range nodeList.Items {
+		if memory, ok := n.Status.Allocatable[v1.ResourceMemory]; ok {
+			capacity.Memory += memory.Value()
+		}
+	}
+	podSelector := provision.PoolAppsLabels(provision.PoolAppsLabelsOpts{
+		Pool:   pool,
+		Prefix: tsuruLabelPrefix,
+	}).ToAppPoolSelector()
+	pods, err := client.Core().Pods(client.Namespace()).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set(podSelector)).String(),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, pod := range pods.Items {
+		for _, c := range pod.Spec.Containers {
+			if memory, ok := c.Resources.Limits[v1.ResourceMemory]; ok {
+				capacity.ReservedMemory += memory.Value()
+			}
+		}
+	}
+	return &capacity, nil
+}
+
+// PoolHealthCheck checks whether the API server of the cluster of the pool is
+// reachable.
+func (p *kubernetesProvisioner) PoolHealthCheck(pool string) error {
+	client, err := clusterForPool(pool)
+	if err != nil {
+		return err
+	}
+	_, err = client.Discovery().ServerVersion()
+	return errors.WithStack(err)
+}
+
 func (p *kubernetesProvisioner) GetNode(address string) (provision.Node, error) {
 	_, node, err := p.findNodeByAddress(address)
 	if err != nil {
@@ -395,27 +573,10 @@ func (p *kubernetesProvisioner) RemoveNode(opts provision.RemoveNodeOptions) err
 	}
 	node := nodeWrapper.node
 	if opts.Rebalance {
-		node.Spec.Unschedulable = true
-		_, err = client.Core().Nodes().Update(node)
-		if err != nil {
-			return errors.WithStack(err)
-		}
-		var pods []v1.Pod
-		pods, err = podsFromNode(client, node.Name)
+		err = evictNodePods(client, node)
 		if err != nil {
 			return err
 		}
-		for _, pod := range pods {
-			err = client.Core().Pods(client.Namespace()).Evict(&policy.Eviction{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      pod.Name,
-					Namespace: client.Namespace(),
-				},
-			})
-			if err != nil {
-				return errors.WithStack(err)
-			}
-		}
 	}
 	err = client.Core().Nodes().Delete(node.Name, &metav1.DeleteOptions{})
 	if err != nil {
@@ -424,6 +585,40 @@ func (p *kubernetesProvisioner) RemoveNode(opts provision.RemoveNodeOptions) err
 	return nil
 }
 
+// EvacuateNode cordons the node and evicts its pods, which are recreated by
+// their controllers in other nodes.
+func (p *kubernetesProvisioner) EvacuateNode(address string, w io.Writer) error {
+	client, nodeWrapper, err := p.findNodeByAddress(address)
+	if err != nil {
+		return err
+	}
+	return evictNodePods(client, nodeWrapper.node)
+}
+
+func evictNodePods(client *clusterClient, node *v1.Node) error {
+	node.Spec.Unschedulable = true
+	_, err := client.Core().Nodes().Update(node)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	pods, err := podsFromNode(client, node.Name)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		err = client.Core().Pods(client.Namespace()).Evict(&policy.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pod.Name,
+				Namespace: client.Namespace(),
+			},
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
 func (p *kubernetesProvisioner) NodeForNodeData(nodeData provision.NodeStatusData) (provision.Node, error) {
 	return provision.FindNodeByAddrs(p, nodeData.Addrs)
 }
@@ -541,6 +736,10 @@ func (p *kubernetesProvisioner) ImageDeploy(a provision.App, imageID string, evt
 		client: client,
 		writer: evt,
 	}
+	err = evt.Checkpoint("deploy")
+	if err != nil {
+		return "", err
+	}
 	err = servicecommon.RunServicePipeline(manager, a, newImage, nil)
 	if err != nil {
 		return "", errors.WithStack(err)
@@ -586,6 +785,10 @@ func (p *kubernetesProvisioner) UploadDeploy(a provision.App, archiveFile io.Rea
 		client: client,
 		writer: evt,
 	}
+	err = evt.Checkpoint("deploy")
+	if err != nil {
+		return "", err
+	}
 	err = servicecommon.RunServicePipeline(manager, a, buildingImage, nil)
 	if err != nil {
 		return "", errors.WithStack(err)
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"sort"
+	"time"
 
 	"github.com/fsouza/go-dockerclient"
 	"github.com/tsuru/tsuru/app"
@@ -40,6 +41,11 @@ func (s *S) TestListNodes(c *check.C) {
 	c.Assert(nodes[1].Address(), check.Equals, "192.168.99.2")
 }
 
+func (s *S) TestPoolHealthCheck(c *check.C) {
+	err := s.p.PoolHealthCheck("test-default")
+	c.Assert(err, check.IsNil)
+}
+
 func (s *S) TestListNodesWithoutNodes(c *check.C) {
 	nodes, err := s.p.ListNodes([]string{})
 	c.Assert(err, check.IsNil)
@@ -152,6 +158,67 @@ func (s *S) TestUnitsEmpty(c *check.C) {
 	c.Assert(units, check.HasLen, 0)
 }
 
+func (s *S) TestUnitsEvents(c *check.C) {
+	a, wait, rollback := s.defaultReactions(c)
+	defer rollback()
+	imgName := "myapp:v1"
+	err := image.SaveImageCustomData(imgName, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python myapp.py",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.GetName(), imgName)
+	c.Assert(err, check.IsNil)
+	err = s.p.Start(a, "")
+	c.Assert(err, check.IsNil)
+	wait()
+	now := time.Now().UTC().Truncate(time.Second)
+	events := []*v1.Event{
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "evt1", Namespace: s.client.Namespace()},
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "myapp-web-pod-1-1"},
+			Type:           "Warning",
+			Reason:         "FailedScheduling",
+			Message:        "no nodes available to schedule pods",
+			Count:          3,
+			FirstTimestamp: metav1.NewTime(now.Add(-time.Minute)),
+			LastTimestamp:  metav1.NewTime(now),
+		},
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "evt2", Namespace: s.client.Namespace()},
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "myapp-web-pod-1-1"},
+			Reason:         "Pulled",
+			FirstTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+			LastTimestamp:  metav1.NewTime(now.Add(-2 * time.Hour)),
+		},
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "evt3", Namespace: s.client.Namespace()},
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "otherapp-web-pod-1-1"},
+			Reason:         "Failed",
+			LastTimestamp:  metav1.NewTime(now),
+		},
+	}
+	for _, evt := range events {
+		_, err = s.client.Core().Events(s.client.Namespace()).Create(evt)
+		c.Assert(err, check.IsNil)
+	}
+	unitEvents, err := s.p.UnitsEvents(a, now.Add(-time.Hour))
+	c.Assert(err, check.IsNil)
+	c.Assert(unitEvents, check.HasLen, 1)
+	c.Assert(unitEvents[0].FirstSeen.Equal(now.Add(-time.Minute)), check.Equals, true)
+	c.Assert(unitEvents[0].LastSeen.Equal(now), check.Equals, true)
+	unitEvents[0].FirstSeen = time.Time{}
+	unitEvents[0].LastSeen = time.Time{}
+	c.Assert(unitEvents[0], check.DeepEquals, provision.UnitEvent{
+		Unit:    "myapp-web-pod-1-1",
+		Type:    "Warning",
+		Reason:  "FailedScheduling",
+		Message: "no nodes available to schedule pods",
+		Count:   3,
+	})
+}
+
 func (s *S) TestGetNode(c *check.C) {
 	s.mockfakeNodes(c)
 	host := "192.168.99.1"
@@ -809,3 +876,34 @@ func (s *S) TestSleepStart(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(units, check.HasLen, 1)
 }
+
+func (s *S) TestPodEviction(c *check.C) {
+	finished := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "myapp-web-1"},
+		Spec:       v1.PodSpec{NodeName: "n1"},
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{{
+				LastTerminationState: v1.ContainerState{
+					Terminated: &v1.ContainerStateTerminated{
+						Reason:     "OOMKilled",
+						FinishedAt: metav1.NewTime(finished),
+					},
+				},
+			}},
+		},
+	}
+	c.Assert(podEviction(&pod), check.DeepEquals, &provision.UnitEviction{
+		Unit:   "myapp-web-1",
+		Node:   "n1",
+		Reason: provision.EvictionReasonOOMKilled,
+		Time:   finished,
+	})
+	pod.Status.Reason = "Evicted"
+	pod.Status.Message = "The node was low on resource: memory."
+	eviction := podEviction(&pod)
+	c.Assert(eviction, check.NotNil)
+	c.Assert(eviction.Reason, check.Equals, provision.EvictionReasonEvicted)
+	c.Assert(eviction.Message, check.Equals, "The node was low on resource: memory.")
+	c.Assert(podEviction(&v1.Pod{}), check.IsNil)
+}
@@ -62,6 +62,10 @@ func (s *LabelSet) ToAppSelector() map[string]string {
 	return withPrefix(subMap(s.Labels, labelAppName), s.Prefix)
 }
 
+func (s *LabelSet) ToAppPoolSelector() map[string]string {
+	return withPrefix(subMap(s.Labels, labelAppPool), s.Prefix)
+}
+
 func (s *LabelSet) ToNodeContainerSelector() map[string]string {
 	return withPrefix(subMap(s.Labels, labelNodeContainerName, labelNodeContainerPool), s.Prefix)
 }
@@ -280,6 +284,18 @@ func NodeContainerLabels(opts NodeContainerLabelsOpts) *LabelSet {
 	return &LabelSet{Labels: labels, Prefix: opts.Prefix}
 }
 
+type PoolAppsLabelsOpts struct {
+	Pool   string
+	Prefix string
+}
+
+func PoolAppsLabels(opts PoolAppsLabelsOpts) *LabelSet {
+	return &LabelSet{
+		Labels: map[string]string{labelAppPool: opts.Pool},
+		Prefix: opts.Prefix,
+	}
+}
+
 type NodeLabelsOpts struct {
 	Addr         string
 	Pool         string
@@ -0,0 +1,77 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MetricsScrapeAnnotation = "prometheus.io/scrape"
+	MetricsPortAnnotation   = "prometheus.io/port"
+	MetricsPathAnnotation   = "prometheus.io/path"
+
+	defaultMetricsPath = "/metrics"
+)
+
+var ErrInvalidMetricsConfig = errors.New("invalid metrics config: port must be between 1 and 65535 and path must start with /")
+
+// MetricsConfig holds the endpoint where the units of an app expose metrics
+// to be scraped by a monitoring system.
+type MetricsConfig struct {
+	// Port is the port, inside the units, serving the metrics.
+	Port int `json:"port,omitempty"`
+	// Path is the HTTP path serving the metrics, defaults to /metrics.
+	Path string `json:"path,omitempty" bson:",omitempty"`
+	// Process restricts scraping to units of the given process. All
+	// processes are scraped when empty.
+	Process string `json:"process,omitempty" bson:",omitempty"`
+}
+
+func (c MetricsConfig) Validate() error {
+	if c.IsEmpty() {
+		return nil
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		return ErrInvalidMetricsConfig
+	}
+	if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+		return ErrInvalidMetricsConfig
+	}
+	return nil
+}
+
+func (c MetricsConfig) IsEmpty() bool {
+	return c.Port == 0 && c.Path == "" && c.Process == ""
+}
+
+// MetricsPath returns the path serving the metrics.
+func (c MetricsConfig) MetricsPath() string {
+	if c.Path == "" {
+		return defaultMetricsPath
+	}
+	return c.Path
+}
+
+// Scrapes returns whether units of the given process must be scraped.
+func (c MetricsConfig) Scrapes(process string) bool {
+	return c.Port > 0 && (c.Process == "" || c.Process == process)
+}
+
+// Annotations returns the annotations used by service discovery to scrape
+// units of the given process, or nil if the process isn't scraped.
+func (c MetricsConfig) Annotations(process string) map[string]string {
+	if !c.Scrapes(process) {
+		return nil
+	}
+	return map[string]string{
+		MetricsScrapeAnnotation: "true",
+		MetricsPortAnnotation:   strconv.Itoa(c.Port),
+		MetricsPathAnnotation:   c.MetricsPath(),
+	}
+}
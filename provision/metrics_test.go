@@ -0,0 +1,48 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision_test
+
+import (
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestMetricsConfigValidate(c *check.C) {
+	valid := []provision.MetricsConfig{
+		{},
+		{Port: 9100},
+		{Port: 9100, Path: "/stats", Process: "web"},
+	}
+	for _, cfg := range valid {
+		c.Check(cfg.Validate(), check.IsNil)
+	}
+	invalid := []provision.MetricsConfig{
+		{Path: "/metrics"},
+		{Port: -1},
+		{Port: 70000},
+		{Port: 9100, Path: "metrics"},
+	}
+	for _, cfg := range invalid {
+		c.Check(cfg.Validate(), check.Equals, provision.ErrInvalidMetricsConfig)
+	}
+}
+
+func (s *S) TestMetricsConfigAnnotations(c *check.C) {
+	cfg := provision.MetricsConfig{Port: 9100}
+	c.Assert(cfg.MetricsPath(), check.Equals, "/metrics")
+	c.Assert(cfg.Annotations("web"), check.DeepEquals, map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   "9100",
+		"prometheus.io/path":   "/metrics",
+	})
+	cfg = provision.MetricsConfig{Port: 8080, Path: "/stats", Process: "worker"}
+	c.Assert(cfg.Annotations("web"), check.IsNil)
+	c.Assert(cfg.Annotations("worker"), check.DeepEquals, map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   "8080",
+		"prometheus.io/path":   "/stats",
+	})
+	c.Assert(provision.MetricsConfig{}.Annotations("web"), check.IsNil)
+}
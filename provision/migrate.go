@@ -5,6 +5,8 @@
 package provision
 
 import (
+	"reflect"
+
 	"github.com/tsuru/tsuru/db"
 )
 
@@ -14,6 +16,29 @@ type poolWithTeams struct {
 	Public bool
 }
 
+// MigratePoolConstraintsIndex drops the old unique index of pool constraints,
+// which doesn't allow constraints of different layers with the same pool
+// expression and field.
+func MigratePoolConstraintsIndex() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.PoolsConstraints()
+	indexes, err := coll.Indexes()
+	if err != nil {
+		return err
+	}
+	oldKey := []string{"poolexpr", "field"}
+	for _, index := range indexes {
+		if reflect.DeepEqual(index.Key, oldKey) {
+			return coll.DropIndexName(index.Name)
+		}
+	}
+	return nil
+}
+
 func MigratePoolTeamsToPoolConstraints() error {
 	conn, err := db.Conn()
 	if err != nil {
@@ -6,6 +6,7 @@ package provision
 
 import (
 	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -33,3 +34,22 @@ func (s *S) TestMigratePoolTeamsToPoolConstraints(c *check.C) {
 		Field:    "team",
 	})
 }
+
+func (s *S) TestMigratePoolConstraintsIndex(c *check.C) {
+	coll := s.storage.Collection("pool_constraints")
+	err := coll.EnsureIndex(mgo.Index{Key: []string{"poolexpr", "field"}, Unique: true})
+	c.Assert(err, check.IsNil)
+	err = MigratePoolConstraintsIndex()
+	c.Assert(err, check.IsNil)
+	indexes, err := coll.Indexes()
+	c.Assert(err, check.IsNil)
+	for _, index := range indexes {
+		c.Assert(index.Key, check.Not(check.DeepEquals), []string{"poolexpr", "field"})
+	}
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "dev", Field: "router", Values: []string{"planb"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "dev", Layer: ConstraintLayerGroup, Field: "router", Values: []string{"galeb"}})
+	c.Assert(err, check.IsNil)
+	err = MigratePoolConstraintsIndex()
+	c.Assert(err, check.IsNil)
+}
@@ -6,6 +6,7 @@ package provision
 
 import (
 	"sort"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/net"
@@ -13,6 +14,11 @@ import (
 
 const PoolMetadataName = "pool"
 
+// PreemptibleMetadataKey is the node metadata key marking nodes that may be
+// reclaimed by the IaaS at any time, like spot or preemptible instances. Nodes
+// are preemptible when the key is set to "true".
+const PreemptibleMetadataKey = "preemptible"
+
 type MetaWithFrequency struct {
 	Metadata map[string]string
 	Nodes    []Node
@@ -51,6 +57,23 @@ func FindNodeByAddrs(p NodeProvisioner, addrs []string) (Node, error) {
 	return node, nil
 }
 
+// MatchesRequirements returns whether the node metadata satisfies all the
+// required key/value pairs.
+func MatchesRequirements(metadata, requirements map[string]string) bool {
+	for k, v := range requirements {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPreemptible returns whether the node metadata marks the node as
+// preemptible.
+func IsPreemptible(metadata map[string]string) bool {
+	return metadata[PreemptibleMetadataKey] == "true"
+}
+
 func metadataNoIaasID(n Node) map[string]string {
 	// iaas-id is ignored because it wasn't created in previous tsuru versions
 	// and having nodes with and without it would cause unbalanced metadata
@@ -122,3 +145,14 @@ func (nodes NodeList) SplitMetadata() (MetaWithFrequencyList, map[string]string,
 	sort.Sort(group)
 	return group, common, nil
 }
+
+// RunNodeCheck runs a diagnostic check on a node, measuring how long it took.
+func RunNodeCheck(name string, check func() error) NodeCheckResult {
+	start := time.Now()
+	err := check()
+	result := NodeCheckResult{Name: name, Successful: err == nil, Duration: time.Since(start)}
+	if err != nil {
+		result.Err = err.Error()
+	}
+	return result
+}
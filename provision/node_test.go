@@ -5,6 +5,8 @@
 package provision_test
 
 import (
+	"errors"
+
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/provisiontest"
 	"gopkg.in/check.v1"
@@ -14,6 +16,22 @@ type S struct{}
 
 var _ = check.Suite(&S{})
 
+func (s *S) TestMatchesRequirements(c *check.C) {
+	metadata := map[string]string{"pool": "p1", "disk": "ssd", "network": "dmz"}
+	c.Assert(provision.MatchesRequirements(metadata, nil), check.Equals, true)
+	c.Assert(provision.MatchesRequirements(metadata, map[string]string{"disk": "ssd"}), check.Equals, true)
+	c.Assert(provision.MatchesRequirements(metadata, map[string]string{"disk": "ssd", "network": "dmz"}), check.Equals, true)
+	c.Assert(provision.MatchesRequirements(metadata, map[string]string{"disk": "hdd"}), check.Equals, false)
+	c.Assert(provision.MatchesRequirements(metadata, map[string]string{"gpu": "true"}), check.Equals, false)
+}
+
+func (s *S) TestIsPreemptible(c *check.C) {
+	c.Assert(provision.IsPreemptible(nil), check.Equals, false)
+	c.Assert(provision.IsPreemptible(map[string]string{"pool": "p1"}), check.Equals, false)
+	c.Assert(provision.IsPreemptible(map[string]string{"preemptible": "false"}), check.Equals, false)
+	c.Assert(provision.IsPreemptible(map[string]string{"preemptible": "true"}), check.Equals, true)
+}
+
 func (s *S) TestFindNodeByAddrs(c *check.C) {
 	p := provisiontest.NewFakeProvisioner()
 	err := p.AddNode(provision.AddNodeOptions{
@@ -112,3 +130,14 @@ func (s *S) TestSplitMetadata(c *check.C) {
 	_, _, err = provision.NodeList(params).SplitMetadata()
 	c.Assert(err, check.ErrorMatches, "unbalanced metadata for node group:.*")
 }
+
+func (s *S) TestRunNodeCheck(c *check.C) {
+	result := provision.RunNodeCheck("ok", func() error { return nil })
+	c.Assert(result.Name, check.Equals, "ok")
+	c.Assert(result.Successful, check.Equals, true)
+	c.Assert(result.Err, check.Equals, "")
+	result = provision.RunNodeCheck("failing", func() error { return errors.New("my error") })
+	c.Assert(result.Name, check.Equals, "failing")
+	c.Assert(result.Successful, check.Equals, false)
+	c.Assert(result.Err, check.Equals, "my error")
+}
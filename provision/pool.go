@@ -10,10 +10,13 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/naming"
 	"github.com/tsuru/tsuru/router"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -24,17 +27,66 @@ var (
 	ErrDefaultPoolAlreadyExists       = errors.New("Default pool already exists.")
 	ErrPoolNameIsRequired             = errors.New("Pool name is required.")
 	ErrPoolNotFound                   = errors.New("Pool does not exist.")
+	ErrPoolAlreadyExists              = errors.New("Pool already exists.")
 	ErrPoolHasNoTeam                  = errors.New("no team found for pool")
+	ErrPoolTeamsRequired              = errors.New("You must provide the team.")
 	ErrPoolHasNoRouter                = errors.New("no router found for pool")
+	ErrPoolHasNoPlatform              = errors.New("no platform found for pool")
+	ErrInvalidPoolMetadataKey         = errors.New("invalid pool label or annotation key, keys must not be empty, contain dots or start with $")
+	ErrInvalidPoolEnvName             = errors.New("invalid pool environment variable name, names must not be empty, contain dots or start with $")
+	ErrInvalidPoolState               = errors.Errorf("invalid pool state, valid states are: %s", strings.Join(validPoolStates, ","))
+	ErrDefaultPoolMustBeActive        = errors.New("the default pool must be active")
+	ErrPoolConstraintNotFound         = errors.New("pool constraint not found")
+	ErrInvalidConstraintLayer         = errors.New("invalid constraint layer, valid layers are: global, group and pool")
+	ErrInvalidPoolExpr                = errors.New("invalid pool expression, re: must be followed by a valid regular expression")
 
 	ErrInvalidConstraintType = errors.Errorf("invalid constraint type. Valid types are: %s", strings.Join(validConstraintTypes, ","))
-	validConstraintTypes     = []string{"team", "router"}
+	validConstraintTypes     = []string{"team", "router", "platform", "plan", "cluster"}
+	validPoolStates          = []string{PoolStateActive, PoolStateDraining, PoolStateArchived}
+)
+
+// Pool states. Draining pools don't accept new apps, while keeping existing
+// apps running. Archived pools don't accept new units either.
+const (
+	PoolStateActive   = "active"
+	PoolStateDraining = "draining"
+	PoolStateArchived = "archived"
 )
 
 type Pool struct {
 	Name        string `bson:"_id"`
 	Default     bool
 	Provisioner string
+	Labels      map[string]string `bson:",omitempty"`
+	Annotations map[string]string `bson:",omitempty"`
+	Env         map[string]string `bson:",omitempty"`
+	Quota       PoolQuota         `bson:",omitempty"`
+	State       string            `bson:",omitempty"`
+	Scheduler   PoolScheduler     `bson:",omitempty"`
+	Tags        []string          `bson:",omitempty"`
+	Group       string            `bson:",omitempty"`
+	// HardwareProfile is the name of the hardware profile of the nodes of
+	// the pool, required by plans restricted to a profile.
+	HardwareProfile string `bson:",omitempty"`
+}
+
+// GetState returns the lifecycle state of the pool, pools without a state
+// are active.
+func (p *Pool) GetState() string {
+	if p.State == "" {
+		return PoolStateActive
+	}
+	return p.State
+}
+
+// AcceptsNewApps returns whether apps may be created in or moved to the pool.
+func (p *Pool) AcceptsNewApps() bool {
+	return p.GetState() == PoolStateActive
+}
+
+// AcceptsNewUnits returns whether units may be added to apps in the pool.
+func (p *Pool) AcceptsNewUnits() bool {
+	return p.GetState() != PoolStateArchived
 }
 
 type AddPoolOptions struct {
@@ -43,13 +95,47 @@ type AddPoolOptions struct {
 	Default     bool
 	Force       bool
 	Provisioner string
+	Labels      map[string]string
+	Annotations map[string]string
+	Scheduler   PoolScheduler
+	Group       string
+	// HardwareProfile must be the name of a registered hardware profile.
+	HardwareProfile string
+	// ConstraintTemplates are the names of the constraint templates applied
+	// to the new pool, in order.
+	ConstraintTemplates []string
 }
 
+// UpdatePoolOptions holds the changes to a pool. Labels and Annotations are
+// merged into the existing ones, and keys with empty values are removed.
 type UpdatePoolOptions struct {
 	Default     *bool
 	Public      *bool
 	Force       bool
 	Provisioner string
+	State       string
+	Labels      map[string]string
+	Annotations map[string]string
+	// Scheduler replaces the scheduler configuration of the pool when set,
+	// an empty configuration restores the default behavior.
+	Scheduler *PoolScheduler
+	// HardwareProfile replaces the hardware profile of the pool when set, an
+	// empty name removes it.
+	HardwareProfile *string
+	// Group replaces the group of the pool when set, an empty name removes
+	// the pool from its group.
+	Group *string
+}
+
+func validatePoolMetadata(maps ...map[string]string) error {
+	for _, m := range maps {
+		for k := range m {
+			if k == "" || strings.Contains(k, ".") || strings.HasPrefix(k, "$") {
+				return ErrInvalidPoolMetadataKey
+			}
+		}
+	}
+	return nil
 }
 
 func (p *Pool) GetProvisioner() (Provisioner, error) {
@@ -81,6 +167,46 @@ func (p *Pool) GetRouters() ([]string, error) {
 	return nil, ErrPoolHasNoRouter
 }
 
+func (p *Pool) GetPlatforms() ([]string, error) {
+	allowedValues, err := p.allowedValues()
+	if err != nil {
+		return nil, err
+	}
+	if c := allowedValues["platform"]; len(c) > 0 {
+		return c, nil
+	}
+	return nil, ErrPoolHasNoPlatform
+}
+
+// AllowsPlatform returns whether apps using the given platform are allowed in
+// the pool. Pools without a platform constraint allow every platform.
+func (p *Pool) AllowsPlatform(platform string) (bool, error) {
+	return p.allows("platform", platform)
+}
+
+// AllowsPlan returns whether apps using the given plan are allowed in the
+// pool. Pools without a plan constraint allow every plan.
+func (p *Pool) AllowsPlan(plan string) (bool, error) {
+	return p.allows("plan", plan)
+}
+
+// AllowsCluster returns whether the given cluster may serve the pool. Pools
+// without a cluster constraint allow every cluster.
+func (p *Pool) AllowsCluster(cluster string) (bool, error) {
+	return p.allows("cluster", cluster)
+}
+
+func (p *Pool) allows(field, value string) (bool, error) {
+	constraints, err := getConstraintsForPool(p.Name, field)
+	if err != nil {
+		return false, err
+	}
+	if c, ok := constraints[field]; ok {
+		return c.check(value), nil
+	}
+	return true, nil
+}
+
 func (p *Pool) allowedValues() (map[string][]string, error) {
 	teams, err := teamsNames()
 	if err != nil {
@@ -90,11 +216,16 @@ func (p *Pool) allowedValues() (map[string][]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	platforms, err := platformsNames()
+	if err != nil {
+		return nil, err
+	}
 	resolved := map[string][]string{
-		"router": routers,
-		"team":   teams,
+		"router":   routers,
+		"team":     teams,
+		"platform": platforms,
 	}
-	constraints, err := getConstraintsForPool(p.Name, "team", "router")
+	constraints, err := getConstraintsForPool(p.Name, "team", "router", "platform")
 	if err != nil {
 		return nil, err
 	}
@@ -105,6 +236,8 @@ func (p *Pool) allowedValues() (map[string][]string, error) {
 			names = teams
 		case "router":
 			names = routers
+		case "platform":
+			names = platforms
 		}
 		var validNames []string
 		for _, n := range names {
@@ -129,6 +262,59 @@ func routersNames() ([]string, error) {
 	return names, nil
 }
 
+func platformsNames() ([]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var platforms []struct {
+		Name string `bson:"_id"`
+	}
+	err = conn.Platforms().Find(nil).Select(bson.M{"_id": 1}).All(&platforms)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, p := range platforms {
+		names = append(names, p.Name)
+	}
+	return names, nil
+}
+
+func plansNames() ([]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return collectionIDs(conn.Plans())
+}
+
+func clustersNames() ([]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return collectionIDs(conn.ProvisionerClusters())
+}
+
+func collectionIDs(coll *storage.Collection) ([]string, error) {
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	err := coll.Find(nil).Select(bson.M{"_id": 1}).All(&docs)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, d := range docs {
+		ids = append(ids, d.ID)
+	}
+	return ids, nil
+}
+
 func teamsNames() ([]string, error) {
 	teams, err := auth.ListTeams()
 	if err != nil {
@@ -157,6 +343,22 @@ func (p *Pool) MarshalJSON() ([]byte, error) {
 	result["provisioner"] = p.Provisioner
 	result["teams"] = resolvedConstraints["team"]
 	result["allowed"] = resolvedConstraints
+	result["labels"] = p.Labels
+	result["annotations"] = p.Annotations
+	result["env"] = p.Env
+	result["quota"] = p.Quota
+	result["state"] = p.GetState()
+	result["scheduler"] = p.Scheduler
+	result["tags"] = p.Tags
+	result["hardwareProfile"] = p.HardwareProfile
+	result["group"] = p.Group
+	if !p.Quota.Unlimited() {
+		usage, err := GetPoolUsage(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		result["usage"] = usage
+	}
 	return json.Marshal(&result)
 }
 
@@ -164,6 +366,26 @@ func AddPool(opts AddPoolOptions) error {
 	if opts.Name == "" {
 		return ErrPoolNameIsRequired
 	}
+	err := naming.Validate(naming.Target{Kind: naming.KindPool, Name: opts.Name})
+	if err != nil {
+		return err
+	}
+	err = validatePoolMetadata(opts.Labels, opts.Annotations)
+	if err != nil {
+		return err
+	}
+	err = opts.Scheduler.validate()
+	if err != nil {
+		return err
+	}
+	err = validateHardwareProfile(opts.HardwareProfile)
+	if err != nil {
+		return err
+	}
+	templates, err := getConstraintTemplates(opts.ConstraintTemplates)
+	if err != nil {
+		return err
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -175,11 +397,24 @@ func AddPool(opts AddPoolOptions) error {
 			return err
 		}
 	}
-	pool := Pool{Name: opts.Name, Default: opts.Default, Provisioner: opts.Provisioner}
+	pool := Pool{
+		Name:            opts.Name,
+		Default:         opts.Default,
+		Provisioner:     opts.Provisioner,
+		Labels:          opts.Labels,
+		Annotations:     opts.Annotations,
+		Scheduler:       opts.Scheduler,
+		HardwareProfile: opts.HardwareProfile,
+		Group:           opts.Group,
+	}
 	err = conn.Pools().Insert(pool)
 	if err != nil {
 		return err
 	}
+	err = applyConstraintTemplates(opts.Name, templates)
+	if err != nil {
+		return err
+	}
 	if opts.Public || opts.Default {
 		return SetPoolConstraint(&PoolConstraint{PoolExpr: opts.Name, Field: "team", Values: []string{"*"}})
 	}
@@ -274,6 +509,38 @@ func RemoveTeamsFromPool(poolName string, teams []string) error {
 	return removePoolConstraint(poolName, "team", teams...)
 }
 
+// SetPoolTeams replaces the teams allowed to use the pool. At least one team
+// must be given.
+func SetPoolTeams(poolName string, teams []string) error {
+	var uniqueTeams []string
+	seen := make(map[string]struct{}, len(teams))
+	for _, t := range teams {
+		if _, ok := seen[t]; ok || t == "" {
+			continue
+		}
+		seen[t] = struct{}{}
+		uniqueTeams = append(uniqueTeams, t)
+	}
+	if len(uniqueTeams) == 0 {
+		return ErrPoolTeamsRequired
+	}
+	pool, err := GetPoolByName(poolName)
+	if err != nil {
+		return err
+	}
+	teamConstraint, err := getExactConstraintForPool(poolName, "team")
+	if err != nil {
+		return err
+	}
+	if teamConstraint != nil && teamConstraint.Blacklist {
+		return errors.New("Unable to set teams of blacklist constraint")
+	}
+	if teamConstraint.AllowsAll() || pool.Default {
+		return ErrPublicDefaultPoolCantHaveTeams
+	}
+	return SetPoolConstraint(&PoolConstraint{PoolExpr: poolName, Field: "team", Values: uniqueTeams})
+}
+
 func ListPools(names ...string) ([]Pool, error) {
 	return listPools(bson.M{"_id": bson.M{"$in": names}})
 }
@@ -286,6 +553,66 @@ func ListPoolsForTeam(team string) ([]Pool, error) {
 	return getPoolsSatisfyConstraints(true, "team", team)
 }
 
+// PoolFilter holds the criteria used to filter a list of pools. Empty fields
+// match all pools.
+type PoolFilter struct {
+	Provisioner string
+	Team        string
+	Default     *bool
+	Labels      map[string]string
+}
+
+// FilterPools returns the pools matching the given filter, in the same order.
+// Pools without a provisioner match the default provisioner and pools match
+// a team when the team is allowed to use them.
+func FilterPools(pools []Pool, filter PoolFilter) ([]Pool, error) {
+	var teamPools map[string]struct{}
+	if filter.Team != "" {
+		possible, err := ListPossiblePools([]string{filter.Team})
+		if err != nil {
+			return nil, err
+		}
+		teamPools = make(map[string]struct{}, len(possible))
+		for _, p := range possible {
+			teamPools[p.Name] = struct{}{}
+		}
+	}
+	var result []Pool
+	for _, p := range pools {
+		if filter.Default != nil && p.Default != *filter.Default {
+			continue
+		}
+		if filter.Provisioner != "" {
+			provName := p.Provisioner
+			if provName == "" {
+				provName = DefaultProvisioner
+			}
+			if provName != filter.Provisioner {
+				continue
+			}
+		}
+		if teamPools != nil {
+			if _, ok := teamPools[p.Name]; !ok {
+				continue
+			}
+		}
+		if !matchLabels(p.Labels, filter.Labels) {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+func matchLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
 func listPools(query bson.M) ([]Pool, error) {
 	conn, err := db.Conn()
 	if err != nil {
@@ -318,6 +645,37 @@ func GetPoolByName(name string) (*Pool, error) {
 	return &p, nil
 }
 
+// ValidEnvName returns whether name may be used as the name of an environment
+// variable stored in a database document: it must not be empty, contain dots
+// or start with $.
+func ValidEnvName(name string) bool {
+	return name != "" && !strings.Contains(name, ".") && !strings.HasPrefix(name, "$")
+}
+
+// SetPoolEnvs replaces the environment variables of the pool, which are set in
+// every unit of the apps in the pool, unless overridden by the app.
+func SetPoolEnvs(name string, envs map[string]string) error {
+	for k := range envs {
+		if !ValidEnvName(k) {
+			return ErrInvalidPoolEnvName
+		}
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	update := bson.M{"$set": bson.M{"env": envs}}
+	if len(envs) == 0 {
+		update = bson.M{"$unset": bson.M{"env": ""}}
+	}
+	err = conn.Pools().UpdateId(name, update)
+	if err == mgo.ErrNotFound {
+		return ErrPoolNotFound
+	}
+	return err
+}
+
 func GetDefaultPool() (*Pool, error) {
 	conn, err := db.Conn()
 	if err != nil {
@@ -336,15 +694,35 @@ func GetDefaultPool() (*Pool, error) {
 }
 
 func PoolUpdate(name string, opts UpdatePoolOptions) error {
+	err := validatePoolMetadata(opts.Labels, opts.Annotations)
+	if err != nil {
+		return err
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
-	_, err = GetPoolByName(name)
+	pool, err := GetPoolByName(name)
 	if err != nil {
 		return err
 	}
+	err = validatePoolState(pool, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Scheduler != nil {
+		err = opts.Scheduler.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if opts.HardwareProfile != nil {
+		err = validateHardwareProfile(*opts.HardwareProfile)
+		if err != nil {
+			return err
+		}
+	}
 	if opts.Default != nil && *opts.Default {
 		err = changeDefaultPool(opts.Force)
 		if err != nil {
@@ -370,27 +748,180 @@ func PoolUpdate(name string, opts UpdatePoolOptions) error {
 	if opts.Provisioner != "" {
 		query["provisioner"] = opts.Provisioner
 	}
-	if len(query) == 0 {
+	unset := bson.M{}
+	switch opts.State {
+	case "":
+	case PoolStateActive:
+		unset["state"] = ""
+	default:
+		query["state"] = opts.State
+	}
+	if opts.Scheduler != nil {
+		if opts.Scheduler.IsEmpty() {
+			unset["scheduler"] = ""
+		} else {
+			query["scheduler"] = *opts.Scheduler
+		}
+	}
+	if opts.HardwareProfile != nil {
+		if *opts.HardwareProfile == "" {
+			unset["hardwareprofile"] = ""
+		} else {
+			query["hardwareprofile"] = *opts.HardwareProfile
+		}
+	}
+	if opts.Group != nil {
+		if *opts.Group == "" {
+			unset["group"] = ""
+		} else {
+			query["group"] = *opts.Group
+		}
+	}
+	for field, values := range map[string]map[string]string{"labels": opts.Labels, "annotations": opts.Annotations} {
+		for k, v := range values {
+			if v == "" {
+				unset[field+"."+k] = ""
+			} else {
+				query[field+"."+k] = v
+			}
+		}
+	}
+	update := bson.M{}
+	if len(query) > 0 {
+		update["$set"] = query
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(update) == 0 {
 		return nil
 	}
-	err = conn.Pools().UpdateId(name, bson.M{"$set": query})
+	err = conn.Pools().UpdateId(name, update)
 	if err == mgo.ErrNotFound {
 		return ErrPoolNotFound
 	}
 	return err
 }
 
+func validatePoolState(pool *Pool, opts UpdatePoolOptions) error {
+	state := pool.GetState()
+	if opts.State != "" {
+		state = opts.State
+		var valid bool
+		for _, s := range validPoolStates {
+			if s == state {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return ErrInvalidPoolState
+		}
+	}
+	isDefault := pool.Default
+	if opts.Default != nil {
+		isDefault = *opts.Default
+	}
+	if isDefault && state != PoolStateActive {
+		return ErrDefaultPoolMustBeActive
+	}
+	return nil
+}
+
+// Constraint layers, from the least to the most specific one. For each field,
+// the constraint of the most specific layer is applied to the pool, and
+// values denied by blacklists of less specific layers are always inherited.
+const (
+	ConstraintLayerGlobal = "global"
+	ConstraintLayerGroup  = "group"
+	ConstraintLayerPool   = ""
+)
+
+// poolExprRegexPrefix marks pool expressions that are regular expressions,
+// like re:^prod-.*-eu$.
+const poolExprRegexPrefix = "re:"
+
 type PoolConstraint struct {
 	PoolExpr  string
 	Field     string
 	Values    []string
 	Blacklist bool
+	// Layer is the layer of the constraint. Pool layer constraints apply
+	// to pools matching PoolExpr, group layer constraints to pools whose
+	// group is PoolExpr and global layer constraints to every pool.
+	Layer string `bson:",omitempty" json:",omitempty"`
+	// Inherited holds the values denied by blacklists of less specific
+	// layers. It's only set in constraints resolved for a pool.
+	Inherited []string `bson:"-" json:",omitempty" form:"-"`
+	// SetBy and SetAt record the user who last changed the constraint and
+	// when. They're empty for constraints changed by tsuru itself.
+	SetBy string    `bson:",omitempty" json:",omitempty" form:"-"`
+	SetAt time.Time `bson:",omitempty" json:",omitempty" form:"-"`
+}
+
+func (c *PoolConstraint) validateLayer() error {
+	switch c.Layer {
+	case ConstraintLayerGlobal:
+		c.PoolExpr = ""
+	case "pool":
+		c.Layer = ConstraintLayerPool
+	case ConstraintLayerGroup, ConstraintLayerPool:
+	default:
+		return ErrInvalidConstraintLayer
+	}
+	return nil
+}
+
+// validatePoolExpr checks that pool expressions using the regular expression
+// syntax compile.
+func (c *PoolConstraint) validatePoolExpr() error {
+	if c.Layer != ConstraintLayerPool || !strings.HasPrefix(c.PoolExpr, poolExprRegexPrefix) {
+		return nil
+	}
+	expr := strings.TrimPrefix(c.PoolExpr, poolExprRegexPrefix)
+	if expr == "" {
+		return ErrInvalidPoolExpr
+	}
+	_, err := regexp.Compile(expr)
+	if err != nil {
+		return ErrInvalidPoolExpr
+	}
+	return nil
+}
+
+// poolExprMatches returns whether the pool expression matches the pool name.
+// Expressions are globs, where * matches any sequence of characters, unless
+// prefixed with re:, in which case they're regular expressions.
+func poolExprMatches(poolExpr, pool string) (bool, error) {
+	if strings.HasPrefix(poolExpr, poolExprRegexPrefix) {
+		return regexp.MatchString(strings.TrimPrefix(poolExpr, poolExprRegexPrefix), pool)
+	}
+	pattern := fmt.Sprintf("^%s$", strings.Replace(poolExpr, "*", ".*", -1))
+	return regexp.MatchString(pattern, pool)
+}
+
+func constraintKey(poolExpr, field, layer string) bson.M {
+	key := bson.M{"poolexpr": poolExpr, "field": field, "layer": nil}
+	if layer != ConstraintLayerPool {
+		key["layer"] = layer
+	}
+	return key
+}
+
+func constraintPatternMatches(pattern, v string) bool {
+	match, _ := regexp.MatchString(fmt.Sprintf("^%s$", strings.Replace(pattern, "*", ".*", -1)), v)
+	return match
 }
 
 func (c *PoolConstraint) checkExact(v string) bool {
 	if c == nil {
 		return false
 	}
+	for _, r := range c.Inherited {
+		if r == v {
+			return false
+		}
+	}
 	for _, r := range c.Values {
 		if r == v {
 			return !c.Blacklist
@@ -403,6 +934,11 @@ func (c *PoolConstraint) check(v string) bool {
 	if c == nil {
 		return false
 	}
+	for _, r := range c.Inherited {
+		if constraintPatternMatches(r, v) {
+			return false
+		}
+	}
 	for _, r := range c.Values {
 		pattern := fmt.Sprintf("^%s$", strings.Replace(r, "*", ".*", -1))
 		if match, _ := regexp.MatchString(pattern, v); match {
@@ -424,6 +960,10 @@ type constraintList []*PoolConstraint
 func (l constraintList) Len() int      { return len(l) }
 func (l constraintList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
 func (l constraintList) Less(i, j int) bool {
+	rankI, rankJ := poolExprRank(l[i].PoolExpr), poolExprRank(l[j].PoolExpr)
+	if rankI != rankJ {
+		return rankI < rankJ
+	}
 	lenI, lenJ := len(l[i].PoolExpr), len(l[j].PoolExpr)
 	if lenI == lenJ {
 		return strings.Count(l[i].PoolExpr, "*") < strings.Count(l[j].PoolExpr, "*")
@@ -431,47 +971,89 @@ func (l constraintList) Less(i, j int) bool {
 	return lenI > lenJ
 }
 
+// poolExprRank sorts pool names before regular expressions, which come before
+// globs.
+func poolExprRank(poolExpr string) int {
+	if strings.HasPrefix(poolExpr, poolExprRegexPrefix) {
+		return 1
+	}
+	if strings.Contains(poolExpr, "*") {
+		return 2
+	}
+	return 0
+}
+
+func validateConstraintType(field string) error {
+	for _, v := range validConstraintTypes {
+		if field == v {
+			return nil
+		}
+	}
+	return ErrInvalidConstraintType
+}
+
 func SetPoolConstraint(c *PoolConstraint) error {
 	conn, err := db.Conn()
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
-	isValid := false
-	for _, v := range validConstraintTypes {
-		if c.Field == v {
-			isValid = true
-			break
-		}
+	err = validateConstraintType(c.Field)
+	if err != nil {
+		return err
+	}
+	err = c.validateLayer()
+	if err != nil {
+		return err
 	}
-	if !isValid {
-		return ErrInvalidConstraintType
+	err = c.validatePoolExpr()
+	if err != nil {
+		return err
 	}
+	c.Inherited = nil
+	c.SetAt = time.Time{}
+	if c.SetBy != "" {
+		c.SetAt = time.Now().UTC()
+	}
+	key := constraintKey(c.PoolExpr, c.Field, c.Layer)
 	if len(c.Values) == 0 || (len(c.Values) == 1 && c.Values[0] == "") {
-		errRem := conn.PoolsConstraints().Remove(bson.M{"poolexpr": c.PoolExpr, "field": c.Field})
+		errRem := conn.PoolsConstraints().Remove(key)
 		if errRem != mgo.ErrNotFound {
 			return errRem
 		}
 		return nil
 	}
-	_, err = conn.PoolsConstraints().Upsert(bson.M{"poolexpr": c.PoolExpr, "field": c.Field}, c)
+	_, err = conn.PoolsConstraints().Upsert(key, c)
 	return err
 }
 
 func AppendPoolConstraint(c *PoolConstraint) error {
-	return appendPoolConstraint(c.PoolExpr, c.Field, c.Values...)
+	err := c.validateLayer()
+	if err != nil {
+		return err
+	}
+	err = c.validatePoolExpr()
+	if err != nil {
+		return err
+	}
+	return appendConstraint(constraintKey(c.PoolExpr, c.Field, c.Layer), c.SetBy, c.Values...)
 }
 
 func appendPoolConstraint(poolExpr string, field string, values ...string) error {
+	return appendConstraint(constraintKey(poolExpr, field, ConstraintLayerPool), "", values...)
+}
+
+func appendConstraint(key bson.M, setBy string, values ...string) error {
 	conn, err := db.Conn()
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
-	_, err = conn.PoolsConstraints().Upsert(
-		bson.M{"poolexpr": poolExpr, "field": field},
-		bson.M{"$addToSet": bson.M{"values": bson.M{"$each": values}}},
-	)
+	update := bson.M{"$addToSet": bson.M{"values": bson.M{"$each": values}}}
+	if setBy != "" {
+		update["$set"] = bson.M{"setby": setBy, "setat": time.Now().UTC()}
+	}
+	_, err = conn.PoolsConstraints().Upsert(key, update)
 	return err
 }
 
@@ -481,7 +1063,162 @@ func removePoolConstraint(poolExpr string, field string, values ...string) error
 		return err
 	}
 	defer conn.Close()
-	return conn.PoolsConstraints().Update(bson.M{"poolexpr": poolExpr, "field": field}, bson.M{"$pullAll": bson.M{"values": values}})
+	return conn.PoolsConstraints().Update(constraintKey(poolExpr, field, ConstraintLayerPool), bson.M{"$pullAll": bson.M{"values": values}})
+}
+
+// RemovePoolConstraint removes the constraint of the field set for the pool
+// expression.
+func RemovePoolConstraint(poolExpr, field string) error {
+	return RemoveConstraint(&PoolConstraint{PoolExpr: poolExpr, Field: field})
+}
+
+// RemoveConstraint removes the constraint of the field set in the layer of
+// the given constraint, for its pool expression or group.
+func RemoveConstraint(c *PoolConstraint) error {
+	err := validateConstraintType(c.Field)
+	if err != nil {
+		return err
+	}
+	err = c.validateLayer()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.PoolsConstraints().Remove(constraintKey(c.PoolExpr, c.Field, c.Layer))
+	if err == mgo.ErrNotFound {
+		return ErrPoolConstraintNotFound
+	}
+	return err
+}
+
+// MatchingPoolConstraints returns the constraints that apply to the given
+// pool name, sorted by precedence: constraints whose pool expression matches
+// the name come first, followed by the ones set for the group of the pool and
+// the global ones. The first constraint of each field is the one applied to
+// the pool.
+func MatchingPoolConstraints(pool string) ([]*PoolConstraint, error) {
+	matches, err := matchingConstraints(pool, nil)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := layerConstraints(pool, nil)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		matches = append(matches, layers[i])
+	}
+	return matches, nil
+}
+
+// ResolvePoolConstraints returns the constraint applied to the given pool
+// name for each field.
+func ResolvePoolConstraints(pool string) (map[string]*PoolConstraint, error) {
+	return getConstraintsForPool(pool)
+}
+
+// ConstraintResolution holds the effective values of a constraint type for a
+// pool name.
+type ConstraintResolution struct {
+	Pool  string `json:"pool"`
+	Field string `json:"field"`
+	// Matching lists the constraints of the field applying to the pool,
+	// from the pool layer to the global layer, sorted by precedence.
+	Matching []*PoolConstraint `json:"matching"`
+	// Applied is the constraint applied to the pool, nil when no
+	// constraint matches and every value is allowed.
+	Applied *PoolConstraint `json:"applied,omitempty"`
+	Allowed []string        `json:"allowed"`
+	Denied  []string        `json:"denied"`
+	// Unmatched lists the values of the applied constraint not matching
+	// any existing value, usually a sign of misconfiguration.
+	Unmatched []string `json:"unmatched"`
+}
+
+// ResolvePoolConstraint evaluates the constraints of the field for the pool
+// name against the existing teams, routers or platforms, without changing
+// anything. The pool doesn't need to exist.
+func ResolvePoolConstraint(pool, field string) (*ConstraintResolution, error) {
+	if pool == "" {
+		return nil, ErrPoolNameIsRequired
+	}
+	err := validateConstraintType(field)
+	if err != nil {
+		return nil, err
+	}
+	all, err := MatchingPoolConstraints(pool)
+	if err != nil {
+		return nil, err
+	}
+	matching := []*PoolConstraint{}
+	for _, c := range all {
+		if c.Field == field {
+			matching = append(matching, c)
+		}
+	}
+	applied, err := getConstraintsForPool(pool, field)
+	if err != nil {
+		return nil, err
+	}
+	names, err := constraintFieldValues(field)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	result := ConstraintResolution{
+		Pool:      pool,
+		Field:     field,
+		Matching:  matching,
+		Allowed:   []string{},
+		Denied:    []string{},
+		Unmatched: []string{},
+	}
+	result.Applied = applied[field]
+	if result.Applied == nil {
+		result.Allowed = append(result.Allowed, names...)
+		return &result, nil
+	}
+	for _, n := range names {
+		if result.Applied.check(n) {
+			result.Allowed = append(result.Allowed, n)
+		} else {
+			result.Denied = append(result.Denied, n)
+		}
+	}
+	for _, v := range result.Applied.Values {
+		single := PoolConstraint{Values: []string{v}}
+		var found bool
+		for _, n := range names {
+			if single.check(n) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.Unmatched = append(result.Unmatched, v)
+		}
+	}
+	return &result, nil
+}
+
+func constraintFieldValues(field string) ([]string, error) {
+	switch field {
+	case "team":
+		return teamsNames()
+	case "router":
+		return routersNames()
+	case "platform":
+		return platformsNames()
+	case "plan":
+		return plansNames()
+	case "cluster":
+		return clustersNames()
+	}
+	return nil, ErrInvalidConstraintType
 }
 
 func getPoolsSatisfyConstraints(exactCheck bool, field string, values ...string) ([]Pool, error) {
@@ -514,18 +1251,116 @@ loop:
 }
 
 func getConstraintsForPool(pool string, fields ...string) (map[string]*PoolConstraint, error) {
+	return resolveConstraintsForPool(pool, nil, fields...)
+}
+
+// resolveConstraintsForPool returns the constraint applied to the pool for
+// each field. When candidate is not nil, constraints are resolved as if it
+// was set, replacing the stored constraint with the same key.
+func resolveConstraintsForPool(pool string, candidate *PoolConstraint, fields ...string) (map[string]*PoolConstraint, error) {
 	var query bson.M
 	if len(fields) > 0 {
 		query = bson.M{"field": bson.M{"$in": fields}}
 	}
+	matches, err := matchingConstraints(pool, query)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := layerConstraints(pool, fields)
+	if err != nil {
+		return nil, err
+	}
+	if candidate != nil {
+		var include bool
+		if candidate.Layer == ConstraintLayerPool {
+			include, err = poolExprMatches(candidate.PoolExpr, pool)
+			if err != nil {
+				return nil, err
+			}
+		}
+		matches = withCandidate(matches, candidate, include)
+		sort.Sort(constraintList(matches))
+		include = candidate.Layer == ConstraintLayerGlobal
+		if candidate.Layer == ConstraintLayerGroup {
+			p, err := GetPoolByName(pool)
+			if err != nil && err != ErrPoolNotFound {
+				return nil, err
+			}
+			include = p != nil && p.Group == candidate.PoolExpr
+		}
+		layers = withCandidate(layers, candidate, include)
+		sortLayers(layers)
+	}
+	merged := make(map[string]*PoolConstraint)
+	for i := range matches {
+		if _, ok := merged[matches[i].Field]; !ok {
+			merged[matches[i].Field] = matches[i]
+		}
+	}
+	chains := make(map[string][]*PoolConstraint)
+	for _, c := range layers {
+		chains[c.Field] = append(chains[c.Field], c)
+	}
+	for field, chain := range chains {
+		if c, ok := merged[field]; ok {
+			chain = append(chain, c)
+		}
+		effective := *chain[len(chain)-1]
+		effective.Inherited = nil
+		for _, c := range chain[:len(chain)-1] {
+			if c.Blacklist {
+				effective.Inherited = append(effective.Inherited, c.Values...)
+			}
+		}
+		merged[field] = &effective
+	}
+	return merged, nil
+}
+
+// layerConstraints returns the global constraints followed by the ones set
+// for the group of the pool, if any.
+func layerConstraints(pool string, fields []string) ([]*PoolConstraint, error) {
+	var group string
+	p, err := GetPoolByName(pool)
+	if err == nil {
+		group = p.Group
+	} else if err != ErrPoolNotFound {
+		return nil, err
+	}
+	layers := []bson.M{{"layer": ConstraintLayerGlobal}}
+	if group != "" {
+		layers = append(layers, bson.M{"layer": ConstraintLayerGroup, "poolexpr": group})
+	}
+	query := bson.M{"$or": layers}
+	if len(fields) > 0 {
+		query["field"] = bson.M{"$in": fields}
+	}
+	constraints, err := ListPoolsConstraints(query)
+	if err != nil {
+		return nil, err
+	}
+	sortLayers(constraints)
+	return constraints, nil
+}
+
+func sortLayers(constraints []*PoolConstraint) {
+	sort.SliceStable(constraints, func(i, j int) bool {
+		return constraints[i].Layer == ConstraintLayerGlobal && constraints[j].Layer != ConstraintLayerGlobal
+	})
+}
+
+func matchingConstraints(pool string, query bson.M) ([]*PoolConstraint, error) {
+	if query == nil {
+		query = bson.M{}
+	}
+	query["layer"] = nil
 	constraints, err := ListPoolsConstraints(query)
 	if err != nil {
 		return nil, err
 	}
 	var matches []*PoolConstraint
 	for _, c := range constraints {
-		pattern := fmt.Sprintf("^%s$", strings.Replace(c.PoolExpr, "*", ".*", -1))
-		match, err := regexp.MatchString(pattern, pool)
+		match, err := poolExprMatches(c.PoolExpr, pool)
 		if err != nil {
 			return nil, err
 		}
@@ -534,17 +1369,11 @@ func getConstraintsForPool(pool string, fields ...string) (map[string]*PoolConst
 		}
 	}
 	sort.Sort(constraintList(matches))
-	merged := make(map[string]*PoolConstraint)
-	for i := range matches {
-		if _, ok := merged[matches[i].Field]; !ok {
-			merged[matches[i].Field] = matches[i]
-		}
-	}
-	return merged, nil
+	return matches, nil
 }
 
 func getExactConstraintForPool(pool, field string) (*PoolConstraint, error) {
-	constraints, err := ListPoolsConstraints(bson.M{"poolexpr": pool, "field": field})
+	constraints, err := ListPoolsConstraints(constraintKey(pool, field, ConstraintLayerPool))
 	if err != nil {
 		return nil, err
 	}
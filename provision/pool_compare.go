@@ -0,0 +1,157 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"reflect"
+	"sort"
+)
+
+// PoolSettingDiff holds the values of a pool setting differing between two
+// pools.
+type PoolSettingDiff struct {
+	Setting string      `json:"setting"`
+	A       interface{} `json:"a"`
+	B       interface{} `json:"b"`
+}
+
+// PoolValuesDiff splits the values allowed in two pools in the values
+// allowed only in the first pool, only in the second one and in both.
+type PoolValuesDiff struct {
+	OnlyA  []string `json:"onlyA"`
+	OnlyB  []string `json:"onlyB"`
+	Common []string `json:"common"`
+}
+
+// Equal returns whether both pools allow the same values.
+func (d *PoolValuesDiff) Equal() bool {
+	return len(d.OnlyA) == 0 && len(d.OnlyB) == 0
+}
+
+// PoolConstraintDiff compares the constraints applied to two pools for a
+// field. A and B are nil when no constraint applies to the pool.
+type PoolConstraintDiff struct {
+	Field   string          `json:"field"`
+	A       *PoolConstraint `json:"a"`
+	B       *PoolConstraint `json:"b"`
+	Allowed PoolValuesDiff  `json:"allowed"`
+}
+
+// PoolComparison is the difference between two pools. Only the settings and
+// constraint fields differing between the pools are listed.
+type PoolComparison struct {
+	A           string               `json:"a"`
+	B           string               `json:"b"`
+	Settings    []PoolSettingDiff    `json:"settings"`
+	Constraints []PoolConstraintDiff `json:"constraints"`
+	Teams       PoolValuesDiff       `json:"teams"`
+}
+
+// ComparePools returns the differences in settings, quotas and constraints
+// between the given pools.
+func ComparePools(a, b *Pool) (*PoolComparison, error) {
+	result := PoolComparison{
+		A:           a.Name,
+		B:           b.Name,
+		Settings:    []PoolSettingDiff{},
+		Constraints: []PoolConstraintDiff{},
+	}
+	settings := []struct {
+		name string
+		a, b interface{}
+	}{
+		{"provisioner", a.Provisioner, b.Provisioner},
+		{"default", a.Default, b.Default},
+		{"state", a.GetState(), b.GetState()},
+		{"group", a.Group, b.Group},
+		{"hardwareProfile", a.HardwareProfile, b.HardwareProfile},
+		{"scheduler", a.Scheduler, b.Scheduler},
+		{"quota", a.Quota, b.Quota},
+		{"labels", a.Labels, b.Labels},
+		{"annotations", a.Annotations, b.Annotations},
+		{"env", a.Env, b.Env},
+	}
+	for _, s := range settings {
+		if !equalSetting(s.a, s.b) {
+			result.Settings = append(result.Settings, PoolSettingDiff{Setting: s.name, A: s.a, B: s.b})
+		}
+	}
+	for _, field := range validConstraintTypes {
+		resA, err := ResolvePoolConstraint(a.Name, field)
+		if err != nil {
+			return nil, err
+		}
+		resB, err := ResolvePoolConstraint(b.Name, field)
+		if err != nil {
+			return nil, err
+		}
+		allowed := diffValues(resA.Allowed, resB.Allowed)
+		if field == "team" {
+			result.Teams = allowed
+		}
+		if allowed.Equal() && equalConstraint(resA.Applied, resB.Applied) {
+			continue
+		}
+		result.Constraints = append(result.Constraints, PoolConstraintDiff{
+			Field:   field,
+			A:       resA.Applied,
+			B:       resB.Applied,
+			Allowed: allowed,
+		})
+	}
+	return &result, nil
+}
+
+// equalSetting compares setting values, treating empty and nil maps as
+// equal.
+func equalSetting(a, b interface{}) bool {
+	if ma, ok := a.(map[string]string); ok {
+		mb := b.(map[string]string)
+		if len(ma) == 0 && len(mb) == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func equalConstraint(a, b *PoolConstraint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Blacklist != b.Blacklist || len(a.Values) != len(b.Values) {
+		return false
+	}
+	valuesA := append([]string{}, a.Values...)
+	valuesB := append([]string{}, b.Values...)
+	sort.Strings(valuesA)
+	sort.Strings(valuesB)
+	return reflect.DeepEqual(valuesA, valuesB)
+}
+
+func diffValues(a, b []string) PoolValuesDiff {
+	diff := PoolValuesDiff{OnlyA: []string{}, OnlyB: []string{}, Common: []string{}}
+	inB := make(map[string]bool, len(b))
+	for _, v := range b {
+		inB[v] = true
+	}
+	inA := make(map[string]bool, len(a))
+	for _, v := range a {
+		inA[v] = true
+		if inB[v] {
+			diff.Common = append(diff.Common, v)
+		} else {
+			diff.OnlyA = append(diff.OnlyA, v)
+		}
+	}
+	for _, v := range b {
+		if !inA[v] {
+			diff.OnlyB = append(diff.OnlyB, v)
+		}
+	}
+	sort.Strings(diff.OnlyA)
+	sort.Strings(diff.OnlyB)
+	sort.Strings(diff.Common)
+	return diff
+}
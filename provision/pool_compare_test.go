@@ -0,0 +1,74 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestComparePools(c *check.C) {
+	err := s.storage.Platforms().Insert(bson.M{"_id": "python"}, bson.M{"_id": "go"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool1", Provisioner: "docker", Labels: map[string]string{"env": "prod"}})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool2", Provisioner: "kubernetes"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolQuota("pool2", PoolQuota{MaxApps: 10})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "pool1", Field: "team", Values: []string{"ateam", "test"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "pool2", Field: "team", Values: []string{"test", "pteam"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "pool2", Field: "platform", Values: []string{"go"}, Blacklist: true})
+	c.Assert(err, check.IsNil)
+	pool1, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	pool2, err := GetPoolByName("pool2")
+	c.Assert(err, check.IsNil)
+	comparison, err := ComparePools(pool1, pool2)
+	c.Assert(err, check.IsNil)
+	teams := PoolValuesDiff{OnlyA: []string{"ateam"}, OnlyB: []string{"pteam"}, Common: []string{"test"}}
+	c.Assert(comparison, check.DeepEquals, &PoolComparison{
+		A: "pool1",
+		B: "pool2",
+		Settings: []PoolSettingDiff{
+			{Setting: "provisioner", A: "docker", B: "kubernetes"},
+			{Setting: "quota", A: PoolQuota{}, B: PoolQuota{MaxApps: 10}},
+			{Setting: "labels", A: map[string]string{"env": "prod"}, B: map[string]string(nil)},
+		},
+		Constraints: []PoolConstraintDiff{
+			{
+				Field:   "team",
+				A:       &PoolConstraint{PoolExpr: "pool1", Field: "team", Values: []string{"ateam", "test"}},
+				B:       &PoolConstraint{PoolExpr: "pool2", Field: "team", Values: []string{"test", "pteam"}},
+				Allowed: teams,
+			},
+			{
+				Field:   "platform",
+				B:       &PoolConstraint{PoolExpr: "pool2", Field: "platform", Values: []string{"go"}, Blacklist: true},
+				Allowed: PoolValuesDiff{OnlyA: []string{"go"}, OnlyB: []string{}, Common: []string{"python"}},
+			},
+		},
+		Teams: teams,
+	})
+}
+
+func (s *S) TestComparePoolsEqual(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", Public: true})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	pool1, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	pool2, err := GetPoolByName("pool2")
+	c.Assert(err, check.IsNil)
+	comparison, err := ComparePools(pool1, pool2)
+	c.Assert(err, check.IsNil)
+	c.Assert(comparison.Settings, check.HasLen, 0)
+	c.Assert(comparison.Constraints, check.HasLen, 0)
+	c.Assert(comparison.Teams.Equal(), check.Equals, true)
+	c.Assert(comparison.Teams.Common, check.DeepEquals, []string{"ateam", "pteam", "test"})
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrInvalidPoolQuota = errors.New("invalid pool quota, limits must not be negative")
+
+// PoolQuota limits the resources used by all apps in a pool, regardless of
+// the team owning them. A zero limit means unlimited.
+type PoolQuota struct {
+	MaxApps     int   `json:"maxApps"`
+	MaxUnits    int   `json:"maxUnits"`
+	MaxMemory   int64 `json:"maxMemory"`
+	MaxCPUShare int   `json:"maxCpuShare"`
+}
+
+// Unlimited returns whether the quota doesn't have any limit set.
+func (q PoolQuota) Unlimited() bool {
+	return q == PoolQuota{}
+}
+
+// PoolUsage is the amount of resources used by the apps in a pool. Memory
+// and CPU share are the values of the app plan multiplied by the number of
+// units of the app.
+type PoolUsage struct {
+	Apps     int   `json:"apps"`
+	Units    int   `json:"units"`
+	Memory   int64 `json:"memory"`
+	CPUShare int   `json:"cpuShare"`
+}
+
+type PoolQuotaExceededError struct {
+	Pool      string
+	Resource  string
+	Limit     int64
+	InUse     int64
+	Requested int64
+}
+
+func (e *PoolQuotaExceededError) Error() string {
+	return fmt.Sprintf("Quota exceeded for %s in pool %q. Limit: %d. In use: %d. Requested: %d.",
+		e.Resource, e.Pool, e.Limit, e.InUse, e.Requested)
+}
+
+// SetPoolQuota replaces the quota of the pool, a zero quota removes all
+// limits.
+func SetPoolQuota(name string, quota PoolQuota) error {
+	if quota.MaxApps < 0 || quota.MaxUnits < 0 || quota.MaxMemory < 0 || quota.MaxCPUShare < 0 {
+		return ErrInvalidPoolQuota
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	update := bson.M{"$set": bson.M{"quota": quota}}
+	if quota.Unlimited() {
+		update = bson.M{"$unset": bson.M{"quota": ""}}
+	}
+	err = conn.Pools().UpdateId(name, update)
+	if err == mgo.ErrNotFound {
+		return ErrPoolNotFound
+	}
+	return err
+}
+
+// GetPoolUsage returns the resources used by the apps in the pool.
+func GetPoolUsage(name string) (PoolUsage, error) {
+	var usage PoolUsage
+	conn, err := db.Conn()
+	if err != nil {
+		return usage, err
+	}
+	defer conn.Close()
+	var apps []struct {
+		Plan struct {
+			Memory   int64
+			CpuShare int
+		}
+		Quota struct {
+			InUse int
+		}
+	}
+	err = conn.Apps().Find(bson.M{"pool": name}).Select(bson.M{
+		"plan.memory":   1,
+		"plan.cpushare": 1,
+		"quota.inuse":   1,
+	}).All(&apps)
+	if err != nil {
+		return usage, err
+	}
+	for _, a := range apps {
+		usage.Apps++
+		usage.Units += a.Quota.InUse
+		usage.Memory += int64(a.Quota.InUse) * a.Plan.Memory
+		usage.CPUShare += a.Quota.InUse * a.Plan.CpuShare
+	}
+	return usage, nil
+}
+
+// CheckPoolQuota returns a *PoolQuotaExceededError if adding the requested
+// resources to the pool would exceed any of the limits in its quota. Unknown
+// pools have no quota.
+func CheckPoolQuota(name string, requested PoolUsage) error {
+	pool, err := GetPoolByName(name)
+	if err == ErrPoolNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pool.Quota.Unlimited() {
+		return nil
+	}
+	usage, err := GetPoolUsage(name)
+	if err != nil {
+		return err
+	}
+	checks := []struct {
+		resource         string
+		limit, inUse, rq int64
+	}{
+		{"apps", int64(pool.Quota.MaxApps), int64(usage.Apps), int64(requested.Apps)},
+		{"units", int64(pool.Quota.MaxUnits), int64(usage.Units), int64(requested.Units)},
+		{"memory", pool.Quota.MaxMemory, usage.Memory, requested.Memory},
+		{"cpu share", int64(pool.Quota.MaxCPUShare), int64(usage.CPUShare), int64(requested.CPUShare)},
+	}
+	for _, c := range checks {
+		if c.limit > 0 && c.rq > 0 && c.inUse+c.rq > c.limit {
+			return &PoolQuotaExceededError{
+				Pool:      name,
+				Resource:  c.resource,
+				Limit:     c.limit,
+				InUse:     c.inUse,
+				Requested: c.rq,
+			}
+		}
+	}
+	return nil
+}
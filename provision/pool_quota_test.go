@@ -0,0 +1,85 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) insertQuotaApp(c *check.C, name, pool string, units int, memory int64, cpuShare int) {
+	err := s.storage.Apps().Insert(bson.M{
+		"name":  name,
+		"pool":  pool,
+		"plan":  bson.M{"memory": memory, "cpushare": cpuShare},
+		"quota": bson.M{"limit": -1, "inuse": units},
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestSetPoolQuota(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	quota := PoolQuota{MaxApps: 10, MaxUnits: 20, MaxMemory: 1024, MaxCPUShare: 100}
+	err = SetPoolQuota("pool1", quota)
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Quota, check.DeepEquals, quota)
+	err = SetPoolQuota("pool1", PoolQuota{})
+	c.Assert(err, check.IsNil)
+	p, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Quota.Unlimited(), check.Equals, true)
+}
+
+func (s *S) TestSetPoolQuotaErrors(c *check.C) {
+	err := SetPoolQuota("notfound", PoolQuota{MaxApps: 1})
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+	err = AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolQuota("pool1", PoolQuota{MaxUnits: -1})
+	c.Assert(err, check.Equals, ErrInvalidPoolQuota)
+}
+
+func (s *S) TestGetPoolUsage(c *check.C) {
+	s.insertQuotaApp(c, "app1", "pool1", 2, 512, 10)
+	s.insertQuotaApp(c, "app2", "pool1", 3, 256, 20)
+	s.insertQuotaApp(c, "app3", "pool2", 5, 1024, 50)
+	usage, err := GetPoolUsage("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, PoolUsage{Apps: 2, Units: 5, Memory: 1792, CPUShare: 80})
+	usage, err = GetPoolUsage("empty")
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, PoolUsage{})
+}
+
+func (s *S) TestCheckPoolQuota(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	s.insertQuotaApp(c, "app1", "pool1", 2, 512, 10)
+	err = CheckPoolQuota("pool1", PoolUsage{Apps: 100, Units: 100})
+	c.Assert(err, check.IsNil)
+	err = SetPoolQuota("pool1", PoolQuota{MaxApps: 2, MaxUnits: 4, MaxMemory: 2048})
+	c.Assert(err, check.IsNil)
+	err = CheckPoolQuota("pool1", PoolUsage{Apps: 1})
+	c.Assert(err, check.IsNil)
+	err = CheckPoolQuota("pool1", PoolUsage{Apps: 2})
+	c.Assert(err, check.DeepEquals, &PoolQuotaExceededError{
+		Pool: "pool1", Resource: "apps", Limit: 2, InUse: 1, Requested: 2,
+	})
+	err = CheckPoolQuota("pool1", PoolUsage{Units: 3, Memory: 3 * 512})
+	c.Assert(err, check.DeepEquals, &PoolQuotaExceededError{
+		Pool: "pool1", Resource: "units", Limit: 4, InUse: 2, Requested: 3,
+	})
+	err = CheckPoolQuota("pool1", PoolUsage{Units: 2, Memory: 2 * 1024})
+	c.Assert(err, check.DeepEquals, &PoolQuotaExceededError{
+		Pool: "pool1", Resource: "memory", Limit: 2048, InUse: 1024, Requested: 2048,
+	})
+	err = CheckPoolQuota("pool1", PoolUsage{Units: 2, CPUShare: 1000})
+	c.Assert(err, check.IsNil)
+	err = CheckPoolQuota("notfound", PoolUsage{Apps: 1})
+	c.Assert(err, check.IsNil)
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import "github.com/pkg/errors"
+
+const (
+	SchedulerStrategySpread  = "spread"
+	SchedulerStrategyBinpack = "binpack"
+
+	AntiAffinityPreferred = "preferred"
+	AntiAffinityRequired  = "required"
+	AntiAffinityNone      = "none"
+)
+
+var ErrInvalidPoolScheduler = errors.New("invalid pool scheduler, strategy must be spread or binpack, anti affinity must be preferred, required or none and max units per node must not be negative")
+
+// PoolScheduler configures how units are placed in the nodes of a pool. The
+// zero value keeps the default behavior: units are spread across nodes,
+// preferring nodes without units of the same app process.
+type PoolScheduler struct {
+	// Strategy is either spread, placing units in the nodes with less
+	// units, or binpack, filling the nodes with more units first.
+	Strategy string `json:"strategy,omitempty" bson:",omitempty"`
+	// AntiAffinity controls whether units of the same app process share
+	// nodes: preferred avoids it when possible, required never places two
+	// of them in the same node and none ignores it.
+	AntiAffinity string `json:"antiAffinity,omitempty" bson:",omitempty"`
+	// MaxUnitsPerNode limits the number of units in each node of the pool,
+	// zero means unlimited.
+	MaxUnitsPerNode int `json:"maxUnitsPerNode,omitempty" bson:",omitempty"`
+}
+
+func (s PoolScheduler) validate() error {
+	switch s.Strategy {
+	case "", SchedulerStrategySpread, SchedulerStrategyBinpack:
+	default:
+		return ErrInvalidPoolScheduler
+	}
+	switch s.AntiAffinity {
+	case "", AntiAffinityPreferred, AntiAffinityRequired, AntiAffinityNone:
+	default:
+		return ErrInvalidPoolScheduler
+	}
+	if s.MaxUnitsPerNode < 0 {
+		return ErrInvalidPoolScheduler
+	}
+	return nil
+}
+
+// IsEmpty returns whether the scheduler keeps the default behavior.
+func (s PoolScheduler) IsEmpty() bool {
+	return s == PoolScheduler{}
+}
+
+// GetStrategy returns the strategy, defaulting to spread.
+func (s PoolScheduler) GetStrategy() string {
+	if s.Strategy == "" {
+		return SchedulerStrategySpread
+	}
+	return s.Strategy
+}
+
+// GetAntiAffinity returns the anti affinity policy, defaulting to preferred.
+func (s PoolScheduler) GetAntiAffinity() string {
+	if s.AntiAffinity == "" {
+		return AntiAffinityPreferred
+	}
+	return s.AntiAffinity
+}
+
+// GetPoolScheduler returns the scheduler configuration of the pool. Unknown
+// pools use the default configuration.
+func GetPoolScheduler(name string) (PoolScheduler, error) {
+	pool, err := GetPoolByName(name)
+	if err == ErrPoolNotFound {
+		return PoolScheduler{}, nil
+	}
+	if err != nil {
+		return PoolScheduler{}, err
+	}
+	return pool.Scheduler, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import "gopkg.in/check.v1"
+
+func (s *S) TestAddPoolWithScheduler(c *check.C) {
+	sched := PoolScheduler{Strategy: SchedulerStrategyBinpack, MaxUnitsPerNode: 20}
+	err := AddPool(AddPoolOptions{Name: "pool1", Scheduler: sched})
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Scheduler, check.DeepEquals, sched)
+	err = AddPool(AddPoolOptions{Name: "pool2", Scheduler: PoolScheduler{AntiAffinity: "sometimes"}})
+	c.Assert(err, check.Equals, ErrInvalidPoolScheduler)
+	_, err = GetPoolByName("pool2")
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+}
+
+func (s *S) TestPoolUpdateScheduler(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", Scheduler: PoolScheduler{MaxUnitsPerNode: 5}})
+	c.Assert(err, check.IsNil)
+	err = PoolUpdate("pool1", UpdatePoolOptions{State: PoolStateDraining})
+	c.Assert(err, check.IsNil)
+	sched, err := GetPoolScheduler("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(sched, check.DeepEquals, PoolScheduler{MaxUnitsPerNode: 5})
+	err = PoolUpdate("pool1", UpdatePoolOptions{Scheduler: &PoolScheduler{AntiAffinity: AntiAffinityRequired}})
+	c.Assert(err, check.IsNil)
+	sched, err = GetPoolScheduler("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(sched, check.DeepEquals, PoolScheduler{AntiAffinity: AntiAffinityRequired})
+	err = PoolUpdate("pool1", UpdatePoolOptions{Scheduler: &PoolScheduler{MaxUnitsPerNode: -1}})
+	c.Assert(err, check.Equals, ErrInvalidPoolScheduler)
+	err = PoolUpdate("pool1", UpdatePoolOptions{Scheduler: &PoolScheduler{}})
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Scheduler.IsEmpty(), check.Equals, true)
+	c.Assert(p.Scheduler.GetStrategy(), check.Equals, SchedulerStrategySpread)
+	c.Assert(p.Scheduler.GetAntiAffinity(), check.Equals, AntiAffinityPreferred)
+}
+
+func (s *S) TestGetPoolSchedulerUnknownPool(c *check.C) {
+	sched, err := GetPoolScheduler("unknown")
+	c.Assert(err, check.IsNil)
+	c.Assert(sched.IsEmpty(), check.Equals, true)
+}
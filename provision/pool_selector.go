@@ -0,0 +1,209 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	PoolSelectorDefault      = "default"
+	PoolSelectorRoundRobin   = "round-robin"
+	PoolSelectorLeastLoaded  = "least-loaded"
+	PoolSelectorTeamAffinity = "team-affinity"
+)
+
+// PoolSelector chooses the pool of apps created without one.
+type PoolSelector interface {
+	SelectPool(opts SelectPoolOptions) (*Pool, error)
+}
+
+// SelectPoolOptions describes the app whose pool is being selected.
+type SelectPoolOptions struct {
+	TeamOwner string
+}
+
+var poolSelectors = map[string]PoolSelector{
+	PoolSelectorDefault:      defaultPoolSelector{},
+	PoolSelectorRoundRobin:   roundRobinPoolSelector{},
+	PoolSelectorLeastLoaded:  leastLoadedPoolSelector{},
+	PoolSelectorTeamAffinity: teamAffinityPoolSelector{},
+}
+
+// RegisterPoolSelector registers a new pool selection strategy.
+func RegisterPoolSelector(name string, selector PoolSelector) {
+	poolSelectors[name] = selector
+}
+
+// UnregisterPoolSelector unregisters a pool selection strategy.
+func UnregisterPoolSelector(name string) {
+	delete(poolSelectors, name)
+}
+
+// PoolSelectorName returns the name of the pool selection strategy set in
+// the pools:selector setting, defaulting to "default".
+func PoolSelectorName() string {
+	name, _ := config.GetString("pools:selector")
+	if name == "" {
+		return PoolSelectorDefault
+	}
+	return name
+}
+
+// SelectPool chooses a pool for a new app using the configured strategy.
+func SelectPool(opts SelectPoolOptions) (*Pool, error) {
+	name := PoolSelectorName()
+	selector, ok := poolSelectors[name]
+	if !ok {
+		return nil, errors.Errorf("unknown pool selector: %q", name)
+	}
+	return selector.SelectPool(opts)
+}
+
+// candidatePools returns the pools the team may use that accept new apps,
+// sorted by name.
+func candidatePools(team string) ([]Pool, error) {
+	pools, err := ListPossiblePools([]string{team})
+	if err != nil {
+		return nil, err
+	}
+	var result []Pool
+	for _, p := range pools {
+		if p.AcceptsNewApps() {
+			result = append(result, p)
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrPoolNotFound
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// defaultPoolSelector chooses the only pool exclusive to the team, failing if
+// there are many of them, or the default pool if there are none.
+type defaultPoolSelector struct{}
+
+func (defaultPoolSelector) SelectPool(opts SelectPoolOptions) (*Pool, error) {
+	teamPools, err := ListPoolsForTeam(opts.TeamOwner)
+	if err != nil {
+		return nil, err
+	}
+	var pools []Pool
+	for _, p := range teamPools {
+		if p.AcceptsNewApps() {
+			pools = append(pools, p)
+		}
+	}
+	if len(pools) > 1 {
+		var names []string
+		for _, p := range pools {
+			names = append(names, fmt.Sprintf("%q", p.Name))
+		}
+		return nil, errors.Errorf("you have access to %s pools. Please choose one in app creation", strings.Join(names, ","))
+	}
+	if len(pools) == 1 {
+		return &pools[0], nil
+	}
+	return GetDefaultPool()
+}
+
+// roundRobinPoolSelector rotates among the pools the team may use.
+type roundRobinPoolSelector struct{}
+
+func (roundRobinPoolSelector) SelectPool(opts SelectPoolOptions) (*Pool, error) {
+	pools, err := candidatePools(opts.TeamOwner)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var counter struct {
+		Next int
+	}
+	_, err = conn.Collection("pool_selector").FindId(PoolSelectorRoundRobin).Apply(mgo.Change{
+		Update: bson.M{"$inc": bson.M{"next": 1}},
+		Upsert: true,
+	}, &counter)
+	if err != nil {
+		return nil, err
+	}
+	return &pools[counter.Next%len(pools)], nil
+}
+
+// leastLoadedPoolSelector chooses the pool with fewer apps among the pools
+// the team may use.
+type leastLoadedPoolSelector struct{}
+
+func (leastLoadedPoolSelector) SelectPool(opts SelectPoolOptions) (*Pool, error) {
+	pools, err := candidatePools(opts.TeamOwner)
+	if err != nil {
+		return nil, err
+	}
+	return poolWithBestCount(pools, bson.M{}, false)
+}
+
+// teamAffinityPoolSelector chooses the pool with more apps of the team among
+// the pools the team may use, falling back to the default pool, or to the
+// first pool, when the team has no apps in them.
+type teamAffinityPoolSelector struct{}
+
+func (teamAffinityPoolSelector) SelectPool(opts SelectPoolOptions) (*Pool, error) {
+	pools, err := candidatePools(opts.TeamOwner)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := poolWithBestCount(pools, bson.M{"teamowner": opts.TeamOwner}, true)
+	if err != nil || pool != nil {
+		return pool, err
+	}
+	for i := range pools {
+		if pools[i].Default {
+			return &pools[i], nil
+		}
+	}
+	return &pools[0], nil
+}
+
+// poolWithBestCount returns the pool with the least, or the most, apps
+// matching the query. When looking for the most apps, pools without any
+// matching apps are never returned.
+func poolWithBestCount(pools []Pool, query bson.M, most bool) (*Pool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var best *Pool
+	var bestCount int
+	for i := range pools {
+		query["pool"] = pools[i].Name
+		count, err := conn.Apps().Find(query).Count()
+		if err != nil {
+			return nil, err
+		}
+		if most && count == 0 {
+			continue
+		}
+		if best == nil || (most && count > bestCount) || (!most && count < bestCount) {
+			best = &pools[i]
+			bestCount = count
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) addSelectorPools(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", Default: true})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool3"})
+	c.Assert(err, check.IsNil)
+	err = AddTeamsToPool("pool3", []string{"ateam"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestSelectPoolDefault(c *check.C) {
+	s.addSelectorPools(c)
+	pool, err := SelectPool(SelectPoolOptions{TeamOwner: "ateam"})
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Name, check.Equals, "pool3")
+	pool, err = SelectPool(SelectPoolOptions{TeamOwner: "test"})
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Name, check.Equals, "pool1")
+	err = AddPool(AddPoolOptions{Name: "pool4"})
+	c.Assert(err, check.IsNil)
+	err = AddTeamsToPool("pool4", []string{"ateam"})
+	c.Assert(err, check.IsNil)
+	_, err = SelectPool(SelectPoolOptions{TeamOwner: "ateam"})
+	c.Assert(err, check.ErrorMatches, `you have access to "pool3","pool4" pools. Please choose one in app creation`)
+}
+
+func (s *S) TestSelectPoolRoundRobin(c *check.C) {
+	config.Set("pools:selector", "round-robin")
+	defer config.Unset("pools:selector")
+	s.addSelectorPools(c)
+	var names []string
+	for i := 0; i < 4; i++ {
+		pool, err := SelectPool(SelectPoolOptions{TeamOwner: "test"})
+		c.Assert(err, check.IsNil)
+		names = append(names, pool.Name)
+	}
+	c.Assert(names, check.DeepEquals, []string{"pool1", "pool2", "pool1", "pool2"})
+}
+
+func (s *S) TestSelectPoolLeastLoaded(c *check.C) {
+	config.Set("pools:selector", "least-loaded")
+	defer config.Unset("pools:selector")
+	s.addSelectorPools(c)
+	err := s.storage.Apps().Insert(bson.M{"name": "a1", "pool": "pool1"}, bson.M{"name": "a2", "pool": "pool3"})
+	c.Assert(err, check.IsNil)
+	pool, err := SelectPool(SelectPoolOptions{TeamOwner: "ateam"})
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Name, check.Equals, "pool2")
+}
+
+func (s *S) TestSelectPoolTeamAffinity(c *check.C) {
+	config.Set("pools:selector", "team-affinity")
+	defer config.Unset("pools:selector")
+	s.addSelectorPools(c)
+	pool, err := SelectPool(SelectPoolOptions{TeamOwner: "ateam"})
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Name, check.Equals, "pool1")
+	err = s.storage.Apps().Insert(
+		bson.M{"name": "a1", "pool": "pool2", "teamowner": "ateam"},
+		bson.M{"name": "a2", "pool": "pool3", "teamowner": "ateam"},
+		bson.M{"name": "a3", "pool": "pool3", "teamowner": "ateam"},
+		bson.M{"name": "a4", "pool": "pool2", "teamowner": "test"},
+		bson.M{"name": "a5", "pool": "pool2", "teamowner": "test"},
+	)
+	c.Assert(err, check.IsNil)
+	pool, err = SelectPool(SelectPoolOptions{TeamOwner: "ateam"})
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Name, check.Equals, "pool3")
+}
+
+func (s *S) TestSelectPoolCustomSelector(c *check.C) {
+	RegisterPoolSelector("fixed", fixedPoolSelector{name: "pool2"})
+	defer UnregisterPoolSelector("fixed")
+	config.Set("pools:selector", "fixed")
+	defer config.Unset("pools:selector")
+	s.addSelectorPools(c)
+	pool, err := SelectPool(SelectPoolOptions{TeamOwner: "ateam"})
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Name, check.Equals, "pool2")
+	config.Set("pools:selector", "unknown")
+	_, err = SelectPool(SelectPoolOptions{TeamOwner: "ateam"})
+	c.Assert(err, check.ErrorMatches, `unknown pool selector: "unknown"`)
+}
+
+type fixedPoolSelector struct {
+	name string
+}
+
+func (s fixedPoolSelector) SelectPool(opts SelectPoolOptions) (*Pool, error) {
+	return GetPoolByName(s.name)
+}
@@ -8,6 +8,7 @@ import (
 	"reflect"
 
 	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
@@ -57,6 +58,32 @@ func (s *S) TestAddPool(c *check.C) {
 	c.Assert(pool, check.DeepEquals, &Pool{Name: "pool1"})
 }
 
+func (s *S) TestAddPoolWithLabelsAndAnnotations(c *check.C) {
+	opts := AddPoolOptions{
+		Name:        "pool1",
+		Labels:      map[string]string{"env": "prod"},
+		Annotations: map[string]string{"cost-center": "1234"},
+	}
+	err := AddPool(opts)
+	c.Assert(err, check.IsNil)
+	pool, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool, check.DeepEquals, &Pool{
+		Name:        "pool1",
+		Labels:      map[string]string{"env": "prod"},
+		Annotations: map[string]string{"cost-center": "1234"},
+	})
+}
+
+func (s *S) TestAddPoolInvalidLabel(c *check.C) {
+	for _, key := range []string{"", "a.b", "$where"} {
+		err := AddPool(AddPoolOptions{Name: "pool1", Labels: map[string]string{key: "x"}})
+		c.Check(err, check.Equals, ErrInvalidPoolMetadataKey)
+	}
+	_, err := GetPoolByName("pool1")
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+}
+
 func (s *S) TestAddNonPublicPool(c *check.C) {
 	coll := s.storage.Pools()
 	opts := AddPoolOptions{
@@ -278,6 +305,38 @@ func (s *S) TestRemoveTeamsFromPoolWithBlacklistShouldFail(c *check.C) {
 	c.Assert(constraint.Values, check.DeepEquals, []string{"myteam"})
 }
 
+func (s *S) TestSetPoolTeams(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = AddTeamsToPool("pool1", []string{"test", "ateam"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolTeams("pool1", []string{"pteam", "ateam", "pteam"})
+	c.Assert(err, check.IsNil)
+	constraint, err := getExactConstraintForPool("pool1", "team")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraint.Values, check.DeepEquals, []string{"pteam", "ateam"})
+}
+
+func (s *S) TestSetPoolTeamsErrors(c *check.C) {
+	err := SetPoolTeams("pool1", []string{"ateam"})
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+	err = AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolTeams("pool1", []string{""})
+	c.Assert(err, check.Equals, ErrPoolTeamsRequired)
+	err = AddPool(AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	err = SetPoolTeams("pool2", []string{"ateam"})
+	c.Assert(err, check.Equals, ErrPublicDefaultPoolCantHaveTeams)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "pool1", Field: "team", Values: []string{"ateam"}, Blacklist: true})
+	c.Assert(err, check.IsNil)
+	err = SetPoolTeams("pool1", []string{"test"})
+	c.Assert(err, check.NotNil)
+	constraint, err := getExactConstraintForPool("pool1", "team")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraint.Values, check.DeepEquals, []string{"ateam"})
+}
+
 func boolPtr(v bool) *bool {
 	return &v
 }
@@ -301,6 +360,45 @@ func (s *S) TestPoolUpdate(c *check.C) {
 	c.Assert(constraint.AllowsAll(), check.Equals, true)
 }
 
+func (s *S) TestPoolUpdateLabelsAndAnnotations(c *check.C) {
+	err := AddPool(AddPoolOptions{
+		Name:   "pool1",
+		Labels: map[string]string{"env": "dev", "region": "us-east"},
+	})
+	c.Assert(err, check.IsNil)
+	err = PoolUpdate("pool1", UpdatePoolOptions{
+		Labels:      map[string]string{"env": "prod", "region": ""},
+		Annotations: map[string]string{"owner": "infra"},
+	})
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Labels, check.DeepEquals, map[string]string{"env": "prod"})
+	c.Assert(p.Annotations, check.DeepEquals, map[string]string{"owner": "infra"})
+	err = PoolUpdate("pool1", UpdatePoolOptions{Labels: map[string]string{"a.b": "c"}})
+	c.Assert(err, check.Equals, ErrInvalidPoolMetadataKey)
+}
+
+func (s *S) TestPoolUpdateGroup(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", Group: "dev"})
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Group, check.Equals, "dev")
+	group := "prod"
+	err = PoolUpdate("pool1", UpdatePoolOptions{Group: &group})
+	c.Assert(err, check.IsNil)
+	p, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Group, check.Equals, "prod")
+	group = ""
+	err = PoolUpdate("pool1", UpdatePoolOptions{Group: &group})
+	c.Assert(err, check.IsNil)
+	p, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Group, check.Equals, "")
+}
+
 func (s *S) TestPoolUpdateToDefault(c *check.C) {
 	opts := AddPoolOptions{
 		Name:    "pool1",
@@ -351,6 +449,53 @@ func (s *S) TestPoolUpdateDontHaveSideEffects(c *check.C) {
 	c.Assert(constraint.AllowsAll(), check.Equals, true)
 }
 
+func (s *S) TestPoolUpdateState(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.GetState(), check.Equals, PoolStateActive)
+	c.Assert(p.AcceptsNewApps(), check.Equals, true)
+	c.Assert(p.AcceptsNewUnits(), check.Equals, true)
+	err = PoolUpdate("pool1", UpdatePoolOptions{State: PoolStateDraining})
+	c.Assert(err, check.IsNil)
+	p, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.GetState(), check.Equals, PoolStateDraining)
+	c.Assert(p.AcceptsNewApps(), check.Equals, false)
+	c.Assert(p.AcceptsNewUnits(), check.Equals, true)
+	err = PoolUpdate("pool1", UpdatePoolOptions{State: PoolStateArchived})
+	c.Assert(err, check.IsNil)
+	p, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.AcceptsNewApps(), check.Equals, false)
+	c.Assert(p.AcceptsNewUnits(), check.Equals, false)
+	err = PoolUpdate("pool1", UpdatePoolOptions{State: PoolStateActive})
+	c.Assert(err, check.IsNil)
+	p, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.State, check.Equals, "")
+	c.Assert(p.GetState(), check.Equals, PoolStateActive)
+}
+
+func (s *S) TestPoolUpdateStateErrors(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", Default: true})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool2"})
+	c.Assert(err, check.IsNil)
+	err = PoolUpdate("pool2", UpdatePoolOptions{State: "decommissioned"})
+	c.Assert(err, check.Equals, ErrInvalidPoolState)
+	err = PoolUpdate("pool1", UpdatePoolOptions{State: PoolStateDraining})
+	c.Assert(err, check.Equals, ErrDefaultPoolMustBeActive)
+	err = PoolUpdate("pool2", UpdatePoolOptions{State: PoolStateDraining})
+	c.Assert(err, check.IsNil)
+	err = PoolUpdate("pool2", UpdatePoolOptions{Default: boolPtr(true), Force: true})
+	c.Assert(err, check.Equals, ErrDefaultPoolMustBeActive)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Default, check.Equals, true)
+}
+
 func (s *S) TestListPool(c *check.C) {
 	err := AddPool(AddPoolOptions{Name: "pool1"})
 	c.Assert(err, check.IsNil)
@@ -393,6 +538,62 @@ func (s *S) TestListPoolEmpty(c *check.C) {
 	c.Assert(pools, check.HasLen, 0)
 }
 
+func (s *S) TestFilterPoolsByLabels(c *check.C) {
+	pools := []Pool{
+		{Name: "pool1", Labels: map[string]string{"env": "prod", "region": "us"}},
+		{Name: "pool2", Labels: map[string]string{"env": "prod", "region": "eu"}},
+		{Name: "pool3"},
+	}
+	result, err := FilterPools(pools, PoolFilter{Labels: map[string]string{"env": "prod"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, pools[:2])
+	result, err = FilterPools(pools, PoolFilter{Labels: map[string]string{"env": "prod", "region": "eu"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, pools[1:2])
+	result, err = FilterPools(pools, PoolFilter{Labels: map[string]string{"env": ""}})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 0)
+}
+
+func (s *S) TestFilterPools(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", Default: true})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool2", Provisioner: "kubernetes"})
+	c.Assert(err, check.IsNil)
+	err = AddTeamsToPool("pool2", []string{"ateam"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool3"})
+	c.Assert(err, check.IsNil)
+	err = AddTeamsToPool("pool3", []string{"other"})
+	c.Assert(err, check.IsNil)
+	pools := []Pool{
+		{Name: "pool1", Default: true},
+		{Name: "pool2", Provisioner: "kubernetes"},
+		{Name: "pool3"},
+	}
+	isDefault := false
+	tests := []struct {
+		filter   PoolFilter
+		expected []string
+	}{
+		{PoolFilter{}, []string{"pool1", "pool2", "pool3"}},
+		{PoolFilter{Provisioner: "kubernetes"}, []string{"pool2"}},
+		{PoolFilter{Provisioner: DefaultProvisioner}, []string{"pool1", "pool3"}},
+		{PoolFilter{Default: &isDefault}, []string{"pool2", "pool3"}},
+		{PoolFilter{Team: "ateam"}, []string{"pool1", "pool2"}},
+		{PoolFilter{Team: "ateam", Default: &isDefault}, []string{"pool2"}},
+	}
+	for i, tt := range tests {
+		filtered, err := FilterPools(pools, tt.filter)
+		c.Assert(err, check.IsNil)
+		var names []string
+		for _, p := range filtered {
+			names = append(names, p.Name)
+		}
+		c.Check(names, check.DeepEquals, tt.expected, check.Commentf("test %d", i))
+	}
+}
+
 func (s *S) TestGetPoolByName(c *check.C) {
 	coll := s.storage.Pools()
 	pool := Pool{Name: "pool1", Default: true}
@@ -406,6 +607,66 @@ func (s *S) TestGetPoolByName(c *check.C) {
 	c.Assert(err, check.NotNil)
 }
 
+func (s *S) TestSetPoolEnvs(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolEnvs("pool1", map[string]string{"HTTP_PROXY": "http://proxy:3128", "REGION": "dc1"})
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Env, check.DeepEquals, map[string]string{"HTTP_PROXY": "http://proxy:3128", "REGION": "dc1"})
+	err = SetPoolEnvs("pool1", map[string]string{"REGION": "dc2"})
+	c.Assert(err, check.IsNil)
+	p, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Env, check.DeepEquals, map[string]string{"REGION": "dc2"})
+	err = SetPoolEnvs("pool1", nil)
+	c.Assert(err, check.IsNil)
+	p, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Env, check.IsNil)
+}
+
+func (s *S) TestSetPoolEnvsErrors(c *check.C) {
+	err := SetPoolEnvs("notfound", map[string]string{"REGION": "dc1"})
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+	err = AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolEnvs("pool1", map[string]string{"a.b": "c"})
+	c.Assert(err, check.Equals, ErrInvalidPoolEnvName)
+}
+
+type envsApp struct {
+	App
+	pool string
+	envs map[string]bind.EnvVar
+}
+
+func (a *envsApp) GetPool() string {
+	return a.pool
+}
+
+func (a *envsApp) Envs() map[string]bind.EnvVar {
+	return a.envs
+}
+
+func (s *S) TestEnvsForAppWithPoolEnvs(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolEnvs("pool1", map[string]string{"REGION": "dc1", "HTTP_PROXY": "http://proxy:3128"})
+	c.Assert(err, check.IsNil)
+	a := &envsApp{pool: "pool1", envs: map[string]bind.EnvVar{
+		"REGION": {Name: "REGION", Value: "custom"},
+	}}
+	envs := EnvsForApp(a, "web", false)
+	c.Assert(envs[:2], check.DeepEquals, []bind.EnvVar{
+		{Name: "HTTP_PROXY", Value: "http://proxy:3128", Public: true},
+		{Name: "REGION", Value: "custom"},
+	})
+	envs = EnvsForApp(a, "web", true)
+	c.Assert(envs, check.DeepEquals, []bind.EnvVar{{Name: "TSURU_HOST", Value: ""}})
+}
+
 func (s *S) TestSetPoolConstraints(c *check.C) {
 	coll := s.storage.PoolsConstraints()
 	err := SetPoolConstraint(&PoolConstraint{PoolExpr: "*", Field: "router", Values: []string{"planb", "hipache"}})
@@ -447,6 +708,55 @@ func (s *S) TestSetPoolConstraintInvalidConstraintType(c *check.C) {
 	c.Assert(len(cs), check.Equals, 0)
 }
 
+func (s *S) TestSetPoolConstraintInvalidRegex(c *check.C) {
+	for _, expr := range []string{"re:", "re:^prod-(.*$"} {
+		err := SetPoolConstraint(&PoolConstraint{PoolExpr: expr, Field: "router", Values: []string{"planb"}})
+		c.Check(err, check.Equals, ErrInvalidPoolExpr)
+		err = AppendPoolConstraint(&PoolConstraint{PoolExpr: expr, Field: "router", Values: []string{"planb"}})
+		c.Check(err, check.Equals, ErrInvalidPoolExpr)
+	}
+	constraints, err := ListPoolsConstraints(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.HasLen, 0)
+}
+
+func (s *S) TestGetConstraintsForPoolRegex(c *check.C) {
+	err := SetPoolConstraint(&PoolConstraint{PoolExpr: "prod-*", Field: "router", Values: []string{"planb"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "re:^prod-.*-eu$", Field: "router", Values: []string{"galeb-eu"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "prod-api-eu", Field: "team", Values: []string{"api"}})
+	c.Assert(err, check.IsNil)
+	tt := []struct {
+		pool     string
+		expected map[string]*PoolConstraint
+	}{
+		{pool: "prod-web-eu", expected: map[string]*PoolConstraint{
+			"router": {PoolExpr: "re:^prod-.*-eu$", Field: "router", Values: []string{"galeb-eu"}},
+		}},
+		{pool: "prod-web-us", expected: map[string]*PoolConstraint{
+			"router": {PoolExpr: "prod-*", Field: "router", Values: []string{"planb"}},
+		}},
+		{pool: "prod-web-eu-2", expected: map[string]*PoolConstraint{
+			"router": {PoolExpr: "prod-*", Field: "router", Values: []string{"planb"}},
+		}},
+	}
+	for i, t := range tt {
+		constraints, err := getConstraintsForPool(t.pool)
+		c.Check(err, check.IsNil)
+		if !reflect.DeepEqual(constraints, t.expected) {
+			c.Fatalf("(%d) Expected %#+v for pool %q. Got %#+v.", i, t.expected, t.pool, constraints)
+		}
+	}
+	constraints, err := MatchingPoolConstraints("prod-api-eu")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.DeepEquals, []*PoolConstraint{
+		{PoolExpr: "prod-api-eu", Field: "team", Values: []string{"api"}},
+		{PoolExpr: "re:^prod-.*-eu$", Field: "router", Values: []string{"galeb-eu"}},
+		{PoolExpr: "prod-*", Field: "router", Values: []string{"planb"}},
+	})
+}
+
 func (s *S) TestGetConstraintsForPool(c *check.C) {
 	err := SetPoolConstraint(&PoolConstraint{PoolExpr: "*", Field: "router", Values: []string{"planb"}})
 	c.Assert(err, check.IsNil)
@@ -489,6 +799,142 @@ func (s *S) TestGetConstraintsForPool(c *check.C) {
 	}
 }
 
+func (s *S) TestGetConstraintsForPoolLayers(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", Group: "dev"})
+	c.Assert(err, check.IsNil)
+	err = AddPool(AddPoolOptions{Name: "pool2", Group: "dev"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{Layer: ConstraintLayerGlobal, Field: "router", Values: []string{"legacy"}, Blacklist: true})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{Layer: ConstraintLayerGroup, PoolExpr: "dev", Field: "router", Values: []string{"planb*"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "pool2", Field: "router", Values: []string{"*"}})
+	c.Assert(err, check.IsNil)
+	tt := []struct {
+		pool     string
+		expected map[string]*PoolConstraint
+	}{
+		{pool: "prod", expected: map[string]*PoolConstraint{
+			"router": {Layer: ConstraintLayerGlobal, Field: "router", Values: []string{"legacy"}, Blacklist: true},
+		}},
+		{pool: "pool1", expected: map[string]*PoolConstraint{
+			"router": {Layer: ConstraintLayerGroup, PoolExpr: "dev", Field: "router", Values: []string{"planb*"}, Inherited: []string{"legacy"}},
+		}},
+		{pool: "pool2", expected: map[string]*PoolConstraint{
+			"router": {PoolExpr: "pool2", Field: "router", Values: []string{"*"}, Inherited: []string{"legacy"}},
+		}},
+	}
+	for i, t := range tt {
+		constraints, err := getConstraintsForPool(t.pool)
+		c.Check(err, check.IsNil)
+		if !reflect.DeepEqual(constraints, t.expected) {
+			c.Fatalf("(%d) Expected %#+v for pool %q. Got %#+v.", i, t.expected, t.pool, constraints)
+		}
+	}
+	applied, err := getConstraintsForPool("pool2")
+	c.Assert(err, check.IsNil)
+	c.Assert(applied["router"].check("galeb"), check.Equals, true)
+	c.Assert(applied["router"].check("legacy"), check.Equals, false)
+	constraints, err := MatchingPoolConstraints("pool2")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.DeepEquals, []*PoolConstraint{
+		{PoolExpr: "pool2", Field: "router", Values: []string{"*"}},
+		{Layer: ConstraintLayerGroup, PoolExpr: "dev", Field: "router", Values: []string{"planb*"}},
+		{Layer: ConstraintLayerGlobal, Field: "router", Values: []string{"legacy"}, Blacklist: true},
+	})
+	err = RemoveConstraint(&PoolConstraint{Layer: ConstraintLayerGroup, PoolExpr: "dev", Field: "router"})
+	c.Assert(err, check.IsNil)
+	err = RemoveConstraint(&PoolConstraint{Layer: ConstraintLayerGroup, PoolExpr: "dev", Field: "router"})
+	c.Assert(err, check.Equals, ErrPoolConstraintNotFound)
+	err = SetPoolConstraint(&PoolConstraint{Layer: "team", Field: "router", Values: []string{"planb"}})
+	c.Assert(err, check.Equals, ErrInvalidConstraintLayer)
+}
+
+func (s *S) TestMatchingPoolConstraints(c *check.C) {
+	err := SetPoolConstraint(&PoolConstraint{PoolExpr: "*", Field: "router", Values: []string{"planb"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "*_dev", Field: "router", Values: []string{"planb_dev"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "pool1_dev", Field: "team", Values: []string{"team_pool1"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "prod", Field: "team", Values: []string{"team_prod"}})
+	c.Assert(err, check.IsNil)
+	constraints, err := MatchingPoolConstraints("pool1_dev")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.DeepEquals, []*PoolConstraint{
+		{PoolExpr: "pool1_dev", Field: "team", Values: []string{"team_pool1"}},
+		{PoolExpr: "*_dev", Field: "router", Values: []string{"planb_dev"}},
+		{PoolExpr: "*", Field: "router", Values: []string{"planb"}},
+	})
+	applied, err := ResolvePoolConstraints("pool1_dev")
+	c.Assert(err, check.IsNil)
+	c.Assert(applied, check.DeepEquals, map[string]*PoolConstraint{
+		"router": {PoolExpr: "*_dev", Field: "router", Values: []string{"planb_dev"}},
+		"team":   {PoolExpr: "pool1_dev", Field: "team", Values: []string{"team_pool1"}},
+	})
+	constraints, err = MatchingPoolConstraints("other")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.DeepEquals, []*PoolConstraint{
+		{PoolExpr: "*", Field: "router", Values: []string{"planb"}},
+	})
+}
+
+func (s *S) TestResolvePoolConstraint(c *check.C) {
+	err := s.storage.Platforms().Insert(bson.M{"_id": "python"}, bson.M{"_id": "java"}, bson.M{"_id": "go"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "*", Field: "platform", Values: []string{"go"}, Blacklist: true})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "prod*", Field: "platform", Values: []string{"py*", "ruby"}})
+	c.Assert(err, check.IsNil)
+	resolution, err := ResolvePoolConstraint("prod-1", "platform")
+	c.Assert(err, check.IsNil)
+	c.Assert(resolution, check.DeepEquals, &ConstraintResolution{
+		Pool:  "prod-1",
+		Field: "platform",
+		Matching: []*PoolConstraint{
+			{PoolExpr: "prod*", Field: "platform", Values: []string{"py*", "ruby"}},
+			{PoolExpr: "*", Field: "platform", Values: []string{"go"}, Blacklist: true},
+		},
+		Applied:   &PoolConstraint{PoolExpr: "prod*", Field: "platform", Values: []string{"py*", "ruby"}},
+		Allowed:   []string{"python"},
+		Denied:    []string{"go", "java"},
+		Unmatched: []string{"ruby"},
+	})
+	resolution, err = ResolvePoolConstraint("dev", "platform")
+	c.Assert(err, check.IsNil)
+	c.Assert(resolution.Applied, check.DeepEquals, &PoolConstraint{PoolExpr: "*", Field: "platform", Values: []string{"go"}, Blacklist: true})
+	c.Assert(resolution.Allowed, check.DeepEquals, []string{"java", "python"})
+	c.Assert(resolution.Denied, check.DeepEquals, []string{"go"})
+	c.Assert(resolution.Unmatched, check.DeepEquals, []string{})
+	resolution, err = ResolvePoolConstraint("dev", "team")
+	c.Assert(err, check.IsNil)
+	c.Assert(resolution.Applied, check.IsNil)
+	c.Assert(resolution.Matching, check.HasLen, 0)
+	c.Assert(resolution.Denied, check.DeepEquals, []string{})
+	_, err = ResolvePoolConstraint("dev", "size")
+	c.Assert(err, check.Equals, ErrInvalidConstraintType)
+	_, err = ResolvePoolConstraint("", "team")
+	c.Assert(err, check.Equals, ErrPoolNameIsRequired)
+}
+
+func (s *S) TestRemovePoolConstraint(c *check.C) {
+	err := SetPoolConstraint(&PoolConstraint{PoolExpr: "*_dev", Field: "router", Values: []string{"planb_dev"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "*_dev", Field: "team", Values: []string{"team_dev"}})
+	c.Assert(err, check.IsNil)
+	err = RemovePoolConstraint("*_dev", "router")
+	c.Assert(err, check.IsNil)
+	constraints, err := ListPoolsConstraints(bson.M{"poolexpr": "*_dev"})
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints, check.DeepEquals, []*PoolConstraint{
+		{PoolExpr: "*_dev", Field: "team", Values: []string{"team_dev"}},
+	})
+	err = RemovePoolConstraint("*_dev", "router")
+	c.Assert(err, check.Equals, ErrPoolConstraintNotFound)
+	err = RemovePoolConstraint("*_dev", "size")
+	c.Assert(err, check.Equals, ErrInvalidConstraintType)
+}
+
 func (s *S) TestAppendPoolConstraint(c *check.C) {
 	err := SetPoolConstraint(&PoolConstraint{PoolExpr: "*", Field: "router", Values: []string{"planb"}, Blacklist: true})
 	c.Assert(err, check.IsNil)
@@ -511,6 +957,27 @@ func (s *S) TestAppendPoolConstraintNewConstraint(c *check.C) {
 	})
 }
 
+func (s *S) TestSetPoolConstraintRecordsSetter(c *check.C) {
+	err := SetPoolConstraint(&PoolConstraint{PoolExpr: "*", Field: "router", Values: []string{"planb"}, SetBy: "admin@example.com"})
+	c.Assert(err, check.IsNil)
+	constraints, err := getConstraintsForPool("*")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints["router"].SetBy, check.Equals, "admin@example.com")
+	c.Assert(constraints["router"].SetAt.IsZero(), check.Equals, false)
+	err = AppendPoolConstraint(&PoolConstraint{PoolExpr: "*", Field: "router", Values: []string{"galeb"}, SetBy: "other@example.com"})
+	c.Assert(err, check.IsNil)
+	constraints, err = getConstraintsForPool("*")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints["router"].Values, check.DeepEquals, []string{"planb", "galeb"})
+	c.Assert(constraints["router"].SetBy, check.Equals, "other@example.com")
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "*", Field: "router", Values: []string{"hipache"}})
+	c.Assert(err, check.IsNil)
+	constraints, err = getConstraintsForPool("*")
+	c.Assert(err, check.IsNil)
+	c.Assert(constraints["router"].SetBy, check.Equals, "")
+	c.Assert(constraints["router"].SetAt.IsZero(), check.Equals, true)
+}
+
 func (s *S) TestGetRouters(c *check.C) {
 	config.Set("routers:router1:type", "hipache")
 	config.Set("routers:router2:type", "hipache")
@@ -549,17 +1016,66 @@ func (s *S) TestPoolAllowedValues(c *check.C) {
 	c.Assert(err, check.IsNil)
 	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "pool1", Field: "team", Values: []string{"team1"}})
 	c.Assert(err, check.IsNil)
+	err = s.storage.Platforms().Insert(bson.M{"_id": "python"}, bson.M{"_id": "java"})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "pool1", Field: "platform", Values: []string{"py*"}})
+	c.Assert(err, check.IsNil)
 	constraints, err := pool.allowedValues()
 	c.Assert(err, check.IsNil)
 	c.Assert(constraints, check.DeepEquals, map[string][]string{
-		"team":   {"team1"},
-		"router": {"router1", "router2"},
+		"team":     {"team1"},
+		"router":   {"router1", "router2"},
+		"platform": {"python"},
 	})
 	pool.Name = "other"
 	constraints, err = pool.allowedValues()
 	c.Assert(err, check.IsNil)
 	c.Assert(constraints, check.DeepEquals, map[string][]string{
-		"team":   {"ateam", "test", "pteam", "pubteam", "team1"},
-		"router": {"router", "router1", "router2"},
+		"team":     {"ateam", "test", "pteam", "pubteam", "team1"},
+		"router":   {"router", "router1", "router2"},
+		"platform": {"python", "java"},
 	})
 }
+
+func (s *S) TestPoolAllowsPlanAndCluster(c *check.C) {
+	pool := Pool{Name: "prod1"}
+	allowed, err := pool.AllowsPlan("small")
+	c.Assert(err, check.IsNil)
+	c.Assert(allowed, check.Equals, true)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "prod*", Field: "plan", Values: []string{"large*"}})
+	c.Assert(err, check.IsNil)
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "prod*", Field: "cluster", Values: []string{"dev"}, Blacklist: true})
+	c.Assert(err, check.IsNil)
+	allowed, err = pool.AllowsPlan("small")
+	c.Assert(err, check.IsNil)
+	c.Assert(allowed, check.Equals, false)
+	allowed, err = pool.AllowsPlan("large-memory")
+	c.Assert(err, check.IsNil)
+	c.Assert(allowed, check.Equals, true)
+	allowed, err = pool.AllowsCluster("dev")
+	c.Assert(err, check.IsNil)
+	c.Assert(allowed, check.Equals, false)
+	allowed, err = pool.AllowsCluster("prod")
+	c.Assert(err, check.IsNil)
+	c.Assert(allowed, check.Equals, true)
+}
+
+func (s *S) TestGetPlatforms(c *check.C) {
+	err := s.storage.Platforms().Insert(bson.M{"_id": "python"}, bson.M{"_id": "java"})
+	c.Assert(err, check.IsNil)
+	pool := Pool{Name: "hardened"}
+	err = s.storage.Pools().Insert(pool)
+	c.Assert(err, check.IsNil)
+	platforms, err := pool.GetPlatforms()
+	c.Assert(err, check.IsNil)
+	c.Assert(platforms, check.DeepEquals, []string{"python", "java"})
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "hardened", Field: "platform", Values: []string{"java"}, Blacklist: true})
+	c.Assert(err, check.IsNil)
+	platforms, err = pool.GetPlatforms()
+	c.Assert(err, check.IsNil)
+	c.Assert(platforms, check.DeepEquals, []string{"python"})
+	err = SetPoolConstraint(&PoolConstraint{PoolExpr: "hardened", Field: "platform", Values: []string{"python", "java"}, Blacklist: true})
+	c.Assert(err, check.IsNil)
+	_, err = pool.GetPlatforms()
+	c.Assert(err, check.Equals, ErrPoolHasNoPlatform)
+}
@@ -0,0 +1,87 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidProcessSettings = errors.New("invalid process settings: process names must not be empty or repeated in the start order and grace periods must not be negative")
+
+// ProcessSettings holds how the processes of an app are started and stopped
+// by the provisioners.
+type ProcessSettings struct {
+	// StartOrder lists processes that must be started, one after the other,
+	// before the remaining processes of the app.
+	StartOrder []string `json:"startOrder,omitempty"`
+	// GracePeriods holds the time, in seconds, each process has to shut down
+	// before being killed. Processes not listed use the provisioner default.
+	GracePeriods map[string]int `json:"gracePeriods,omitempty"`
+}
+
+func (s ProcessSettings) Validate() error {
+	seen := map[string]struct{}{}
+	for _, p := range s.StartOrder {
+		if _, ok := seen[p]; ok || p == "" {
+			return ErrInvalidProcessSettings
+		}
+		seen[p] = struct{}{}
+	}
+	for p, seconds := range s.GracePeriods {
+		if p == "" || seconds < 0 {
+			return ErrInvalidProcessSettings
+		}
+	}
+	return nil
+}
+
+func (s ProcessSettings) IsEmpty() bool {
+	return len(s.StartOrder) == 0 && len(s.GracePeriods) == 0
+}
+
+// GracePeriod returns the grace period of the given process, zero means the
+// provisioner default should be used.
+func (s ProcessSettings) GracePeriod(process string) time.Duration {
+	return time.Duration(s.GracePeriods[process]) * time.Second
+}
+
+// StartStages groups the given processes in the order they must be started.
+// Each process in StartOrder has a stage of its own, the remaining processes
+// are started together, in the last stage.
+func (s ProcessSettings) StartStages(processes []string) [][]string {
+	pending := make(map[string]struct{}, len(processes))
+	for _, p := range processes {
+		pending[p] = struct{}{}
+	}
+	var stages [][]string
+	for _, p := range s.StartOrder {
+		if _, ok := pending[p]; ok {
+			stages = append(stages, []string{p})
+			delete(pending, p)
+		}
+	}
+	if len(pending) > 0 {
+		last := make([]string, 0, len(pending))
+		for p := range pending {
+			last = append(last, p)
+		}
+		sort.Strings(last)
+		stages = append(stages, last)
+	}
+	return stages
+}
+
+// SortProcesses returns the given processes in the order they must be
+// started.
+func (s ProcessSettings) SortProcesses(processes []string) []string {
+	result := make([]string, 0, len(processes))
+	for _, stage := range s.StartStages(processes) {
+		result = append(result, stage...)
+	}
+	return result
+}
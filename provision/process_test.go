@@ -0,0 +1,49 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision_test
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestProcessSettingsValidate(c *check.C) {
+	valid := provision.ProcessSettings{
+		StartOrder:   []string{"migrate", "web"},
+		GracePeriods: map[string]int{"web": 30, "worker": 0},
+	}
+	c.Assert(valid.Validate(), check.IsNil)
+	invalid := []provision.ProcessSettings{
+		{StartOrder: []string{"migrate", "migrate"}},
+		{StartOrder: []string{""}},
+		{GracePeriods: map[string]int{"web": -1}},
+		{GracePeriods: map[string]int{"": 10}},
+	}
+	for _, settings := range invalid {
+		c.Check(settings.Validate(), check.Equals, provision.ErrInvalidProcessSettings)
+	}
+}
+
+func (s *S) TestProcessSettingsGracePeriod(c *check.C) {
+	settings := provision.ProcessSettings{GracePeriods: map[string]int{"web": 30}}
+	c.Assert(settings.GracePeriod("web"), check.Equals, 30*time.Second)
+	c.Assert(settings.GracePeriod("worker"), check.Equals, time.Duration(0))
+}
+
+func (s *S) TestProcessSettingsStartStages(c *check.C) {
+	settings := provision.ProcessSettings{StartOrder: []string{"migrate", "missing", "web"}}
+	processes := []string{"worker", "web", "migrate", "clock"}
+	c.Assert(settings.StartStages(processes), check.DeepEquals, [][]string{
+		{"migrate"},
+		{"web"},
+		{"clock", "worker"},
+	})
+	c.Assert(settings.SortProcesses(processes), check.DeepEquals, []string{"migrate", "web", "clock", "worker"})
+	c.Assert(provision.ProcessSettings{}.StartStages(processes), check.DeepEquals, [][]string{
+		{"clock", "migrate", "web", "worker"},
+	})
+}
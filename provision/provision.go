@@ -27,6 +27,8 @@ var (
 	ErrEmptyApp      = errors.New("no units for this app")
 	ErrNodeNotFound  = errors.New("node not found")
 
+	ErrHealthcheckConflict = errors.New("healthcheck: only one of path, command and grpc may be set")
+
 	DefaultProvisioner = defaultDockerProvisioner
 )
 
@@ -142,7 +144,25 @@ type Unit struct {
 	Type        string
 	Ip          string
 	Status      Status
-	Address     *url.URL
+	// StatusReason explains why the unit is in its current status when the
+	// cluster killed or evicted it, e.g. "OOMKilled" or "Evicted".
+	StatusReason string
+	Address      *url.URL
+}
+
+const (
+	EvictionReasonOOMKilled = "OOMKilled"
+	EvictionReasonEvicted   = "Evicted"
+)
+
+// UnitEviction describes a unit killed or evicted by the underlying cluster,
+// instead of being stopped or removed by tsuru.
+type UnitEviction struct {
+	Unit    string    `json:"unit"`
+	Node    string    `json:"node"`
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
 }
 
 // GetName returns the name of the unit.
@@ -213,6 +233,35 @@ type App interface {
 	GetMemory() int64
 	GetSwap() int64
 	GetCpuShare() int
+	GetEphemeralStorage() int64
+
+	// GetNodeRequirements returns the metadata that nodes must have in order
+	// to run units of the app.
+	GetNodeRequirements() map[string]string
+
+	// ToleratesPreemption returns whether units of the app may run on
+	// preemptible nodes.
+	ToleratesPreemption() bool
+
+	// GetProcessSettings returns the start order and grace periods of the
+	// app processes.
+	GetProcessSettings() ProcessSettings
+
+	// GetDeployToggles returns the switches changing how deploys of the app
+	// are executed.
+	GetDeployToggles() DeployToggles
+
+	// GetMetricsConfig returns the endpoint where the units of the app
+	// expose metrics.
+	GetMetricsConfig() MetricsConfig
+
+	// GetConfigTemplates returns the configuration files rendered for the
+	// units of the app.
+	GetConfigTemplates() []ConfigTemplate
+
+	// IsDaemon returns whether the app is a daemon app, which runs one unit
+	// of each process in every node of its pool.
+	IsDaemon() bool
 
 	SetUpdatePlatform(bool) error
 	GetUpdatePlatform() bool
@@ -380,6 +429,55 @@ type UnitStatusProvisioner interface {
 	SetUnitStatus(Unit, Status) error
 }
 
+// UnitDiskUsage is the amount of ephemeral storage (in bytes) used by a unit.
+type UnitDiskUsage struct {
+	ID    string `json:"id"`
+	Usage int64  `json:"usage"`
+}
+
+// DiskUsageProvisioner is a provisioner that is able to report the ephemeral
+// storage used by the units of an app.
+type DiskUsageProvisioner interface {
+	UnitsDiskUsage(App) ([]UnitDiskUsage, error)
+}
+
+// UnitEvent is an event reported by the underlying cluster about a unit of an
+// app, like a scheduling failure or an error pulling the unit image.
+type UnitEvent struct {
+	Unit      string    `json:"unit"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// UnitEventsProvisioner is a provisioner that is able to report the events of
+// the units of an app seen by the underlying cluster since the given time.
+type UnitEventsProvisioner interface {
+	UnitsEvents(a App, since time.Time) ([]UnitEvent, error)
+}
+
+// PoolCapacity is the amount of memory (in bytes) offered by the nodes of a
+// pool and the amount of it already reserved by units running in the pool.
+type PoolCapacity struct {
+	Memory         int64 `json:"memory"`
+	ReservedMemory int64 `json:"reservedMemory"`
+}
+
+// PoolCapacityProvisioner is a provisioner that is able to report the
+// resources available in a pool.
+type PoolCapacityProvisioner interface {
+	PoolCapacity(pool string) (*PoolCapacity, error)
+}
+
+// PoolHealthCheckProvisioner is a provisioner that is able to check whether
+// the cluster backing a pool is reachable.
+type PoolHealthCheckProvisioner interface {
+	PoolHealthCheck(pool string) error
+}
+
 type AddNodeOptions struct {
 	Address    string
 	Metadata   map[string]string
@@ -388,6 +486,7 @@ type AddNodeOptions struct {
 	ClientCert []byte
 	ClientKey  []byte
 	WaitTO     time.Duration
+	Writer     io.Writer `form:"-"`
 }
 
 type RemoveNodeOptions struct {
@@ -435,6 +534,54 @@ type NodeRebalanceProvisioner interface {
 	RebalanceNodes(RebalanceNodesOptions) (bool, error)
 }
 
+// UnitKillerProvisioner is a provisioner able to abruptly terminate a unit,
+// leaving its recovery to the provisioner, used by chaos experiments.
+type UnitKillerProvisioner interface {
+	KillUnit(app App, unitID string) error
+}
+
+// UnitRemoverProvisioner is a provisioner able to remove specific units of an
+// app, used to drain the units left in the pool an app is moving out of.
+type UnitRemoverProvisioner interface {
+	RemoveUnitsByID(app App, ids []string, w io.Writer) error
+}
+
+// ImageKeepingDestroyer is a provisioner whose Destroy also removes the
+// images of the app. DestroyKeepingImages removes everything else, and is
+// used when the app moves to another provisioner, which keeps using the
+// images.
+type ImageKeepingDestroyer interface {
+	DestroyKeepingImages(app App) error
+}
+
+// UnitPlacement is the node where a new unit of an app process would be
+// started.
+type UnitPlacement struct {
+	Process string `json:"process"`
+	Node    string `json:"node"`
+}
+
+// UnitPlannerProvisioner is a provisioner able to tell where new units of an
+// app would be started, without starting them, used by dry runs.
+type UnitPlannerProvisioner interface {
+	PlanUnits(app App, n uint, process string) ([]UnitPlacement, error)
+}
+
+// NodeEvacuationProvisioner is a provisioner able to move every unit out of a
+// node, used when preemptible nodes are about to be reclaimed by the IaaS.
+type NodeEvacuationProvisioner interface {
+	// EvacuateNode disables the node and reschedules its units in other
+	// nodes.
+	EvacuateNode(address string, w io.Writer) error
+}
+
+// NodeDiagnosticsProvisioner is a provisioner able to run a predefined set of
+// checks on a node, like the status of the container runtime and the
+// available disk, used to troubleshoot nodes without accessing them.
+type NodeDiagnosticsProvisioner interface {
+	DiagnoseNode(address string) ([]NodeCheckResult, error)
+}
+
 type NodeContainerProvisioner interface {
 	UpgradeNodeContainer(name string, pool string, writer io.Writer) error
 	RemoveNodeContainer(name string, pool string, writer io.Writer) error
@@ -521,6 +668,9 @@ type NodeCheckResult struct {
 	Name       string
 	Err        string
 	Successful bool
+	// Duration is how long the check took to run, it's zero when the agent
+	// running the checks doesn't report it.
+	Duration time.Duration
 }
 
 // PlatformOptions is the set of options provided to PlatformAdd and
@@ -655,6 +805,10 @@ type TsuruYamlHooks struct {
 	Build   []string
 }
 
+// TsuruYamlHealthcheck is the healthcheck of the web process of an app. Units
+// are checked with an HTTP request to Path, by running Command inside the
+// unit or with the gRPC health checking protocol, when GRPC is set. Only one
+// of them may be set.
 type TsuruYamlHealthcheck struct {
 	Path            string
 	Method          string
@@ -663,10 +817,58 @@ type TsuruYamlHealthcheck struct {
 	RouterBody      string
 	UseInRouter     bool `json:"use_in_router" bson:"use_in_router"`
 	AllowedFailures int  `json:"allowed_failures" bson:"allowed_failures"`
+	Command         string
+	GRPC            *TsuruYamlGRPCHealthcheck `json:"grpc,omitempty" bson:",omitempty"`
+}
+
+// TsuruYamlGRPCHealthcheck checks units with the gRPC health checking
+// protocol, using the grpc_health_probe command, which must be available in
+// the app image. Port defaults to the port of the web process and an empty
+// Service checks the overall health of the server.
+type TsuruYamlGRPCHealthcheck struct {
+	Port    int
+	Service string
+}
+
+func (hc TsuruYamlHealthcheck) Validate() error {
+	set := 0
+	if hc.Path != "" {
+		set++
+	}
+	if hc.Command != "" {
+		set++
+	}
+	if hc.GRPC != nil {
+		set++
+	}
+	if set > 1 {
+		return ErrHealthcheckConflict
+	}
+	return nil
+}
+
+// ExecCommand returns the shell command run inside units to check them, for
+// command and gRPC healthchecks. It returns an empty string for HTTP
+// healthchecks. The port is used by gRPC healthchecks without a port.
+func (hc TsuruYamlHealthcheck) ExecCommand(port int) string {
+	if hc.Command != "" {
+		return hc.Command
+	}
+	if hc.GRPC == nil {
+		return ""
+	}
+	if hc.GRPC.Port != 0 {
+		port = hc.GRPC.Port
+	}
+	cmd := fmt.Sprintf("grpc_health_probe -addr=localhost:%d", port)
+	if hc.GRPC.Service != "" {
+		cmd += fmt.Sprintf(" -service=%s", hc.GRPC.Service)
+	}
+	return cmd
 }
 
 func (hc TsuruYamlHealthcheck) ToRouterHC() router.HealthcheckData {
-	if hc.UseInRouter {
+	if hc.UseInRouter && hc.Path != "" {
 		return router.HealthcheckData{
 			Path:   hc.Path,
 			Status: hc.Status,
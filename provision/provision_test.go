@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/tsuru/tsuru/router"
 	"gopkg.in/check.v1"
 )
 
@@ -186,3 +187,37 @@ func (ProvisionSuite) TestNodeToSpecExtraData(c *check.C) {
 		Pool:     "a",
 	})
 }
+
+func (ProvisionSuite) TestTsuruYamlHealthcheckValidate(c *check.C) {
+	valid := []TsuruYamlHealthcheck{
+		{},
+		{Path: "/hc"},
+		{Command: "check"},
+		{GRPC: &TsuruYamlGRPCHealthcheck{}},
+	}
+	for _, hc := range valid {
+		c.Check(hc.Validate(), check.IsNil, check.Commentf("%#v", hc))
+	}
+	invalid := []TsuruYamlHealthcheck{
+		{Path: "/hc", Command: "check"},
+		{Path: "/hc", GRPC: &TsuruYamlGRPCHealthcheck{}},
+		{Command: "check", GRPC: &TsuruYamlGRPCHealthcheck{}},
+	}
+	for _, hc := range invalid {
+		c.Check(hc.Validate(), check.Equals, ErrHealthcheckConflict, check.Commentf("%#v", hc))
+	}
+}
+
+func (ProvisionSuite) TestTsuruYamlHealthcheckExecCommand(c *check.C) {
+	c.Assert(TsuruYamlHealthcheck{Path: "/hc"}.ExecCommand(8888), check.Equals, "")
+	c.Assert(TsuruYamlHealthcheck{Command: "curl -f localhost:8888"}.ExecCommand(8888), check.Equals, "curl -f localhost:8888")
+	hc := TsuruYamlHealthcheck{GRPC: &TsuruYamlGRPCHealthcheck{}}
+	c.Assert(hc.ExecCommand(8888), check.Equals, "grpc_health_probe -addr=localhost:8888")
+	hc.GRPC = &TsuruYamlGRPCHealthcheck{Port: 50051, Service: "myservice"}
+	c.Assert(hc.ExecCommand(8888), check.Equals, "grpc_health_probe -addr=localhost:50051 -service=myservice")
+}
+
+func (ProvisionSuite) TestTsuruYamlHealthcheckToRouterHCWithoutPath(c *check.C) {
+	hc := TsuruYamlHealthcheck{Command: "check", UseInRouter: true}
+	c.Assert(hc.ToRouterHC(), check.DeepEquals, router.HealthcheckData{Path: "/"})
+}
@@ -61,6 +61,14 @@ type FakeApp struct {
 	Memory         int64
 	Swap           int64
 	CpuShare       int
+	Storage        int64
+	Requirements   map[string]string
+	Preemptible    bool
+	Processes      provision.ProcessSettings
+	Toggles        provision.DeployToggles
+	Metrics        provision.MetricsConfig
+	Templates      []provision.ConfigTemplate
+	Daemon         bool
 	commMut        sync.Mutex
 	Deploys        uint
 	env            map[string]bind.EnvVar
@@ -117,6 +125,38 @@ func (a *FakeApp) GetCpuShare() int {
 	return a.CpuShare
 }
 
+func (a *FakeApp) GetEphemeralStorage() int64 {
+	return a.Storage
+}
+
+func (a *FakeApp) GetNodeRequirements() map[string]string {
+	return a.Requirements
+}
+
+func (a *FakeApp) ToleratesPreemption() bool {
+	return a.Preemptible
+}
+
+func (a *FakeApp) GetProcessSettings() provision.ProcessSettings {
+	return a.Processes
+}
+
+func (a *FakeApp) GetDeployToggles() provision.DeployToggles {
+	return a.Toggles
+}
+
+func (a *FakeApp) GetMetricsConfig() provision.MetricsConfig {
+	return a.Metrics
+}
+
+func (a *FakeApp) GetConfigTemplates() []provision.ConfigTemplate {
+	return a.Templates
+}
+
+func (a *FakeApp) IsDaemon() bool {
+	return a.Daemon
+}
+
 func (a *FakeApp) HasBind(unit *provision.Unit) bool {
 	a.bindLock.Lock()
 	defer a.bindLock.Unlock()
@@ -370,6 +410,9 @@ type FakeProvisioner struct {
 	shellMut       sync.Mutex
 	nodes          map[string]FakeNode
 	nodeContainers map[string]int
+	diskUsage      map[string]int64
+	poolCapacity   map[string]provision.PoolCapacity
+	unitEvents     map[string][]provision.UnitEvent
 }
 
 func NewFakeProvisioner() *FakeProvisioner {
@@ -380,6 +423,9 @@ func NewFakeProvisioner() *FakeProvisioner {
 	p.shells = make(map[string][]provision.ShellOptions)
 	p.nodes = make(map[string]FakeNode)
 	p.nodeContainers = make(map[string]int)
+	p.diskUsage = make(map[string]int64)
+	p.poolCapacity = make(map[string]provision.PoolCapacity)
+	p.unitEvents = make(map[string][]provision.UnitEvent)
 	return &p
 }
 
@@ -539,6 +585,36 @@ func (p *FakeProvisioner) UpdateNode(opts provision.UpdateNodeOptions) error {
 	return nil
 }
 
+func (p *FakeProvisioner) EvacuateNode(address string, w io.Writer) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if err := p.getError("EvacuateNode"); err != nil {
+		return err
+	}
+	n, ok := p.nodes[address]
+	if !ok {
+		return provision.ErrNodeNotFound
+	}
+	n.status = "disabled"
+	p.nodes[address] = n
+	if w != nil {
+		fmt.Fprintf(w, "evacuating %s\n", address)
+	}
+	return nil
+}
+
+func (p *FakeProvisioner) DiagnoseNode(address string) ([]provision.NodeCheckResult, error) {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	if err := p.getError("DiagnoseNode"); err != nil {
+		return nil, err
+	}
+	if _, ok := p.nodes[address]; !ok {
+		return nil, provision.ErrNodeNotFound
+	}
+	return []provision.NodeCheckResult{{Name: "fake", Successful: true}}, nil
+}
+
 type nodeList []provision.Node
 
 func (l nodeList) Len() int           { return len(l) }
@@ -769,6 +845,9 @@ func (p *FakeProvisioner) Reset() {
 
 	p.mut.Lock()
 	p.nodes = make(map[string]FakeNode)
+	p.diskUsage = make(map[string]int64)
+	p.poolCapacity = make(map[string]provision.PoolCapacity)
+	p.unitEvents = make(map[string][]provision.UnitEvent)
 	p.mut.Unlock()
 	uniqueIpCounter = 0
 
@@ -1028,6 +1107,68 @@ func (p *FakeProvisioner) RemoveUnits(app provision.App, n uint, process string,
 	return nil
 }
 
+func (p *FakeProvisioner) RemoveUnitsByID(app provision.App, ids []string, w io.Writer) error {
+	if err := p.getError("RemoveUnitsByID"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	toRemove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		toRemove[id] = true
+	}
+	var newUnits []provision.Unit
+	for _, u := range pApp.units {
+		if toRemove[u.ID] {
+			delete(toRemove, u.ID)
+			err := routertest.FakeRouter.RemoveRoute(app.GetName(), u.Address)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		newUnits = append(newUnits, u)
+	}
+	for id := range toRemove {
+		return &provision.UnitNotFoundError{ID: id}
+	}
+	if w != nil {
+		fmt.Fprintf(w, "removing %d units", len(ids))
+	}
+	pApp.units = newUnits
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
+// PlanUnits places the units in the nodes of the pool of the app, in turns,
+// sorted by address.
+func (p *FakeProvisioner) PlanUnits(app provision.App, n uint, process string) ([]provision.UnitPlacement, error) {
+	if err := p.getError("PlanUnits"); err != nil {
+		return nil, err
+	}
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	var addrs []string
+	for _, node := range p.nodes {
+		if node.PoolName == app.GetPool() {
+			addrs = append(addrs, node.Addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.Errorf("no nodes found in pool %q", app.GetPool())
+	}
+	sort.Strings(addrs)
+	placement := make([]provision.UnitPlacement, n)
+	for i := range placement {
+		placement[i] = provision.UnitPlacement{Process: process, Node: addrs[i%len(addrs)]}
+	}
+	return placement, nil
+}
+
 // ExecuteCommand will pretend to execute the given command, recording data
 // about it.
 //
@@ -1172,6 +1313,26 @@ func (p *FakeProvisioner) RoutableAddresses(app provision.App) ([]url.URL, error
 	return addrs, nil
 }
 
+// KillUnit sets the status of the unit to error, as if it had crashed.
+func (p *FakeProvisioner) KillUnit(app provision.App, unitID string) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if err := p.getError("KillUnit"); err != nil {
+		return err
+	}
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	for i := range pApp.units {
+		if pApp.units[i].ID == unitID {
+			pApp.units[i].Status = provision.StatusError
+			return nil
+		}
+	}
+	return &provision.UnitNotFoundError{ID: unitID}
+}
+
 func (p *FakeProvisioner) SetUnitStatus(unit provision.Unit, status provision.Status) error {
 	p.mut.Lock()
 	defer p.mut.Unlock()
@@ -1202,6 +1363,90 @@ func (p *FakeProvisioner) SetUnitStatus(unit provision.Unit, status provision.St
 	return nil
 }
 
+// SetUnitDiskUsage changes the ephemeral storage usage reported for the unit
+// with the given id.
+func (p *FakeProvisioner) SetUnitDiskUsage(unitID string, usage int64) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.diskUsage[unitID] = usage
+}
+
+func (p *FakeProvisioner) UnitsDiskUsage(app provision.App) ([]provision.UnitDiskUsage, error) {
+	if err := p.getError("UnitsDiskUsage"); err != nil {
+		return nil, err
+	}
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return nil, errNotProvisioned
+	}
+	usage := make([]provision.UnitDiskUsage, len(pApp.units))
+	for i, u := range pApp.units {
+		usage[i] = provision.UnitDiskUsage{ID: u.ID, Usage: p.diskUsage[u.ID]}
+	}
+	return usage, nil
+}
+
+// AddUnitEvent adds an event reported for the units of the given app.
+func (p *FakeProvisioner) AddUnitEvent(appName string, evt provision.UnitEvent) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.unitEvents[appName] = append(p.unitEvents[appName], evt)
+}
+
+func (p *FakeProvisioner) UnitsEvents(app provision.App, since time.Time) ([]provision.UnitEvent, error) {
+	if err := p.getError("UnitsEvents"); err != nil {
+		return nil, err
+	}
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	if _, ok := p.apps[app.GetName()]; !ok {
+		return nil, errNotProvisioned
+	}
+	var events []provision.UnitEvent
+	for _, evt := range p.unitEvents[app.GetName()] {
+		if !evt.LastSeen.Before(since) {
+			events = append(events, evt)
+		}
+	}
+	return events, nil
+}
+
+// SetPoolCapacity changes the capacity reported for the given pool.
+func (p *FakeProvisioner) SetPoolCapacity(pool string, capacity provision.PoolCapacity) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.poolCapacity[pool] = capacity
+}
+
+func (p *FakeProvisioner) PoolCapacity(pool string) (*provision.PoolCapacity, error) {
+	if err := p.getError("PoolCapacity"); err != nil {
+		return nil, err
+	}
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	capacity := p.poolCapacity[pool]
+	return &capacity, nil
+}
+
+func (p *FakeProvisioner) PoolHealthCheck(pool string) error {
+	return p.getError("PoolHealthCheck")
+}
+
+// SetNodeStatus changes the status reported by the given node.
+func (p *FakeProvisioner) SetNodeStatus(address, status string) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	n, ok := p.nodes[address]
+	if !ok {
+		return provision.ErrNodeNotFound
+	}
+	n.status = status
+	p.nodes[address] = n
+	return nil
+}
+
 func (p *FakeProvisioner) getAllUnits() []provision.Unit {
 	var units []provision.Unit
 	for _, app := range p.apps {
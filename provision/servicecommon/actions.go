@@ -5,8 +5,6 @@
 package servicecommon
 
 import (
-	"sort"
-
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/action"
 	"github.com/tsuru/tsuru/app/image"
@@ -168,7 +166,7 @@ var updateServices = &action.Action{
 		for processName := range args.newImageSpec {
 			toDeployProcesses = append(toDeployProcesses, processName)
 		}
-		sort.Strings(toDeployProcesses)
+		toDeployProcesses = args.app.GetProcessSettings().SortProcesses(toDeployProcesses)
 		totalUnits := 0
 		labelsMap := map[string]*labelReplicas{}
 		for _, processName := range toDeployProcesses {
@@ -342,6 +342,10 @@ func serviceSpecForApp(opts tsuruServiceOpts) (*swarm.ServiceSpec, error) {
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
+		err = yamlData.Healthcheck.Validate()
+		if err != nil {
+			return nil, err
+		}
 		healthConfig = toHealthConfig(yamlData.Healthcheck, portInt)
 	}
 	if opts.labels == nil {
@@ -371,16 +375,21 @@ func serviceSpecForApp(opts tsuruServiceOpts) (*swarm.ServiceSpec, error) {
 	}
 	uReplicas := uint64(opts.replicas)
 	user, _ := dockercommon.UserForContainer()
+	var stopGracePeriod *time.Duration
+	if period := opts.app.GetProcessSettings().GracePeriod(opts.process); period > 0 {
+		stopGracePeriod = &period
+	}
 	opts.constraints = append(opts.constraints, fmt.Sprintf("node.labels.%s == %s", provision.LabelNodePool, opts.app.GetPool()))
 	spec := swarm.ServiceSpec{
 		TaskTemplate: swarm.TaskSpec{
 			ContainerSpec: swarm.ContainerSpec{
-				Image:       opts.image,
-				Env:         envs,
-				Labels:      opts.labels.ToLabels(),
-				Command:     cmds,
-				User:        user,
-				Healthcheck: healthConfig,
+				Image:           opts.image,
+				Env:             envs,
+				Labels:          opts.labels.ToLabels(),
+				Command:         cmds,
+				User:            user,
+				Healthcheck:     healthConfig,
+				StopGracePeriod: stopGracePeriod,
 			},
 			Networks: networks,
 			RestartPolicy: &swarm.RestartPolicy{
@@ -402,6 +411,16 @@ func serviceSpecForApp(opts tsuruServiceOpts) (*swarm.ServiceSpec, error) {
 			},
 		},
 	}
+	if opts.app.IsDaemon() && !opts.isDeploy && !opts.isIsolatedRun {
+		// Daemon apps run one task in each node of the pool. A global service
+		// can't be scaled to zero, so stopped daemon apps get a constraint
+		// that no node satisfies.
+		spec.Mode = swarm.ServiceMode{Global: &swarm.GlobalService{}}
+		if opts.replicas == 0 {
+			spec.TaskTemplate.Placement.Constraints = append(spec.TaskTemplate.Placement.Constraints,
+				fmt.Sprintf("node.labels.%sdaemon-stopped == true", tsuruLabelPrefix))
+		}
+	}
 	return &spec, nil
 }
 
@@ -17,8 +17,10 @@ import (
 	"github.com/docker/docker/api/types/swarm"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/fsouza/go-dockerclient/testing"
+	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/nodecontainer"
+	"github.com/tsuru/tsuru/provision/provisiontest"
 	"github.com/tsuru/tsuru/provision/servicecommon"
 	"gopkg.in/check.v1"
 )
@@ -223,6 +225,32 @@ func (s *S) TestListValidNodesNoneValid(c *check.C) {
 	c.Assert(nodes, check.DeepEquals, []swarm.Node{})
 }
 
+func (s *S) TestServiceSpecForAppDaemon(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	a.Daemon = true
+	err := image.SaveImageCustomData("myimg", map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python myapp.py",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	spec, err := serviceSpecForApp(tsuruServiceOpts{app: a, process: "web", image: "myimg", replicas: 1})
+	c.Assert(err, check.IsNil)
+	c.Assert(spec.Mode, check.DeepEquals, swarm.ServiceMode{Global: &swarm.GlobalService{}})
+	c.Assert(spec.TaskTemplate.Placement.Constraints, check.DeepEquals, []string{"node.labels.pool == test-default"})
+	spec, err = serviceSpecForApp(tsuruServiceOpts{app: a, process: "web", image: "myimg", replicas: 0})
+	c.Assert(err, check.IsNil)
+	c.Assert(spec.Mode, check.DeepEquals, swarm.ServiceMode{Global: &swarm.GlobalService{}})
+	c.Assert(spec.TaskTemplate.Placement.Constraints, check.DeepEquals, []string{
+		"node.labels.pool == test-default",
+		"node.labels.tsuru.daemon-stopped == true",
+	})
+	spec, err = serviceSpecForApp(tsuruServiceOpts{app: a, process: "web", image: "myimg", isDeploy: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(spec.Mode.Global, check.IsNil)
+	c.Assert(spec.Mode.Replicated, check.NotNil)
+}
+
 func (s *S) TestServiceSpecForNodeContainer(c *check.C) {
 	c1 := nodecontainer.NodeContainerConfig{
 		Name: "swarmbs",
@@ -20,6 +20,21 @@ func toHealthConfig(hc provision.TsuruYamlHealthcheck, port int) *container.Heal
 	match := hc.Match
 	status := hc.Status
 	allowedFailures := hc.AllowedFailures
+	maxWaitTime, _ := config.GetInt("docker:healthcheck:max-time")
+	if maxWaitTime == 0 {
+		maxWaitTime = 120
+	}
+	if cmd := hc.ExecCommand(port); cmd != "" {
+		return &container.HealthConfig{
+			Interval: 3 * time.Second,
+			Retries:  allowedFailures + 1,
+			Timeout:  time.Duration(maxWaitTime) * time.Second,
+			Test: []string{
+				"CMD-SHELL",
+				cmd,
+			},
+		}
+	}
 	if path == "" {
 		return nil
 	}
@@ -31,10 +46,6 @@ func toHealthConfig(hc provision.TsuruYamlHealthcheck, port int) *container.Heal
 	if status == 0 && match == "" {
 		status = 200
 	}
-	maxWaitTime, _ := config.GetInt("docker:healthcheck:max-time")
-	if maxWaitTime == 0 {
-		maxWaitTime = 120
-	}
 	curlLine := fmt.Sprintf("curl -X%s -fsSL http://localhost:%d/%s", method, port, strings.TrimPrefix(path, "/"))
 	if match != "" {
 		curlLine = fmt.Sprintf("%s | egrep %q", curlLine, match)
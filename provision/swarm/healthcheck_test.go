@@ -59,6 +59,29 @@ func (s *S) TestToHealthConfig(c *check.C) {
 			Interval: 3 * time.Second,
 			Retries:  11,
 		}},
+		{input: provision.TsuruYamlHealthcheck{
+			Command:         "/bin/check-health",
+			AllowedFailures: 2,
+		}, expected: &container.HealthConfig{
+			Test: []string{
+				"CMD-SHELL",
+				"/bin/check-health",
+			},
+			Timeout:  120 * time.Second,
+			Interval: 3 * time.Second,
+			Retries:  3,
+		}},
+		{input: provision.TsuruYamlHealthcheck{
+			GRPC: &provision.TsuruYamlGRPCHealthcheck{Port: 50051},
+		}, expected: &container.HealthConfig{
+			Test: []string{
+				"CMD-SHELL",
+				"grpc_health_probe -addr=localhost:50051",
+			},
+			Timeout:  120 * time.Second,
+			Interval: 3 * time.Second,
+			Retries:  1,
+		}},
 	}
 	for i, test := range tests {
 		result := toHealthConfig(test.input, 9000)
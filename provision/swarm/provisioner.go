@@ -622,6 +622,10 @@ func (p *swarmProvisioner) ArchiveDeploy(a provision.App, archiveURL string, evt
 	if err != nil {
 		return "", err
 	}
+	err = evt.Checkpoint("deploy")
+	if err != nil {
+		return "", err
+	}
 	err = deployProcesses(a, buildingImage, nil)
 	if err != nil {
 		return "", errors.WithStack(err)
@@ -662,6 +666,10 @@ func (p *swarmProvisioner) ImageDeploy(a provision.App, imgID string, evt *event
 		return "", err
 	}
 	a.SetUpdatePlatform(true)
+	err = evt.Checkpoint("deploy")
+	if err != nil {
+		return "", err
+	}
 	err = deployProcesses(a, newImage, nil)
 	if err != nil {
 		return "", err
@@ -679,6 +687,10 @@ func (p *swarmProvisioner) UploadDeploy(app provision.App, archiveFile io.ReadCl
 	if err != nil {
 		return "", err
 	}
+	err = evt.Checkpoint("deploy")
+	if err != nil {
+		return "", err
+	}
 	err = deployProcesses(app, buildingImage, nil)
 	if err != nil {
 		return "", errors.WithStack(err)
@@ -0,0 +1,149 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package registryauth provides an extension point for brokering the
+// credentials used to pull app images from the docker registry. Instead of
+// sharing the same long-lived registry credentials with every node, the
+// configured broker issues short-lived credentials allowed to pull only the
+// repository of the image being pulled.
+package registryauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+const (
+	defaultBroker   = "static"
+	defaultTokenTTL = 15 * time.Minute
+)
+
+var brokers map[string]Broker
+
+// Credentials are the credentials used to authenticate in the registry. A
+// zero ExpiresAt means the credentials never expire.
+type Credentials struct {
+	ServerAddress string
+	Username      string
+	Password      string
+	Email         string
+	ExpiresAt     time.Time
+}
+
+// Broker represents the service issuing registry credentials, usually the
+// token service of the registry.
+type Broker interface {
+	// PullCredentials issues credentials allowed to pull only the given
+	// repository, like "tsuru/app-myapp", valid for at most ttl.
+	PullCredentials(repository string, ttl time.Duration) (Credentials, error)
+}
+
+// Register registers a new registry credentials broker, that can be later
+// configured and used.
+func Register(name string, broker Broker) {
+	if brokers == nil {
+		brokers = make(map[string]Broker)
+	}
+	brokers[name] = broker
+}
+
+// GetBroker returns the current configured broker, as defined in the
+// configuration file.
+func GetBroker() Broker {
+	name, err := config.GetString("registry-auth:broker")
+	if err != nil {
+		name = defaultBroker
+	}
+	if _, ok := brokers[name]; !ok {
+		name = defaultBroker
+	}
+	return brokers[name]
+}
+
+// TokenTTL returns the maximum lifetime of the credentials issued by the
+// broker, as defined in the configuration file.
+func TokenTTL() time.Duration {
+	seconds, err := config.GetInt("registry-auth:token-ttl")
+	if err != nil || seconds <= 0 {
+		return defaultTokenTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// ParseImage splits the image name in the registry server and the repository,
+// without the tag or digest. The server is empty for images without an
+// explicit registry.
+func ParseImage(image string) (server, repository string) {
+	if i := strings.Index(image, "@"); i != -1 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], parts[1]
+	}
+	return "", image
+}
+
+// PullCredentials returns credentials allowed to pull the given image. Images
+// not hosted in the registry used by tsuru, defined by the "docker:registry"
+// setting, get empty credentials.
+func PullCredentials(image string) (Credentials, error) {
+	registry, _ := config.GetString("docker:registry")
+	server, repository := ParseImage(image)
+	if registry == "" || server != registry {
+		return Credentials{}, nil
+	}
+	creds, err := GetBroker().PullCredentials(repository, TokenTTL())
+	if err != nil {
+		return Credentials{}, errors.Wrapf(err, "unable to issue registry credentials for %q", repository)
+	}
+	if creds.ServerAddress == "" {
+		creds.ServerAddress = server
+	}
+	return creds, nil
+}
+
+// Empty returns whether the credentials have no username and password, in
+// which case pulls are anonymous.
+func (c Credentials) Empty() bool {
+	return c.Username == "" && c.Password == ""
+}
+
+// AuthConfiguration returns the credentials in the format used by the docker
+// API.
+func (c Credentials) AuthConfiguration() docker.AuthConfiguration {
+	return docker.AuthConfiguration{
+		ServerAddress: c.ServerAddress,
+		Username:      c.Username,
+		Password:      c.Password,
+		Email:         c.Email,
+	}
+}
+
+// DockerConfigJSON returns the credentials in the format of the
+// ~/.docker/config.json file, used by kubernetes image pull secrets.
+func (c Credentials) DockerConfigJSON() ([]byte, error) {
+	type authEntry struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Email    string `json:"email,omitempty"`
+		Auth     string `json:"auth"`
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password))
+	data, err := json.Marshal(map[string]map[string]authEntry{
+		"auths": {
+			c.ServerAddress: {Username: c.Username, Password: c.Password, Email: c.Email, Auth: auth},
+		},
+	})
+	return data, errors.WithStack(err)
+}
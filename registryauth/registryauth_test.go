@@ -0,0 +1,140 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package registryauth
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type recordingBroker struct {
+	repositories []string
+	ttls         []time.Duration
+	err          error
+}
+
+func (b *recordingBroker) PullCredentials(repository string, ttl time.Duration) (Credentials, error) {
+	b.repositories = append(b.repositories, repository)
+	b.ttls = append(b.ttls, ttl)
+	if b.err != nil {
+		return Credentials{}, b.err
+	}
+	return Credentials{Username: "puller", Password: "short-lived"}, nil
+}
+
+func (s *S) TestParseImage(c *check.C) {
+	tests := []struct {
+		image      string
+		server     string
+		repository string
+	}{
+		{"registry.example.com/tsuru/app-myapp:v1", "registry.example.com", "tsuru/app-myapp"},
+		{"registry.example.com:5000/tsuru/app-myapp:v1", "registry.example.com:5000", "tsuru/app-myapp"},
+		{"localhost/tsuru/python", "localhost", "tsuru/python"},
+		{"registry.example.com/app@sha256:abc", "registry.example.com", "app"},
+		{"tsuru/python:latest", "", "tsuru/python"},
+		{"ubuntu", "", "ubuntu"},
+	}
+	for _, tt := range tests {
+		server, repository := ParseImage(tt.image)
+		c.Check(server, check.Equals, tt.server, check.Commentf(tt.image))
+		c.Check(repository, check.Equals, tt.repository, check.Commentf(tt.image))
+	}
+}
+
+func (s *S) TestGetBrokerDefaultsToStatic(c *check.C) {
+	c.Assert(GetBroker(), check.Equals, staticBroker{})
+	config.Set("registry-auth:broker", "unknown")
+	defer config.Unset("registry-auth:broker")
+	c.Assert(GetBroker(), check.Equals, staticBroker{})
+}
+
+func (s *S) TestTokenTTL(c *check.C) {
+	c.Assert(TokenTTL(), check.Equals, 15*time.Minute)
+	config.Set("registry-auth:token-ttl", 60)
+	defer config.Unset("registry-auth:token-ttl")
+	c.Assert(TokenTTL(), check.Equals, time.Minute)
+}
+
+func (s *S) TestPullCredentials(c *check.C) {
+	broker := &recordingBroker{}
+	Register("recording", broker)
+	config.Set("registry-auth:broker", "recording")
+	defer config.Unset("registry-auth:broker")
+	config.Set("docker:registry", "registry.example.com")
+	defer config.Unset("docker:registry")
+	creds, err := PullCredentials("registry.example.com/tsuru/app-myapp:v2")
+	c.Assert(err, check.IsNil)
+	c.Assert(creds, check.DeepEquals, Credentials{
+		ServerAddress: "registry.example.com",
+		Username:      "puller",
+		Password:      "short-lived",
+	})
+	c.Assert(broker.repositories, check.DeepEquals, []string{"tsuru/app-myapp"})
+	c.Assert(broker.ttls, check.DeepEquals, []time.Duration{15 * time.Minute})
+}
+
+func (s *S) TestPullCredentialsOtherRegistry(c *check.C) {
+	broker := &recordingBroker{}
+	Register("recording", broker)
+	config.Set("registry-auth:broker", "recording")
+	defer config.Unset("registry-auth:broker")
+	config.Set("docker:registry", "registry.example.com")
+	defer config.Unset("docker:registry")
+	creds, err := PullCredentials("tsuru/python:latest")
+	c.Assert(err, check.IsNil)
+	c.Assert(creds.Empty(), check.Equals, true)
+	c.Assert(broker.repositories, check.IsNil)
+}
+
+func (s *S) TestPullCredentialsBrokerError(c *check.C) {
+	broker := &recordingBroker{err: errors.New("token service unavailable")}
+	Register("recording", broker)
+	config.Set("registry-auth:broker", "recording")
+	defer config.Unset("registry-auth:broker")
+	config.Set("docker:registry", "registry.example.com")
+	defer config.Unset("docker:registry")
+	_, err := PullCredentials("registry.example.com/tsuru/app-myapp:v2")
+	c.Assert(err, check.ErrorMatches, `unable to issue registry credentials for "tsuru/app-myapp": token service unavailable`)
+}
+
+func (s *S) TestPullCredentialsStaticBroker(c *check.C) {
+	config.Set("docker:registry", "registry.example.com")
+	defer config.Unset("docker:registry")
+	config.Set("docker:registry-auth:username", "admin")
+	defer config.Unset("docker:registry-auth:username")
+	config.Set("docker:registry-auth:password", "secret")
+	defer config.Unset("docker:registry-auth:password")
+	creds, err := PullCredentials("registry.example.com/tsuru/app-myapp:v2")
+	c.Assert(err, check.IsNil)
+	c.Assert(creds.AuthConfiguration(), check.DeepEquals, docker.AuthConfiguration{
+		ServerAddress: "registry.example.com",
+		Username:      "admin",
+		Password:      "secret",
+	})
+}
+
+func (s *S) TestCredentialsDockerConfigJSON(c *check.C) {
+	creds := Credentials{ServerAddress: "registry.example.com", Username: "puller", Password: "short-lived"}
+	data, err := creds.DockerConfigJSON()
+	c.Assert(err, check.IsNil)
+	var result map[string]map[string]map[string]string
+	err = json.Unmarshal(data, &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, map[string]map[string]map[string]string{
+		"auths": {
+			"registry.example.com": {
+				"username": "puller",
+				"password": "short-lived",
+				"auth":     "cHVsbGVyOnNob3J0LWxpdmVk",
+			},
+		},
+	})
+}
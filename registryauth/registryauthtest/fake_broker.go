@@ -0,0 +1,61 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package registryauthtest provides a fake registry credentials broker for
+// use in tests.
+//
+// Users can use the fake broker by just importing this package and setting
+// the "registry-auth:broker" setting to "fake".
+package registryauthtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tsuru/tsuru/registryauth"
+)
+
+func init() {
+	registryauth.Register("fake", &broker)
+}
+
+var broker fakeBroker
+
+// Request is a request for credentials received by the fake broker.
+type Request struct {
+	Repository string
+	TTL        time.Duration
+}
+
+type fakeBroker struct {
+	mu       sync.Mutex
+	requests []Request
+}
+
+// PullCredentials issues credentials with the repository as username and
+// "token-<repository>" as password.
+func (b *fakeBroker) PullCredentials(repository string, ttl time.Duration) (registryauth.Credentials, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = append(b.requests, Request{Repository: repository, TTL: ttl})
+	return registryauth.Credentials{
+		Username:  repository,
+		Password:  "token-" + repository,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// Requests returns the requests received by the fake broker.
+func Requests() []Request {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	return broker.requests
+}
+
+// Reset removes all requests recorded by the fake broker.
+func Reset() {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	broker.requests = nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package registryauth
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+)
+
+func init() {
+	Register("static", staticBroker{})
+}
+
+// staticBroker returns the credentials defined in the "docker:registry-auth"
+// settings for every repository. The credentials are neither scoped nor
+// short-lived.
+type staticBroker struct{}
+
+func (staticBroker) PullCredentials(repository string, ttl time.Duration) (Credentials, error) {
+	var creds Credentials
+	creds.Email, _ = config.GetString("docker:registry-auth:email")
+	creds.Username, _ = config.GetString("docker:registry-auth:username")
+	creds.Password, _ = config.GetString("docker:registry-auth:password")
+	creds.ServerAddress, _ = config.GetString("docker:registry")
+	return creds, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package registryauth
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+// defaultTokenExpiration is the lifetime of tokens whose response doesn't
+// include expires_in, as defined by the docker registry token specification.
+const defaultTokenExpiration = time.Minute
+
+func init() {
+	Register("token-service", tokenServiceBroker{})
+}
+
+// tokenServiceBroker requests tokens allowed to pull only the given
+// repository from the token service of the registry, following the docker
+// registry token authentication specification. The token service is
+// configured in the "registry-auth:token-service" settings, and tsuru
+// authenticates in it with its own account. The lifetime of the tokens is
+// defined by the token service, so ttl is not used.
+type tokenServiceBroker struct{}
+
+type tokenResponse struct {
+	Token       string    `json:"token"`
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int       `json:"expires_in"`
+	IssuedAt    time.Time `json:"issued_at"`
+}
+
+func (tokenServiceBroker) PullCredentials(repository string, ttl time.Duration) (Credentials, error) {
+	realm, err := config.GetString("registry-auth:token-service:realm")
+	if err != nil {
+		return Credentials{}, errors.New("registry-auth:token-service:realm is not set")
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return Credentials{}, errors.Wrapf(err, "invalid token service realm %q", realm)
+	}
+	query := u.Query()
+	if service, _ := config.GetString("registry-auth:token-service:service"); service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+repository+":pull")
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Credentials{}, errors.WithStack(err)
+	}
+	username, _ := config.GetString("registry-auth:token-service:username")
+	password, _ := config.GetString("registry-auth:token-service:password")
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := tsuruNet.Dial5Full60ClientNoKeepAlive.Do(req)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "unable to request token")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return Credentials{}, errors.Errorf("token service returned status %d: %s", resp.StatusCode, body)
+	}
+	var token tokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "unable to parse token service response")
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return Credentials{}, errors.New("token service returned an empty token")
+	}
+	if token.IssuedAt.IsZero() {
+		token.IssuedAt = time.Now().UTC()
+	}
+	expiration := defaultTokenExpiration
+	if token.ExpiresIn > 0 {
+		expiration = time.Duration(token.ExpiresIn) * time.Second
+	}
+	tokenUsername, _ := config.GetString("registry-auth:token-service:token-username")
+	if tokenUsername == "" {
+		tokenUsername = username
+	}
+	return Credentials{
+		Username:  tokenUsername,
+		Password:  token.Token,
+		ExpiresAt: token.IssuedAt.Add(expiration),
+	}, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package registryauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) setUpTokenService(c *check.C, handler http.HandlerFunc) func() {
+	server := httptest.NewServer(handler)
+	config.Set("registry-auth:token-service:realm", server.URL+"/token")
+	config.Set("registry-auth:token-service:service", "registry.example.com")
+	config.Set("registry-auth:token-service:username", "tsuru")
+	config.Set("registry-auth:token-service:password", "secret")
+	return func() {
+		server.Close()
+		config.Unset("registry-auth:token-service")
+	}
+}
+
+func (s *S) TestTokenServiceBrokerPullCredentials(c *check.C) {
+	var query url.Values
+	var username, password string
+	issuedAt := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	cleanup := s.setUpTokenService(c, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		username, password, _ = r.BasicAuth()
+		w.Write([]byte(`{"token": "scoped-token", "expires_in": 300, "issued_at": "2017-05-10T12:00:00Z"}`))
+	})
+	defer cleanup()
+	creds, err := tokenServiceBroker{}.PullCredentials("tsuru/app-myapp", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(creds, check.DeepEquals, Credentials{
+		Username:  "tsuru",
+		Password:  "scoped-token",
+		ExpiresAt: issuedAt.Add(5 * time.Minute),
+	})
+	c.Assert(query.Get("scope"), check.Equals, "repository:tsuru/app-myapp:pull")
+	c.Assert(query.Get("service"), check.Equals, "registry.example.com")
+	c.Assert(username, check.Equals, "tsuru")
+	c.Assert(password, check.Equals, "secret")
+}
+
+func (s *S) TestTokenServiceBrokerPullCredentialsAccessToken(c *check.C) {
+	cleanup := s.setUpTokenService(c, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token": "scoped-token"}`))
+	})
+	defer cleanup()
+	config.Set("registry-auth:token-service:token-username", "oauth2accesstoken")
+	before := time.Now().UTC()
+	creds, err := tokenServiceBroker{}.PullCredentials("tsuru/app-myapp", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(creds.Username, check.Equals, "oauth2accesstoken")
+	c.Assert(creds.Password, check.Equals, "scoped-token")
+	c.Assert(creds.ExpiresAt.Before(before.Add(defaultTokenExpiration)), check.Equals, false)
+}
+
+func (s *S) TestTokenServiceBrokerPullCredentialsError(c *check.C) {
+	cleanup := s.setUpTokenService(c, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "access denied", http.StatusUnauthorized)
+	})
+	defer cleanup()
+	_, err := tokenServiceBroker{}.PullCredentials("tsuru/app-myapp", time.Minute)
+	c.Assert(err, check.ErrorMatches, "token service returned status 401: access denied\n")
+}
+
+func (s *S) TestTokenServiceBrokerPullCredentialsNoRealm(c *check.C) {
+	_, err := tokenServiceBroker{}.PullCredentials("tsuru/app-myapp", time.Minute)
+	c.Assert(err, check.ErrorMatches, "registry-auth:token-service:realm is not set")
+}
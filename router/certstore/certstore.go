@@ -0,0 +1,59 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package certstore provides storage for the TLS certificates and private
+// keys of app cnames, which are sent to the app router.
+package certstore
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+var ErrCertificateNotFound = errors.New("certificate not found")
+
+var stores = make(map[string]func() (CertificateStore, error))
+
+// CertificateRef identifies the certificate of a cname of an app.
+type CertificateRef struct {
+	App   string
+	Pool  string
+	CName string
+}
+
+// Certificate is a PEM encoded certificate and its private key.
+type Certificate struct {
+	CertificateRef
+	Certificate string
+	Key         string
+}
+
+// CertificateStore stores certificates outside of the routers, allowing them
+// to be retrieved and sent to routers again.
+type CertificateStore interface {
+	Save(cert Certificate) error
+	Get(ref CertificateRef) (*Certificate, error)
+	Remove(ref CertificateRef) error
+}
+
+// Register registers a new certificate store, that can be later configured
+// and used.
+func Register(name string, factory func() (CertificateStore, error)) {
+	stores[name] = factory
+}
+
+// Get returns the certificate store set in the certificates:store config.
+// When no store is configured, it returns nil and certificates are kept only
+// in the app router.
+func Get() (CertificateStore, error) {
+	name, _ := config.GetString("certificates:store")
+	if name == "" {
+		return nil, nil
+	}
+	factory, ok := stores[name]
+	if !ok {
+		return nil, errors.Errorf("unknown certificate store: %q", name)
+	}
+	return factory()
+}
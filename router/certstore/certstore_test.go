@@ -0,0 +1,46 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package certstore
+
+import (
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestGetNotConfigured(c *check.C) {
+	store, err := Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(store, check.IsNil)
+}
+
+func (s *S) TestGetUnknownStore(c *check.C) {
+	config.Set("certificates:store", "unknown")
+	defer config.Unset("certificates:store")
+	_, err := Get()
+	c.Assert(err, check.ErrorMatches, `unknown certificate store: "unknown"`)
+}
+
+func (s *S) TestDatabaseStore(c *check.C) {
+	config.Set("certificates:store", "database")
+	defer config.Unset("certificates:store")
+	store, err := Get()
+	c.Assert(err, check.IsNil)
+	ref := CertificateRef{App: "myapp", Pool: "pool1", CName: "myapp.example.com"}
+	_, err = store.Get(ref)
+	c.Assert(err, check.Equals, ErrCertificateNotFound)
+	err = store.Save(Certificate{CertificateRef: ref, Certificate: "cert", Key: "key"})
+	c.Assert(err, check.IsNil)
+	err = store.Save(Certificate{CertificateRef: ref, Certificate: "cert2", Key: "key2"})
+	c.Assert(err, check.IsNil)
+	cert, err := store.Get(ref)
+	c.Assert(err, check.IsNil)
+	c.Assert(cert, check.DeepEquals, &Certificate{CertificateRef: ref, Certificate: "cert2", Key: "key2"})
+	err = store.Remove(ref)
+	c.Assert(err, check.IsNil)
+	_, err = store.Get(ref)
+	c.Assert(err, check.Equals, ErrCertificateNotFound)
+	err = store.Remove(ref)
+	c.Assert(err, check.Equals, ErrCertificateNotFound)
+}
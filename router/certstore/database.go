@@ -0,0 +1,77 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package certstore
+
+import (
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func init() {
+	Register("database", func() (CertificateStore, error) {
+		return &databaseStore{}, nil
+	})
+}
+
+type databaseStore struct{}
+
+type certificateData struct {
+	ID          string `bson:"_id"`
+	App         string
+	CName       string
+	Certificate string
+	Key         string
+}
+
+func certificateID(ref CertificateRef) string {
+	return ref.App + "/" + ref.CName
+}
+
+func (s *databaseStore) Save(cert Certificate) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Collection("certificates").UpsertId(certificateID(cert.CertificateRef), certificateData{
+		ID:          certificateID(cert.CertificateRef),
+		App:         cert.App,
+		CName:       cert.CName,
+		Certificate: cert.Certificate,
+		Key:         cert.Key,
+	})
+	return err
+}
+
+func (s *databaseStore) Get(ref CertificateRef) (*Certificate, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var data certificateData
+	err = conn.Collection("certificates").FindId(certificateID(ref)).One(&data)
+	if err == mgo.ErrNotFound {
+		return nil, ErrCertificateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{CertificateRef: ref, Certificate: data.Certificate, Key: data.Key}, nil
+}
+
+func (s *databaseStore) Remove(ref CertificateRef) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Collection("certificates").Remove(bson.M{"_id": certificateID(ref)})
+	if err == mgo.ErrNotFound {
+		return ErrCertificateNotFound
+	}
+	return err
+}
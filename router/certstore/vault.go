@@ -0,0 +1,121 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package certstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+const defaultVaultPath = "secret/tsuru/certificates"
+
+func init() {
+	Register("vault", newVaultStore)
+}
+
+// vaultStore keeps certificates in a key/value secrets engine of Vault,
+// using its HTTP API.
+type vaultStore struct {
+	address string
+	token   string
+	path    string
+}
+
+func newVaultStore() (CertificateStore, error) {
+	address, err := config.GetString("certificates:vault:address")
+	if err != nil {
+		return nil, errors.New("certificates:vault:address is required for the vault certificate store")
+	}
+	token, err := config.GetString("certificates:vault:token")
+	if err != nil {
+		return nil, errors.New("certificates:vault:token is required for the vault certificate store")
+	}
+	path, _ := config.GetString("certificates:vault:path")
+	if path == "" {
+		path = defaultVaultPath
+	}
+	return &vaultStore{
+		address: strings.TrimRight(address, "/"),
+		token:   token,
+		path:    strings.Trim(path, "/"),
+	}, nil
+}
+
+type vaultCertificate struct {
+	Certificate string `json:"certificate"`
+	Key         string `json:"key"`
+}
+
+func (s *vaultStore) do(method string, ref CertificateRef, body io.Reader) (*http.Response, error) {
+	url := s.address + "/v1/" + s.path + "/" + ref.App + "/" + ref.CName
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rsp, err := tsuruNet.Dial5Full60ClientNoKeepAlive.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode == http.StatusNotFound {
+		rsp.Body.Close()
+		return nil, ErrCertificateNotFound
+	}
+	if rsp.StatusCode >= http.StatusBadRequest {
+		defer rsp.Body.Close()
+		data, _ := ioutil.ReadAll(rsp.Body)
+		return nil, errors.Errorf("vault returned status %d for %s %s: %s", rsp.StatusCode, method, url, data)
+	}
+	return rsp, nil
+}
+
+func (s *vaultStore) Save(cert Certificate) error {
+	data, err := json.Marshal(vaultCertificate{Certificate: cert.Certificate, Key: cert.Key})
+	if err != nil {
+		return err
+	}
+	rsp, err := s.do(http.MethodPut, cert.CertificateRef, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	return nil
+}
+
+func (s *vaultStore) Get(ref CertificateRef) (*Certificate, error) {
+	rsp, err := s.do(http.MethodGet, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	var result struct {
+		Data vaultCertificate `json:"data"`
+	}
+	err = json.NewDecoder(rsp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{CertificateRef: ref, Certificate: result.Data.Certificate, Key: result.Data.Key}, nil
+}
+
+func (s *vaultStore) Remove(ref CertificateRef) error {
+	rsp, err := s.do(http.MethodDelete, ref, nil)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	return nil
+}
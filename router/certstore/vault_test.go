@@ -0,0 +1,84 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package certstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestVaultStore(c *check.C) {
+	secrets := map[string]vaultCertificate{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("X-Vault-Token"), check.Equals, "mytoken")
+		switch r.Method {
+		case http.MethodPut:
+			var data vaultCertificate
+			err := json.NewDecoder(r.Body).Decode(&data)
+			c.Check(err, check.IsNil)
+			secrets[r.URL.Path] = data
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			data, ok := secrets[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+		case http.MethodDelete:
+			delete(secrets, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	config.Set("certificates:store", "vault")
+	config.Set("certificates:vault:address", server.URL)
+	config.Set("certificates:vault:token", "mytoken")
+	defer config.Unset("certificates")
+	store, err := Get()
+	c.Assert(err, check.IsNil)
+	ref := CertificateRef{App: "myapp", Pool: "pool1", CName: "myapp.example.com"}
+	_, err = store.Get(ref)
+	c.Assert(err, check.Equals, ErrCertificateNotFound)
+	err = store.Save(Certificate{CertificateRef: ref, Certificate: "cert", Key: "key"})
+	c.Assert(err, check.IsNil)
+	c.Assert(secrets, check.DeepEquals, map[string]vaultCertificate{
+		"/v1/secret/tsuru/certificates/myapp/myapp.example.com": {Certificate: "cert", Key: "key"},
+	})
+	cert, err := store.Get(ref)
+	c.Assert(err, check.IsNil)
+	c.Assert(cert, check.DeepEquals, &Certificate{CertificateRef: ref, Certificate: "cert", Key: "key"})
+	err = store.Remove(ref)
+	c.Assert(err, check.IsNil)
+	c.Assert(secrets, check.HasLen, 0)
+}
+
+func (s *S) TestVaultStoreRequiresAddress(c *check.C) {
+	config.Set("certificates:store", "vault")
+	defer config.Unset("certificates")
+	_, err := Get()
+	c.Assert(err, check.ErrorMatches, "certificates:vault:address is required .*")
+}
+
+func (s *S) TestVaultStoreError(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("permission denied"))
+	}))
+	defer server.Close()
+	config.Set("certificates:store", "vault")
+	config.Set("certificates:vault:address", server.URL)
+	config.Set("certificates:vault:token", "mytoken")
+	config.Set("certificates:vault:path", "/kv/certs/")
+	defer config.Unset("certificates")
+	store, err := Get()
+	c.Assert(err, check.IsNil)
+	err = store.Save(Certificate{CertificateRef: CertificateRef{App: "myapp", CName: "myapp.example.com"}})
+	c.Assert(err, check.ErrorMatches, `vault returned status 403 for PUT .*/v1/kv/certs/myapp/myapp.example.com: permission denied`)
+}
@@ -670,6 +670,91 @@ type planbRouter struct {
 	hipacheRouter
 }
 
+func (r *planbRouter) RemoveBackend(name string) (err error) {
+	key, err := r.mirrorKey(name)
+	if err != nil {
+		return err
+	}
+	err = r.hipacheRouter.RemoveBackend(name)
+	if err != nil {
+		return err
+	}
+	conn, err := r.connect()
+	if err != nil {
+		return &router.RouterError{Op: "remove", Err: err}
+	}
+	err = conn.Del(key).Err()
+	if err != nil {
+		return &router.RouterError{Op: "remove", Err: err}
+	}
+	return nil
+}
+
+// mirrorKey returns the key of the hash read by planb to copy requests of the
+// frontend of the backend to the frontend of its mirror.
+func (r *planbRouter) mirrorKey(name string) (string, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return "", err
+	}
+	domain, err := config.GetString(r.prefix + ":domain")
+	if err != nil {
+		return "", &router.RouterError{Op: "mirror", Err: err}
+	}
+	return "mirror:" + backendName + "." + domain, nil
+}
+
+func (r *planbRouter) SetMirror(name, mirror string, percentage int) (err error) {
+	done := router.InstrumentRequest(r.routerName)
+	defer func() {
+		done(err)
+	}()
+	key, err := r.mirrorKey(name)
+	if err != nil {
+		return err
+	}
+	mirrorBackend, err := router.Retrieve(mirror)
+	if err != nil {
+		return err
+	}
+	domain, err := config.GetString(r.prefix + ":domain")
+	if err != nil {
+		return &router.RouterError{Op: "setMirror", Err: err}
+	}
+	conn, err := r.connect()
+	if err != nil {
+		return &router.RouterError{Op: "setMirror", Err: err}
+	}
+	err = conn.HMSetMap(key, map[string]string{
+		"frontend":   mirrorBackend + "." + domain,
+		"percentage": strconv.Itoa(percentage),
+	}).Err()
+	if err != nil {
+		return &router.RouterError{Op: "setMirror", Err: err}
+	}
+	return nil
+}
+
+func (r *planbRouter) UnsetMirror(name string) (err error) {
+	done := router.InstrumentRequest(r.routerName)
+	defer func() {
+		done(err)
+	}()
+	key, err := r.mirrorKey(name)
+	if err != nil {
+		return err
+	}
+	conn, err := r.connect()
+	if err != nil {
+		return &router.RouterError{Op: "unsetMirror", Err: err}
+	}
+	err = conn.Del(key).Err()
+	if err != nil {
+		return &router.RouterError{Op: "unsetMirror", Err: err}
+	}
+	return nil
+}
+
 func (r *planbRouter) AddCertificate(cname, cert, key string) (err error) {
 	done := router.InstrumentRequest(r.routerName)
 	defer func() {
@@ -760,3 +760,56 @@ func (s *S) TestGetCertificateNotFound(c *check.C) {
 	c.Assert(err, check.DeepEquals, router.ErrCertificateNotFound)
 	c.Assert(cert, check.Equals, "")
 }
+
+func (s *S) TestSetMirror(c *check.C) {
+	config.Set("planb:domain", "planb.router")
+	defer config.Unset("planb:domain")
+	r := planbRouter{hipacheRouter{prefix: "planb"}}
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	defer r.RemoveBackend("myapp")
+	err = r.AddBackend("myapp-next")
+	c.Assert(err, check.IsNil)
+	defer r.RemoveBackend("myapp-next")
+	err = r.SetMirror("myapp", "myapp-next", 10)
+	c.Assert(err, check.IsNil)
+	redisConn, err := r.connect()
+	c.Assert(err, check.IsNil)
+	data, err := redisConn.HMGet("mirror:myapp.planb.router", "frontend", "percentage").Result()
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.DeepEquals, []interface{}{"myapp-next.planb.router", "10"})
+	err = r.UnsetMirror("myapp")
+	c.Assert(err, check.IsNil)
+	exists, err := redisConn.Exists("mirror:myapp.planb.router").Result()
+	c.Assert(err, check.IsNil)
+	c.Assert(exists, check.Equals, false)
+}
+
+func (s *S) TestRemoveBackendRemovesMirror(c *check.C) {
+	config.Set("planb:domain", "planb.router")
+	defer config.Unset("planb:domain")
+	r := planbRouter{hipacheRouter{prefix: "planb"}}
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = r.AddBackend("myapp-next")
+	c.Assert(err, check.IsNil)
+	defer r.RemoveBackend("myapp-next")
+	err = r.SetMirror("myapp", "myapp-next", 50)
+	c.Assert(err, check.IsNil)
+	err = r.RemoveBackend("myapp")
+	c.Assert(err, check.IsNil)
+	redisConn, err := r.connect()
+	c.Assert(err, check.IsNil)
+	exists, err := redisConn.Exists("mirror:myapp.planb.router").Result()
+	c.Assert(err, check.IsNil)
+	c.Assert(exists, check.Equals, false)
+}
+
+func (s *S) TestHipacheRouterDoesNotMirror(c *check.C) {
+	var r router.Router = &hipacheRouter{prefix: "hipache"}
+	_, ok := r.(router.MirrorRouter)
+	c.Assert(ok, check.Equals, false)
+	r = &planbRouter{hipacheRouter{prefix: "planb"}}
+	_, ok = r.(router.MirrorRouter)
+	c.Assert(ok, check.Equals, true)
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rebuild
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/dns"
+	"github.com/tsuru/tsuru/jobs"
+	"github.com/tsuru/tsuru/log"
+)
+
+const dnsSyncJob = "dns-sync"
+
+func init() {
+	jobs.Register(dnsSyncJob, runDNSSyncJob)
+}
+
+// enqueueDNSSync retries publishing the DNS records of the app in
+// background, so that failures of the DNS backend don't fail the rebuild of
+// the routes of the app.
+func enqueueDNSSync(appName string) {
+	_, err := jobs.Enqueue(dnsSyncJob, dnsSyncJob+":"+appName, jobs.Params{
+		"app": appName,
+	})
+	if err != nil {
+		log.Errorf("[rebuild-routes] unable to enqueue dns sync of app %q: %s", appName, err)
+	}
+}
+
+func runDNSSyncJob(params jobs.Params) error {
+	if appFinder == nil {
+		return errors.New("app finder not registered")
+	}
+	a, err := appFinder(params["app"])
+	if err != nil {
+		return err
+	}
+	// The app was removed along with its records.
+	if a == nil {
+		return nil
+	}
+	dnsApp, ok := a.(dns.App)
+	if !ok {
+		return nil
+	}
+	return dns.SyncApp(dnsApp)
+}
@@ -7,6 +7,8 @@ package rebuild
 import (
 	"net/url"
 
+	"github.com/tsuru/tsuru/dns"
+	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/router"
 )
 
@@ -86,5 +88,12 @@ func RebuildRoutes(app RebuildApp) (*RebuildRoutesResult, error) {
 		}
 		result.Removed = append(result.Removed, toRemoveUrl.String())
 	}
+	if dnsApp, ok := app.(dns.App); ok {
+		err = dns.SyncApp(dnsApp)
+		if err != nil {
+			log.Errorf("[rebuild-routes] %s, retrying in background", err)
+			enqueueDNSSync(app.GetName())
+		}
+	}
 	return &result, nil
 }
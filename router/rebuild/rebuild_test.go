@@ -5,10 +5,15 @@
 package rebuild_test
 
 import (
+	"errors"
 	"net/url"
 	"sort"
 
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/dns"
+	"github.com/tsuru/tsuru/dns/dnstest"
+	"github.com/tsuru/tsuru/jobs"
 	"github.com/tsuru/tsuru/provision/provisiontest"
 	"github.com/tsuru/tsuru/router/rebuild"
 	"github.com/tsuru/tsuru/router/routertest"
@@ -42,6 +47,59 @@ func (s *S) TestRebuildRoutes(c *check.C) {
 	c.Assert(app.Ip, check.Equals, addr)
 }
 
+func (s *S) TestRebuildRoutesSyncsDNSRecords(c *check.C) {
+	config.Set("dns:backend", "fake")
+	defer config.Unset("dns:backend")
+	defer dnstest.Reset()
+	a := app.App{Name: "my-test-app", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = provisiontest.ProvisionerInstance.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	_, err = rebuild.RebuildRoutes(&a)
+	c.Assert(err, check.IsNil)
+	addrs := []string{units[0].Address.Host, units[1].Address.Host}
+	sort.Strings(addrs)
+	c.Assert(dnstest.Records(a.Name), check.DeepEquals, []dns.Record{
+		{Name: "my-test-app.tsuru.internal", Addresses: addrs},
+		{Name: "web.my-test-app.tsuru.internal", Addresses: addrs},
+	})
+}
+
+func (s *S) TestRebuildRoutesDNSFailureEnqueuesRetry(c *check.C) {
+	config.Set("dns:backend", "fake")
+	defer config.Unset("dns:backend")
+	defer dnstest.Reset()
+	dnstest.FailSetRecords(errors.New("dns server is down"))
+	a := app.App{Name: "my-test-app", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = provisiontest.ProvisionerInstance.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	changes, err := rebuild.RebuildRoutes(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.NotNil)
+	c.Assert(dnstest.Records(a.Name), check.IsNil)
+	pending, err := jobs.List(jobs.Filter{Kind: "dns-sync"})
+	c.Assert(err, check.IsNil)
+	c.Assert(pending, check.HasLen, 1)
+	c.Assert(pending[0].Params, check.DeepEquals, jobs.Params{"app": a.Name})
+	dnstest.FailSetRecords(nil)
+	_, err = jobs.RunPending()
+	c.Assert(err, check.IsNil)
+	job, err := jobs.Get(pending[0].ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(job.Status, check.Equals, jobs.StatusSucceeded)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(dnstest.Records(a.Name), check.DeepEquals, []dns.Record{
+		{Name: "my-test-app.tsuru.internal", Addresses: []string{units[0].Address.Host}},
+		{Name: "web.my-test-app.tsuru.internal", Addresses: []string{units[0].Address.Host}},
+	})
+}
+
 func (s *S) TestRebuildRoutesTCPRoutes(c *check.C) {
 	a := app.App{Name: "my-test-app", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
@@ -153,6 +154,32 @@ type TLSRouter interface {
 	GetCertificate(cname string) (string, error)
 }
 
+// MaintenanceRouter is a router able to serve a maintenance page in place of
+// the backend, either custom HTML or a redirect to another address.
+type MaintenanceRouter interface {
+	SetMaintenance(name string, page MaintenancePage) error
+	UnsetMaintenance(name string) error
+}
+
+type MaintenancePage struct {
+	HTML        string
+	RedirectURL string
+}
+
+// MirrorRouter is a router able to copy a percentage of the requests of a
+// backend to another backend. Responses of the mirror are discarded.
+type MirrorRouter interface {
+	SetMirror(name, mirror string, percentage int) error
+	UnsetMirror(name string) error
+}
+
+// FaultInjectionRouter is a router able to delay every request of a backend,
+// used by chaos experiments to simulate slow networks.
+type FaultInjectionRouter interface {
+	SetLatency(name string, latency time.Duration) error
+	UnsetLatency(name string) error
+}
+
 type HealthcheckData struct {
 	Path   string
 	Status int
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/router"
@@ -45,7 +46,7 @@ func createTLSRouter(name, prefix string) (router.Router, error) {
 }
 
 func newFakeRouter() fakeRouter {
-	return fakeRouter{cnames: make(map[string]string), backends: make(map[string][]string), failuresByIp: make(map[string]bool), healthcheck: make(map[string]router.HealthcheckData), mutex: &sync.Mutex{}}
+	return fakeRouter{cnames: make(map[string]string), backends: make(map[string][]string), failuresByIp: make(map[string]bool), healthcheck: make(map[string]router.HealthcheckData), maintenance: make(map[string]router.MaintenancePage), mirrors: make(map[string]Mirror), latencies: make(map[string]time.Duration), mutex: &sync.Mutex{}}
 }
 
 type fakeRouter struct {
@@ -53,9 +54,18 @@ type fakeRouter struct {
 	cnames       map[string]string
 	failuresByIp map[string]bool
 	healthcheck  map[string]router.HealthcheckData
+	maintenance  map[string]router.MaintenancePage
+	mirrors      map[string]Mirror
+	latencies    map[string]time.Duration
 	mutex        *sync.Mutex
 }
 
+// Mirror is a backend receiving a copy of the requests of another backend.
+type Mirror struct {
+	Backend    string
+	Percentage int
+}
+
 func (r *fakeRouter) FailForIp(ip string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -169,6 +179,9 @@ func (r *fakeRouter) RemoveBackend(name string) error {
 		}
 	}
 	delete(r.backends, backendName)
+	delete(r.maintenance, backendName)
+	delete(r.mirrors, backendName)
+	delete(r.latencies, backendName)
 	return nil
 }
 
@@ -343,6 +356,9 @@ func (r *fakeRouter) Reset() {
 	r.failuresByIp = make(map[string]bool)
 	r.cnames = make(map[string]string)
 	r.healthcheck = make(map[string]router.HealthcheckData)
+	r.maintenance = make(map[string]router.MaintenancePage)
+	r.mirrors = make(map[string]Mirror)
+	r.latencies = make(map[string]time.Duration)
 }
 
 func (r *fakeRouter) Routes(name string) ([]*url.URL, error) {
@@ -396,6 +412,109 @@ func (r *fakeRouter) SetHealthcheck(name string, data router.HealthcheckData) er
 	return nil
 }
 
+func (r *fakeRouter) SetMaintenance(name string, page router.MaintenancePage) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.backends[backendName]; !ok {
+		return router.ErrBackendNotFound
+	}
+	r.maintenance[backendName] = page
+	return nil
+}
+
+func (r *fakeRouter) UnsetMaintenance(name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.maintenance, backendName)
+	return nil
+}
+
+func (r *fakeRouter) MaintenancePage(name string) (router.MaintenancePage, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	page, ok := r.maintenance[name]
+	return page, ok
+}
+
+func (r *fakeRouter) SetMirror(name, mirror string, percentage int) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	mirrorBackend, err := router.Retrieve(mirror)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.backends[backendName]; !ok {
+		return router.ErrBackendNotFound
+	}
+	if _, ok := r.backends[mirrorBackend]; !ok {
+		return router.ErrBackendNotFound
+	}
+	r.mirrors[backendName] = Mirror{Backend: mirrorBackend, Percentage: percentage}
+	return nil
+}
+
+func (r *fakeRouter) UnsetMirror(name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.mirrors, backendName)
+	return nil
+}
+
+func (r *fakeRouter) GetMirror(name string) (Mirror, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	mirror, ok := r.mirrors[name]
+	return mirror, ok
+}
+
+func (r *fakeRouter) SetLatency(name string, latency time.Duration) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.backends[backendName]; !ok {
+		return router.ErrBackendNotFound
+	}
+	r.latencies[backendName] = latency
+	return nil
+}
+
+func (r *fakeRouter) UnsetLatency(name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.latencies, backendName)
+	return nil
+}
+
+func (r *fakeRouter) GetLatency(name string) (time.Duration, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	latency, ok := r.latencies[name]
+	return latency, ok
+}
+
 type tlsRouter struct {
 	fakeRouter
 	Certs map[string]string
@@ -257,6 +257,23 @@ func (s *S) TestSwap(c *check.C) {
 	c.Assert(addr, check.Equals, "b1.fakerouter.com")
 }
 
+func (s *S) TestSetMaintenance(c *check.C) {
+	r := newFakeRouter()
+	err := r.SetMaintenance("name", router.MaintenancePage{HTML: "<h1>down</h1>"})
+	c.Assert(err, check.Equals, router.ErrBackendNotFound)
+	err = r.AddBackend("name")
+	c.Assert(err, check.IsNil)
+	err = r.SetMaintenance("name", router.MaintenancePage{HTML: "<h1>down</h1>"})
+	c.Assert(err, check.IsNil)
+	page, ok := r.MaintenancePage("name")
+	c.Assert(ok, check.Equals, true)
+	c.Assert(page, check.DeepEquals, router.MaintenancePage{HTML: "<h1>down</h1>"})
+	err = r.UnsetMaintenance("name")
+	c.Assert(err, check.IsNil)
+	_, ok = r.MaintenancePage("name")
+	c.Assert(ok, check.Equals, false)
+}
+
 func (s *S) TestAddCertificate(c *check.C) {
 	r := TLSRouter
 	err := r.AddCertificate("example.com", "cert", "key")
@@ -127,6 +127,9 @@ func (c *Client) Create(instance *ServiceInstance, user, requestID string) error
 	if instance.Description != "" {
 		params["description"] = []string{instance.Description}
 	}
+	if instance.Pool != "" {
+		params["pool"] = []string{instance.Pool}
+	}
 	log.Debugf("Attempting to call creation of service instance for %q, params: %#v", instance.ServiceName, params)
 	resp, err = c.issueRequest("/resources", "POST", params)
 	if err == nil {
@@ -268,6 +271,13 @@ func (c *Client) UnbindUnit(instance *ServiceInstance, app bind.App, unit bind.U
 }
 
 func (c *Client) Status(instance *ServiceInstance, requestID string) (string, error) {
+	status, err := c.StatusDetail(instance, requestID)
+	return status.Status, err
+}
+
+// StatusDetail returns the status of the instance along with the progress
+// message sent by the service api in the body of pending responses.
+func (c *Client) StatusDetail(instance *ServiceInstance, requestID string) (InstanceStatus, error) {
 	log.Debugf("Attempting to call status of service instance %q at %q api", instance.Name, instance.ServiceName)
 	var (
 		resp *http.Response
@@ -283,19 +293,21 @@ func (c *Client) Status(instance *ServiceInstance, requestID string) (string, er
 		case http.StatusOK:
 			var data []byte
 			data, err = ioutil.ReadAll(resp.Body)
-			return string(data), err
+			return InstanceStatus{Status: string(data)}, err
 		case http.StatusAccepted:
-			return "pending", nil
+			var data []byte
+			data, err = ioutil.ReadAll(resp.Body)
+			return InstanceStatus{Status: InstanceStatusPending, Message: strings.TrimSpace(string(data))}, err
 		case http.StatusNoContent:
-			return "up", nil
+			return InstanceStatus{Status: "up"}, nil
 		case http.StatusNotFound:
-			return "not implemented for this service", nil
+			return InstanceStatus{Status: "not implemented for this service"}, nil
 		case http.StatusInternalServerError:
-			return "down", nil
+			return InstanceStatus{Status: "down"}, nil
 		}
 	}
 	err = errors.Wrapf(c.buildErrorMessage(err, resp), "Failed to get status of instance %s", instance.Name)
-	return "", log.WrapError(err)
+	return InstanceStatus{}, log.WrapError(err)
 }
 
 // Info returns the additional info about a service instance.
@@ -545,6 +545,20 @@ func (s *S) TestStatus(c *check.C) {
 	}
 }
 
+func (s *S) TestStatusDetail(c *check.C) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("creating database (2/5)\n"))
+	})
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	status, err := client.StatusDetail(&instance, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, InstanceStatus{Status: "pending", Message: "creating database (2/5)"})
+}
+
 func (s *S) TestInfo(c *check.C) {
 	h := infoHandler{}
 	ts := httptest.NewServer(&h)
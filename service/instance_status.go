@@ -0,0 +1,94 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+// InstanceStatusPending is the status of instances still being provisioned
+// by the service api.
+const InstanceStatusPending = "pending"
+
+const (
+	defaultStatusStreamInterval = 5 * time.Second
+	defaultStatusStreamTimeout  = 10 * time.Minute
+)
+
+var ErrStatusStreamTimeout = errors.New("timeout waiting for the service instance to leave the pending status")
+
+// InstanceStatus is the status of a service instance as reported by the
+// service api. Message holds the provisioning progress of pending instances.
+type InstanceStatus struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty" bson:",omitempty"`
+}
+
+func (s InstanceStatus) String() string {
+	if s.Message == "" {
+		return s.Status
+	}
+	return fmt.Sprintf("%s: %s", s.Status, s.Message)
+}
+
+// StatusDetail returns the status of the instance along with its
+// provisioning progress.
+func (si *ServiceInstance) StatusDetail(requestID string) (InstanceStatus, error) {
+	endpoint, err := si.Service().getClient("production")
+	if err != nil {
+		return InstanceStatus{}, err
+	}
+	return endpoint.StatusDetail(si, requestID)
+}
+
+// StatusStreamOptions returns the interval between status checks and the
+// maximum time to wait for pending instances, set in the
+// services:status-stream:interval and services:status-stream:timeout
+// settings.
+func StatusStreamOptions() (interval, timeout time.Duration) {
+	interval, err := config.GetDuration("services:status-stream:interval")
+	if err != nil || interval <= 0 {
+		interval = defaultStatusStreamInterval
+	}
+	timeout, err = config.GetDuration("services:status-stream:timeout")
+	if err != nil || timeout <= 0 {
+		timeout = defaultStatusStreamTimeout
+	}
+	return interval, timeout
+}
+
+// StreamStatus checks the status of the instance every interval, writing
+// each change to w, until the instance is no longer pending or the timeout
+// is reached. The last status is returned.
+func (si *ServiceInstance) StreamStatus(w io.Writer, requestID string, interval, timeout time.Duration) (InstanceStatus, error) {
+	endpoint, err := si.Service().getClient("production")
+	if err != nil {
+		return InstanceStatus{}, err
+	}
+	deadline := time.Now().Add(timeout)
+	var last InstanceStatus
+	for {
+		status, err := endpoint.StatusDetail(si, requestID)
+		if err != nil {
+			return last, err
+		}
+		if status != last {
+			fmt.Fprintf(w, "Service instance %q is %s\n", si.Name, status)
+			last = status
+		}
+		if status.Status != InstanceStatusPending {
+			return status, nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return status, ErrStatusStreamTimeout
+		}
+		time.Sleep(interval)
+	}
+}
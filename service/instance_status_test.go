@@ -0,0 +1,93 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *InstanceSuite) TestStatusDetail(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("creating database\n"))
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mongodb", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	si := ServiceInstance{Name: "instance", ServiceName: srv.Name}
+	status, err := si.StatusDetail("")
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, InstanceStatus{Status: InstanceStatusPending, Message: "creating database"})
+	c.Assert(status.String(), check.Equals, "pending: creating database")
+}
+
+func (s *InstanceSuite) TestStreamStatus(c *check.C) {
+	responses := []struct {
+		code int
+		body string
+	}{
+		{http.StatusAccepted, "creating database"},
+		{http.StatusAccepted, "creating database"},
+		{http.StatusAccepted, "creating users"},
+		{http.StatusNoContent, ""},
+	}
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := responses[calls]
+		calls++
+		w.WriteHeader(resp.code)
+		w.Write([]byte(resp.body))
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mongodb", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	si := ServiceInstance{Name: "instance", ServiceName: srv.Name}
+	var buf bytes.Buffer
+	status, err := si.StreamStatus(&buf, "", time.Millisecond, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, InstanceStatus{Status: "up"})
+	c.Assert(calls, check.Equals, 4)
+	c.Assert(buf.String(), check.Equals, `Service instance "instance" is pending: creating database
+Service instance "instance" is pending: creating users
+Service instance "instance" is up
+`)
+}
+
+func (s *InstanceSuite) TestStreamStatusTimeout(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mongodb", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	si := ServiceInstance{Name: "instance", ServiceName: srv.Name}
+	var buf bytes.Buffer
+	status, err := si.StreamStatus(&buf, "", 10*time.Millisecond, 50*time.Millisecond)
+	c.Assert(err, check.Equals, ErrStatusStreamTimeout)
+	c.Assert(status, check.Equals, InstanceStatus{Status: InstanceStatusPending})
+	c.Assert(buf.String(), check.Equals, "Service instance \"instance\" is pending\n")
+}
+
+func (s *InstanceSuite) TestStatusStreamOptions(c *check.C) {
+	interval, timeout := StatusStreamOptions()
+	c.Assert(interval, check.Equals, 5*time.Second)
+	c.Assert(timeout, check.Equals, 10*time.Minute)
+	config.Set("services:status-stream:interval", "1s")
+	defer config.Unset("services:status-stream:interval")
+	config.Set("services:status-stream:timeout", "2m")
+	defer config.Unset("services:status-stream:timeout")
+	interval, timeout = StatusStreamOptions()
+	c.Assert(interval, check.Equals, time.Second)
+	c.Assert(timeout, check.Equals, 2*time.Minute)
+}
@@ -6,8 +6,8 @@ package service
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
-	"regexp"
 	"strconv"
 	"strings"
 
@@ -16,14 +16,17 @@ import (
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/naming"
+	"github.com/tsuru/tsuru/provision"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 var (
 	ErrServiceInstanceNotFound   = errors.New("service instance not found")
-	ErrInvalidInstanceName       = errors.New("invalid service instance name")
+	ErrInvalidInstanceName       = naming.ErrInvalidServiceInstanceName
 	ErrInstanceNameAlreadyExists = errors.New("instance name already exists.")
 	ErrAccessNotAllowed          = errors.New("user does not have access to this service instance")
 	ErrTeamMandatory             = errors.New("please specify the team that owns the service instance")
@@ -32,7 +35,6 @@ var (
 	ErrUnitAlreadyBound          = errors.New("unit is already bound to this service instance")
 	ErrUnitNotBound              = errors.New("unit is not bound to this service instance")
 	ErrServiceInstanceBound      = errors.New("This service instance is bound to at least one app. Unbind them before removing it")
-	instanceNameRegexp           = regexp.MustCompile(`^[A-Za-z][-a-zA-Z0-9_]+$`)
 )
 
 type ServiceInstance struct {
@@ -46,6 +48,9 @@ type ServiceInstance struct {
 	TeamOwner   string
 	Description string
 	Tags        []string
+	// Pool is the pool where the service provisions the workloads of the
+	// instance, for services running inside tsuru-managed clusters.
+	Pool string `bson:",omitempty"`
 }
 
 // DeleteInstance deletes the service instance from the database.
@@ -303,16 +308,21 @@ func genericServiceInstancesFilter(services interface{}, teams []string) bson.M
 	return query
 }
 
-func validateServiceInstanceName(service string, instance string) error {
-	if !instanceNameRegexp.MatchString(instance) {
-		return ErrInvalidInstanceName
+func validateServiceInstanceName(service string, instance ServiceInstance) error {
+	err := naming.Validate(naming.Target{
+		Kind: naming.KindServiceInstance,
+		Name: instance.Name,
+		Team: instance.TeamOwner,
+	})
+	if err != nil {
+		return err
 	}
 	conn, err := db.Conn()
 	if err != nil {
 		return nil
 	}
 	defer conn.Close()
-	query := bson.M{"name": instance, "service_name": service}
+	query := bson.M{"name": instance.Name, "service_name": service}
 	length, err := conn.ServiceInstances().Find(query).Count()
 	if err != nil {
 		return err
@@ -323,8 +333,38 @@ func validateServiceInstanceName(service string, instance string) error {
 	return nil
 }
 
+// validateServiceInstancePool checks whether the instance may be placed in its
+// pool, following the same rules applied to apps created in the pool.
+func validateServiceInstancePool(instance ServiceInstance) error {
+	if instance.Pool == "" {
+		return nil
+	}
+	pool, err := provision.GetPoolByName(instance.Pool)
+	if err != nil {
+		if err == provision.ErrPoolNotFound {
+			return &tsuruErrors.ValidationError{Message: err.Error()}
+		}
+		return err
+	}
+	if !pool.AcceptsNewApps() {
+		msg := fmt.Sprintf("pool %q is %s and doesn't accept new service instances", pool.Name, pool.GetState())
+		return &tsuruErrors.ValidationError{Message: msg}
+	}
+	teams, err := pool.GetTeams()
+	if err != nil && err != provision.ErrPoolHasNoTeam {
+		return err
+	}
+	for _, team := range teams {
+		if team == instance.TeamOwner {
+			return nil
+		}
+	}
+	msg := fmt.Sprintf("service instance team owner %q has no access to pool %q", instance.TeamOwner, pool.Name)
+	return &tsuruErrors.ValidationError{Message: msg}
+}
+
 func CreateServiceInstance(instance ServiceInstance, service *Service, user *auth.User, requestID string) error {
-	err := validateServiceInstanceName(service.Name, instance.Name)
+	err := validateServiceInstanceName(service.Name, instance)
 	if err != nil {
 		return err
 	}
@@ -332,6 +372,10 @@ func CreateServiceInstance(instance ServiceInstance, service *Service, user *aut
 	if instance.TeamOwner == "" {
 		return ErrTeamMandatory
 	}
+	err = validateServiceInstancePool(instance)
+	if err != nil {
+		return err
+	}
 	instance.Teams = []string{instance.TeamOwner}
 	instance.Tags = processTags(instance.Tags)
 	actions := []*action.Action{&createServiceInstance, &insertServiceInstance}
@@ -352,21 +396,44 @@ func GetServiceInstancesByServices(services []Service) ([]ServiceInstance, error
 	return instances, err
 }
 
-func GetServicesInstancesByTeamsAndNames(teams []string, names []string, appName, serviceName string) ([]ServiceInstance, error) {
-	filter := bson.M{}
-	if teams != nil || names != nil {
-		filter = bson.M{
-			"$or": []bson.M{
-				{"teams": bson.M{"$in": teams}},
-				{"name": bson.M{"$in": names}},
-			},
-		}
+// ServiceInstanceFilter narrows the listing of service instances. Empty
+// fields are ignored, and instances must have all the given tags.
+type ServiceInstanceFilter struct {
+	AppName     string
+	ServiceName string
+	TeamOwner   string
+	PlanName    string
+	Tags        []string
+}
+
+func (f *ServiceInstanceFilter) query() bson.M {
+	query := bson.M{}
+	if f.AppName != "" {
+		query["apps"] = f.AppName
 	}
-	if appName != "" {
-		filter["apps"] = appName
+	if f.ServiceName != "" {
+		query["service_name"] = f.ServiceName
 	}
-	if serviceName != "" {
-		filter["service_name"] = serviceName
+	if f.TeamOwner != "" {
+		query["teamowner"] = f.TeamOwner
+	}
+	if f.PlanName != "" {
+		query["plan_name"] = f.PlanName
+	}
+	tags := processTags(f.Tags)
+	if len(tags) > 0 {
+		query["tags"] = bson.M{"$all": tags}
+	}
+	return query
+}
+
+func GetServicesInstancesByTeamsAndNames(teams []string, names []string, filter ServiceInstanceFilter) ([]ServiceInstance, error) {
+	query := filter.query()
+	if teams != nil || names != nil {
+		query["$or"] = []bson.M{
+			{"teams": bson.M{"$in": teams}},
+			{"name": bson.M{"$in": names}},
+		}
 	}
 	conn, err := db.Conn()
 	if err != nil {
@@ -374,7 +441,7 @@ func GetServicesInstancesByTeamsAndNames(teams []string, names []string, appName
 	}
 	defer conn.Close()
 	var instances []ServiceInstance
-	err = conn.ServiceInstances().Find(filter).All(&instances)
+	err = conn.ServiceInstances().Find(query).All(&instances)
 	return instances, err
 }
 
@@ -22,6 +22,8 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/provisiontest"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
@@ -213,6 +215,48 @@ func (s *InstanceSuite) TestGenericServiceInstancesFilterWithoutSpecifingTeams(c
 	c.Assert(query, check.DeepEquals, bson.M{"service_name": bson.M{"$in": names}})
 }
 
+func (s *InstanceSuite) TestServiceInstanceFilterQuery(c *check.C) {
+	filter := ServiceInstanceFilter{
+		AppName:     "myapp",
+		ServiceName: "redis",
+		TeamOwner:   "myteam",
+		PlanName:    "small",
+		Tags:        []string{" env=prod ", "", "env=prod", "tier=cache"},
+	}
+	c.Assert(filter.query(), check.DeepEquals, bson.M{
+		"apps":         "myapp",
+		"service_name": "redis",
+		"teamowner":    "myteam",
+		"plan_name":    "small",
+		"tags":         bson.M{"$all": []string{"env=prod", "tier=cache"}},
+	})
+	filter = ServiceInstanceFilter{}
+	c.Assert(filter.query(), check.DeepEquals, bson.M{})
+}
+
+func (s *InstanceSuite) TestGetServicesInstancesByTeamsAndNamesWithFilter(c *check.C) {
+	instances := []ServiceInstance{
+		{Name: "redis-prod", ServiceName: "redis", PlanName: "large", TeamOwner: s.team.Name, Teams: []string{s.team.Name}, Tags: []string{"env=prod", "tier=cache"}},
+		{Name: "redis-dev", ServiceName: "redis", PlanName: "small", TeamOwner: s.team.Name, Teams: []string{s.team.Name}, Tags: []string{"env=dev", "tier=cache"}},
+		{Name: "redis-other", ServiceName: "redis", PlanName: "large", TeamOwner: "other", Teams: []string{"other"}, Tags: []string{"env=prod"}},
+	}
+	for _, si := range instances {
+		err := s.conn.ServiceInstances().Insert(&si)
+		c.Assert(err, check.IsNil)
+	}
+	result, err := GetServicesInstancesByTeamsAndNames(nil, nil, ServiceInstanceFilter{Tags: []string{"env=prod"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 2)
+	result, err = GetServicesInstancesByTeamsAndNames([]string{s.team.Name}, nil, ServiceInstanceFilter{Tags: []string{"env=prod"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].Name, check.Equals, "redis-prod")
+	result, err = GetServicesInstancesByTeamsAndNames(nil, nil, ServiceInstanceFilter{TeamOwner: s.team.Name, PlanName: "small"})
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].Name, check.Equals, "redis-dev")
+}
+
 func (s *InstanceSuite) TestAdditionalInfo(c *check.C) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`[{"label": "key", "value": "value"}, {"label": "key2", "value": "value2"}]`))
@@ -356,6 +400,64 @@ func (s *InstanceSuite) TestCreateServiceInstance(c *check.C) {
 	c.Assert(si.Tags, check.DeepEquals, []string{"tag1", "tag2"})
 }
 
+func (s *InstanceSuite) TestCreateServiceInstanceInPool(c *check.C) {
+	var pool string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pool = r.FormValue("pool")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	err := provision.AddPool(provision.AddPoolOptions{Name: "storage"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddTeamsToPool("storage", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	srv := Service{Name: "mongodb", Endpoint: map[string]string{"production": ts.URL}}
+	err = s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	instance := ServiceInstance{Name: "instance", TeamOwner: s.team.Name, Pool: "storage"}
+	err = CreateServiceInstance(instance, &srv, s.user, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool, check.Equals, "storage")
+	si, err := GetServiceInstance("mongodb", "instance")
+	c.Assert(err, check.IsNil)
+	c.Assert(si.Pool, check.Equals, "storage")
+}
+
+func (s *InstanceSuite) TestCreateServiceInstanceInvalidPool(c *check.C) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer ts.Close()
+	err := provision.AddPool(provision.AddPoolOptions{Name: "storage"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "old"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddTeamsToPool("old", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	err = provision.PoolUpdate("old", provision.UpdatePoolOptions{State: provision.PoolStateDraining})
+	c.Assert(err, check.IsNil)
+	srv := Service{Name: "mongodb", Endpoint: map[string]string{"production": ts.URL}}
+	err = s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		pool    string
+		message string
+	}{
+		{"unknown", provision.ErrPoolNotFound.Error()},
+		{"storage", `service instance team owner "Raul" has no access to pool "storage"`},
+		{"old", `pool "old" is draining and doesn't accept new service instances`},
+	}
+	for _, tt := range tests {
+		instance := ServiceInstance{Name: "instance", TeamOwner: s.team.Name, Pool: tt.pool}
+		err = CreateServiceInstance(instance, &srv, s.user, "")
+		c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+		c.Assert(err.(*tsuruErrors.ValidationError).Message, check.Equals, tt.message)
+	}
+	c.Assert(atomic.LoadInt32(&requests), check.Equals, int32(0))
+}
+
 func (s *InstanceSuite) TestCreateServiceInstanceWithSameInstanceName(c *check.C) {
 	var requests int32
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -487,6 +589,24 @@ func (s *InstanceSuite) TestCreateServiceInstanceValidatesTheName(c *check.C) {
 	}
 }
 
+func (s *InstanceSuite) TestCreateServiceInstanceTeamPrefix(c *check.C) {
+	config.Set("naming:service-instance:team-prefixes:"+s.team.Name, []interface{}{"raul-"})
+	defer config.Unset("naming")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mongodb", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	instance := ServiceInstance{Name: "instance", TeamOwner: s.team.Name}
+	err = CreateServiceInstance(instance, &srv, s.user, "")
+	c.Assert(err, check.ErrorMatches, `Invalid service instance name, names owned by the team ".*" must start with one of: raul-.`)
+	instance.Name = "raul-instance"
+	err = CreateServiceInstance(instance, &srv, s.user, "")
+	c.Assert(err, check.IsNil)
+}
+
 func (s *InstanceSuite) TestCreateServiceInstanceRemovesDuplicatedAndEmptyTags(c *check.C) {
 	var requests int32
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,194 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tag provides a tagging facility shared by apps, pools and service
+// instances. Tags are stored in the tags field of each object and may be used
+// to grant permissions to every object with a given tag.
+package tag
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	KindApp             = "app"
+	KindPool            = "pool"
+	KindServiceInstance = "service-instance"
+)
+
+var (
+	ErrInvalidTag     = errors.New("invalid tag, tags must not be empty")
+	ErrInvalidKind    = errors.New("invalid kind, must be app, pool or service-instance")
+	ErrObjectNotFound = errors.New("object not found")
+
+	Kinds = []string{KindApp, KindPool, KindServiceInstance}
+)
+
+// Object identifies a tagged object. Service instances are named after the
+// service and the instance, separated by a slash.
+type Object struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// Normalize trims the tags and removes duplicated ones, failing if any of
+// them is empty.
+func Normalize(tags []string) ([]string, error) {
+	result := make([]string, 0, len(tags))
+	used := map[string]bool{}
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			return nil, ErrInvalidTag
+		}
+		if !used[t] {
+			used[t] = true
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+// Get returns the tags of the object.
+func Get(obj Object) ([]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	coll, query, err := objectQuery(conn, obj)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Tags []string
+	}
+	err = coll.Find(query).Select(bson.M{"tags": 1}).One(&result)
+	if err == mgo.ErrNotFound {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.Tags == nil {
+		result.Tags = []string{}
+	}
+	return result.Tags, nil
+}
+
+// Add adds the tags to the object, ignoring the ones it already has.
+func Add(obj Object, tags ...string) error {
+	tags, err := Normalize(tags)
+	if err != nil {
+		return err
+	}
+	return update(obj, bson.M{"$addToSet": bson.M{"tags": bson.M{"$each": tags}}})
+}
+
+// Remove removes the tags from the object.
+func Remove(obj Object, tags ...string) error {
+	tags, err := Normalize(tags)
+	if err != nil {
+		return err
+	}
+	return update(obj, bson.M{"$pullAll": bson.M{"tags": tags}})
+}
+
+// List returns all objects with the tag, sorted by kind and name.
+func List(tag string) ([]Object, error) {
+	var result []Object
+	for _, kind := range Kinds {
+		names, err := Names(kind, tag)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			result = append(result, Object{Kind: kind, Name: n})
+		}
+	}
+	return result, nil
+}
+
+// Names returns the sorted names of the objects of the kind with the tag.
+func Names(kind, tag string) ([]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := bson.M{"tags": tag}
+	var names []string
+	switch kind {
+	case KindApp:
+		var apps []struct{ Name string }
+		err = conn.Apps().Find(query).Select(bson.M{"name": 1}).All(&apps)
+		for _, a := range apps {
+			names = append(names, a.Name)
+		}
+	case KindPool:
+		var pools []struct {
+			Name string `bson:"_id"`
+		}
+		err = conn.Pools().Find(query).Select(bson.M{"_id": 1}).All(&pools)
+		for _, p := range pools {
+			names = append(names, p.Name)
+		}
+	case KindServiceInstance:
+		var instances []struct {
+			Name        string
+			ServiceName string `bson:"service_name"`
+		}
+		err = conn.ServiceInstances().Find(query).Select(bson.M{"name": 1, "service_name": 1}).All(&instances)
+		for _, si := range instances {
+			names = append(names, si.ServiceName+"/"+si.Name)
+		}
+	default:
+		return nil, ErrInvalidKind
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func update(obj Object, change bson.M) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll, query, err := objectQuery(conn, obj)
+	if err != nil {
+		return err
+	}
+	err = coll.Update(query, change)
+	if err == mgo.ErrNotFound {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+func objectQuery(conn *db.Storage, obj Object) (*storage.Collection, bson.M, error) {
+	switch obj.Kind {
+	case KindApp:
+		return conn.Apps(), bson.M{"name": obj.Name}, nil
+	case KindPool:
+		return conn.Pools(), bson.M{"_id": obj.Name}, nil
+	case KindServiceInstance:
+		parts := strings.SplitN(obj.Name, "/", 2)
+		if len(parts) != 2 {
+			return nil, nil, ErrObjectNotFound
+		}
+		return conn.ServiceInstances(), bson.M{"service_name": parts[0], "name": parts[1]}, nil
+	}
+	return nil, nil, ErrInvalidKind
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tag
+
+import (
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestNormalize(c *check.C) {
+	tags, err := Normalize([]string{" region=eu", "team=a", "region=eu "})
+	c.Assert(err, check.IsNil)
+	c.Assert(tags, check.DeepEquals, []string{"region=eu", "team=a"})
+	_, err = Normalize([]string{"a", "  "})
+	c.Assert(err, check.Equals, ErrInvalidTag)
+}
+
+func (s *S) TestAddAndRemove(c *check.C) {
+	err := s.conn.Apps().Insert(bson.M{"name": "myapp", "tags": []string{"old"}})
+	c.Assert(err, check.IsNil)
+	obj := Object{Kind: KindApp, Name: "myapp"}
+	err = Add(obj, "region=eu", "old")
+	c.Assert(err, check.IsNil)
+	tags, err := Get(obj)
+	c.Assert(err, check.IsNil)
+	c.Assert(tags, check.DeepEquals, []string{"old", "region=eu"})
+	err = Remove(obj, "old")
+	c.Assert(err, check.IsNil)
+	tags, err = Get(obj)
+	c.Assert(err, check.IsNil)
+	c.Assert(tags, check.DeepEquals, []string{"region=eu"})
+}
+
+func (s *S) TestAddInvalid(c *check.C) {
+	err := Add(Object{Kind: KindApp, Name: "unknown"}, "region=eu")
+	c.Assert(err, check.Equals, ErrObjectNotFound)
+	err = Add(Object{Kind: "volume", Name: "v1"}, "region=eu")
+	c.Assert(err, check.Equals, ErrInvalidKind)
+	err = Add(Object{Kind: KindServiceInstance, Name: "noservice"}, "region=eu")
+	c.Assert(err, check.Equals, ErrObjectNotFound)
+	err = Add(Object{Kind: KindPool, Name: "pool1"}, "")
+	c.Assert(err, check.Equals, ErrInvalidTag)
+}
+
+func (s *S) TestList(c *check.C) {
+	err := s.conn.Apps().Insert(bson.M{"name": "myapp", "tags": []string{"region=eu"}}, bson.M{"name": "other"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Pools().Insert(bson.M{"_id": "pool1", "tags": []string{"region=eu", "x"}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Insert(bson.M{"name": "db1", "service_name": "mysql", "tags": []string{"region=eu"}})
+	c.Assert(err, check.IsNil)
+	objs, err := List("region=eu")
+	c.Assert(err, check.IsNil)
+	c.Assert(objs, check.DeepEquals, []Object{
+		{Kind: KindApp, Name: "myapp"},
+		{Kind: KindPool, Name: "pool1"},
+		{Kind: KindServiceInstance, Name: "mysql/db1"},
+	})
+	tags, err := Get(Object{Kind: KindServiceInstance, Name: "mysql/db1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(tags, check.DeepEquals, []string{"region=eu"})
+	names, err := Names(KindApp, "x")
+	c.Assert(err, check.IsNil)
+	c.Assert(names, check.HasLen, 0)
+}